@@ -35,6 +35,7 @@ export interface AnalysisFact {
   included: boolean;
   confirmed: boolean;
   source: string;
+  version: number;
 }
 
 export interface AnalysisGap {
@@ -42,10 +43,12 @@ export interface AnalysisGap {
   text: string;
   selected: boolean;
   resolved: boolean;
+  version: number;
 }
 
 export interface AnalysisDetail {
   id: number;
+  version: number;
   title: string;
   category: string;
   status: string;
@@ -95,18 +98,21 @@ interface AnalysePayload {
 }
 
 interface UpdateFactPayload {
+  version: number;
   text?: string;
   included?: boolean;
   confirmed?: boolean;
 }
 
 interface UpdateGapPayload {
+  version: number;
   text?: string;
   selected?: boolean;
   resolved?: boolean;
 }
 
 export interface UpdateAnalysisPayload {
+  version: number;
   status?: "draft" | "pending" | "completed";
   category?: string;
   selectedFormat?: string;
@@ -140,6 +146,68 @@ function compactPayload<T extends Record<string, unknown>>(payload: T): T {
   ) as T;
 }
 
+// Codes the backend sends as "code" on errors; see backend/models/errors.go.
+export type ApiErrorCode =
+  | "ERR_INVALID_REQUEST"
+  | "ERR_UNAUTHENTICATED"
+  | "ERR_FORBIDDEN"
+  | "ERR_FEATURE_DISABLED"
+  | "ERR_NOT_FOUND"
+  | "ERR_VERSION_CONFLICT"
+  | "ERR_VERSION_REQUIRED"
+  | "ERR_NOTHING_TO_UNDO"
+  | "ERR_NOTHING_TO_REDO"
+  | "ERR_BODY_TOO_LARGE"
+  | "ERR_URL_UNREACHABLE"
+  | "ERR_URL_NO_TEXT"
+  | "ERR_EMPTY_FACTS"
+  | "ERR_CONTENT_SPAM"
+  | "ERR_CONTENT_ADULT"
+  | "ERR_CONTENT_GIBBERISH"
+  | "ERR_NOT_CONFIGURED"
+  | "ERR_PROVIDER_UNAVAILABLE"
+  | "ERR_PROVIDER_QUOTA"
+  | "ERR_INTERNAL";
+
+export class ApiError extends Error {
+  constructor(
+    message: string,
+    readonly status: number,
+    readonly code?: ApiErrorCode,
+    readonly errorId?: string,
+  ) {
+    super(message);
+    this.name = "ApiError";
+  }
+}
+
+const friendlyErrorMessages: Partial<Record<ApiErrorCode, string>> = {
+  ERR_URL_UNREACHABLE: "The article URL could not be opened. Check the link, or paste the text instead.",
+  ERR_URL_NO_TEXT: "No readable article text was found at that URL. Try pasting the text instead.",
+  ERR_EMPTY_FACTS: "No facts could be extracted from this input. Try a longer or more detailed article.",
+  ERR_CONTENT_SPAM: "This looks like advertising or spam rather than a news story, so it was not analysed.",
+  ERR_CONTENT_ADULT: "This looks like adult content rather than a news story, so it was not analysed.",
+  ERR_CONTENT_GIBBERISH: "This doesn't read as text in any language, so it was not analysed.",
+  ERR_PROVIDER_QUOTA: "The AI provider's usage limit has been reached. Try again in a few minutes.",
+  ERR_PROVIDER_UNAVAILABLE: "The AI provider is unavailable right now. Try again shortly.",
+  ERR_NOT_CONFIGURED: "This feature is not set up on the server yet.",
+};
+
+// errorMessage picks the message to show for error: a targeted one for codes
+// the user can act on, otherwise the server's message.
+export function errorMessage(error: Error): string {
+  if (error instanceof ApiError) {
+    const friendly = error.code ? friendlyErrorMessages[error.code] : undefined;
+    if (friendly) {
+      return friendly;
+    }
+    if (error.errorId) {
+      return `${error.message} (error ID ${error.errorId})`;
+    }
+  }
+  return error.message;
+}
+
 async function request<T>(path: string, init?: RequestInit): Promise<T> {
   const headers = new Headers(init?.headers);
   const method = (init?.method ?? "GET").toUpperCase();
@@ -168,9 +236,10 @@ async function request<T>(path: string, init?: RequestInit): Promise<T> {
 
   if (!response.ok) {
     if (typeof payload === "object" && payload !== null && "error" in payload) {
-      throw new Error(String((payload as { error?: unknown }).error ?? "Request failed"));
+      const body = payload as { error?: unknown; code?: ApiErrorCode; errorId?: string };
+      throw new ApiError(String(body.error ?? "Request failed"), response.status, body.code, body.errorId);
     }
-    throw new Error(`Request failed (${response.status})`);
+    throw new ApiError(`Request failed (${response.status})`, response.status);
   }
 
   return payload as T;
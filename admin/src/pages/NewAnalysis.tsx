@@ -15,7 +15,7 @@ import {
   SelectTrigger,
   SelectValue,
 } from "@/components/ui/select";
-import { api, type UpdateAnalysisPayload } from "@/lib/api";
+import { api, errorMessage, type UpdateAnalysisPayload } from "@/lib/api";
 import { cn } from "@/lib/utils";
 import { useToast } from "@/hooks/use-toast";
 import {
@@ -204,7 +204,7 @@ const NewAnalysis = () => {
     onError: (error: Error) => {
       toast({
         title: "Analysis failed",
-        description: error.message,
+        description: errorMessage(error),
         variant: "destructive",
       });
     },
@@ -213,7 +213,7 @@ const NewAnalysis = () => {
   const updateFactMutation = useMutation({
     mutationFn: (params: {
       factID: number;
-      payload: { text?: string; included?: boolean; confirmed?: boolean };
+      payload: { version: number; text?: string; included?: boolean; confirmed?: boolean };
     }) => api.updateFact(params.factID, params.payload),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["analysis", analysisID] });
@@ -223,7 +223,7 @@ const NewAnalysis = () => {
     onError: (error: Error) => {
       toast({
         title: "Fact update failed",
-        description: error.message,
+        description: errorMessage(error),
         variant: "destructive",
       });
     },
@@ -239,14 +239,14 @@ const NewAnalysis = () => {
     onError: (error: Error) => {
       toast({
         title: "Delete failed",
-        description: error.message,
+        description: errorMessage(error),
         variant: "destructive",
       });
     },
   });
 
   const updateGapMutation = useMutation({
-    mutationFn: (params: { gapID: number; payload: { text?: string; selected?: boolean } }) =>
+    mutationFn: (params: { gapID: number; payload: { version: number; text?: string; selected?: boolean } }) =>
       api.updateGap(params.gapID, params.payload),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["analysis", analysisID] });
@@ -256,7 +256,7 @@ const NewAnalysis = () => {
     onError: (error: Error) => {
       toast({
         title: "Gap update failed",
-        description: error.message,
+        description: errorMessage(error),
         variant: "destructive",
       });
     },
@@ -277,7 +277,7 @@ const NewAnalysis = () => {
     onError: (error: Error) => {
       toast({
         title: "Add fact failed",
-        description: error.message,
+        description: errorMessage(error),
         variant: "destructive",
       });
     },
@@ -294,7 +294,7 @@ const NewAnalysis = () => {
     onError: (error: Error) => {
       toast({
         title: "Save failed",
-        description: error.message,
+        description: errorMessage(error),
         variant: "destructive",
       });
     },
@@ -406,12 +406,12 @@ const NewAnalysis = () => {
     });
   };
 
-  const toggleFact = (factID: number, included: boolean) => {
-    updateFactMutation.mutate({ factID, payload: { included: !included } });
+  const toggleFact = (fact: { id: number; included: boolean; version: number }) => {
+    updateFactMutation.mutate({ factID: fact.id, payload: { version: fact.version, included: !fact.included } });
   };
 
-  const toggleGap = (gapID: number, selected: boolean) => {
-    updateGapMutation.mutate({ gapID, payload: { selected: !selected } });
+  const toggleGap = (gap: { id: number; selected: boolean; version: number }) => {
+    updateGapMutation.mutate({ gapID: gap.id, payload: { version: gap.version, selected: !gap.selected } });
   };
 
   const startEdit = (fact: { id: number; text: string }) => {
@@ -420,6 +420,9 @@ const NewAnalysis = () => {
   };
 
   const saveEdit = (factID: number) => {
+    const fact = facts.find((item) => item.id === factID);
+    if (!fact) return;
+
     const clean = editText.trim();
     if (clean === "") {
       toast({
@@ -431,7 +434,7 @@ const NewAnalysis = () => {
     }
 
     updateFactMutation.mutate(
-      { factID, payload: { text: clean } },
+      { factID, payload: { version: fact.version, text: clean } },
       { onSuccess: () => setEditingID(null) },
     );
   };
@@ -461,10 +464,10 @@ const NewAnalysis = () => {
   };
 
   const continueToWriter = () => {
-    if (!analysisID) return;
+    if (!analysisID || !analysisQuery.data) return;
 
     updateAnalysisMutation.mutate(
-      { selectedFormat, status: "pending" },
+      { version: analysisQuery.data.version, selectedFormat, status: "pending" },
       { onSuccess: () => moveToStep(4) },
     );
   };
@@ -493,10 +496,11 @@ const NewAnalysis = () => {
   };
 
   const saveDraft = () => {
-    if (!analysisID) return;
+    if (!analysisID || !analysisQuery.data) return;
 
     updateAnalysisMutation.mutate(
       {
+        version: analysisQuery.data.version,
         status: "draft",
         category: category || undefined,
         selectedFormat,
@@ -658,7 +662,7 @@ const NewAnalysis = () => {
                   <div key={fact.id} className="flex items-start gap-3 px-5 py-3 transition-colors hover:bg-muted/30">
                     <Checkbox
                       checked={fact.included}
-                      onCheckedChange={() => toggleFact(fact.id, fact.included)}
+                      onCheckedChange={() => toggleFact(fact)}
                       className="mt-1"
                     />
 
@@ -738,7 +742,7 @@ const NewAnalysis = () => {
                   <div key={gap.id} className="flex items-start gap-3 px-5 py-3 transition-colors hover:bg-muted/30">
                     <Checkbox
                       checked={gap.selected}
-                      onCheckedChange={() => toggleGap(gap.id, gap.selected)}
+                      onCheckedChange={() => toggleGap(gap)}
                       className="mt-1"
                     />
                     <span className="flex-1 text-sm leading-normal text-foreground">{gap.text}</span>
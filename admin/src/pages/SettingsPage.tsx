@@ -10,7 +10,7 @@ import {
 } from "@/components/ui/select";
 import { CheckCircle2, Save } from "lucide-react";
 import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
-import { api } from "@/lib/api";
+import { api, errorMessage } from "@/lib/api";
 import { useToast } from "@/hooks/use-toast";
 import {
   Card,
@@ -58,7 +58,7 @@ const SettingsPage = () => {
     onError: (error: Error) => {
       toast({
         title: "Save failed",
-        description: error.message,
+        description: errorMessage(error),
         variant: "destructive",
       });
     },
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"nanoheads/client"
+	"nanoheads/config"
+	"nanoheads/db"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+var cliCommands = map[string]struct{}{"analyse": {}, "list": {}, "export": {}}
+
+func isCLICommand(name string) bool {
+	_, ok := cliCommands[name]
+	return ok
+}
+
+// pipeline is what the CLI commands need from nanoheads: the API via the
+// client, or the services on the database when run next to it.
+type pipeline interface {
+	Analyse(ctx context.Context, request client.AnalyseRequest) (models.PhaseOneResponse, error)
+	ListAnalyses(ctx context.Context, options client.ListOptions) ([]models.AnalysisListItem, error)
+	GetAnalysis(ctx context.Context, analysisID int64) (models.AnalysisDetail, error)
+	Close() error
+}
+
+type cliOptions struct {
+	apiURL string
+	apiKey string
+}
+
+// runCLI runs the scripting commands. With --api they talk to a running
+// server; without it they open the database from the environment, as the
+// server does.
+func runCLI(args []string) error {
+	options := &cliOptions{}
+	root := &cobra.Command{
+		Use:           "nanoheads",
+		Short:         "Analyse stories and read analyses from the command line",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVar(&options.apiURL, "api", os.Getenv("NANOHEADS_API_URL"), "API base URL; the database is used directly when empty")
+	root.PersistentFlags().StringVar(&options.apiKey, "api-key", os.Getenv("NANOHEADS_API_KEY"), "API key for --api")
+
+	root.AddCommand(analyseCommand(options), listCommand(options), exportCommand(options))
+	root.SetArgs(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return root.ExecuteContext(ctx)
+}
+
+func (o *cliOptions) open() (pipeline, error) {
+	if o.apiURL != "" {
+		return apiPipeline{client.New(o.apiURL, o.apiKey, client.WithSubmissionChannel(models.SubmissionChannelCLI))}, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	database, err := db.Connect(cfg.DatabaseURL, cfg.DBDriver)
+	if err != nil {
+		return nil, fmt.Errorf("database connection failed: %w", err)
+	}
+	db.ConfigurePool(database, db.PoolSettings{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime.Duration,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime.Duration,
+	})
+	return &dbPipeline{
+		database:  database,
+		facts:     services.NewFactService(database),
+		admin:     services.NewAdminService(database),
+		languages: services.NewLanguageService(database),
+	}, nil
+}
+
+type apiPipeline struct {
+	*client.Client
+}
+
+func (apiPipeline) Close() error { return nil }
+
+// dbPipeline runs as an operator on the server: it sees source text and
+// diagnostics, and has no user for "me" filters.
+type dbPipeline struct {
+	database  *sql.DB
+	facts     *services.FactService
+	admin     *services.AdminService
+	languages *services.LanguageService
+}
+
+var operatorVisibility = models.Visibility{SourceText: true, Diagnostics: true}
+
+func (p *dbPipeline) Analyse(ctx context.Context, request client.AnalyseRequest) (models.PhaseOneResponse, error) {
+	language := strings.TrimSpace(request.Language)
+	if language != "" {
+		resolved, err := p.languages.Resolve(ctx, language)
+		if err != nil {
+			return models.PhaseOneResponse{}, err
+		}
+		language = resolved.Name
+	}
+	articleMode, err := services.NormalizeArticleMode(request.ArticleMode)
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+
+	text := strings.TrimSpace(request.Text)
+	params := map[string]any{
+		"url":        request.URL,
+		"language":   language,
+		"category":   request.Category,
+		"textLength": len([]rune(text)),
+	}
+	return p.facts.RunPhaseOne(ctx, models.PhaseOneInput{
+		Text:        text,
+		URL:         strings.TrimSpace(request.URL),
+		Language:    language,
+		Category:    strings.TrimSpace(request.Category),
+		ArticleMode: articleMode,
+		Render:      request.Render,
+		Submission:  &models.Submission{Channel: models.SubmissionChannelCLI, Params: params},
+	})
+}
+
+func (p *dbPipeline) ListAnalyses(ctx context.Context, options client.ListOptions) ([]models.AnalysisListItem, error) {
+	filter := models.AnalysisFilter{Status: options.Status, Category: options.Category, Days: options.Days}
+	if strings.EqualFold(options.AssignedTo, "none") {
+		filter.Unassigned = true
+	} else if options.AssignedTo != "" {
+		id, err := parseCLIUserID("assigned-to", options.AssignedTo)
+		if err != nil {
+			return nil, err
+		}
+		filter.AssignedTo = &id
+	}
+	if options.CreatedBy != "" {
+		id, err := parseCLIUserID("created-by", options.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		filter.CreatedBy = &id
+	}
+	return p.admin.ListAnalyses(ctx, options.Limit, filter, operatorVisibility)
+}
+
+func (p *dbPipeline) GetAnalysis(ctx context.Context, analysisID int64) (models.AnalysisDetail, error) {
+	return p.admin.GetAnalysisDetail(ctx, analysisID, operatorVisibility)
+}
+
+func (p *dbPipeline) Close() error { return p.database.Close() }
+
+func parseCLIUserID(flag string, value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		if strings.EqualFold(value, "me") {
+			return 0, fmt.Errorf("--%s me needs --api", flag)
+		}
+		return 0, fmt.Errorf("invalid --%s %q", flag, value)
+	}
+	return id, nil
+}
+
+func analyseCommand(options *cliOptions) *cobra.Command {
+	var (
+		request  client.AnalyseRequest
+		file     string
+		urlsFile string
+		asJSON   bool
+	)
+	command := &cobra.Command{
+		Use:   "analyse",
+		Short: "Analyse a story from a URL or text",
+		Long: "Analyse a story given by --url, --text or --file (- reads stdin). With --urls-file every URL in the file, " +
+			"one per line, is analysed in turn; blank lines and lines starting with # are skipped, and a failed URL " +
+			"doesn't stop the rest.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if file != "" {
+				text, err := readCLIInput(cmd.InOrStdin(), file)
+				if err != nil {
+					return err
+				}
+				request.Text = text
+			}
+
+			var urls []string
+			if urlsFile != "" {
+				if request.URL != "" || request.Text != "" {
+					return errors.New("--urls-file can't be combined with --url, --text or --file")
+				}
+				content, err := readCLIInput(cmd.InOrStdin(), urlsFile)
+				if err != nil {
+					return err
+				}
+				for _, line := range strings.Split(content, "\n") {
+					if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+						urls = append(urls, line)
+					}
+				}
+				if len(urls) == 0 {
+					return errors.New("--urls-file has no URLs")
+				}
+			} else if strings.TrimSpace(request.URL) == "" && strings.TrimSpace(request.Text) == "" {
+				return errors.New("provide --url, --text, --file or --urls-file")
+			}
+
+			p, err := options.open()
+			if err != nil {
+				return err
+			}
+			defer p.Close()
+
+			if urls == nil {
+				result, err := p.Analyse(cmd.Context(), request)
+				if err != nil {
+					return err
+				}
+				return printAnalyseResult(cmd.OutOrStdout(), result, asJSON)
+			}
+
+			failed := 0
+			for _, url := range urls {
+				if err := cmd.Context().Err(); err != nil {
+					return err
+				}
+				request.URL = url
+				result, err := p.Analyse(cmd.Context(), request)
+				if err != nil {
+					failed++
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", url, err)
+					continue
+				}
+				if err := printAnalyseResult(cmd.OutOrStdout(), result, asJSON); err != nil {
+					return err
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d URLs failed", failed, len(urls))
+			}
+			return nil
+		},
+	}
+	flags := command.Flags()
+	flags.StringVar(&request.URL, "url", "", "URL of the story")
+	flags.StringVar(&request.Text, "text", "", "text of the story")
+	flags.StringVar(&file, "file", "", "read the story's text from a file, or - for stdin")
+	flags.StringVar(&urlsFile, "urls-file", "", "analyse each URL in a file, or - for stdin")
+	flags.StringVar(&request.Language, "language", "", "output language")
+	flags.StringVar(&request.Category, "category", "", "category of the analysis")
+	flags.StringVar(&request.ArticleMode, "mode", "", "article mode: paragraph or long-form")
+	flags.BoolVar(&request.Render, "render", false, "load URLs in a headless browser (needs RENDER_ENABLED on the server)")
+	flags.BoolVar(&asJSON, "json", false, "print each result as a JSON line")
+	return command
+}
+
+func readCLIInput(stdin io.Reader, path string) (string, error) {
+	if path == "-" {
+		content, err := io.ReadAll(stdin)
+		return string(content), err
+	}
+	content, err := os.ReadFile(path)
+	return string(content), err
+}
+
+func printAnalyseResult(out io.Writer, result models.PhaseOneResponse, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(out).Encode(result)
+	}
+	_, err := fmt.Fprintf(out, "analysis %d: %d facts, %d open questions (%s)\n", result.ArticleID, len(result.Facts), len(result.Gaps), result.Language)
+	return err
+}
+
+func addListFlags(command *cobra.Command, options *client.ListOptions, defaultLimit int) {
+	flags := command.Flags()
+	flags.IntVar(&options.Limit, "limit", defaultLimit, "maximum number of analyses")
+	flags.StringVar(&options.Status, "status", "", "only analyses with this status")
+	flags.StringVar(&options.Category, "category", "", "only analyses in this category")
+	flags.StringVar(&options.AssignedTo, "assigned-to", "", "a user id, me or none")
+	flags.StringVar(&options.CreatedBy, "created-by", "", "a user id or me")
+	flags.IntVar(&options.Days, "days", 0, "only analyses from the last N days")
+}
+
+func listCommand(options *cliOptions) *cobra.Command {
+	var (
+		listOptions client.ListOptions
+		asJSON      bool
+	)
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List analyses, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			p, err := options.open()
+			if err != nil {
+				return err
+			}
+			defer p.Close()
+
+			items, err := p.ListAnalyses(cmd.Context(), listOptions)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				for _, item := range items {
+					if err := encoder.Encode(item); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			table := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(table, "ID\tCREATED\tSTATUS\tCATEGORY\tTITLE")
+			for _, item := range items {
+				fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", item.ID, item.CreatedAt.Local().Format("2006-01-02 15:04"), item.Status, item.Category, item.Title)
+			}
+			return table.Flush()
+		},
+	}
+	addListFlags(command, &listOptions, 50)
+	command.Flags().BoolVar(&asJSON, "json", false, "print each analysis as a JSON line")
+	return command
+}
+
+var exportColumns = []string{"id", "created_at", "status", "category", "title", "headline", "strapline", "source_url", "language", "facts", "open_questions", "article"}
+
+func exportCommand(options *cliOptions) *cobra.Command {
+	var (
+		listOptions client.ListOptions
+		format      string
+		output      string
+	)
+	command := &cobra.Command{
+		Use:   "export",
+		Short: "Export analyses with their facts and open questions",
+		Long: "Export analyses matching the filters, newest first. jsonl writes each analysis as returned by the API; " +
+			"csv writes one row per analysis with included facts and unresolved questions joined by newlines.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if format != "jsonl" && format != "csv" {
+				return fmt.Errorf("--format must be jsonl or csv")
+			}
+
+			p, err := options.open()
+			if err != nil {
+				return err
+			}
+			defer p.Close()
+
+			items, err := p.ListAnalyses(cmd.Context(), listOptions)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+			buffered := bufio.NewWriter(out)
+
+			var write func(models.AnalysisDetail) error
+			var csvWriter *csv.Writer
+			if format == "csv" {
+				csvWriter = csv.NewWriter(buffered)
+				if err := csvWriter.Write(exportColumns); err != nil {
+					return err
+				}
+				write = func(detail models.AnalysisDetail) error { return csvWriter.Write(exportRow(detail)) }
+			} else {
+				encoder := json.NewEncoder(buffered)
+				write = func(detail models.AnalysisDetail) error { return encoder.Encode(detail) }
+			}
+
+			for _, item := range items {
+				detail, err := p.GetAnalysis(cmd.Context(), item.ID)
+				if err != nil {
+					return fmt.Errorf("analysis %d: %w", item.ID, err)
+				}
+				if err := write(detail); err != nil {
+					return err
+				}
+			}
+			if csvWriter != nil {
+				csvWriter.Flush()
+				if err := csvWriter.Error(); err != nil {
+					return err
+				}
+			}
+			if err := buffered.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "exported %d analyses\n", len(items))
+			return nil
+		},
+	}
+	addListFlags(command, &listOptions, 100)
+	command.Flags().StringVar(&format, "format", "jsonl", "jsonl or csv")
+	command.Flags().StringVarP(&output, "output", "o", "", "write to a file instead of stdout")
+	return command
+}
+
+func exportRow(detail models.AnalysisDetail) []string {
+	facts := make([]string, 0, len(detail.Facts))
+	for _, fact := range detail.Facts {
+		if fact.Included {
+			facts = append(facts, fact.Text)
+		}
+	}
+	questions := make([]string, 0, len(detail.Gaps))
+	for _, gap := range detail.Gaps {
+		if !gap.Resolved {
+			questions = append(questions, gap.Text)
+		}
+	}
+	return []string{
+		strconv.FormatInt(detail.ID, 10),
+		detail.CreatedAt.UTC().Format(time.RFC3339),
+		detail.Status,
+		detail.Category,
+		detail.Title,
+		detail.HeadlineSelected,
+		detail.StraplineSelected,
+		detail.SourceURL,
+		detail.OutputLanguage,
+		strings.Join(facts, "\n"),
+		strings.Join(questions, "\n"),
+		detail.ArticleText,
+	}
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"nanoheads/models"
+)
+
+// AnalyseRequest is a story to analyse: text, a URL, content of a page the
+// caller already fetched, or several sources at once.
+type AnalyseRequest struct {
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Language string `json:"language,omitempty"`
+	Category string `json:"category,omitempty"`
+
+	// ArticleMode is "paragraph" (the default) or "long-form".
+	ArticleMode string                `json:"articleMode,omitempty"`
+	Lengths     *models.LengthTargets `json:"lengths,omitempty"`
+
+	Sources []AnalyseSource `json:"sources,omitempty"`
+	URLs    []string        `json:"urls,omitempty"`
+	Texts   []string        `json:"texts,omitempty"`
+	Images  []AnalyseImage  `json:"images,omitempty"`
+
+	// Render asks the server to load URLs in a headless browser.
+	Render bool `json:"render,omitempty"`
+}
+
+type AnalyseSource struct {
+	Text    string `json:"text,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// AnalyseImage is an image to read text from. Data is base64 or a data: URL.
+type AnalyseImage struct {
+	Data     string `json:"data"`
+	FileName string `json:"fileName,omitempty"`
+}
+
+type MergeRequest struct {
+	IDs         []int64               `json:"ids"`
+	ArticleMode string                `json:"articleMode,omitempty"`
+	Lengths     *models.LengthTargets `json:"lengths,omitempty"`
+}
+
+// ListOptions filters ListAnalyses. AssignedTo takes a user id, "me" or
+// "none"; CreatedBy a user id or "me".
+type ListOptions struct {
+	Limit      int
+	ViewID     int64
+	Status     string
+	Category   string
+	AssignedTo string
+	CreatedBy  string
+	Days       int
+}
+
+// AnalysisUpdate changes the fields that are set and leaves the rest.
+type AnalysisUpdate struct {
+	Status            *string `json:"status,omitempty"`
+	Category          *string `json:"category,omitempty"`
+	SelectedFormat    *string `json:"selectedFormat,omitempty"`
+	ArticleText       *string `json:"articleText,omitempty"`
+	HeadlineSelected  *string `json:"headlineSelected,omitempty"`
+	StraplineSelected *string `json:"straplineSelected,omitempty"`
+	Slug              *string `json:"slug,omitempty"`
+	MetaDescription   *string `json:"metaDescription,omitempty"`
+	Excerpt           *string `json:"excerpt,omitempty"`
+}
+
+type FactUpdate struct {
+	Text      *string `json:"text,omitempty"`
+	Included  *bool   `json:"included,omitempty"`
+	Confirmed *bool   `json:"confirmed,omitempty"`
+}
+
+type GapUpdate struct {
+	Text     *string `json:"text,omitempty"`
+	Selected *bool   `json:"selected,omitempty"`
+	Resolved *bool   `json:"resolved,omitempty"`
+}
+
+// Analyse extracts facts and open questions from a story and writes an
+// article with headline and strapline options. It is not retried once the
+// server has accepted the request, so a slow analysis isn't run twice.
+func (c *Client) Analyse(ctx context.Context, request AnalyseRequest) (models.PhaseOneResponse, error) {
+	var response models.PhaseOneResponse
+	err := c.do(ctx, http.MethodPost, "/api/analyse", nil, request, &response)
+	return response, err
+}
+
+func (c *Client) MergeAnalyses(ctx context.Context, request MergeRequest) (models.PhaseOneResponse, error) {
+	var response models.PhaseOneResponse
+	err := c.do(ctx, http.MethodPost, "/api/analyses/merge", nil, request, &response)
+	return response, err
+}
+
+// RetryAnalysis re-runs a failed analysis from the step it failed at.
+func (c *Client) RetryAnalysis(ctx context.Context, analysisID int64) (models.PhaseOneResponse, error) {
+	var response models.PhaseOneResponse
+	err := c.do(ctx, http.MethodPost, analysisPath(analysisID)+"/retry", nil, nil, &response)
+	return response, err
+}
+
+func (c *Client) ListAnalyses(ctx context.Context, options ListOptions) ([]models.AnalysisListItem, error) {
+	query := url.Values{}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.ViewID > 0 {
+		query.Set("viewId", strconv.FormatInt(options.ViewID, 10))
+	}
+	if options.Days > 0 {
+		query.Set("days", strconv.Itoa(options.Days))
+	}
+	for key, value := range map[string]string{
+		"status":     options.Status,
+		"category":   options.Category,
+		"assignedTo": options.AssignedTo,
+		"createdBy":  options.CreatedBy,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	var response struct {
+		Items []models.AnalysisListItem `json:"items"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/analyses", query, nil, &response)
+	return response.Items, err
+}
+
+func (c *Client) GetAnalysis(ctx context.Context, analysisID int64) (models.AnalysisDetail, error) {
+	var detail models.AnalysisDetail
+	err := c.do(ctx, http.MethodGet, analysisPath(analysisID), nil, nil, &detail)
+	return detail, err
+}
+
+func (c *Client) UpdateAnalysis(ctx context.Context, analysisID int64, update AnalysisUpdate) (models.AnalysisDetail, error) {
+	var detail models.AnalysisDetail
+	err := c.do(ctx, http.MethodPatch, analysisPath(analysisID), nil, update, &detail)
+	return detail, err
+}
+
+func (c *Client) AssignAnalysis(ctx context.Context, analysisID int64, userID int64) (models.AnalysisDetail, error) {
+	var detail models.AnalysisDetail
+	body := struct {
+		UserID int64 `json:"userId"`
+	}{UserID: userID}
+	err := c.do(ctx, http.MethodPut, analysisPath(analysisID)+"/assignee", nil, body, &detail)
+	return detail, err
+}
+
+func (c *Client) UnassignAnalysis(ctx context.Context, analysisID int64) (models.AnalysisDetail, error) {
+	var detail models.AnalysisDetail
+	err := c.do(ctx, http.MethodDelete, analysisPath(analysisID)+"/assignee", nil, nil, &detail)
+	return detail, err
+}
+
+// AddFact adds a fact to an analysis and returns its id.
+func (c *Client) AddFact(ctx context.Context, analysisID int64, text string) (int64, error) {
+	var response struct {
+		ID int64 `json:"id"`
+	}
+	body := struct {
+		Text string `json:"text"`
+	}{Text: text}
+	err := c.do(ctx, http.MethodPost, analysisPath(analysisID)+"/facts", nil, body, &response)
+	return response.ID, err
+}
+
+func (c *Client) UpdateFact(ctx context.Context, factID int64, update FactUpdate) error {
+	return c.do(ctx, http.MethodPatch, "/api/facts/"+strconv.FormatInt(factID, 10), nil, update, nil)
+}
+
+func (c *Client) DeleteFact(ctx context.Context, factID int64) error {
+	return c.do(ctx, http.MethodDelete, "/api/facts/"+strconv.FormatInt(factID, 10), nil, nil, nil)
+}
+
+func (c *Client) UpdateGap(ctx context.Context, gapID int64, update GapUpdate) error {
+	return c.do(ctx, http.MethodPatch, "/api/gaps/"+strconv.FormatInt(gapID, 10), nil, update, nil)
+}
+
+// RunFactChecks queues fact checks of an analysis; poll the job with GetJob.
+func (c *Client) RunFactChecks(ctx context.Context, analysisID int64) (models.Job, error) {
+	var job models.Job
+	err := c.do(ctx, http.MethodPost, analysisPath(analysisID)+"/fact-checks", nil, nil, &job)
+	return job, err
+}
+
+func (c *Client) GetJob(ctx context.Context, jobID int64) (models.Job, error) {
+	var job models.Job
+	err := c.do(ctx, http.MethodGet, "/api/jobs/"+strconv.FormatInt(jobID, 10), nil, nil, &job)
+	return job, err
+}
+
+// Me returns the user the API key belongs to, with their permissions.
+func (c *Client) Me(ctx context.Context) (models.Principal, error) {
+	var principal models.Principal
+	err := c.do(ctx, http.MethodGet, "/api/me", nil, nil, &principal)
+	return principal, err
+}
+
+func analysisPath(analysisID int64) string {
+	return "/api/analyses/" + strconv.FormatInt(analysisID, 10)
+}
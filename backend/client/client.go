@@ -0,0 +1,250 @@
+// Package client is a Go client for the nanoheads API. It authenticates with
+// an API key and retries requests the server turned away because it was busy
+// or rate limiting.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout    = 2 * time.Minute
+	defaultMaxRetries = 3
+	defaultBackoff    = 500 * time.Millisecond
+	maxBackoff        = 30 * time.Second
+)
+
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	userAgent  string
+	channel    string
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default client, which times out after two
+// minutes; analysing a story can take most of that.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetries sets how many times a failed request is tried again and the
+// wait before the first retry, which doubles after each attempt. Zero retries
+// turns retrying off.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = max(maxRetries, 0)
+		if backoff > 0 {
+			c.backoff = backoff
+		}
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithSubmissionChannel records analyses submitted by this client as coming
+// from channel, one of models.SubmissionChannels, instead of "api".
+func WithSubmissionChannel(channel string) Option {
+	return func(c *Client) { c.channel = channel }
+}
+
+// New returns a client for the API at baseURL, such as
+// "https://newsroom.example.com", sending apiKey with every request.
+func New(baseURL string, apiKey string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		apiKey:     strings.TrimSpace(apiKey),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  "nanoheads-go-client",
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// APIError is a response with an error status. Message is the error the
+// server reported; a 422 also lists each invalid field in Fields.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("nanoheads: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func IsNotFound(err error) bool { return hasStatus(err, http.StatusNotFound) }
+
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized) || hasStatus(err, http.StatusForbidden)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// do sends a request and decodes a JSON response into out, which may be nil.
+// Requests that change state are only retried when the server refused them
+// outright: a 429, or a connection that could not be made. Reads and updates
+// that set values are also retried on gateway errors and lost connections.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = encoded
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	idempotent := method != http.MethodPost
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		if payload != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("User-Agent", c.userAgent)
+		if c.apiKey != "" {
+			request.Header.Set("X-API-Key", c.apiKey)
+		}
+		if c.channel != "" {
+			request.Header.Set("X-Submission-Channel", c.channel)
+		}
+
+		response, err := c.httpClient.Do(request)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.maxRetries || !(idempotent || isDialError(err)) {
+				return err
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		retryAfter := parseRetryAfter(response.Header.Get("Retry-After"))
+		err = decodeResponse(response, out)
+		if err == nil {
+			return nil
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || attempt >= c.maxRetries || !shouldRetry(apiErr.StatusCode, idempotent) {
+			return err
+		}
+		if err := c.wait(ctx, attempt, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+func shouldRetry(status int, idempotent bool) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// wait sleeps before the next attempt: the server's Retry-After when it sent
+// one, otherwise an exponential backoff with jitter.
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	delay := retryAfter
+	if delay <= 0 {
+		delay = min(c.backoff<<attempt, maxBackoff)
+		delay = delay/2 + rand.N(delay/2+1)
+	}
+	timer := time.NewTimer(min(delay, maxBackoff))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+func decodeResponse(response *http.Response, out any) error {
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		var payload struct {
+			Error  string       `json:"error"`
+			Fields []FieldError `json:"fields"`
+		}
+		message := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+			message = payload.Error
+		}
+		return &APIError{StatusCode: response.StatusCode, Message: message, Fields: payload.Fields}
+	}
+
+	if out == nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("nanoheads: decode %s response: %w", response.Request.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,1594 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultAllowedOrigin = "https://newsapp-frontned.onrender.com"
+
+const googleIssuer = "https://accounts.google.com"
+
+// Pipeline events that can be sent to webhooks.
+const (
+	EventAnalysisFinished  = "analysis.finished"
+	EventAnalysisFailed    = "analysis.failed"
+	EventAnalysisPublished = "analysis.published"
+)
+
+var PipelineEvents = []string{EventAnalysisFinished, EventAnalysisFailed, EventAnalysisPublished}
+
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		parsed, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		d.Duration = parsed
+		return nil
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return errors.New("duration must be a string like \"30s\" or a number of seconds")
+	}
+	d.Duration = time.Duration(seconds * float64(time.Second))
+	return nil
+}
+
+type TitleRules struct {
+	HeadlineMaxWords  int `json:"headlineMaxWords"`
+	HeadlineMaxChars  int `json:"headlineMaxChars"`
+	StraplineMaxWords int `json:"straplineMaxWords"`
+	StraplineMaxChars int `json:"straplineMaxChars"`
+}
+
+type Config struct {
+	Port            int      `json:"port"`
+	AllowedOrigins  []string `json:"allowedOrigins"`
+	ReadTimeout     Duration `json:"readTimeout"`
+	WriteTimeout    Duration `json:"writeTimeout"`
+	IdleTimeout     Duration `json:"idleTimeout"`
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+	LogLevel        string   `json:"logLevel"`
+
+	// WorkerMode runs the job queue and its schedules without the API,
+	// serving only the probes. ProcessJobs off leaves the queue to such
+	// workers, so the API tier only takes requests.
+	WorkerMode  bool `json:"workerMode"`
+	ProcessJobs bool `json:"processJobs"`
+
+	DatabaseURL string `json:"databaseUrl"`
+	DBDriver    string `json:"dbDriver"`
+	AutoMigrate bool   `json:"autoMigrate"`
+
+	DBMaxOpenConns    int      `json:"dbMaxOpenConns"`
+	DBMaxIdleConns    int      `json:"dbMaxIdleConns"`
+	DBConnMaxLifetime Duration `json:"dbConnMaxLifetime"`
+	DBConnMaxIdleTime Duration `json:"dbConnMaxIdleTime"`
+	DBPingTimeout     Duration `json:"dbPingTimeout"`
+
+	// ReadyCheckProviders makes /readyz also require the selected AI
+	// provider to answer. Off by default, so a provider outage doesn't take
+	// every replica out of the load balancer.
+	ReadyCheckProviders bool `json:"readyCheckProviders"`
+
+	// RedisURL (redis:// or rediss://) moves the LLM cache, per-host fetch
+	// spacing and job wake-ups to Redis, shared by every instance. Unset,
+	// they stay in the database and in process memory.
+	RedisURL string `json:"redisUrl"`
+
+	AuthRequired bool   `json:"authRequired"`
+	AdminAPIKey  string `json:"adminApiKey"`
+
+	// SessionSecret, at least 32 characters, signs the access tokens of
+	// sessions started with POST /api/auth/login. Access tokens last
+	// SessionAccessTTL and are renewed with a refresh token that lasts
+	// SessionRefreshTTL. Sessions are off without a secret.
+	SessionSecret     string   `json:"sessionSecret"`
+	SessionAccessTTL  Duration `json:"sessionAccessTtl"`
+	SessionRefreshTTL Duration `json:"sessionRefreshTtl"`
+
+	// OIDCClientID turns on single sign-on with Google (OIDCIssuer "google")
+	// or another OpenID Connect provider at OIDCIssuer. It starts sessions, so
+	// it needs SessionSecret, and sends people back to AdminURL.
+	// OIDCRedirectURL is this API's /api/auth/sso/callback as registered with
+	// the provider. Someone signing in for the first time gets an account
+	// with OIDCDefaultRole if their email's domain is in OIDCAllowedDomains;
+	// on Google, their Workspace domain instead.
+	OIDCIssuer         string   `json:"oidcIssuer"`
+	OIDCClientID       string   `json:"oidcClientId"`
+	OIDCClientSecret   string   `json:"oidcClientSecret"`
+	OIDCRedirectURL    string   `json:"oidcRedirectUrl"`
+	OIDCAllowedDomains []string `json:"oidcAllowedDomains"`
+	OIDCDefaultRole    string   `json:"oidcDefaultRole"`
+
+	// RequireApproval holds back publishing an analysis until someone other
+	// than the publisher, with the approve permission, has approved the
+	// version being published. Approvals are signed, so turning it on needs
+	// AUTH_REQUIRED or AUTH_ADMIN_API_KEY; the local operator can't approve.
+	RequireApproval bool `json:"requireApproval"`
+
+	// SensitivityCheck has the model read each new analysis for legal risk,
+	// such as defamation or comment on an ongoing trial, and flag what it
+	// finds. Editors can flag analyses by hand either way.
+	SensitivityCheck bool `json:"sensitivityCheck"`
+
+	// PIIRedaction looks for email addresses, phone numbers and ID or
+	// account numbers in submitted text before it is stored or sent to a
+	// model. "mask" replaces them, in the source text and fetched pages
+	// alike; "flag" keeps them but raises a privacy flag on the analysis, so
+	// it can't be completed until someone clears it; "off" does neither.
+	PIIRedaction string `json:"piiRedaction"`
+
+	// MaxBodyBytes caps API request bodies. POST /api/analyse takes more, to
+	// fit its images at MaxImageBytes.
+	MaxBodyBytes int `json:"maxBodyBytes"`
+
+	// URLAllowlist limits submitted URLs to these hosts and their subdomains
+	// when set. URLDenylist hosts are rejected even if allow-listed.
+	URLAllowlist []string `json:"urlAllowlist"`
+	URLDenylist  []string `json:"urlDenylist"`
+
+	// Fetching a submitted URL never connects to loopback, private,
+	// link-local or other internal addresses, redirects included, unless the
+	// range is listed in FetchAllowedNetworks. FetchBlockedNetworks adds
+	// ranges (CIDRs or single addresses) that are always refused.
+	FetchBlockedNetworks []string `json:"fetchBlockedNetworks"`
+	FetchAllowedNetworks []string `json:"fetchAllowedNetworks"`
+	FetchMaxRedirects    int      `json:"fetchMaxRedirects"`
+
+	// FetchUserAgent identifies URL fetches to publishers; its first token is
+	// also the agent matched against robots.txt, which is obeyed unless
+	// FetchRespectRobots is off. Fetches to one host are spaced at least
+	// FetchDomainInterval apart, or the host's Crawl-delay if longer.
+	FetchUserAgent      string   `json:"fetchUserAgent"`
+	FetchRespectRobots  bool     `json:"fetchRespectRobots"`
+	FetchDomainInterval Duration `json:"fetchDomainInterval"`
+
+	// A fetched page with fewer than FetchMinWords words, or one that fails,
+	// is retried through FetchFallbacks in order: "amp" (the page's AMP
+	// version), "google_cache" and "archive" (the latest archive.org
+	// snapshot). Fallback hosts must pass the URL allow and deny lists.
+	FetchMinWords  int      `json:"fetchMinWords"`
+	FetchFallbacks []string `json:"fetchFallbacks"`
+
+	// RenderEnabled lets URL fetches load pages in headless Chrome: when an
+	// analysis asks for it, or when the static page has fewer than
+	// RenderMinWords words (0 turns that off). Chrome is launched from
+	// ChromePath, or the default install, unless RenderBrowserURL points at
+	// a running browser's DevTools websocket.
+	RenderEnabled    bool     `json:"renderEnabled"`
+	RenderMinWords   int      `json:"renderMinWords"`
+	RenderTimeout    Duration `json:"renderTimeout"`
+	ChromePath       string   `json:"chromePath"`
+	RenderBrowserURL string   `json:"renderBrowserUrl"`
+
+	// CategorySuggestions picks how a topic is suggested for analyses
+	// submitted without one: "llm" (falling back to keywords), "keywords" or "off".
+	CategorySuggestions string `json:"categorySuggestions"`
+
+	// LanguageDetection is "llm" to ask the model when the script and word
+	// checks are unsure of the input language, or "heuristic" to never ask.
+	LanguageDetection string `json:"languageDetection"`
+
+	// ContentScreening turns away spam, adult content and gibberish before
+	// the pipeline spends tokens on it. Registry domains marked
+	// skipScreening are let through.
+	ContentScreening bool `json:"contentScreening"`
+
+	// TranslationMemory reuses earlier translations of identical strings
+	// instead of sending them to the model again.
+	TranslationMemory bool `json:"translationMemory"`
+
+	// LLMCacheTTL keeps each completion that long and answers identical
+	// requests from it, so re-running a step doesn't pay for it twice. 0
+	// turns the cache off.
+	LLMCacheTTL Duration `json:"llmCacheTtl"`
+
+	// LLMChunkTokens is about how much source text goes into one fact
+	// extraction call; longer texts are extracted in chunks of that size.
+	LLMChunkTokens int `json:"llmChunkTokens"`
+
+	// LLMParallelism caps how many independent model calls one analysis
+	// makes at once, such as its headline and strapline options.
+	LLMParallelism int `json:"llmParallelism"`
+
+	// MockLLM answers every model call with canned output after
+	// MockLLMLatency, for load tests and offline development.
+	MockLLM        bool     `json:"mockLlm"`
+	MockLLMLatency Duration `json:"mockLlmLatency"`
+
+	// After ProviderFailures consecutive failed calls (0 never) a model
+	// provider is given a rest of ProviderCooldown. Calls meanwhile go to
+	// ProviderFailover, "openai" or "groq", or fail with a 503 when it is
+	// empty or resting too.
+	ProviderFailures int      `json:"providerFailures"`
+	ProviderCooldown Duration `json:"providerCooldown"`
+	ProviderFailover string   `json:"providerFailover"`
+
+	// ProviderKeySecret, 32 bytes in base64, seals provider API keys saved
+	// through the settings API. Without it keys come from the environment only.
+	ProviderKeySecret string `json:"providerKeySecret"`
+
+	// FactCheckAPIKey turns on lookups of each extracted fact against the
+	// Google Fact Check Tools claim search at FactCheckURL.
+	FactCheckAPIKey  string   `json:"factCheckApiKey"`
+	FactCheckURL     string   `json:"factCheckUrl"`
+	FactCheckTimeout Duration `json:"factCheckTimeout"`
+
+	// OCRModel reads text out of images uploaded to /api/analyse. It must be
+	// a vision model of the configured provider; empty picks the provider default.
+	OCRModel      string `json:"ocrModel"`
+	MaxImageBytes int    `json:"maxImageBytes"`
+	// UploadDir keeps uploaded images, named by content hash, so analyses
+	// can point back at what was submitted.
+	UploadDir string `json:"uploadDir"`
+
+	// SMTPHost turns on email copies of in-app notifications, sent from
+	// SMTPFrom. SMTPUsername and SMTPPassword are only needed by servers that
+	// require authentication.
+	SMTPHost     string `json:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"smtpPassword"`
+	SMTPFrom     string `json:"smtpFrom"`
+	// DigestHour is the UTC hour daily digests go out; weekly ones go out
+	// at the same hour on Mondays. Digests need SMTPHost too.
+	DigestHour int `json:"digestHour"`
+
+	// RetentionDays is how long a draft may go untouched before the daily
+	// retention run deals with it; 0 keeps drafts forever. RetentionAction
+	// "archive" keeps the analysis but scrubs its source text, images and
+	// snapshots; "delete" removes it.
+	RetentionDays   int    `json:"retentionDays"`
+	RetentionAction string `json:"retentionAction"`
+
+	// SentryDSN sends panics, 5xx responses and failed LLM calls to Sentry
+	// or a service speaking its protocol, tagged with SentryEnvironment.
+	SentryDSN         string `json:"sentryDsn"`
+	SentryEnvironment string `json:"sentryEnvironment"`
+
+	// SlackWebhookURL and TeamsWebhookURL are incoming webhooks that get a
+	// message for each of WebhookEvents. Messages link to AdminURL when set.
+	// WebhookURL gets the same events as JSON for automation tools, signed
+	// with WebhookSecret when it is set.
+	SlackWebhookURL string   `json:"slackWebhookUrl"`
+	TeamsWebhookURL string   `json:"teamsWebhookUrl"`
+	WebhookURL      string   `json:"webhookUrl"`
+	WebhookSecret   string   `json:"webhookSecret"`
+	WebhookEvents   []string `json:"webhookEvents"`
+	WebhookTimeout  Duration `json:"webhookTimeout"`
+	AdminURL        string   `json:"adminUrl"`
+
+	// TelegramBotToken turns on the Telegram bot, which analyses what linked
+	// users send it. Telegram posts updates to /telegram/webhook with
+	// TelegramWebhookSecret, given to it when the webhook is set.
+	TelegramBotToken      string `json:"telegramBotToken"`
+	TelegramWebhookSecret string `json:"telegramWebhookSecret"`
+
+	// WhatsAppVerifyToken and WhatsAppAppSecret turn on intake from a
+	// WhatsApp Business number: Meta checks /whatsapp/webhook with the
+	// verify token and signs each delivery with the app secret. Every
+	// message becomes an analysis in WhatsAppLanguage.
+	WhatsAppVerifyToken string `json:"whatsAppVerifyToken"`
+	WhatsAppAppSecret   string `json:"whatsAppAppSecret"`
+	WhatsAppLanguage    string `json:"whatsAppLanguage"`
+
+	// PublicSiteURL is where the static front-end serves completed analyses,
+	// at PublicSiteURL/<slug>. The public feeds and sitemap link there and
+	// are off without it; FeedTitle names the feeds.
+	PublicSiteURL string `json:"publicSiteUrl"`
+	FeedTitle     string `json:"feedTitle"`
+
+	// PublicAPIURL is where readers reach this server, such as
+	// https://api.example.com. The feeds' self links and review links are
+	// built on it, never on a request's Host header; without it the feeds
+	// leave their own URL out and review links are given as a path.
+	PublicAPIURL string `json:"publicApiUrl"`
+
+	// Every GapRecheckInterval (0 turns it off) the open gaps of analyses
+	// from the last GapRecheckDays days are searched for in GapFeeds, RSS or
+	// Atom feed URLs, and in GapSearchURL, a search returning a feed with
+	// {query} standing for each gap's search terms. Editors are told of new
+	// coverage that looks like an answer.
+	GapRecheckInterval Duration `json:"gapRecheckInterval"`
+	GapRecheckDays     int      `json:"gapRecheckDays"`
+	GapFeeds           []string `json:"gapFeeds"`
+	GapSearchURL       string   `json:"gapSearchUrl"`
+
+	// SearchProvider is the web search behind gap research: "bing", "brave"
+	// or "serpapi", called with SearchAPIKey. SearchURL replaces the
+	// provider's endpoint, for example to go through a proxy. The top
+	// SearchResults results are fetched and summarized.
+	SearchProvider string   `json:"searchProvider"`
+	SearchAPIKey   string   `json:"searchApiKey"`
+	SearchURL      string   `json:"searchUrl"`
+	SearchResults  int      `json:"searchResults"`
+	SearchTimeout  Duration `json:"searchTimeout"`
+
+	// EntityEnrichment looks up the people, places and organisations an
+	// analysis names in Wikidata, at WikidataURL, once the analysis is saved.
+	// Lookups are cached for EntityCacheTTL. It is off by default because
+	// names from unpublished drafts leave the newsroom.
+	EntityEnrichment bool     `json:"entityEnrichment"`
+	WikidataURL      string   `json:"wikidataUrl"`
+	EntityCacheTTL   Duration `json:"entityCacheTtl"`
+
+	Titles TitleRules `json:"titles"`
+
+	// FeatureFlags sets flags, such as enable_timeline, on or off for this
+	// deployment. The admin API can still override them at runtime.
+	FeatureFlags map[string]bool `json:"featureFlags"`
+}
+
+type PublicConfig struct {
+	Port            int             `json:"port"`
+	AllowedOrigins  []string        `json:"allowedOrigins"`
+	ReadTimeout     string          `json:"readTimeout"`
+	WriteTimeout    string          `json:"writeTimeout"`
+	IdleTimeout     string          `json:"idleTimeout"`
+	ShutdownTimeout string          `json:"shutdownTimeout"`
+	LogLevel        string          `json:"logLevel"`
+	WorkerMode      bool            `json:"workerMode"`
+	ProcessJobs     bool            `json:"processJobs"`
+	DBDriver        string          `json:"dbDriver"`
+	AutoMigrate     bool            `json:"autoMigrate"`
+	ReadyProviders  bool            `json:"readyCheckProviders"`
+	DBMaxOpenConns  int             `json:"dbMaxOpenConns"`
+	DBMaxIdleConns  int             `json:"dbMaxIdleConns"`
+	DBConnLifetime  string          `json:"dbConnMaxLifetime"`
+	DBConnIdleTime  string          `json:"dbConnMaxIdleTime"`
+	AuthRequired    bool            `json:"authRequired"`
+	Sessions        bool            `json:"sessions"`
+	SSO             bool            `json:"sso"`
+	RequireApproval bool            `json:"requireApproval"`
+	Sensitivity     bool            `json:"sensitivityCheck"`
+	PIIRedaction    string          `json:"piiRedaction"`
+	MaxBodyBytes    int             `json:"maxBodyBytes"`
+	URLAllowlist    []string        `json:"urlAllowlist"`
+	URLDenylist     []string        `json:"urlDenylist"`
+	FetchBlocked    []string        `json:"fetchBlockedNetworks"`
+	FetchAllowed    []string        `json:"fetchAllowedNetworks"`
+	FetchRedirects  int             `json:"fetchMaxRedirects"`
+	FetchUserAgent  string          `json:"fetchUserAgent"`
+	FetchRobots     bool            `json:"fetchRespectRobots"`
+	FetchInterval   string          `json:"fetchDomainInterval"`
+	FetchMinWords   int             `json:"fetchMinWords"`
+	FetchFallbacks  []string        `json:"fetchFallbacks"`
+	Rendering       bool            `json:"renderEnabled"`
+	RenderMinWords  int             `json:"renderMinWords"`
+	RenderTimeout   string          `json:"renderTimeout"`
+	CategorySuggest string          `json:"categorySuggestions"`
+	LanguageDetect  string          `json:"languageDetection"`
+	Screening       bool            `json:"contentScreening"`
+	TranslationMem  bool            `json:"translationMemory"`
+	LLMCache        string          `json:"llmCacheTtl"`
+	ChunkTokens     int             `json:"llmChunkTokens"`
+	Parallelism     int             `json:"llmParallelism"`
+	MockLLM         bool            `json:"mockLlm"`
+	ProviderBreak   int             `json:"providerFailures"`
+	ProviderRest    string          `json:"providerCooldown"`
+	Failover        string          `json:"providerFailover"`
+	StoredKeys      bool            `json:"storedProviderKeys"`
+	ErrorReporting  bool            `json:"errorReporting"`
+	Redis           bool            `json:"redis"`
+	FactChecks      bool            `json:"factChecks"`
+	OCRModel        string          `json:"ocrModel"`
+	MaxImageBytes   int             `json:"maxImageBytes"`
+	EmailNotify     bool            `json:"emailNotifications"`
+	Digests         bool            `json:"digests"`
+	PublicSite      string          `json:"publicSiteUrl"`
+	DigestHour      int             `json:"digestHour"`
+	RetentionDays   int             `json:"retentionDays"`
+	RetentionAction string          `json:"retentionAction"`
+	Webhooks        []string        `json:"webhooks"`
+	WebhookEvents   []string        `json:"webhookEvents"`
+	Telegram        bool            `json:"telegram"`
+	WhatsApp        bool            `json:"whatsApp"`
+	GapRecheck      string          `json:"gapRecheckInterval"`
+	GapRecheckDays  int             `json:"gapRecheckDays"`
+	GapFeeds        []string        `json:"gapFeeds"`
+	GapSearch       bool            `json:"gapSearch"`
+	Search          string          `json:"searchProvider"`
+	SearchResults   int             `json:"searchResults"`
+	Entities        bool            `json:"entityEnrichment"`
+	Titles          TitleRules      `json:"titles"`
+	FeatureFlags    map[string]bool `json:"featureFlags"`
+}
+
+var (
+	currentMu sync.RWMutex
+	current   = Defaults()
+)
+
+func Defaults() Config {
+	return Config{
+		Port:            8085,
+		AllowedOrigins:  []string{defaultAllowedOrigin},
+		ReadTimeout:     Duration{30 * time.Second},
+		WriteTimeout:    Duration{5 * time.Minute},
+		IdleTimeout:     Duration{120 * time.Second},
+		ShutdownTimeout: Duration{20 * time.Second},
+		LogLevel:        "info",
+		ProcessJobs:     true,
+		AutoMigrate:     true,
+
+		SessionAccessTTL:  Duration{15 * time.Minute},
+		SessionRefreshTTL: Duration{30 * 24 * time.Hour},
+		OIDCIssuer:        "google",
+		OIDCDefaultRole:   "editor",
+		SensitivityCheck:  true,
+		PIIRedaction:      "off",
+
+		DBMaxOpenConns:    25,
+		DBMaxIdleConns:    10,
+		DBConnMaxLifetime: Duration{30 * time.Minute},
+		DBConnMaxIdleTime: Duration{5 * time.Minute},
+		DBPingTimeout:     Duration{2 * time.Second},
+
+		MaxBodyBytes:      2 << 20,
+		FetchMaxRedirects: 5,
+
+		FetchUserAgent:      "NanoheadsBot/1.0",
+		FetchRespectRobots:  true,
+		FetchDomainInterval: Duration{2 * time.Second},
+		FetchMinWords:       150,
+		FetchFallbacks:      []string{"amp", "archive"},
+
+		RenderMinWords: 50,
+		RenderTimeout:  Duration{30 * time.Second},
+
+		CategorySuggestions: "llm",
+		LanguageDetection:   "llm",
+		ContentScreening:    true,
+		TranslationMemory:   true,
+		LLMCacheTTL:         Duration{24 * time.Hour},
+		LLMChunkTokens:      3000,
+		LLMParallelism:      4,
+
+		ProviderFailures: 5,
+		ProviderCooldown: Duration{time.Minute},
+
+		FactCheckURL:     "https://factchecktools.googleapis.com/v1alpha1/claims:search",
+		FactCheckTimeout: Duration{8 * time.Second},
+
+		MaxImageBytes: 8 << 20,
+		UploadDir:     "uploads",
+
+		SMTPPort:   587,
+		DigestHour: 7,
+
+		RetentionAction: "archive",
+
+		WebhookEvents:  append([]string(nil), PipelineEvents...),
+		WebhookTimeout: Duration{5 * time.Second},
+
+		WhatsAppLanguage: "Telugu",
+
+		FeedTitle: "NanoHeads",
+
+		GapRecheckInterval: Duration{6 * time.Hour},
+		GapRecheckDays:     7,
+
+		SearchResults: 5,
+		SearchTimeout: Duration{10 * time.Second},
+
+		WikidataURL:    "https://www.wikidata.org/w/api.php",
+		EntityCacheTTL: Duration{30 * 24 * time.Hour},
+
+		Titles: TitleRules{
+			HeadlineMaxWords:  12,
+			HeadlineMaxChars:  90,
+			StraplineMaxWords: 14,
+			StraplineMaxChars: 140,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, then the optional JSON file
+// named by CONFIG_FILE, then environment variables, in that order of precedence.
+func Load() (Config, error) {
+	cfg := Defaults()
+
+	if path := strings.TrimSpace(os.Getenv("CONFIG_FILE")); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	Set(cfg)
+	return cfg, nil
+}
+
+// Set replaces the process-wide configuration, for commands that adjust it
+// after loading.
+func Set(cfg Config) {
+	currentMu.Lock()
+	current = cfg
+	currentMu.Unlock()
+}
+
+func Current() Config {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+func (c Config) Validate() error {
+	problems := make([]string, 0)
+
+	if c.Port <= 0 || c.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("PORT must be between 1 and 65535 (got %d)", c.Port))
+	}
+	if len(c.AllowedOrigins) == 0 {
+		problems = append(problems, "CORS_ALLOWED_ORIGINS must list at least one origin")
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin != "*" && !hasOriginScheme(origin) {
+			problems = append(problems, fmt.Sprintf("invalid CORS origin %q (must be * or start with http://, https://, chrome-extension:// or moz-extension://)", origin))
+		}
+	}
+	if c.RequireApproval && !c.AuthRequired && c.AdminAPIKey == "" {
+		problems = append(problems, "REQUIRE_APPROVAL needs AUTH_REQUIRED=true or AUTH_ADMIN_API_KEY, or nobody could approve and no analysis could be completed")
+	}
+	if c.WorkerMode && !c.ProcessJobs {
+		problems = append(problems, "PROCESS_JOBS must be true when WORKER_MODE is on")
+	}
+	if c.ReadTimeout.Duration <= 0 || c.WriteTimeout.Duration <= 0 || c.IdleTimeout.Duration <= 0 || c.ShutdownTimeout.Duration <= 0 {
+		problems = append(problems, "timeouts must be positive durations")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be debug, info, warn, or error (got %q)", c.LogLevel))
+	}
+	if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		problems = append(problems, fmt.Sprintf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.DBMaxIdleConns, c.DBMaxOpenConns))
+	}
+	if c.DBConnMaxLifetime.Duration < 0 || c.DBConnMaxIdleTime.Duration < 0 {
+		problems = append(problems, "DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME must not be negative")
+	}
+	if c.DBPingTimeout.Duration <= 0 {
+		problems = append(problems, "DB_PING_TIMEOUT must be a positive duration")
+	}
+	if c.MaxBodyBytes <= 0 {
+		problems = append(problems, "MAX_BODY_BYTES must be positive")
+	}
+	for key, hosts := range map[string][]string{"URL_ALLOWLIST": c.URLAllowlist, "URL_DENYLIST": c.URLDenylist} {
+		for _, host := range hosts {
+			if strings.ContainsAny(host, "/:?#@ ") {
+				problems = append(problems, fmt.Sprintf("%s entries must be host names like example.com (got %q)", key, host))
+			}
+		}
+	}
+	for key, networks := range map[string][]string{"FETCH_BLOCKED_NETWORKS": c.FetchBlockedNetworks, "FETCH_ALLOWED_NETWORKS": c.FetchAllowedNetworks} {
+		for _, network := range networks {
+			if _, err := parseNetwork(network); err != nil {
+				problems = append(problems, fmt.Sprintf("%s entries must be CIDRs like 10.0.0.0/8 or IP addresses (got %q)", key, network))
+			}
+		}
+	}
+	if c.FetchMaxRedirects < 0 {
+		problems = append(problems, "FETCH_MAX_REDIRECTS must not be negative")
+	}
+	if c.FetchUserAgent == "" {
+		problems = append(problems, "FETCH_USER_AGENT is required")
+	}
+	if c.FetchDomainInterval.Duration < 0 {
+		problems = append(problems, "FETCH_DOMAIN_INTERVAL must not be negative")
+	}
+	if c.FetchMinWords < 0 {
+		problems = append(problems, "FETCH_MIN_WORDS must not be negative")
+	}
+	if c.RenderMinWords < 0 {
+		problems = append(problems, "RENDER_MIN_WORDS must not be negative")
+	}
+	if c.RenderEnabled && c.RenderTimeout.Duration <= 0 {
+		problems = append(problems, "RENDER_TIMEOUT must be a positive duration")
+	}
+	if c.RenderBrowserURL != "" && !strings.HasPrefix(c.RenderBrowserURL, "ws://") && !strings.HasPrefix(c.RenderBrowserURL, "wss://") {
+		problems = append(problems, fmt.Sprintf("RENDER_BROWSER_URL must be a ws:// or wss:// DevTools URL (got %q)", c.RenderBrowserURL))
+	}
+	for _, strategy := range c.FetchFallbacks {
+		switch strategy {
+		case "amp", "google_cache", "archive":
+		default:
+			problems = append(problems, fmt.Sprintf("FETCH_FALLBACKS entries must be amp, google_cache, or archive (got %q)", strategy))
+		}
+	}
+	switch c.CategorySuggestions {
+	case "llm", "keywords", "off":
+	default:
+		problems = append(problems, fmt.Sprintf("CATEGORY_SUGGESTIONS must be llm, keywords, or off (got %q)", c.CategorySuggestions))
+	}
+	switch c.PIIRedaction {
+	case "off", "mask", "flag":
+	default:
+		problems = append(problems, fmt.Sprintf("PII_REDACTION must be off, mask, or flag (got %q)", c.PIIRedaction))
+	}
+	switch c.RetentionAction {
+	case "archive", "delete":
+	default:
+		problems = append(problems, fmt.Sprintf("RETENTION_ACTION must be archive or delete (got %q)", c.RetentionAction))
+	}
+	if c.RetentionDays < 0 {
+		problems = append(problems, "RETENTION_DAYS must not be negative")
+	}
+	switch c.LanguageDetection {
+	case "llm", "heuristic":
+	default:
+		problems = append(problems, fmt.Sprintf("LANGUAGE_DETECTION must be llm or heuristic (got %q)", c.LanguageDetection))
+	}
+	if c.MockLLMLatency.Duration < 0 {
+		problems = append(problems, "LLM_MOCK_LATENCY must not be negative")
+	}
+	if c.LLMCacheTTL.Duration < 0 {
+		problems = append(problems, "LLM_CACHE_TTL must not be negative")
+	}
+	if c.RedisURL != "" {
+		if parsed, err := url.Parse(c.RedisURL); err != nil || (parsed.Scheme != "redis" && parsed.Scheme != "rediss") || parsed.Host == "" {
+			problems = append(problems, "REDIS_URL must be a redis:// or rediss:// URL with a host")
+		}
+	}
+	if c.LLMChunkTokens < 200 {
+		problems = append(problems, "LLM_CHUNK_TOKENS must be at least 200")
+	}
+	if c.LLMParallelism < 1 {
+		problems = append(problems, "LLM_PARALLELISM must be at least 1")
+	}
+	if c.ProviderFailures < 0 {
+		problems = append(problems, "PROVIDER_FAILURES must not be negative")
+	}
+	if c.ProviderFailures > 0 && c.ProviderCooldown.Duration <= 0 {
+		problems = append(problems, "PROVIDER_COOLDOWN must be a positive duration")
+	}
+	switch c.ProviderFailover {
+	case "", "openai", "groq":
+	default:
+		problems = append(problems, fmt.Sprintf("PROVIDER_FAILOVER must be openai or groq (got %q)", c.ProviderFailover))
+	}
+	if c.SessionSecret != "" && len(c.SessionSecret) < 32 {
+		problems = append(problems, "SESSION_SECRET must be at least 32 characters")
+	}
+	if c.SessionAccessTTL.Duration <= 0 || c.SessionRefreshTTL.Duration <= c.SessionAccessTTL.Duration {
+		problems = append(problems, "SESSION_ACCESS_TTL must be positive and shorter than SESSION_REFRESH_TTL")
+	}
+	if c.SSOEnabled() {
+		if !strings.HasPrefix(c.OIDCIssuer, "https://") && !strings.HasPrefix(c.OIDCIssuer, "http://") {
+			problems = append(problems, "OIDC_ISSUER must be google or an http or https URL")
+		}
+		if c.OIDCClientSecret == "" {
+			problems = append(problems, "OIDC_CLIENT_SECRET is required with OIDC_CLIENT_ID")
+		}
+		if !strings.HasPrefix(c.OIDCRedirectURL, "https://") && !strings.HasPrefix(c.OIDCRedirectURL, "http://") {
+			problems = append(problems, "OIDC_REDIRECT_URL must be an http or https URL")
+		}
+		if c.SessionSecret == "" {
+			problems = append(problems, "OIDC_CLIENT_ID needs SESSION_SECRET to start sessions")
+		}
+		if c.AdminURL == "" {
+			problems = append(problems, "OIDC_CLIENT_ID needs ADMIN_URL to send people back to")
+		}
+		if c.OIDCDefaultRole == "" {
+			problems = append(problems, "OIDC_DEFAULT_ROLE must not be empty")
+		}
+	}
+	if c.ProviderKeySecret != "" {
+		if _, err := c.ProviderKeyCipherKey(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.FactCheckAPIKey != "" && !strings.HasPrefix(c.FactCheckURL, "http://") && !strings.HasPrefix(c.FactCheckURL, "https://") {
+		problems = append(problems, fmt.Sprintf("FACT_CHECK_URL must be an http or https URL (got %q)", c.FactCheckURL))
+	}
+	if c.FactCheckTimeout.Duration <= 0 {
+		problems = append(problems, "FACT_CHECK_TIMEOUT must be a positive duration")
+	}
+	if c.MaxImageBytes <= 0 {
+		problems = append(problems, "MAX_IMAGE_BYTES must be positive")
+	}
+	if c.FeedTitle == "" {
+		problems = append(problems, "FEED_TITLE must not be empty")
+	}
+	if c.UploadDir == "" {
+		problems = append(problems, "UPLOAD_DIR is required")
+	}
+	if c.SMTPHost != "" && c.SMTPFrom == "" {
+		problems = append(problems, "SMTP_FROM is required when SMTP_HOST is set")
+	}
+	if c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+		problems = append(problems, fmt.Sprintf("SMTP_PORT must be between 1 and 65535 (got %d)", c.SMTPPort))
+	}
+	if c.DigestHour < 0 || c.DigestHour > 23 {
+		problems = append(problems, fmt.Sprintf("DIGEST_HOUR must be between 0 and 23 (got %d)", c.DigestHour))
+	}
+	if c.SentryDSN != "" {
+		if parsed, err := url.Parse(c.SentryDSN); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.User == nil || strings.Trim(parsed.Path, "/") == "" {
+			problems = append(problems, "SENTRY_DSN must look like https://<key>@<host>/<project>")
+		}
+	}
+	for key, value := range map[string]string{"SLACK_WEBHOOK_URL": c.SlackWebhookURL, "TEAMS_WEBHOOK_URL": c.TeamsWebhookURL, "WEBHOOK_URL": c.WebhookURL, "ADMIN_URL": c.AdminURL, "PUBLIC_SITE_URL": c.PublicSiteURL, "PUBLIC_API_URL": c.PublicAPIURL} {
+		if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			problems = append(problems, fmt.Sprintf("%s must be an http or https URL", key))
+		}
+	}
+	for _, event := range c.WebhookEvents {
+		if !slices.Contains(PipelineEvents, event) {
+			problems = append(problems, fmt.Sprintf("WEBHOOK_EVENTS has unknown event %q (use %s)", event, strings.Join(PipelineEvents, ", ")))
+		}
+	}
+	if c.WebhookTimeout.Duration <= 0 {
+		problems = append(problems, "WEBHOOK_TIMEOUT must be a positive duration")
+	}
+	if c.TelegramBotToken != "" {
+		switch {
+		case c.TelegramWebhookSecret == "":
+			problems = append(problems, "TELEGRAM_WEBHOOK_SECRET is required with TELEGRAM_BOT_TOKEN")
+		case !validTelegramSecret(c.TelegramWebhookSecret):
+			problems = append(problems, "TELEGRAM_WEBHOOK_SECRET must be 16 to 256 letters, digits, _ or -")
+		}
+	}
+	if (c.WhatsAppVerifyToken == "") != (c.WhatsAppAppSecret == "") {
+		problems = append(problems, "WHATSAPP_VERIFY_TOKEN and WHATSAPP_APP_SECRET must be set together")
+	}
+	if c.GapRecheckInterval.Duration < 0 {
+		problems = append(problems, "GAP_RECHECK_INTERVAL must not be negative")
+	}
+	if c.GapRecheckDays <= 0 {
+		problems = append(problems, "GAP_RECHECK_DAYS must be positive")
+	}
+	for _, feed := range c.GapFeeds {
+		if !strings.HasPrefix(feed, "http://") && !strings.HasPrefix(feed, "https://") {
+			problems = append(problems, fmt.Sprintf("GAP_FEEDS entries must be http or https URLs (got %q)", feed))
+		}
+	}
+	if c.GapSearchURL != "" {
+		if !strings.HasPrefix(c.GapSearchURL, "http://") && !strings.HasPrefix(c.GapSearchURL, "https://") {
+			problems = append(problems, "GAP_SEARCH_URL must be an http or https URL")
+		} else if !strings.Contains(c.GapSearchURL, "{query}") {
+			problems = append(problems, "GAP_SEARCH_URL must contain {query}")
+		}
+	}
+	switch c.SearchProvider {
+	case "":
+	case "bing", "brave", "serpapi":
+		if c.SearchAPIKey == "" {
+			problems = append(problems, "SEARCH_API_KEY is required when SEARCH_PROVIDER is set")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("SEARCH_PROVIDER must be bing, brave, or serpapi (got %q)", c.SearchProvider))
+	}
+	if c.SearchURL != "" && !strings.HasPrefix(c.SearchURL, "http://") && !strings.HasPrefix(c.SearchURL, "https://") {
+		problems = append(problems, fmt.Sprintf("SEARCH_URL must be an http or https URL (got %q)", c.SearchURL))
+	}
+	if c.SearchResults < 1 || c.SearchResults > 20 {
+		problems = append(problems, fmt.Sprintf("SEARCH_RESULTS must be between 1 and 20 (got %d)", c.SearchResults))
+	}
+	if c.SearchTimeout.Duration <= 0 {
+		problems = append(problems, "SEARCH_TIMEOUT must be a positive duration")
+	}
+	if c.EntityEnrichment && !strings.HasPrefix(c.WikidataURL, "http://") && !strings.HasPrefix(c.WikidataURL, "https://") {
+		problems = append(problems, fmt.Sprintf("WIKIDATA_URL must be an http or https URL (got %q)", c.WikidataURL))
+	}
+	if c.EntityCacheTTL.Duration < 0 {
+		problems = append(problems, "ENTITY_CACHE_TTL must not be negative")
+	}
+	if c.Titles.HeadlineMaxWords <= 0 || c.Titles.HeadlineMaxChars <= 0 ||
+		c.Titles.StraplineMaxWords <= 0 || c.Titles.StraplineMaxChars <= 0 {
+		problems = append(problems, "headline and strapline limits must be positive")
+	}
+	if strings.TrimSpace(c.DatabaseURL) == "" {
+		problems = append(problems, "DATABASE_URL is required")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c Config) Public() PublicConfig {
+	return PublicConfig{
+		Port:            c.Port,
+		AllowedOrigins:  append([]string(nil), c.AllowedOrigins...),
+		ReadTimeout:     c.ReadTimeout.String(),
+		WriteTimeout:    c.WriteTimeout.String(),
+		IdleTimeout:     c.IdleTimeout.String(),
+		ShutdownTimeout: c.ShutdownTimeout.String(),
+		LogLevel:        c.LogLevel,
+		WorkerMode:      c.WorkerMode,
+		ProcessJobs:     c.ProcessJobs,
+		DBDriver:        c.DBDriver,
+		AutoMigrate:     c.AutoMigrate,
+		ReadyProviders:  c.ReadyCheckProviders,
+		DBMaxOpenConns:  c.DBMaxOpenConns,
+		DBMaxIdleConns:  c.DBMaxIdleConns,
+		DBConnLifetime:  c.DBConnMaxLifetime.String(),
+		DBConnIdleTime:  c.DBConnMaxIdleTime.String(),
+		AuthRequired:    c.AuthRequired,
+		Sessions:        c.SessionSecret != "",
+		SSO:             c.SSOEnabled(),
+		RequireApproval: c.RequireApproval,
+		Sensitivity:     c.SensitivityCheck,
+		PIIRedaction:    c.PIIRedaction,
+		MaxBodyBytes:    c.MaxBodyBytes,
+		URLAllowlist:    append([]string(nil), c.URLAllowlist...),
+		URLDenylist:     append([]string(nil), c.URLDenylist...),
+		FetchBlocked:    append([]string(nil), c.FetchBlockedNetworks...),
+		FetchAllowed:    append([]string(nil), c.FetchAllowedNetworks...),
+		FetchRedirects:  c.FetchMaxRedirects,
+		FetchUserAgent:  c.FetchUserAgent,
+		FetchRobots:     c.FetchRespectRobots,
+		FetchInterval:   c.FetchDomainInterval.String(),
+		FetchMinWords:   c.FetchMinWords,
+		FetchFallbacks:  append([]string(nil), c.FetchFallbacks...),
+		Rendering:       c.RenderEnabled,
+		RenderMinWords:  c.RenderMinWords,
+		RenderTimeout:   c.RenderTimeout.String(),
+		CategorySuggest: c.CategorySuggestions,
+		LanguageDetect:  c.LanguageDetection,
+		Screening:       c.ContentScreening,
+		TranslationMem:  c.TranslationMemory,
+		LLMCache:        c.LLMCacheTTL.String(),
+		ChunkTokens:     c.LLMChunkTokens,
+		Parallelism:     c.LLMParallelism,
+		MockLLM:         c.MockLLM,
+		ProviderBreak:   c.ProviderFailures,
+		ProviderRest:    c.ProviderCooldown.String(),
+		Failover:        c.ProviderFailover,
+		StoredKeys:      c.ProviderKeySecret != "",
+		Redis:           c.RedisURL != "",
+		FactChecks:      c.FactCheckAPIKey != "",
+		OCRModel:        c.OCRModel,
+		MaxImageBytes:   c.MaxImageBytes,
+		EmailNotify:     c.SMTPHost != "",
+		Digests:         c.DigestsEnabled(),
+		PublicSite:      c.PublicSiteURL,
+		DigestHour:      c.DigestHour,
+		RetentionDays:   c.RetentionDays,
+		RetentionAction: c.RetentionAction,
+		ErrorReporting:  c.SentryDSN != "",
+		Webhooks:        c.webhookTargets(),
+		WebhookEvents:   append([]string(nil), c.WebhookEvents...),
+		Telegram:        c.TelegramBotToken != "",
+		WhatsApp:        c.WhatsAppAppSecret != "",
+		GapRecheck:      c.GapRecheckInterval.String(),
+		GapRecheckDays:  c.GapRecheckDays,
+		GapFeeds:        append([]string(nil), c.GapFeeds...),
+		GapSearch:       c.GapSearchURL != "",
+		Search:          c.SearchProvider,
+		SearchResults:   c.SearchResults,
+		Entities:        c.EntityEnrichment,
+		Titles:          c.Titles,
+		FeatureFlags:    maps.Clone(c.FeatureFlags),
+	}
+}
+
+// ProviderKeyCipherKey decodes ProviderKeySecret into an AES-256 key.
+func (c Config) ProviderKeyCipherKey() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(c.ProviderKeySecret)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("PROVIDER_KEY_SECRET must be 32 bytes encoded as base64")
+	}
+	return key, nil
+}
+
+func (c Config) DebugEnabled() bool {
+	return c.LogLevel == "debug"
+}
+
+func (c Config) Addr() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+// WebhookEnabled reports whether event is sent to any webhook.
+func (c Config) WebhookEnabled(event string) bool {
+	return len(c.webhookTargets()) > 0 && slices.Contains(c.WebhookEvents, event)
+}
+
+// HostAllowed applies the URL allow and deny lists to host. A list entry
+// matches the host itself and its subdomains.
+func (c Config) HostAllowed(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if matchesHost(c.URLDenylist, host) {
+		return false
+	}
+	return len(c.URLAllowlist) == 0 || matchesHost(c.URLAllowlist, host)
+}
+
+func matchesHost(hosts []string, host string) bool {
+	for _, entry := range hosts {
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// internalNetworks are refused to URL fetches unless FetchAllowedNetworks
+// lists them: this-host, private, CGNAT, loopback, link-local (which holds
+// cloud metadata endpoints), benchmarking, multicast and reserved ranges.
+var internalNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// RobotsAgent is the User-agent name matched against robots.txt groups: the
+// product token of FetchUserAgent, lowercased.
+func (c Config) RobotsAgent() string {
+	agent := strings.Fields(c.FetchUserAgent)
+	if len(agent) == 0 {
+		return ""
+	}
+	name, _, _ := strings.Cut(agent[0], "/")
+	return strings.ToLower(name)
+}
+
+// DigestsEnabled reports whether digest emails can be sent.
+func (c Config) DigestsEnabled() bool {
+	return c.SMTPHost != ""
+}
+
+// SSOEnabled reports whether people can sign in through OIDCIssuer.
+func (c Config) SSOEnabled() bool {
+	return c.OIDCClientID != ""
+}
+
+// RetentionEnabled reports whether old drafts are archived or deleted.
+func (c Config) RetentionEnabled() bool {
+	return c.RetentionDays > 0
+}
+
+// GapRecheckEnabled reports whether open gaps are searched for periodically:
+// an interval is set and there is somewhere to search.
+func (c Config) GapRecheckEnabled() bool {
+	return c.GapRecheckInterval.Duration > 0 && (len(c.GapFeeds) > 0 || c.GapSearchURL != "")
+}
+
+// FetchAddressAllowed reports whether fetching a submitted URL may connect
+// to addr. FetchBlockedNetworks wins over FetchAllowedNetworks.
+func (c Config) FetchAddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if containsAddr(c.FetchBlockedNetworks, addr) {
+		return false
+	}
+	if containsAddr(c.FetchAllowedNetworks, addr) {
+		return true
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAddr(networks []string, addr netip.Addr) bool {
+	for _, entry := range networks {
+		if network, err := parseNetwork(entry); err == nil && network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetwork reads a CIDR, or a single address as a one-address range.
+func parseNetwork(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validTelegramSecret holds a secret to what Telegram accepts as one, and to
+// a length that is hard to guess.
+func validTelegramSecret(secret string) bool {
+	if len(secret) < 16 || len(secret) > 256 {
+		return false
+	}
+	for _, r := range secret {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Config) webhookTargets() []string {
+	targets := make([]string, 0, 3)
+	if c.SlackWebhookURL != "" {
+		targets = append(targets, "slack")
+	}
+	if c.TeamsWebhookURL != "" {
+		targets = append(targets, "teams")
+	}
+	if c.WebhookURL != "" {
+		targets = append(targets, "json")
+	}
+	return targets
+}
+
+func (c *Config) normalize() {
+	c.LogLevel = strings.ToLower(strings.TrimSpace(c.LogLevel))
+	if c.LogLevel == "warning" {
+		c.LogLevel = "warn"
+	}
+	c.DatabaseURL = strings.TrimSpace(c.DatabaseURL)
+	c.DBDriver = strings.TrimSpace(c.DBDriver)
+	c.AdminAPIKey = strings.TrimSpace(c.AdminAPIKey)
+	c.SessionSecret = strings.TrimSpace(c.SessionSecret)
+	c.OIDCIssuer = strings.TrimRight(strings.TrimSpace(c.OIDCIssuer), "/")
+	if strings.EqualFold(c.OIDCIssuer, "google") {
+		c.OIDCIssuer = googleIssuer
+	}
+	c.OIDCClientID = strings.TrimSpace(c.OIDCClientID)
+	c.OIDCClientSecret = strings.TrimSpace(c.OIDCClientSecret)
+	c.OIDCRedirectURL = strings.TrimSpace(c.OIDCRedirectURL)
+	c.OIDCAllowedDomains = normalizeHosts(c.OIDCAllowedDomains)
+	c.OIDCDefaultRole = strings.ToLower(strings.TrimSpace(c.OIDCDefaultRole))
+	c.CategorySuggestions = strings.ToLower(strings.TrimSpace(c.CategorySuggestions))
+	c.PIIRedaction = strings.ToLower(strings.TrimSpace(c.PIIRedaction))
+	c.RetentionAction = strings.ToLower(strings.TrimSpace(c.RetentionAction))
+	c.LanguageDetection = strings.ToLower(strings.TrimSpace(c.LanguageDetection))
+	c.ProviderKeySecret = strings.TrimSpace(c.ProviderKeySecret)
+	c.FactCheckAPIKey = strings.TrimSpace(c.FactCheckAPIKey)
+	c.FactCheckURL = strings.TrimSpace(c.FactCheckURL)
+	c.OCRModel = strings.TrimSpace(c.OCRModel)
+	c.UploadDir = strings.TrimSpace(c.UploadDir)
+	c.SMTPHost = strings.TrimSpace(c.SMTPHost)
+	c.SMTPFrom = strings.TrimSpace(c.SMTPFrom)
+	c.SlackWebhookURL = strings.TrimSpace(c.SlackWebhookURL)
+	c.SentryDSN = strings.TrimSpace(c.SentryDSN)
+	c.SentryEnvironment = strings.TrimSpace(c.SentryEnvironment)
+	c.TeamsWebhookURL = strings.TrimSpace(c.TeamsWebhookURL)
+	c.WebhookURL = strings.TrimSpace(c.WebhookURL)
+	c.WebhookSecret = strings.TrimSpace(c.WebhookSecret)
+	c.TelegramBotToken = strings.TrimSpace(c.TelegramBotToken)
+	c.TelegramWebhookSecret = strings.TrimSpace(c.TelegramWebhookSecret)
+	c.WhatsAppVerifyToken = strings.TrimSpace(c.WhatsAppVerifyToken)
+	c.WhatsAppAppSecret = strings.TrimSpace(c.WhatsAppAppSecret)
+	c.WhatsAppLanguage = strings.TrimSpace(c.WhatsAppLanguage)
+	c.AdminURL = strings.TrimRight(strings.TrimSpace(c.AdminURL), "/")
+	c.PublicSiteURL = strings.TrimRight(strings.TrimSpace(c.PublicSiteURL), "/")
+	c.PublicAPIURL = strings.TrimRight(strings.TrimSpace(c.PublicAPIURL), "/")
+	c.FeedTitle = strings.TrimSpace(c.FeedTitle)
+
+	events := make([]string, 0, len(c.WebhookEvents))
+	for _, event := range c.WebhookEvents {
+		if clean := strings.ToLower(strings.TrimSpace(event)); clean != "" {
+			events = append(events, clean)
+		}
+	}
+	c.WebhookEvents = events
+
+	flags := make(map[string]bool, len(c.FeatureFlags))
+	for key, enabled := range c.FeatureFlags {
+		if clean := strings.ToLower(strings.TrimSpace(key)); clean != "" {
+			flags[clean] = enabled
+		}
+	}
+	c.FeatureFlags = flags
+
+	c.URLAllowlist = normalizeHosts(c.URLAllowlist)
+	c.URLDenylist = normalizeHosts(c.URLDenylist)
+	c.FetchBlockedNetworks = trimEntries(c.FetchBlockedNetworks)
+	c.FetchAllowedNetworks = trimEntries(c.FetchAllowedNetworks)
+	c.FetchUserAgent = strings.TrimSpace(c.FetchUserAgent)
+	c.FetchFallbacks = normalizeFallbacks(c.FetchFallbacks)
+	c.ChromePath = strings.TrimSpace(c.ChromePath)
+	c.RenderBrowserURL = strings.TrimSpace(c.RenderBrowserURL)
+	c.GapFeeds = trimEntries(c.GapFeeds)
+	c.GapSearchURL = strings.TrimSpace(c.GapSearchURL)
+	c.SearchProvider = strings.ToLower(strings.TrimSpace(c.SearchProvider))
+	c.SearchURL = strings.TrimSpace(c.SearchURL)
+	c.WikidataURL = strings.TrimSpace(c.WikidataURL)
+	c.ProviderFailover = strings.ToLower(strings.TrimSpace(c.ProviderFailover))
+
+	origins := make([]string, 0, len(c.AllowedOrigins))
+	for _, origin := range c.AllowedOrigins {
+		clean := strings.TrimRight(strings.TrimSpace(origin), "/")
+		if clean != "" {
+			origins = append(origins, clean)
+		}
+	}
+	c.AllowedOrigins = origins
+}
+
+// normalizeHosts lowercases list entries and drops a leading "*." or ".",
+// which the subdomain matching makes redundant.
+func normalizeHosts(values []string) []string {
+	hosts := make([]string, 0, len(values))
+	for _, value := range values {
+		clean := strings.ToLower(strings.TrimSpace(value))
+		clean = strings.TrimPrefix(strings.TrimPrefix(clean, "*"), ".")
+		if clean != "" {
+			hosts = append(hosts, clean)
+		}
+	}
+	return hosts
+}
+
+// normalizeFallbacks lowercases and dedupes fetch fallbacks; "none" alone
+// turns them off.
+func normalizeFallbacks(values []string) []string {
+	strategies := make([]string, 0, len(values))
+	for _, value := range values {
+		clean := strings.ToLower(strings.TrimSpace(value))
+		if clean == "" || clean == "none" || slices.Contains(strategies, clean) {
+			continue
+		}
+		strategies = append(strategies, clean)
+	}
+	return strategies
+}
+
+func trimEntries(values []string) []string {
+	entries := make([]string, 0, len(values))
+	for _, value := range values {
+		if clean := strings.TrimSpace(value); clean != "" {
+			entries = append(entries, clean)
+		}
+	}
+	return entries
+}
+
+// hasOriginScheme accepts web origins and browser-extension origins, so the
+// clipping extension can call the API directly.
+func hasOriginScheme(origin string) bool {
+	for _, scheme := range []string{"http://", "https://", "chrome-extension://", "moz-extension://"} {
+		if strings.HasPrefix(origin, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	if value := envValue("PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("PORT must be a number: %w", err)
+		}
+		cfg.Port = port
+	}
+
+	if value := envValue("CORS_ALLOWED_ORIGINS"); value != "" {
+		cfg.AllowedOrigins = strings.Split(value, ",")
+	}
+
+	durations := map[string]*Duration{
+		"HTTP_READ_TIMEOUT":     &cfg.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":    &cfg.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":     &cfg.IdleTimeout,
+		"HTTP_SHUTDOWN_TIMEOUT": &cfg.ShutdownTimeout,
+		"DB_CONN_MAX_LIFETIME":  &cfg.DBConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME": &cfg.DBConnMaxIdleTime,
+		"DB_PING_TIMEOUT":       &cfg.DBPingTimeout,
+		"LLM_MOCK_LATENCY":      &cfg.MockLLMLatency,
+		"LLM_CACHE_TTL":         &cfg.LLMCacheTTL,
+		"PROVIDER_COOLDOWN":     &cfg.ProviderCooldown,
+		"FACT_CHECK_TIMEOUT":    &cfg.FactCheckTimeout,
+		"WEBHOOK_TIMEOUT":       &cfg.WebhookTimeout,
+		"FETCH_DOMAIN_INTERVAL": &cfg.FetchDomainInterval,
+		"RENDER_TIMEOUT":        &cfg.RenderTimeout,
+		"GAP_RECHECK_INTERVAL":  &cfg.GapRecheckInterval,
+		"SEARCH_TIMEOUT":        &cfg.SearchTimeout,
+		"ENTITY_CACHE_TTL":      &cfg.EntityCacheTTL,
+		"SESSION_ACCESS_TTL":    &cfg.SessionAccessTTL,
+		"SESSION_REFRESH_TTL":   &cfg.SessionRefreshTTL,
+	}
+	for key, target := range durations {
+		value := envValue(key)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a duration like 30s: %w", key, err)
+		}
+		target.Duration = parsed
+	}
+
+	if value := envValue("LOG_LEVEL"); value != "" {
+		cfg.LogLevel = value
+	}
+	if value := envValue("WORKER_MODE"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("WORKER_MODE must be true or false: %w", err)
+		}
+		cfg.WorkerMode = enabled
+	}
+	if value := envValue("PROCESS_JOBS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("PROCESS_JOBS must be true or false: %w", err)
+		}
+		cfg.ProcessJobs = enabled
+	}
+	if value := envValue("DATABASE_URL"); value != "" {
+		cfg.DatabaseURL = value
+	}
+	if value := envValue("DB_DRIVER"); value != "" {
+		cfg.DBDriver = value
+	}
+	if value := envValue("AUTO_MIGRATE"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("AUTO_MIGRATE must be true or false: %w", err)
+		}
+		cfg.AutoMigrate = enabled
+	}
+	if value := envValue("READY_CHECK_PROVIDERS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("READY_CHECK_PROVIDERS must be true or false: %w", err)
+		}
+		cfg.ReadyCheckProviders = enabled
+	}
+	if value := envValue("AUTH_REQUIRED"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("AUTH_REQUIRED must be true or false: %w", err)
+		}
+		cfg.AuthRequired = required
+	}
+	if value := envValue("REQUIRE_APPROVAL"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("REQUIRE_APPROVAL must be true or false: %w", err)
+		}
+		cfg.RequireApproval = required
+	}
+	if value := envValue("SENSITIVITY_CHECK"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("SENSITIVITY_CHECK must be true or false: %w", err)
+		}
+		cfg.SensitivityCheck = enabled
+	}
+	if value := envValue("AUTH_ADMIN_API_KEY"); value != "" {
+		cfg.AdminAPIKey = value
+	}
+	if value := envValue("SESSION_SECRET"); value != "" {
+		cfg.SessionSecret = value
+	}
+	if value := envValue("OIDC_ISSUER"); value != "" {
+		cfg.OIDCIssuer = value
+	}
+	if value := envValue("OIDC_CLIENT_ID"); value != "" {
+		cfg.OIDCClientID = value
+	}
+	if value := envValue("OIDC_CLIENT_SECRET"); value != "" {
+		cfg.OIDCClientSecret = value
+	}
+	if value := envValue("OIDC_REDIRECT_URL"); value != "" {
+		cfg.OIDCRedirectURL = value
+	}
+	if value := envValue("OIDC_ALLOWED_DOMAINS"); value != "" {
+		cfg.OIDCAllowedDomains = strings.Split(value, ",")
+	}
+	if value := envValue("OIDC_DEFAULT_ROLE"); value != "" {
+		cfg.OIDCDefaultRole = value
+	}
+	if value := envValue("CATEGORY_SUGGESTIONS"); value != "" {
+		cfg.CategorySuggestions = value
+	}
+	if value := envValue("PII_REDACTION"); value != "" {
+		cfg.PIIRedaction = value
+	}
+	if value := envValue("RETENTION_ACTION"); value != "" {
+		cfg.RetentionAction = value
+	}
+	if value := envValue("LANGUAGE_DETECTION"); value != "" {
+		cfg.LanguageDetection = value
+	}
+	if value := envValue("CONTENT_SCREENING"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("CONTENT_SCREENING must be true or false: %w", err)
+		}
+		cfg.ContentScreening = enabled
+	}
+	if value := envValue("TRANSLATION_MEMORY"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("TRANSLATION_MEMORY must be true or false: %w", err)
+		}
+		cfg.TranslationMemory = enabled
+	}
+	if value := envValue("LLM_MOCK"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("LLM_MOCK must be true or false: %w", err)
+		}
+		cfg.MockLLM = enabled
+	}
+	if value := envValue("PROVIDER_FAILOVER"); value != "" {
+		cfg.ProviderFailover = value
+	}
+
+	if value := envValue("PROVIDER_KEY_SECRET"); value != "" {
+		cfg.ProviderKeySecret = value
+	}
+	if value := envValue("REDIS_URL"); value != "" {
+		cfg.RedisURL = value
+	}
+
+	if value := envValue("FACT_CHECK_API_KEY"); value != "" {
+		cfg.FactCheckAPIKey = value
+	}
+	if value := envValue("FACT_CHECK_URL"); value != "" {
+		cfg.FactCheckURL = value
+	}
+	if value := envValue("OCR_MODEL"); value != "" {
+		cfg.OCRModel = value
+	}
+	if value := envValue("UPLOAD_DIR"); value != "" {
+		cfg.UploadDir = value
+	}
+	if value := envValue("SMTP_HOST"); value != "" {
+		cfg.SMTPHost = value
+	}
+	if value := envValue("SMTP_USERNAME"); value != "" {
+		cfg.SMTPUsername = value
+	}
+	if value := envValue("SMTP_PASSWORD"); value != "" {
+		cfg.SMTPPassword = value
+	}
+	if value := envValue("SMTP_FROM"); value != "" {
+		cfg.SMTPFrom = value
+	}
+	if value := envValue("SENTRY_DSN"); value != "" {
+		cfg.SentryDSN = value
+	}
+	if value := envValue("SENTRY_ENVIRONMENT"); value != "" {
+		cfg.SentryEnvironment = value
+	}
+	if value := envValue("SLACK_WEBHOOK_URL"); value != "" {
+		cfg.SlackWebhookURL = value
+	}
+	if value := envValue("TEAMS_WEBHOOK_URL"); value != "" {
+		cfg.TeamsWebhookURL = value
+	}
+	if value := envValue("WEBHOOK_URL"); value != "" {
+		cfg.WebhookURL = value
+	}
+	if value := envValue("WEBHOOK_SECRET"); value != "" {
+		cfg.WebhookSecret = value
+	}
+	if value := envValue("TELEGRAM_BOT_TOKEN"); value != "" {
+		cfg.TelegramBotToken = value
+	}
+	if value := envValue("TELEGRAM_WEBHOOK_SECRET"); value != "" {
+		cfg.TelegramWebhookSecret = value
+	}
+	if value := envValue("WHATSAPP_VERIFY_TOKEN"); value != "" {
+		cfg.WhatsAppVerifyToken = value
+	}
+	if value := envValue("WHATSAPP_APP_SECRET"); value != "" {
+		cfg.WhatsAppAppSecret = value
+	}
+	if value := envValue("WHATSAPP_LANGUAGE"); value != "" {
+		cfg.WhatsAppLanguage = value
+	}
+	if value := envValue("WEBHOOK_EVENTS"); value != "" {
+		cfg.WebhookEvents = strings.Split(value, ",")
+	}
+	if value := envValue("FEATURE_FLAGS"); value != "" {
+		flags, err := parseFeatureFlags(value)
+		if err != nil {
+			return err
+		}
+		cfg.FeatureFlags = flags
+	}
+	if value := envValue("ADMIN_URL"); value != "" {
+		cfg.AdminURL = value
+	}
+	if value := envValue("PUBLIC_SITE_URL"); value != "" {
+		cfg.PublicSiteURL = value
+	}
+	if value := envValue("PUBLIC_API_URL"); value != "" {
+		cfg.PublicAPIURL = value
+	}
+	if value := envValue("FEED_TITLE"); value != "" {
+		cfg.FeedTitle = value
+	}
+	if value := envValue("URL_ALLOWLIST"); value != "" {
+		cfg.URLAllowlist = strings.Split(value, ",")
+	}
+	if value := envValue("URL_DENYLIST"); value != "" {
+		cfg.URLDenylist = strings.Split(value, ",")
+	}
+	if value := envValue("FETCH_BLOCKED_NETWORKS"); value != "" {
+		cfg.FetchBlockedNetworks = strings.Split(value, ",")
+	}
+	if value := envValue("FETCH_ALLOWED_NETWORKS"); value != "" {
+		cfg.FetchAllowedNetworks = strings.Split(value, ",")
+	}
+	if value := envValue("FETCH_FALLBACKS"); value != "" {
+		cfg.FetchFallbacks = strings.Split(value, ",")
+	}
+	if value := envValue("RENDER_ENABLED"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("RENDER_ENABLED must be true or false: %w", err)
+		}
+		cfg.RenderEnabled = enabled
+	}
+	if value := envValue("CHROME_PATH"); value != "" {
+		cfg.ChromePath = value
+	}
+	if value := envValue("RENDER_BROWSER_URL"); value != "" {
+		cfg.RenderBrowserURL = value
+	}
+	if value := envValue("GAP_FEEDS"); value != "" {
+		cfg.GapFeeds = strings.Split(value, ",")
+	}
+	if value := envValue("GAP_SEARCH_URL"); value != "" {
+		cfg.GapSearchURL = value
+	}
+	if value := envValue("SEARCH_PROVIDER"); value != "" {
+		cfg.SearchProvider = value
+	}
+	if value := envValue("SEARCH_API_KEY"); value != "" {
+		cfg.SearchAPIKey = value
+	}
+	if value := envValue("SEARCH_URL"); value != "" {
+		cfg.SearchURL = value
+	}
+	if value := envValue("ENTITY_ENRICHMENT"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("ENTITY_ENRICHMENT must be true or false: %w", err)
+		}
+		cfg.EntityEnrichment = enabled
+	}
+	if value := envValue("WIKIDATA_URL"); value != "" {
+		cfg.WikidataURL = value
+	}
+	if value := envValue("FETCH_USER_AGENT"); value != "" {
+		cfg.FetchUserAgent = value
+	}
+	if value := envValue("FETCH_RESPECT_ROBOTS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("FETCH_RESPECT_ROBOTS must be true or false: %w", err)
+		}
+		cfg.FetchRespectRobots = enabled
+	}
+
+	limits := map[string]*int{
+		"DB_MAX_OPEN_CONNS":   &cfg.DBMaxOpenConns,
+		"DB_MAX_IDLE_CONNS":   &cfg.DBMaxIdleConns,
+		"HEADLINE_MAX_WORDS":  &cfg.Titles.HeadlineMaxWords,
+		"HEADLINE_MAX_CHARS":  &cfg.Titles.HeadlineMaxChars,
+		"STRAPLINE_MAX_WORDS": &cfg.Titles.StraplineMaxWords,
+		"STRAPLINE_MAX_CHARS": &cfg.Titles.StraplineMaxChars,
+		"MAX_IMAGE_BYTES":     &cfg.MaxImageBytes,
+		"MAX_BODY_BYTES":      &cfg.MaxBodyBytes,
+		"FETCH_MAX_REDIRECTS": &cfg.FetchMaxRedirects,
+		"FETCH_MIN_WORDS":     &cfg.FetchMinWords,
+		"RENDER_MIN_WORDS":    &cfg.RenderMinWords,
+		"GAP_RECHECK_DAYS":    &cfg.GapRecheckDays,
+		"DIGEST_HOUR":         &cfg.DigestHour,
+		"RETENTION_DAYS":      &cfg.RetentionDays,
+		"SEARCH_RESULTS":      &cfg.SearchResults,
+		"PROVIDER_FAILURES":   &cfg.ProviderFailures,
+		"LLM_CHUNK_TOKENS":    &cfg.LLMChunkTokens,
+		"LLM_PARALLELISM":     &cfg.LLMParallelism,
+		"SMTP_PORT":           &cfg.SMTPPort,
+	}
+	for key, target := range limits {
+		value := envValue(key)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number: %w", key, err)
+		}
+		*target = parsed
+	}
+
+	return nil
+}
+
+// parseFeatureFlags reads FEATURE_FLAGS, a comma-separated list such as
+// "enable_timeline=false,enable_web_search=true".
+func parseFeatureFlags(value string) (map[string]bool, error) {
+	flags := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, setting, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("FEATURE_FLAGS entries must be name=true or name=false (got %q)", entry)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(setting))
+		if err != nil {
+			return nil, fmt.Errorf("FEATURE_FLAGS entry %q must be true or false: %w", entry, err)
+		}
+		flags[strings.TrimSpace(key)] = enabled
+	}
+	return flags, nil
+}
+
+func envValue(key string) string {
+	return strings.TrimSpace(os.Getenv(key))
+}
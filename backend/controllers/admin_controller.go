@@ -1,34 +1,55 @@
 package controllers
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"mime"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"nanoheads/db"
+	"nanoheads/middleware"
+	"nanoheads/models"
 	"nanoheads/services"
 )
 
 type AdminController struct {
 	adminService *services.AdminService
+	views        *services.SavedViewService
 }
 
+// The version fields name the version an edit was based on. They may be sent
+// in the If-Match header instead.
 type updateFactRequest struct {
+	Version   *int64  `json:"version"`
 	Text      *string `json:"text"`
 	Included  *bool   `json:"included"`
 	Confirmed *bool   `json:"confirmed"`
 }
 
 type updateGapRequest struct {
+	Version  *int64  `json:"version"`
 	Text     *string `json:"text"`
 	Selected *bool   `json:"selected"`
 	Resolved *bool   `json:"resolved"`
 }
 
+type reviewGapAnswerRequest struct {
+	Status string `json:"status"`
+}
+
 type updateAnalysisRequest struct {
+	Version           *int64  `json:"version"`
 	Status            *string `json:"status"`
 	Category          *string `json:"category"`
 	SelectedFormat    *string `json:"selectedFormat"`
@@ -40,10 +61,48 @@ type updateAnalysisRequest struct {
 	Excerpt           *string `json:"excerpt"`
 }
 
+type bulkUpdateAnalysesRequest struct {
+	IDs      []int64 `json:"ids"`
+	Status   *string `json:"status"`
+	Category *string `json:"category"`
+}
+
+type assignAnalysisRequest struct {
+	UserID *int64 `json:"userId"`
+}
+
+type approveAnalysisRequest struct {
+	Version *int64 `json:"version"`
+	Note    string `json:"note"`
+}
+
+type flagAnalysisRequest struct {
+	Kind    string `json:"kind"`
+	Reason  string `json:"reason"`
+	Excerpt string `json:"excerpt"`
+}
+
+type clearFlagRequest struct {
+	Note string `json:"note"`
+}
+
+type addCorrectionRequest struct {
+	Mistake       string `json:"mistake"`
+	CorrectedText string `json:"correctedText"`
+}
+
+type reorderFactsRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
 type addFactRequest struct {
 	Text string `json:"text"`
 }
 
+type factTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
 type updateSettingsRequest struct {
 	Provider string `json:"provider"`
 	Model    string `json:"model"`
@@ -52,12 +111,17 @@ type updateSettingsRequest struct {
 func NewAdminController(database *sql.DB) *AdminController {
 	return &AdminController{
 		adminService: services.NewAdminService(database),
+		views:        services.NewSavedViewService(database),
 	}
 }
 
 func (a *AdminController) GetDashboard(c *gin.Context) {
 	limit := parseOptionalInt(c.Query("limit"), 5)
-	result, err := a.adminService.GetDashboard(c.Request.Context(), limit)
+	period := models.AnalyticsPeriod{
+		Bucket: c.Query("bucket"),
+		Days:   parseOptionalInt(c.Query("days"), 0),
+	}
+	result, err := a.adminService.GetDashboard(c.Request.Context(), limit, period, principalUserID(c), middleware.CurrentPrincipal(c).Visibility())
 	if err != nil {
 		respondWithError(c, err)
 		return
@@ -68,7 +132,40 @@ func (a *AdminController) GetDashboard(c *gin.Context) {
 
 func (a *AdminController) ListAnalyses(c *gin.Context) {
 	limit := parseOptionalInt(c.Query("limit"), 100)
-	items, err := a.adminService.ListAnalyses(c.Request.Context(), limit)
+
+	// A saved view supplies the defaults; explicit query parameters win.
+	var filters models.ViewFilters
+	if raw := strings.TrimSpace(c.Query("viewId")); raw != "" {
+		viewID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || viewID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid viewId", "code": models.ErrorCodeInvalidRequest})
+			return
+		}
+		view, err := a.views.Get(c.Request.Context(), viewID, principalUserID(c))
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		filters = view.Filters
+	}
+	for key, target := range map[string]*string{
+		"assignedTo": &filters.AssignedTo,
+		"createdBy":  &filters.CreatedBy,
+		"status":     &filters.Status,
+		"category":   &filters.Category,
+	} {
+		if value := strings.TrimSpace(c.Query(key)); value != "" {
+			*target = value
+		}
+	}
+	filters.Days = parseOptionalInt(c.Query("days"), filters.Days)
+
+	filter, ok := analysisFilter(c, filters)
+	if !ok {
+		return
+	}
+
+	items, err := a.adminService.ListAnalyses(c.Request.Context(), limit, filter, middleware.CurrentPrincipal(c).Visibility())
 	if err != nil {
 		respondWithError(c, err)
 		return
@@ -79,21 +176,142 @@ func (a *AdminController) ListAnalyses(c *gin.Context) {
 	})
 }
 
+// AnalysisChanges serves polling triggers in automation tools: the analyses
+// changed after ?cursor=, oldest first, with the cursor to send next time.
+func (a *AdminController) AnalysisChanges(c *gin.Context) {
+	changes, err := a.adminService.AnalysisChanges(c.Request.Context(), c.Query("cursor"), parseOptionalInt(c.Query("limit"), 0))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// CompareAnalyses sets two analyses of a story side by side, given as
+// ?ids=1,2.
+func (a *AdminController) CompareAnalyses(c *gin.Context) {
+	ids := make([]int64, 0, 2)
+	for _, raw := range strings.Split(c.Query("ids"), ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ids must be comma-separated analysis ids", "code": models.ErrorCodeInvalidRequest})
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	comparison, err := a.adminService.CompareAnalyses(c.Request.Context(), ids)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
+
 func (a *AdminController) GetAnalysis(c *gin.Context) {
 	articleID, ok := parsePathID(c, "id")
 	if !ok {
 		return
 	}
 
-	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID)
+	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID, middleware.CurrentPrincipal(c).Visibility())
 	if err != nil {
 		respondWithError(c, err)
 		return
 	}
+	// factTags lists every tag, so the filter can be offered even while on.
+	detail.Facts = services.FilterFactsByTags(detail.Facts, factTagsQuery(c))
 
+	c.Header("ETag", versionETag(detail.Version))
 	c.JSON(http.StatusOK, detail)
 }
 
+// factTagsQuery reads ?factTags=statistic,quote.
+func factTagsQuery(c *gin.Context) []string {
+	return strings.Split(c.Query("factTags"), ",")
+}
+
+// GetAnalysisImage serves an image an analysis was transcribed from.
+func (a *AdminController) GetAnalysisImage(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+	imageID, ok := parsePathID(c, "imageId")
+	if !ok {
+		return
+	}
+
+	image, path, err := a.adminService.GetAnalysisImage(c.Request.Context(), articleID, imageID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "image file is no longer stored", "code": models.ErrorCodeNotFound})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.DataFromReader(http.StatusOK, image.SizeBytes, image.MimeType, file, nil)
+}
+
+// GetSourceSnapshot serves a source page as it was fetched, as plain text so
+// the browser never runs it. X-Content-SHA256 is the hash recorded at fetch
+// time, which the body has been checked against.
+func (a *AdminController) GetSourceSnapshot(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+	snapshotID, ok := parsePathID(c, "snapshotId")
+	if !ok {
+		return
+	}
+
+	snapshot, path, err := a.adminService.GetSourceSnapshot(c.Request.Context(), articleID, snapshotID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	body, err := services.ReadSnapshot(snapshot, path)
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			c.JSON(http.StatusNotFound, gin.H{"error": "snapshot file is no longer stored", "code": models.ErrorCodeNotFound})
+		case errors.Is(err, services.ErrSnapshotChanged):
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": models.ErrorCodeInternal})
+		default:
+			respondInternalError(c, err)
+		}
+		return
+	}
+
+	contentType := "text/plain"
+	if _, params, err := mime.ParseMediaType(snapshot.ContentType); err == nil && params["charset"] != "" {
+		contentType += "; charset=" + params["charset"]
+	}
+	c.Header("X-Content-SHA256", snapshot.SHA256)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="snapshot-%d.html"`, snapshot.ID))
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, contentType, body)
+}
+
 func (a *AdminController) AddFact(c *gin.Context) {
 	articleID, ok := parsePathID(c, "id")
 	if !ok {
@@ -101,12 +319,11 @@ func (a *AdminController) AddFact(c *gin.Context) {
 	}
 
 	var req addFactRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	factID, err := a.adminService.AddFact(c.Request.Context(), articleID, req.Text)
+	factID, err := a.adminService.AddFact(c.Request.Context(), articleID, req.Text, principalUserID(c))
 	if err != nil {
 		respondWithError(c, err)
 		return
@@ -117,6 +334,33 @@ func (a *AdminController) AddFact(c *gin.Context) {
 	})
 }
 
+// ReorderFacts takes every fact id of the analysis in the new order and
+// returns the reordered analysis.
+func (a *AdminController) ReorderFacts(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req reorderFactsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := a.adminService.ReorderFacts(c.Request.Context(), articleID, req.IDs, principalUserID(c)); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID, middleware.CurrentPrincipal(c).Visibility())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
 func (a *AdminController) UpdateFact(c *gin.Context) {
 	factID, ok := parsePathID(c, "id")
 	if !ok {
@@ -124,17 +368,22 @@ func (a *AdminController) UpdateFact(c *gin.Context) {
 	}
 
 	var req updateFactRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
+		return
+	}
+	version, ok := editVersion(c, req.Version)
+	if !ok {
 		return
 	}
 
-	if err := a.adminService.UpdateFact(c.Request.Context(), factID, req.Text, req.Included, req.Confirmed); err != nil {
+	version, err := a.adminService.UpdateFact(c.Request.Context(), factID, version, req.Text, req.Included, req.Confirmed, principalUserID(c))
+	if err != nil {
 		respondWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	c.Header("ETag", versionETag(version))
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "version": version})
 }
 
 func (a *AdminController) DeleteFact(c *gin.Context) {
@@ -143,7 +392,7 @@ func (a *AdminController) DeleteFact(c *gin.Context) {
 		return
 	}
 
-	if err := a.adminService.DeleteFact(c.Request.Context(), factID); err != nil {
+	if err := a.adminService.DeleteFact(c.Request.Context(), factID, principalUserID(c)); err != nil {
 		respondWithError(c, err)
 		return
 	}
@@ -151,6 +400,61 @@ func (a *AdminController) DeleteFact(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// ListFacts lists an analysis's facts; includeDeleted=true adds the trash.
+func (a *AdminController) ListFacts(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+	includeDeleted, _ := strconv.ParseBool(c.Query("includeDeleted"))
+
+	facts, err := a.adminService.ListFacts(c.Request.Context(), articleID, includeDeleted)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": services.FilterFactsByTags(facts, factTagsQuery(c))})
+}
+
+// SetFactTags replaces a fact's tags, such as statistic or official
+// statement. Tags sit outside the fact's text, so no version is checked.
+func (a *AdminController) SetFactTags(c *gin.Context) {
+	factID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req factTagsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tags, err := a.adminService.SetFactTags(c.Request.Context(), factID, req.Tags, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+func (a *AdminController) RestoreFact(c *gin.Context) {
+	factID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	fact, err := a.adminService.RestoreFact(c.Request.Context(), factID, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", versionETag(fact.Version))
+	c.JSON(http.StatusOK, fact)
+}
+
 func (a *AdminController) UpdateGap(c *gin.Context) {
 	gapID, ok := parsePathID(c, "id")
 	if !ok {
@@ -158,12 +462,75 @@ func (a *AdminController) UpdateGap(c *gin.Context) {
 	}
 
 	var req updateGapRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
+		return
+	}
+	version, ok := editVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
+	version, err := a.adminService.UpdateGap(c.Request.Context(), gapID, version, req.Text, req.Selected, req.Resolved, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", versionETag(version))
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "version": version})
+}
+
+func (a *AdminController) UndoFactEdit(c *gin.Context) {
+	a.revertEdit(c, a.adminService.UndoFactEdit)
+}
+
+func (a *AdminController) RedoFactEdit(c *gin.Context) {
+	a.revertEdit(c, a.adminService.RedoFactEdit)
+}
+
+func (a *AdminController) UndoGapEdit(c *gin.Context) {
+	a.revertEdit(c, a.adminService.UndoGapEdit)
+}
+
+func (a *AdminController) RedoGapEdit(c *gin.Context) {
+	a.revertEdit(c, a.adminService.RedoGapEdit)
+}
+
+// revertEdit runs an undo or redo on the record in the path. It needs no
+// version: one is refused if the record was changed since the edit.
+func (a *AdminController) revertEdit(c *gin.Context, revert func(context.Context, int64, *int64) (int64, error)) {
+	id, ok := parsePathID(c, "id")
+	if !ok {
 		return
 	}
 
-	if err := a.adminService.UpdateGap(c.Request.Context(), gapID, req.Text, req.Selected, req.Resolved); err != nil {
+	version, err := revert(c.Request.Context(), id, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", versionETag(version))
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "version": version})
+}
+
+// ReviewGapAnswer accepts or rejects a candidate answer from gap research.
+func (a *AdminController) ReviewGapAnswer(c *gin.Context) {
+	gapID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+	answerID, ok := parsePathID(c, "answerId")
+	if !ok {
+		return
+	}
+
+	var req reviewGapAnswerRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := a.adminService.ReviewGapAnswer(c.Request.Context(), gapID, answerID, req.Status, principalUserID(c)); err != nil {
 		respondWithError(c, err)
 		return
 	}
@@ -178,14 +545,24 @@ func (a *AdminController) UpdateAnalysis(c *gin.Context) {
 	}
 
 	var req updateAnalysisRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
+		return
+	}
+	version, ok := editVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
+	if req.Status != nil && strings.EqualFold(strings.TrimSpace(*req.Status), "completed") &&
+		!middleware.HasPermission(c, models.PermissionPublish) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "missing permission: " + models.PermissionPublish, "code": models.ErrorCodeForbidden})
 		return
 	}
 
 	if err := a.adminService.UpdateAnalysis(
 		c.Request.Context(),
 		articleID,
+		version,
 		req.Status,
 		req.Category,
 		req.SelectedFormat,
@@ -195,12 +572,217 @@ func (a *AdminController) UpdateAnalysis(c *gin.Context) {
 		req.Slug,
 		req.MetaDescription,
 		req.Excerpt,
+		principalUserID(c),
 	); err != nil {
 		respondWithError(c, err)
 		return
 	}
 
-	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID)
+	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID, middleware.CurrentPrincipal(c).Visibility())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", versionETag(detail.Version))
+	c.JSON(http.StatusOK, detail)
+}
+
+// BulkUpdateAnalyses applies one status and/or category to every listed
+// analysis, or to none of them if any id is unknown.
+func (a *AdminController) BulkUpdateAnalyses(c *gin.Context) {
+	var req bulkUpdateAnalysesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if req.Status != nil && strings.EqualFold(strings.TrimSpace(*req.Status), "completed") &&
+		!middleware.HasPermission(c, models.PermissionPublish) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "missing permission: " + models.PermissionPublish, "code": models.ErrorCodeForbidden})
+		return
+	}
+
+	updated, err := a.adminService.BulkUpdateAnalyses(c.Request.Context(), req.IDs, req.Status, req.Category, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"updated": updated,
+	})
+}
+
+// AssignAnalysis sets who reviews an analysis and returns the updated detail.
+func (a *AdminController) AssignAnalysis(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req assignAnalysisRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.UserID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "userId is required", "code": models.ErrorCodeInvalidRequest})
+		return
+	}
+
+	a.assign(c, articleID, req.UserID)
+}
+
+func (a *AdminController) UnassignAnalysis(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	a.assign(c, articleID, nil)
+}
+
+// ApproveAnalysis signs off the version of an analysis the reviewer read,
+// given as for an edit, and returns the detail.
+func (a *AdminController) ApproveAnalysis(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req approveAnalysisRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	version, ok := editVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
+	if err := a.adminService.ApproveAnalysis(c.Request.Context(), articleID, version, principalUserID(c), req.Note); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID, middleware.CurrentPrincipal(c).Visibility())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+func (a *AdminController) WithdrawApproval(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := a.adminService.WithdrawApproval(c.Request.Context(), articleID, principalUserID(c)); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID, middleware.CurrentPrincipal(c).Visibility())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// FlagAnalysis raises a legal or sensitivity concern; the analysis can't be
+// completed until the flag is cleared.
+func (a *AdminController) FlagAnalysis(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req flagAnalysisRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	flag, err := a.adminService.FlagAnalysis(c.Request.Context(), articleID, req.Kind, req.Reason, req.Excerpt, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, flag)
+}
+
+func (a *AdminController) ClearFlag(c *gin.Context) {
+	flagID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req clearFlagRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	flag, err := a.adminService.ClearFlag(c.Request.Context(), flagID, principalUserID(c), req.Note)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// AddCorrection records what a published analysis got wrong and what it
+// should have said.
+func (a *AdminController) AddCorrection(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req addCorrectionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	correction, err := a.adminService.AddCorrection(c.Request.Context(), articleID, req.Mistake, req.CorrectedText, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, correction)
+}
+
+func (a *AdminController) assign(c *gin.Context, articleID int64, userID *int64) {
+	if err := a.adminService.AssignAnalysis(c.Request.Context(), articleID, userID, principalUserID(c)); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID, middleware.CurrentPrincipal(c).Visibility())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+func (a *AdminController) AcceptCategorySuggestion(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := a.adminService.AcceptCategorySuggestion(c.Request.Context(), articleID); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	detail, err := a.adminService.GetAnalysisDetail(c.Request.Context(), articleID, middleware.CurrentPrincipal(c).Visibility())
 	if err != nil {
 		respondWithError(c, err)
 		return
@@ -233,8 +815,7 @@ func (a *AdminController) GetSettings(c *gin.Context) {
 
 func (a *AdminController) UpdateSettings(c *gin.Context) {
 	var req updateSettingsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -252,24 +833,101 @@ func (a *AdminController) UpdateSettings(c *gin.Context) {
 	c.JSON(http.StatusOK, settings)
 }
 
+// TestSettings runs a tiny completion against the selected model, or the
+// provider and model in the body, so a switch can be checked first.
+func (a *AdminController) TestSettings(c *gin.Context) {
+	var req updateSettingsRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := a.adminService.TestSettings(c.Request.Context(), req.Provider, req.Model)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 func respondWithError(c *gin.Context, err error) {
-	if errors.Is(err, sql.ErrNoRows) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+	if respondProviderUnavailable(c, err) {
 		return
 	}
 
-	lower := strings.ToLower(err.Error())
-	if strings.Contains(lower, "required") ||
-		strings.Contains(lower, "invalid") ||
-		strings.Contains(lower, "must be") ||
-		strings.Contains(lower, "no fact fields provided") ||
-		strings.Contains(lower, "no gap fields provided") ||
-		strings.Contains(lower, "no analysis fields provided") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	code := services.ErrorCode(err)
+	switch code {
+	case models.ErrorCodeVersionConflict:
+		var conflict *services.VersionConflictError
+		errors.As(err, &conflict)
+		c.Header("ETag", versionETag(conflict.Current))
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": code, "currentVersion": conflict.Current})
+	case models.ErrorCodeUnauthenticated:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": code})
+	case models.ErrorCodeNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found", "code": code})
+	case models.ErrorCodeNothingToUndo, models.ErrorCodeNothingToRedo:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": code})
+	case models.ErrorCodeInvalidRequest:
+		// A validation message wrapping a database or provider error is
+		// still ours to fix, and may carry details callers shouldn't see.
+		if db.IsDriverError(err) || services.IsUpstreamError(err) {
+			respondInternalError(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": code})
+	case models.ErrorCodeURLUnreachable, models.ErrorCodeURLNoText, models.ErrorCodeEmptyFacts,
+		models.ErrorCodeContentSpam, models.ErrorCodeContentAdult, models.ErrorCodeContentGibberish:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "code": code})
+	case models.ErrorCodeNotConfigured:
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error(), "code": code})
+	case models.ErrorCodeProviderQuota:
+		// The provider's message names our account and plan.
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the AI provider's rate limit or quota is used up; try again later", "code": code})
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// respondInternalError only passes the underlying error through to callers
+// allowed to see diagnostics; provider and database errors can carry request
+// bodies, hostnames and keys.
+func respondInternalError(c *gin.Context, err error) {
+	if respondProviderUnavailable(c, err) {
+		return
+	}
+	// Kept for middleware.ReportErrors.
+	c.Error(err)
+	errorID := middleware.ErrorID(c)
+	log.Printf("[error] error_id=%s request_id=%s %s %s: %v", errorID, services.RequestIDFromContext(c.Request.Context()), c.Request.Method, c.FullPath(), err)
+	if middleware.HasPermission(c, models.PermissionViewDiagnostics) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": models.ErrorCodeInternal, "errorId": errorID})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "code": models.ErrorCodeInternal, "errorId": errorID})
+}
+
+// respondProviderUnavailable answers 503 when err is a call skipped because
+// the model provider is resting, with the providers' health. Their last
+// errors are only shown to callers allowed to see diagnostics.
+func respondProviderUnavailable(c *gin.Context, err error) bool {
+	var unavailable *services.ProviderUnavailableError
+	if !errors.As(err, &unavailable) {
+		return false
+	}
 
-	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	providers := make([]models.ProviderHealth, len(unavailable.Providers))
+	copy(providers, unavailable.Providers)
+	if !middleware.HasPermission(c, models.PermissionViewDiagnostics) {
+		for idx := range providers {
+			providers[idx].LastError = ""
+		}
+	}
+	if retryAt := providers[0].RetryAt; retryAt != nil {
+		c.Header("Retry-After", strconv.Itoa(max(int(math.Ceil(time.Until(*retryAt).Seconds())), 1)))
+	}
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error(), "code": models.ErrorCodeProviderUnavailable, "providers": providers})
+	return true
 }
 
 func parseOptionalInt(raw string, defaultValue int) int {
@@ -285,11 +943,85 @@ func parseOptionalInt(raw string, defaultValue int) int {
 	return value
 }
 
+// analysisFilter turns a view's filters into a list filter, answering 400
+// itself when a user filter isn't valid.
+func analysisFilter(c *gin.Context, filters models.ViewFilters) (models.AnalysisFilter, bool) {
+	filter := models.AnalysisFilter{
+		Status:   filters.Status,
+		Category: filters.Category,
+		Days:     filters.Days,
+	}
+	if strings.EqualFold(filters.AssignedTo, "none") {
+		filter.Unassigned = true
+	} else if filters.AssignedTo != "" {
+		userID, ok := parseUserFilter(c, "assignedTo", filters.AssignedTo)
+		if !ok {
+			return models.AnalysisFilter{}, false
+		}
+		filter.AssignedTo = &userID
+	}
+	if filters.CreatedBy != "" {
+		userID, ok := parseUserFilter(c, "createdBy", filters.CreatedBy)
+		if !ok {
+			return models.AnalysisFilter{}, false
+		}
+		filter.CreatedBy = &userID
+	}
+	return filter, true
+}
+
+// parseUserFilter reads a user id query value, where "me" is the caller.
+func parseUserFilter(c *gin.Context, key string, value string) (int64, bool) {
+	if strings.EqualFold(value, "me") {
+		if userID := principalUserID(c); userID != nil {
+			return *userID, true
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": key + "=me needs a signed-in user", "code": models.ErrorCodeInvalidRequest})
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + key, "code": models.ErrorCodeInvalidRequest})
+		return 0, false
+	}
+	return id, true
+}
+
+// editVersion reads the version an edit is based on from the If-Match header
+// or, failing that, the body. Edits without one are refused, so that nobody
+// overwrites a change they haven't seen.
+func editVersion(c *gin.Context, bodyVersion *int64) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if bodyVersion == nil {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "version is required: send the If-Match header or a version field", "code": models.ErrorCodeVersionRequired})
+			return 0, false
+		}
+		return *bodyVersion, true
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid If-Match header: expected a version such as \"3\"", "code": models.ErrorCodeInvalidRequest})
+		return 0, false
+	}
+	if bodyVersion != nil && *bodyVersion != version {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match and version disagree", "code": models.ErrorCodeInvalidRequest})
+		return 0, false
+	}
+	return version, true
+}
+
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
 func parsePathID(c *gin.Context, key string) (int64, bool) {
 	value := strings.TrimSpace(c.Param(key))
 	id, err := strconv.ParseInt(value, 10, 64)
 	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id", "code": models.ErrorCodeInvalidRequest})
 		return 0, false
 	}
 	return id, true
@@ -2,42 +2,101 @@ package controllers
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"nanoheads/config"
 	"nanoheads/models"
 	"nanoheads/services"
 )
 
 type AnalyseController struct {
 	factService *services.FactService
+	languages   *services.LanguageService
 }
 
 type analyseRequest struct {
-	Text     string `json:"text"`
-	URL      string `json:"url"`
-	Content  string `json:"content"`
-	Language string `json:"language"`
-	Category string `json:"category"`
+	Text     string `json:"text" form:"text"`
+	URL      string `json:"url" form:"url"`
+	Content  string `json:"content" form:"content"`
+	Language string `json:"language" form:"language"`
+	Category string `json:"category" form:"category"`
+
+	// ArticleMode is "paragraph" (the default) or "long-form".
+	ArticleMode string `json:"articleMode" form:"articleMode"`
+
+	Lengths models.LengthTargets `json:"lengths" form:"-"`
+
+	// Render loads the URLs in a headless browser, for pages that need
+	// JavaScript.
+	Render bool `json:"render" form:"render"`
+
+	Sources []analyseSource `json:"sources" form:"-"`
+	URLs    []string        `json:"urls" form:"urls"`
+	Texts   []string        `json:"texts" form:"texts"`
+
+	// Images carry base64 data or data: URLs for JSON clients; form uploads
+	// use image or images file fields instead.
+	Images []analyseImage `json:"images" form:"-"`
+}
+
+type simplifyArticleRequest struct {
+	Level string `json:"level"`
+}
+
+type mergeAnalysesRequest struct {
+	IDs         []int64              `json:"ids"`
+	ArticleMode string               `json:"articleMode"`
+	Lengths     models.LengthTargets `json:"lengths"`
+}
+
+type analyseImage struct {
+	Data     string `json:"data"`
+	FileName string `json:"fileName"`
+}
+
+type analyseSource struct {
+	Text    string `json:"text"`
+	URL     string `json:"url"`
+	Content string `json:"content"`
 }
 
 func NewAnalyseController(database *sql.DB) *AnalyseController {
 	return &AnalyseController{
 		factService: services.NewFactService(database),
+		languages:   services.NewLanguageService(database),
 	}
 }
 
 func (a *AnalyseController) AnalyseArticle(c *gin.Context) {
-	var req analyseRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
+	var (
+		req    analyseRequest
+		images []models.ImageInput
+		err    error
+	)
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		if !bindForm(c, &req) {
+			return
+		}
+		images, err = readUploadedImages(c)
+	} else {
+		if !bindJSON(c, &req) {
+			return
+		}
+		images, err = decodeRequestImages(req.Images)
+	}
+	var validation requestValidation
+	if err != nil {
+		validation.add("images", "%s", err.Error())
 	}
 
 	text := strings.TrimSpace(req.Text)
@@ -46,44 +105,299 @@ func (a *AnalyseController) AnalyseArticle(c *gin.Context) {
 	}
 
 	urlValue := strings.TrimSpace(req.URL)
+	validation.checkURL("url", urlValue)
+	for idx, source := range req.Sources {
+		validation.checkURL(fmt.Sprintf("sources[%d].url", idx), strings.TrimSpace(source.URL))
+	}
+	for idx, value := range req.URLs {
+		validation.checkURL(fmt.Sprintf("urls[%d]", idx), strings.TrimSpace(value))
+	}
+
 	language := strings.TrimSpace(req.Language)
+	if language != "" {
+		languages, err := a.languages.List(c.Request.Context())
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if resolved, ok := services.FindLanguage(languages, language); ok {
+			language = resolved.Name
+		} else {
+			validation.add("language", "must be one of %s", languageNames(languages))
+		}
+	}
 	category := strings.TrimSpace(req.Category)
-	if text == "" && urlValue == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "provide either text or url",
-		})
+	articleMode, err := services.NormalizeArticleMode(req.ArticleMode)
+	if err != nil {
+		validation.add("articleMode", "must be %s or %s", models.ArticleModeParagraph, models.ArticleModeLongForm)
+	}
+	if err := services.ValidateLengthTargets(req.Lengths); err != nil {
+		validation.add("lengths", "are invalid: %s", err.Error())
+	}
+
+	sources := collectAnalyseSources(text, urlValue, req)
+	if len(sources) > services.MaxCorroborationSources {
+		validation.add("sources", "must be at most %d", services.MaxCorroborationSources)
+	}
+	if len(sources) == 1 {
+		text, urlValue = sources[0].Text, sources[0].URL
+		sources = nil
+	}
+	if len(images) > 0 && len(sources) > 0 {
+		validation.add("images", "cannot be combined with multiple sources")
+	}
+	if text == "" && urlValue == "" && len(sources) == 0 && len(images) == 0 {
+		validation.add("text", "is required unless a url, sources or images are given")
+	}
+	if req.Render && !config.Current().RenderEnabled {
+		validation.add("render", "is not available: rendering is not enabled on this server")
+	}
+	if validation.respond(c) {
 		return
 	}
 
 	log.Printf(
-		"phase-1 incoming request: text=%s url=%s language=%s category=%s",
+		"phase-1 incoming request: text=%s url=%s language=%s category=%s sources=%d images=%d",
 		previewForLog(text),
 		previewForLog(urlValue),
 		previewForLog(language),
 		previewForLog(category),
+		len(sources),
+		len(images),
 	)
 
+	params := map[string]any{
+		"url":        urlValue,
+		"language":   language,
+		"category":   category,
+		"textLength": len([]rune(text)),
+	}
+	if len(sources) > 0 {
+		params["sources"] = len(sources)
+	}
+	if len(images) > 0 {
+		params["images"] = len(images)
+	}
+	if articleMode != models.ArticleModeParagraph {
+		params["articleMode"] = articleMode
+	}
+	if !req.Lengths.IsZero() {
+		params["lengths"] = req.Lengths
+	}
+	if req.Render {
+		params["render"] = true
+	}
+
 	result, err := a.factService.RunPhaseOne(c.Request.Context(), models.PhaseOneInput{
-		Text:     text,
-		URL:      urlValue,
-		Language: language,
-		Category: category,
+		Text:        text,
+		URL:         urlValue,
+		Language:    language,
+		Category:    category,
+		Sources:     sources,
+		Images:      images,
+		ArticleMode: articleMode,
+		Lengths:     req.Lengths,
+		Render:      req.Render,
+		Submission:  newSubmission(c, browserChannel(c), params),
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		respondInternalError(c, err)
+		return
+	}
+
+	if config.Current().DebugEnabled() {
+		responseBytes, err := json.Marshal(result)
+		if err == nil {
+			log.Printf("phase-1 response: %s", responseBytes)
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MergeAnalyses combines analyses of the same story into a new analysis and
+// returns it as POST /analyse would.
+func (a *AnalyseController) MergeAnalyses(c *gin.Context) {
+	var req mergeAnalysesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	submission := newSubmission(c, browserChannel(c), map[string]any{"mergedFrom": req.IDs})
+	result, err := a.factService.MergeAnalyses(c.Request.Context(), req.IDs, req.ArticleMode, req.Lengths, submission)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RetryAnalysis re-runs the pipeline steps a failed analysis didn't finish
+// and returns the completed analysis as POST /analyse would.
+func (a *AnalyseController) RetryAnalysis(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	result, err := a.factService.RetryAnalysis(c.Request.Context(), articleID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SimplifyArticle rewrites an analysis's article for the requested reading
+// level and replaces the stored text.
+func (a *AnalyseController) SimplifyArticle(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
 		return
 	}
 
-	responseBytes, err := json.Marshal(result)
-	if err == nil {
-		log.Printf("phase-1 response: %s", responseBytes)
+	var req simplifyArticleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := a.factService.SimplifyArticle(c.Request.Context(), articleID, req.Level, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// collectAnalyseSources gathers every report in the request: the top-level
+// text/url pair, then sources, urls and texts. Blank entries are dropped.
+func collectAnalyseSources(text string, urlValue string, req analyseRequest) []models.SourceInput {
+	sources := make([]models.SourceInput, 0, 1+len(req.Sources)+len(req.URLs)+len(req.Texts))
+	add := func(text string, urlValue string) {
+		text, urlValue = strings.TrimSpace(text), strings.TrimSpace(urlValue)
+		if text != "" || urlValue != "" {
+			sources = append(sources, models.SourceInput{Text: text, URL: urlValue})
+		}
+	}
+
+	add(text, urlValue)
+	for _, source := range req.Sources {
+		sourceText := source.Text
+		if strings.TrimSpace(sourceText) == "" {
+			sourceText = source.Content
+		}
+		add(sourceText, source.URL)
+	}
+	for _, value := range req.URLs {
+		add("", value)
+	}
+	for _, value := range req.Texts {
+		add(value, "")
+	}
+	return sources
+}
+
+// MaxAnalyseBodyBytes leaves room for every image at the size limit, base64
+// encoded as JSON clients send them, plus the text fields.
+func MaxAnalyseBodyBytes() int64 {
+	return int64(services.MaxAnalysisImages)*int64(config.Current().MaxImageBytes)*4/3 + 1<<20
+}
+
+func readUploadedImages(c *gin.Context) ([]models.ImageInput, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, errors.New("invalid form body")
+	}
+
+	headers := append(append([]*multipart.FileHeader(nil), form.File["image"]...), form.File["images"]...)
+	if len(headers) > services.MaxAnalysisImages {
+		return nil, fmt.Errorf("at most %d images are allowed", services.MaxAnalysisImages)
+	}
+
+	limit := int64(config.Current().MaxImageBytes)
+	images := make([]models.ImageInput, 0, len(headers))
+	for idx, header := range headers {
+		if header.Size > limit {
+			return nil, fmt.Errorf("image %d must be at most %s", idx+1, formatByteLimit(limit))
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("image %d could not be read", idx+1)
+		}
+		data, err := io.ReadAll(io.LimitReader(file, limit+1))
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("image %d could not be read", idx+1)
+		}
+
+		image, err := newImageInput(idx, header.Filename, data)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func decodeRequestImages(items []analyseImage) ([]models.ImageInput, error) {
+	if len(items) > services.MaxAnalysisImages {
+		return nil, fmt.Errorf("at most %d images are allowed", services.MaxAnalysisImages)
+	}
+
+	images := make([]models.ImageInput, 0, len(items))
+	for idx, item := range items {
+		raw := strings.TrimSpace(item.Data)
+		if strings.HasPrefix(raw, "data:") {
+			if comma := strings.Index(raw, ","); comma >= 0 {
+				raw = raw[comma+1:]
+			}
+		}
+		if raw == "" {
+			return nil, fmt.Errorf("image %d data is required", idx+1)
+		}
+
+		data, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("image %d data must be base64", idx+1)
+		}
+
+		image, err := newImageInput(idx, item.FileName, data)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// newImageInput checks the size and sniffs the type from the bytes; the
+// declared content type of an upload isn't trusted.
+func newImageInput(idx int, fileName string, data []byte) (models.ImageInput, error) {
+	limit := int64(config.Current().MaxImageBytes)
+	if len(data) == 0 {
+		return models.ImageInput{}, fmt.Errorf("image %d must not be empty", idx+1)
+	}
+	if int64(len(data)) > limit {
+		return models.ImageInput{}, fmt.Errorf("image %d must be at most %s", idx+1, formatByteLimit(limit))
+	}
+
+	mimeType := http.DetectContentType(data)
+	if _, ok := services.ImageExtensions[mimeType]; !ok {
+		return models.ImageInput{}, fmt.Errorf("image %d must be a PNG, JPEG, WebP or GIF", idx+1)
+	}
+	return models.ImageInput{FileName: fileName, MimeType: mimeType, Data: data}, nil
+}
+
+func formatByteLimit(limit int64) string {
+	if limit >= 1<<20 && limit%(1<<20) == 0 {
+		return fmt.Sprintf("%d MB", limit>>20)
+	}
+	return fmt.Sprintf("%d bytes", limit)
+}
+
 func previewForLog(value string) string {
 	if strings.TrimSpace(value) == "" {
 		return "<empty>"
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+const maxClipBodyBytes = 2 << 20
+
+// Extensions and bookmarklets name things differently. Each field takes the
+// first alias present; keys are compared lowercased with "_" and "-" removed.
+var (
+	clipURLKeys      = []string{"url", "pageurl", "sourceurl", "href", "link", "location"}
+	clipTitleKeys    = []string{"title", "pagetitle", "documenttitle", "headline"}
+	clipTextKeys     = []string{"selection", "selectedtext", "selectiontext", "text", "quote", "content", "body"}
+	clipLanguageKeys = []string{"language", "lang"}
+	clipCategoryKeys = []string{"category"}
+)
+
+type ClipController struct {
+	jobs *services.JobService
+}
+
+func NewClipController(database *sql.DB) *ClipController {
+	return &ClipController{
+		jobs: services.NewJobService(database),
+	}
+}
+
+// Clip queues the page a reporter is reading for analysis. It accepts JSON
+// (flat or one level nested, e.g. {"page": {"url": ...}}), form posts, a
+// plain-text body holding the selection, and query parameters as a fallback.
+func (cc *ClipController) Clip(c *gin.Context) {
+	clip, err := parseClip(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": middleware.BodyTooLargeMessage(tooLarge.Limit), "code": models.ErrorCodeBodyTooLarge})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": models.ErrorCodeInvalidRequest})
+		return
+	}
+
+	clip.Submission = newSubmission(c, models.SubmissionChannelExtension, map[string]any{
+		"url":             clip.URL,
+		"title":           clip.Title,
+		"language":        clip.Language,
+		"category":        clip.Category,
+		"selectionLength": len([]rune(strings.TrimSpace(clip.Text))),
+	})
+
+	job, err := services.EnqueueClip(c.Request.Context(), cc.jobs, clip, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
+
+func parseClip(c *gin.Context) (models.Clip, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxClipBodyBytes))
+	if err != nil {
+		return models.Clip{}, err
+	}
+
+	// Values are gathered in layers: the body itself, objects nested one level
+	// down, then the query string. Earlier layers win.
+	fromBody, nested, query := make(map[string]string), make(map[string]string), make(map[string]string)
+	contentType := c.ContentType()
+
+	switch {
+	case contentType == gin.MIMEPOSTForm || contentType == gin.MIMEMultipartPOSTForm:
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err := c.Request.ParseMultipartForm(maxClipBodyBytes); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return models.Clip{}, errors.New("invalid form body")
+		}
+		for key, items := range c.Request.PostForm {
+			setClipValue(fromBody, key, strings.Join(items, "\n\n"))
+		}
+	case len(bytes.TrimSpace(body)) == 0:
+	default:
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err != nil {
+			if contentType == gin.MIMEJSON {
+				return models.Clip{}, errors.New("invalid JSON body")
+			}
+			// A body that is not JSON is taken to be the selection itself.
+			setClipValue(fromBody, "selection", string(body))
+			break
+		}
+		collectClipValues(fromBody, nested, payload)
+	}
+
+	for key, items := range c.Request.URL.Query() {
+		if len(items) > 0 {
+			setClipValue(query, key, items[0])
+		}
+	}
+
+	layers := []map[string]string{fromBody, nested, query}
+	return models.Clip{
+		URL:      pickClipValue(layers, clipURLKeys),
+		Title:    pickClipValue(layers, clipTitleKeys),
+		Text:     pickClipValue(layers, clipTextKeys),
+		Language: pickClipValue(layers, clipLanguageKeys),
+		Category: pickClipValue(layers, clipCategoryKeys),
+	}, nil
+}
+
+func collectClipValues(values map[string]string, nested map[string]string, payload map[string]any) {
+	for key, value := range payload {
+		switch typed := value.(type) {
+		case string:
+			setClipValue(values, key, typed)
+		case []any:
+			parts := make([]string, 0, len(typed))
+			for _, item := range typed {
+				if text, ok := item.(string); ok && strings.TrimSpace(text) != "" {
+					parts = append(parts, strings.TrimSpace(text))
+				}
+			}
+			setClipValue(values, key, strings.Join(parts, "\n\n"))
+		case map[string]any:
+			for innerKey, innerValue := range typed {
+				if text, ok := innerValue.(string); ok {
+					setClipValue(nested, innerKey, text)
+				}
+			}
+		}
+	}
+}
+
+func setClipValue(values map[string]string, key string, value string) {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(key)))
+	if normalized == "" || strings.TrimSpace(value) == "" {
+		return
+	}
+	if _, exists := values[normalized]; exists {
+		return
+	}
+	values[normalized] = value
+}
+
+func pickClipValue(layers []map[string]string, keys []string) string {
+	for _, values := range layers {
+		for _, key := range keys {
+			if value, ok := values[key]; ok {
+				return value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+const (
+	livePingInterval = 30 * time.Second
+	// A client that sends nothing, not even a pong, for this long is gone.
+	liveReadTimeout  = 75 * time.Second
+	liveWriteTimeout = 10 * time.Second
+	maxLiveMessage   = 4 << 10
+	// liveProtocol is the subprotocol clients offer alongside their key.
+	liveProtocol = "nanoheads"
+)
+
+type CollaborationController struct {
+	collaboration *services.CollaborationService
+}
+
+func NewCollaborationController(database *sql.DB) *CollaborationController {
+	return &CollaborationController{
+		collaboration: services.NewCollaborationService(database),
+	}
+}
+
+type presenceMessage struct {
+	State  string `json:"state"`
+	Target string `json:"target"`
+}
+
+// liveConn serialises writes, as pongs and close replies are written from
+// the reading goroutine while events are written from the handler's.
+type liveConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Live upgrades to a WebSocket carrying the analysis's presence and edit
+// events. The client sends {"state": "viewing" | "editing", "target": ...}
+// as the user moves between reading and editing. Browsers authenticate by
+// offering the subprotocols "nanoheads" and "apikey.<key>".
+func (cc *CollaborationController) Live(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "websocket upgrade required", "code": models.ErrorCodeInvalidRequest})
+		return
+	}
+
+	session, err := cc.collaboration.Join(c.Request.Context(), articleID, middleware.CurrentPrincipal(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	defer session.Leave()
+
+	upgrader := ws.HTTPUpgrader{Protocol: func(protocol string) bool { return protocol == liveProtocol }}
+	conn, _, _, err := upgrader.Upgrade(c.Request, c.Writer)
+	if err != nil {
+		log.Printf("[collaboration] upgrade failed for analysis %d: %v", articleID, err)
+		return
+	}
+	live := &liveConn{conn: conn}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		live.readPresence(session)
+	}()
+
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, open := <-session.Events():
+			if !open {
+				live.write(ws.OpClose, ws.NewCloseFrameBody(ws.StatusGoingAway, "fell behind; reconnect"))
+				return
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("[collaboration] failed to encode event: %v", err)
+				continue
+			}
+			if err := live.write(ws.OpText, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := live.write(ws.OpPing, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (l *liveConn) write(op ws.OpCode, payload []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout)); err != nil {
+		return err
+	}
+	return wsutil.WriteServerMessage(l.conn, op, payload)
+}
+
+// readPresence applies the client's presence messages until it disconnects.
+func (l *liveConn) readPresence(session *services.CollaborationSession) {
+	controls := func(header ws.Header, payload io.Reader) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if err := l.conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout)); err != nil {
+			return err
+		}
+		return wsutil.ControlHandler{Src: payload, Dst: l.conn, State: ws.StateServerSide, DisableSrcCiphering: true}.Handle(header)
+	}
+	reader := wsutil.Reader{Source: l.conn, State: ws.StateServerSide, CheckUTF8: true, OnIntermediate: controls}
+
+	for {
+		if err := l.conn.SetReadDeadline(time.Now().Add(liveReadTimeout)); err != nil {
+			return
+		}
+		header, err := reader.NextFrame()
+		if err != nil {
+			return
+		}
+		if header.OpCode.IsControl() {
+			if err := controls(header, &reader); err != nil {
+				return
+			}
+			continue
+		}
+		if header.OpCode != ws.OpText || header.Length > maxLiveMessage {
+			l.write(ws.OpClose, ws.NewCloseFrameBody(ws.StatusUnsupportedData, "send JSON text messages of at most 4KB"))
+			return
+		}
+
+		var message presenceMessage
+		data, err := io.ReadAll(io.LimitReader(&reader, maxLiveMessage))
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(data, &message)
+		if err == nil {
+			err = session.SetState(message.State, message.Target)
+		}
+		if err != nil {
+			reply, _ := json.Marshal(gin.H{"type": "error", "error": err.Error()})
+			if l.write(ws.OpText, reply) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type CommentController struct {
+	comments *services.CommentService
+}
+
+type createCommentRequest struct {
+	Body     string `json:"body"`
+	ParentID *int64 `json:"parentId"`
+	FactID   *int64 `json:"factId"`
+	GapID    *int64 `json:"gapId"`
+}
+
+func NewCommentController(database *sql.DB) *CommentController {
+	return &CommentController{
+		comments: services.NewCommentService(database),
+	}
+}
+
+func (cc *CommentController) ListComments(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	items, err := cc.comments.List(c.Request.Context(), articleID, c.Query("status"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+	})
+}
+
+func (cc *CommentController) CreateComment(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req createCommentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	comment, err := cc.comments.Create(c.Request.Context(), articleID, req.ParentID, req.FactID, req.GapID, req.Body, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+func (cc *CommentController) ResolveComment(c *gin.Context) {
+	cc.setResolved(c, true)
+}
+
+func (cc *CommentController) ReopenComment(c *gin.Context) {
+	cc.setResolved(c, false)
+}
+
+func (cc *CommentController) setResolved(c *gin.Context, resolved bool) {
+	commentID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	comment, err := cc.comments.SetResolved(c.Request.Context(), commentID, resolved, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
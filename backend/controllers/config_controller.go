@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/config"
+)
+
+type ConfigController struct{}
+
+func NewConfigController() *ConfigController {
+	return &ConfigController{}
+}
+
+func (cc *ConfigController) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, config.Current().Public())
+}
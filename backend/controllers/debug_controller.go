@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type DebugController struct {
+	llmCalls *services.LLMCallService
+	llmCache *services.LLMCacheService
+}
+
+func NewDebugController(database *sql.DB) *DebugController {
+	return &DebugController{
+		llmCalls: services.NewLLMCallService(database),
+		llmCache: services.NewLLMCacheService(database),
+	}
+}
+
+func (d *DebugController) ListLLMCalls(c *gin.Context) {
+	filter := models.LLMCallFilter{
+		ArticleID:  int64(parseOptionalInt(c.Query("articleId"), 0)),
+		Step:       strings.TrimSpace(c.Query("step")),
+		Model:      strings.TrimSpace(c.Query("model")),
+		Provider:   strings.TrimSpace(c.Query("provider")),
+		Status:     strings.TrimSpace(c.Query("status")),
+		ErrorClass: strings.TrimSpace(c.Query("errorClass")),
+		Search:     strings.TrimSpace(c.Query("q")),
+		Page:       parseOptionalInt(c.Query("page"), 1),
+		PageSize:   parseOptionalInt(c.Query("pageSize"), 25),
+	}
+
+	result, err := d.llmCalls.Search(c.Request.Context(), filter)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (d *DebugController) GetLLMCall(c *gin.Context) {
+	callID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	call, err := d.llmCalls.Get(c.Request.Context(), callID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, call)
+}
+
+func (d *DebugController) LLMCacheStats(c *gin.Context) {
+	stats, err := d.llmCache.Stats(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// PurgeLLMCache empties the completion cache, or with ?step= only that
+// step's entries; ?expired=true keeps entries that haven't expired.
+func (d *DebugController) PurgeLLMCache(c *gin.Context) {
+	expiredOnly, _ := strconv.ParseBool(c.Query("expired"))
+	result, err := d.llmCache.Purge(c.Request.Context(), c.Query("step"), expiredOnly)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type ExportController struct {
+	exports *services.ExportService
+}
+
+type exportBundleRequest struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Categories []string `json:"categories"`
+	Format     string   `json:"format"`
+}
+
+func NewExportController(database *sql.DB) *ExportController {
+	return &ExportController{
+		exports: services.NewExportService(database),
+	}
+}
+
+// ExportBundle answers with the bundle as a file download rather than JSON.
+func (e *ExportController) ExportBundle(c *gin.Context) {
+	var req exportBundleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	bundle, err := e.exports.Bundle(c.Request.Context(), req.From, req.To, req.Categories, req.Format)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, bundle.Filename))
+	c.Header("X-Analysis-Count", strconv.Itoa(bundle.Analyses))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, bundle.ContentType, bundle.Body)
+}
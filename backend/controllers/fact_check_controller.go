@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type FactCheckController struct {
+	jobs *services.JobService
+}
+
+func NewFactCheckController(database *sql.DB) *FactCheckController {
+	return &FactCheckController{
+		jobs: services.NewJobService(database),
+	}
+}
+
+// RunFactChecks queues a fresh lookup; matches appear on the analysis once the
+// job finishes.
+func (f *FactCheckController) RunFactChecks(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := services.EnqueueFactCheck(c.Request.Context(), f.jobs, articleID, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
+
+// RunGroundingCheck queues a fresh check of the article against the included
+// facts, for instance after the editor rewrites it.
+func (f *FactCheckController) RunGroundingCheck(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := services.EnqueueGroundingCheck(c.Request.Context(), f.jobs, articleID, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
+
+// RunSensitivityCheck queues a fresh automated legal read of the article.
+// Open automated flags are replaced once the job finishes.
+func (f *FactCheckController) RunSensitivityCheck(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := services.EnqueueSensitivityCheck(c.Request.Context(), f.jobs, articleID, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
+
+// LookUpEntities queues fresh Wikidata lookups of the names an analysis
+// mentions.
+func (f *FactCheckController) LookUpEntities(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := services.EnqueueEntityEnrichment(c.Request.Context(), f.jobs, articleID, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
+
+// ResearchGap queues a web search for answers to an open question; the
+// candidates appear on the gap, pending review, once the job finishes.
+func (f *FactCheckController) ResearchGap(c *gin.Context) {
+	gapID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := services.EnqueueGapResearch(c.Request.Context(), f.jobs, gapID, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
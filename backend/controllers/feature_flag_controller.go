@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type FeatureFlagController struct {
+	flags *services.FeatureFlagService
+}
+
+type updateFeatureFlagRequest struct {
+	Enabled        *bool `json:"enabled"`
+	RolloutPercent *int  `json:"rolloutPercent"`
+}
+
+func NewFeatureFlagController(database *sql.DB) *FeatureFlagController {
+	return &FeatureFlagController{
+		flags: services.NewFeatureFlagService(database),
+	}
+}
+
+func (fc *FeatureFlagController) ListFlags(c *gin.Context) {
+	flags, err := fc.flags.List(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": flags,
+	})
+}
+
+func (fc *FeatureFlagController) UpdateFlag(c *gin.Context) {
+	var req updateFeatureFlagRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	flag, err := fc.flags.Set(c.Request.Context(), c.Param("key"), req.Enabled, req.RolloutPercent, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// ResetFlag removes the runtime override of a flag.
+func (fc *FeatureFlagController) ResetFlag(c *gin.Context) {
+	flag, err := fc.flags.Reset(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type GlossaryController struct {
+	glossary *services.GlossaryService
+	jobs     *services.JobService
+}
+
+type createGlossaryTermRequest struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Notes    string `json:"notes"`
+}
+
+type updateGlossaryTermRequest struct {
+	Source *string `json:"source"`
+	Target *string `json:"target"`
+	Notes  *string `json:"notes"`
+}
+
+type updateStyleNotesRequest struct {
+	Language   string `json:"language"`
+	StyleNotes string `json:"styleNotes"`
+}
+
+type retranslateRequest struct {
+	Language string `json:"language"`
+}
+
+func NewGlossaryController(database *sql.DB) *GlossaryController {
+	return &GlossaryController{
+		glossary: services.NewGlossaryService(database),
+		jobs:     services.NewJobService(database),
+	}
+}
+
+func (g *GlossaryController) GetGlossary(c *gin.Context) {
+	glossary, err := g.glossary.GetGlossary(c.Request.Context(), c.Query("language"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, glossary)
+}
+
+func (g *GlossaryController) AddTerm(c *gin.Context) {
+	var req createGlossaryTermRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	glossary, err := g.glossary.AddTerm(c.Request.Context(), req.Language, req.Source, req.Target, req.Notes)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, glossary)
+}
+
+func (g *GlossaryController) UpdateTerm(c *gin.Context) {
+	termID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req updateGlossaryTermRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	glossary, err := g.glossary.UpdateTerm(c.Request.Context(), termID, req.Source, req.Target, req.Notes)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, glossary)
+}
+
+func (g *GlossaryController) DeleteTerm(c *gin.Context) {
+	termID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	glossary, err := g.glossary.DeleteTerm(c.Request.Context(), termID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, glossary)
+}
+
+func (g *GlossaryController) UpdateStyleNotes(c *gin.Context) {
+	var req updateStyleNotesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	glossary, err := g.glossary.UpdateStyleNotes(c.Request.Context(), req.Language, req.StyleNotes)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, glossary)
+}
+
+func (g *GlossaryController) Retranslate(c *gin.Context) {
+	var req retranslateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	job, err := services.EnqueueRetranslation(c.Request.Context(), g.jobs, req.Language, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/graphql"
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type GraphQLController struct {
+	graphql *services.GraphQLService
+}
+
+func NewGraphQLController(database *sql.DB) *GraphQLController {
+	return &GraphQLController{
+		graphql: services.NewGraphQLService(database),
+	}
+}
+
+// Query runs a GraphQL query. As GraphQL servers do, it answers 200 with
+// errors in the body once the request could be read; only a body that
+// isn't a GraphQL request is a 400.
+func (g *GraphQLController) Query(c *gin.Context) {
+	var request graphql.Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body", "code": models.ErrorCodeInvalidRequest})
+		return
+	}
+
+	viewer := services.GraphQLViewer{
+		UserID:     principalUserID(c),
+		Visibility: middleware.CurrentPrincipal(c).Visibility(),
+	}
+	c.JSON(http.StatusOK, g.graphql.Execute(c.Request.Context(), request, viewer))
+}
+
+func (g *GraphQLController) Schema(c *gin.Context) {
+	c.String(http.StatusOK, g.graphql.SDL())
+}
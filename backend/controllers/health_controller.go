@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/config"
+	"nanoheads/db"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type HealthController struct {
+	database  *sql.DB
+	providers *services.ProviderProbe
+}
+
+func NewHealthController(database *sql.DB) *HealthController {
+	return &HealthController{
+		database:  database,
+		providers: services.NewProviderProbe(database),
+	}
+}
+
+// Live answers as long as the process can serve HTTP. It checks nothing
+// else, so a database outage doesn't get every pod restarted.
+func (h *HealthController) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready answers 503 until the database is reachable and fully migrated, and,
+// with READY_CHECK_PROVIDERS, the selected AI provider answers.
+func (h *HealthController) Ready(c *gin.Context) {
+	ctx := c.Request.Context()
+	cfg := config.Current()
+	readiness := models.Readiness{Status: "ok", Checks: make(map[string]models.DependencyCheck)}
+
+	latency, err := db.Ping(ctx, h.database, cfg.DBPingTimeout.Duration)
+	database := models.DependencyCheck{
+		Status:    models.DependencyUp,
+		LatencyMs: latency.Milliseconds(),
+		Detail:    gin.H{"driver": db.Driver(), "pool": db.Stats(h.database)},
+	}
+	if err != nil {
+		database.Status = models.DependencyDown
+		database.Error = err.Error()
+	}
+	readiness.Checks["database"] = database
+
+	migrations := models.DependencyCheck{Status: models.DependencySkipped}
+	if database.Status == models.DependencyUp {
+		started := time.Now()
+		pending, err := db.PendingMigrations(ctx, h.database, db.Driver())
+		migrations.LatencyMs = time.Since(started).Milliseconds()
+		switch {
+		case err != nil:
+			migrations.Status = models.DependencyDown
+			migrations.Error = err.Error()
+		case len(pending) > 0:
+			names := make([]string, len(pending))
+			for idx, migration := range pending {
+				names[idx] = fmt.Sprintf("%04d_%s", migration.Version, migration.Name)
+			}
+			migrations.Status = models.DependencyDown
+			migrations.Error = fmt.Sprintf("%d migration(s) pending", len(pending))
+			migrations.Detail = gin.H{"pending": names}
+		default:
+			migrations.Status = models.DependencyUp
+		}
+	}
+	readiness.Checks["migrations"] = migrations
+
+	providers := models.DependencyCheck{Status: models.DependencySkipped}
+	if cfg.ReadyCheckProviders {
+		providers = h.providers.Check(ctx)
+	}
+	readiness.Checks["providers"] = providers
+
+	for _, check := range readiness.Checks {
+		if check.Status == models.DependencyDown {
+			readiness.Status = "unavailable"
+		}
+	}
+	status := http.StatusOK
+	if readiness.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type ImportController struct {
+	jobs *services.JobService
+}
+
+type importArticlesRequest struct {
+	URL      string `json:"url"`
+	Since    string `json:"since"`
+	Limit    int    `json:"limit"`
+	Language string `json:"language"`
+	Category string `json:"category"`
+}
+
+func NewImportController(database *sql.DB) *ImportController {
+	return &ImportController{
+		jobs: services.NewJobService(database),
+	}
+}
+
+// ImportWordPress queues the published posts of a WordPress site, read from
+// its REST API, for back-analysis.
+func (i *ImportController) ImportWordPress(c *gin.Context) {
+	i.enqueue(c, models.ImportSourceWordPress)
+}
+
+// ImportRSS queues the items of an RSS or Atom feed for back-analysis.
+func (i *ImportController) ImportRSS(c *gin.Context) {
+	i.enqueue(c, models.ImportSourceRSS)
+}
+
+func (i *ImportController) enqueue(c *gin.Context, source string) {
+	var req importArticlesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	request := models.ArticleImport{
+		Source:   source,
+		URL:      req.URL,
+		Since:    req.Since,
+		Limit:    req.Limit,
+		Language: req.Language,
+		Category: req.Category,
+	}
+	request.Submission = newSubmission(c, models.SubmissionChannelImport, map[string]any{
+		"url":      req.URL,
+		"since":    req.Since,
+		"language": req.Language,
+		"category": req.Category,
+	})
+
+	job, err := services.EnqueueImport(c.Request.Context(), i.jobs, request, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, visibleJob(c, job))
+}
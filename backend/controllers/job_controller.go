@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type JobController struct {
+	jobs *services.JobService
+}
+
+func NewJobController(database *sql.DB) *JobController {
+	return &JobController{
+		jobs: services.NewJobService(database),
+	}
+}
+
+func (j *JobController) ListJobs(c *gin.Context) {
+	limit := parseOptionalInt(c.Query("limit"), 20)
+
+	jobs, err := j.jobs.List(c.Request.Context(), c.Query("type"), limit)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	for idx := range jobs {
+		jobs[idx] = visibleJob(c, jobs[idx])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": jobs,
+	})
+}
+
+func (j *JobController) GetJob(c *gin.Context) {
+	jobID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := j.jobs.Get(c.Request.Context(), jobID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, visibleJob(c, job))
+}
+
+// principalUserID is the user to record as a job's creator, if any.
+func principalUserID(c *gin.Context) *int64 {
+	if principal := middleware.CurrentPrincipal(c); principal.UserID > 0 {
+		return &principal.UserID
+	}
+	return nil
+}
+
+// visibleJob drops who queued the job, submission details carried in the
+// payload and the raw failure message for callers without diagnostics access.
+func visibleJob(c *gin.Context, job models.Job) models.Job {
+	if middleware.HasPermission(c, models.PermissionViewDiagnostics) {
+		return job
+	}
+
+	job.CreatedBy = nil
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(job.Payload, &payload); err == nil {
+		if _, ok := payload["submission"]; ok {
+			delete(payload, "submission")
+			if encoded, err := json.Marshal(payload); err == nil {
+				job.Payload = encoded
+			}
+		}
+	}
+	if job.Error != "" {
+		job.Error = "job failed"
+	}
+	return job
+}
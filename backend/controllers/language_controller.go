@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type LanguageController struct {
+	languages *services.LanguageService
+}
+
+func NewLanguageController(database *sql.DB) *LanguageController {
+	return &LanguageController{
+		languages: services.NewLanguageService(database),
+	}
+}
+
+func (l *LanguageController) ListLanguages(c *gin.Context) {
+	items, err := l.languages.List(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type ModelController struct {
+	models       *services.ModelService
+	providerKeys *services.ProviderKeyService
+}
+
+type createModelRequest struct {
+	Key                   string   `json:"key"`
+	Name                  string   `json:"name"`
+	MaxTokens             *int     `json:"maxTokens"`
+	InputPricePerMillion  *float64 `json:"inputPricePerMillion"`
+	OutputPricePerMillion *float64 `json:"outputPricePerMillion"`
+}
+
+type updateModelRequest struct {
+	Name                  *string  `json:"name"`
+	Enabled               *bool    `json:"enabled"`
+	MaxTokens             *int     `json:"maxTokens"`
+	InputPricePerMillion  *float64 `json:"inputPricePerMillion"`
+	OutputPricePerMillion *float64 `json:"outputPricePerMillion"`
+}
+
+// providerKeyRequest carries a provider API key. It is optional when testing,
+// which then checks the key in use.
+type providerKeyRequest struct {
+	APIKey string `json:"apiKey"`
+}
+
+func NewModelController(database *sql.DB) *ModelController {
+	return &ModelController{
+		models:       services.NewModelService(database),
+		providerKeys: services.NewProviderKeyService(database),
+	}
+}
+
+func (m *ModelController) ListProviders(c *gin.Context) {
+	providers, err := m.models.ListProviders(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": providers,
+	})
+}
+
+// ProviderHealth reports each model provider's circuit breaker.
+func (m *ModelController) ProviderHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"items": services.ProviderHealth(),
+	})
+}
+
+func (m *ModelController) AddModel(c *gin.Context) {
+	var req createModelRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	model, err := m.models.AddModel(
+		c.Request.Context(),
+		c.Param("provider"),
+		req.Key,
+		req.Name,
+		req.MaxTokens,
+		req.InputPricePerMillion,
+		req.OutputPricePerMillion,
+	)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, model)
+}
+
+func (m *ModelController) UpdateModel(c *gin.Context) {
+	modelID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req updateModelRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	model, err := m.models.UpdateModel(
+		c.Request.Context(),
+		modelID,
+		req.Name,
+		req.Enabled,
+		req.MaxTokens,
+		req.InputPricePerMillion,
+		req.OutputPricePerMillion,
+	)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model)
+}
+
+func (m *ModelController) SetDefaultModel(c *gin.Context) {
+	modelID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	model, err := m.models.SetDefault(c.Request.Context(), modelID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model)
+}
+
+func (m *ModelController) ListProviderKeys(c *gin.Context) {
+	keys, err := m.providerKeys.ListKeys(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": keys,
+	})
+}
+
+func (m *ModelController) SetProviderKey(c *gin.Context) {
+	var req providerKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	status, err := m.providerKeys.SetKey(c.Request.Context(), c.Param("provider"), req.APIKey, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (m *ModelController) DeleteProviderKey(c *gin.Context) {
+	if err := m.providerKeys.DeleteKey(c.Request.Context(), c.Param("provider")); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// TestProviderKey tries a key against the provider without saving it.
+func (m *ModelController) TestProviderKey(c *gin.Context) {
+	var req providerKeyRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := m.providerKeys.TestKey(c.Request.Context(), c.Param("provider"), req.APIKey)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type NotificationController struct {
+	notifications *services.NotificationService
+	digests       *services.DigestService
+}
+
+type digestSubscriptionRequest struct {
+	Frequency string `json:"frequency"`
+}
+
+func NewNotificationController(database *sql.DB) *NotificationController {
+	return &NotificationController{
+		notifications: services.NewNotificationService(database),
+		digests:       services.NewDigestService(database),
+	}
+}
+
+// ListNotifications returns the caller's notifications; ?unread=true leaves
+// out the ones already read.
+func (n *NotificationController) ListNotifications(c *gin.Context) {
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+	limit := parseOptionalInt(c.Query("limit"), 0)
+
+	list, err := n.notifications.List(c.Request.Context(), principalUserID(c), unreadOnly, limit)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (n *NotificationController) MarkRead(c *gin.Context) {
+	notificationID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := n.notifications.MarkRead(c.Request.Context(), principalUserID(c), notificationID); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (n *NotificationController) MarkAllRead(c *gin.Context) {
+	marked, err := n.notifications.MarkAllRead(c.Request.Context(), principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked": marked})
+}
+
+func (n *NotificationController) GetDigestSubscription(c *gin.Context) {
+	subscription, err := n.digests.Get(c.Request.Context(), principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// SubscribeDigest starts the caller's daily or weekly digest, or switches
+// between them.
+func (n *NotificationController) SubscribeDigest(c *gin.Context) {
+	var req digestSubscriptionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	subscription, err := n.digests.Subscribe(c.Request.Context(), principalUserID(c), req.Frequency)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+func (n *NotificationController) UnsubscribeDigest(c *gin.Context) {
+	if err := n.digests.Unsubscribe(c.Request.Context(), principalUserID(c)); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
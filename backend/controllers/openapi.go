@@ -0,0 +1,401 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Operation documents a handler for the OpenAPI spec. Body and Response are
+// zero values of the request and response types, and their schemas are read
+// from the json tags, so the spec follows the types as they change.
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	// Permission is the permission the route requires beyond an API key.
+	Permission string
+	Public     bool
+	Query      []QueryParam
+	Body       any
+	// Form marks bodies that may also be sent as multipart/form-data.
+	Form     bool
+	Status   int
+	Response any
+	// ResponseType is set for responses that aren't JSON, such as images.
+	ResponseType string
+}
+
+type QueryParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+type OpenAPIController struct {
+	routes func() gin.RoutesInfo
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// NewOpenAPIController serves a spec of the routes returned by routes. They
+// are read on the first request, once every route is registered.
+func NewOpenAPIController(routes func() gin.RoutesInfo) *OpenAPIController {
+	return &OpenAPIController{routes: routes}
+}
+
+func (o *OpenAPIController) Spec(c *gin.Context) {
+	o.once.Do(func() {
+		o.spec, o.err = json.Marshal(buildOpenAPISpec(o.routes(), operations, webhookOperations))
+	})
+	if o.err != nil {
+		respondInternalError(c, o.err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", o.spec)
+}
+
+func (o *OpenAPIController) SwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>NanoHeads API</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+	<script>
+		window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui", persistAuthorization: true });
+	</script>
+</body>
+</html>
+`
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+type versionResponse struct {
+	Status  string `json:"status"`
+	Version int64  `json:"version"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	// Code is stable for clients to match on, e.g. ERR_EMPTY_FACTS; the
+	// codes are listed in models/errors.go.
+	Code string `json:"code"`
+	// ErrorID is set on 500s and matches the server's log line.
+	ErrorID string `json:"errorId,omitempty"`
+}
+
+// items describes the {"items": [...]} body list endpoints return.
+func items(element any) any {
+	field := reflect.StructField{
+		Name: "Items",
+		Type: reflect.SliceOf(reflect.TypeOf(element)),
+		Tag:  `json:"items"`,
+	}
+	return reflect.New(reflect.StructOf([]reflect.StructField{field})).Elem().Interface()
+}
+
+func buildOpenAPISpec(routes gin.RoutesInfo, docs map[string]Operation, events map[string]Operation) map[string]any {
+	schemas := &schemaBuilder{components: map[string]any{}, names: map[reflect.Type]string{}}
+	errorSchema := schemas.schema(reflect.TypeOf(errorResponse{}))
+	validationSchema := schemas.schema(reflect.TypeOf(validationResponse{}))
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	paths := map[string]map[string]any{}
+	for _, route := range routes {
+		doc, documented := docs[route.Method+" "+route.Path]
+		if !documented && strings.HasPrefix(route.Path, "/api/openapi") {
+			continue
+		}
+
+		specPath, params := openAPIPath(route.Path)
+		for _, query := range doc.Query {
+			param := map[string]any{
+				"name":   query.Name,
+				"in":     "query",
+				"schema": map[string]any{"type": query.Type},
+			}
+			if query.Description != "" {
+				param["description"] = query.Description
+			}
+			params = append(params, param)
+		}
+
+		tag := doc.Tag
+		if tag == "" {
+			tag = routeTag(route.Path)
+		}
+		description := doc.Description
+		if doc.Permission != "" {
+			description = strings.TrimSpace(description + "\n\nRequires the `" + doc.Permission + "` permission.")
+		}
+
+		status := doc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		switch {
+		case doc.ResponseType != "":
+			success["content"] = map[string]any{doc.ResponseType: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}
+		case doc.Response != nil:
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(doc.Response))}}
+		}
+
+		responses := map[string]any{
+			strconv.Itoa(status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorSchema}},
+			},
+		}
+		operation := map[string]any{
+			"tags":        []string{tag},
+			"operationId": operationID(route.Handler),
+			"responses":   responses,
+		}
+		if doc.Summary != "" {
+			operation["summary"] = doc.Summary
+		}
+		if description != "" {
+			operation["description"] = description
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if doc.Body != nil {
+			body := schemas.schema(reflect.TypeOf(doc.Body))
+			content := map[string]any{"application/json": map[string]any{"schema": body}}
+			if doc.Form {
+				content["multipart/form-data"] = map[string]any{"schema": body}
+			}
+			operation["requestBody"] = map[string]any{"required": true, "content": content}
+			responses[strconv.Itoa(http.StatusUnprocessableEntity)] = map[string]any{
+				"description": "The body is invalid, with fields listing each problem; or, with code ERR_URL_UNREACHABLE, ERR_URL_NO_TEXT or ERR_EMPTY_FACTS and no fields, its input gave nothing to analyse",
+				"content":     map[string]any{"application/json": map[string]any{"schema": validationSchema}},
+			}
+		}
+		if doc.Public || !strings.HasPrefix(route.Path, "/api/") {
+			operation["security"] = []any{}
+		}
+
+		if paths[specPath] == nil {
+			paths[specPath] = map[string]any{}
+		}
+		paths[specPath][strings.ToLower(route.Method)] = operation
+	}
+
+	// OpenAPI 3.0 has no webhooks object; x-webhooks is the extension
+	// renderers read instead.
+	webhooks := map[string]any{}
+	for event, doc := range events {
+		operation := map[string]any{
+			"tags":        []string{"webhooks"},
+			"operationId": event,
+			"requestBody": map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(doc.Body))}},
+			},
+			"responses": map[string]any{"2XX": map[string]any{"description": "Any 2xx status acknowledges the delivery"}},
+		}
+		if doc.Summary != "" {
+			operation["summary"] = doc.Summary
+		}
+		if doc.Description != "" {
+			operation["description"] = doc.Description
+		}
+		webhooks[event] = map[string]any{"post": operation}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "NanoHeads API",
+			"version":     "1.0.0",
+			"description": "Fact extraction, article generation and editorial review. Authenticate with an API key in the X-API-Key header or as a bearer token.",
+		},
+		"paths":      paths,
+		"x-webhooks": webhooks,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{
+			map[string]any{"apiKey": []string{}},
+			map[string]any{"bearer": []string{}},
+		},
+	}
+}
+
+// openAPIPath turns gin's :id and *rest segments into {id} and {rest}.
+func openAPIPath(route string) (string, []any) {
+	segments := strings.Split(route, "/")
+	params := make([]any, 0)
+	for idx, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		segments[idx] = "{" + name + "}"
+		kind := "string"
+		if name == "id" || strings.HasSuffix(name, "Id") {
+			kind = "integer"
+		}
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": kind},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func routeTag(route string) string {
+	segments := strings.Split(strings.TrimPrefix(strings.TrimPrefix(route, "/api"), "/"), "/")
+	return segments[0]
+}
+
+// operationID takes the handler's method name, as in AdminController.GetAnalysis.
+func operationID(handler string) string {
+	name := strings.TrimSuffix(path.Ext(handler), "-fm")
+	name = strings.TrimPrefix(name, ".")
+	if base := path.Base(handler); strings.Contains(base, ".(*") {
+		controller := strings.SplitN(strings.SplitN(base, ".(*", 2)[1], ")", 2)[0]
+		return controller + "." + name
+	}
+	return name
+}
+
+type schemaBuilder struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		inner := b.schema(t.Elem())
+		if _, ref := inner["$ref"]; ref {
+			return inner
+		}
+		inner["nullable"] = true
+		return inner
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = b.componentName(t)
+			b.names[t] = name
+			// Registered before the walk so self-referencing types terminate.
+			b.components[name] = map[string]any{}
+			b.components[name] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	b.addFields(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]any) {
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			b.addFields(field.Type, properties)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+	}
+}
+
+// componentName capitalises the type's name, falling back to a package
+// prefix when two packages use the same one.
+func (b *schemaBuilder) componentName(t reflect.Type) string {
+	runes := []rune(t.Name())
+	runes[0] = unicode.ToUpper(runes[0])
+	name := string(runes)
+	if _, taken := b.components[name]; !taken {
+		return name
+	}
+	pkg := []rune(path.Base(t.PkgPath()))
+	pkg[0] = unicode.ToUpper(pkg[0])
+	return string(pkg) + name
+}
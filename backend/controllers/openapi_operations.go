@@ -0,0 +1,621 @@
+package controllers
+
+import (
+	"net/http"
+
+	"nanoheads/config"
+	"nanoheads/graphql"
+	"nanoheads/models"
+)
+
+type idResponse struct {
+	ID int64 `json:"id"`
+}
+
+type updatedResponse struct {
+	Updated int `json:"updated"`
+}
+
+type markedResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+type apiKeyResponse struct {
+	APIKey string `json:"apiKey"`
+}
+
+type telegramLinksResponse struct {
+	Items []models.TelegramLink `json:"items"`
+}
+
+type factTagsResponse struct {
+	Tags []string `json:"tags"`
+}
+
+type rolesResponse struct {
+	Items       []models.Role `json:"items"`
+	Permissions []string      `json:"permissions"`
+}
+
+var (
+	limitParam = QueryParam{Name: "limit", Type: "integer", Description: "Maximum number of items to return."}
+	daysParam  = QueryParam{Name: "days", Type: "integer", Description: "Only include the last N days."}
+)
+
+const versionedEdit = "Send the version the edit is based on, in the If-Match header or as version. " +
+	"Without one the edit is refused with 428; if the record has changed since, it is refused with 409 and currentVersion."
+
+const revertedEdit = "The last 20 edits can be undone, newest first, and redone until the record is edited again. " +
+	"Answers 409 when there is nothing to undo or redo, or when the record was changed some other way since."
+
+// operations documents the routes by method and gin path. Routes missing
+// here still appear in the spec, with their path parameters only.
+var operations = map[string]Operation{
+	"GET /livez": {Summary: "Liveness probe", Description: "Answers 200 while the process serves HTTP; it checks no dependencies.", Tag: "health", Public: true, Response: statusResponse{}},
+	"GET /readyz": {
+		Summary:     "Readiness probe",
+		Description: "Checks the database, pending migrations and, with READY_CHECK_PROVIDERS, the selected AI provider. Answers 503 while any check is down; each check reports up, down or skipped.",
+		Tag:         "health",
+		Public:      true,
+		Response:    models.Readiness{},
+	},
+
+	"POST /api/analyse": {
+		Summary:     "Analyse a story",
+		Description: "Extracts facts and open questions from text, a URL, several sources or images, and writes an article with headline and strapline options. Form uploads send images in image or images file fields.",
+		Tag:         "analyses",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        analyseRequest{},
+		Form:        true,
+		Response:    models.PhaseOneResponse{},
+	},
+	"POST /api/clip": {
+		Summary:     "Queue a clipped page for analysis",
+		Description: "Accepts JSON, form bodies or a plain-text selection, as sent by bookmarklets and browser extensions.",
+		Tag:         "analyses",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        models.Clip{},
+		Status:      http.StatusAccepted,
+		Response:    models.Job{},
+	},
+	"POST /api/import/wordpress": {
+		Summary:     "Import a WordPress site's published posts",
+		Description: "Reads posts from the site's REST API, newest first, and analyses each one in a background job. The analyses are marked imported; posts already analysed from the same URL are skipped.",
+		Tag:         "analyses",
+		Permission:  models.PermissionManageAnalyses,
+		Body:        importArticlesRequest{},
+		Status:      http.StatusAccepted,
+		Response:    models.Job{},
+	},
+	"POST /api/import/rss": {
+		Summary:     "Import the items of an RSS or Atom feed",
+		Description: "Analyses each item in a background job, from the feed's full content when it carries it and from the linked page otherwise. The analyses are marked imported; items already analysed from the same URL are skipped.",
+		Tag:         "analyses",
+		Permission:  models.PermissionManageAnalyses,
+		Body:        importArticlesRequest{},
+		Status:      http.StatusAccepted,
+		Response:    models.Job{},
+	},
+	"GET /api/dashboard": {
+		Summary: "Dashboard counts and recent analyses",
+		Tag:     "dashboard",
+		Query: []QueryParam{
+			limitParam,
+			{Name: "bucket", Type: "string", Description: "Bucket analytics by day or week."},
+			daysParam,
+		},
+		Response: models.DashboardResponse{},
+	},
+	"GET /api/analyses": {
+		Summary: "List analyses",
+		Tag:     "analyses",
+		Query: []QueryParam{
+			limitParam,
+			{Name: "viewId", Type: "integer", Description: "Start from a saved view's filters."},
+			{Name: "status", Type: "string"},
+			{Name: "category", Type: "string"},
+			{Name: "assignedTo", Type: "string", Description: "A user id, me, or none."},
+			{Name: "createdBy", Type: "string", Description: "A user id or me."},
+			daysParam,
+		},
+		Response: items(models.AnalysisListItem{}),
+	},
+	"GET /api/analyses/:id/snapshots/:snapshotId": {
+		Summary:      "Download a source page as fetched",
+		Description:  "Served as plain text. X-Content-SHA256 is the hash recorded at fetch time, which the body is checked against.",
+		Tag:          "analyses",
+		Permission:   models.PermissionViewSource,
+		ResponseType: "text/plain",
+	},
+	"GET /api/analyses/updated-since": {
+		Summary:     "Poll for changed analyses",
+		Description: "For polling triggers in tools such as Zapier and Make. Lists the analyses changed after the cursor, oldest first, and returns the cursor to send on the next poll; without one it starts with the latest changes. Item ids are unique per change, for deduplication.",
+		Tag:         "analyses",
+		Query: []QueryParam{
+			{Name: "cursor", Type: "string", Description: "The cursor of the previous response."},
+			limitParam,
+		},
+		Response: models.AnalysisChanges{},
+	},
+	"GET /api/analyses/compare": {
+		Summary:     "Compare two analyses of a story",
+		Description: "Matches the included facts of the two analyses as shared, contradictory (the same claim with different figures) or found in only one, and diffs their article texts a sentence at a time. Both must be in the same output language.",
+		Tag:         "analyses",
+		Query:       []QueryParam{{Name: "ids", Type: "string", Description: "Two analysis ids, comma-separated."}},
+		Response:    models.AnalysisComparison{},
+	},
+	"GET /api/analyses/:id": {
+		Summary:  "Get an analysis",
+		Tag:      "analyses",
+		Query:    []QueryParam{{Name: "factTags", Type: "string", Description: "Comma-separated tags; only facts with any of them are returned. factTags in the response still lists every tag in use."}},
+		Response: models.AnalysisDetail{},
+	},
+	"GET /api/analyses/:id/images/:imageId": {Summary: "Download a source image", Tag: "analyses", Permission: models.PermissionViewSource, ResponseType: "image/*"},
+	"POST /api/analyses/merge":              {Summary: "Merge analyses of the same story", Tag: "analyses", Permission: models.PermissionManageAnalyses, Body: mergeAnalysesRequest{}, Response: models.PhaseOneResponse{}},
+	"PATCH /api/analyses/bulk":              {Summary: "Update the status or category of several analyses", Tag: "analyses", Permission: models.PermissionManageAnalyses, Body: bulkUpdateAnalysesRequest{}, Response: updatedResponse{}},
+	"GET /api/retention/preview": {
+		Summary:     "Preview the next draft retention run",
+		Description: "Lists, oldest first, up to 200 of the drafts last changed more than RETENTION_DAYS ago, which the daily run archives (scrubbing their source text, images and snapshots) or deletes, per RETENTION_ACTION. total counts them all.",
+		Tag:         "analyses",
+		Permission:  models.PermissionManageAnalyses,
+		Response:    models.RetentionPreview{},
+	},
+	"GET /api/privacy/export": {
+		Summary:     "Export the data held about a source or person",
+		Description: "Returns every stored row of the analyses that used sourceUrl, as their source or a corroborating one, or that mention entity (a name, Wikidata label or Wikidata id): the article, its sources, facts, gaps, snapshots and images (as rows, not files), comments, history and LLM calls. Give exactly one of the two.",
+		Tag:         "privacy",
+		Permission:  models.PermissionManagePrivacy,
+		Query: []QueryParam{
+			{Name: "sourceUrl", Type: "string", Description: "Exact source URL to export analyses of."},
+			{Name: "entity", Type: "string", Description: "Person or other entity to export analyses mentioning."},
+		},
+		Response: models.PrivacyExport{},
+	},
+	"POST /api/privacy/purge": {
+		Summary:     "Irreversibly purge analyses",
+		Description: "Deletes the analyses with everything derived from them, including snapshots, images, facts, LLM calls, jobs and translation memory entries, retitles the story threads they were in (deleting those left empty), removes stored files no other analysis uses and empties the LLM cache. Needs a signed-in user and a reason; the purge is recorded without any of the purged content.",
+		Tag:         "privacy",
+		Permission:  models.PermissionManagePrivacy,
+		Body:        purgeAnalysesRequest{},
+		Response:    models.PrivacyPurge{},
+	},
+	"GET /api/privacy/purges":           {Summary: "List recent privacy purges", Tag: "privacy", Permission: models.PermissionManagePrivacy, Response: items(models.PrivacyPurge{})},
+	"PUT /api/analyses/:id/assignee":    {Summary: "Assign an analysis for review", Tag: "analyses", Permission: models.PermissionEditAnalyses, Body: assignAnalysisRequest{}, Response: models.AnalysisDetail{}},
+	"DELETE /api/analyses/:id/assignee": {Summary: "Unassign an analysis", Tag: "analyses", Permission: models.PermissionEditAnalyses, Response: models.AnalysisDetail{}},
+	"POST /api/analyses/:id/approval": {
+		Summary:     "Approve an analysis for publishing",
+		Description: "Approves the version given in If-Match or the body, which must be the current one. With REQUIRE_APPROVAL, completing an analysis needs an approval of its current version by someone other than the publisher.",
+		Tag:         "analyses",
+		Permission:  models.PermissionApprove,
+		Body:        approveAnalysisRequest{},
+		Response:    models.AnalysisDetail{},
+	},
+	"DELETE /api/analyses/:id/approval": {Summary: "Withdraw your approval of an analysis", Tag: "analyses", Permission: models.PermissionApprove, Response: models.AnalysisDetail{}},
+	"POST /api/analyses/:id/flags": {
+		Summary:     "Flag an analysis as legally sensitive",
+		Description: "kind is defamation, ongoing_trial, privacy or other. An analysis with an open flag can't be completed until someone with clear_flags clears it.",
+		Tag:         "analyses",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        flagAnalysisRequest{},
+		Status:      http.StatusCreated,
+		Response:    models.AnalysisFlag{},
+	},
+	"POST /api/analyses/:id/flags/check": {
+		Summary:     "Queue the automated legal check of the article",
+		Description: "Replaces the open automated flags when the job finishes. Risks already cleared are not flagged again while their excerpt is unchanged.",
+		Tag:         "analyses",
+		Permission:  models.PermissionEditAnalyses,
+		Status:      http.StatusAccepted,
+		Response:    models.Job{},
+	},
+	"POST /api/flags/:id/clear": {
+		Summary:     "Clear a legal flag",
+		Description: "Needs a signed-in user and a note saying why the analysis may go out; both stay on the flag.",
+		Tag:         "analyses",
+		Permission:  models.PermissionClearFlags,
+		Body:        clearFlagRequest{},
+		Response:    models.AnalysisFlag{},
+	},
+	"POST /api/analyses/:id/corrections": {
+		Summary:     "Correct a published analysis",
+		Description: "Records what was wrong and the corrected text against a completed analysis. The article itself is not changed; corrections are printed, oldest first, under it in the JSON Feed and export bundles.",
+		Tag:         "analyses",
+		Permission:  models.PermissionPublish,
+		Body:        addCorrectionRequest{},
+		Status:      http.StatusCreated,
+		Response:    models.AnalysisCorrection{},
+	},
+	"POST /api/analyses/:id/category/accept": {Summary: "Accept the suggested category", Tag: "analyses", Permission: models.PermissionEditAnalyses, Response: models.AnalysisDetail{}},
+	"POST /api/analyses/:id/facts":           {Summary: "Add a fact", Tag: "facts", Permission: models.PermissionEditAnalyses, Body: addFactRequest{}, Status: http.StatusCreated, Response: idResponse{}},
+	"PATCH /api/analyses/:id/facts/order":    {Summary: "Reorder facts", Tag: "facts", Permission: models.PermissionEditAnalyses, Body: reorderFactsRequest{}, Response: models.AnalysisDetail{}},
+	"POST /api/analyses/:id/fact-checks":     {Summary: "Queue fact checks", Tag: "analyses", Permission: models.PermissionEditAnalyses, Status: http.StatusAccepted, Response: models.Job{}},
+	"POST /api/analyses/:id/grounding":       {Summary: "Queue a grounding check of the article", Tag: "analyses", Permission: models.PermissionEditAnalyses, Status: http.StatusAccepted, Response: models.Job{}},
+	"POST /api/analyses/:id/entities":        {Summary: "Queue Wikidata lookups of the names an analysis mentions", Tag: "analyses", Permission: models.PermissionEditAnalyses, Status: http.StatusAccepted, Response: models.Job{}},
+	"POST /api/analyses/:id/simplify":        {Summary: "Rewrite the article for a reading level", Tag: "analyses", Permission: models.PermissionEditAnalyses, Body: simplifyArticleRequest{}, Response: models.SimplifyResult{}},
+	"POST /api/analyses/:id/retry":           {Summary: "Retry a failed analysis from the step it failed at", Tag: "analyses", Permission: models.PermissionViewDiagnostics, Response: models.PhaseOneResponse{}},
+	"POST /api/analyses/:id/share": {
+		Summary:     "Create a review link",
+		Description: "The link shows the draft article, its facts and gaps read-only to anyone who has it, until expiresInHours (72 by default, at most 720) pass or it is revoked. url is only in this response, on PUBLIC_API_URL (just the path without it).",
+		Tag:         "analyses",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        createShareRequest{},
+		Status:      http.StatusCreated,
+		Response:    models.AnalysisShare{},
+	},
+	"GET /api/analyses/:id/shares":          {Summary: "List an analysis's review links", Tag: "analyses", Response: items(models.AnalysisShare{})},
+	"DELETE /api/shares/:id":                {Summary: "Revoke a review link", Tag: "analyses", Permission: models.PermissionEditAnalyses, Response: models.AnalysisShare{}},
+	"DELETE /api/facts/:id":                 {Summary: "Move a fact to the trash", Tag: "facts", Permission: models.PermissionEditAnalyses, Response: statusResponse{}},
+	"POST /api/facts/:id/restore":           {Summary: "Restore a fact from the trash", Tag: "facts", Permission: models.PermissionEditAnalyses, Response: models.AnalysisFact{}},
+	"POST /api/facts/:id/undo":              {Summary: "Undo the last edit of a fact", Description: revertedEdit, Tag: "facts", Permission: models.PermissionEditAnalyses, Response: versionResponse{}},
+	"POST /api/facts/:id/redo":              {Summary: "Redo the last undone edit of a fact", Description: revertedEdit, Tag: "facts", Permission: models.PermissionEditAnalyses, Response: versionResponse{}},
+	"POST /api/gaps/:id/undo":               {Summary: "Undo the last edit of an open question", Description: revertedEdit, Tag: "gaps", Permission: models.PermissionEditAnalyses, Response: versionResponse{}},
+	"POST /api/gaps/:id/redo":               {Summary: "Redo the last undone edit of an open question", Description: revertedEdit, Tag: "gaps", Permission: models.PermissionEditAnalyses, Response: versionResponse{}},
+	"POST /api/gaps/:id/research":           {Summary: "Queue a web search for answers to an open question", Tag: "gaps", Permission: models.PermissionEditAnalyses, Status: http.StatusAccepted, Response: models.Job{}},
+	"PATCH /api/gaps/:id/answers/:answerId": {Summary: "Accept or reject a researched answer", Tag: "gaps", Permission: models.PermissionEditAnalyses, Body: reviewGapAnswerRequest{}, Response: statusResponse{}},
+	"GET /api/categories":                   {Summary: "List categories", Tag: "categories", Response: items("")},
+	"GET /api/languages":                    {Summary: "List output languages", Tag: "languages", Response: items(models.Language{})},
+	"GET /api/config":                       {Summary: "Get the public configuration", Tag: "config", Response: config.PublicConfig{}},
+	"GET /api/settings":                     {Summary: "Get the AI provider settings", Tag: "settings", Response: models.SettingsResponse{}},
+	"PUT /api/settings":                     {Summary: "Change the default AI provider and model", Tag: "settings", Permission: models.PermissionManageProviders, Body: updateSettingsRequest{}, Response: models.SettingsResponse{}},
+	"POST /api/settings/test": {
+		Summary:     "Test a provider and model with a tiny completion",
+		Description: "Tests the selected model, or provider and model from the body before switching to them. Failures are reported in the result with errorClass, not as an error status.",
+		Tag:         "settings",
+		Permission:  models.PermissionManageProviders,
+		Body:        updateSettingsRequest{},
+		Response:    models.SettingsTest{},
+	},
+
+	"GET /api/analyses/:id/live": {
+		Summary: "Follow who has an analysis open and what they change",
+		Description: "A WebSocket of presence and edit events. Send {\"state\": \"viewing\" | \"editing\", \"target\": \"fact:12\"} as the user's focus changes. " +
+			"Browsers, which can't set headers on the handshake, offer the subprotocols nanoheads and apikey.<key> instead.",
+		Tag:      "analyses",
+		Status:   http.StatusSwitchingProtocols,
+		Response: models.CollaborationEvent{},
+	},
+	"PATCH /api/analyses/:id": {
+		Summary:     "Edit an analysis",
+		Description: versionedEdit + " With REQUIRE_APPROVAL, completing needs an approval of the current version by someone else, and nothing else may change in the same edit. Analyses with open legal flags can't be completed.",
+		Tag:         "analyses",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        updateAnalysisRequest{},
+		Response:    models.AnalysisDetail{},
+	},
+	"PATCH /api/facts/:id": {
+		Summary:     "Edit a fact",
+		Description: versionedEdit,
+		Tag:         "facts",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        updateFactRequest{},
+		Response:    versionResponse{},
+	},
+	"PUT /api/facts/:id/tags": {
+		Summary:     "Set the tags on a fact",
+		Description: "Replaces the fact's tags, such as statistic, quote or official statement; [] clears them. Tags are lowercased, at most 6 per fact and 40 characters each. Extraction tags new facts itself.",
+		Tag:         "facts",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        factTagsRequest{},
+		Response:    factTagsResponse{},
+	},
+	"PATCH /api/gaps/:id": {
+		Summary:     "Edit an open question",
+		Description: versionedEdit,
+		Tag:         "gaps",
+		Permission:  models.PermissionEditAnalyses,
+		Body:        updateGapRequest{},
+		Response:    versionResponse{},
+	},
+
+	"GET /api/analyses/:id/facts": {
+		Summary: "List the facts of an analysis",
+		Tag:     "facts",
+		Query: []QueryParam{
+			{Name: "includeDeleted", Type: "boolean", Description: "Include facts in the trash, which carry deletedAt."},
+			{Name: "factTags", Type: "string", Description: "Comma-separated tags; only facts with any of them are returned."},
+		},
+		Response: items(models.AnalysisFact{}),
+	},
+	"GET /api/analyses/:id/comments": {
+		Summary:  "List comments on an analysis",
+		Tag:      "comments",
+		Query:    []QueryParam{{Name: "status", Type: "string", Description: "open or resolved."}},
+		Response: items(models.Comment{}),
+	},
+	"POST /api/analyses/:id/comments": {Summary: "Comment on an analysis", Tag: "comments", Permission: models.PermissionComment, Body: createCommentRequest{}, Status: http.StatusCreated, Response: models.Comment{}},
+	"POST /api/comments/:id/resolve":  {Summary: "Resolve a comment", Tag: "comments", Permission: models.PermissionComment, Response: models.Comment{}},
+	"POST /api/comments/:id/reopen":   {Summary: "Reopen a comment", Tag: "comments", Permission: models.PermissionComment, Response: models.Comment{}},
+
+	"GET /api/debug/llm-calls": {
+		Summary:    "Search recorded LLM calls",
+		Tag:        "debug",
+		Permission: models.PermissionViewDiagnostics,
+		Query: []QueryParam{
+			{Name: "articleId", Type: "integer"},
+			{Name: "step", Type: "string"},
+			{Name: "model", Type: "string"},
+			{Name: "provider", Type: "string"},
+			{Name: "status", Type: "string"},
+			{Name: "errorClass", Type: "string"},
+			{Name: "q", Type: "string", Description: "Search prompts and responses."},
+			{Name: "page", Type: "integer"},
+			{Name: "pageSize", Type: "integer"},
+		},
+		Response: models.LLMCallPage{},
+	},
+	"GET /api/debug/llm-calls/:id": {Summary: "Get a recorded LLM call", Tag: "debug", Permission: models.PermissionViewDiagnostics, Response: models.LLMCall{}},
+	"GET /api/debug/llm-cache":     {Summary: "Completion cache size and hit rates", Tag: "debug", Permission: models.PermissionViewDiagnostics, Response: models.LLMCacheStats{}},
+	"DELETE /api/debug/llm-cache": {
+		Summary:    "Purge cached completions",
+		Tag:        "debug",
+		Permission: models.PermissionViewDiagnostics,
+		Query: []QueryParam{
+			{Name: "step", Type: "string", Description: "Only purge this step's entries."},
+			{Name: "expired", Type: "boolean", Description: "Only purge expired entries."},
+		},
+		Response: models.LLMCachePurge{},
+	},
+
+	"GET /api/glossary": {
+		Summary:  "Get a language's glossary",
+		Tag:      "glossary",
+		Query:    []QueryParam{{Name: "language", Type: "string"}},
+		Response: models.Glossary{},
+	},
+	"POST /api/glossary/terms":       {Summary: "Add a glossary term", Tag: "glossary", Permission: models.PermissionManagePrompts, Body: createGlossaryTermRequest{}, Status: http.StatusCreated, Response: models.Glossary{}},
+	"PATCH /api/glossary/terms/:id":  {Summary: "Edit a glossary term", Tag: "glossary", Permission: models.PermissionManagePrompts, Body: updateGlossaryTermRequest{}, Response: models.Glossary{}},
+	"DELETE /api/glossary/terms/:id": {Summary: "Delete a glossary term", Tag: "glossary", Permission: models.PermissionManagePrompts, Response: models.Glossary{}},
+	"PUT /api/glossary/style":        {Summary: "Set a language's style notes", Tag: "glossary", Permission: models.PermissionManagePrompts, Body: updateStyleNotesRequest{}, Response: models.Glossary{}},
+	"POST /api/glossary/retranslate": {Summary: "Queue retranslation of stale analyses", Tag: "glossary", Permission: models.PermissionManagePrompts, Body: retranslateRequest{}, Status: http.StatusAccepted, Response: models.Job{}},
+
+	"GET /api/jobs": {
+		Summary:  "List background jobs",
+		Tag:      "jobs",
+		Query:    []QueryParam{{Name: "type", Type: "string"}, limitParam},
+		Response: items(models.Job{}),
+	},
+	"GET /api/jobs/:id": {Summary: "Get a background job", Tag: "jobs", Response: models.Job{}},
+
+	"GET /api/providers":                   {Summary: "List AI providers and their models", Tag: "models", Permission: models.PermissionManageProviders, Response: items(models.ProviderOption{})},
+	"GET /api/providers/health":            {Summary: "Circuit breaker state of each AI provider", Tag: "models", Permission: models.PermissionViewDiagnostics, Response: items(models.ProviderHealth{})},
+	"POST /api/providers/:provider/models": {Summary: "Add a model to a provider", Tag: "models", Permission: models.PermissionManageProviders, Body: createModelRequest{}, Status: http.StatusCreated, Response: models.ModelOption{}},
+	"PATCH /api/models/:id":                {Summary: "Edit a model", Tag: "models", Permission: models.PermissionManageProviders, Body: updateModelRequest{}, Response: models.ModelOption{}},
+	"POST /api/models/:id/default":         {Summary: "Make a model the default", Tag: "models", Permission: models.PermissionManageProviders, Response: models.ModelOption{}},
+	"GET /api/settings/provider-keys":      {Summary: "Show where each provider's API key comes from, masked", Tag: "models", Permission: models.PermissionManageProviders, Response: items(models.ProviderKeyStatus{})},
+	"PUT /api/settings/provider-keys/:provider": {
+		Summary:     "Store a provider API key",
+		Description: "The key is encrypted with PROVIDER_KEY_SECRET and used instead of the provider's environment variable. Only a masked form is ever returned.",
+		Tag:         "models",
+		Permission:  models.PermissionManageProviders,
+		Body:        providerKeyRequest{},
+		Response:    models.ProviderKeyStatus{},
+	},
+	"DELETE /api/settings/provider-keys/:provider": {Summary: "Remove a stored provider API key", Tag: "models", Permission: models.PermissionManageProviders, Response: statusResponse{}},
+	"POST /api/settings/provider-keys/:provider/test": {
+		Summary:     "Check a provider API key",
+		Description: "Lists the provider's models with apiKey, or with the key in use when the body is empty. Nothing is saved.",
+		Tag:         "models",
+		Permission:  models.PermissionManageProviders,
+		Body:        providerKeyRequest{},
+		Response:    models.ProviderKeyTest{},
+	},
+
+	"POST /api/exports/bundle": {
+		Summary:      "Download completed analyses as one file",
+		Description:  "Bundles the analyses completed from from to to (dates, inclusive, UTC; to defaults to today) as Markdown or HTML for newsletters or static sites. categories limits it to those categories. X-Analysis-Count says how many it holds.",
+		Tag:          "analyses",
+		Permission:   models.PermissionPublish,
+		Body:         exportBundleRequest{},
+		ResponseType: "text/markdown",
+	},
+
+	"GET /api/feature-flags": {
+		Summary:     "List feature flags",
+		Description: "Each flag shows its value and source: a runtime override, FEATURE_FLAGS, or the built-in default.",
+		Tag:         "settings",
+		Permission:  models.PermissionManageProviders,
+		Response:    items(models.FeatureFlag{}),
+	},
+	"PATCH /api/feature-flags/:key": {
+		Summary:     "Override a feature flag",
+		Description: "While enabled, rolloutPercent of users (for routes) or runs (for pipeline steps) get the feature. Other instances pick the change up within 15 seconds.",
+		Tag:         "settings",
+		Permission:  models.PermissionManageProviders,
+		Body:        updateFeatureFlagRequest{},
+		Response:    models.FeatureFlag{},
+	},
+	"DELETE /api/feature-flags/:key": {Summary: "Drop a feature flag's override", Tag: "settings", Permission: models.PermissionManageProviders, Response: models.FeatureFlag{}},
+
+	"GET /api/notifications": {
+		Summary:  "List your notifications",
+		Tag:      "notifications",
+		Query:    []QueryParam{{Name: "unread", Type: "boolean"}, limitParam},
+		Response: models.NotificationList{},
+	},
+	"POST /api/notifications/read":     {Summary: "Mark all notifications read", Tag: "notifications", Response: markedResponse{}},
+	"POST /api/notifications/:id/read": {Summary: "Mark a notification read", Tag: "notifications", Response: statusResponse{}},
+
+	"GET /api/digests/subscription": {Summary: "Get your digest subscription", Tag: "notifications", Response: models.DigestSubscription{}},
+	"PUT /api/digests/subscription": {
+		Summary:     "Subscribe to the digest email",
+		Description: "frequency is daily or weekly. Digests list the analyses completed since the last one and go out at DIGEST_HOUR UTC, weekly ones on Mondays. Answers 501 without SMTP_HOST.",
+		Tag:         "notifications",
+		Body:        digestSubscriptionRequest{},
+		Response:    models.DigestSubscription{},
+	},
+	"DELETE /api/digests/subscription": {Summary: "Stop your digest email", Tag: "notifications", Response: statusResponse{}},
+
+	"GET /api/prompts":                {Summary: "List prompt templates", Tag: "prompts", Permission: models.PermissionManagePrompts, Response: items(models.PromptTemplate{})},
+	"GET /api/prompts/:key":           {Summary: "Get a prompt template with its versions", Tag: "prompts", Permission: models.PermissionManagePrompts, Response: models.PromptTemplateDetail{}},
+	"POST /api/prompts/:key":          {Summary: "Save a new prompt version", Tag: "prompts", Permission: models.PermissionManagePrompts, Body: savePromptRequest{}, Status: http.StatusCreated, Response: models.PromptTemplateDetail{}},
+	"POST /api/prompts/:key/activate": {Summary: "Activate a prompt version", Tag: "prompts", Permission: models.PermissionManagePrompts, Body: activatePromptRequest{}, Response: models.PromptTemplateDetail{}},
+	"POST /api/prompts/:key/preview":  {Summary: "Render a prompt with sample values", Tag: "prompts", Permission: models.PermissionManagePrompts, Body: previewPromptRequest{}, Response: models.PromptPreview{}},
+
+	"GET /api/views":        {Summary: "List your saved views", Tag: "views", Response: items(models.SavedView{})},
+	"POST /api/views":       {Summary: "Save a view", Tag: "views", Body: savedViewRequest{}, Status: http.StatusCreated, Response: models.SavedView{}},
+	"GET /api/views/:id":    {Summary: "Get a saved view", Tag: "views", Response: models.SavedView{}},
+	"PUT /api/views/:id":    {Summary: "Change a saved view", Tag: "views", Body: savedViewRequest{}, Response: models.SavedView{}},
+	"DELETE /api/views/:id": {Summary: "Delete a saved view", Tag: "views", Response: statusResponse{}},
+
+	"GET /api/sources": {
+		Summary:  "List rated sources",
+		Tag:      "sources",
+		Query:    []QueryParam{{Name: "credibility", Type: "string"}},
+		Response: items(models.Source{}),
+	},
+	"GET /api/sources/:id":    {Summary: "Get a source", Tag: "sources", Response: models.Source{}},
+	"POST /api/sources":       {Summary: "Rate a source", Tag: "sources", Permission: models.PermissionManageSources, Body: createSourceRequest{}, Status: http.StatusCreated, Response: models.Source{}},
+	"PATCH /api/sources/:id":  {Summary: "Change a source's rating", Tag: "sources", Permission: models.PermissionManageSources, Body: updateSourceRequest{}, Response: models.Source{}},
+	"DELETE /api/sources/:id": {Summary: "Delete a source", Tag: "sources", Permission: models.PermissionManageSources, Response: statusResponse{}},
+
+	"GET /api/stats/editors":   {Summary: "Editor throughput", Tag: "stats", Permission: models.PermissionManageUsers, Query: []QueryParam{daysParam}, Response: models.EditorStats{}},
+	"GET /api/stats/trending":  {Summary: "Most covered topics and entities", Tag: "stats", Query: []QueryParam{daysParam, limitParam}, Response: models.TrendingStats{}},
+	"GET /api/stats/headlines": {Summary: "Headline click-through rates by style, length and model", Tag: "stats", Query: []QueryParam{daysParam}, Response: models.HeadlineStats{}},
+	"POST /api/analyses/:id/headline-results": {
+		Summary:     "Record headline A/B test results",
+		Description: "Stores the impressions and clicks a platform's A/B test reported for an analysis's headlines. Variants are matched to the generated headlines by text; reporting the same test again replaces its counts.",
+		Tag:         "stats",
+		Permission:  models.PermissionPublish,
+		Body:        headlineResultsRequest{},
+		Response:    items(models.HeadlineResult{}),
+	},
+
+	"GET /api/style-rules":        {Summary: "List house style rules", Tag: "style", Response: items(models.StyleRule{})},
+	"POST /api/style-rules/check": {Summary: "Check text against the style rules", Tag: "style", Body: checkStyleRequest{}, Response: models.StyleCheckResult{}},
+	"PUT /api/style-rules":        {Summary: "Replace all style rules", Tag: "style", Permission: models.PermissionManagePrompts, Body: replaceStyleRulesRequest{}, Response: items(models.StyleRule{})},
+	"POST /api/style-rules":       {Summary: "Add a style rule", Tag: "style", Permission: models.PermissionManagePrompts, Body: styleRuleRequest{}, Status: http.StatusCreated, Response: models.StyleRule{}},
+	"PUT /api/style-rules/:id":    {Summary: "Change a style rule", Tag: "style", Permission: models.PermissionManagePrompts, Body: styleRuleRequest{}, Response: models.StyleRule{}},
+	"DELETE /api/style-rules/:id": {Summary: "Delete a style rule", Tag: "style", Permission: models.PermissionManagePrompts, Response: statusResponse{}},
+
+	"POST /api/graphql": {
+		Summary:     "Run a GraphQL query",
+		Description: "Queries analyses with their facts, open questions, headlines, straplines and topics in one request. Only queries are supported; errors are returned in the body with status 200.",
+		Tag:         "graphql",
+		Body:        graphql.Request{},
+		Response:    graphql.Result{},
+	},
+	"GET /api/graphql/schema": {Summary: "Get the GraphQL schema", Tag: "graphql", ResponseType: "text/plain"},
+
+	"GET /api/clusters": {
+		Summary:     "Group recent analyses by story",
+		Description: "Analyses in the same language are grouped when their headlines and facts share enough names and words, the test used to thread follow-ups, or when they are already threaded together. Similarity is lexical rather than by embeddings, which Groq doesn't offer, so it works with either provider and needs no model calls.",
+		Tag:         "threads",
+		Query: []QueryParam{
+			daysParam,
+			{Name: "minSize", Type: "integer", Description: "Smallest cluster to return."},
+		},
+		Response: items(models.StoryCluster{}),
+	},
+	"GET /api/threads/:id": {Summary: "Get a story thread", Tag: "threads", Response: models.StoryThread{}},
+
+	"POST /api/auth/login": {
+		Summary:     "Sign in",
+		Description: "Trades a user's API key for a session: an access token to send as the bearer token until expiresIn seconds pass, and a single-use refresh token. Answers 501 without SESSION_SECRET.",
+		Tag:         "users",
+		Public:      true,
+		Body:        loginRequest{},
+		Status:      http.StatusCreated,
+		Response:    models.SessionTokens{},
+	},
+	"POST /api/auth/refresh": {
+		Summary:     "Refresh a session",
+		Description: "Replaces the refresh token and issues a new access token. A refresh token presented a second time revokes its session.",
+		Tag:         "users",
+		Public:      true,
+		Body:        refreshTokenRequest{},
+		Response:    models.SessionTokens{},
+	},
+	"POST /api/auth/logout": {Summary: "Sign out", Description: "Ends the session holding the refresh token.", Tag: "users", Public: true, Body: refreshTokenRequest{}, Response: statusResponse{}},
+	"GET /api/auth/sso/start": {
+		Summary:     "Sign in with single sign-on",
+		Description: "Redirects the browser to the OIDC provider. Answers 501 without OIDC_CLIENT_ID.",
+		Tag:         "users",
+		Public:      true,
+		Query:       []QueryParam{{Name: "next", Type: "string", Description: "Path in the admin app to land on after signing in."}},
+		Status:      http.StatusFound,
+	},
+	"GET /api/auth/sso/callback": {
+		Summary:     "Finish single sign-on",
+		Description: "Where the provider sends the browser back. Redirects to ADMIN_URL with accessToken, refreshToken and expiresIn, or error, in the URL fragment. First-time users get an account if their email domain is in OIDC_ALLOWED_DOMAINS.",
+		Tag:         "users",
+		Public:      true,
+		Status:      http.StatusFound,
+	},
+	"GET /api/auth/sessions":                    {Summary: "List the calling user's sessions", Tag: "users", Response: items(models.AuthSession{})},
+	"DELETE /api/auth/sessions/:id":             {Summary: "End one of the calling user's sessions", Tag: "users", Response: statusResponse{}},
+	"GET /api/users/:id/sessions":               {Summary: "List a user's sessions", Tag: "users", Permission: models.PermissionManageUsers, Response: items(models.AuthSession{})},
+	"DELETE /api/users/:id/sessions":            {Summary: "Sign a user out everywhere", Tag: "users", Permission: models.PermissionManageUsers, Response: statusResponse{}},
+	"DELETE /api/users/:id/sessions/:sessionId": {Summary: "End a user's session", Tag: "users", Permission: models.PermissionManageUsers, Response: statusResponse{}},
+	"GET /api/me":                               {Summary: "Get the calling user", Tag: "users", Response: models.Principal{}},
+	"GET /api/users":                            {Summary: "List users", Tag: "users", Permission: models.PermissionManageUsers, Response: items(models.User{})},
+	"POST /api/users":                           {Summary: "Create a user", Tag: "users", Permission: models.PermissionManageUsers, Body: createUserRequest{}, Status: http.StatusCreated, Response: models.CreatedUserResponse{}},
+	"PATCH /api/users/:id":                      {Summary: "Edit a user", Tag: "users", Permission: models.PermissionManageUsers, Body: updateUserRequest{}, Response: models.User{}},
+	"POST /api/users/:id/api-key":               {Summary: "Issue a user a new API key", Description: "The old key stops working and the sessions signed in with it end.", Tag: "users", Permission: models.PermissionManageUsers, Response: apiKeyResponse{}},
+	"GET /api/roles":                            {Summary: "List roles and permissions", Tag: "users", Permission: models.PermissionManageUsers, Response: rolesResponse{}},
+	"PUT /api/roles/:key/permissions":           {Summary: "Set a role's permissions", Tag: "users", Permission: models.PermissionManageUsers, Body: updateRolePermissionsRequest{}, Response: rolesResponse{}},
+
+	"POST /api/telegram/link": {
+		Summary:     "Get a code to link Telegram",
+		Description: "Send the bot /link and the code within 15 minutes. Messages sent to a linked bot are analysed as you, and the result is sent back as a reply. Answers 501 without TELEGRAM_BOT_TOKEN.",
+		Tag:         "telegram",
+		Permission:  models.PermissionEditAnalyses,
+		Status:      http.StatusCreated,
+		Response:    models.TelegramLinkCode{},
+	},
+	"GET /api/telegram/links":        {Summary: "List your linked Telegram accounts", Tag: "telegram", Response: telegramLinksResponse{}},
+	"DELETE /api/telegram/links/:id": {Summary: "Unlink a Telegram account", Tag: "telegram", Response: statusResponse{}},
+	"POST /telegram/webhook": {
+		Summary:     "Telegram bot webhook",
+		Description: "Called by Telegram with each update, with TELEGRAM_WEBHOOK_SECRET in X-Telegram-Bot-Api-Secret-Token. Register it with setWebhook; it answers 404 without TELEGRAM_BOT_TOKEN.",
+		Tag:         "telegram",
+		Public:      true,
+	},
+
+	"GET /whatsapp/webhook": {
+		Summary:     "Verify the WhatsApp webhook",
+		Description: "Meta's check when the webhook is saved: answers hub.challenge as plain text when hub.verify_token is WHATSAPP_VERIFY_TOKEN, and 403 otherwise.",
+		Tag:         "whatsapp",
+		Public:      true,
+		Query: []QueryParam{
+			{Name: "hub.mode", Type: "string"},
+			{Name: "hub.verify_token", Type: "string"},
+			{Name: "hub.challenge", Type: "string"},
+		},
+		ResponseType: "text/plain",
+	},
+	"POST /whatsapp/webhook": {
+		Summary:     "WhatsApp Business webhook",
+		Description: "Called by Meta with messages sent to the business number, signed in X-Hub-Signature-256 with WHATSAPP_APP_SECRET. Each text message, or media caption, is queued as an analysis in WHATSAPP_LANGUAGE, with the sender in its submission. Answers 404 without WHATSAPP_APP_SECRET.",
+		Tag:         "whatsapp",
+		Public:      true,
+	},
+
+	"GET /public/feed.json": {
+		Summary:     "JSON Feed of published analyses",
+		Description: "The 50 newest completed analyses with a slug, linked at PUBLIC_SITE_URL/<slug>. feed_url is given on PUBLIC_API_URL, and left out without it. Answers 501 without PUBLIC_SITE_URL.",
+		Tag:         "public",
+		Public:      true,
+		Response:    models.JSONFeed{},
+	},
+	"GET /public/reviews/:token": {
+		Summary:     "Open a review link",
+		Description: "An HTML page, or the analysis as JSON when Accept asks for it. Expired and revoked links answer 404.",
+		Tag:         "public",
+		Public:      true,
+		Response:    models.SharedAnalysis{},
+	},
+	"GET /public/rss.xml":     {Summary: "RSS feed of published analyses", Description: "The same analyses as the JSON Feed, as RSS 2.0.", Tag: "public", Public: true, ResponseType: "application/rss+xml"},
+	"GET /public/sitemap.xml": {Summary: "Sitemap of published analyses", Description: "Every completed analysis with a slug, with when it last changed.", Tag: "public", Public: true, ResponseType: "application/xml"},
+	"GET /api/docs":           {Summary: "Swagger UI for this spec", Tag: "docs", Public: true, ResponseType: "text/html"},
+}
+
+const webhookDelivery = "Posted to WEBHOOK_URL, in the WebhookEvent schema, when the event is in WEBHOOK_EVENTS. " +
+	"With WEBHOOK_SECRET set, X-Nanoheads-Signature is sha256= and the hex HMAC-SHA256 of the body. " +
+	"Deliveries are not retried; the changed-analyses poll catches up on any that are lost."
+
+// webhookOperations documents the JSON webhook by event, under x-webhooks.
+var webhookOperations = map[string]Operation{
+	config.EventAnalysisFinished:  {Summary: "A new draft is ready", Description: webhookDelivery, Body: models.WebhookEvent{}},
+	config.EventAnalysisFailed:    {Summary: "An analysis failed", Description: webhookDelivery + " data.analysisId is null when the analysis failed before it was saved.", Body: models.WebhookEvent{}},
+	config.EventAnalysisPublished: {Summary: "An analysis was published", Description: webhookDelivery, Body: models.WebhookEvent{}},
+}
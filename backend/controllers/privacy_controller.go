@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type PrivacyController struct {
+	privacy *services.PrivacyService
+}
+
+func NewPrivacyController(database *sql.DB) *PrivacyController {
+	return &PrivacyController{
+		privacy: services.NewPrivacyService(database),
+	}
+}
+
+type purgeAnalysesRequest struct {
+	AnalysisIDs []int64 `json:"analysisIds"`
+	Reason      string  `json:"reason"`
+}
+
+// ExportData answers a data subject's access request: every stored row of
+// the analyses touching a source URL or entity.
+func (p *PrivacyController) ExportData(c *gin.Context) {
+	export, err := p.privacy.Export(c.Request.Context(), c.Query("sourceUrl"), c.Query("entity"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="privacy-export.json"`)
+	c.JSON(http.StatusOK, export)
+}
+
+func (p *PrivacyController) PurgeAnalyses(c *gin.Context) {
+	var req purgeAnalysesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	purge, err := p.privacy.Purge(c.Request.Context(), req.AnalysisIDs, req.Reason, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, purge)
+}
+
+func (p *PrivacyController) ListPurges(c *gin.Context) {
+	purges, err := p.privacy.ListPurges(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": purges})
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type PromptController struct {
+	prompts *services.PromptService
+}
+
+type savePromptRequest struct {
+	Body     string `json:"body"`
+	Notes    string `json:"notes"`
+	Activate *bool  `json:"activate"`
+}
+
+type activatePromptRequest struct {
+	Version *int `json:"version"`
+}
+
+type previewPromptRequest struct {
+	Body      string            `json:"body"`
+	Version   *int              `json:"version"`
+	Variables map[string]string `json:"variables"`
+}
+
+func NewPromptController(database *sql.DB) *PromptController {
+	return &PromptController{
+		prompts: services.NewPromptService(database),
+	}
+}
+
+func (p *PromptController) ListPrompts(c *gin.Context) {
+	items, err := p.prompts.List(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+	})
+}
+
+func (p *PromptController) GetPrompt(c *gin.Context) {
+	detail, err := p.prompts.Get(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+func (p *PromptController) SavePrompt(c *gin.Context) {
+	var req savePromptRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	activate := req.Activate == nil || *req.Activate
+	detail, err := p.prompts.Save(c.Request.Context(), c.Param("key"), req.Body, req.Notes, activate, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, detail)
+}
+
+func (p *PromptController) ActivatePrompt(c *gin.Context) {
+	var req activatePromptRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Version == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required", "code": models.ErrorCodeInvalidRequest})
+		return
+	}
+
+	detail, err := p.prompts.Activate(c.Request.Context(), c.Param("key"), *req.Version)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+func (p *PromptController) PreviewPrompt(c *gin.Context) {
+	var req previewPromptRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	preview, err := p.prompts.Preview(c.Request.Context(), c.Param("key"), req.Body, req.Version, req.Variables)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/config"
+	"nanoheads/services"
+)
+
+// Feeds change only when an analysis is completed or edited; a few minutes
+// of caching keeps aggregators polling them cheap.
+const publicFeedCacheControl = "public, max-age=300"
+
+type PublicFeedController struct {
+	feeds *services.PublicFeedService
+}
+
+func NewPublicFeedController(database *sql.DB) *PublicFeedController {
+	return &PublicFeedController{
+		feeds: services.NewPublicFeedService(database),
+	}
+}
+
+func (p *PublicFeedController) JSONFeed(c *gin.Context) {
+	feed, err := p.feeds.JSONFeed(c.Request.Context(), publicURL(c.Request.URL.Path))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", publicFeedCacheControl)
+	c.Header("Content-Type", "application/feed+json; charset=utf-8")
+	c.JSON(http.StatusOK, feed)
+}
+
+func (p *PublicFeedController) RSS(c *gin.Context) {
+	body, err := p.feeds.RSS(c.Request.Context(), publicURL(c.Request.URL.Path))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", publicFeedCacheControl)
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", body)
+}
+
+func (p *PublicFeedController) Sitemap(c *gin.Context) {
+	body, err := p.feeds.Sitemap(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", publicFeedCacheControl)
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
+
+// publicURL is path on PUBLIC_API_URL, or "" without it.
+func publicURL(path string) string {
+	base := strings.TrimRight(config.Current().PublicAPIURL, "/")
+	if base == "" {
+		return ""
+	}
+	return base + path
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type RetentionController struct {
+	retention *services.RetentionService
+}
+
+func NewRetentionController(database *sql.DB) *RetentionController {
+	return &RetentionController{
+		retention: services.NewRetentionService(database),
+	}
+}
+
+// PreviewRetention lists the drafts the next retention run would archive or
+// delete, so an admin can check the policy before it bites.
+func (r *RetentionController) PreviewRetention(c *gin.Context) {
+	preview, err := r.retention.Preview(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
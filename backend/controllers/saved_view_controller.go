@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type SavedViewController struct {
+	views *services.SavedViewService
+}
+
+type savedViewRequest struct {
+	Name    string             `json:"name"`
+	Filters models.ViewFilters `json:"filters"`
+}
+
+func NewSavedViewController(database *sql.DB) *SavedViewController {
+	return &SavedViewController{
+		views: services.NewSavedViewService(database),
+	}
+}
+
+func (v *SavedViewController) ListViews(c *gin.Context) {
+	views, err := v.views.List(c.Request.Context(), principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": views,
+	})
+}
+
+func (v *SavedViewController) GetView(c *gin.Context) {
+	viewID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	view, err := v.views.Get(c.Request.Context(), viewID, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+func (v *SavedViewController) CreateView(c *gin.Context) {
+	var req savedViewRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	view, err := v.views.Create(c.Request.Context(), req.Name, req.Filters, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, view)
+}
+
+func (v *SavedViewController) UpdateView(c *gin.Context) {
+	viewID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req savedViewRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	view, err := v.views.Update(c.Request.Context(), viewID, req.Name, req.Filters, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+func (v *SavedViewController) DeleteView(c *gin.Context) {
+	viewID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := v.views.Delete(c.Request.Context(), viewID, principalUserID(c)); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
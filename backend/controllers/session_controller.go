@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/middleware"
+	"nanoheads/services"
+)
+
+type SessionController struct {
+	authService *services.AuthService
+}
+
+type loginRequest struct {
+	APIKey string `json:"apiKey"`
+	Device string `json:"device"`
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func NewSessionController(authService *services.AuthService) *SessionController {
+	return &SessionController{
+		authService: authService,
+	}
+}
+
+func (s *SessionController) Login(c *gin.Context) {
+	var req loginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := s.authService.Login(c.Request.Context(), req.APIKey, req.Device, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, tokens)
+}
+
+func (s *SessionController) Refresh(c *gin.Context) {
+	var req refreshTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tokens, err := s.authService.Refresh(c.Request.Context(), req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (s *SessionController) Logout(c *gin.Context) {
+	var req refreshTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := s.authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *SessionController) ListOwnSessions(c *gin.Context) {
+	principal := middleware.CurrentPrincipal(c)
+	sessions, err := s.authService.ListSessions(c.Request.Context(), principal.UserID, principal.SessionID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": sessions,
+	})
+}
+
+func (s *SessionController) RevokeOwnSession(c *gin.Context) {
+	sessionID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.authService.RevokeSession(c.Request.Context(), middleware.CurrentPrincipal(c).UserID, sessionID); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *SessionController) ListUserSessions(c *gin.Context) {
+	userID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	sessions, err := s.authService.ListSessions(c.Request.Context(), userID, middleware.CurrentPrincipal(c).SessionID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": sessions,
+	})
+}
+
+func (s *SessionController) RevokeUserSession(c *gin.Context) {
+	userID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+	sessionID, ok := parsePathID(c, "sessionId")
+	if !ok {
+		return
+	}
+
+	if err := s.authService.RevokeSession(c.Request.Context(), userID, sessionID); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *SessionController) RevokeUserSessions(c *gin.Context) {
+	userID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.authService.RevokeUserSessions(c.Request.Context(), userID); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type ShareController struct {
+	shares *services.ShareService
+}
+
+type createShareRequest struct {
+	ExpiresInHours int    `json:"expiresInHours"`
+	Note           string `json:"note"`
+}
+
+func NewShareController(database *sql.DB) *ShareController {
+	return &ShareController{
+		shares: services.NewShareService(database),
+	}
+}
+
+// CreateShare makes a review link for the analysis. The link's URL is in
+// this response only.
+func (s *ShareController) CreateShare(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+	// The body is optional; without one the link lasts 72 hours.
+	var req createShareRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	share, token, err := s.shares.Create(c.Request.Context(), articleID, req.ExpiresInHours, req.Note, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	// Without PUBLIC_API_URL the link is given as a path on this server.
+	path := "/public/reviews/" + token
+	if share.URL = publicURL(path); share.URL == "" {
+		share.URL = path
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+func (s *ShareController) ListShares(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	shares, err := s.shares.List(c.Request.Context(), articleID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": shares})
+}
+
+func (s *ShareController) RevokeShare(c *gin.Context) {
+	shareID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	share, err := s.shares.Revoke(c.Request.Context(), shareID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// OpenShare shows a shared analysis: as a page in a browser, or as JSON to
+// a client that asks for it.
+func (s *ShareController) OpenShare(c *gin.Context) {
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+
+	shared, err := s.shares.Open(c.Request.Context(), c.Param("token"))
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, shared)
+		return
+	}
+
+	status, page := http.StatusOK, &shared
+	if errors.Is(err, services.ErrShareUnavailable) {
+		status, page = http.StatusNotFound, nil
+	} else if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	body, err := services.RenderReview(page)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", body)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type SourceController struct {
+	sources *services.SourceService
+}
+
+type createSourceRequest struct {
+	Domain      string `json:"domain"`
+	Name        string `json:"name"`
+	Credibility string `json:"credibility"`
+	Notes       string `json:"notes"`
+	// SkipScreening exempts the domain's submissions from content screening.
+	SkipScreening bool `json:"skipScreening"`
+}
+
+type updateSourceRequest struct {
+	Name          *string `json:"name"`
+	Credibility   *string `json:"credibility"`
+	Notes         *string `json:"notes"`
+	SkipScreening *bool   `json:"skipScreening"`
+}
+
+func NewSourceController(database *sql.DB) *SourceController {
+	return &SourceController{
+		sources: services.NewSourceService(database),
+	}
+}
+
+func (s *SourceController) ListSources(c *gin.Context) {
+	items, err := s.sources.List(c.Request.Context(), c.Query("credibility"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+	})
+}
+
+func (s *SourceController) GetSource(c *gin.Context) {
+	sourceID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	source, err := s.sources.Get(c.Request.Context(), sourceID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+func (s *SourceController) CreateSource(c *gin.Context) {
+	var req createSourceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	source, err := s.sources.Create(c.Request.Context(), req.Domain, req.Name, req.Credibility, req.Notes, req.SkipScreening, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, source)
+}
+
+func (s *SourceController) UpdateSource(c *gin.Context) {
+	sourceID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req updateSourceRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	source, err := s.sources.Update(c.Request.Context(), sourceID, req.Name, req.Credibility, req.Notes, req.SkipScreening, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, source)
+}
+
+func (s *SourceController) DeleteSource(c *gin.Context) {
+	sourceID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.sources.Delete(c.Request.Context(), sourceID); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
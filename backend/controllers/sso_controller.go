@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+const (
+	ssoCookie     = "nh_sso"
+	ssoCookiePath = "/api/auth/sso"
+)
+
+type SSOController struct {
+	sso *services.SSOService
+}
+
+func NewSSOController(authService *services.AuthService) *SSOController {
+	return &SSOController{
+		sso: services.NewSSOService(authService),
+	}
+}
+
+// requestIsHTTPS tells whether the browser used https, directly or through
+// a proxy that sets X-Forwarded-Proto, so the cookie is marked Secure.
+func requestIsHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// Start sends the browser to the sign-in provider.
+func (s *SSOController) Start(c *gin.Context) {
+	location, nonce, err := s.sso.Start(c.Request.Context(), c.Query("next"))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoCookie, nonce, 600, ssoCookiePath, "", requestIsHTTPS(c), true)
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, location)
+}
+
+// Callback is where the provider sends the browser back. Either way it ends
+// up at the admin app: with the session's tokens in the URL fragment, which
+// never reaches a server, or with an error to show.
+func (s *SSOController) Callback(c *gin.Context) {
+	nonce, _ := c.Cookie(ssoCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoCookie, "", -1, ssoCookiePath, "", requestIsHTTPS(c), true)
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	adminURL := config.Current().AdminURL
+	if message := c.Query("error"); message != "" {
+		if description := c.Query("error_description"); description != "" {
+			message = description
+		}
+		c.Redirect(http.StatusFound, adminURL+"/#"+url.Values{"error": {"sign-in was cancelled or refused: " + message}}.Encode())
+		return
+	}
+
+	tokens, next, err := s.sso.Callback(c.Request.Context(), c.Query("code"), c.Query("state"), nonce, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		message := err.Error()
+		switch services.ErrorCode(err) {
+		case models.ErrorCodeInvalidRequest, models.ErrorCodeForbidden, models.ErrorCodeNotConfigured:
+		default:
+			log.Printf("[auth] single sign-on failed: %v", err)
+			message = "sign-in failed; try again or ask an admin"
+		}
+		c.Redirect(http.StatusFound, adminURL+"/#"+url.Values{"error": {message}}.Encode())
+		return
+	}
+
+	if next == "" {
+		next = "/"
+	}
+	fragment := url.Values{
+		"accessToken":  {tokens.AccessToken},
+		"refreshToken": {tokens.RefreshToken},
+		"expiresIn":    {strconv.Itoa(tokens.ExpiresIn)},
+	}
+	c.Redirect(http.StatusFound, adminURL+next+"#"+fragment.Encode())
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type headlineResultsRequest struct {
+	TestID   string                   `json:"testId"`
+	Platform string                   `json:"platform"`
+	Variants []models.HeadlineVariant `json:"variants"`
+}
+
+type StatsController struct {
+	stats *services.StatsService
+}
+
+func NewStatsController(database *sql.DB) *StatsController {
+	return &StatsController{
+		stats: services.NewStatsService(database),
+	}
+}
+
+// EditorStats summarizes each editor's output over the last ?days= days,
+// thirty by default.
+func (s *StatsController) EditorStats(c *gin.Context) {
+	result, err := s.stats.EditorStats(c.Request.Context(), parseOptionalInt(c.Query("days"), 0))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// TrendingStats ranks the topics and entities the newsroom covered most over
+// the last ?days= days, thirty by default, keeping the top ?limit= of each.
+func (s *StatsController) TrendingStats(c *gin.Context) {
+	result, err := s.stats.TrendingStats(c.Request.Context(), parseOptionalInt(c.Query("days"), 0), parseOptionalInt(c.Query("limit"), 0))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RecordHeadlineResults stores the impressions and clicks an A/B test on a
+// publishing platform reported for an analysis's headlines. Reporting the
+// same test again replaces its counts.
+func (s *StatsController) RecordHeadlineResults(c *gin.Context) {
+	articleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req headlineResultsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	results, err := s.stats.RecordHeadlineResults(c.Request.Context(), articleID, req.TestID, req.Platform, req.Variants)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": results})
+}
+
+// HeadlineStats compares click-through rates by headline style, length and
+// model over the last ?days= days, ninety by default.
+func (s *StatsController) HeadlineStats(c *gin.Context) {
+	result, err := s.stats.HeadlineStats(c.Request.Context(), parseOptionalInt(c.Query("days"), 0))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
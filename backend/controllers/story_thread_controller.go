@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/services"
+)
+
+type StoryThreadController struct {
+	threads *services.StoryThreadService
+}
+
+func NewStoryThreadController(database *sql.DB) *StoryThreadController {
+	return &StoryThreadController{
+		threads: services.NewStoryThreadService(database),
+	}
+}
+
+// GetThread returns a story thread with its analyses in the order they were
+// written, showing what each one added.
+func (s *StoryThreadController) GetThread(c *gin.Context) {
+	threadID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	thread, err := s.threads.Get(c.Request.Context(), threadID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+// ListClusters groups the analyses of the last days by story, for the
+// dashboard's "N analyses about ..." view.
+func (s *StoryThreadController) ListClusters(c *gin.Context) {
+	days := parseOptionalInt(c.Query("days"), 7)
+	minSize := parseOptionalInt(c.Query("minSize"), 2)
+
+	clusters, err := s.threads.Clusters(c.Request.Context(), days, minSize)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": clusters,
+	})
+}
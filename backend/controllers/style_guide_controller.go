@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type StyleGuideController struct {
+	styleGuide *services.StyleGuideService
+}
+
+type styleRuleRequest struct {
+	Kind        string `json:"kind"`
+	Value       string `json:"value"`
+	Replacement string `json:"replacement"`
+	Action      string `json:"action"`
+	Notes       string `json:"notes"`
+}
+
+type replaceStyleRulesRequest struct {
+	Rules []styleRuleRequest `json:"rules"`
+}
+
+type checkStyleRequest struct {
+	Field string `json:"field"`
+	Text  string `json:"text"`
+}
+
+func NewStyleGuideController(database *sql.DB) *StyleGuideController {
+	return &StyleGuideController{
+		styleGuide: services.NewStyleGuideService(database),
+	}
+}
+
+func (r styleRuleRequest) rule() models.StyleRule {
+	return models.StyleRule{
+		Kind:        r.Kind,
+		Value:       r.Value,
+		Replacement: r.Replacement,
+		Action:      r.Action,
+		Notes:       r.Notes,
+	}
+}
+
+func (s *StyleGuideController) ListRules(c *gin.Context) {
+	items, err := s.styleGuide.List(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+	})
+}
+
+// ReplaceRules uploads a complete style guide in place of the current rules.
+func (s *StyleGuideController) ReplaceRules(c *gin.Context) {
+	var req replaceStyleRulesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rules := make([]models.StyleRule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		rules = append(rules, rule.rule())
+	}
+	items, err := s.styleGuide.Replace(c.Request.Context(), rules, principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+	})
+}
+
+func (s *StyleGuideController) CreateRule(c *gin.Context) {
+	var req styleRuleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rule, err := s.styleGuide.Create(c.Request.Context(), req.rule(), principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (s *StyleGuideController) UpdateRule(c *gin.Context) {
+	ruleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req styleRuleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rule, err := s.styleGuide.Update(c.Request.Context(), ruleID, req.rule(), principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *StyleGuideController) DeleteRule(c *gin.Context) {
+	ruleID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.styleGuide.Delete(c.Request.Context(), ruleID); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *StyleGuideController) Check(c *gin.Context) {
+	var req checkStyleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := s.styleGuide.Check(c.Request.Context(), req.Field, req.Text)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+)
+
+const maxUserAgentLength = 512
+
+// newSubmission captures who sent a request and from where. An explicit
+// X-Submission-Channel header wins over the channel inferred from the route.
+func newSubmission(c *gin.Context, channel string, params map[string]any) *models.Submission {
+	if requested := strings.ToLower(strings.TrimSpace(c.GetHeader("X-Submission-Channel"))); requested != "" {
+		for _, known := range models.SubmissionChannels {
+			if requested == known {
+				channel = known
+				break
+			}
+		}
+	}
+
+	userAgent := strings.TrimSpace(c.Request.UserAgent())
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	cleanParams := make(map[string]any, len(params))
+	for key, value := range params {
+		if text, ok := value.(string); ok && strings.TrimSpace(text) == "" {
+			continue
+		}
+		cleanParams[key] = value
+	}
+
+	return &models.Submission{
+		SubmittedBy: principalUserID(c),
+		Channel:     channel,
+		ClientIP:    c.ClientIP(),
+		UserAgent:   userAgent,
+		Params:      cleanParams,
+	}
+}
+
+// browserChannel tells requests made from a browser page (the newsroom UI)
+// apart from scripts calling the API directly.
+func browserChannel(c *gin.Context) string {
+	if strings.TrimSpace(c.GetHeader("Origin")) != "" {
+		return models.SubmissionChannelUI
+	}
+	return models.SubmissionChannelAPI
+}
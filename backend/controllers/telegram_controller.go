@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+const maxTelegramUpdateBytes = 1 << 20
+
+type TelegramController struct {
+	telegram *services.TelegramService
+}
+
+func NewTelegramController(database *sql.DB) *TelegramController {
+	return &TelegramController{
+		telegram: services.NewTelegramService(database),
+	}
+}
+
+// Webhook receives updates from Telegram. The secret given to setWebhook is
+// sent back in a header on every update, which is how they are told apart
+// from anyone else calling the URL.
+func (t *TelegramController) Webhook(c *gin.Context) {
+	cfg := config.Current()
+	if cfg.TelegramBotToken == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the Telegram bot is not configured", "code": models.ErrorCodeNotFound})
+		return
+	}
+	secret := c.GetHeader("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(cfg.TelegramWebhookSecret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid secret token", "code": models.ErrorCodeUnauthenticated})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxTelegramUpdateBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "update too large"})
+		return
+	}
+	// Telegram resends an update until it gets a 2xx, so a failure is only
+	// logged; the user has already been told what went wrong where they can.
+	if err := t.telegram.HandleUpdate(c.Request.Context(), body); err != nil {
+		log.Printf("[telegram] failed to handle an update: %v", err)
+	}
+	c.Status(http.StatusOK)
+}
+
+// CreateLinkCode gives the caller a one-time code to send the bot, which
+// links their Telegram account to them.
+func (t *TelegramController) CreateLinkCode(c *gin.Context) {
+	code, err := t.telegram.CreateLinkCode(c.Request.Context(), principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, code)
+}
+
+func (t *TelegramController) ListLinks(c *gin.Context) {
+	links, err := t.telegram.ListLinks(c.Request.Context(), principalUserID(c))
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": links})
+}
+
+func (t *TelegramController) DeleteLink(c *gin.Context) {
+	id, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := t.telegram.Unlink(c.Request.Context(), principalUserID(c), id); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
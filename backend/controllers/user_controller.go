@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+type UserController struct {
+	authService *services.AuthService
+}
+
+type createUserRequest struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName"`
+	Role        string `json:"role"`
+}
+
+type updateUserRequest struct {
+	DisplayName *string `json:"displayName"`
+	Role        *string `json:"role"`
+	Active      *bool   `json:"active"`
+}
+
+type updateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+func NewUserController(authService *services.AuthService) *UserController {
+	return &UserController{
+		authService: authService,
+	}
+}
+
+func (u *UserController) GetCurrentUser(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.CurrentPrincipal(c))
+}
+
+func (u *UserController) ListUsers(c *gin.Context) {
+	users, err := u.authService.ListUsers(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": users,
+	})
+}
+
+func (u *UserController) CreateUser(c *gin.Context) {
+	var req createUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, apiKey, err := u.authService.CreateUser(c.Request.Context(), req.Email, req.DisplayName, req.Role)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreatedUserResponse{
+		User:   user,
+		APIKey: apiKey,
+	})
+}
+
+func (u *UserController) UpdateUser(c *gin.Context) {
+	userID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req updateUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := u.authService.UpdateUser(c.Request.Context(), userID, req.DisplayName, req.Role, req.Active); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	user, err := u.authService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (u *UserController) RotateAPIKey(c *gin.Context) {
+	userID, ok := parsePathID(c, "id")
+	if !ok {
+		return
+	}
+
+	apiKey, err := u.authService.RotateAPIKey(c.Request.Context(), userID)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"apiKey": apiKey,
+	})
+}
+
+func (u *UserController) ListRoles(c *gin.Context) {
+	roles, err := u.authService.ListRoles(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       roles,
+		"permissions": models.AllPermissions,
+	})
+}
+
+func (u *UserController) UpdateRolePermissions(c *gin.Context) {
+	roleKey := strings.TrimSpace(c.Param("key"))
+
+	var req updateRolePermissionsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := u.authService.SetRolePermissions(c.Request.Context(), roleKey, req.Permissions); err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	roles, err := u.authService.ListRoles(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       roles,
+		"permissions": models.AllPermissions,
+	})
+}
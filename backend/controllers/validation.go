@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+// fieldError is one problem with a request. Field is the JSON path of the
+// value, such as "sources[1].url", or "body" for the body as a whole.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationResponse is the 422 body: every problem found, not just the first.
+type validationResponse struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code"`
+	Fields []fieldError `json:"fields"`
+}
+
+type requestValidation struct {
+	fields []fieldError
+}
+
+func (v *requestValidation) add(field string, format string, args ...any) {
+	v.fields = append(v.fields, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkURL applies the URL rules for submitted sources to value, if set.
+func (v *requestValidation) checkURL(field string, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := services.CheckSourceURL(value); err != nil {
+		v.add(field, "%s", strings.TrimPrefix(err.Error(), "url "))
+	}
+}
+
+// respond writes the problems found as a 422 and reports whether there were
+// any.
+func (v *requestValidation) respond(c *gin.Context) bool {
+	if len(v.fields) == 0 {
+		return false
+	}
+	respondValidation(c, v.fields...)
+	return true
+}
+
+func respondValidation(c *gin.Context, fields ...fieldError) {
+	message := "invalid request"
+	if len(fields) == 1 {
+		message = strings.TrimSpace(fields[0].Field + " " + fields[0].Message)
+	}
+	c.JSON(http.StatusUnprocessableEntity, validationResponse{Error: message, Code: models.ErrorCodeInvalidRequest, Fields: fields})
+}
+
+func bindJSON(c *gin.Context, target any) bool {
+	return bind(c, target, binding.JSON)
+}
+
+// bindForm binds multipart and urlencoded forms, or JSON, by content type.
+func bindForm(c *gin.Context, target any) bool {
+	return bind(c, target, binding.Default(c.Request.Method, c.ContentType()))
+}
+
+func bind(c *gin.Context, target any, b binding.Binding) bool {
+	err := c.ShouldBindWith(target, b)
+	if err == nil {
+		return true
+	}
+
+	var (
+		tooLarge  *http.MaxBytesError
+		syntax    *json.SyntaxError
+		wrongType *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": middleware.BodyTooLargeMessage(tooLarge.Limit), "code": models.ErrorCodeBodyTooLarge})
+	case errors.Is(err, io.EOF):
+		respondValidation(c, fieldError{Field: "body", Message: "is required"})
+	case errors.As(err, &syntax):
+		respondValidation(c, fieldError{Field: "body", Message: fmt.Sprintf("is not valid JSON (at byte %d)", syntax.Offset)})
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		respondValidation(c, fieldError{Field: "body", Message: "is not valid JSON (it ends early)"})
+	case errors.As(err, &wrongType):
+		field := wrongType.Field
+		if field == "" {
+			field = "body"
+		}
+		respondValidation(c, fieldError{Field: field, Message: "must be " + jsonKind(wrongType.Type.Kind())})
+	default:
+		respondValidation(c, fieldError{Field: "body", Message: err.Error()})
+	}
+	return false
+}
+
+func jsonKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+func languageNames(languages []models.Language) string {
+	names := make([]string, len(languages))
+	for idx, language := range languages {
+		names[idx] = language.Name
+	}
+	return strings.Join(names, ", ")
+}
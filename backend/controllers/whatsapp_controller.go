@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+const maxWhatsAppDeliveryBytes = 1 << 20
+
+type WhatsAppController struct {
+	whatsapp *services.WhatsAppService
+}
+
+func NewWhatsAppController(database *sql.DB) *WhatsAppController {
+	return &WhatsAppController{
+		whatsapp: services.NewWhatsAppService(database),
+	}
+}
+
+// VerifyWebhook answers the check Meta makes when the webhook URL is saved
+// in the app dashboard.
+func (w *WhatsAppController) VerifyWebhook(c *gin.Context) {
+	if config.Current().WhatsAppAppSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WhatsApp intake is not configured", "code": models.ErrorCodeNotFound})
+		return
+	}
+
+	challenge, ok := w.whatsapp.VerifySubscription(c.Query("hub.mode"), c.Query("hub.verify_token"), c.Query("hub.challenge"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid verify token", "code": models.ErrorCodeForbidden})
+		return
+	}
+	c.String(http.StatusOK, challenge)
+}
+
+// Webhook receives messages sent to the newsroom's WhatsApp Business number
+// and queues each one for analysis.
+func (w *WhatsAppController) Webhook(c *gin.Context) {
+	if config.Current().WhatsAppAppSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WhatsApp intake is not configured", "code": models.ErrorCodeNotFound})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWhatsAppDeliveryBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "delivery too large"})
+		return
+	}
+	if !w.whatsapp.VerifySignature(body, c.GetHeader("X-Hub-Signature-256")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature", "code": models.ErrorCodeUnauthenticated})
+		return
+	}
+
+	// Meta retries deliveries that don't get a 200, so failures are logged
+	// rather than answered.
+	if _, err := w.whatsapp.HandleDelivery(c.Request.Context(), body); err != nil {
+		log.Printf("[whatsapp] failed to handle a delivery: %v", err)
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,345 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationState struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// LoadMigrations reads the embedded migrations for a driver, ordered by version.
+// Files are named NNNN_name.up.sql and NNNN_name.down.sql.
+func LoadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %w", driver, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		if direction != ".up" && direction != ".down" {
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", fileName)
+		}
+
+		versionText, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s must be named NNNN_name", fileName)
+		}
+		version, err := strconv.ParseInt(versionText, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has an invalid version", fileName)
+		}
+
+		raw, err := migrationFiles.ReadFile(path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, name)
+		}
+
+		if direction == ".up" {
+			migration.Up = string(raw)
+		} else {
+			migration.Down = string(raw)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration and returns how many ran.
+func MigrateUp(database *sql.DB, driver string) (int, error) {
+	migrations, err := LoadMigrations(driver)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(database, driver)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if _, done := applied[migration.Version]; done {
+			continue
+		}
+		if err := runMigration(database, driver, migration, true); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown rolls back the most recent steps applied migrations.
+func MigrateDown(database *sql.DB, driver string, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, errors.New("steps must be positive")
+	}
+
+	migrations, err := LoadMigrations(driver)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(database, driver)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := migrations[i]
+		if _, done := applied[migration.Version]; !done {
+			continue
+		}
+		if strings.TrimSpace(migration.Down) == "" {
+			return count, fmt.Errorf("migration %04d_%s has no down script", migration.Version, migration.Name)
+		}
+		if err := runMigration(database, driver, migration, false); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func MigrationStatus(database *sql.DB, driver string) ([]MigrationState, error) {
+	migrations, err := LoadMigrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(database, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, migration := range migrations {
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if appliedAt, done := applied[migration.Version]; done {
+			at := appliedAt
+			state.Applied = true
+			state.AppliedAt = &at
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// PendingMigrations lists the migrations not applied yet. Unlike the other
+// functions here it only reads, so probes can call it without creating
+// schema_migrations.
+func PendingMigrations(ctx context.Context, database *sql.DB, driver string) ([]Migration, error) {
+	migrations, err := LoadMigrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := database.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]struct{})
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pending := make([]Migration, 0)
+	for _, migration := range migrations {
+		if _, done := applied[migration.Version]; !done {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func ensureMigrationsTable(database *sql.DB, driver string) error {
+	var statement string
+	switch driver {
+	case "postgres":
+		statement = `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`
+	case "mysql":
+		statement = `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`
+	default:
+		return fmt.Errorf("unsupported driver for migrations: %s", driver)
+	}
+
+	if _, err := database.Exec(statement); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func appliedMigrations(database *sql.DB, driver string) (map[int64]time.Time, error) {
+	if err := ensureMigrationsTable(database, driver); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt.Time
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes one direction of a migration and records it in a single
+// transaction. MySQL commits DDL implicitly, so a failure part way through a
+// MySQL migration can leave earlier statements applied.
+func runMigration(database *sql.DB, driver string, migration Migration, up bool) error {
+	script := migration.Up
+	direction := "up"
+	if !up {
+		script = migration.Down
+		direction = "down"
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range splitStatements(script) {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("migration %04d_%s %s: %w", migration.Version, migration.Name, direction, err)
+		}
+	}
+
+	placeholder := "?"
+	namePlaceholder := "?"
+	if driver == "postgres" {
+		placeholder = "$1"
+		namePlaceholder = "$2"
+	}
+
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (`+placeholder+`, `+namePlaceholder+`)`, migration.Version, migration.Name)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = `+placeholder, migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements breaks a script on semicolons that are outside quotes and
+// line comments.
+func splitStatements(script string) []string {
+	statements := make([]string, 0)
+	var current strings.Builder
+	var quote rune
+	inComment := false
+
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inComment:
+			if r == '\n' {
+				inComment = false
+				current.WriteRune(r)
+			}
+			continue
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			inComment = true
+			continue
+		case r == ';':
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+
+	return statements
+}
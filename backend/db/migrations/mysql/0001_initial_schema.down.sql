@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS app_settings;
+
+DROP TABLE IF EXISTS ai_models;
+
+DROP TABLE IF EXISTS ai_providers;
+
+DROP TABLE IF EXISTS straplines;
+
+DROP TABLE IF EXISTS headlines;
+
+DROP TABLE IF EXISTS gaps;
+
+DROP TABLE IF EXISTS facts;
+
+DROP TABLE IF EXISTS articles;
+
+DROP TABLE IF EXISTS topics;
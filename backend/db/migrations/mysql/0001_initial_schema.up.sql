@@ -0,0 +1,130 @@
+CREATE TABLE IF NOT EXISTS articles (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	source_url TEXT,
+	raw_text LONGTEXT,
+	status VARCHAR(50) DEFAULT 'draft',
+	selected_format VARCHAR(50),
+	article_text LONGTEXT,
+	headline_selected TEXT,
+	strapline_selected TEXT,
+	slug TEXT,
+	meta_description TEXT,
+	excerpt TEXT,
+	topic_id BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS excerpt TEXT;
+
+CREATE TABLE IF NOT EXISTS facts (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT,
+	fact_text TEXT,
+	is_confirmed BOOLEAN DEFAULT FALSE,
+	is_included BOOLEAN DEFAULT TRUE,
+	source TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS gaps (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT,
+	question TEXT,
+	is_selected BOOLEAN DEFAULT TRUE,
+	is_resolved BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+ALTER TABLE gaps ADD COLUMN IF NOT EXISTS is_selected BOOLEAN DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS headlines (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT,
+	headline_text TEXT,
+	is_selected BOOLEAN DEFAULT FALSE,
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS straplines (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT,
+	strapline_text TEXT,
+	is_selected BOOLEAN DEFAULT FALSE,
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS topics (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) UNIQUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT IGNORE INTO topics (name) VALUES
+	('Finance'),
+	('Politics'),
+	('Technology'),
+	('Science'),
+	('Sports'),
+	('Other');
+
+CREATE TABLE IF NOT EXISTS ai_providers (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	provider_key VARCHAR(100) NOT NULL UNIQUE,
+	display_name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ai_models (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	provider_id BIGINT NOT NULL,
+	model_key VARCHAR(255) NOT NULL UNIQUE,
+	display_name VARCHAR(255) NOT NULL,
+	is_default BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (provider_id) REFERENCES ai_providers(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS app_settings (
+	id SMALLINT PRIMARY KEY,
+	provider_id BIGINT NOT NULL,
+	model_id BIGINT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	FOREIGN KEY (provider_id) REFERENCES ai_providers(id),
+	FOREIGN KEY (model_id) REFERENCES ai_models(id)
+);
+
+INSERT IGNORE INTO ai_providers (provider_key, display_name) VALUES
+	('openai', 'OpenAI'),
+	('groq', 'Groq');
+
+INSERT IGNORE INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gpt-4o', 'gpt-4o', FALSE FROM ai_providers WHERE provider_key = 'openai';
+
+INSERT IGNORE INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gpt-4o-mini', 'gpt-4o-mini', FALSE FROM ai_providers WHERE provider_key = 'openai';
+
+INSERT IGNORE INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gpt-3.5-turbo', 'gpt-3.5-turbo', FALSE FROM ai_providers WHERE provider_key = 'openai';
+
+INSERT IGNORE INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'llama-3.3-70b-versatile', 'llama-3.3-70b-versatile', TRUE FROM ai_providers WHERE provider_key = 'groq';
+
+INSERT IGNORE INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'mixtral-8x7b-32768', 'mixtral-8x7b-32768', FALSE FROM ai_providers WHERE provider_key = 'groq';
+
+INSERT IGNORE INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gemma2-9b-it', 'gemma2-9b-it', FALSE FROM ai_providers WHERE provider_key = 'groq';
+
+INSERT INTO app_settings (id, provider_id, model_id, created_at, updated_at)
+SELECT 1, p.id, m.id, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM ai_providers p
+JOIN ai_models m ON m.provider_id = p.id
+WHERE p.provider_key = 'groq' AND m.model_key = 'llama-3.3-70b-versatile'
+ON DUPLICATE KEY UPDATE
+	provider_id = VALUES(provider_id),
+	model_id = VALUES(model_id),
+	updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS users;
+
+DROP TABLE IF EXISTS role_permissions;
+
+DROP TABLE IF EXISTS roles;
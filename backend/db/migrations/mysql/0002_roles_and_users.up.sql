@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS roles (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	role_key VARCHAR(100) NOT NULL UNIQUE,
+	display_name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role_id BIGINT NOT NULL,
+	permission VARCHAR(100) NOT NULL,
+	PRIMARY KEY (role_id, permission),
+	FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	email VARCHAR(255) NOT NULL UNIQUE,
+	display_name VARCHAR(255),
+	role_id BIGINT,
+	api_key_hash VARCHAR(64) UNIQUE,
+	is_active BOOLEAN DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	FOREIGN KEY (role_id) REFERENCES roles(id)
+);
+
+INSERT IGNORE INTO roles (role_key, display_name) VALUES
+	('admin', 'Administrator'),
+	('editor', 'Editor'),
+	('viewer', 'Viewer');
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (
+	SELECT 'manage_providers' AS permission
+	UNION ALL SELECT 'manage_prompts'
+	UNION ALL SELECT 'manage_users'
+	UNION ALL SELECT 'publish'
+) p
+WHERE r.role_key = 'admin'
+	AND NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = r.id);
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, 'publish'
+FROM roles r
+WHERE r.role_key = 'editor'
+	AND NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = r.id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS llm_calls;
+
+DELETE FROM role_permissions WHERE permission = 'view_diagnostics';
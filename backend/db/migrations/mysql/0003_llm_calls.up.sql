@@ -0,0 +1,24 @@
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT id, 'view_diagnostics' FROM roles WHERE role_key = 'admin';
+
+CREATE TABLE IF NOT EXISTS llm_calls (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	run_id VARCHAR(64),
+	article_id BIGINT,
+	step VARCHAR(100),
+	provider VARCHAR(100),
+	model VARCHAR(255),
+	json_mode BOOLEAN DEFAULT FALSE,
+	system_prompt LONGTEXT,
+	user_prompt LONGTEXT,
+	response_text LONGTEXT,
+	status VARCHAR(20),
+	error_class VARCHAR(50),
+	error_message TEXT,
+	http_status INT,
+	latency_ms BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_llm_calls_run_id (run_id),
+	INDEX idx_llm_calls_article_id (article_id),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE SET NULL
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS jobs;
+
+DROP TABLE IF EXISTS translations;
+
+DROP TABLE IF EXISTS glossary_terms;
+
+DROP TABLE IF EXISTS glossaries;
@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS glossaries (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	language VARCHAR(100) NOT NULL UNIQUE,
+	version INT NOT NULL DEFAULT 0,
+	style_notes TEXT,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS glossary_terms (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	glossary_id BIGINT NOT NULL,
+	source_term VARCHAR(255) NOT NULL,
+	target_term VARCHAR(255) NOT NULL,
+	notes TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_glossary_terms_source (glossary_id, source_term),
+	FOREIGN KEY (glossary_id) REFERENCES glossaries(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS translations (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT,
+	entity_type VARCHAR(20) NOT NULL,
+	entity_id BIGINT NOT NULL,
+	target_language VARCHAR(100) NOT NULL,
+	source_text LONGTEXT NOT NULL,
+	translated_text LONGTEXT NOT NULL,
+	glossary_version INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	INDEX idx_translations_language_version (target_language, glossary_version),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	job_type VARCHAR(100) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'queued',
+	payload TEXT,
+	result LONGTEXT,
+	error_message TEXT,
+	progress INT NOT NULL DEFAULT 0,
+	total INT NOT NULL DEFAULT 0,
+	created_by BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	started_at TIMESTAMP NULL,
+	finished_at TIMESTAMP NULL,
+	INDEX idx_jobs_status (status, id)
+);
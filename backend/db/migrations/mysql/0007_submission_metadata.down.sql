@@ -0,0 +1,9 @@
+ALTER TABLE articles DROP COLUMN submission_params;
+
+ALTER TABLE articles DROP COLUMN user_agent;
+
+ALTER TABLE articles DROP COLUMN client_ip;
+
+ALTER TABLE articles DROP COLUMN submission_channel;
+
+ALTER TABLE articles DROP COLUMN submitted_by;
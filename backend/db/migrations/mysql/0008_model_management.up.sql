@@ -0,0 +1,9 @@
+ALTER TABLE ai_models ADD COLUMN is_enabled BOOLEAN DEFAULT TRUE;
+
+ALTER TABLE ai_models ADD COLUMN max_tokens INTEGER;
+
+ALTER TABLE ai_models ADD COLUMN input_price_per_million DOUBLE;
+
+ALTER TABLE ai_models ADD COLUMN output_price_per_million DOUBLE;
+
+ALTER TABLE ai_models ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS prompt_templates (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	template_key VARCHAR(100) NOT NULL,
+	version INT NOT NULL,
+	body LONGTEXT NOT NULL,
+	notes TEXT,
+	is_active BOOLEAN NOT NULL DEFAULT FALSE,
+	created_by BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_prompt_templates_version (template_key, version)
+);
+
+CREATE TABLE IF NOT EXISTS article_prompt_versions (
+	article_id BIGINT NOT NULL,
+	template_key VARCHAR(100) NOT NULL,
+	version INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (article_id, template_key),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fact_sources;
+
+DROP TABLE IF EXISTS article_sources;
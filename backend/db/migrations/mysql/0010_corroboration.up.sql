@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS article_sources (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	position INT NOT NULL,
+	source_url TEXT,
+	raw_text LONGTEXT,
+	fact_count INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_article_sources_position (article_id, position),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS fact_sources (
+	fact_id BIGINT NOT NULL,
+	article_source_id BIGINT NOT NULL,
+	PRIMARY KEY (fact_id, article_source_id),
+	FOREIGN KEY (fact_id) REFERENCES facts(id) ON DELETE CASCADE,
+	FOREIGN KEY (article_source_id) REFERENCES article_sources(id) ON DELETE CASCADE
+);
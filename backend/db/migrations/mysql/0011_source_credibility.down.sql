@@ -0,0 +1,7 @@
+DELETE FROM role_permissions WHERE permission = 'manage_sources';
+
+DROP INDEX idx_articles_source_domain ON articles;
+
+ALTER TABLE articles DROP COLUMN source_domain;
+
+DROP TABLE IF EXISTS sources;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS sources (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	domain VARCHAR(255) NOT NULL UNIQUE,
+	name VARCHAR(255),
+	credibility VARCHAR(20) NOT NULL DEFAULT 'unrated',
+	notes TEXT,
+	updated_by BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+ALTER TABLE articles ADD COLUMN source_domain VARCHAR(255);
+
+CREATE INDEX idx_articles_source_domain ON articles (source_domain);
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT id, 'manage_sources' FROM roles WHERE role_key IN ('admin', 'editor');
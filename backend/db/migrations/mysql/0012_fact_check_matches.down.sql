@@ -0,0 +1,3 @@
+ALTER TABLE articles DROP COLUMN fact_checked_at;
+
+DROP TABLE IF EXISTS fact_check_matches;
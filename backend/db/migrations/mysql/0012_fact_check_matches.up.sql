@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS fact_check_matches (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	fact_id BIGINT NOT NULL,
+	claim_text TEXT,
+	claimant VARCHAR(255),
+	claim_date VARCHAR(40),
+	publisher VARCHAR(255),
+	publisher_site VARCHAR(255),
+	review_url TEXT NOT NULL,
+	review_title TEXT,
+	rating VARCHAR(255),
+	language_code VARCHAR(20),
+	review_date VARCHAR(40),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_fact_check_matches_article (article_id, fact_id),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (fact_id) REFERENCES facts(id) ON DELETE CASCADE
+);
+
+ALTER TABLE articles ADD COLUMN fact_checked_at TIMESTAMP NULL;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS article_images (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	position INT NOT NULL,
+	file_name VARCHAR(255),
+	mime_type VARCHAR(50) NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	sha256 CHAR(64) NOT NULL,
+	storage_key VARCHAR(255) NOT NULL,
+	ocr_text LONGTEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_article_images_position (article_id, position),
+	INDEX idx_article_images_sha256 (sha256),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
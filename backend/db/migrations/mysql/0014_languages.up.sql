@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS languages (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	code VARCHAR(10) UNIQUE NOT NULL,
+	name VARCHAR(100) UNIQUE NOT NULL,
+	native_name VARCHAR(100) NOT NULL,
+	script VARCHAR(30) NOT NULL,
+	generate_in_english BOOLEAN DEFAULT true,
+	is_enabled BOOLEAN DEFAULT true,
+	position INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT IGNORE INTO languages (code, name, native_name, script, generate_in_english, position) VALUES
+	('en', 'English', 'English', 'latin', false, 1),
+	('te', 'Telugu', 'తెలుగు', 'telugu', true, 2),
+	('hi', 'Hindi', 'हिन्दी', 'devanagari', true, 3),
+	('ta', 'Tamil', 'தமிழ்', 'tamil', true, 4),
+	('kn', 'Kannada', 'ಕನ್ನಡ', 'kannada', true, 5),
+	('ml', 'Malayalam', 'മലയാളം', 'malayalam', true, 6),
+	('mr', 'Marathi', 'मराठी', 'devanagari', true, 7),
+	('bn', 'Bengali', 'বাংলা', 'bengali', true, 8),
+	('es', 'Spanish', 'Español', 'latin', false, 9);
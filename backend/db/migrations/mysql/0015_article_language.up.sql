@@ -0,0 +1,7 @@
+ALTER TABLE articles ADD COLUMN input_language VARCHAR(10);
+
+ALTER TABLE articles ADD COLUMN input_language_confidence DOUBLE;
+
+ALTER TABLE articles ADD COLUMN language_detection VARCHAR(20);
+
+ALTER TABLE articles ADD COLUMN output_language VARCHAR(100);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS translation_memory (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	source_hash CHAR(64) NOT NULL,
+	target_language VARCHAR(100) NOT NULL,
+	glossary_version INT NOT NULL DEFAULT 0,
+	source_text LONGTEXT NOT NULL,
+	translated_text LONGTEXT NOT NULL,
+	hit_count INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_translation_memory_source (source_hash, target_language, glossary_version)
+);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS analysis_comments (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	parent_id BIGINT,
+	fact_id BIGINT,
+	gap_id BIGINT,
+	author_id BIGINT,
+	body TEXT NOT NULL,
+	resolved_by BIGINT,
+	resolved_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_analysis_comments_article (article_id, parent_id),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (parent_id) REFERENCES analysis_comments(id) ON DELETE CASCADE,
+	FOREIGN KEY (fact_id) REFERENCES facts(id) ON DELETE SET NULL,
+	FOREIGN KEY (gap_id) REFERENCES gaps(id) ON DELETE SET NULL
+);
@@ -0,0 +1,9 @@
+DROP INDEX idx_articles_submitted_by ON articles;
+
+DROP INDEX idx_articles_assigned_to ON articles;
+
+ALTER TABLE articles DROP COLUMN assigned_at;
+
+ALTER TABLE articles DROP COLUMN assigned_by;
+
+ALTER TABLE articles DROP COLUMN assigned_to;
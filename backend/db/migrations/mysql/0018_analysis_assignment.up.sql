@@ -0,0 +1,9 @@
+ALTER TABLE articles ADD COLUMN assigned_to BIGINT;
+
+ALTER TABLE articles ADD COLUMN assigned_by BIGINT;
+
+ALTER TABLE articles ADD COLUMN assigned_at TIMESTAMP NULL;
+
+CREATE INDEX idx_articles_assigned_to ON articles (assigned_to);
+
+CREATE INDEX idx_articles_submitted_by ON articles (submitted_by);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS notifications (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	kind VARCHAR(50) NOT NULL,
+	article_id BIGINT,
+	job_id BIGINT,
+	title VARCHAR(255) NOT NULL,
+	body TEXT,
+	read_at TIMESTAMP NULL,
+	emailed_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_notifications_user (user_id, read_at),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE SET NULL
+);
@@ -0,0 +1,5 @@
+DROP INDEX idx_llm_calls_created_at ON llm_calls;
+
+DROP INDEX idx_articles_completed_at ON articles;
+
+ALTER TABLE articles DROP COLUMN completed_at;
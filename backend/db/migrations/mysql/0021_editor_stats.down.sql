@@ -0,0 +1,3 @@
+DROP INDEX idx_articles_completed_by ON articles;
+
+ALTER TABLE articles DROP COLUMN completed_by;
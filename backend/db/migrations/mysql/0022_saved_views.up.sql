@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS saved_views (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	filters TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_saved_views_name (user_id, name)
+);
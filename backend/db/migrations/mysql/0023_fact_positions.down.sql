@@ -0,0 +1,3 @@
+DROP INDEX idx_facts_article_position ON facts;
+
+ALTER TABLE facts DROP COLUMN position;
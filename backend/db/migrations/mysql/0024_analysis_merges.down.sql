@@ -0,0 +1,3 @@
+DROP INDEX idx_articles_merged_into ON articles;
+
+ALTER TABLE articles DROP COLUMN merged_into;
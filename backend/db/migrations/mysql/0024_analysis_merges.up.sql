@@ -0,0 +1,3 @@
+ALTER TABLE articles ADD COLUMN merged_into BIGINT;
+
+CREATE INDEX idx_articles_merged_into ON articles (merged_into);
@@ -0,0 +1,3 @@
+ALTER TABLE articles DROP COLUMN article_sections;
+
+ALTER TABLE articles DROP COLUMN article_mode;
@@ -0,0 +1,3 @@
+ALTER TABLE articles ADD COLUMN article_mode VARCHAR(20);
+
+ALTER TABLE articles ADD COLUMN article_sections LONGTEXT;
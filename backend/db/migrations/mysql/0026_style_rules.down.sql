@@ -0,0 +1 @@
+DROP TABLE IF EXISTS style_rules;
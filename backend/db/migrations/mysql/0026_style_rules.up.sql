@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS style_rules (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	kind VARCHAR(20) NOT NULL,
+	value VARCHAR(255) NOT NULL,
+	replacement VARCHAR(255),
+	action VARCHAR(10) NOT NULL DEFAULT 'flag',
+	notes TEXT,
+	updated_by BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_style_rules_kind ON style_rules (kind);
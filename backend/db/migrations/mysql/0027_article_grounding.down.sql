@@ -0,0 +1,3 @@
+ALTER TABLE articles DROP COLUMN grounding_checked_at;
+
+DROP TABLE IF EXISTS unsupported_sentences;
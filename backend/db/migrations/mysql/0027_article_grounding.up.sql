@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS unsupported_sentences (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	position INT NOT NULL,
+	sentence TEXT NOT NULL,
+	reason TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_unsupported_sentences_article (article_id, position),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+ALTER TABLE articles ADD COLUMN grounding_checked_at TIMESTAMP NULL;
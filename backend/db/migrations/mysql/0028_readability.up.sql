@@ -0,0 +1,7 @@
+ALTER TABLE articles ADD COLUMN readability_metric VARCHAR(20);
+
+ALTER TABLE articles ADD COLUMN readability_score DOUBLE;
+
+ALTER TABLE articles ADD COLUMN readability_grade DOUBLE;
+
+ALTER TABLE articles ADD COLUMN readability_level VARCHAR(20);
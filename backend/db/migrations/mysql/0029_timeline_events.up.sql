@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS timeline_events (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	position INT NOT NULL DEFAULT 0,
+	event_date VARCHAR(10),
+	date_text VARCHAR(255),
+	event_text TEXT NOT NULL,
+	source_sentence TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_timeline_events_article (article_id, position),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS numeric_claims (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	position INT NOT NULL DEFAULT 0,
+	kind VARCHAR(32) NOT NULL,
+	value DOUBLE,
+	unit VARCHAR(64),
+	claim_date VARCHAR(10),
+	subject VARCHAR(255) NOT NULL,
+	group_label VARCHAR(255),
+	role VARCHAR(16),
+	claim_text TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_numeric_claims_article (article_id, position),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
+
+ALTER TABLE gaps ADD COLUMN origin VARCHAR(32) NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS story_thread_articles;
+DROP TABLE IF EXISTS story_threads;
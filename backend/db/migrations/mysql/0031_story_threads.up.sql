@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS story_threads (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	title VARCHAR(255),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS story_thread_articles (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	thread_id BIGINT NOT NULL,
+	article_id BIGINT NOT NULL UNIQUE,
+	follows_article_id BIGINT NULL,
+	similarity DOUBLE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_story_thread_articles_thread (thread_id),
+	FOREIGN KEY (thread_id) REFERENCES story_threads(id) ON DELETE CASCADE,
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (follows_article_id) REFERENCES articles(id) ON DELETE SET NULL
+);
@@ -0,0 +1,6 @@
+ALTER TABLE articles ADD COLUMN failed_step VARCHAR(32) NULL;
+ALTER TABLE articles ADD COLUMN failure_error TEXT;
+ALTER TABLE articles ADD COLUMN failed_at TIMESTAMP NULL;
+
+-- What a failed run had finished, so a retry can resume from the failed step.
+ALTER TABLE articles ADD COLUMN pipeline_checkpoint LONGTEXT;
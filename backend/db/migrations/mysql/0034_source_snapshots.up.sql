@@ -0,0 +1,19 @@
+-- What each fetched source said at analysis time. The body is stored
+-- gzipped under UPLOAD_DIR by storage_key; sha256 is of the body as received.
+CREATE TABLE IF NOT EXISTS source_snapshots (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	position INT NOT NULL,
+	source_url TEXT NOT NULL,
+	fetched_url TEXT NOT NULL,
+	fetch_strategy VARCHAR(32) NOT NULL,
+	content_type VARCHAR(255),
+	size_bytes BIGINT NOT NULL,
+	sha256 CHAR(64) NOT NULL,
+	storage_key VARCHAR(255) NOT NULL,
+	fetched_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_source_snapshots_position (article_id, position),
+	INDEX idx_source_snapshots_sha256 (sha256),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
@@ -0,0 +1,20 @@
+-- Coverage found after an analysis that may answer one of its open gaps.
+-- url_hash (sha256 of url) keeps a lead from being recorded, and its
+-- editors notified, twice.
+CREATE TABLE IF NOT EXISTS gap_leads (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	gap_id BIGINT NOT NULL,
+	article_id BIGINT NOT NULL,
+	url TEXT NOT NULL,
+	url_hash CHAR(64) NOT NULL,
+	title TEXT NOT NULL,
+	summary TEXT,
+	feed_url TEXT NOT NULL,
+	published_at TIMESTAMP NULL,
+	score DOUBLE NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_gap_leads_url (gap_id, url_hash),
+	INDEX idx_gap_leads_article_id (article_id),
+	FOREIGN KEY (gap_id) REFERENCES gaps(id) ON DELETE CASCADE,
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
@@ -0,0 +1,20 @@
+-- Candidate answers to a gap drafted from web search results, waiting for an
+-- editor to accept or reject them. sources is a JSON array of the results
+-- the answer cites, as {"title": ..., "url": ...}.
+CREATE TABLE IF NOT EXISTS gap_answers (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	gap_id BIGINT NOT NULL,
+	article_id BIGINT NOT NULL,
+	answer TEXT NOT NULL,
+	confidence VARCHAR(16) NOT NULL,
+	sources TEXT NOT NULL,
+	search_query TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	created_by BIGINT,
+	reviewed_by BIGINT,
+	reviewed_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_gap_answers_article_id (article_id),
+	FOREIGN KEY (gap_id) REFERENCES gaps(id) ON DELETE CASCADE,
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+ALTER TABLE articles DROP COLUMN entities_checked_at;
+
+DROP TABLE IF EXISTS article_entities;
+
+DROP TABLE IF EXISTS entities;
@@ -0,0 +1,28 @@
+-- Wikidata lookups, cached by name and language. A name Wikidata had no
+-- match for is kept with a NULL wikidata_id, so it isn't looked up again
+-- until looked_up_at is older than ENTITY_CACHE_TTL.
+CREATE TABLE IF NOT EXISTS entities (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	name_key VARCHAR(255) NOT NULL,
+	language VARCHAR(16) NOT NULL,
+	wikidata_id VARCHAR(32),
+	label TEXT,
+	description TEXT,
+	wikipedia_url TEXT,
+	looked_up_at TIMESTAMP NOT NULL,
+	UNIQUE KEY uniq_entities_name (name_key, language)
+);
+
+CREATE TABLE IF NOT EXISTS article_entities (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	entity_id BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	mentions INT NOT NULL,
+	position INT NOT NULL,
+	UNIQUE KEY uniq_article_entities (article_id, entity_id),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (entity_id) REFERENCES entities(id) ON DELETE CASCADE
+);
+
+ALTER TABLE articles ADD COLUMN entities_checked_at TIMESTAMP NULL;
@@ -0,0 +1,17 @@
+-- Completions kept for LLM_CACHE_TTL, keyed by a hash of the provider,
+-- model, step, prompts and request options.
+CREATE TABLE IF NOT EXISTS llm_cache (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	cache_key CHAR(64) NOT NULL,
+	provider VARCHAR(32) NOT NULL,
+	model VARCHAR(255) NOT NULL,
+	step VARCHAR(64) NOT NULL,
+	response LONGTEXT NOT NULL,
+	hit_count INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL,
+	UNIQUE KEY uniq_llm_cache_key (cache_key),
+	INDEX idx_llm_cache_step (step),
+	INDEX idx_llm_cache_expires (expires_at)
+);
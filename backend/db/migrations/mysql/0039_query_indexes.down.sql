@@ -0,0 +1,7 @@
+DROP INDEX idx_articles_status_assigned ON articles;
+
+DROP INDEX idx_articles_topic_id ON articles;
+
+DROP INDEX idx_articles_created_at ON articles;
+
+DROP INDEX idx_articles_status ON articles;
@@ -0,0 +1,10 @@
+-- facts(article_id) is already served by idx_facts_article_position, and
+-- gaps(article_id) by the index behind its foreign key.
+CREATE INDEX idx_articles_status ON articles (status);
+
+CREATE INDEX idx_articles_created_at ON articles (created_at);
+
+CREATE INDEX idx_articles_topic_id ON articles (topic_id);
+
+-- Covers the dashboard's article counts, which group on status and assignee.
+CREATE INDEX idx_articles_status_assigned ON articles (status, assigned_to);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS edit_history;
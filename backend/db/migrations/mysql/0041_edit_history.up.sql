@@ -0,0 +1,16 @@
+-- The last few editor changes to each fact and gap, for undo and redo.
+-- previous_state and new_state are JSON snapshots of the edited fields;
+-- undone_at is set while an edit is undone and can be redone.
+CREATE TABLE IF NOT EXISTS edit_history (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	entity_type VARCHAR(16) NOT NULL,
+	entity_id BIGINT NOT NULL,
+	article_id BIGINT NOT NULL,
+	previous_state TEXT NOT NULL,
+	new_state TEXT NOT NULL,
+	edited_by BIGINT,
+	undone_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_edit_history_entity (entity_type, entity_id),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
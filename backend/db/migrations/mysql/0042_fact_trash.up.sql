@@ -0,0 +1,2 @@
+-- Deleted facts stay in the trash until restored or the analysis is re-run.
+ALTER TABLE facts ADD COLUMN deleted_at TIMESTAMP NULL;
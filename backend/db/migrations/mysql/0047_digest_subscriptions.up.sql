@@ -0,0 +1,9 @@
+-- Users who get a daily or weekly email of finished analyses. last_sent_at
+-- is when the last digest went out, so each one starts where it ended.
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+	user_id BIGINT PRIMARY KEY,
+	frequency VARCHAR(16) NOT NULL,
+	last_sent_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
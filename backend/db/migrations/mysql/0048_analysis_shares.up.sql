@@ -0,0 +1,16 @@
+-- Links that let someone without an account read an analysis until
+-- expires_at. Only a hash of the link's token is kept, as for API keys.
+CREATE TABLE IF NOT EXISTS analysis_shares (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	token_hash VARCHAR(64) NOT NULL UNIQUE,
+	note TEXT,
+	created_by BIGINT,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP NULL,
+	view_count BIGINT NOT NULL DEFAULT 0,
+	last_viewed_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_analysis_shares_article (article_id),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE
+);
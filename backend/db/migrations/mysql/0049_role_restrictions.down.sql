@@ -0,0 +1,8 @@
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT id, 'publish' FROM roles WHERE role_key = 'editor';
+
+DELETE FROM role_permissions WHERE permission IN ('edit_analyses', 'comment', 'manage_analyses');
+
+DELETE r FROM roles r
+LEFT JOIN users u ON u.role_id = r.id
+WHERE r.role_key = 'reviewer' AND u.id IS NULL;
@@ -0,0 +1,36 @@
+-- Editing analyses and commenting become permissions of their own, and bulk
+-- changes and merges need manage_analyses. Publishing moves to admins only.
+-- Reviewers may read and comment; viewers only read.
+INSERT IGNORE INTO roles (role_key, display_name) VALUES ('reviewer', 'Reviewer');
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (
+	SELECT 'edit_analyses' AS permission
+	UNION ALL SELECT 'comment'
+	UNION ALL SELECT 'manage_analyses'
+) p
+WHERE r.role_key = 'admin';
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (
+	SELECT 'edit_analyses' AS permission
+	UNION ALL SELECT 'comment'
+) p
+WHERE r.role_key = 'editor';
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (
+	SELECT 'comment' AS permission
+	UNION ALL SELECT 'view_source'
+) p
+WHERE r.role_key = 'reviewer';
+
+DELETE rp FROM role_permissions rp
+JOIN roles r ON r.id = rp.role_id
+WHERE rp.permission = 'publish' AND r.role_key = 'editor';
@@ -0,0 +1,20 @@
+-- Signed-in sessions. An access token names its session and is only honoured
+-- while the session is unrevoked, so revoking one ends it at once. Refresh
+-- tokens are single-use: previous_token_hash is the one just replaced, and
+-- presenting it again means it was copied, which revokes the session.
+CREATE TABLE IF NOT EXISTS auth_sessions (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+	previous_token_hash VARCHAR(64),
+	device TEXT,
+	user_agent TEXT,
+	client_ip VARCHAR(64),
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP NULL,
+	last_used_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_auth_sessions_user (user_id),
+	INDEX idx_auth_sessions_previous (previous_token_hash),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
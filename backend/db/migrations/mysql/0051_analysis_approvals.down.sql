@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'approve';
+
+DROP TABLE IF EXISTS analysis_approvals;
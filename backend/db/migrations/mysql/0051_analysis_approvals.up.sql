@@ -0,0 +1,21 @@
+-- Sign-offs on an analysis before it is published. An approval is for the
+-- article version the reviewer read; editing the analysis moves its version
+-- on and leaves the approval behind. Each reviewer holds one approval per
+-- analysis.
+CREATE TABLE IF NOT EXISTS analysis_approvals (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	approved_by BIGINT NOT NULL,
+	version BIGINT NOT NULL,
+	note TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_analysis_approvals_reviewer (article_id, approved_by),
+	INDEX idx_analysis_approvals_article (article_id, version),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (approved_by) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, 'approve'
+FROM roles r
+WHERE r.role_key IN ('admin', 'reviewer');
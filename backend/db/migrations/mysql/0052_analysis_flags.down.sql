@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'clear_flags';
+
+DROP TABLE IF EXISTS analysis_flags;
@@ -0,0 +1,26 @@
+-- Legal and sensitivity flags on an analysis, raised by an editor or by the
+-- automated check. An open flag holds back publishing until a named user
+-- clears it with a note; cleared flags stay as the record of who cleared
+-- what.
+CREATE TABLE IF NOT EXISTS analysis_flags (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	kind VARCHAR(32) NOT NULL,
+	reason TEXT NOT NULL,
+	excerpt TEXT,
+	source VARCHAR(16) NOT NULL DEFAULT 'editor',
+	flagged_by BIGINT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	cleared_by BIGINT NULL,
+	cleared_at TIMESTAMP NULL,
+	clearance_note TEXT,
+	INDEX idx_analysis_flags_article (article_id, cleared_at),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (flagged_by) REFERENCES users(id) ON DELETE SET NULL,
+	FOREIGN KEY (cleared_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, 'clear_flags'
+FROM roles r
+WHERE r.role_key IN ('admin', 'reviewer');
@@ -0,0 +1,14 @@
+-- Corrections to analyses after they were published: what was wrong and
+-- what it should have said. They are printed with the analysis wherever it
+-- goes out, newest last.
+CREATE TABLE IF NOT EXISTS analysis_corrections (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	mistake TEXT NOT NULL,
+	corrected_text TEXT NOT NULL,
+	created_by BIGINT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_analysis_corrections_article (article_id, created_at),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
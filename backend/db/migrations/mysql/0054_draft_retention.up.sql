@@ -0,0 +1,2 @@
+-- When the retention run archived a draft and scrubbed its source material.
+ALTER TABLE articles ADD COLUMN archived_at TIMESTAMP NULL;
@@ -0,0 +1,20 @@
+-- One row per privacy purge: who ran it, why and which analyses went. Only
+-- ids and counts are kept, never anything that was purged.
+CREATE TABLE IF NOT EXISTS privacy_purges (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	purged_by BIGINT NULL,
+	reason TEXT NOT NULL,
+	analysis_ids TEXT NOT NULL,
+	analyses INT NOT NULL DEFAULT 0,
+	llm_calls INT NOT NULL DEFAULT 0,
+	jobs INT NOT NULL DEFAULT 0,
+	files_removed INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_privacy_purges_created_at (created_at),
+	FOREIGN KEY (purged_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT r.id, 'manage_privacy'
+FROM roles r
+WHERE r.role_key = 'admin';
@@ -0,0 +1,25 @@
+-- Click-through figures for headline variants, as reported by the publishing
+-- platform's A/B tests. Each report replaces the previous figures for the same
+-- test and headline. style, word_count and model are worked out when the
+-- result is recorded, so summaries don't depend on the headline still
+-- existing as an option.
+CREATE TABLE IF NOT EXISTS headline_results (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	article_id BIGINT NOT NULL,
+	headline_id BIGINT NULL,
+	test_key VARCHAR(128) NOT NULL DEFAULT '',
+	headline_hash VARCHAR(64) NOT NULL,
+	headline_text TEXT NOT NULL,
+	platform VARCHAR(64),
+	style VARCHAR(16) NOT NULL,
+	word_count INT NOT NULL,
+	model VARCHAR(255),
+	impressions BIGINT NOT NULL DEFAULT 0,
+	clicks BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uniq_headline_results_variant (article_id, test_key, headline_hash),
+	INDEX idx_headline_results_updated_at (updated_at),
+	FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+	FOREIGN KEY (headline_id) REFERENCES headlines(id) ON DELETE SET NULL
+);
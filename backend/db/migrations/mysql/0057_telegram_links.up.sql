@@ -0,0 +1,16 @@
+-- Telegram accounts linked to users, so the bot analyses on someone's behalf.
+-- A link starts as a one-time code the user sends the bot; only the code's
+-- hash is kept, and it is cleared once telegram_user_id is set.
+CREATE TABLE IF NOT EXISTS telegram_links (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	code_hash VARCHAR(64) UNIQUE,
+	code_expires_at TIMESTAMP NULL,
+	telegram_user_id BIGINT UNIQUE,
+	telegram_username VARCHAR(255),
+	language VARCHAR(64),
+	linked_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_telegram_links_user (user_id),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
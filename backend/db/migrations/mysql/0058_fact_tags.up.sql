@@ -0,0 +1,10 @@
+-- Tags on facts, such as statistic or official statement, to structure long
+-- fact lists. The extraction prompt suggests them and editors set them.
+CREATE TABLE IF NOT EXISTS fact_tags (
+	fact_id BIGINT NOT NULL,
+	tag VARCHAR(64) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (fact_id, tag),
+	INDEX idx_fact_tags_tag (tag),
+	FOREIGN KEY (fact_id) REFERENCES facts(id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE articles MODIFY status VARCHAR(50) DEFAULT 'draft';
@@ -0,0 +1,6 @@
+-- Status is compared as the bare column, so idx_articles_status can serve
+-- it; old rows written before statuses were normalized are fixed up here.
+-- updated_at is kept, so the backfill isn't reported as a change.
+UPDATE articles SET status = LOWER(COALESCE(status, 'draft')), updated_at = updated_at WHERE status IS NULL OR BINARY status <> LOWER(status);
+
+ALTER TABLE articles MODIFY status VARCHAR(50) NOT NULL DEFAULT 'draft';
@@ -0,0 +1,3 @@
+-- Running jobs refresh heartbeat_at, so one whose worker died can be told
+-- apart from one still at work and claimed again.
+ALTER TABLE jobs ADD COLUMN heartbeat_at TIMESTAMP NULL;
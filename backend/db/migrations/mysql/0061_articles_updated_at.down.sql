@@ -0,0 +1,2 @@
+-- Nothing to do: articles.updated_at is ON UPDATE CURRENT_TIMESTAMP here
+-- already. The postgres migration of this version adds the same behaviour.
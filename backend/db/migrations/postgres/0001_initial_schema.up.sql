@@ -0,0 +1,164 @@
+CREATE TABLE IF NOT EXISTS articles (
+	id SERIAL PRIMARY KEY,
+	source_url TEXT,
+	raw_text TEXT,
+	status TEXT DEFAULT 'draft',
+	selected_format TEXT,
+	article_text TEXT,
+	headline_selected TEXT,
+	strapline_selected TEXT,
+	slug TEXT,
+	meta_description TEXT,
+	excerpt TEXT,
+	topic_id INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS source_url TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS raw_text TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS status TEXT DEFAULT 'draft';
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS selected_format TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS article_text TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS headline_selected TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS strapline_selected TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS slug TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS meta_description TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS excerpt TEXT;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS topic_id INTEGER;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE articles ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE articles ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE articles ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE articles DROP COLUMN IF EXISTS title;
+
+ALTER TABLE articles DROP COLUMN IF EXISTS content;
+
+ALTER TABLE articles DROP COLUMN IF EXISTS verdict;
+
+CREATE TABLE IF NOT EXISTS facts (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER REFERENCES articles(id) ON DELETE CASCADE,
+	fact_text TEXT,
+	is_confirmed BOOLEAN DEFAULT false,
+	is_included BOOLEAN DEFAULT true,
+	source TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS gaps (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER REFERENCES articles(id) ON DELETE CASCADE,
+	question TEXT,
+	is_selected BOOLEAN DEFAULT true,
+	is_resolved BOOLEAN DEFAULT false,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE gaps ADD COLUMN IF NOT EXISTS is_selected BOOLEAN DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS headlines (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER REFERENCES articles(id) ON DELETE CASCADE,
+	headline_text TEXT,
+	is_selected BOOLEAN DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS straplines (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER REFERENCES articles(id) ON DELETE CASCADE,
+	strapline_text TEXT,
+	is_selected BOOLEAN DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS topics (
+	id SERIAL PRIMARY KEY,
+	name TEXT UNIQUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO topics (name) VALUES
+	('Finance'),
+	('Politics'),
+	('Technology'),
+	('Science'),
+	('Sports'),
+	('Other')
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS ai_providers (
+	id SERIAL PRIMARY KEY,
+	provider_key TEXT UNIQUE NOT NULL,
+	display_name TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ai_models (
+	id SERIAL PRIMARY KEY,
+	provider_id INTEGER REFERENCES ai_providers(id) ON DELETE CASCADE,
+	model_key TEXT UNIQUE NOT NULL,
+	display_name TEXT NOT NULL,
+	is_default BOOLEAN DEFAULT false,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS app_settings (
+	id SMALLINT PRIMARY KEY,
+	provider_id INTEGER REFERENCES ai_providers(id),
+	model_id INTEGER REFERENCES ai_models(id),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO ai_providers (provider_key, display_name) VALUES
+	('openai', 'OpenAI'),
+	('groq', 'Groq')
+ON CONFLICT (provider_key) DO NOTHING;
+
+INSERT INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gpt-4o', 'gpt-4o', false FROM ai_providers WHERE provider_key = 'openai'
+ON CONFLICT (model_key) DO NOTHING;
+
+INSERT INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gpt-4o-mini', 'gpt-4o-mini', false FROM ai_providers WHERE provider_key = 'openai'
+ON CONFLICT (model_key) DO NOTHING;
+
+INSERT INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gpt-3.5-turbo', 'gpt-3.5-turbo', false FROM ai_providers WHERE provider_key = 'openai'
+ON CONFLICT (model_key) DO NOTHING;
+
+INSERT INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'llama-3.3-70b-versatile', 'llama-3.3-70b-versatile', true FROM ai_providers WHERE provider_key = 'groq'
+ON CONFLICT (model_key) DO NOTHING;
+
+INSERT INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'mixtral-8x7b-32768', 'mixtral-8x7b-32768', false FROM ai_providers WHERE provider_key = 'groq'
+ON CONFLICT (model_key) DO NOTHING;
+
+INSERT INTO ai_models (provider_id, model_key, display_name, is_default)
+SELECT id, 'gemma2-9b-it', 'gemma2-9b-it', false FROM ai_providers WHERE provider_key = 'groq'
+ON CONFLICT (model_key) DO NOTHING;
+
+INSERT INTO app_settings (id, provider_id, model_id)
+SELECT 1, p.id, m.id
+FROM ai_providers p
+JOIN ai_models m ON m.provider_id = p.id
+WHERE p.provider_key = 'groq' AND m.model_key = 'llama-3.3-70b-versatile'
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS roles (
+	id SERIAL PRIMARY KEY,
+	role_key TEXT UNIQUE NOT NULL,
+	display_name TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role_id INTEGER REFERENCES roles(id) ON DELETE CASCADE,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	email TEXT UNIQUE NOT NULL,
+	display_name TEXT,
+	role_id INTEGER REFERENCES roles(id),
+	api_key_hash TEXT UNIQUE,
+	is_active BOOLEAN DEFAULT true,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO roles (role_key, display_name) VALUES
+	('admin', 'Administrator'),
+	('editor', 'Editor'),
+	('viewer', 'Viewer')
+ON CONFLICT (role_key) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (VALUES ('manage_providers'), ('manage_prompts'), ('manage_users'), ('publish')) AS p(permission)
+WHERE r.role_key = 'admin'
+	AND NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = r.id)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, 'publish'
+FROM roles r
+WHERE r.role_key = 'editor'
+	AND NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = r.id)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,26 @@
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'view_diagnostics' FROM roles WHERE role_key = 'admin'
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS llm_calls (
+	id SERIAL PRIMARY KEY,
+	run_id TEXT,
+	article_id INTEGER REFERENCES articles(id) ON DELETE SET NULL,
+	step TEXT,
+	provider TEXT,
+	model TEXT,
+	json_mode BOOLEAN DEFAULT false,
+	system_prompt TEXT,
+	user_prompt TEXT,
+	response_text TEXT,
+	status TEXT,
+	error_class TEXT,
+	error_message TEXT,
+	http_status INTEGER,
+	latency_ms BIGINT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_calls_run_id ON llm_calls (run_id);
+
+CREATE INDEX IF NOT EXISTS idx_llm_calls_article_id ON llm_calls (article_id);
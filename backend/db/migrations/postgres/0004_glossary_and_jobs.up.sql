@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS glossaries (
+	id SERIAL PRIMARY KEY,
+	language TEXT UNIQUE NOT NULL,
+	version INTEGER NOT NULL DEFAULT 0,
+	style_notes TEXT,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS glossary_terms (
+	id SERIAL PRIMARY KEY,
+	glossary_id INTEGER NOT NULL REFERENCES glossaries(id) ON DELETE CASCADE,
+	source_term TEXT NOT NULL,
+	target_term TEXT NOT NULL,
+	notes TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (glossary_id, source_term)
+);
+
+CREATE TABLE IF NOT EXISTS translations (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER REFERENCES articles(id) ON DELETE CASCADE,
+	entity_type TEXT NOT NULL,
+	entity_id INTEGER NOT NULL,
+	target_language TEXT NOT NULL,
+	source_text TEXT NOT NULL,
+	translated_text TEXT NOT NULL,
+	glossary_version INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_translations_language_version ON translations (target_language, glossary_version);
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id SERIAL PRIMARY KEY,
+	job_type TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	payload TEXT,
+	result TEXT,
+	error_message TEXT,
+	progress INTEGER NOT NULL DEFAULT 0,
+	total INTEGER NOT NULL DEFAULT 0,
+	created_by INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	started_at TIMESTAMP,
+	finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status, id);
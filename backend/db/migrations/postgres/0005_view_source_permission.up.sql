@@ -0,0 +1,3 @@
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'view_source' FROM roles WHERE role_key IN ('admin', 'editor')
+ON CONFLICT DO NOTHING;
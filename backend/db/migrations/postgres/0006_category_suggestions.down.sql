@@ -0,0 +1,3 @@
+ALTER TABLE articles DROP COLUMN category_suggestion_source;
+
+ALTER TABLE articles DROP COLUMN suggested_topic_id;
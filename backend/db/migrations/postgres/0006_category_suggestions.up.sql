@@ -0,0 +1,3 @@
+ALTER TABLE articles ADD COLUMN suggested_topic_id INTEGER;
+
+ALTER TABLE articles ADD COLUMN category_suggestion_source TEXT;
@@ -0,0 +1,9 @@
+ALTER TABLE articles ADD COLUMN submitted_by INTEGER;
+
+ALTER TABLE articles ADD COLUMN submission_channel TEXT;
+
+ALTER TABLE articles ADD COLUMN client_ip TEXT;
+
+ALTER TABLE articles ADD COLUMN user_agent TEXT;
+
+ALTER TABLE articles ADD COLUMN submission_params TEXT;
@@ -0,0 +1,9 @@
+ALTER TABLE ai_models DROP COLUMN updated_at;
+
+ALTER TABLE ai_models DROP COLUMN output_price_per_million;
+
+ALTER TABLE ai_models DROP COLUMN input_price_per_million;
+
+ALTER TABLE ai_models DROP COLUMN max_tokens;
+
+ALTER TABLE ai_models DROP COLUMN is_enabled;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS article_prompt_versions;
+
+DROP TABLE IF EXISTS prompt_templates;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS prompt_templates (
+	id SERIAL PRIMARY KEY,
+	template_key TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	body TEXT NOT NULL,
+	notes TEXT,
+	is_active BOOLEAN NOT NULL DEFAULT false,
+	created_by INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (template_key, version)
+);
+
+CREATE TABLE IF NOT EXISTS article_prompt_versions (
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	template_key TEXT NOT NULL,
+	version INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (article_id, template_key)
+);
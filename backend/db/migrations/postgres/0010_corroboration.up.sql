@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS article_sources (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	source_url TEXT,
+	raw_text TEXT,
+	fact_count INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (article_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS fact_sources (
+	fact_id INTEGER NOT NULL REFERENCES facts(id) ON DELETE CASCADE,
+	article_source_id INTEGER NOT NULL REFERENCES article_sources(id) ON DELETE CASCADE,
+	PRIMARY KEY (fact_id, article_source_id)
+);
@@ -0,0 +1,7 @@
+DELETE FROM role_permissions WHERE permission = 'manage_sources';
+
+DROP INDEX IF EXISTS idx_articles_source_domain;
+
+ALTER TABLE articles DROP COLUMN source_domain;
+
+DROP TABLE IF EXISTS sources;
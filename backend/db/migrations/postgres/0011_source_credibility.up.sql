@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS sources (
+	id SERIAL PRIMARY KEY,
+	domain TEXT UNIQUE NOT NULL,
+	name TEXT,
+	credibility TEXT NOT NULL DEFAULT 'unrated',
+	notes TEXT,
+	updated_by INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE articles ADD COLUMN source_domain TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_articles_source_domain ON articles (source_domain);
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'manage_sources' FROM roles WHERE role_key IN ('admin', 'editor')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS fact_check_matches (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	fact_id INTEGER NOT NULL REFERENCES facts(id) ON DELETE CASCADE,
+	claim_text TEXT,
+	claimant TEXT,
+	claim_date TEXT,
+	publisher TEXT,
+	publisher_site TEXT,
+	review_url TEXT NOT NULL,
+	review_title TEXT,
+	rating TEXT,
+	language_code TEXT,
+	review_date TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fact_check_matches_article ON fact_check_matches (article_id, fact_id);
+
+ALTER TABLE articles ADD COLUMN fact_checked_at TIMESTAMP;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS article_images (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	file_name TEXT,
+	mime_type TEXT NOT NULL,
+	size_bytes BIGINT NOT NULL,
+	sha256 TEXT NOT NULL,
+	storage_key TEXT NOT NULL,
+	ocr_text TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (article_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_images_sha256 ON article_images (sha256);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS languages (
+	id SERIAL PRIMARY KEY,
+	code TEXT UNIQUE NOT NULL,
+	name TEXT UNIQUE NOT NULL,
+	native_name TEXT NOT NULL,
+	script TEXT NOT NULL,
+	generate_in_english BOOLEAN DEFAULT true,
+	is_enabled BOOLEAN DEFAULT true,
+	position INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO languages (code, name, native_name, script, generate_in_english, position) VALUES
+	('en', 'English', 'English', 'latin', false, 1),
+	('te', 'Telugu', 'తెలుగు', 'telugu', true, 2),
+	('hi', 'Hindi', 'हिन्दी', 'devanagari', true, 3),
+	('ta', 'Tamil', 'தமிழ்', 'tamil', true, 4),
+	('kn', 'Kannada', 'ಕನ್ನಡ', 'kannada', true, 5),
+	('ml', 'Malayalam', 'മലയാളം', 'malayalam', true, 6),
+	('mr', 'Marathi', 'मराठी', 'devanagari', true, 7),
+	('bn', 'Bengali', 'বাংলা', 'bengali', true, 8),
+	('es', 'Spanish', 'Español', 'latin', false, 9)
+ON CONFLICT (code) DO NOTHING;
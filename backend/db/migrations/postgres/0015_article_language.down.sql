@@ -0,0 +1,7 @@
+ALTER TABLE articles DROP COLUMN output_language;
+
+ALTER TABLE articles DROP COLUMN language_detection;
+
+ALTER TABLE articles DROP COLUMN input_language_confidence;
+
+ALTER TABLE articles DROP COLUMN input_language;
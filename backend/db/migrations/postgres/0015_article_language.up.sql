@@ -0,0 +1,7 @@
+ALTER TABLE articles ADD COLUMN input_language TEXT;
+
+ALTER TABLE articles ADD COLUMN input_language_confidence DOUBLE PRECISION;
+
+ALTER TABLE articles ADD COLUMN language_detection TEXT;
+
+ALTER TABLE articles ADD COLUMN output_language TEXT;
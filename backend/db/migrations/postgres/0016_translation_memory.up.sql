@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS translation_memory (
+	id SERIAL PRIMARY KEY,
+	source_hash TEXT NOT NULL,
+	target_language TEXT NOT NULL,
+	glossary_version INTEGER NOT NULL DEFAULT 0,
+	source_text TEXT NOT NULL,
+	translated_text TEXT NOT NULL,
+	hit_count INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (source_hash, target_language, glossary_version)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS analysis_comments (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	parent_id INTEGER REFERENCES analysis_comments(id) ON DELETE CASCADE,
+	fact_id INTEGER REFERENCES facts(id) ON DELETE SET NULL,
+	gap_id INTEGER REFERENCES gaps(id) ON DELETE SET NULL,
+	author_id INTEGER,
+	body TEXT NOT NULL,
+	resolved_by INTEGER,
+	resolved_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_comments_article ON analysis_comments (article_id, parent_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_articles_submitted_by;
+
+DROP INDEX IF EXISTS idx_articles_assigned_to;
+
+ALTER TABLE articles DROP COLUMN assigned_at;
+
+ALTER TABLE articles DROP COLUMN assigned_by;
+
+ALTER TABLE articles DROP COLUMN assigned_to;
@@ -0,0 +1,9 @@
+ALTER TABLE articles ADD COLUMN assigned_to INTEGER;
+
+ALTER TABLE articles ADD COLUMN assigned_by INTEGER;
+
+ALTER TABLE articles ADD COLUMN assigned_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_articles_assigned_to ON articles (assigned_to);
+
+CREATE INDEX IF NOT EXISTS idx_articles_submitted_by ON articles (submitted_by);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS notifications (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	article_id INTEGER REFERENCES articles(id) ON DELETE CASCADE,
+	job_id INTEGER REFERENCES jobs(id) ON DELETE SET NULL,
+	title TEXT NOT NULL,
+	body TEXT,
+	read_at TIMESTAMP,
+	emailed_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, read_at);
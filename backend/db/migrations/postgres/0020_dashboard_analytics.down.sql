@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_llm_calls_created_at;
+
+DROP INDEX IF EXISTS idx_articles_completed_at;
+
+ALTER TABLE articles DROP COLUMN completed_at;
@@ -0,0 +1,7 @@
+ALTER TABLE articles ADD COLUMN completed_at TIMESTAMP;
+
+UPDATE articles SET completed_at = COALESCE(updated_at, created_at) WHERE LOWER(COALESCE(status, 'draft')) = 'completed';
+
+CREATE INDEX IF NOT EXISTS idx_articles_completed_at ON articles (completed_at);
+
+CREATE INDEX IF NOT EXISTS idx_llm_calls_created_at ON llm_calls (created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_articles_completed_by;
+
+ALTER TABLE articles DROP COLUMN completed_by;
@@ -0,0 +1,3 @@
+ALTER TABLE articles ADD COLUMN completed_by INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_articles_completed_by ON articles (completed_by);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_facts_article_position;
+
+ALTER TABLE facts DROP COLUMN position;
@@ -0,0 +1,3 @@
+ALTER TABLE facts ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_facts_article_position ON facts (article_id, position);
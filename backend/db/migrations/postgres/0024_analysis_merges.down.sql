@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_articles_merged_into;
+
+ALTER TABLE articles DROP COLUMN merged_into;
@@ -0,0 +1,3 @@
+ALTER TABLE articles ADD COLUMN article_mode TEXT;
+
+ALTER TABLE articles ADD COLUMN article_sections TEXT;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS style_rules (
+	id SERIAL PRIMARY KEY,
+	kind TEXT NOT NULL,
+	value TEXT NOT NULL,
+	replacement TEXT,
+	action TEXT NOT NULL DEFAULT 'flag',
+	notes TEXT,
+	updated_by INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_style_rules_kind ON style_rules (kind);
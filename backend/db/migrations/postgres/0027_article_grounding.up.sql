@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS unsupported_sentences (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	sentence TEXT NOT NULL,
+	reason TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_unsupported_sentences_article ON unsupported_sentences (article_id, position);
+
+ALTER TABLE articles ADD COLUMN grounding_checked_at TIMESTAMP;
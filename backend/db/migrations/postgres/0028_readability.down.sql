@@ -0,0 +1,7 @@
+ALTER TABLE articles DROP COLUMN readability_level;
+
+ALTER TABLE articles DROP COLUMN readability_grade;
+
+ALTER TABLE articles DROP COLUMN readability_score;
+
+ALTER TABLE articles DROP COLUMN readability_metric;
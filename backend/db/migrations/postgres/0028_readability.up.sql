@@ -0,0 +1,7 @@
+ALTER TABLE articles ADD COLUMN readability_metric TEXT;
+
+ALTER TABLE articles ADD COLUMN readability_score DOUBLE PRECISION;
+
+ALTER TABLE articles ADD COLUMN readability_grade DOUBLE PRECISION;
+
+ALTER TABLE articles ADD COLUMN readability_level TEXT;
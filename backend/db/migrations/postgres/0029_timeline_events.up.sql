@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS timeline_events (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL DEFAULT 0,
+	event_date TEXT,
+	date_text TEXT,
+	event_text TEXT NOT NULL,
+	source_sentence TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_timeline_events_article ON timeline_events (article_id, position);
@@ -0,0 +1,3 @@
+ALTER TABLE gaps DROP COLUMN origin;
+
+DROP TABLE IF EXISTS numeric_claims;
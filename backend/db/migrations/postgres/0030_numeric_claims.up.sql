@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS numeric_claims (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL DEFAULT 0,
+	kind TEXT NOT NULL,
+	value DOUBLE PRECISION,
+	unit TEXT,
+	claim_date TEXT,
+	subject TEXT NOT NULL,
+	group_label TEXT,
+	role TEXT,
+	claim_text TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_numeric_claims_article ON numeric_claims (article_id, position);
+
+ALTER TABLE gaps ADD COLUMN IF NOT EXISTS origin TEXT;
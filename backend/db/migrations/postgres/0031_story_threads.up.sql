@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS story_threads (
+	id SERIAL PRIMARY KEY,
+	title TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS story_thread_articles (
+	id SERIAL PRIMARY KEY,
+	thread_id INTEGER NOT NULL REFERENCES story_threads(id) ON DELETE CASCADE,
+	article_id INTEGER NOT NULL UNIQUE REFERENCES articles(id) ON DELETE CASCADE,
+	follows_article_id INTEGER REFERENCES articles(id) ON DELETE SET NULL,
+	similarity DOUBLE PRECISION,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_story_thread_articles_thread ON story_thread_articles (thread_id);
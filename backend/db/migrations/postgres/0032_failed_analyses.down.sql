@@ -0,0 +1,5 @@
+ALTER TABLE articles DROP COLUMN pipeline_checkpoint;
+
+ALTER TABLE articles DROP COLUMN failed_at;
+ALTER TABLE articles DROP COLUMN failure_error;
+ALTER TABLE articles DROP COLUMN failed_step;
@@ -0,0 +1,6 @@
+ALTER TABLE articles ADD COLUMN failed_step TEXT;
+ALTER TABLE articles ADD COLUMN failure_error TEXT;
+ALTER TABLE articles ADD COLUMN failed_at TIMESTAMP;
+
+-- What a failed run had finished, so a retry can resume from the failed step.
+ALTER TABLE articles ADD COLUMN pipeline_checkpoint TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE article_sources DROP COLUMN fetch_strategy;
+ALTER TABLE articles DROP COLUMN fetch_strategy;
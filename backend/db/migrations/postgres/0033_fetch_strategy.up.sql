@@ -0,0 +1,3 @@
+-- How a submitted URL's text was obtained: direct, amp, google_cache or archive.
+ALTER TABLE articles ADD COLUMN fetch_strategy VARCHAR(32);
+ALTER TABLE article_sources ADD COLUMN fetch_strategy VARCHAR(32);
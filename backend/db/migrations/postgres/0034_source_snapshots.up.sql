@@ -0,0 +1,19 @@
+-- What each fetched source said at analysis time. The body is stored
+-- gzipped under UPLOAD_DIR by storage_key; sha256 is of the body as received.
+CREATE TABLE IF NOT EXISTS source_snapshots (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	source_url TEXT NOT NULL,
+	fetched_url TEXT NOT NULL,
+	fetch_strategy VARCHAR(32) NOT NULL,
+	content_type TEXT,
+	size_bytes BIGINT NOT NULL,
+	sha256 TEXT NOT NULL,
+	storage_key TEXT NOT NULL,
+	fetched_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (article_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_source_snapshots_sha256 ON source_snapshots (sha256);
@@ -0,0 +1,19 @@
+-- Coverage found after an analysis that may answer one of its open gaps.
+-- url_hash (sha256 of url) keeps a lead from being recorded, and its
+-- editors notified, twice.
+CREATE TABLE IF NOT EXISTS gap_leads (
+	id SERIAL PRIMARY KEY,
+	gap_id INTEGER NOT NULL REFERENCES gaps(id) ON DELETE CASCADE,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	url_hash TEXT NOT NULL,
+	title TEXT NOT NULL,
+	summary TEXT,
+	feed_url TEXT NOT NULL,
+	published_at TIMESTAMP,
+	score DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (gap_id, url_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_gap_leads_article_id ON gap_leads (article_id);
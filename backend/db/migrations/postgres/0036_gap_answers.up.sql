@@ -0,0 +1,19 @@
+-- Candidate answers to a gap drafted from web search results, waiting for an
+-- editor to accept or reject them. sources is a JSON array of the results
+-- the answer cites, as {"title": ..., "url": ...}.
+CREATE TABLE IF NOT EXISTS gap_answers (
+	id SERIAL PRIMARY KEY,
+	gap_id INTEGER NOT NULL REFERENCES gaps(id) ON DELETE CASCADE,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	answer TEXT NOT NULL,
+	confidence VARCHAR(16) NOT NULL,
+	sources TEXT NOT NULL,
+	search_query TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	created_by INTEGER,
+	reviewed_by INTEGER,
+	reviewed_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_gap_answers_article_id ON gap_answers (article_id);
@@ -0,0 +1,26 @@
+-- Wikidata lookups, cached by name and language. A name Wikidata had no
+-- match for is kept with a NULL wikidata_id, so it isn't looked up again
+-- until looked_up_at is older than ENTITY_CACHE_TTL.
+CREATE TABLE IF NOT EXISTS entities (
+	id SERIAL PRIMARY KEY,
+	name_key VARCHAR(255) NOT NULL,
+	language VARCHAR(16) NOT NULL,
+	wikidata_id VARCHAR(32),
+	label TEXT,
+	description TEXT,
+	wikipedia_url TEXT,
+	looked_up_at TIMESTAMP NOT NULL,
+	UNIQUE (name_key, language)
+);
+
+CREATE TABLE IF NOT EXISTS article_entities (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	entity_id INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	mentions INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	UNIQUE (article_id, entity_id)
+);
+
+ALTER TABLE articles ADD COLUMN entities_checked_at TIMESTAMP;
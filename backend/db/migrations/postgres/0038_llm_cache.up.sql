@@ -0,0 +1,17 @@
+-- Completions kept for LLM_CACHE_TTL, keyed by a hash of the provider,
+-- model, step, prompts and request options.
+CREATE TABLE IF NOT EXISTS llm_cache (
+	id SERIAL PRIMARY KEY,
+	cache_key CHAR(64) NOT NULL UNIQUE,
+	provider VARCHAR(32) NOT NULL,
+	model VARCHAR(255) NOT NULL,
+	step VARCHAR(64) NOT NULL,
+	response TEXT NOT NULL,
+	hit_count INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_llm_cache_step ON llm_cache (step);
+CREATE INDEX IF NOT EXISTS idx_llm_cache_expires ON llm_cache (expires_at);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_gaps_article_id;
+
+DROP INDEX IF EXISTS idx_articles_status_assigned;
+
+DROP INDEX IF EXISTS idx_articles_topic_id;
+
+DROP INDEX IF EXISTS idx_articles_created_at;
+
+DROP INDEX IF EXISTS idx_articles_status;
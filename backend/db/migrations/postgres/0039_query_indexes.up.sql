@@ -0,0 +1,11 @@
+-- facts(article_id) is already served by idx_facts_article_position.
+CREATE INDEX IF NOT EXISTS idx_articles_status ON articles (status);
+
+CREATE INDEX IF NOT EXISTS idx_articles_created_at ON articles (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_articles_topic_id ON articles (topic_id);
+
+-- Covers the dashboard's article counts, which group on status and assignee.
+CREATE INDEX IF NOT EXISTS idx_articles_status_assigned ON articles (status, assigned_to);
+
+CREATE INDEX IF NOT EXISTS idx_gaps_article_id ON gaps (article_id);
@@ -0,0 +1,5 @@
+ALTER TABLE gaps DROP COLUMN version;
+
+ALTER TABLE facts DROP COLUMN version;
+
+ALTER TABLE articles DROP COLUMN version;
@@ -0,0 +1,6 @@
+-- Bumped on every editor change; a PATCH names the version it was based on.
+ALTER TABLE articles ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE facts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE gaps ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
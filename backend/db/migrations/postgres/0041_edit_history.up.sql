@@ -0,0 +1,16 @@
+-- The last few editor changes to each fact and gap, for undo and redo.
+-- previous_state and new_state are JSON snapshots of the edited fields;
+-- undone_at is set while an edit is undone and can be redone.
+CREATE TABLE IF NOT EXISTS edit_history (
+	id SERIAL PRIMARY KEY,
+	entity_type VARCHAR(16) NOT NULL,
+	entity_id INTEGER NOT NULL,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	previous_state TEXT NOT NULL,
+	new_state TEXT NOT NULL,
+	edited_by INTEGER,
+	undone_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_edit_history_entity ON edit_history (entity_type, entity_id);
@@ -0,0 +1 @@
+ALTER TABLE facts DROP COLUMN deleted_at;
@@ -0,0 +1,10 @@
+-- API keys entered in settings, sealed with AES-GCM under PROVIDER_KEY_SECRET.
+-- A stored key takes precedence over the provider's environment variable;
+-- key_hint keeps the masked form so listing never decrypts.
+CREATE TABLE IF NOT EXISTS provider_api_keys (
+	provider_id INTEGER PRIMARY KEY REFERENCES ai_providers(id) ON DELETE CASCADE,
+	encrypted_key TEXT NOT NULL,
+	key_hint VARCHAR(32) NOT NULL,
+	updated_by INTEGER,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
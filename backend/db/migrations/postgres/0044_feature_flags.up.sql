@@ -0,0 +1,9 @@
+-- Runtime overrides of the feature flags; flags without a row use
+-- FEATURE_FLAGS or their built-in default.
+CREATE TABLE IF NOT EXISTS feature_flags (
+	flag_key VARCHAR(64) PRIMARY KEY,
+	enabled BOOLEAN NOT NULL,
+	rollout_percent INTEGER NOT NULL DEFAULT 100,
+	updated_by INTEGER,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
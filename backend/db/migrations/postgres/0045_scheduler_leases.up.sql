@@ -0,0 +1,8 @@
+-- One row per scheduled task; only the instance holding an unexpired lease
+-- runs the task.
+CREATE TABLE IF NOT EXISTS scheduler_leases (
+	task_name VARCHAR(128) PRIMARY KEY,
+	holder VARCHAR(255) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	acquired_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+ALTER TABLE sources DROP COLUMN skip_screening;
@@ -0,0 +1,2 @@
+-- Registry domains whose submissions skip content screening.
+ALTER TABLE sources ADD COLUMN skip_screening BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,16 @@
+-- Links that let someone without an account read an analysis until
+-- expires_at. Only a hash of the link's token is kept, as for API keys.
+CREATE TABLE IF NOT EXISTS analysis_shares (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	token_hash VARCHAR(64) NOT NULL UNIQUE,
+	note TEXT,
+	created_by INTEGER,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP,
+	view_count INTEGER NOT NULL DEFAULT 0,
+	last_viewed_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_shares_article ON analysis_shares (article_id);
@@ -0,0 +1,7 @@
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'publish' FROM roles WHERE role_key = 'editor'
+ON CONFLICT DO NOTHING;
+
+DELETE FROM role_permissions WHERE permission IN ('edit_analyses', 'comment', 'manage_analyses');
+
+DELETE FROM roles WHERE role_key = 'reviewer' AND NOT EXISTS (SELECT 1 FROM users WHERE users.role_id = roles.id);
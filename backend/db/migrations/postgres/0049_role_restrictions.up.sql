@@ -0,0 +1,29 @@
+-- Editing analyses and commenting become permissions of their own, and bulk
+-- changes and merges need manage_analyses. Publishing moves to admins only.
+-- Reviewers may read and comment; viewers only read.
+INSERT INTO roles (role_key, display_name) VALUES ('reviewer', 'Reviewer')
+ON CONFLICT (role_key) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (VALUES ('edit_analyses'), ('comment'), ('manage_analyses')) AS p(permission)
+WHERE r.role_key = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (VALUES ('edit_analyses'), ('comment')) AS p(permission)
+WHERE r.role_key = 'editor'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (VALUES ('comment'), ('view_source')) AS p(permission)
+WHERE r.role_key = 'reviewer'
+ON CONFLICT DO NOTHING;
+
+DELETE FROM role_permissions
+WHERE permission = 'publish' AND role_id IN (SELECT id FROM roles WHERE role_key = 'editor');
@@ -0,0 +1,21 @@
+-- Signed-in sessions. An access token names its session and is only honoured
+-- while the session is unrevoked, so revoking one ends it at once. Refresh
+-- tokens are single-use: previous_token_hash is the one just replaced, and
+-- presenting it again means it was copied, which revokes the session.
+CREATE TABLE IF NOT EXISTS auth_sessions (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+	previous_token_hash VARCHAR(64),
+	device TEXT,
+	user_agent TEXT,
+	client_ip VARCHAR(64),
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP,
+	last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user ON auth_sessions (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_previous ON auth_sessions (previous_token_hash);
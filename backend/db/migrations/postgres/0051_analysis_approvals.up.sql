@@ -0,0 +1,21 @@
+-- Sign-offs on an analysis before it is published. An approval is for the
+-- article version the reviewer read; editing the analysis moves its version
+-- on and leaves the approval behind. Each reviewer holds one approval per
+-- analysis.
+CREATE TABLE IF NOT EXISTS analysis_approvals (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	approved_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	version INTEGER NOT NULL,
+	note TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (article_id, approved_by)
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_approvals_article ON analysis_approvals (article_id, version);
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, 'approve'
+FROM roles r
+WHERE r.role_key IN ('admin', 'reviewer')
+ON CONFLICT DO NOTHING;
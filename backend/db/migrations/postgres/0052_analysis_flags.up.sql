@@ -0,0 +1,25 @@
+-- Legal and sensitivity flags on an analysis, raised by an editor or by the
+-- automated check. An open flag holds back publishing until a named user
+-- clears it with a note; cleared flags stay as the record of who cleared
+-- what.
+CREATE TABLE IF NOT EXISTS analysis_flags (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	kind VARCHAR(32) NOT NULL,
+	reason TEXT NOT NULL,
+	excerpt TEXT,
+	source VARCHAR(16) NOT NULL DEFAULT 'editor',
+	flagged_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	cleared_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	cleared_at TIMESTAMP,
+	clearance_note TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_flags_article ON analysis_flags (article_id, cleared_at);
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, 'clear_flags'
+FROM roles r
+WHERE r.role_key IN ('admin', 'reviewer')
+ON CONFLICT DO NOTHING;
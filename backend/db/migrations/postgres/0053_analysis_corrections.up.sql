@@ -0,0 +1,13 @@
+-- Corrections to analyses after they were published: what was wrong and
+-- what it should have said. They are printed with the analysis wherever it
+-- goes out, newest last.
+CREATE TABLE IF NOT EXISTS analysis_corrections (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	mistake TEXT NOT NULL,
+	corrected_text TEXT NOT NULL,
+	created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_corrections_article ON analysis_corrections (article_id, created_at);
@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'manage_privacy';
+
+DROP TABLE IF EXISTS privacy_purges;
@@ -0,0 +1,21 @@
+-- One row per privacy purge: who ran it, why and which analyses went. Only
+-- ids and counts are kept, never anything that was purged.
+CREATE TABLE IF NOT EXISTS privacy_purges (
+	id SERIAL PRIMARY KEY,
+	purged_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	reason TEXT NOT NULL,
+	analysis_ids TEXT NOT NULL,
+	analyses INTEGER NOT NULL DEFAULT 0,
+	llm_calls INTEGER NOT NULL DEFAULT 0,
+	jobs INTEGER NOT NULL DEFAULT 0,
+	files_removed INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_privacy_purges_created_at ON privacy_purges (created_at);
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, 'manage_privacy'
+FROM roles r
+WHERE r.role_key = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,24 @@
+-- Click-through figures for headline variants, as reported by the publishing
+-- platform's A/B tests. Each report replaces the previous figures for the same
+-- test and headline. style, word_count and model are worked out when the
+-- result is recorded, so summaries don't depend on the headline still
+-- existing as an option.
+CREATE TABLE IF NOT EXISTS headline_results (
+	id SERIAL PRIMARY KEY,
+	article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+	headline_id INTEGER REFERENCES headlines(id) ON DELETE SET NULL,
+	test_key VARCHAR(128) NOT NULL DEFAULT '',
+	headline_hash VARCHAR(64) NOT NULL,
+	headline_text TEXT NOT NULL,
+	platform VARCHAR(64),
+	style VARCHAR(16) NOT NULL,
+	word_count INTEGER NOT NULL,
+	model TEXT,
+	impressions BIGINT NOT NULL DEFAULT 0,
+	clicks BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (article_id, test_key, headline_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_headline_results_updated_at ON headline_results (updated_at);
@@ -0,0 +1,16 @@
+-- Telegram accounts linked to users, so the bot analyses on someone's behalf.
+-- A link starts as a one-time code the user sends the bot; only the code's
+-- hash is kept, and it is cleared once telegram_user_id is set.
+CREATE TABLE IF NOT EXISTS telegram_links (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code_hash VARCHAR(64) UNIQUE,
+	code_expires_at TIMESTAMP,
+	telegram_user_id BIGINT UNIQUE,
+	telegram_username TEXT,
+	language TEXT,
+	linked_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_telegram_links_user ON telegram_links (user_id);
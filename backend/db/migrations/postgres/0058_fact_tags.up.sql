@@ -0,0 +1,10 @@
+-- Tags on facts, such as statistic or official statement, to structure long
+-- fact lists. The extraction prompt suggests them and editors set them.
+CREATE TABLE IF NOT EXISTS fact_tags (
+	fact_id INTEGER NOT NULL REFERENCES facts(id) ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (fact_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fact_tags_tag ON fact_tags (tag);
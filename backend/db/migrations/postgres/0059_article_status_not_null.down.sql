@@ -0,0 +1 @@
+ALTER TABLE articles ALTER COLUMN status DROP NOT NULL;
@@ -0,0 +1,6 @@
+-- Status is compared as the bare column, so idx_articles_status can serve
+-- it; old rows written before statuses were normalized are fixed up here.
+UPDATE articles SET status = LOWER(COALESCE(status, 'draft')) WHERE status IS NULL OR status <> LOWER(status);
+
+ALTER TABLE articles ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE articles ALTER COLUMN status SET NOT NULL;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS articles_touch_updated_at ON articles;
+
+DROP FUNCTION IF EXISTS touch_articles_updated_at();
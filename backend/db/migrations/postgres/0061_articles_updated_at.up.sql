@@ -0,0 +1,18 @@
+-- Keeps articles.updated_at current on every change, as ON UPDATE
+-- CURRENT_TIMESTAMP does on MySQL, so the changed-analyses feed and the
+-- retention run see the same edits on both drivers. As there, an UPDATE
+-- that sets updated_at itself keeps the value it gave.
+CREATE OR REPLACE FUNCTION touch_articles_updated_at() RETURNS trigger AS '
+BEGIN
+	IF NEW IS DISTINCT FROM OLD AND NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at THEN
+		NEW.updated_at = CURRENT_TIMESTAMP;
+	END IF;
+	RETURN NEW;
+END;
+' LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS articles_touch_updated_at ON articles;
+
+CREATE TRIGGER articles_touch_updated_at
+	BEFORE UPDATE ON articles
+	FOR EACH ROW EXECUTE PROCEDURE touch_articles_updated_at();
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+type PoolStats struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64  `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+}
+
+// ConfigurePool applies pool limits. Zero values leave the database/sql
+// defaults in place (unlimited open connections, no lifetime).
+func ConfigurePool(database *sql.DB, settings PoolSettings) {
+	database.SetMaxOpenConns(settings.MaxOpenConns)
+	database.SetMaxIdleConns(settings.MaxIdleConns)
+	database.SetConnMaxLifetime(settings.ConnMaxLifetime)
+	database.SetConnMaxIdleTime(settings.ConnMaxIdleTime)
+}
+
+func Stats(database *sql.DB) PoolStats {
+	stats := database.Stats()
+	return PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+// Ping checks the database within timeout and reports how long it took.
+func Ping(ctx context.Context, database *sql.DB, timeout time.Duration) (time.Duration, error) {
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := database.PingContext(pingCtx)
+	return time.Since(started), err
+}
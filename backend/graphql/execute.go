@@ -0,0 +1,615 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type Result struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is a request or field error. Path leads to the field that failed;
+// it is empty when the request was rejected before execution.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// OrderedMap is a response object. Fields are encoded in the order the query
+// selected them.
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *OrderedMap) set(key string, value any) {
+	if m.values == nil {
+		m.values = map[string]any{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of a response key.
+func (m *OrderedMap) Get(key string) (any, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for idx, key := range m.keys {
+		if idx > 0 {
+			out.WriteByte(',')
+		}
+		encodedKey, _ := json.Marshal(key)
+		out.Write(encodedKey)
+		out.WriteByte(':')
+		encoded, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		out.Write(encoded)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// enumLiteral is an unquoted name in argument position. No input of the
+// schema is an enum, so scalars reject it.
+type enumLiteral string
+
+// Execute runs a query against the schema. Requests that fail to parse or
+// validate return errors and no data; errors raised while resolving a field
+// null that field and are returned alongside the rest of the data.
+func (s *Schema) Execute(ctx context.Context, request Request) *Result {
+	doc, err := Parse(request.Query)
+	if err != nil {
+		return requestError(err)
+	}
+
+	operation, err := selectOperation(doc, request.OperationName)
+	if err != nil {
+		return requestError(err)
+	}
+	if operation.Type != "query" {
+		return requestError(fmt.Errorf("%s operations are not supported", operation.Type))
+	}
+
+	variables, err := coerceVariables(operation, request.Variables)
+	if err != nil {
+		return requestError(err)
+	}
+
+	v := &validator{doc: doc, variables: map[string]struct{}{}, fragments: map[string]bool{}}
+	for _, definition := range operation.Variables {
+		v.variables[definition.Name] = struct{}{}
+	}
+	v.selections(s.Query, operation.Selections)
+	if len(v.errors) > 0 {
+		return &Result{Errors: v.errors}
+	}
+
+	e := &executor{doc: doc, variables: variables}
+	data, _ := e.selections(ctx, s.Query, nil, operation.Selections, nil)
+	result := &Result{Errors: e.errors}
+	if data != nil {
+		result.Data = data
+	}
+	return result
+}
+
+func requestError(err error) *Result {
+	return &Result{Errors: []*Error{{Message: err.Error()}}}
+}
+
+func selectOperation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the query has more than one operation")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, operation := range doc.Operations {
+		if operation.Name == name {
+			return operation, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func coerceVariables(operation *Operation, provided map[string]any) (map[string]any, error) {
+	variables := map[string]any{}
+	for _, definition := range operation.Variables {
+		inputType, err := parseInputType(definition.Type)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", definition.Name, err)
+		}
+
+		value, ok := provided[definition.Name]
+		if !ok {
+			if definition.Default == nil && !isNonNull(inputType) {
+				continue
+			}
+			if definition.Default != nil {
+				value, err = literalValue(*definition.Default, nil)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("variable $%s: %w", definition.Name, err)
+			}
+		}
+		coerced, err := coerceInput(inputType, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s %s", definition.Name, err)
+		}
+		variables[definition.Name] = coerced
+	}
+	return variables, nil
+}
+
+func isNonNull(t Type) bool {
+	_, ok := t.(*NonNull)
+	return ok
+}
+
+// parseInputType reads a variable's type, such as "[Int!]!".
+func parseInputType(ref string) (Type, error) {
+	if inner, ok := strings.CutSuffix(ref, "!"); ok {
+		t, err := parseInputType(inner)
+		if err != nil {
+			return nil, err
+		}
+		return &NonNull{Of: t}, nil
+	}
+	if strings.HasPrefix(ref, "[") && strings.HasSuffix(ref, "]") {
+		t, err := parseInputType(ref[1 : len(ref)-1])
+		if err != nil {
+			return nil, err
+		}
+		return &List{Of: t}, nil
+	}
+	for _, scalar := range []*Scalar{String, ID, Int, Float, Boolean} {
+		if scalar.Name == ref {
+			return scalar, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown input type %q", ref)
+}
+
+// literalValue reads a query literal into the Go values JSON variables
+// decode to.
+func literalValue(value Value, variables map[string]any) (any, error) {
+	switch value.Kind {
+	case VariableValue:
+		return variables[value.Variable], nil
+	case IntValue:
+		n, err := strconv.Atoi(value.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s is out of range", value.Raw)
+		}
+		return n, nil
+	case FloatValue:
+		return strconv.ParseFloat(value.Raw, 64)
+	case StringValue:
+		return value.Raw, nil
+	case BooleanValue:
+		return value.Raw == "true", nil
+	case EnumValue:
+		return enumLiteral(value.Raw), nil
+	case ListValue:
+		items := make([]any, 0, len(value.List))
+		for _, item := range value.List {
+			v, err := literalValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case ObjectValue:
+		object := make(map[string]any, len(value.Object))
+		for key, item := range value.Object {
+			v, err := literalValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = v
+		}
+		return object, nil
+	}
+	return nil, nil
+}
+
+func coerceInput(t Type, value any) (any, error) {
+	switch v := t.(type) {
+	case *NonNull:
+		if value == nil {
+			return nil, fmt.Errorf("is required")
+		}
+		return coerceInput(v.Of, value)
+	case *List:
+		if value == nil {
+			return nil, nil
+		}
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+		out := make([]any, 0, len(items))
+		for _, item := range items {
+			coerced, err := coerceInput(v.Of, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, coerced)
+		}
+		return out, nil
+	case *Scalar:
+		if value == nil {
+			return nil, nil
+		}
+		return v.Coerce(value)
+	}
+	return nil, fmt.Errorf("must be an input type")
+}
+
+func namedType(t Type) Type {
+	for {
+		switch v := t.(type) {
+		case *NonNull:
+			t = v.Of
+		case *List:
+			t = v.Of
+		default:
+			return t
+		}
+	}
+}
+
+type validator struct {
+	doc       *Document
+	variables map[string]struct{}
+	// fragments marks the fragments being validated, to catch cycles.
+	fragments map[string]bool
+	errors    []*Error
+}
+
+func (v *validator) fail(format string, args ...any) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) selections(object *Object, selections []Selection) {
+	for _, selection := range selections {
+		v.directives(selection.directives())
+		switch sel := selection.(type) {
+		case *Field:
+			v.field(object, sel)
+		case *FragmentSpread:
+			fragment, ok := v.doc.Fragments[sel.Name]
+			if !ok {
+				v.fail("unknown fragment %q", sel.Name)
+				continue
+			}
+			if fragment.TypeCondition != object.Name {
+				v.fail("fragment %q on %s can't be spread on type %s", sel.Name, fragment.TypeCondition, object.Name)
+				continue
+			}
+			if v.fragments[sel.Name] {
+				v.fail("fragment %q spreads itself", sel.Name)
+				continue
+			}
+			v.fragments[sel.Name] = true
+			v.selections(object, fragment.Selections)
+			delete(v.fragments, sel.Name)
+		case *InlineFragment:
+			if sel.TypeCondition != "" && sel.TypeCondition != object.Name {
+				v.fail("fragment on %s can't be spread on type %s", sel.TypeCondition, object.Name)
+				continue
+			}
+			v.selections(object, sel.Selections)
+		}
+	}
+}
+
+func (v *validator) field(object *Object, field *Field) {
+	if field.Name == "__typename" {
+		if len(field.Arguments) > 0 || field.Selections != nil {
+			v.fail("field __typename takes no arguments or selections")
+		}
+		return
+	}
+
+	definition := object.Field(field.Name)
+	if definition == nil {
+		v.fail("cannot query field %q on type %s", field.Name, object.Name)
+		return
+	}
+	for name, value := range field.Arguments {
+		arg := definition.arg(name)
+		if arg == nil {
+			v.fail("unknown argument %q on field %s.%s", name, object.Name, field.Name)
+			continue
+		}
+		if !v.usesDefinedVariables(value) {
+			continue
+		}
+		if !containsVariable(value) {
+			literal, err := literalValue(value, nil)
+			if err == nil {
+				_, err = coerceInput(arg.Type, literal)
+			}
+			if err != nil {
+				v.fail("argument %q on field %s.%s %s", name, object.Name, field.Name, err)
+			}
+		}
+	}
+	for _, arg := range definition.Args {
+		if _, ok := field.Arguments[arg.Name]; !ok && isNonNull(arg.Type) && arg.Default == nil {
+			v.fail("argument %q on field %s.%s is required", arg.Name, object.Name, field.Name)
+		}
+	}
+
+	child, isObject := namedType(definition.Type).(*Object)
+	switch {
+	case isObject && field.Selections == nil:
+		v.fail("field %s.%s of type %s must have a selection of subfields", object.Name, field.Name, definition.Type)
+	case !isObject && field.Selections != nil:
+		v.fail("field %s.%s of type %s can't have a selection of subfields", object.Name, field.Name, definition.Type)
+	case isObject:
+		v.selections(child, field.Selections)
+	}
+}
+
+func (v *validator) directives(directives []Directive) {
+	for _, directive := range directives {
+		if directive.Name != "skip" && directive.Name != "include" {
+			v.fail("unknown directive @%s", directive.Name)
+			continue
+		}
+		value, ok := directive.Arguments["if"]
+		if !ok || len(directive.Arguments) != 1 {
+			v.fail("directive @%s takes a single if argument", directive.Name)
+			continue
+		}
+		if v.usesDefinedVariables(value) && value.Kind != VariableValue && value.Kind != BooleanValue {
+			v.fail("argument if of @%s must be a boolean", directive.Name)
+		}
+	}
+}
+
+func (v *validator) usesDefinedVariables(value Value) bool {
+	switch value.Kind {
+	case VariableValue:
+		if _, ok := v.variables[value.Variable]; !ok {
+			v.fail("variable $%s is not defined", value.Variable)
+			return false
+		}
+	case ListValue:
+		for _, item := range value.List {
+			if !v.usesDefinedVariables(item) {
+				return false
+			}
+		}
+	case ObjectValue:
+		for _, item := range value.Object {
+			if !v.usesDefinedVariables(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsVariable(value Value) bool {
+	switch value.Kind {
+	case VariableValue:
+		return true
+	case ListValue:
+		for _, item := range value.List {
+			if containsVariable(item) {
+				return true
+			}
+		}
+	case ObjectValue:
+		for _, item := range value.Object {
+			if containsVariable(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type executor struct {
+	doc       *Document
+	variables map[string]any
+	errors    []*Error
+}
+
+func (e *executor) fail(path []any, message string) {
+	e.errors = append(e.errors, &Error{Message: message, Path: append([]any(nil), path...)})
+}
+
+// collect groups the fields selected on object by response key, following
+// fragments and dropping fields skipped by @skip or @include.
+func (e *executor) collect(object *Object, selections []Selection, keys *[]string, fields map[string][]*Field) {
+	for _, selection := range selections {
+		if !e.included(selection.directives()) {
+			continue
+		}
+		switch sel := selection.(type) {
+		case *Field:
+			key := sel.ResponseKey()
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], sel)
+		case *FragmentSpread:
+			e.collect(object, e.doc.Fragments[sel.Name].Selections, keys, fields)
+		case *InlineFragment:
+			e.collect(object, sel.Selections, keys, fields)
+		}
+	}
+}
+
+func (e *executor) included(directives []Directive) bool {
+	for _, directive := range directives {
+		value, _ := literalValue(directive.Arguments["if"], e.variables)
+		condition, _ := value.(bool)
+		if directive.Name == "skip" && condition {
+			return false
+		}
+		if directive.Name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// selections resolves the fields of one object. ok is false when a
+// non-null field came back null, which nulls the object itself.
+func (e *executor) selections(ctx context.Context, object *Object, source any, selections []Selection, path []any) (*OrderedMap, bool) {
+	keys := make([]string, 0)
+	fields := map[string][]*Field{}
+	e.collect(object, selections, &keys, fields)
+
+	out := &OrderedMap{}
+	for _, key := range keys {
+		field := fields[key][0]
+		fieldPath := append(append([]any(nil), path...), key)
+		if field.Name == "__typename" {
+			out.set(key, object.Name)
+			continue
+		}
+
+		definition := object.Field(field.Name)
+		value, ok := e.resolve(ctx, definition, source, field, fieldPath)
+		if ok {
+			value, ok = e.complete(ctx, definition.Type, value, fields[key], fieldPath)
+		}
+		if !ok {
+			if isNonNull(definition.Type) {
+				return nil, false
+			}
+			value = nil
+		}
+		out.set(key, value)
+	}
+	return out, true
+}
+
+func (e *executor) resolve(ctx context.Context, definition *FieldDef, source any, field *Field, path []any) (any, bool) {
+	args := map[string]any{}
+	for _, arg := range definition.Args {
+		raw, given := field.Arguments[arg.Name]
+		var value any
+		if given {
+			if raw.Kind == VariableValue {
+				if _, given = e.variables[raw.Variable]; !given && arg.Default == nil {
+					continue
+				}
+			}
+			value, _ = literalValue(raw, e.variables)
+		}
+		if !given {
+			if arg.Default == nil {
+				continue
+			}
+			value = arg.Default
+		}
+		coerced, err := coerceInput(arg.Type, value)
+		if err != nil {
+			e.fail(path, fmt.Sprintf("argument %q %s", arg.Name, err))
+			return nil, false
+		}
+		args[arg.Name] = coerced
+	}
+
+	if definition.Resolve == nil {
+		return readField(source, definition.Name), true
+	}
+	value, err := definition.Resolve(ctx, source, args)
+	if err != nil {
+		e.fail(path, err.Error())
+		return nil, false
+	}
+	return value, true
+}
+
+// complete shapes a resolved value to the field's type. ok is false when a
+// null reached a non-null type; the error has been recorded by then.
+func (e *executor) complete(ctx context.Context, t Type, value any, fields []*Field, path []any) (any, bool) {
+	if nonNull, ok := t.(*NonNull); ok {
+		completed, ok := e.complete(ctx, nonNull.Of, value, fields, path)
+		if !ok {
+			return nil, false
+		}
+		if completed == nil {
+			e.fail(path, fmt.Sprintf("non-null field %s returned null", fields[0].Name))
+			return nil, false
+		}
+		return completed, true
+	}
+
+	reflected := reflect.ValueOf(value)
+	for reflected.Kind() == reflect.Pointer || reflected.Kind() == reflect.Interface {
+		if reflected.IsNil() {
+			return nil, true
+		}
+		reflected = reflected.Elem()
+	}
+	if !reflected.IsValid() {
+		return nil, true
+	}
+
+	switch v := t.(type) {
+	case *List:
+		if reflected.Kind() != reflect.Slice && reflected.Kind() != reflect.Array {
+			e.fail(path, fmt.Sprintf("field %s must resolve to a list", fields[0].Name))
+			return nil, true
+		}
+		items := make([]any, 0, reflected.Len())
+		for idx := 0; idx < reflected.Len(); idx++ {
+			item, ok := e.complete(ctx, v.Of, reflected.Index(idx).Interface(), fields, append(append([]any(nil), path...), idx))
+			if !ok {
+				return nil, true
+			}
+			items = append(items, item)
+		}
+		return items, true
+	case *Object:
+		selections := make([]Selection, 0)
+		for _, field := range fields {
+			selections = append(selections, field.Selections...)
+		}
+		out, ok := e.selections(ctx, v, value, selections, path)
+		if !ok {
+			return nil, true
+		}
+		return out, true
+	case *Scalar:
+		if v == ID && reflected.CanInt() {
+			return strconv.FormatInt(reflected.Int(), 10), true
+		}
+		return reflected.Interface(), true
+	}
+	return nil, true
+}
@@ -0,0 +1,651 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed request: its operations and the fragments they use.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+type Operation struct {
+	Type       string
+	Name       string
+	Variables  []VariableDefinition
+	Selections []Selection
+}
+
+type VariableDefinition struct {
+	Name    string
+	Type    string
+	Default *Value
+}
+
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []Selection
+}
+
+// Selection is a *Field, *FragmentSpread or *InlineFragment.
+type Selection interface {
+	directives() []Directive
+}
+
+type Field struct {
+	Alias      string
+	Name       string
+	Arguments  map[string]Value
+	Directives []Directive
+	Selections []Selection
+}
+
+type FragmentSpread struct {
+	Name       string
+	Directives []Directive
+}
+
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []Directive
+	Selections    []Selection
+}
+
+type Directive struct {
+	Name      string
+	Arguments map[string]Value
+}
+
+func (f *Field) directives() []Directive          { return f.Directives }
+func (f *FragmentSpread) directives() []Directive { return f.Directives }
+func (f *InlineFragment) directives() []Directive { return f.Directives }
+
+// ResponseKey is the name the field's value is returned under.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Value is a literal in a query. Variables, lists and objects are resolved
+// against the request's variables when read.
+type Value struct {
+	Kind     ValueKind
+	Raw      string
+	List     []Value
+	Object   map[string]Value
+	Variable string
+}
+
+type ValueKind int
+
+const (
+	IntValue ValueKind = iota
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+	VariableValue
+)
+
+// Parse reads a query document. Only the executable parts of the language
+// are understood; schema definitions are rejected.
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: lexer{source: source}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.token.kind == tokenPunct && p.token.value == "{":
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: selections})
+		case p.token.kind == tokenName && p.token.value == "fragment":
+			fragment, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.Fragments[fragment.Name]; exists {
+				return nil, fmt.Errorf("fragment %q is defined more than once", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		case p.token.kind == tokenName && (p.token.value == "query" || p.token.value == "mutation" || p.token.value == "subscription"):
+			operation, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, operation)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("query has no operations")
+	}
+	return doc, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type lexer struct {
+	source string
+	pos    int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.source) {
+		ch := l.source[l.pos]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			l.pos++
+		case ch == '#':
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.source[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return l.read()
+		}
+	}
+	return token{kind: tokenEOF, pos: l.pos}, nil
+}
+
+func (l *lexer) read() (token, error) {
+	start := l.pos
+	ch := l.source[l.pos]
+	switch {
+	case strings.HasPrefix(l.source[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunct, value: "...", pos: start}, nil
+	case strings.ContainsRune("!$():=@[]{}|", rune(ch)):
+		l.pos++
+		return token{kind: tokenPunct, value: string(ch), pos: start}, nil
+	case ch == '_' || isLetter(ch):
+		for l.pos < len(l.source) && (l.source[l.pos] == '_' || isLetter(l.source[l.pos]) || isDigit(l.source[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.source[start:l.pos], pos: start}, nil
+	case ch == '-' || isDigit(ch):
+		return l.number()
+	case ch == '"':
+		return l.string()
+	}
+	r, _ := utf8.DecodeRuneInString(l.source[l.pos:])
+	return token{}, fmt.Errorf("unexpected character %q at offset %d", r, start)
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokenInt
+	if l.source[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() {
+		for l.pos < len(l.source) && isDigit(l.source[l.pos]) {
+			l.pos++
+		}
+	}
+	digits()
+	if l.pos < len(l.source) && l.source[l.pos] == '.' {
+		kind = tokenFloat
+		l.pos++
+		digits()
+	}
+	if l.pos < len(l.source) && (l.source[l.pos] == 'e' || l.source[l.pos] == 'E') {
+		kind = tokenFloat
+		l.pos++
+		if l.pos < len(l.source) && (l.source[l.pos] == '+' || l.source[l.pos] == '-') {
+			l.pos++
+		}
+		digits()
+	}
+	raw := l.source[start:l.pos]
+	if raw == "-" {
+		return token{}, fmt.Errorf("invalid number at offset %d", start)
+	}
+	return token{kind: kind, value: raw, pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.source[l.pos:], `"""`) {
+		end := strings.Index(l.source[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, fmt.Errorf("unterminated string at offset %d", start)
+		}
+		value := l.source[l.pos+3 : l.pos+3+end]
+		l.pos += end + 6
+		return token{kind: tokenString, value: strings.TrimSpace(value), pos: start}, nil
+	}
+
+	l.pos++
+	var out strings.Builder
+	for l.pos < len(l.source) {
+		ch := l.source[l.pos]
+		switch {
+		case ch == '"':
+			l.pos++
+			return token{kind: tokenString, value: out.String(), pos: start}, nil
+		case ch == '\n':
+			return token{}, fmt.Errorf("unterminated string at offset %d", start)
+		case ch == '\\' && l.pos+1 < len(l.source):
+			escaped := l.source[l.pos+1]
+			l.pos += 2
+			switch escaped {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case 'b':
+				out.WriteByte('\b')
+			case 'f':
+				out.WriteByte('\f')
+			case 'u':
+				if l.pos+4 > len(l.source) {
+					return token{}, fmt.Errorf("invalid escape at offset %d", l.pos)
+				}
+				code, err := strconv.ParseUint(l.source[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, fmt.Errorf("invalid escape at offset %d", l.pos)
+				}
+				out.WriteRune(rune(code))
+				l.pos += 4
+			default:
+				out.WriteByte(escaped)
+			}
+		default:
+			out.WriteByte(ch)
+			l.pos++
+		}
+	}
+	return token{}, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func isLetter(ch byte) bool { return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') }
+func isDigit(ch byte) bool  { return ch >= '0' && ch <= '9' }
+
+type parser struct {
+	lexer lexer
+	token token
+}
+
+func (p *parser) advance() error {
+	next, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = next
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.token.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of query")
+	}
+	return fmt.Errorf("unexpected %q at offset %d", p.token.value, p.token.pos)
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.token.kind == tokenPunct && p.token.value == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.token.kind != tokenName {
+		return "", p.unexpected()
+	}
+	value := p.token.value
+	return value, p.advance()
+}
+
+func (p *parser) operation() (*Operation, error) {
+	operation := &Operation{Type: p.token.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == tokenName {
+		operation.Name = p.token.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek(")") {
+			definition, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			operation.Variables = append(operation.Variables, definition)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	operation.Selections = selections
+	return operation, nil
+}
+
+func (p *parser) variableDefinition() (VariableDefinition, error) {
+	var definition VariableDefinition
+	if err := p.expect("$"); err != nil {
+		return definition, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return definition, err
+	}
+	definition.Name = name
+	if err := p.expect(":"); err != nil {
+		return definition, err
+	}
+	if definition.Type, err = p.typeReference(); err != nil {
+		return definition, err
+	}
+	if p.peek("=") {
+		if err := p.advance(); err != nil {
+			return definition, err
+		}
+		value, err := p.value(true)
+		if err != nil {
+			return definition, err
+		}
+		definition.Default = &value
+	}
+	return definition, nil
+}
+
+func (p *parser) typeReference() (string, error) {
+	var out string
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+		inner, err := p.typeReference()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		out = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		out = name
+	}
+	if p.peek("!") {
+		out += "!"
+		return out, p.advance()
+	}
+	return out, nil
+}
+
+func (p *parser) fragment() (*Fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != tokenName || p.token.value != "on" {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	condition, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Fragment{Name: name, TypeCondition: condition, Selections: selections}, nil
+}
+
+func (p *parser) selectionSet() ([]Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	selections := make([]Selection, 0)
+	for !p.peek("}") {
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("empty selection at offset %d", p.token.pos)
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) selection() (Selection, error) {
+	if p.peek("...") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.token.kind == tokenName && p.token.value != "on" {
+			name := p.token.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			directives, err := p.directives()
+			if err != nil {
+				return nil, err
+			}
+			return &FragmentSpread{Name: name, Directives: directives}, nil
+		}
+		inline := &InlineFragment{}
+		if p.token.kind == tokenName && p.token.value == "on" {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			condition, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			inline.TypeCondition = condition
+		}
+		directives, err := p.directives()
+		if err != nil {
+			return nil, err
+		}
+		inline.Directives = directives
+		if inline.Selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+		return inline, nil
+	}
+
+	field := &Field{}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	field.Name = name
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		field.Alias = name
+		if field.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if field.Arguments, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if field.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if field.Selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) arguments() (map[string]Value, error) {
+	if !p.peek("(") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	args := map[string]Value{}
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = value
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]Directive, error) {
+	var directives []Directive
+	for p.peek("@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, Directive{Name: name, Arguments: args})
+	}
+	return directives, nil
+}
+
+// value reads a literal; constant values, such as variable defaults, can't
+// refer to variables.
+func (p *parser) value(constant bool) (Value, error) {
+	tok := p.token
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{Kind: VariableValue, Variable: name}, nil
+	case p.peek("["):
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		list := Value{Kind: ListValue, List: []Value{}}
+		for !p.peek("]") {
+			item, err := p.value(constant)
+			if err != nil {
+				return Value{}, err
+			}
+			list.List = append(list.List, item)
+		}
+		return list, p.advance()
+	case p.peek("{"):
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		object := Value{Kind: ObjectValue, Object: map[string]Value{}}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return Value{}, err
+			}
+			if err := p.expect(":"); err != nil {
+				return Value{}, err
+			}
+			if object.Object[name], err = p.value(constant); err != nil {
+				return Value{}, err
+			}
+		}
+		return object, p.advance()
+	case tok.kind == tokenInt:
+		return Value{Kind: IntValue, Raw: tok.value}, p.advance()
+	case tok.kind == tokenFloat:
+		return Value{Kind: FloatValue, Raw: tok.value}, p.advance()
+	case tok.kind == tokenString:
+		return Value{Kind: StringValue, Raw: tok.value}, p.advance()
+	case tok.kind == tokenName:
+		switch tok.value {
+		case "true", "false":
+			return Value{Kind: BooleanValue, Raw: tok.value}, p.advance()
+		case "null":
+			return Value{Kind: NullValue}, p.advance()
+		}
+		return Value{Kind: EnumValue, Raw: tok.value}, p.advance()
+	}
+	return Value{}, p.unexpected()
+}
@@ -0,0 +1,244 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a scalar, object, list or non-null type of the schema.
+type Type interface {
+	String() string
+}
+
+// ResolveFunc returns the value of a field of source. Args holds the
+// arguments the query passed, already coerced to their declared types, and
+// defaults for those it left out.
+type ResolveFunc func(ctx context.Context, source any, args map[string]any) (any, error)
+
+type Scalar struct {
+	Name string
+	// Coerce turns an input value into the scalar's Go value.
+	Coerce func(any) (any, error)
+}
+
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*FieldDef
+}
+
+type List struct {
+	Of Type
+}
+
+type NonNull struct {
+	Of Type
+}
+
+type FieldDef struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []Argument
+	// Resolve may be nil, in which case the field is read from source: a
+	// map by key, or a struct by its json tag.
+	Resolve ResolveFunc
+}
+
+type Argument struct {
+	Name    string
+	Type    Type
+	Default any
+}
+
+func (s *Scalar) String() string  { return s.Name }
+func (o *Object) String() string  { return o.Name }
+func (l *List) String() string    { return "[" + l.Of.String() + "]" }
+func (n *NonNull) String() string { return n.Of.String() + "!" }
+
+// Field returns the field called name, or nil.
+func (o *Object) Field(name string) *FieldDef {
+	for _, field := range o.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+func (f *FieldDef) arg(name string) *Argument {
+	for idx := range f.Args {
+		if f.Args[idx].Name == name {
+			return &f.Args[idx]
+		}
+	}
+	return nil
+}
+
+var (
+	String  = &Scalar{Name: "String", Coerce: coerceString}
+	ID      = &Scalar{Name: "ID", Coerce: coerceID}
+	Int     = &Scalar{Name: "Int", Coerce: coerceInt}
+	Float   = &Scalar{Name: "Float", Coerce: coerceFloat}
+	Boolean = &Scalar{Name: "Boolean", Coerce: coerceBoolean}
+)
+
+func coerceString(value any) (any, error) {
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+	return nil, fmt.Errorf("must be a string")
+}
+
+func coerceID(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		if v == float64(int64(v)) {
+			return strconv.FormatInt(int64(v), 10), nil
+		}
+	}
+	return nil, fmt.Errorf("must be an ID")
+}
+
+func coerceInt(value any) (any, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		// Variables decoded from JSON arrive as floats.
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return nil, fmt.Errorf("must be an integer")
+}
+
+func coerceFloat(value any) (any, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return nil, fmt.Errorf("must be a number")
+}
+
+func coerceBoolean(value any) (any, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("must be a boolean")
+}
+
+// Schema is a read-only schema: it has a query root and nothing else.
+type Schema struct {
+	Query *Object
+}
+
+// SDL prints the schema in the GraphQL schema language.
+func (s *Schema) SDL() string {
+	objects := make([]*Object, 0)
+	seen := map[string]struct{}{}
+	var collect func(t Type)
+	collect = func(t Type) {
+		switch v := t.(type) {
+		case *List:
+			collect(v.Of)
+		case *NonNull:
+			collect(v.Of)
+		case *Object:
+			if _, ok := seen[v.Name]; ok {
+				return
+			}
+			seen[v.Name] = struct{}{}
+			objects = append(objects, v)
+			for _, field := range v.Fields {
+				collect(field.Type)
+			}
+		}
+	}
+	collect(s.Query)
+	sort.SliceStable(objects[1:], func(i, j int) bool { return objects[i+1].Name < objects[j+1].Name })
+
+	var out strings.Builder
+	for idx, object := range objects {
+		if idx > 0 {
+			out.WriteString("\n")
+		}
+		writeDescription(&out, "", object.Description)
+		fmt.Fprintf(&out, "type %s {\n", object.Name)
+		for _, field := range object.Fields {
+			writeDescription(&out, "  ", field.Description)
+			out.WriteString("  " + field.Name)
+			if len(field.Args) > 0 {
+				args := make([]string, 0, len(field.Args))
+				for _, arg := range field.Args {
+					entry := arg.Name + ": " + arg.Type.String()
+					if arg.Default != nil {
+						entry += " = " + literal(arg.Default)
+					}
+					args = append(args, entry)
+				}
+				out.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			out.WriteString(": " + field.Type.String() + "\n")
+		}
+		out.WriteString("}\n")
+	}
+	return out.String()
+}
+
+func writeDescription(out *strings.Builder, indent string, description string) {
+	if description == "" {
+		return
+	}
+	out.WriteString(indent + strconv.Quote(description) + "\n")
+}
+
+func literal(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// readField is the resolver for fields without one.
+func readField(source any, name string) any {
+	if m, ok := source.(map[string]any); ok {
+		return m[name]
+	}
+
+	value := reflect.ValueOf(source)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	valueType := value.Type()
+	for idx := 0; idx < valueType.NumField(); idx++ {
+		structField := valueType.Field(idx)
+		if !structField.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if tag == name || (tag == "" && strings.EqualFold(structField.Name, name)) {
+			return value.Field(idx).Interface()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/config"
+	"nanoheads/db"
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/repository"
+	"nanoheads/routes"
+)
+
+const loadtestSampleText = `The city council approved a budget of 420 crore rupees for road repairs on Monday after a three-hour debate. ` +
+	`The commissioner said work on the first 40 kilometres will begin in the second week of March and should finish before the monsoon. ` +
+	`Opposition members walked out before the vote, saying the tender process had not been made public. ` +
+	`Residents of the eastern wards have complained about potholes for more than two years, according to a survey by a local civic group.`
+
+type loadtestOptions struct {
+	requests    int
+	concurrency int
+	target      string
+	apiKey      string
+	llmLatency  time.Duration
+	dbInterval  time.Duration
+	timeout     time.Duration
+	keep        bool
+}
+
+type loadtestResult struct {
+	latency   time.Duration
+	articleID int64
+	err       error
+}
+
+// runLoadtestCommand drives POST /api/analyse with the mock provider and
+// reports request and database latency, plus how hard the pool was pushed.
+func runLoadtestCommand(database *sql.DB, args []string) error {
+	options := loadtestOptions{}
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	flags.IntVar(&options.requests, "n", 100, "total analyse requests to send")
+	flags.IntVar(&options.concurrency, "c", 8, "requests in flight at once")
+	flags.StringVar(&options.target, "target", "", "base URL of a running server (it must run with LLM_MOCK=true); default is an in-process server")
+	flags.StringVar(&options.apiKey, "api-key", "", "API key to send; defaults to AUTH_ADMIN_API_KEY")
+	flags.DurationVar(&options.llmLatency, "llm-latency", 300*time.Millisecond, "simulated latency of each mock model call (in-process only)")
+	flags.DurationVar(&options.dbInterval, "db-interval", 100*time.Millisecond, "how often to sample database round-trip time")
+	flags.DurationVar(&options.timeout, "timeout", 2*time.Minute, "per-request timeout")
+	flags.BoolVar(&options.keep, "keep", false, "keep the analyses created by the run instead of deleting them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if options.requests <= 0 || options.concurrency <= 0 {
+		return errors.New("-n and -c must be positive")
+	}
+
+	cfg := config.Current()
+	if options.apiKey == "" {
+		options.apiKey = cfg.AdminAPIKey
+	}
+
+	if options.target == "" {
+		cfg.MockLLM = true
+		cfg.MockLLMLatency = config.Duration{Duration: options.llmLatency}
+		config.Set(cfg)
+
+		gin.SetMode(gin.ReleaseMode)
+		router := gin.New()
+		router.Use(middleware.CORS(cfg.AllowedOrigins))
+		routes.RegisterAnalyseRoutes(router, database)
+
+		server := httptest.NewServer(router)
+		defer server.Close()
+		options.target = server.URL
+	}
+	options.target = strings.TrimRight(options.target, "/")
+
+	fmt.Printf("loadtest: %d requests, concurrency %d, target %s\n", options.requests, options.concurrency, options.target)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := database.Stats()
+	sampler := startDBSampler(ctx, database, options.dbInterval, cfg.DBPingTimeout.Duration)
+
+	started := time.Now()
+	results := runLoadtestRequests(options)
+	elapsed := time.Since(started)
+
+	cancel()
+	<-sampler.done
+	after := database.Stats()
+
+	printLoadtestReport(results, elapsed, sampler, before, after)
+
+	if !options.keep {
+		removed, err := removeLoadtestArticles(database, results)
+		if err != nil {
+			return fmt.Errorf("cleanup: %w", err)
+		}
+		fmt.Printf("\nremoved %d analyses created by the run (use -keep to retain them)\n", removed)
+	}
+	return nil
+}
+
+func runLoadtestRequests(options loadtestOptions) []loadtestResult {
+	client := &http.Client{Timeout: options.timeout}
+	results := make([]loadtestResult, options.requests)
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < options.concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				results[idx] = sendLoadtestRequest(client, options, idx)
+			}
+		}()
+	}
+
+	for idx := 0; idx < options.requests; idx++ {
+		work <- idx
+	}
+	close(work)
+	wg.Wait()
+
+	return results
+}
+
+func sendLoadtestRequest(client *http.Client, options loadtestOptions, idx int) loadtestResult {
+	body, err := json.Marshal(map[string]string{
+		"text":     fmt.Sprintf("%s (load test request %d)", loadtestSampleText, idx+1),
+		"category": "Other",
+	})
+	if err != nil {
+		return loadtestResult{err: err}
+	}
+
+	request, err := http.NewRequest(http.MethodPost, options.target+"/api/analyse", bytes.NewReader(body))
+	if err != nil {
+		return loadtestResult{err: err}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Submission-Channel", models.SubmissionChannelAPI)
+	if options.apiKey != "" {
+		request.Header.Set("X-API-Key", options.apiKey)
+	}
+
+	started := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return loadtestResult{latency: time.Since(started), err: err}
+	}
+	defer response.Body.Close()
+
+	raw, err := io.ReadAll(response.Body)
+	result := loadtestResult{latency: time.Since(started), err: err}
+	if err == nil && response.StatusCode == http.StatusOK {
+		var out models.PhaseOneResponse
+		if json.Unmarshal(raw, &out) == nil {
+			result.articleID = out.ArticleID
+		}
+	}
+	if err == nil && response.StatusCode != http.StatusOK {
+		result.err = fmt.Errorf("status %d: %s", response.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return result
+}
+
+// dbSampler pings the database on an interval until its context ends. Each
+// ping includes waiting for a free connection, so pool pressure shows up here.
+type dbSampler struct {
+	samples   []time.Duration
+	peakInUse int
+	done      chan struct{}
+}
+
+func startDBSampler(ctx context.Context, database *sql.DB, interval time.Duration, timeout time.Duration) *dbSampler {
+	sampler := &dbSampler{done: make(chan struct{})}
+
+	go func() {
+		defer close(sampler.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if inUse := database.Stats().InUse; inUse > sampler.peakInUse {
+				sampler.peakInUse = inUse
+			}
+			if latency, err := db.Ping(ctx, database, timeout); err == nil {
+				sampler.samples = append(sampler.samples, latency)
+			}
+		}
+	}()
+
+	return sampler
+}
+
+func printLoadtestReport(results []loadtestResult, elapsed time.Duration, sampler *dbSampler, before sql.DBStats, after sql.DBStats) {
+	latencies := make([]time.Duration, 0, len(results))
+	failures := 0
+	var firstErr error
+	for _, result := range results {
+		if result.err != nil {
+			failures++
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		latencies = append(latencies, result.latency)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer)
+	fmt.Fprintf(writer, "duration\t%s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(writer, "succeeded\t%d\n", len(latencies))
+	fmt.Fprintf(writer, "failed\t%d\n", failures)
+	fmt.Fprintf(writer, "throughput\t%.2f req/s\n", float64(len(latencies))/elapsed.Seconds())
+
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "latency\tp50\tp90\tp95\tp99\tmax\tsamples")
+	fmt.Fprintln(writer, latencyRow("http", latencies))
+	fmt.Fprintln(writer, latencyRow("db", sampler.samples))
+
+	fmt.Fprintln(writer)
+	fmt.Fprintf(writer, "pool max open\t%s\n", formatPoolLimit(after.MaxOpenConnections))
+	fmt.Fprintf(writer, "pool peak in use\t%d\n", sampler.peakInUse)
+	fmt.Fprintf(writer, "pool waits\t%d\n", after.WaitCount-before.WaitCount)
+	fmt.Fprintf(writer, "pool wait time\t%s\n", (after.WaitDuration - before.WaitDuration).Round(time.Millisecond))
+	_ = writer.Flush()
+
+	if firstErr != nil {
+		fmt.Printf("\nfirst failure: %v\n", firstErr)
+	}
+	if after.MaxOpenConnections > 0 && sampler.peakInUse >= after.MaxOpenConnections {
+		fmt.Printf("\nthe pool ran at its limit of %d connections; consider raising DB_MAX_OPEN_CONNS\n", after.MaxOpenConnections)
+	}
+}
+
+func latencyRow(label string, values []time.Duration) string {
+	if len(values) == 0 {
+		return label + "\t-\t-\t-\t-\t-\t0"
+	}
+
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	columns := []string{label}
+	for _, p := range []float64{50, 90, 95, 99} {
+		columns = append(columns, formatLatency(percentile(sorted, p)))
+	}
+	columns = append(columns, formatLatency(sorted[len(sorted)-1]), fmt.Sprintf("%d", len(sorted)))
+	return strings.Join(columns, "\t")
+}
+
+// percentile uses the nearest-rank method on an already sorted slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func formatLatency(value time.Duration) string {
+	if value < time.Millisecond {
+		return value.Round(time.Microsecond).String()
+	}
+	return value.Round(100 * time.Microsecond).String()
+}
+
+func formatPoolLimit(limit int) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", limit)
+}
+
+func removeLoadtestArticles(database *sql.DB, results []loadtestResult) (int, error) {
+	ids := make([]any, 0, len(results))
+	for _, result := range results {
+		if result.articleID > 0 {
+			ids = append(ids, result.articleID)
+		}
+	}
+
+	store := repository.New(database)
+	ctx := context.Background()
+	removed := 0
+	for start := 0; start < len(ids); start += 200 {
+		end := start + 200
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+		in := repository.Placeholders(len(batch))
+
+		if _, err := store.ExecContext(ctx, "DELETE FROM llm_calls WHERE article_id IN ("+in+")", batch...); err != nil {
+			return removed, err
+		}
+		result, err := store.ExecContext(ctx, "DELETE FROM articles WHERE id IN ("+in+")", batch...)
+		if err != nil {
+			return removed, err
+		}
+		affected, _ := result.RowsAffected()
+		removed += int(affected)
+	}
+	return removed, nil
+}
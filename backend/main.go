@@ -1,55 +1,159 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
+	"nanoheads/config"
+	"nanoheads/controllers"
 	"nanoheads/db"
+	"nanoheads/middleware"
+	"nanoheads/models"
 	"nanoheads/routes"
+	"nanoheads/services"
 )
 
 func main() {
 	_ = godotenv.Load()
 
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL is required in .env")
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		if err := runCLI(os.Args[1:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "--worker" {
+		cfg.WorkerMode = true
+		cfg.ProcessJobs = true
+		config.Set(cfg)
 	}
 
-	database, err := db.Connect(databaseURL, os.Getenv("DB_DRIVER"))
+	database, err := db.Connect(cfg.DatabaseURL, cfg.DBDriver)
 	if err != nil {
 		log.Fatalf("database connection failed: %v", err)
 	}
 	defer database.Close()
 
-	router := gin.Default()
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "https://newsapp-frontned.onrender.com")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	db.ConfigurePool(database, db.PoolSettings{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime.Duration,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime.Duration,
+	})
 
-		if c.Request.Method == http.MethodOptions {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(database, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
 		}
+		return
+	}
 
-		c.Next()
-	})
+	if cfg.AutoMigrate {
+		applied, err := db.MigrateUp(database, db.Driver())
+		if err != nil {
+			log.Fatalf("database migration failed: %v", err)
+		}
+		if applied > 0 {
+			log.Printf("applied %d database migration(s)", applied)
+		}
+	}
 
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status": "ok",
-			"db":     "connected",
-		})
-	})
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		if err := runLoadtestCommand(database, os.Args[2:]); err != nil {
+			log.Fatalf("loadtest: %v", err)
+		}
+		return
+	}
+
+	if cfg.DebugEnabled() {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
-	routes.RegisterAnalyseRoutes(router, database)
+	router := gin.New()
+	router.Use(gin.Logger(), middleware.RequestID(), middleware.Recovery(), middleware.ReportErrors())
+	router.Use(middleware.CORS(cfg.AllowedOrigins))
 
-	if err := router.Run(":8085"); err != nil {
-		log.Fatalf("server failed to start: %v", err)
+	health := controllers.NewHealthController(database)
+	router.GET("/livez", health.Live)
+	router.GET("/readyz", health.Ready)
+
+	// A worker serves only the probes; the API is left to server processes.
+	if !cfg.WorkerMode {
+		routes.RegisterAnalyseRoutes(router, database)
+	}
+
+	server := &http.Server{
+		Addr:         cfg.Addr(),
+		Handler:      router,
+		ReadTimeout:  cfg.ReadTimeout.Duration,
+		WriteTimeout: cfg.WriteTimeout.Duration,
+		IdleTimeout:  cfg.IdleTimeout.Duration,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	jobsDone := make(chan struct{})
+	if cfg.ProcessJobs {
+		jobs := services.NewJobService(database)
+		services.RegisterJobHandlers(jobs, database)
+		go func() {
+			defer close(jobsDone)
+			jobs.Run(ctx, 2*time.Second)
+		}()
+		if cfg.GapRecheckEnabled() {
+			go jobs.Schedule(ctx, models.JobTypeGapRecheck, struct{}{}, cfg.GapRecheckInterval.Duration)
+		}
+		if cfg.DigestsEnabled() {
+			// Hourly, so each digest goes out within the hour after DIGEST_HOUR.
+			go jobs.Schedule(ctx, models.JobTypeDigest, struct{}{}, time.Hour)
+		}
+		if cfg.RetentionEnabled() {
+			go jobs.Schedule(ctx, models.JobTypeRetention, struct{}{}, 24*time.Hour)
+		}
+	} else {
+		close(jobsDone)
+		log.Printf("not processing jobs (PROCESS_JOBS=false); workers run the queue")
+	}
+
+	role := "server"
+	if cfg.WorkerMode {
+		role = "worker"
+	}
+	go func() {
+		log.Printf("%s listening on %s (log_level=%s)", role, cfg.Addr(), cfg.LogLevel)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server failed to start: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("graceful shutdown failed: %v", err)
+	}
+	// Let a job cut short record how it ended before the database closes.
+	select {
+	case <-jobsDone:
+	case <-shutdownCtx.Done():
+		log.Printf("a job was still running at shutdown")
 	}
 }
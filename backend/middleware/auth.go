@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+const principalContextKey = "principal"
+
+func Authenticate(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authService.Resolve(c.Request.Context(), apiKeyFromRequest(c))
+		if err != nil {
+			if errors.Is(err, services.ErrUnauthenticated) || errors.Is(err, services.ErrInvalidAPIKey) || services.ErrorCode(err) == models.ErrorCodeUnauthenticated {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": models.ErrorCodeUnauthenticated})
+				return
+			}
+			log.Printf("[auth] resolve principal failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "authentication failed", "code": models.ErrorCodeInternal})
+			return
+		}
+
+		c.Set(principalContextKey, principal)
+		c.Next()
+	}
+}
+
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "missing permission: " + permission,
+				"code":  models.ErrorCodeForbidden,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func CurrentPrincipal(c *gin.Context) models.Principal {
+	value, ok := c.Get(principalContextKey)
+	if !ok {
+		return models.Principal{}
+	}
+	principal, _ := value.(models.Principal)
+	return principal
+}
+
+func HasPermission(c *gin.Context, permission string) bool {
+	return CurrentPrincipal(c).Can(permission)
+}
+
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("X-API-Key")); key != "" {
+		return key
+	}
+
+	authorization := strings.TrimSpace(c.GetHeader("Authorization"))
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+
+	// Browsers can't set headers on a WebSocket handshake, only offer
+	// subprotocols, so the key may come as one named "apikey.<key>". It is
+	// never chosen and so never echoed back.
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		for _, protocol := range strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",") {
+			if key, ok := strings.CutPrefix(strings.TrimSpace(protocol), "apikey."); ok {
+				return key
+			}
+		}
+	}
+	return ""
+}
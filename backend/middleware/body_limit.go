@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+)
+
+// LimitBody rejects request bodies over limit bytes with 413. overrides sets
+// other limits per route, keyed by method and route path as gin reports it,
+// e.g. "POST /api/analyse".
+//
+// A declared Content-Length is checked up front; otherwise the body is cut
+// off at the limit and the handler's read fails with *http.MaxBytesError.
+func LimitBody(limit int64, overrides map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		max := limit
+		if override, ok := overrides[c.Request.Method+" "+c.FullPath()]; ok {
+			max = override
+		}
+
+		if c.Request.ContentLength > max {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": BodyTooLargeMessage(max), "code": models.ErrorCodeBodyTooLarge})
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		}
+		c.Next()
+	}
+}
+
+func BodyTooLargeMessage(limit int64) string {
+	if limit >= 1<<20 && limit%(1<<20) == 0 {
+		return fmt.Sprintf("request body must be at most %d MB", limit>>20)
+	}
+	return fmt.Sprintf("request body must be at most %d bytes", limit)
+}
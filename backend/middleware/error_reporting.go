@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestID answers every request with an X-Request-ID, the caller's own if
+// it sent a usable one, and puts it in the request context with the route
+// and analysis, so errors reported while serving it can be traced back.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(requestIDHeader, requestID)
+
+		tags := map[string]string{"method": c.Request.Method, "route": c.FullPath()}
+		if id := c.Param("id"); id != "" && strings.HasPrefix(c.FullPath(), "/api/analyses/:id") {
+			tags["article_id"] = id
+		}
+		c.Request = c.Request.WithContext(services.WithErrorScope(c.Request.Context(), requestID, tags))
+		c.Next()
+	}
+}
+
+// ErrorID names one failure in the logs, the error report and the 500
+// sent to the caller, so a caller's report can be matched to the cause. It
+// is made on first use within a request.
+func ErrorID(c *gin.Context) string {
+	if id := c.GetString(errorIDKey); id != "" {
+		return id
+	}
+	id := newRequestID()
+	c.Set(errorIDKey, id)
+	return id
+}
+
+const errorIDKey = "errorId"
+
+// Recovery answers a panic with a JSON 500 carrying an error ID, logging
+// the panic and its stack under that ID. It takes gin.Recovery's place.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			errorID := ErrorID(c)
+			stack := debug.Stack()
+			log.Printf("[panic] error_id=%s request_id=%s %s %s: %v\n%s", errorID, services.RequestIDFromContext(c.Request.Context()), c.Request.Method, c.Request.URL.Path, recovered, stack)
+			services.ReportPanic(c.Request.Context(), recovered, stack, map[string]string{"error_id": errorID})
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error", "code": models.ErrorCodeInternal, "errorId": errorID})
+		}()
+		c.Next()
+	}
+}
+
+// ReportErrors sends 5xx responses to error reporting; panics are reported
+// by Recovery, which must come before it. 501s and 503s are left out: they
+// are deliberate, from a feature not set up, a resting provider or a probe
+// that isn't ready.
+func ReportErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < http.StatusInternalServerError || status == http.StatusNotImplemented || status == http.StatusServiceUnavailable {
+			return
+		}
+		tags := map[string]string{"status": fmt.Sprint(status)}
+		if id := c.GetString(errorIDKey); id != "" {
+			tags["error_id"] = id
+		}
+		err := c.Errors.Last()
+		if err == nil {
+			services.ReportError(c.Request.Context(), fmt.Errorf("%s %s answered %d", c.Request.Method, c.FullPath(), status), tags)
+			return
+		}
+		services.ReportError(c.Request.Context(), err.Err, tags)
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
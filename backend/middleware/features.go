@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+// RequireFeature answers 403 while flag is off for the caller. Partial
+// rollouts are decided per user, so anonymous callers wait for 100%.
+func RequireFeature(flags *services.FeatureFlagService, flag string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := ""
+		if userID := CurrentPrincipal(c).UserID; userID > 0 {
+			subject = strconv.FormatInt(userID, 10)
+		}
+		if !flags.Enabled(c.Request.Context(), flag, subject) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "feature is turned off: " + flag,
+				"code":  models.ErrorCodeFeatureDisabled,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"nanoheads/db"
+)
+
+const migrateUsage = "usage: nanoheads migrate up | down [steps] | status"
+
+func runMigrateCommand(database *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	driver := db.Driver()
+	switch args[0] {
+	case "up":
+		count, err := db.MigrateUp(database, driver)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("steps must be a positive number (got %q)", args[1])
+			}
+			steps = parsed
+		}
+		count, err := db.MigrateDown(database, driver, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migration(s)\n", count)
+	case "status":
+		states, err := db.MigrationStatus(database, driver)
+		if err != nil {
+			return err
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.AppliedAt != nil {
+				appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(writer, "%04d\t%s\t%s\n", state.Version, state.Name, appliedAt)
+		}
+		return writer.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
@@ -5,22 +5,124 @@ import "time"
 type DashboardSummary struct {
 	TotalAnalyses int64  `json:"totalAnalyses"`
 	PendingReview int64  `json:"pendingReview"`
+	OpenThreads   int64  `json:"openThreads"`
+	AssignedToMe  int64  `json:"assignedToMe"`
+	Unassigned    int64  `json:"unassigned"`
 	SavedArticles int64  `json:"savedArticles"`
 	AIUsagePct    int64  `json:"aiUsagePct"`
 	AIUsageText   string `json:"aiUsageText"`
+
+	// AwaitingApproval counts analyses pending review with no approval of
+	// their current version; Approved those approved and not yet completed.
+	AwaitingApproval int64 `json:"awaitingApproval"`
+	Approved         int64 `json:"approved"`
+	// Flagged counts analyses not yet completed with an open legal flag.
+	Flagged int64 `json:"flagged"`
 }
 
 type DashboardResponse struct {
 	Summary        DashboardSummary   `json:"summary"`
+	Analytics      DashboardAnalytics `json:"analytics"`
 	RecentAnalyses []AnalysisListItem `json:"recentAnalyses"`
 }
 
+// AnalyticsPeriod selects the window charted on the dashboard: the last Days
+// days, grouped by Bucket ("day" or "week").
+type AnalyticsPeriod struct {
+	Bucket string
+	Days   int
+}
+
+// DashboardAnalytics holds chart-ready series. Every series has one point per
+// bucket in the window, oldest first, so series line up with each other.
+type DashboardAnalytics struct {
+	Bucket                 string          `json:"bucket"`
+	From                   string          `json:"from"`
+	To                     string          `json:"to"`
+	Analyses               []SeriesPoint   `json:"analyses"`
+	Completed              []SeriesPoint   `json:"completed"`
+	AverageFacts           []SeriesPoint   `json:"averageFacts"`
+	AverageHoursToComplete []SeriesPoint   `json:"averageHoursToComplete"`
+	Totals                 AnalyticsTotals `json:"totals"`
+	Categories             []CategoryCount `json:"categories"`
+	Providers              []ProviderUsage `json:"providers"`
+}
+
+// SeriesPoint is one bucket; Date is the day the bucket starts (YYYY-MM-DD).
+type SeriesPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+type AnalyticsTotals struct {
+	Analyses               int64   `json:"analyses"`
+	Completed              int64   `json:"completed"`
+	AverageFacts           float64 `json:"averageFacts"`
+	AverageHoursToComplete float64 `json:"averageHoursToComplete"`
+}
+
+type CategoryCount struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
+}
+
+// ProviderUsage counts model calls, with Calls as a series over the window.
+type ProviderUsage struct {
+	Provider     string        `json:"provider"`
+	Calls        int64         `json:"calls"`
+	Errors       int64         `json:"errors"`
+	AvgLatencyMS int64         `json:"avgLatencyMs"`
+	Series       []SeriesPoint `json:"series"`
+}
+
 type AnalysisListItem struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Category  string    `json:"category"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID                int64         `json:"id"`
+	Title             string        `json:"title"`
+	Category          string        `json:"category"`
+	SuggestedCategory string        `json:"suggestedCategory,omitempty"`
+	Status            string        `json:"status"`
+	SourceRating      *SourceRating `json:"sourceRating,omitempty"`
+	Assignee          *Assignee     `json:"assignee,omitempty"`
+	CreatedBy         *int64        `json:"createdBy,omitempty"`
+	CreatedAt         time.Time     `json:"createdAt"`
+	FactCount         int64         `json:"factCount"`
+	IncludedFacts     int64         `json:"includedFacts"`
+	GapCount          int64         `json:"gapCount"`
+	HasHeadline       bool          `json:"hasHeadline"`
+	ApprovalStatus    string        `json:"approvalStatus,omitempty"`
+	OpenFlags         int64         `json:"openFlags"`
+}
+
+type Topic struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// AnalysisFilter narrows the analysis list; the zero value lists everything.
+// CreatedBy matches the user who submitted the analysis, and Days keeps
+// analyses created in the last Days days, today included.
+type AnalysisFilter struct {
+	AssignedTo *int64
+	Unassigned bool
+	CreatedBy  *int64
+	Status     string
+	Category   string
+	Days       int
+}
+
+// Assignee is the editor an analysis is assigned to for review.
+type Assignee struct {
+	UserID     int64      `json:"userId"`
+	Name       string     `json:"name"`
+	AssignedBy *int64     `json:"assignedBy,omitempty"`
+	AssignedAt *time.Time `json:"assignedAt,omitempty"`
+}
+
+// CategorySuggestion is a topic proposed for an analysis that was submitted
+// without one. Source is "llm" or "keywords".
+type CategorySuggestion struct {
+	Category string `json:"category"`
+	Source   string `json:"source"`
 }
 
 type AnalysisFact struct {
@@ -29,41 +131,163 @@ type AnalysisFact struct {
 	Included  bool   `json:"included"`
 	Confirmed bool   `json:"confirmed"`
 	Source    string `json:"source"`
+	Version   int64  `json:"version"`
+	// DeletedAt is set on facts listed from the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	Corroboration string           `json:"corroboration,omitempty"`
+	Sources       []int            `json:"sources,omitempty"`
+	FactChecks    []FactCheckMatch `json:"factChecks,omitempty"`
+	// Tags are lowercase labels such as "statistic" or "official statement".
+	Tags []string `json:"tags,omitempty"`
 }
 
 type AnalysisGap struct {
-	ID       int64  `json:"id"`
-	Text     string `json:"text"`
-	Selected bool   `json:"selected"`
-	Resolved bool   `json:"resolved"`
+	ID       int64       `json:"id"`
+	Text     string      `json:"text"`
+	Selected bool        `json:"selected"`
+	Resolved bool        `json:"resolved"`
+	Origin   string      `json:"origin,omitempty"`
+	Version  int64       `json:"version"`
+	Leads    []GapLead   `json:"leads,omitempty"`
+	Answers  []GapAnswer `json:"answers,omitempty"`
+}
+
+// GapLead is later coverage, found by the scheduled gap re-check, that may
+// answer a gap. Score is the share of the gap's terms it mentions.
+type GapLead struct {
+	ID          int64      `json:"id"`
+	URL         string     `json:"url"`
+	Title       string     `json:"title"`
+	Summary     string     `json:"summary,omitempty"`
+	FeedURL     string     `json:"feedUrl"`
+	PublishedAt *time.Time `json:"publishedAt"`
+	Score       float64    `json:"score"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+const (
+	GapAnswerPending  = "pending"
+	GapAnswerAccepted = "accepted"
+	GapAnswerRejected = "rejected"
+)
+
+// GapAnswer is a candidate answer to a gap that the model drafted from web
+// search results, for an editor to accept or reject. Confidence is "high",
+// "medium" or "low".
+type GapAnswer struct {
+	ID         int64             `json:"id"`
+	Answer     string            `json:"answer"`
+	Confidence string            `json:"confidence"`
+	Sources    []GapAnswerSource `json:"sources"`
+	Query      string            `json:"query"`
+	Status     string            `json:"status"`
+	ReviewedAt *time.Time        `json:"reviewedAt"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+type GapAnswerSource struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type GapResearchResult struct {
+	GapID   int64  `json:"gapId"`
+	Query   string `json:"query"`
+	Results int    `json:"results"`
+	Fetched int    `json:"fetched"`
+	Answers int    `json:"answers"`
+}
+
+type GapRecheckResult struct {
+	Gaps       int `json:"gaps"`
+	Feeds      int `json:"feeds"`
+	Searches   int `json:"searches"`
+	FeedErrors int `json:"feedErrors"`
+	Items      int `json:"items"`
+	Leads      int `json:"leads"`
+	Analyses   int `json:"analyses"`
 }
 
 type AnalysisDetail struct {
-	ID                int64          `json:"id"`
-	Title             string         `json:"title"`
-	Category          string         `json:"category"`
-	Status            string         `json:"status"`
-	SourceURL         string         `json:"sourceUrl"`
-	RawText           string         `json:"rawText"`
-	SelectedFormat    string         `json:"selectedFormat"`
-	ArticleText       string         `json:"articleText"`
-	HeadlineSelected  string         `json:"headlineSelected"`
-	StraplineSelected string         `json:"straplineSelected"`
-	HeadlineOptions   []string       `json:"headlineOptions"`
-	StraplineOptions  []string       `json:"straplineOptions"`
-	Slug              string         `json:"slug"`
-	MetaDescription   string         `json:"metaDescription"`
-	Excerpt           string         `json:"excerpt"`
-	CreatedAt         time.Time      `json:"createdAt"`
-	Facts             []AnalysisFact `json:"facts"`
-	Gaps              []AnalysisGap  `json:"gaps"`
+	ID                 int64                 `json:"id"`
+	Version            int64                 `json:"version"`
+	Title              string                `json:"title"`
+	Category           string                `json:"category"`
+	Status             string                `json:"status"`
+	SourceURL          string                `json:"sourceUrl"`
+	FetchStrategy      string                `json:"fetchStrategy,omitempty"`
+	RawText            string                `json:"rawText"`
+	SelectedFormat     string                `json:"selectedFormat"`
+	ArticleText        string                `json:"articleText"`
+	ArticleMode        string                `json:"articleMode"`
+	ArticleSections    []ArticleSection      `json:"articleSections,omitempty"`
+	Readability        Readability           `json:"readability"`
+	Timeline           []TimelineEvent       `json:"timeline"`
+	NumericClaims      []NumericClaim        `json:"numericClaims"`
+	HeadlineSelected   string                `json:"headlineSelected"`
+	StraplineSelected  string                `json:"straplineSelected"`
+	HeadlineOptions    []string              `json:"headlineOptions"`
+	StraplineOptions   []string              `json:"straplineOptions"`
+	Slug               string                `json:"slug"`
+	MetaDescription    string                `json:"metaDescription"`
+	Excerpt            string                `json:"excerpt"`
+	CategorySuggestion *CategorySuggestion   `json:"categorySuggestion"`
+	Submission         Submission            `json:"submission"`
+	Assignee           *Assignee             `json:"assignee"`
+	MergedInto         *int64                `json:"mergedInto,omitempty"`
+	Failure            *AnalysisFailure      `json:"failure,omitempty"`
+	ThreadID           *int64                `json:"threadId"`
+	SourceRating       *SourceRating         `json:"sourceRating"`
+	FactCheckedAt      *time.Time            `json:"factCheckedAt"`
+	InputLanguage      *LanguageDetection    `json:"inputLanguage"`
+	OutputLanguage     string                `json:"outputLanguage"`
+	PromptVersions     map[string]int        `json:"promptVersions"`
+	Sources            []AnalysisSource      `json:"sources"`
+	Images             []AnalysisImage       `json:"images"`
+	Snapshots          []SourceSnapshot      `json:"snapshots"`
+	EntitiesCheckedAt  *time.Time            `json:"entitiesCheckedAt"`
+	Entities           []Entity              `json:"entities"`
+	CreatedAt          time.Time             `json:"createdAt"`
+	Facts              []AnalysisFact        `json:"facts"`
+	FactTags           []string              `json:"factTags"`
+	Gaps               []AnalysisGap         `json:"gaps"`
+	TitleIssues        []TitleIssue          `json:"titleIssues"`
+	StyleIssues        []StyleIssue          `json:"styleIssues"`
+	GroundingCheckedAt *time.Time            `json:"groundingCheckedAt"`
+	Unsupported        []UnsupportedSentence `json:"unsupportedSentences"`
+	ApprovalStatus     string                `json:"approvalStatus"`
+	Approvals          []AnalysisApproval    `json:"approvals"`
+	Flags              []AnalysisFlag        `json:"flags"`
+	Corrections        []AnalysisCorrection  `json:"corrections"`
+}
+
+// AnalysisFailure is the pipeline step a failed analysis stopped at. Error is
+// only shown to users who can see diagnostics.
+type AnalysisFailure struct {
+	Step     string     `json:"step"`
+	Error    string     `json:"error,omitempty"`
+	FailedAt *time.Time `json:"failedAt,omitempty"`
+}
+
+type TitleIssue struct {
+	Kind     string   `json:"kind"`
+	Text     string   `json:"text"`
+	Problems []string `json:"problems"`
 }
 
+// ModelOption describes a configured model. Prices are per million tokens; nil
+// limits and prices mean none were set.
 type ModelOption struct {
-	ID        int64  `json:"id"`
-	Key       string `json:"key"`
-	Name      string `json:"name"`
-	IsDefault bool   `json:"isDefault"`
+	ID                    int64    `json:"id"`
+	Provider              string   `json:"provider"`
+	Key                   string   `json:"key"`
+	Name                  string   `json:"name"`
+	IsDefault             bool     `json:"isDefault"`
+	Enabled               bool     `json:"enabled"`
+	MaxTokens             *int     `json:"maxTokens"`
+	InputPricePerMillion  *float64 `json:"inputPricePerMillion"`
+	OutputPricePerMillion *float64 `json:"outputPricePerMillion"`
 }
 
 type ProviderOption struct {
@@ -73,6 +297,63 @@ type ProviderOption struct {
 	Models []ModelOption `json:"models"`
 }
 
+// Where a provider's API key comes from.
+const (
+	ProviderKeyStored      = "stored"
+	ProviderKeyEnvironment = "environment"
+	ProviderKeyMissing     = "none"
+)
+
+// ProviderKeyStatus describes a provider's API key without revealing it.
+type ProviderKeyStatus struct {
+	Provider  string     `json:"provider"`
+	Name      string     `json:"name"`
+	Source    string     `json:"source"`
+	MaskedKey string     `json:"maskedKey,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type ProviderKeyTest struct {
+	Provider   string `json:"provider"`
+	Valid      bool   `json:"valid"`
+	MaskedKey  string `json:"maskedKey,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ProviderHealth is a model provider's circuit breaker. A provider is
+// "open", and skipped, until RetryAt; the first call after that is let
+// through "half-open" to see whether it has recovered.
+type ProviderHealth struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	Healthy             bool       `json:"healthy"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	RetryAt             *time.Time `json:"retryAt,omitempty"`
+}
+
+const (
+	ProviderClosed   = "closed"
+	ProviderOpen     = "open"
+	ProviderHalfOpen = "half-open"
+)
+
+// SettingsTest is the outcome of one small completion against a provider and
+// model. Reply is what the model answered, cut short.
+type SettingsTest struct {
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	Success    bool   `json:"success"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Reply      string `json:"reply,omitempty"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
 type SettingsResponse struct {
 	Provider  string           `json:"provider"`
 	Model     string           `json:"model"`
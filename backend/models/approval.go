@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Approval states of an analysis: approved at its current version, approved
+// only before its latest edit, or not at all.
+const (
+	ApprovalApproved = "approved"
+	ApprovalStale    = "stale"
+	ApprovalNone     = "none"
+)
+
+// AnalysisApproval is a reviewer's sign-off on one version of an analysis.
+// Current is false once the analysis has been edited since.
+type AnalysisApproval struct {
+	ID           int64     `json:"id"`
+	ApprovedBy   int64     `json:"approvedBy"`
+	ApproverName string    `json:"approverName"`
+	Version      int64     `json:"version"`
+	Current      bool      `json:"current"`
+	Note         string    `json:"note,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
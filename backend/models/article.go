@@ -15,6 +15,25 @@ type PhaseOneInput struct {
 	URL      string `json:"url,omitempty"`
 	Language string `json:"language,omitempty"`
 	Category string `json:"category,omitempty"`
+
+	// Sources switches to corroboration mode when it has two or more entries;
+	// Text and URL are then ignored.
+	Sources []SourceInput `json:"sources,omitempty"`
+
+	// Images are transcribed and their text put ahead of Text.
+	Images []ImageInput `json:"-"`
+
+	// ArticleMode is ArticleModeParagraph (the default) or ArticleModeLongForm.
+	ArticleMode string `json:"articleMode,omitempty"`
+
+	// Lengths are optional targets for the article and title options.
+	Lengths LengthTargets `json:"lengths"`
+
+	// Render loads submitted URLs in a headless browser rather than fetching
+	// them directly. It needs RENDER_ENABLED.
+	Render bool `json:"render,omitempty"`
+
+	Submission *Submission `json:"submission,omitempty"`
 }
 
 type PhaseOneResponse struct {
@@ -23,4 +42,22 @@ type PhaseOneResponse struct {
 	Facts     []string `json:"facts"`
 	Gaps      []string `json:"gaps"`
 	Article   string   `json:"article"`
+
+	InputLanguage *LanguageDetection `json:"inputLanguage,omitempty"`
+
+	SuggestedCategory string `json:"suggestedCategory,omitempty"`
+
+	Sources       []AnalysisSource    `json:"sources,omitempty"`
+	Corroboration []FactCorroboration `json:"corroboration,omitempty"`
+
+	Images []AnalysisImage `json:"images,omitempty"`
+
+	MergedFrom []int64 `json:"mergedFrom,omitempty"`
+
+	ArticleMode string           `json:"articleMode"`
+	Sections    []ArticleSection `json:"sections,omitempty"`
+	Readability Readability      `json:"readability"`
+
+	Timeline      []TimelineEvent `json:"timeline"`
+	NumericClaims []NumericClaim  `json:"numericClaims"`
 }
@@ -0,0 +1,24 @@
+package models
+
+const (
+	ArticleModeParagraph = "paragraph"
+	ArticleModeLongForm  = "long-form"
+)
+
+// Section keys of a long-form article, in the order they are rendered.
+const (
+	SectionLede       = "lede"
+	SectionBackground = "background"
+	SectionUnverified = "unverified"
+	SectionNext       = "next"
+)
+
+var ArticleSectionKeys = []string{SectionLede, SectionBackground, SectionUnverified, SectionNext}
+
+// ArticleSection is one part of a long-form article. The lede is rendered
+// without its heading.
+type ArticleSection struct {
+	Key     string `json:"key"`
+	Heading string `json:"heading"`
+	Body    string `json:"body"`
+}
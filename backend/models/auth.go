@@ -0,0 +1,116 @@
+package models
+
+import "time"
+
+const (
+	PermissionEditAnalyses    = "edit_analyses"
+	PermissionApprove         = "approve"
+	PermissionClearFlags      = "clear_flags"
+	PermissionComment         = "comment"
+	PermissionManageAnalyses  = "manage_analyses"
+	PermissionManageProviders = "manage_providers"
+	PermissionManagePrivacy   = "manage_privacy"
+	PermissionManagePrompts   = "manage_prompts"
+	PermissionManageSources   = "manage_sources"
+	PermissionManageUsers     = "manage_users"
+	PermissionPublish         = "publish"
+	PermissionViewDiagnostics = "view_diagnostics"
+	PermissionViewSource      = "view_source"
+)
+
+var AllPermissions = []string{
+	PermissionEditAnalyses,
+	PermissionApprove,
+	PermissionClearFlags,
+	PermissionComment,
+	PermissionManageAnalyses,
+	PermissionManageProviders,
+	PermissionManagePrivacy,
+	PermissionManagePrompts,
+	PermissionManageSources,
+	PermissionManageUsers,
+	PermissionPublish,
+	PermissionViewDiagnostics,
+	PermissionViewSource,
+}
+
+type Principal struct {
+	UserID        int64    `json:"userId"`
+	Email         string   `json:"email"`
+	DisplayName   string   `json:"displayName"`
+	Role          string   `json:"role"`
+	Permissions   []string `json:"permissions"`
+	Authenticated bool     `json:"authenticated"`
+	// SessionID is set when the caller signed in with an access token
+	// rather than an API key.
+	SessionID int64 `json:"sessionId,omitempty"`
+}
+
+func (p Principal) Can(permission string) bool {
+	for _, item := range p.Permissions {
+		if item == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Visibility says which sensitive parts of a response the caller may receive.
+type Visibility struct {
+	SourceText  bool
+	Diagnostics bool
+}
+
+func (p Principal) Visibility() Visibility {
+	return Visibility{
+		SourceText:  p.Can(PermissionViewSource),
+		Diagnostics: p.Can(PermissionViewDiagnostics),
+	}
+}
+
+type Role struct {
+	ID          int64    `json:"id"`
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type User struct {
+	ID          int64     `json:"id"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"displayName"`
+	Role        string    `json:"role"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type CreatedUserResponse struct {
+	User   User   `json:"user"`
+	APIKey string `json:"apiKey"`
+}
+
+// AuthSession is one signed-in device. LastUsedAt moves when its access
+// token is refreshed, not on every request.
+type AuthSession struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"userId"`
+	Device     string     `json:"device,omitempty"`
+	UserAgent  string     `json:"userAgent,omitempty"`
+	ClientIP   string     `json:"clientIp,omitempty"`
+	Current    bool       `json:"current"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// SessionTokens answers a login or refresh. The refresh token is only ever
+// sent here and replaces the one presented, which stops working.
+type SessionTokens struct {
+	AccessToken      string      `json:"accessToken"`
+	TokenType        string      `json:"tokenType"`
+	ExpiresIn        int         `json:"expiresIn"`
+	RefreshToken     string      `json:"refreshToken"`
+	RefreshExpiresAt time.Time   `json:"refreshExpiresAt"`
+	Session          AuthSession `json:"session"`
+}
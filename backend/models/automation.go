@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// WebhookEventVersion is raised only when a field of WebhookEvent changes
+// meaning or goes away. Fields may be added to an event without raising it.
+const WebhookEventVersion = 1
+
+// WebhookEvent is what WEBHOOK_URL receives for each pipeline event, as
+// automation tools such as Zapier and Make expect it: flat, with an ID to
+// deduplicate deliveries on.
+type WebhookEvent struct {
+	ID         string           `json:"id"`
+	Event      string           `json:"event"`
+	Version    int              `json:"version"`
+	OccurredAt time.Time        `json:"occurredAt"`
+	Data       WebhookEventData `json:"data"`
+}
+
+// WebhookEventData describes the analysis the event is about. AnalysisID is
+// null for an analysis that failed before it was saved; URL opens the
+// analysis in the admin app when ADMIN_URL is set.
+type WebhookEventData struct {
+	AnalysisID *int64 `json:"analysisId"`
+	Headline   string `json:"headline"`
+	Detail     string `json:"detail,omitempty"`
+	URL        string `json:"url,omitempty"`
+}
+
+// AnalysisChange is an analysis as it was when it last changed. ID differs
+// for each change, so polling triggers can deduplicate on it.
+type AnalysisChange struct {
+	ID         string    `json:"id"`
+	AnalysisID int64     `json:"analysisId"`
+	Title      string    `json:"title"`
+	Status     string    `json:"status"`
+	Category   string    `json:"category"`
+	Version    int64     `json:"version"`
+	URL        string    `json:"url,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// AnalysisChanges is a page of changes, oldest first. Cursor is passed back
+// to fetch the changes after the last one; it stays the same while nothing
+// has changed.
+type AnalysisChanges struct {
+	Items   []AnalysisChange `json:"items"`
+	Cursor  string           `json:"cursor"`
+	HasMore bool             `json:"hasMore"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Clip is a page sent in from the browser extension: where the reporter was,
+// what the page was called and whatever they had selected.
+type Clip struct {
+	URL      string `json:"url,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Language string `json:"language,omitempty"`
+	Category string `json:"category,omitempty"`
+
+	Submission *Submission `json:"submission,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	PresenceViewing = "viewing"
+	PresenceEditing = "editing"
+)
+
+const (
+	CollaborationPresence        = "presence"
+	CollaborationAnalysisUpdated = "analysis-updated"
+	CollaborationFactAdded       = "fact-added"
+	CollaborationFactUpdated     = "fact-updated"
+	CollaborationFactDeleted     = "fact-deleted"
+	CollaborationFactRestored    = "fact-restored"
+	CollaborationFactsReordered  = "facts-reordered"
+	CollaborationGapUpdated      = "gap-updated"
+)
+
+// CollaborationEvent is sent to everyone with an analysis open. Presence
+// events list who is there; the others say what changed and by whom, and
+// carry the new version where the record has one.
+type CollaborationEvent struct {
+	Type       string                `json:"type"`
+	AnalysisID int64                 `json:"analysisId"`
+	EntityID   int64                 `json:"entityId,omitempty"`
+	Version    int64                 `json:"version,omitempty"`
+	UserID     *int64                `json:"userId,omitempty"`
+	Members    []CollaborationMember `json:"members,omitempty"`
+	At         time.Time             `json:"at"`
+}
+
+// CollaborationMember is one user with an analysis open. Target says what
+// they are editing, such as "fact:12", and is empty while viewing.
+type CollaborationMember struct {
+	UserID int64     `json:"userId"`
+	Name   string    `json:"name"`
+	State  string    `json:"state"`
+	Target string    `json:"target,omitempty"`
+	Since  time.Time `json:"since"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Comment is an editor's note on an analysis. A thread is a top-level comment
+// and its replies; anchors and resolution belong to the thread, so replies
+// carry neither.
+type Comment struct {
+	ID         int64      `json:"id"`
+	ArticleID  int64      `json:"articleId"`
+	ParentID   *int64     `json:"parentId,omitempty"`
+	FactID     *int64     `json:"factId,omitempty"`
+	GapID      *int64     `json:"gapId,omitempty"`
+	AuthorID   *int64     `json:"authorId"`
+	AuthorName string     `json:"authorName"`
+	Body       string     `json:"body"`
+	Resolved   bool       `json:"resolved"`
+	ResolvedBy *int64     `json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	Replies    []Comment  `json:"replies,omitempty"`
+}
@@ -0,0 +1,50 @@
+package models
+
+const (
+	ArticleDiffSame    = "same"
+	ArticleDiffChanged = "changed"
+	ArticleDiffRemoved = "removed"
+	ArticleDiffAdded   = "added"
+)
+
+// AnalysisComparison sets two analyses of the same story side by side, as
+// when two outlets' coverage is compared. Left and right follow the order
+// the ids were given in.
+type AnalysisComparison struct {
+	Left  ComparedAnalysis `json:"left"`
+	Right ComparedAnalysis `json:"right"`
+
+	// SharedFacts are reported by both; ContradictoryFacts are the same
+	// claim with different figures.
+	SharedFacts        []FactPair `json:"sharedFacts"`
+	ContradictoryFacts []FactPair `json:"contradictoryFacts"`
+	LeftOnlyFacts      []string   `json:"leftOnlyFacts"`
+	RightOnlyFacts     []string   `json:"rightOnlyFacts"`
+
+	// Article diffs the two article texts a sentence at a time.
+	Article []ArticleDiffLine `json:"article"`
+}
+
+type ComparedAnalysis struct {
+	ID           int64  `json:"id"`
+	Title        string `json:"title"`
+	Status       string `json:"status"`
+	Language     string `json:"language"`
+	SourceURL    string `json:"sourceUrl"`
+	SourceDomain string `json:"sourceDomain"`
+	FactCount    int    `json:"factCount"`
+}
+
+type FactPair struct {
+	Left       string  `json:"left"`
+	Right      string  `json:"right"`
+	Similarity float64 `json:"similarity"`
+}
+
+// ArticleDiffLine is one row of the side-by-side diff. Removed rows only
+// have Left and added rows only Right.
+type ArticleDiffLine struct {
+	Op    string `json:"op"`
+	Left  string `json:"left,omitempty"`
+	Right string `json:"right,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// AnalysisCorrection records a mistake in a published analysis and the text
+// that corrects it.
+type AnalysisCorrection struct {
+	ID            int64     `json:"id"`
+	Mistake       string    `json:"mistake"`
+	CorrectedText string    `json:"correctedText"`
+	CreatedBy     *int64    `json:"createdBy,omitempty"`
+	CreatedByName string    `json:"createdByName,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+const (
+	FactCorroborated = "corroborated"
+	FactSingleSource = "single-source"
+)
+
+// SourceInput is one report of the event in a multi-source analysis.
+type SourceInput struct {
+	Text string `json:"text,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type AnalysisSource struct {
+	Position      int           `json:"position"`
+	URL           string        `json:"url,omitempty"`
+	FetchStrategy string        `json:"fetchStrategy,omitempty"`
+	FactCount     int           `json:"factCount"`
+	SourceRating  *SourceRating `json:"sourceRating,omitempty"`
+}
+
+// FactCorroboration says which sources reported a fact. Sources holds
+// AnalysisSource positions.
+type FactCorroboration struct {
+	Text    string `json:"text"`
+	Status  string `json:"status"`
+	Sources []int  `json:"sources"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSubscription is whether a user gets a digest email of completed
+// analyses, and how often.
+type DigestSubscription struct {
+	Subscribed bool       `json:"subscribed"`
+	Frequency  string     `json:"frequency,omitempty"`
+	LastSentAt *time.Time `json:"lastSentAt,omitempty"`
+}
+
+type DigestRunResult struct {
+	Due    int `json:"due"`
+	Sent   int `json:"sent"`
+	Empty  int `json:"empty"`
+	Failed int `json:"failed"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Entity is a person, place or organisation an analysis names, with what
+// Wikidata says about it. WikidataID is empty when Wikidata had no match.
+type Entity struct {
+	Name         string `json:"name"`
+	Mentions     int    `json:"mentions"`
+	WikidataID   string `json:"wikidataId,omitempty"`
+	Label        string `json:"label,omitempty"`
+	Description  string `json:"description,omitempty"`
+	WikidataURL  string `json:"wikidataUrl,omitempty"`
+	WikipediaURL string `json:"wikipediaUrl,omitempty"`
+}
+
+type EntityResult struct {
+	ArticleID int64 `json:"articleId"`
+	Entities  int   `json:"entities"`
+	Matched   int   `json:"matched"`
+	Cached    int   `json:"cached"`
+	Failed    int   `json:"failed"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// Error codes sent as "code" in every error response. Messages are for
+// people and may change wording; clients should branch on the code.
+const (
+	ErrorCodeInvalidRequest      = "ERR_INVALID_REQUEST"
+	ErrorCodeUnauthenticated     = "ERR_UNAUTHENTICATED"
+	ErrorCodeForbidden           = "ERR_FORBIDDEN"
+	ErrorCodeFeatureDisabled     = "ERR_FEATURE_DISABLED"
+	ErrorCodeNotFound            = "ERR_NOT_FOUND"
+	ErrorCodeVersionConflict     = "ERR_VERSION_CONFLICT"
+	ErrorCodeVersionRequired     = "ERR_VERSION_REQUIRED"
+	ErrorCodeNothingToUndo       = "ERR_NOTHING_TO_UNDO"
+	ErrorCodeNothingToRedo       = "ERR_NOTHING_TO_REDO"
+	ErrorCodeBodyTooLarge        = "ERR_BODY_TOO_LARGE"
+	ErrorCodeURLUnreachable      = "ERR_URL_UNREACHABLE"
+	ErrorCodeURLNoText           = "ERR_URL_NO_TEXT"
+	ErrorCodeEmptyFacts          = "ERR_EMPTY_FACTS"
+	ErrorCodeContentSpam         = "ERR_CONTENT_SPAM"
+	ErrorCodeContentAdult        = "ERR_CONTENT_ADULT"
+	ErrorCodeContentGibberish    = "ERR_CONTENT_GIBBERISH"
+	ErrorCodeNotConfigured       = "ERR_NOT_CONFIGURED"
+	ErrorCodeProviderUnavailable = "ERR_PROVIDER_UNAVAILABLE"
+	ErrorCodeProviderQuota       = "ERR_PROVIDER_QUOTA"
+	ErrorCodeInternal            = "ERR_INTERNAL"
+)
@@ -0,0 +1,14 @@
+package models
+
+const (
+	BundleFormatMarkdown = "markdown"
+	BundleFormatHTML     = "html"
+)
+
+// ExportBundle is a rendered file of completed analyses, ready to download.
+type ExportBundle struct {
+	Filename    string
+	ContentType string
+	Body        []byte
+	Analyses    int
+}
@@ -0,0 +1,26 @@
+package models
+
+// FactCheckMatch is a published fact-check whose claim resembles one of an
+// analysis's facts.
+type FactCheckMatch struct {
+	ID            int64  `json:"id"`
+	FactID        int64  `json:"factId"`
+	ClaimText     string `json:"claimText"`
+	Claimant      string `json:"claimant,omitempty"`
+	ClaimDate     string `json:"claimDate,omitempty"`
+	Publisher     string `json:"publisher"`
+	PublisherSite string `json:"publisherSite,omitempty"`
+	URL           string `json:"url"`
+	Title         string `json:"title,omitempty"`
+	Rating        string `json:"rating"`
+	Language      string `json:"language,omitempty"`
+	ReviewDate    string `json:"reviewDate,omitempty"`
+}
+
+type FactCheckResult struct {
+	ArticleID int64 `json:"articleId"`
+	Checked   int   `json:"checked"`
+	Matched   int   `json:"matched"`
+	Matches   int   `json:"matches"`
+	Failed    int   `json:"failed"`
+}
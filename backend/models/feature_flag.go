@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	FlagWebSearch        = "enable_web_search"
+	FlagTimeline         = "enable_timeline"
+	FlagNumericClaims    = "enable_numeric_claims"
+	FlagHeadlineGuidance = "enable_headline_guidance"
+)
+
+// Where a flag's current value comes from. An override set through the API
+// wins over FEATURE_FLAGS, which wins over the built-in default.
+const (
+	FlagSourceDefault     = "default"
+	FlagSourceEnvironment = "environment"
+	FlagSourceOverride    = "override"
+)
+
+// FeatureFlag gates a pipeline step or route. While enabled, RolloutPercent
+// of subjects (users for routes, runs for pipeline steps) get the feature.
+type FeatureFlag struct {
+	Key            string     `json:"key"`
+	Description    string     `json:"description"`
+	Enabled        bool       `json:"enabled"`
+	RolloutPercent int        `json:"rolloutPercent"`
+	Default        bool       `json:"default"`
+	Source         string     `json:"source"`
+	UpdatedAt      *time.Time `json:"updatedAt,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Kinds of legal and sensitivity flag.
+const (
+	FlagKindDefamation   = "defamation"
+	FlagKindOngoingTrial = "ongoing_trial"
+	FlagKindPrivacy      = "privacy"
+	FlagKindOther        = "other"
+)
+
+var FlagKinds = []string{FlagKindDefamation, FlagKindOngoingTrial, FlagKindPrivacy, FlagKindOther}
+
+// Who raised a flag: an editor, the automated sensitivity check, or the
+// PII scan of submitted text.
+const (
+	FlagSourceEditor    = "editor"
+	FlagSourceAutomated = "automated"
+	FlagSourcePII       = "pii"
+)
+
+// AnalysisFlag marks an analysis as legally sensitive. It stays open, and
+// keeps the analysis from being completed, until someone clears it with a
+// note.
+type AnalysisFlag struct {
+	ID            int64      `json:"id"`
+	Kind          string     `json:"kind"`
+	Reason        string     `json:"reason"`
+	Excerpt       string     `json:"excerpt,omitempty"`
+	Source        string     `json:"source"`
+	FlaggedBy     *int64     `json:"flaggedBy,omitempty"`
+	FlaggedByName string     `json:"flaggedByName,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ClearedBy     *int64     `json:"clearedBy,omitempty"`
+	ClearedByName string     `json:"clearedByName,omitempty"`
+	ClearedAt     *time.Time `json:"clearedAt,omitempty"`
+	ClearanceNote string     `json:"clearanceNote,omitempty"`
+}
+
+type SensitivityResult struct {
+	ArticleID int64 `json:"articleId"`
+	Flagged   int   `json:"flagged"`
+	Skipped   int   `json:"skipped"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type GlossaryTerm struct {
+	ID        int64     `json:"id"`
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	Notes     string    `json:"notes"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Glossary struct {
+	Language          string         `json:"language"`
+	Version           int            `json:"version"`
+	StyleNotes        string         `json:"styleNotes"`
+	UpdatedAt         *time.Time     `json:"updatedAt"`
+	Terms             []GlossaryTerm `json:"terms"`
+	StaleTranslations int64          `json:"staleTranslations"`
+}
+
+type RetranslationResult struct {
+	Language        string `json:"language"`
+	GlossaryVersion int    `json:"glossaryVersion"`
+	Total           int    `json:"total"`
+	Updated         int    `json:"updated"`
+	SkippedEdited   int    `json:"skippedEdited"`
+	Failed          int    `json:"failed"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// UnsupportedSentence is an article sentence the grounding check could not
+// tie to the analysis's included facts. Position is the sentence's 1-based
+// place in the article.
+type UnsupportedSentence struct {
+	ID       int64  `json:"id"`
+	Position int    `json:"position"`
+	Sentence string `json:"sentence"`
+	Reason   string `json:"reason"`
+}
+
+type GroundingResult struct {
+	ArticleID   int64 `json:"articleId"`
+	Sentences   int   `json:"sentences"`
+	Unsupported int   `json:"unsupported"`
+}
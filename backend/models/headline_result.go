@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Headline styles, as headline test results are grouped by them.
+const (
+	HeadlineStyleQuestion  = "question"
+	HeadlineStyleQuote     = "quote"
+	HeadlineStyleNumber    = "number"
+	HeadlineStyleLabel     = "label"
+	HeadlineStyleStatement = "statement"
+)
+
+// HeadlineVariant is one headline's figures in a test, as the publishing
+// platform reports them.
+type HeadlineVariant struct {
+	Headline    string `json:"headline"`
+	Impressions int64  `json:"impressions"`
+	Clicks      int64  `json:"clicks"`
+}
+
+// HeadlineResult is the latest figures for one headline in one test.
+// HeadlineID is set when the headline is one of the analysis's generated
+// options, and Model is the model that wrote them.
+type HeadlineResult struct {
+	ID               int64     `json:"id"`
+	ArticleID        int64     `json:"articleId"`
+	HeadlineID       *int64    `json:"headlineId,omitempty"`
+	TestID           string    `json:"testId,omitempty"`
+	Headline         string    `json:"headline"`
+	Platform         string    `json:"platform,omitempty"`
+	Style            string    `json:"style"`
+	WordCount        int       `json:"wordCount"`
+	Model            string    `json:"model,omitempty"`
+	Impressions      int64     `json:"impressions"`
+	Clicks           int64     `json:"clicks"`
+	ClickThroughRate float64   `json:"clickThroughRate"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+type HeadlinePerformance struct {
+	Group            string  `json:"group"`
+	Variants         int64   `json:"variants"`
+	Impressions      int64   `json:"impressions"`
+	Clicks           int64   `json:"clicks"`
+	ClickThroughRate float64 `json:"clickThroughRate"`
+}
+
+// HeadlineStats sums up headline tests over the last Days days, best
+// click-through first in each grouping. Guidance is what new headline prompts
+// are told, empty until there is enough data to say anything.
+type HeadlineStats struct {
+	Days             int                   `json:"days"`
+	Variants         int64                 `json:"variants"`
+	Impressions      int64                 `json:"impressions"`
+	Clicks           int64                 `json:"clicks"`
+	ClickThroughRate float64               `json:"clickThroughRate"`
+	ByStyle          []HeadlinePerformance `json:"byStyle"`
+	ByLength         []HeadlinePerformance `json:"byLength"`
+	ByModel          []HeadlinePerformance `json:"byModel"`
+	Guidance         string                `json:"guidance"`
+}
@@ -0,0 +1,22 @@
+package models
+
+const (
+	DependencyUp      = "up"
+	DependencyDown    = "down"
+	DependencySkipped = "skipped"
+)
+
+// DependencyCheck is one thing /readyz needs before the instance takes
+// traffic. Detail carries check-specific data, such as pool stats or the
+// pending migrations.
+type DependencyCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	Detail    any    `json:"detail,omitempty"`
+}
+
+type Readiness struct {
+	Status string                     `json:"status"`
+	Checks map[string]DependencyCheck `json:"checks"`
+}
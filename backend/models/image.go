@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ImageInput is an uploaded image whose text is read and analysed.
+type ImageInput struct {
+	FileName string
+	MimeType string
+	Data     []byte
+}
+
+// AnalysisImage records an image an analysis was read from. SHA256 names the
+// stored file.
+type AnalysisImage struct {
+	ID        int64     `json:"id"`
+	Position  int       `json:"position"`
+	FileName  string    `json:"fileName,omitempty"`
+	MimeType  string    `json:"mimeType"`
+	SizeBytes int64     `json:"sizeBytes"`
+	SHA256    string    `json:"sha256"`
+	OCRText   string    `json:"ocrText,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
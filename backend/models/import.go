@@ -0,0 +1,37 @@
+package models
+
+const (
+	ImportSourceWordPress = "wordpress"
+	ImportSourceRSS       = "rss"
+)
+
+// ArticleImport asks for a site's published articles to be pulled in and
+// analysed. URL is the WordPress site or the RSS/Atom feed. Articles are
+// taken newest first, up to Limit, back to the date Since when it is given.
+type ArticleImport struct {
+	Source   string `json:"source,omitempty"`
+	URL      string `json:"url"`
+	Since    string `json:"since,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+	Language string `json:"language,omitempty"`
+	Category string `json:"category,omitempty"`
+
+	Submission *Submission `json:"submission,omitempty"`
+}
+
+type ImportFailure struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// ImportResult counts what an import did. Skipped articles had already been
+// analysed from the same URL.
+type ImportResult struct {
+	Source   string          `json:"source"`
+	Found    int             `json:"found"`
+	Imported int             `json:"imported"`
+	Skipped  int             `json:"skipped"`
+	Failed   int             `json:"failed"`
+	Analyses []int64         `json:"analyses"`
+	Failures []ImportFailure `json:"failures,omitempty"`
+}
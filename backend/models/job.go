@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobTypeRetranslate = "retranslate"
+	JobTypeClip        = "clip"
+	JobTypeFactCheck   = "fact-check"
+	JobTypeGrounding   = "grounding-check"
+	JobTypeFollowUp    = "follow-up-detection"
+	JobTypeGapRecheck  = "gap-recheck"
+	JobTypeGapResearch = "gap-research"
+	JobTypeEntities    = "entity-enrichment"
+	JobTypeDigest      = "digest-email"
+	JobTypeSensitivity = "sensitivity-check"
+	JobTypeRetention   = "draft-retention"
+	JobTypeImport      = "article-import"
+	JobTypeTelegram    = "telegram-analysis"
+
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+type Job struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Progress   int             `json:"progress"`
+	Total      int             `json:"total"`
+	CreatedBy  *int64          `json:"createdBy"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt"`
+	FinishedAt *time.Time      `json:"finishedAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Language is a supported output language. GenerateInEnglish languages have
+// facts and the article written in English first and then translated, which
+// models handle more reliably than writing them directly.
+type Language struct {
+	Code              string `json:"code"`
+	Name              string `json:"name"`
+	NativeName        string `json:"nativeName"`
+	Script            string `json:"script"`
+	GenerateInEnglish bool   `json:"generateInEnglish"`
+}
+
+// LanguageDetection is the language an analysis input was found to be in.
+// Method is "script", "words", "llm" or "default" when nothing matched.
+type LanguageDetection struct {
+	Code       string  `json:"code"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+	Method     string  `json:"method"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// LengthRange limits the length of one generated output. Zero means no limit.
+type LengthRange struct {
+	MinWords int `json:"minWords,omitempty"`
+	MaxWords int `json:"maxWords,omitempty"`
+	MinChars int `json:"minChars,omitempty"`
+	MaxChars int `json:"maxChars,omitempty"`
+}
+
+func (r LengthRange) IsZero() bool {
+	return r == LengthRange{}
+}
+
+// LengthTargets are the lengths requested for an analysis's article and for
+// each headline and strapline option.
+type LengthTargets struct {
+	Article   LengthRange `json:"article"`
+	Headline  LengthRange `json:"headline"`
+	Strapline LengthRange `json:"strapline"`
+}
+
+func (t LengthTargets) IsZero() bool {
+	return t.Article.IsZero() && t.Headline.IsZero() && t.Strapline.IsZero()
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+type LLMCall struct {
+	ID           int64     `json:"id"`
+	RunID        string    `json:"runId"`
+	ArticleID    *int64    `json:"articleId"`
+	Step         string    `json:"step"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	JSONMode     bool      `json:"jsonMode"`
+	SystemPrompt string    `json:"systemPrompt"`
+	UserPrompt   string    `json:"userPrompt"`
+	Response     string    `json:"response"`
+	Status       string    `json:"status"`
+	ErrorClass   string    `json:"errorClass"`
+	ErrorMessage string    `json:"errorMessage"`
+	HTTPStatus   int       `json:"httpStatus"`
+	LatencyMS    int64     `json:"latencyMs"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type LLMCallSummary struct {
+	ID              int64     `json:"id"`
+	RunID           string    `json:"runId"`
+	ArticleID       *int64    `json:"articleId"`
+	Step            string    `json:"step"`
+	Provider        string    `json:"provider"`
+	Model           string    `json:"model"`
+	Status          string    `json:"status"`
+	ErrorClass      string    `json:"errorClass"`
+	ErrorMessage    string    `json:"errorMessage"`
+	HTTPStatus      int       `json:"httpStatus"`
+	LatencyMS       int64     `json:"latencyMs"`
+	PromptPreview   string    `json:"promptPreview"`
+	ResponsePreview string    `json:"responsePreview"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+type LLMCallFilter struct {
+	ArticleID  int64
+	Step       string
+	Model      string
+	Provider   string
+	Status     string
+	ErrorClass string
+	Search     string
+	Page       int
+	PageSize   int
+}
+
+type LLMCallPage struct {
+	Items    []LLMCallSummary `json:"items"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
+	Total    int64            `json:"total"`
+}
+
+// LLMCacheStats describes the completion cache, kept in the "database" or in
+// "redis". Entries and StoredHits come from the store (Redis keeps no hit
+// counts); Hits, Misses and HitRate count lookups by this process since Since.
+type LLMCacheStats struct {
+	Enabled    bool           `json:"enabled"`
+	Backend    string         `json:"backend"`
+	TTL        string         `json:"ttl"`
+	Since      time.Time      `json:"since"`
+	Entries    int64          `json:"entries"`
+	Expired    int64          `json:"expired"`
+	StoredHits int64          `json:"storedHits"`
+	Hits       int64          `json:"hits"`
+	Misses     int64          `json:"misses"`
+	HitRate    float64        `json:"hitRate"`
+	Steps      []LLMCacheStep `json:"steps"`
+}
+
+type LLMCacheStep struct {
+	Step       string  `json:"step"`
+	Entries    int64   `json:"entries"`
+	StoredHits int64   `json:"storedHits"`
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
+	HitRate    float64 `json:"hitRate"`
+}
+
+type LLMCachePurge struct {
+	Deleted int64 `json:"deleted"`
+}
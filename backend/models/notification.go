@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	NotificationAnalysisAssigned  = "analysis-assigned"
+	NotificationAnalysisCompleted = "analysis-completed"
+	NotificationJobFailed         = "job-failed"
+	NotificationGapLeads          = "gap-leads"
+)
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	Kind      string     `json:"kind"`
+	ArticleID *int64     `json:"articleId,omitempty"`
+	JobID     *int64     `json:"jobId,omitempty"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body,omitempty"`
+	Read      bool       `json:"read"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type NotificationList struct {
+	Items  []Notification `json:"items"`
+	Unread int64          `json:"unread"`
+}
@@ -0,0 +1,38 @@
+package models
+
+// Kinds of numeric claim.
+const (
+	NumericAmount     = "amount"
+	NumericPercentage = "percentage"
+	NumericCount      = "count"
+	NumericDate       = "date"
+)
+
+var NumericClaimKinds = []string{NumericAmount, NumericPercentage, NumericCount, NumericDate}
+
+// A claim's place in a breakdown: a stated total, or one of the figures that
+// make it up.
+const (
+	NumericRoleTotal = "total"
+	NumericRolePart  = "part"
+)
+
+// GapOriginNumericCheck marks open questions raised by the numeric checks
+// rather than by the model.
+const GapOriginNumericCheck = "numeric-check"
+
+// NumericClaim is a figure stated in an analysis's source. Date claims carry
+// the date in Date (YYYY-MM-DD, YYYY-MM or YYYY) and its year in Value.
+// Claims sharing a Group form a breakdown of the group's total.
+type NumericClaim struct {
+	ID       int64   `json:"id"`
+	Position int     `json:"position"`
+	Kind     string  `json:"kind"`
+	Value    float64 `json:"value"`
+	Unit     string  `json:"unit"`
+	Date     string  `json:"date,omitempty"`
+	Subject  string  `json:"subject"`
+	Group    string  `json:"group,omitempty"`
+	Role     string  `json:"role,omitempty"`
+	Text     string  `json:"text"`
+}
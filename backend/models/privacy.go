@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// PrivacyExport is everything stored about the analyses matching a source
+// URL or entity. Each record is a raw table row keyed by column name; stored
+// images and page snapshots are listed by their rows, not embedded.
+type PrivacyExport struct {
+	SourceURL   string                  `json:"sourceUrl,omitempty"`
+	Entity      string                  `json:"entity,omitempty"`
+	GeneratedAt time.Time               `json:"generatedAt"`
+	Analyses    []PrivacyExportAnalysis `json:"analyses"`
+}
+
+type PrivacyExportAnalysis struct {
+	ID      int64                       `json:"id"`
+	Article map[string]any              `json:"article"`
+	Records map[string][]map[string]any `json:"records"`
+}
+
+// PrivacyPurge is the audit record of one purge.
+type PrivacyPurge struct {
+	ID           int64     `json:"id"`
+	PurgedBy     *int64    `json:"purgedBy,omitempty"`
+	PurgedByName string    `json:"purgedByName,omitempty"`
+	Reason       string    `json:"reason"`
+	AnalysisIDs  []int64   `json:"analysisIds"`
+	Analyses     int       `json:"analyses"`
+	LLMCalls     int       `json:"llmCalls"`
+	Jobs         int       `json:"jobs"`
+	FilesRemoved int       `json:"filesRemoved"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// PromptTemplate is one pipeline prompt. ActiveVersion 0 means the built-in
+// default body is in use.
+type PromptTemplate struct {
+	Key           string     `json:"key"`
+	Description   string     `json:"description"`
+	Variables     []string   `json:"variables"`
+	ActiveVersion int        `json:"activeVersion"`
+	LatestVersion int        `json:"latestVersion"`
+	Body          string     `json:"body"`
+	UpdatedAt     *time.Time `json:"updatedAt"`
+}
+
+type PromptVersion struct {
+	Version   int       `json:"version"`
+	Body      string    `json:"body"`
+	Notes     string    `json:"notes"`
+	Active    bool      `json:"active"`
+	CreatedBy *int64    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PromptTemplateDetail struct {
+	PromptTemplate
+	Default  string          `json:"default"`
+	Versions []PromptVersion `json:"versions"`
+}
+
+type PromptPreview struct {
+	Key       string            `json:"key"`
+	Variables map[string]string `json:"variables"`
+	Prompt    string            `json:"prompt"`
+}
@@ -0,0 +1,43 @@
+package models
+
+// Readability metrics. English articles are scored with Flesch reading ease,
+// other languages with LIX, which doesn't depend on counting syllables.
+const (
+	ReadabilityFlesch = "flesch"
+	ReadabilityLIX    = "lix"
+)
+
+// Reading levels, easiest first.
+const (
+	ReadingLevelVeryEasy      = "very-easy"
+	ReadingLevelEasy          = "easy"
+	ReadingLevelStandard      = "standard"
+	ReadingLevelDifficult     = "difficult"
+	ReadingLevelVeryDifficult = "very-difficult"
+)
+
+var ReadingLevels = []string{ReadingLevelVeryEasy, ReadingLevelEasy, ReadingLevelStandard, ReadingLevelDifficult, ReadingLevelVeryDifficult}
+
+// SimplifyLevels are the reading levels an article can be rewritten for.
+var SimplifyLevels = []string{ReadingLevelVeryEasy, ReadingLevelEasy, ReadingLevelStandard}
+
+// Readability scores an article. For Flesch a higher score is easier to
+// read; for LIX a lower one is. Grade is the Flesch-Kincaid US school grade
+// and is only set for Flesch.
+type Readability struct {
+	Metric string   `json:"metric"`
+	Score  float64  `json:"score"`
+	Grade  *float64 `json:"grade,omitempty"`
+	Level  string   `json:"level"`
+}
+
+// SimplifyResult reports a rewrite for a reading level. Reached is false when
+// the rewrite still reads harder than Target.
+type SimplifyResult struct {
+	ArticleID int64       `json:"articleId"`
+	Target    string      `json:"target"`
+	Article   string      `json:"article"`
+	Before    Readability `json:"before"`
+	After     Readability `json:"after"`
+	Reached   bool        `json:"reached"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	RetentionArchive = "archive"
+	RetentionDelete  = "delete"
+)
+
+// RetentionCandidate is a draft the next retention run would archive or
+// delete.
+type RetentionCandidate struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Category  string    `json:"category"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RetentionPreview is what the next retention run would do. Analyses lists
+// the oldest drafts first and may stop short of Total.
+type RetentionPreview struct {
+	Enabled  bool                 `json:"enabled"`
+	Action   string               `json:"action"`
+	Days     int                  `json:"days"`
+	Cutoff   *time.Time           `json:"cutoff,omitempty"`
+	Total    int64                `json:"total"`
+	Analyses []RetentionCandidate `json:"analyses"`
+}
+
+type RetentionResult struct {
+	Action       string `json:"action"`
+	Archived     int    `json:"archived"`
+	Deleted      int    `json:"deleted"`
+	FilesRemoved int    `json:"filesRemoved"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ViewFilters holds the analysis list filters a saved view applies, in the
+// form the list endpoint accepts them; assignedTo and createdBy may be "me".
+type ViewFilters struct {
+	AssignedTo string `json:"assignedTo,omitempty"`
+	CreatedBy  string `json:"createdBy,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Days       int    `json:"days,omitempty"`
+}
+
+type SavedView struct {
+	ID        int64       `json:"id"`
+	Name      string      `json:"name"`
+	Filters   ViewFilters `json:"filters"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
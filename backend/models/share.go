@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// AnalysisShare is a link that lets someone without an account read an
+// analysis until it expires or is revoked. URL is only known when the link
+// is created; just a hash of its token is stored.
+type AnalysisShare struct {
+	ID           int64      `json:"id"`
+	ArticleID    int64      `json:"articleId"`
+	URL          string     `json:"url,omitempty"`
+	Note         string     `json:"note,omitempty"`
+	CreatedBy    *int64     `json:"createdBy,omitempty"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+	Active       bool       `json:"active"`
+	Views        int64      `json:"views"`
+	LastViewedAt *time.Time `json:"lastViewedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// SharedAnalysis is the read-only view behind a share link: the draft and
+// what it rests on, without source text or anything about the newsroom.
+type SharedAnalysis struct {
+	Headline        string           `json:"headline"`
+	Strapline       string           `json:"strapline,omitempty"`
+	Category        string           `json:"category"`
+	ArticleText     string           `json:"articleText"`
+	ArticleSections []ArticleSection `json:"articleSections,omitempty"`
+	Facts           []SharedFact     `json:"facts"`
+	Gaps            []SharedGap      `json:"gaps"`
+	Note            string           `json:"note,omitempty"`
+	ExpiresAt       time.Time        `json:"expiresAt"`
+}
+
+type SharedFact struct {
+	Text      string `json:"text"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+type SharedGap struct {
+	Text     string `json:"text"`
+	Resolved bool   `json:"resolved"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SourceSnapshot records the page a URL source was read from, as fetched.
+// SHA256 is of the body as received, so a download can be checked against
+// it. Position matches AnalysisSource positions, and is 1 for an analysis of
+// a single URL.
+type SourceSnapshot struct {
+	ID            int64     `json:"id"`
+	Position      int       `json:"position"`
+	SourceURL     string    `json:"sourceUrl"`
+	FetchedURL    string    `json:"fetchedUrl"`
+	FetchStrategy string    `json:"fetchStrategy"`
+	ContentType   string    `json:"contentType,omitempty"`
+	SizeBytes     int64     `json:"sizeBytes"`
+	SHA256        string    `json:"sha256"`
+	FetchedAt     time.Time `json:"fetchedAt"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	CredibilityHigh    = "high"
+	CredibilityMedium  = "medium"
+	CredibilityLow     = "low"
+	CredibilityUnrated = "unrated"
+)
+
+var CredibilityRatings = []string{CredibilityHigh, CredibilityMedium, CredibilityLow, CredibilityUnrated}
+
+// Source is an entry in the credibility registry. Domains are stored without
+// a "www." prefix and also cover their subdomains.
+type Source struct {
+	ID          int64  `json:"id"`
+	Domain      string `json:"domain"`
+	Name        string `json:"name"`
+	Credibility string `json:"credibility"`
+	Notes       string `json:"notes"`
+	Flagged     bool   `json:"flagged"`
+	// SkipScreening lets submissions from the domain past content screening.
+	SkipScreening bool       `json:"skipScreening"`
+	AnalysisCount int64      `json:"analysisCount"`
+	UpdatedBy     *int64     `json:"updatedBy"`
+	UpdatedAt     *time.Time `json:"updatedAt"`
+}
+
+// SourceRating is the registry entry that applies to an analysis's origin.
+// Domain is the analysis's own host; RegistryDomain is the entry it matched.
+type SourceRating struct {
+	Domain         string `json:"domain"`
+	RegistryDomain string `json:"registryDomain,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Credibility    string `json:"credibility"`
+	Notes          string `json:"notes,omitempty"`
+	Flagged        bool   `json:"flagged"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// EditorStats summarizes each editor's work over the last Days days, From and
+// To inclusive.
+type EditorStats struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Days    int          `json:"days"`
+	Editors []EditorStat `json:"editors"`
+}
+
+// EditorStat counts analyses an editor created, reviewed as the assignee and
+// published within the period. AvgReviewHours runs from assignment to
+// completion over the reviewed analyses.
+type EditorStat struct {
+	UserID          int64   `json:"userId"`
+	Name            string  `json:"name"`
+	Email           string  `json:"email"`
+	Role            string  `json:"role"`
+	Active          bool    `json:"active"`
+	Created         int64   `json:"created"`
+	Reviewed        int64   `json:"reviewed"`
+	Published       int64   `json:"published"`
+	Comments        int64   `json:"comments"`
+	OpenAssignments int64   `json:"openAssignments"`
+	AvgReviewHours  float64 `json:"avgReviewHours"`
+}
+
+// TrendingStats ranks the topics and entities of the analyses created in the
+// last Days days, From and To inclusive.
+type TrendingStats struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Days     int            `json:"days"`
+	Topics   []TrendingItem `json:"topics"`
+	Entities []TrendingItem `json:"entities"`
+}
+
+// TrendingItem counts the analyses covering a topic or entity. Score weighs
+// each analysis by its age, so a subject covered a lot this week outranks one
+// covered as often a month ago.
+type TrendingItem struct {
+	Name       string    `json:"name"`
+	WikidataID string    `json:"wikidataId,omitempty"`
+	Analyses   int64     `json:"analyses"`
+	LastSeen   time.Time `json:"lastSeen"`
+	Score      float64   `json:"score"`
+}
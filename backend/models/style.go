@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+const (
+	StyleRuleBannedWord = "banned-word"
+	StyleRuleDateFormat = "date-format"
+	StyleRuleTitleCase  = "title-case"
+)
+
+var StyleRuleKinds = []string{StyleRuleBannedWord, StyleRuleDateFormat, StyleRuleTitleCase}
+
+// What a rule does with a violation: flag it for the editor, fix it in
+// generated copy and flag only what it could not fix, or, for banned words,
+// have the model write the copy again without them. A regenerate rule with a
+// replacement also fixes what the second attempt still has.
+const (
+	StyleActionFlag       = "flag"
+	StyleActionFix        = "fix"
+	StyleActionRegenerate = "regenerate"
+)
+
+// Values of a date-format rule.
+const (
+	DateFormatDayMonthYear = "day-month-year"
+	DateFormatMonthDayYear = "month-day-year"
+	DateFormatISO          = "iso"
+)
+
+var DateFormats = []string{DateFormatDayMonthYear, DateFormatMonthDayYear, DateFormatISO}
+
+// Values of a title-case rule, which applies to headlines and straplines.
+const (
+	TitleCaseSentence = "sentence"
+	TitleCaseTitle    = "title"
+)
+
+var TitleCaseStyles = []string{TitleCaseSentence, TitleCaseTitle}
+
+// StyleRule is one house style rule. Value is the banned word or phrase, the
+// date format, or the title case style; Replacement only applies to banned
+// words.
+type StyleRule struct {
+	ID          int64      `json:"id"`
+	Kind        string     `json:"kind"`
+	Value       string     `json:"value"`
+	Replacement string     `json:"replacement"`
+	Action      string     `json:"action"`
+	Notes       string     `json:"notes"`
+	UpdatedBy   *int64     `json:"updatedBy"`
+	UpdatedAt   *time.Time `json:"updatedAt"`
+}
+
+// StyleIssue is a violation of a style rule in an article, headline or
+// strapline. Suggestion is the corrected text when the rule can supply one.
+type StyleIssue struct {
+	RuleID     int64  `json:"ruleId"`
+	Kind       string `json:"kind"`
+	Field      string `json:"field"`
+	Text       string `json:"text"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+type StyleCheckResult struct {
+	Text   string       `json:"text"`
+	Issues []StyleIssue `json:"issues"`
+}
@@ -0,0 +1,36 @@
+package models
+
+const (
+	SubmissionChannelUI        = "ui"
+	SubmissionChannelAPI       = "api"
+	SubmissionChannelRSS       = "rss"
+	SubmissionChannelEmail     = "email"
+	SubmissionChannelExtension = "extension"
+	SubmissionChannelCLI       = "cli"
+	SubmissionChannelImport    = "import"
+	SubmissionChannelTelegram  = "telegram"
+	SubmissionChannelWhatsApp  = "whatsapp"
+)
+
+var SubmissionChannels = []string{
+	SubmissionChannelUI,
+	SubmissionChannelAPI,
+	SubmissionChannelRSS,
+	SubmissionChannelEmail,
+	SubmissionChannelExtension,
+	SubmissionChannelCLI,
+	SubmissionChannelImport,
+	SubmissionChannelTelegram,
+	SubmissionChannelWhatsApp,
+}
+
+// Submission records where an analysis came from. Params holds the request
+// parameters as sent, minus the article text, which is stored separately.
+type Submission struct {
+	SubmittedBy   *int64         `json:"submittedBy"`
+	SubmitterName string         `json:"submitterName,omitempty"`
+	Channel       string         `json:"channel"`
+	ClientIP      string         `json:"clientIp,omitempty"`
+	UserAgent     string         `json:"userAgent,omitempty"`
+	Params        map[string]any `json:"params,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// TelegramLink is a Telegram account linked to a user; what the account
+// sends the bot is analysed in Language, or the default output language.
+type TelegramLink struct {
+	ID             int64      `json:"id"`
+	TelegramUserID int64      `json:"telegramUserId"`
+	Username       string     `json:"username,omitempty"`
+	Language       string     `json:"language,omitempty"`
+	LinkedAt       *time.Time `json:"linkedAt"`
+}
+
+// TelegramLinkCode links the Telegram account that sends it to the bot as
+// "/link <code>" before ExpiresAt.
+type TelegramLinkCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
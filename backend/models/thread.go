@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// StoryThread is a story followed across analyses: each new analysis that
+// picks up an earlier one joins its thread.
+type StoryThread struct {
+	ID        int64              `json:"id"`
+	Title     string             `json:"title"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	Analyses  []StoryThreadEntry `json:"analyses"`
+}
+
+// StoryThreadEntry is an analysis in a thread, oldest first. NewFacts are
+// its included facts that no earlier analysis in the thread reported.
+type StoryThreadEntry struct {
+	ArticleID        int64     `json:"articleId"`
+	Title            string    `json:"title"`
+	Status           string    `json:"status"`
+	FollowsArticleID *int64    `json:"followsArticleId"`
+	Similarity       *float64  `json:"similarity"`
+	CreatedAt        time.Time `json:"createdAt"`
+	NewFacts         []string  `json:"newFacts"`
+}
+
+type FollowUpResult struct {
+	ArticleID        int64   `json:"articleId"`
+	ThreadID         *int64  `json:"threadId"`
+	FollowsArticleID *int64  `json:"followsArticleId"`
+	Similarity       float64 `json:"similarity"`
+}
+
+// StoryCluster is a group of recent analyses about the same story, headed by
+// the analysis closest to the rest of the group.
+type StoryCluster struct {
+	Headline         string          `json:"headline"`
+	RepresentativeID int64           `json:"representativeId"`
+	Language         string          `json:"language"`
+	Size             int             `json:"size"`
+	LatestAt         time.Time       `json:"latestAt"`
+	Analyses         []ClusterMember `json:"analyses"`
+}
+
+type ClusterMember struct {
+	ArticleID int64     `json:"articleId"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	ThreadID  *int64    `json:"threadId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
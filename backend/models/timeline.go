@@ -0,0 +1,14 @@
+package models
+
+// TimelineEvent is a dated event taken from an analysis's source. Date is as
+// precise as the source allows (YYYY-MM-DD, YYYY-MM or YYYY) and empty when
+// the source only gives a relative date; DateText keeps the date as written.
+// DateText and SourceSentence stay in the language of the source.
+type TimelineEvent struct {
+	ID             int64  `json:"id"`
+	Position       int    `json:"position"`
+	Date           string `json:"date"`
+	DateText       string `json:"dateText"`
+	Event          string `json:"event"`
+	SourceSentence string `json:"sourceSentence"`
+}
@@ -1,6 +1,10 @@
 package prompts
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 const factsPromptTemplate = `Extract clean facts from the input.
 
@@ -10,12 +14,13 @@ Rules:
 - Return 5 to 8 facts maximum.
 - Remove duplicates.
 - Do not invent facts.
+- Tag each fact with the labels that fit, from: attributed, statistic, official statement, quote, allegation, date. Keep the labels in English, as written here; a fact may have none.
 
 Return strict JSON:
-{"facts":["fact 1","fact 2"]}
+{"facts":[{"text":"fact 1","tags":["statistic"]},{"text":"fact 2","tags":[]}]}
 
 Input:
-%s`
+{{text}}`
 
 const gapsPromptTemplate = `Generate missing-context questions from the input facts.
 
@@ -30,7 +35,7 @@ Return strict JSON:
 {"gaps":["question 1","question 2"]}
 
 Input:
-%s`
+{{facts}}`
 
 const articlePromptTemplate = `Generate one structured article paragraph.
 
@@ -45,10 +50,51 @@ Return strict JSON:
 {"article":"final paragraph text"}
 
 Facts:
-%s
+{{facts}}
 
 Gaps:
-%s`
+{{gaps}}`
+
+const corroboratedArticlePromptTemplate = `Generate one structured article paragraph from facts gathered across several reports of the same event.
+
+Rules:
+- Each fact is tagged with how many sources reported it.
+- State corroborated facts plainly.
+- Attribute single-source facts, for example "according to one report".
+- Mention unresolved gaps as context.
+- Keep it to one paragraph (around 80-140 words).
+- Do not add unknown claims.
+
+Return strict JSON:
+{"article":"final paragraph text"}
+
+Facts:
+{{facts}}
+
+Gaps:
+{{gaps}}`
+
+const sectionedArticlePromptTemplate = `Generate a long-form news article in sections.
+
+Rules:
+- Write exactly these sections, in this order:
+  - "lede": the core news in 2-3 sentences.
+  - "background": context that explains the facts.
+  - "unverified": what is claimed but not confirmed, drawn from the gaps.
+  - "next": what happens next or what to watch for.
+- Give every section except the lede a short subheading.
+- Use facts as primary truth. Facts tagged with a source count come from several reports; attribute single-source facts.
+- Keep each section to one or two paragraphs.
+- Do not add unknown claims.
+
+Return strict JSON:
+{"sections":[{"key":"lede","heading":"","body":"text"},{"key":"background","heading":"Background","body":"text"}]}
+
+Facts:
+{{facts}}
+
+Gaps:
+{{gaps}}`
 
 const headlinesPromptTemplate = `Generate headline options for a news analysis.
 
@@ -63,10 +109,10 @@ Return strict JSON:
 {"headlines":["headline 1","headline 2"]}
 
 Facts:
-%s
+{{facts}}
 
 Article:
-%s`
+{{article}}`
 
 const straplinesPromptTemplate = `Generate strapline options for a news analysis.
 
@@ -80,30 +126,281 @@ Return strict JSON:
 {"straplines":["strapline 1","strapline 2"]}
 
 Facts:
-%s
+{{facts}}
 
 Open gaps:
-%s
+{{gaps}}
 
 Article:
-%s`
+{{article}}`
+
+const categoryPromptTemplate = `Pick the topic that best fits this news analysis.
+
+Rules:
+- Choose exactly one topic from the list below, spelled as it appears.
+- Judge by the main subject of the facts, not passing mentions.
+- If nothing fits, choose "Other" when it is listed.
+
+Return strict JSON:
+{"category":"Topic"}
+
+Topics:
+{{categories}}
+
+Facts:
+{{facts}}`
+
+const languagePromptTemplate = `Identify the language the input is written in.
+
+Rules:
+- Choose one language code from the list below.
+- Judge by the main body of the text, not quoted names, handles or short English phrases.
+- Confidence is between 0 and 1.
+
+Return strict JSON:
+{"language":"code","confidence":0.9}
+
+Languages:
+{{languages}}
+
+Input:
+{{text}}`
+
+const imageTextPromptTemplate = `Transcribe the text in the attached image. It is usually a screenshot of a social media post or a photo of a printed press note.
+
+Rules:
+- Copy the text exactly as written, in its original language. Do not translate.
+- Keep the reading order and separate blocks with a blank line.
+- Include the author name or handle and any visible date.
+- Skip interface elements such as buttons, menus and like or share counts.
+- Do not summarise, describe the image or add commentary.
+- If there is no readable text, return nothing.
+
+Return only the transcribed text.`
+
+const groundingPromptTemplate = `Check each numbered article sentence against the facts.
+
+Rules:
+- A sentence is supported when every claim in it is stated in, or follows directly from, the facts.
+- Sentences that only point out an open question from the gaps are supported.
+- Flag a sentence that adds names, numbers, dates, places, causes, quotes or outcomes the facts do not contain.
+- Give a short reason naming the unsupported claim.
+- Do not rewrite sentences.
+
+Return strict JSON:
+{"unsupported":[{"sentence":2,"reason":"short reason"}]}
+Return an empty list when every sentence is supported.
+
+Facts:
+{{facts}}
 
-func BuildFactsPrompt(text string) string {
-	return fmt.Sprintf(factsPromptTemplate, text)
+Gaps:
+{{gaps}}
+
+Sentences:
+{{sentences}}`
+
+const sensitivityPromptTemplate = `Read this news article for legal risk before it is published.
+
+Rules:
+- defamation: a claim, stated as fact, that could harm the reputation of a named or identifiable person or organisation and that the facts do not attribute to a source, official record or court.
+- ongoing_trial: comment on the guilt, character or evidence of someone in live criminal or civil proceedings beyond what was said in court or by officials.
+- privacy: details that identify a child, a victim of a sexual offence, or someone's private health or personal life.
+- other: any other clear legal risk, such as a breached court order or a leaked document.
+- excerpt: the words of the article that carry the risk, copied exactly.
+- reason: one short sentence naming the risk.
+- Flag clear risks only. Attributed, factual reporting of charges or court proceedings is fine.
+
+Return strict JSON:
+{"flags":[{"kind":"defamation","excerpt":"text","reason":"short reason"}]}
+Return an empty list when nothing is a risk.
+
+Facts:
+{{facts}}
+
+Article:
+{{article}}`
+
+const simplifyPromptTemplate = `Rewrite this news article so it is easier to read.
+
+Rules:
+- Write for {{level}}.
+- Use shorter sentences and plainer words; split long sentences.
+- Keep every fact, figure, name and attribution in the article.
+- Use the facts only to check details; do not add claims.
+- Keep paragraph breaks.
+
+Return strict JSON:
+{"article":"text"}
+
+Facts:
+{{facts}}
+
+Article:
+{{article}}`
+
+const timelinePromptTemplate = `Extract a timeline of dated events from this news text.
+
+Rules:
+- Include only events the text ties to a date or time, such as "on 3 March", "last Tuesday" or "in 2019".
+- date: the date as YYYY-MM-DD, or YYYY-MM or YYYY when the text is less precise. Leave it empty when the text only gives a relative date.
+- dateText: the date exactly as the text gives it.
+- event: one short sentence saying what happened.
+- sourceSentence: the sentence of the text the event comes from, copied exactly.
+- List events in chronological order.
+- Return an empty list when the text has no dated events.
+
+Return strict JSON:
+{"events":[{"date":"2024-03-03","dateText":"3 March","event":"text","sourceSentence":"text"}]}
+
+Input:
+{{text}}`
+
+const numericClaimsPromptTemplate = `List the numeric claims in this news text: amounts, percentages, counts and dates.
+
+Rules:
+- kind: one of amount, percentage, count, date.
+- value: the number as a plain number, such as 2500000 for "2.5 million". For a date, use 0.
+- unit: the unit the number is in, such as "%", "USD", "people" or "votes". Leave it empty for a date.
+- date: for a date only, the date as YYYY-MM-DD, or YYYY-MM or YYYY when the text is less precise.
+- subject: a short label for what the number measures, worded the same way each time the text gives a figure for the same thing.
+- group: when figures break down a total, or are shares of the same whole, a short label shared by all of them and the total. Leave it empty otherwise.
+- role: "total" for the stated total of a group, "part" for a figure that makes it up. Leave it empty outside a group.
+- text: the sentence of the text the claim comes from, copied exactly.
+- Do not calculate figures the text doesn't state.
+- Return an empty list when the text has no numeric claims.
+
+Return strict JSON:
+{"claims":[{"kind":"count","value":120,"unit":"people","date":"","subject":"people injured","group":"casualties","role":"total","text":"text"}]}
+
+Input:
+{{text}}`
+
+const gapResearchPromptTemplate = `Answer an open question about a news story from the numbered web search results.
+
+Rules:
+- Use only what the results say. Do not answer from your own knowledge.
+- Give each distinct answer the results support, in one or two sentences.
+- sources: the numbers of the results that state the answer.
+- confidence: "high" when several independent results agree, "medium" for one reliable result, "low" when results are vague or conflict.
+- Say so in the answer when results conflict.
+- Return an empty list when no result answers the question.
+
+Return strict JSON:
+{"answers":[{"answer":"text","sources":[1,3],"confidence":"medium"}]}
+
+Question:
+{{question}}
+
+What the story reports:
+{{facts}}
+
+Results:
+{{results}}`
+
+const (
+	KeyFacts               = "facts"
+	KeyGaps                = "gaps"
+	KeyArticle             = "article"
+	KeyCorroboratedArticle = "corroborated-article"
+	KeySectionedArticle    = "sectioned-article"
+	KeyHeadlines           = "headlines"
+	KeyStraplines          = "straplines"
+	KeyCategory            = "category"
+	KeyLanguage            = "language"
+	KeyImageText           = "image-text"
+	KeyGrounding           = "grounding"
+	KeySensitivity         = "sensitivity"
+	KeySimplify            = "simplify"
+	KeyTimeline            = "timeline"
+	KeyNumericClaims       = "numeric-claims"
+	KeyGapResearch         = "gap-research"
+)
+
+// Template is a user prompt the pipeline renders. Default is the built-in
+// body, used until a version is saved in the database.
+type Template struct {
+	Key         string   `json:"key"`
+	Description string   `json:"description"`
+	Variables   []string `json:"variables"`
+	Default     string   `json:"default"`
+}
+
+var templates = []Template{
+	{Key: KeyFacts, Description: "Fact extraction from the submitted text", Variables: []string{"text"}, Default: factsPromptTemplate},
+	{Key: KeyGaps, Description: "Missing-context questions from the facts", Variables: []string{"facts"}, Default: gapsPromptTemplate},
+	{Key: KeyArticle, Description: "Structured article paragraph", Variables: []string{"facts", "gaps"}, Default: articlePromptTemplate},
+	{Key: KeyCorroboratedArticle, Description: "Article paragraph from facts merged across several sources", Variables: []string{"facts", "gaps"}, Default: corroboratedArticlePromptTemplate},
+	{Key: KeySectionedArticle, Description: "Long-form article in sections with subheadings", Variables: []string{"facts", "gaps"}, Default: sectionedArticlePromptTemplate},
+	{Key: KeyHeadlines, Description: "Headline options", Variables: []string{"facts", "article"}, Default: headlinesPromptTemplate},
+	{Key: KeyStraplines, Description: "Strapline options", Variables: []string{"facts", "gaps", "article"}, Default: straplinesPromptTemplate},
+	{Key: KeyCategory, Description: "Topic suggestion", Variables: []string{"categories", "facts"}, Default: categoryPromptTemplate},
+	{Key: KeyLanguage, Description: "Input language detection", Variables: []string{"languages", "text"}, Default: languagePromptTemplate},
+	{Key: KeyImageText, Description: "Text transcription from an uploaded image", Variables: []string{}, Default: imageTextPromptTemplate},
+	{Key: KeyGrounding, Description: "Article sentences not supported by the facts", Variables: []string{"facts", "gaps", "sentences"}, Default: groundingPromptTemplate},
+	{Key: KeySensitivity, Description: "Legal risks in the article, such as defamation or comment on a live trial", Variables: []string{"facts", "article"}, Default: sensitivityPromptTemplate},
+	{Key: KeySimplify, Description: "Article rewritten for a reading level", Variables: []string{"level", "facts", "article"}, Default: simplifyPromptTemplate},
+	{Key: KeyTimeline, Description: "Dated events from the source", Variables: []string{"text"}, Default: timelinePromptTemplate},
+	{Key: KeyNumericClaims, Description: "Numeric claims in the source", Variables: []string{"text"}, Default: numericClaimsPromptTemplate},
+	{Key: KeyGapResearch, Description: "Candidate answers to an open question from web search results", Variables: []string{"question", "facts", "results"}, Default: gapResearchPromptTemplate},
 }
 
-func BuildGapsPrompt(facts string) string {
-	return fmt.Sprintf(gapsPromptTemplate, facts)
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_]+)\s*\}\}`)
+
+func Templates() []Template {
+	return append([]Template(nil), templates...)
 }
 
-func BuildArticlePrompt(facts string, gaps string) string {
-	return fmt.Sprintf(articlePromptTemplate, facts, gaps)
+func Lookup(key string) (Template, bool) {
+	for _, template := range templates {
+		if template.Key == key {
+			return template, true
+		}
+	}
+	return Template{}, false
 }
 
-func BuildHeadlinesPrompt(facts string, article string) string {
-	return fmt.Sprintf(headlinesPromptTemplate, facts, article)
+// Render fills {{name}} placeholders in body. Unknown names are left as is.
+func Render(body string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(body, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
 }
 
-func BuildStraplinesPrompt(facts string, gaps string, article string) string {
-	return fmt.Sprintf(straplinesPromptTemplate, facts, gaps, article)
+// Validate checks that body uses every variable of the template and no others.
+func Validate(key string, body string) error {
+	template, ok := Lookup(key)
+	if !ok {
+		return fmt.Errorf("invalid prompt template %q", key)
+	}
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("prompt body is required")
+	}
+
+	used := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		used[match[1]] = true
+	}
+
+	allowed := make(map[string]bool, len(template.Variables))
+	missing := make([]string, 0)
+	for _, name := range template.Variables {
+		allowed[name] = true
+		if !used[name] {
+			missing = append(missing, "{{"+name+"}}")
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("prompt must include %s", strings.Join(missing, ", "))
+	}
+	for name := range used {
+		if !allowed[name] {
+			return fmt.Errorf("invalid placeholder {{%s}} for the %s prompt", name, key)
+		}
+	}
+	return nil
 }
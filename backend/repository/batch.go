@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Both postgres and mysql cap a prepared statement at 65535 parameters; a
+// batch stays well under that so one statement never gets unwieldy.
+const maxBatchArgs = 4000
+
+var errBatchShape = errors.New("batch row does not match its columns")
+
+type preparer interface {
+	execQueryer
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// InsertMany inserts rows into table, whose id must be an auto-increment or
+// serial column, and returns their ids in row order.
+func (s *Store) InsertMany(ctx context.Context, table string, columns []string, rows [][]any) ([]int64, error) {
+	return insertMany(ctx, s.driver, s.database, table, columns, rows)
+}
+
+func (t *Tx) InsertMany(ctx context.Context, table string, columns []string, rows [][]any) ([]int64, error) {
+	return insertMany(ctx, t.driver, t.tx, table, columns, rows)
+}
+
+// insertMany inserts rows with multi-row INSERT statements and returns the
+// new ids in row order. Batches of the same size share a prepared statement.
+//
+// On mysql the ids are read from LastInsertId, which is the first id of the
+// statement; InnoDB hands a multi-row INSERT its ids in one go whatever its
+// lock mode, as the row count is known up front. They are
+// auto_increment_increment apart, which multi-primary clusters such as
+// Galera set above 1, so the step is read from the server rather than assumed.
+func insertMany(ctx context.Context, driver string, target preparer, table string, columns []string, rows [][]any) ([]int64, error) {
+	if driver != "postgres" && driver != "mysql" {
+		return nil, ErrUnsupportedDriver
+	}
+	if len(rows) == 0 {
+		return []int64{}, nil
+	}
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			return nil, errBatchShape
+		}
+	}
+
+	step := int64(1)
+	if driver == "mysql" {
+		if err := target.QueryRowContext(ctx, "SELECT @@auto_increment_increment").Scan(&step); err != nil {
+			return nil, err
+		}
+		if step < 1 {
+			return nil, fmt.Errorf("auto_increment_increment is %d", step)
+		}
+	}
+
+	batchRows := max(1, maxBatchArgs/max(1, len(columns)))
+	statements := make(map[int]*sql.Stmt)
+	defer func() {
+		for _, statement := range statements {
+			statement.Close()
+		}
+	}()
+
+	ids := make([]int64, 0, len(rows))
+	for start := 0; start < len(rows); start += batchRows {
+		batch := rows[start:min(start+batchRows, len(rows))]
+		statement, ok := statements[len(batch)]
+		if !ok {
+			prepared, err := target.PrepareContext(ctx, batchInsertQuery(driver, table, columns, len(batch)))
+			if err != nil {
+				return nil, err
+			}
+			statement = prepared
+			statements[len(batch)] = statement
+		}
+
+		args := make([]any, 0, len(batch)*len(columns))
+		for _, row := range batch {
+			args = append(args, row...)
+		}
+		batchIDs, err := execBatch(ctx, driver, statement, args, len(batch), step)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, batchIDs...)
+	}
+	return ids, nil
+}
+
+func batchInsertQuery(driver string, table string, columns []string, count int) string {
+	values := make([]string, count)
+	row := "(" + Placeholders(len(columns)) + ")"
+	for idx := range values {
+		values[idx] = row
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+	if driver == "postgres" {
+		query += " RETURNING id"
+	}
+	return Rebind(driver, query)
+}
+
+// execBatch runs one batch. step is the gap between the ids mysql hands out.
+func execBatch(ctx context.Context, driver string, statement *sql.Stmt, args []any, count int, step int64) ([]int64, error) {
+	ids := make([]int64, 0, count)
+	if driver == "mysql" {
+		result, err := statement.ExecContext(ctx, args...)
+		if err != nil {
+			return nil, err
+		}
+		first, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		// Assumes the statement's ids were allocated together, as InnoDB
+		// does for inserts whose row count it knows; see insertMany.
+		for idx := range count {
+			ids = append(ids, first+int64(idx)*step)
+		}
+		return ids, nil
+	}
+
+	rows, err := statement.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) != count {
+		return nil, fmt.Errorf("batch insert returned %d ids for %d rows", len(ids), count)
+	}
+	// RETURNING makes no promise about order, but a serial column hands out
+	// ids in VALUES order within a statement.
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
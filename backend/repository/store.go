@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"nanoheads/db"
+)
+
+var ErrUnsupportedDriver = errors.New("unsupported database driver")
+
+// Querier is satisfied by both Store and Tx. Queries are always written with
+// ? placeholders and rebound for the connected driver.
+type Querier interface {
+	Driver() string
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Insert(ctx context.Context, query string, args ...any) (int64, error)
+	InsertMany(ctx context.Context, table string, columns []string, rows [][]any) ([]int64, error)
+}
+
+type Store struct {
+	database *sql.DB
+	driver   string
+}
+
+type Tx struct {
+	tx     *sql.Tx
+	driver string
+}
+
+func New(database *sql.DB) *Store {
+	return &Store{
+		database: database,
+		driver:   db.Driver(),
+	}
+}
+
+func (s *Store) DB() *sql.DB {
+	return s.database
+}
+
+func (s *Store) Driver() string {
+	return s.driver
+}
+
+func (s *Store) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.database.ExecContext(ctx, Rebind(s.driver, query), args...)
+}
+
+func (s *Store) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.database.QueryContext(ctx, Rebind(s.driver, query), args...)
+}
+
+func (s *Store) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.database.QueryRowContext(ctx, Rebind(s.driver, query), args...)
+}
+
+func (s *Store) Insert(ctx context.Context, query string, args ...any) (int64, error) {
+	return insert(ctx, s.driver, s.database, query, args...)
+}
+
+func (s *Store) BeginTx(ctx context.Context) (*Tx, error) {
+	tx, err := s.database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, driver: s.driver}, nil
+}
+
+// WithTx runs fn in a transaction, committing when it returns nil.
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (t *Tx) Driver() string {
+	return t.driver
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, Rebind(t.driver, query), args...)
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, Rebind(t.driver, query), args...)
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, Rebind(t.driver, query), args...)
+}
+
+func (t *Tx) Insert(ctx context.Context, query string, args ...any) (int64, error) {
+	return insert(ctx, t.driver, t.tx, query, args...)
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback is safe to defer after a successful Commit.
+func (t *Tx) Rollback() error {
+	err := t.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insert runs an INSERT and returns the new id, using RETURNING id on postgres
+// and LastInsertId on mysql.
+func insert(ctx context.Context, driver string, target execQueryer, query string, args ...any) (int64, error) {
+	switch driver {
+	case "postgres":
+		var id int64
+		statement := Rebind(driver, strings.TrimRight(strings.TrimSpace(query), ";")) + " RETURNING id"
+		if err := target.QueryRowContext(ctx, statement, args...).Scan(&id); err != nil {
+			return 0, err
+		}
+		return id, nil
+	case "mysql":
+		result, err := target.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	default:
+		return 0, ErrUnsupportedDriver
+	}
+}
+
+// Rebind rewrites ? placeholders as $1, $2, ... for postgres. Question marks
+// inside quoted literals are left alone.
+func Rebind(driver string, query string) string {
+	if driver != "postgres" || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(query) + 16)
+
+	index := 1
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '?':
+			builder.WriteString("$" + strconv.Itoa(index))
+			index++
+			continue
+		}
+		builder.WriteByte(ch)
+	}
+	return builder.String()
+}
+
+// Placeholders returns "?, ?, ..." for IN lists and multi-column inserts.
+func Placeholders(count int) string {
+	if count <= 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+// InsertIgnore builds an insert that silently skips rows that collide on
+// conflictColumns.
+func InsertIgnore(driver string, table string, columns []string, conflictColumns []string) (string, error) {
+	base := fmt.Sprintf("INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), Placeholders(len(columns)))
+	switch driver {
+	case "postgres":
+		return fmt.Sprintf("INSERT %s ON CONFLICT (%s) DO NOTHING", base, strings.Join(conflictColumns, ", ")), nil
+	case "mysql":
+		return "INSERT IGNORE " + base, nil
+	default:
+		return "", ErrUnsupportedDriver
+	}
+}
+
+// Upsert builds an insert that overwrites updateColumns with the new values
+// when a row collides on conflictColumns. extra holds literal assignments such
+// as "updated_at = CURRENT_TIMESTAMP".
+func Upsert(driver string, table string, columns []string, conflictColumns []string, updateColumns []string, extra ...string) (string, error) {
+	base := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), Placeholders(len(columns)))
+
+	assignments := make([]string, 0, len(updateColumns)+len(extra))
+	switch driver {
+	case "postgres":
+		for _, column := range updateColumns {
+			assignments = append(assignments, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+		assignments = append(assignments, extra...)
+		return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s", base, strings.Join(conflictColumns, ", "), strings.Join(assignments, ", ")), nil
+	case "mysql":
+		for _, column := range updateColumns {
+			assignments = append(assignments, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		}
+		assignments = append(assignments, extra...)
+		return fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s", base, strings.Join(assignments, ", ")), nil
+	default:
+		return "", ErrUnsupportedDriver
+	}
+}
@@ -5,24 +5,118 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"nanoheads/config"
 	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
 )
 
 func RegisterAnalyseRoutes(router *gin.Engine, database *sql.DB) {
+	authService := services.NewAuthService(database)
+	flags := services.NewFeatureFlagService(database)
 	controller := controllers.NewAnalyseController(database)
+	clipController := controllers.NewClipController(database)
 	adminController := controllers.NewAdminController(database)
+	configController := controllers.NewConfigController()
+	factCheckController := controllers.NewFactCheckController(database)
+	languageController := controllers.NewLanguageController(database)
+	collaborationController := controllers.NewCollaborationController(database)
+	shareController := controllers.NewShareController(database)
+	retentionController := controllers.NewRetentionController(database)
+	privacyController := controllers.NewPrivacyController(database)
 
 	api := router.Group("/api")
-	api.POST("/analyse", controller.AnalyseArticle)
+	api.Use(middleware.LimitBody(int64(config.Current().MaxBodyBytes), map[string]int64{
+		"POST /api/analyse": controllers.MaxAnalyseBodyBytes(),
+	}))
+	registerSessionRoutes(api, authService)
+	api.Use(middleware.Authenticate(authService))
+
+	// Reading is open to every role; changing an analysis is not.
+	editAnalyses := middleware.RequirePermission(models.PermissionEditAnalyses)
+	manageAnalyses := middleware.RequirePermission(models.PermissionManageAnalyses)
+
+	api.POST("/analyse", editAnalyses, controller.AnalyseArticle)
+	api.POST("/clip", editAnalyses, clipController.Clip)
 	api.GET("/dashboard", adminController.GetDashboard)
 	api.GET("/analyses", adminController.ListAnalyses)
+	api.GET("/analyses/updated-since", adminController.AnalysisChanges)
+	api.GET("/analyses/compare", adminController.CompareAnalyses)
 	api.GET("/analyses/:id", adminController.GetAnalysis)
-	api.PATCH("/analyses/:id", adminController.UpdateAnalysis)
-	api.POST("/analyses/:id/facts", adminController.AddFact)
-	api.PATCH("/facts/:id", adminController.UpdateFact)
-	api.DELETE("/facts/:id", adminController.DeleteFact)
-	api.PATCH("/gaps/:id", adminController.UpdateGap)
+	api.GET("/analyses/:id/images/:imageId", middleware.RequirePermission(models.PermissionViewSource), adminController.GetAnalysisImage)
+	api.GET("/analyses/:id/snapshots/:snapshotId", middleware.RequirePermission(models.PermissionViewSource), adminController.GetSourceSnapshot)
+	api.GET("/analyses/:id/live", collaborationController.Live)
+	api.POST("/analyses/merge", manageAnalyses, controller.MergeAnalyses)
+	api.PATCH("/analyses/bulk", manageAnalyses, adminController.BulkUpdateAnalyses)
+	api.GET("/retention/preview", manageAnalyses, retentionController.PreviewRetention)
+	managePrivacy := middleware.RequirePermission(models.PermissionManagePrivacy)
+	api.GET("/privacy/export", managePrivacy, privacyController.ExportData)
+	api.POST("/privacy/purge", managePrivacy, privacyController.PurgeAnalyses)
+	api.GET("/privacy/purges", managePrivacy, privacyController.ListPurges)
+	api.PATCH("/analyses/:id", editAnalyses, adminController.UpdateAnalysis)
+	api.PUT("/analyses/:id/assignee", editAnalyses, adminController.AssignAnalysis)
+	api.DELETE("/analyses/:id/assignee", editAnalyses, adminController.UnassignAnalysis)
+	api.POST("/analyses/:id/approval", middleware.RequirePermission(models.PermissionApprove), adminController.ApproveAnalysis)
+	api.DELETE("/analyses/:id/approval", middleware.RequirePermission(models.PermissionApprove), adminController.WithdrawApproval)
+	api.POST("/analyses/:id/flags", editAnalyses, adminController.FlagAnalysis)
+	api.POST("/analyses/:id/flags/check", editAnalyses, factCheckController.RunSensitivityCheck)
+	api.POST("/flags/:id/clear", middleware.RequirePermission(models.PermissionClearFlags), adminController.ClearFlag)
+	api.POST("/analyses/:id/corrections", middleware.RequirePermission(models.PermissionPublish), adminController.AddCorrection)
+	api.POST("/analyses/:id/category/accept", editAnalyses, adminController.AcceptCategorySuggestion)
+	api.GET("/analyses/:id/facts", adminController.ListFacts)
+	api.POST("/analyses/:id/facts", editAnalyses, adminController.AddFact)
+	api.PATCH("/analyses/:id/facts/order", editAnalyses, adminController.ReorderFacts)
+	api.POST("/analyses/:id/fact-checks", editAnalyses, factCheckController.RunFactChecks)
+	api.POST("/analyses/:id/grounding", editAnalyses, factCheckController.RunGroundingCheck)
+	api.POST("/analyses/:id/entities", editAnalyses, factCheckController.LookUpEntities)
+	api.POST("/analyses/:id/simplify", editAnalyses, controller.SimplifyArticle)
+	api.POST("/analyses/:id/retry", middleware.RequirePermission(models.PermissionViewDiagnostics), controller.RetryAnalysis)
+	api.POST("/analyses/:id/share", editAnalyses, shareController.CreateShare)
+	api.GET("/analyses/:id/shares", shareController.ListShares)
+	api.DELETE("/shares/:id", editAnalyses, shareController.RevokeShare)
+	api.PATCH("/facts/:id", editAnalyses, adminController.UpdateFact)
+	api.DELETE("/facts/:id", editAnalyses, adminController.DeleteFact)
+	api.POST("/facts/:id/restore", editAnalyses, adminController.RestoreFact)
+	api.POST("/facts/:id/undo", editAnalyses, adminController.UndoFactEdit)
+	api.POST("/facts/:id/redo", editAnalyses, adminController.RedoFactEdit)
+	api.PUT("/facts/:id/tags", editAnalyses, adminController.SetFactTags)
+	api.PATCH("/gaps/:id", editAnalyses, adminController.UpdateGap)
+	api.POST("/gaps/:id/undo", editAnalyses, adminController.UndoGapEdit)
+	api.POST("/gaps/:id/redo", editAnalyses, adminController.RedoGapEdit)
+	api.POST("/gaps/:id/research", editAnalyses, middleware.RequireFeature(flags, models.FlagWebSearch), factCheckController.ResearchGap)
+	api.PATCH("/gaps/:id/answers/:answerId", editAnalyses, adminController.ReviewGapAnswer)
 	api.GET("/categories", adminController.ListCategories)
+	api.GET("/languages", languageController.ListLanguages)
+	api.GET("/config", configController.GetConfig)
 	api.GET("/settings", adminController.GetSettings)
-	api.PUT("/settings", adminController.UpdateSettings)
+	api.PUT("/settings", middleware.RequirePermission(models.PermissionManageProviders), adminController.UpdateSettings)
+	api.POST("/settings/test", middleware.RequirePermission(models.PermissionManageProviders), adminController.TestSettings)
+
+	registerUserRoutes(api, authService)
+	registerCommentRoutes(api, database)
+	registerDebugRoutes(api, database)
+	registerExportRoutes(api, database)
+	registerFeatureFlagRoutes(api, database)
+	registerGlossaryRoutes(api, database)
+	registerGraphQLRoutes(api, database)
+	registerImportRoutes(api, database)
+	registerModelRoutes(api, database)
+	registerNotificationRoutes(api, database)
+	registerPromptRoutes(api, database)
+	registerSavedViewRoutes(api, database)
+	registerSourceRoutes(api, database)
+	registerStatsRoutes(api, database)
+	registerStyleGuideRoutes(api, database)
+	registerTelegramRoutes(router, api, database)
+	registerThreadRoutes(api, database)
+
+	registerPublicRoutes(router, database)
+	registerWhatsAppRoutes(router, database)
+
+	// The spec and its UI are open, so integrators can read the contract
+	// before they have an API key.
+	openAPIController := controllers.NewOpenAPIController(router.Routes)
+	router.GET("/api/openapi.json", openAPIController.Spec)
+	router.GET("/api/docs", openAPIController.SwaggerUI)
 }
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/services"
+)
+
+// registerSessionRoutes are the routes that take the place of an API key, so
+// they must be registered before api uses Authenticate.
+func registerSessionRoutes(api *gin.RouterGroup, authService *services.AuthService) {
+	sessionController := controllers.NewSessionController(authService)
+	ssoController := controllers.NewSSOController(authService)
+
+	api.POST("/auth/login", sessionController.Login)
+	api.POST("/auth/refresh", sessionController.Refresh)
+	api.POST("/auth/logout", sessionController.Logout)
+	api.GET("/auth/sso/start", ssoController.Start)
+	api.GET("/auth/sso/callback", ssoController.Callback)
+}
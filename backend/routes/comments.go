@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerCommentRoutes(api *gin.RouterGroup, database *sql.DB) {
+	commentController := controllers.NewCommentController(database)
+	comment := middleware.RequirePermission(models.PermissionComment)
+
+	api.GET("/analyses/:id/comments", commentController.ListComments)
+	api.POST("/analyses/:id/comments", comment, commentController.CreateComment)
+	api.POST("/comments/:id/resolve", comment, commentController.ResolveComment)
+	api.POST("/comments/:id/reopen", comment, commentController.ReopenComment)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerDebugRoutes(api *gin.RouterGroup, database *sql.DB) {
+	debugController := controllers.NewDebugController(database)
+
+	debug := api.Group("/debug", middleware.RequirePermission(models.PermissionViewDiagnostics))
+	debug.GET("/llm-calls", debugController.ListLLMCalls)
+	debug.GET("/llm-calls/:id", debugController.GetLLMCall)
+	debug.GET("/llm-cache", debugController.LLMCacheStats)
+	debug.DELETE("/llm-cache", debugController.PurgeLLMCache)
+}
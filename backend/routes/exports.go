@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerExportRoutes(api *gin.RouterGroup, database *sql.DB) {
+	exportController := controllers.NewExportController(database)
+
+	api.POST("/exports/bundle", middleware.RequirePermission(models.PermissionPublish), exportController.ExportBundle)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerFeatureFlagRoutes(api *gin.RouterGroup, database *sql.DB) {
+	flagController := controllers.NewFeatureFlagController(database)
+
+	manage := api.Group("", middleware.RequirePermission(models.PermissionManageProviders))
+	manage.GET("/feature-flags", flagController.ListFlags)
+	manage.PATCH("/feature-flags/:key", flagController.UpdateFlag)
+	manage.DELETE("/feature-flags/:key", flagController.ResetFlag)
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerGlossaryRoutes(api *gin.RouterGroup, database *sql.DB) {
+	glossaryController := controllers.NewGlossaryController(database)
+	jobController := controllers.NewJobController(database)
+
+	api.GET("/glossary", glossaryController.GetGlossary)
+
+	manage := api.Group("/glossary", middleware.RequirePermission(models.PermissionManagePrompts))
+	manage.POST("/terms", glossaryController.AddTerm)
+	manage.PATCH("/terms/:id", glossaryController.UpdateTerm)
+	manage.DELETE("/terms/:id", glossaryController.DeleteTerm)
+	manage.PUT("/style", glossaryController.UpdateStyleNotes)
+	manage.POST("/retranslate", glossaryController.Retranslate)
+
+	api.GET("/jobs", jobController.ListJobs)
+	api.GET("/jobs/:id", jobController.GetJob)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+)
+
+func registerGraphQLRoutes(api *gin.RouterGroup, database *sql.DB) {
+	graphQLController := controllers.NewGraphQLController(database)
+
+	api.POST("/graphql", graphQLController.Query)
+	api.GET("/graphql/schema", graphQLController.Schema)
+}
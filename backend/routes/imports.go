@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerImportRoutes(api *gin.RouterGroup, database *sql.DB) {
+	importController := controllers.NewImportController(database)
+	manageAnalyses := middleware.RequirePermission(models.PermissionManageAnalyses)
+
+	api.POST("/import/wordpress", manageAnalyses, importController.ImportWordPress)
+	api.POST("/import/rss", manageAnalyses, importController.ImportRSS)
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerModelRoutes(api *gin.RouterGroup, database *sql.DB) {
+	modelController := controllers.NewModelController(database)
+
+	manage := api.Group("", middleware.RequirePermission(models.PermissionManageProviders))
+	manage.GET("/providers", modelController.ListProviders)
+	api.GET("/providers/health", middleware.RequirePermission(models.PermissionViewDiagnostics), modelController.ProviderHealth)
+	manage.POST("/providers/:provider/models", modelController.AddModel)
+	manage.PATCH("/models/:id", modelController.UpdateModel)
+	manage.POST("/models/:id/default", modelController.SetDefaultModel)
+	manage.GET("/settings/provider-keys", modelController.ListProviderKeys)
+	manage.PUT("/settings/provider-keys/:provider", modelController.SetProviderKey)
+	manage.DELETE("/settings/provider-keys/:provider", modelController.DeleteProviderKey)
+	manage.POST("/settings/provider-keys/:provider/test", modelController.TestProviderKey)
+}
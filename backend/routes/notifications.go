@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+)
+
+func registerNotificationRoutes(api *gin.RouterGroup, database *sql.DB) {
+	notificationController := controllers.NewNotificationController(database)
+
+	api.GET("/notifications", notificationController.ListNotifications)
+	api.POST("/notifications/read", notificationController.MarkAllRead)
+	api.POST("/notifications/:id/read", notificationController.MarkRead)
+
+	api.GET("/digests/subscription", notificationController.GetDigestSubscription)
+	api.PUT("/digests/subscription", notificationController.SubscribeDigest)
+	api.DELETE("/digests/subscription", notificationController.UnsubscribeDigest)
+}
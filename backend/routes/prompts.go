@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerPromptRoutes(api *gin.RouterGroup, database *sql.DB) {
+	promptController := controllers.NewPromptController(database)
+
+	manage := api.Group("/prompts", middleware.RequirePermission(models.PermissionManagePrompts))
+	manage.GET("", promptController.ListPrompts)
+	manage.GET("/:key", promptController.GetPrompt)
+	manage.POST("/:key", promptController.SavePrompt)
+	manage.POST("/:key/activate", promptController.ActivatePrompt)
+	manage.POST("/:key/preview", promptController.PreviewPrompt)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+)
+
+// registerPublicRoutes serves what a static front-end or an aggregator
+// reads. They sit outside /api and need no key.
+func registerPublicRoutes(router *gin.Engine, database *sql.DB) {
+	publicFeedController := controllers.NewPublicFeedController(database)
+	shareController := controllers.NewShareController(database)
+
+	public := router.Group("/public")
+	public.GET("/feed.json", publicFeedController.JSONFeed)
+	public.GET("/rss.xml", publicFeedController.RSS)
+	public.GET("/sitemap.xml", publicFeedController.Sitemap)
+	public.GET("/reviews/:token", shareController.OpenShare)
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerSourceRoutes(api *gin.RouterGroup, database *sql.DB) {
+	sourceController := controllers.NewSourceController(database)
+
+	api.GET("/sources", sourceController.ListSources)
+	api.GET("/sources/:id", sourceController.GetSource)
+
+	manage := api.Group("/sources", middleware.RequirePermission(models.PermissionManageSources))
+	manage.POST("", sourceController.CreateSource)
+	manage.PATCH("/:id", sourceController.UpdateSource)
+	manage.DELETE("/:id", sourceController.DeleteSource)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerStatsRoutes(api *gin.RouterGroup, database *sql.DB) {
+	statsController := controllers.NewStatsController(database)
+
+	api.GET("/stats/editors", middleware.RequirePermission(models.PermissionManageUsers), statsController.EditorStats)
+	api.GET("/stats/trending", statsController.TrendingStats)
+	api.GET("/stats/headlines", statsController.HeadlineStats)
+	api.POST("/analyses/:id/headline-results", middleware.RequirePermission(models.PermissionPublish), statsController.RecordHeadlineResults)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+func registerStyleGuideRoutes(api *gin.RouterGroup, database *sql.DB) {
+	styleGuideController := controllers.NewStyleGuideController(database)
+
+	api.GET("/style-rules", styleGuideController.ListRules)
+	api.POST("/style-rules/check", styleGuideController.Check)
+
+	manage := api.Group("/style-rules", middleware.RequirePermission(models.PermissionManagePrompts))
+	manage.PUT("", styleGuideController.ReplaceRules)
+	manage.POST("", styleGuideController.CreateRule)
+	manage.PUT("/:id", styleGuideController.UpdateRule)
+	manage.DELETE("/:id", styleGuideController.DeleteRule)
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+)
+
+// registerTelegramRoutes serves the bot's webhook, which Telegram calls
+// with its own secret rather than an API key, and the linking endpoints.
+func registerTelegramRoutes(router *gin.Engine, api *gin.RouterGroup, database *sql.DB) {
+	telegramController := controllers.NewTelegramController(database)
+	editAnalyses := middleware.RequirePermission(models.PermissionEditAnalyses)
+
+	router.POST("/telegram/webhook", telegramController.Webhook)
+
+	api.POST("/telegram/link", editAnalyses, telegramController.CreateLinkCode)
+	api.GET("/telegram/links", telegramController.ListLinks)
+	api.DELETE("/telegram/links/:id", telegramController.DeleteLink)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+)
+
+func registerThreadRoutes(api *gin.RouterGroup, database *sql.DB) {
+	threadController := controllers.NewStoryThreadController(database)
+
+	api.GET("/clusters", threadController.ListClusters)
+	api.GET("/threads/:id", threadController.GetThread)
+}
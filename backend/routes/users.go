@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+	"nanoheads/middleware"
+	"nanoheads/models"
+	"nanoheads/services"
+)
+
+func registerUserRoutes(api *gin.RouterGroup, authService *services.AuthService) {
+	userController := controllers.NewUserController(authService)
+	sessionController := controllers.NewSessionController(authService)
+	manageUsers := middleware.RequirePermission(models.PermissionManageUsers)
+
+	api.GET("/me", userController.GetCurrentUser)
+	api.GET("/auth/sessions", sessionController.ListOwnSessions)
+	api.DELETE("/auth/sessions/:id", sessionController.RevokeOwnSession)
+	api.GET("/users", manageUsers, userController.ListUsers)
+	api.POST("/users", manageUsers, userController.CreateUser)
+	api.PATCH("/users/:id", manageUsers, userController.UpdateUser)
+	api.POST("/users/:id/api-key", manageUsers, userController.RotateAPIKey)
+	api.GET("/users/:id/sessions", manageUsers, sessionController.ListUserSessions)
+	api.DELETE("/users/:id/sessions", manageUsers, sessionController.RevokeUserSessions)
+	api.DELETE("/users/:id/sessions/:sessionId", manageUsers, sessionController.RevokeUserSession)
+	api.GET("/roles", manageUsers, userController.ListRoles)
+	api.PUT("/roles/:key/permissions", manageUsers, userController.UpdateRolePermissions)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+)
+
+func registerSavedViewRoutes(api *gin.RouterGroup, database *sql.DB) {
+	viewController := controllers.NewSavedViewController(database)
+
+	api.GET("/views", viewController.ListViews)
+	api.POST("/views", viewController.CreateView)
+	api.GET("/views/:id", viewController.GetView)
+	api.PUT("/views/:id", viewController.UpdateView)
+	api.DELETE("/views/:id", viewController.DeleteView)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"nanoheads/controllers"
+)
+
+// registerWhatsAppRoutes serves the WhatsApp Business webhook. Meta signs
+// deliveries with the app secret rather than sending an API key.
+func registerWhatsAppRoutes(router *gin.Engine, database *sql.DB) {
+	whatsAppController := controllers.NewWhatsAppController(database)
+
+	router.GET("/whatsapp/webhook", whatsAppController.VerifyWebhook)
+	router.POST("/whatsapp/webhook", whatsAppController.Webhook)
+}
@@ -39,6 +39,9 @@ func main() {
 	defer database.Close()
 
 	driver := appdb.Driver()
+	if _, err := appdb.MigrateUp(database, driver); err != nil {
+		log.Fatalf("migrate failed: %v", err)
+	}
 	ctx := context.Background()
 
 	tx, err := database.BeginTx(ctx, nil)
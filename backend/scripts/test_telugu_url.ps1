@@ -10,7 +10,7 @@ $isReady = $false
 for ($i = 0; $i -lt 90; $i++) {
     Start-Sleep -Seconds 1
     try {
-        $health = Invoke-RestMethod -Uri "http://127.0.0.1:8000/health" -Method Get
+        $health = Invoke-RestMethod -Uri "http://127.0.0.1:8000/readyz" -Method Get
         if ($health.status -eq "ok") {
             $isReady = $true
             break
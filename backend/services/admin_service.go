@@ -3,40 +3,44 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/url"
 	"strings"
 	"time"
 
-	"nanoheads/db"
+	"nanoheads/config"
 	"nanoheads/models"
+	"nanoheads/repository"
 )
 
 type AdminService struct {
-	database *sql.DB
-	driver   string
+	store *repository.Store
 }
 
 func NewAdminService(database *sql.DB) *AdminService {
 	return &AdminService{
-		database: database,
-		driver:   db.Driver(),
+		store: repository.New(database),
 	}
 }
 
-func (s *AdminService) GetDashboard(ctx context.Context, limit int) (models.DashboardResponse, error) {
-	totalAnalyses, err := s.count(ctx, `SELECT COUNT(*) FROM articles`)
+// GetDashboard summarises the newsroom's work and charts it over period.
+// userID, when known, adds how many unfinished analyses are assigned to that
+// user.
+func (s *AdminService) GetDashboard(ctx context.Context, limit int, period models.AnalyticsPeriod, userID *int64, visibility models.Visibility) (models.DashboardResponse, error) {
+	period, err := normalizeAnalyticsPeriod(period)
 	if err != nil {
 		return models.DashboardResponse{}, err
 	}
 
-	pendingReview, err := s.count(ctx, `SELECT COUNT(*) FROM articles WHERE LOWER(COALESCE(status, 'draft')) = 'pending'`)
+	counts, err := s.articleCounts(ctx, userID)
 	if err != nil {
 		return models.DashboardResponse{}, err
 	}
 
-	savedArticles, err := s.count(ctx, `SELECT COUNT(*) FROM articles WHERE LOWER(COALESCE(status, 'draft')) = 'completed'`)
+	openThreads, err := s.count(ctx, `SELECT COUNT(*) FROM analysis_comments WHERE parent_id IS NULL AND resolved_at IS NULL`)
 	if err != nil {
 		return models.DashboardResponse{}, err
 	}
@@ -51,26 +55,79 @@ func (s *AdminService) GetDashboard(ctx context.Context, limit int) (models.Dash
 		aiUsagePct = (includedFacts * 100) / totalFacts
 	}
 
-	recentAnalyses, err := s.ListAnalyses(ctx, limit)
+	analytics, err := loadDashboardAnalytics(ctx, s.store, period, time.Now())
+	if err != nil {
+		return models.DashboardResponse{}, err
+	}
+
+	recentAnalyses, err := s.ListAnalyses(ctx, limit, models.AnalysisFilter{}, visibility)
 	if err != nil {
 		return models.DashboardResponse{}, err
 	}
 
 	return models.DashboardResponse{
 		Summary: models.DashboardSummary{
-			TotalAnalyses: totalAnalyses,
-			PendingReview: pendingReview,
-			SavedArticles: savedArticles,
+			TotalAnalyses: counts.total,
+			PendingReview: counts.pending,
+			OpenThreads:   openThreads,
+			AssignedToMe:  counts.assignedToMe,
+			Unassigned:    counts.unassigned,
+			SavedArticles: counts.completed,
 			AIUsagePct:    aiUsagePct,
 			AIUsageText:   fmt.Sprintf("%d included / %d total facts", includedFacts, totalFacts),
+
+			AwaitingApproval: counts.awaitingApproval,
+			Approved:         counts.approved,
+			Flagged:          counts.flagged,
 		},
+		Analytics:      analytics,
 		RecentAnalyses: recentAnalyses,
 	}, nil
 }
 
-func (s *AdminService) ListAnalyses(ctx context.Context, limit int) ([]models.AnalysisListItem, error) {
+func (s *AdminService) ListAnalyses(ctx context.Context, limit int, filter models.AnalysisFilter, visibility models.Visibility) ([]models.AnalysisListItem, error) {
 	limit = normalizeLimit(limit)
 
+	conditions := make([]string, 0, 6)
+	args := make([]any, 0, 6)
+	switch {
+	case filter.Unassigned:
+		conditions = append(conditions, "a.assigned_to IS NULL")
+	case filter.AssignedTo != nil:
+		conditions = append(conditions, "a.assigned_to = ?")
+		args = append(args, *filter.AssignedTo)
+	}
+	if filter.CreatedBy != nil {
+		conditions = append(conditions, "a.submitted_by = ?")
+		args = append(args, *filter.CreatedBy)
+	}
+	if filter.Status != "" {
+		status, err := normalizeStatusFilter(filter.Status)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "a.status = ?")
+		args = append(args, status)
+	}
+	if category := strings.TrimSpace(filter.Category); strings.EqualFold(category, "Uncategorized") {
+		conditions = append(conditions, "a.topic_id IS NULL")
+	} else if category != "" {
+		conditions = append(conditions, "LOWER(t.name) = LOWER(?)")
+		args = append(args, category)
+	}
+	if filter.Days != 0 {
+		if filter.Days < 1 || filter.Days > maxAnalyticsDays {
+			return nil, invalidInput("days must be between 1 and 366")
+		}
+		conditions = append(conditions, "a.created_at >= ?")
+		args = append(args, calendarDay(time.Now()).AddDate(0, 0, -(filter.Days-1)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+
 	query := `
 		SELECT
 			a.id,
@@ -79,26 +136,64 @@ func (s *AdminService) ListAnalyses(ctx context.Context, limit int) ([]models.An
 			COALESCE(a.created_at, CURRENT_TIMESTAMP) AS created_at,
 			COALESCE(a.headline_selected, '') AS headline_selected,
 			COALESCE(a.source_url, '') AS source_url,
-			COALESCE(a.raw_text, '') AS raw_text
+			COALESCE(a.raw_text, '') AS raw_text,
+			COALESCE(st.name, '') AS suggested_category,
+			COALESCE(a.source_domain, '') AS source_domain,
+			a.submitted_by,
+			a.assigned_to,
+			COALESCE(au.display_name, au.email, '') AS assignee_name,
+			COALESCE(fc.total, 0) AS fact_count,
+			COALESCE(fc.included, 0) AS included_facts,
+			COALESCE(gc.total, 0) AS gap_count,
+			COALESCE(hc.selected, 0) AS selected_headlines,
+			a.version,
+			ap.version AS approved_version,
+			COALESCE(lf.total, 0) AS open_flags
 		FROM articles a
 		LEFT JOIN topics t ON t.id = a.topic_id
+		LEFT JOIN topics st ON st.id = a.suggested_topic_id
+		LEFT JOIN users au ON au.id = a.assigned_to
+		LEFT JOIN (
+			SELECT article_id, COUNT(*) AS total, SUM(CASE WHEN is_included THEN 1 ELSE 0 END) AS included
+			FROM facts
+			WHERE deleted_at IS NULL
+			GROUP BY article_id
+		) fc ON fc.article_id = a.id
+		LEFT JOIN (
+			SELECT article_id, COUNT(*) AS total
+			FROM gaps
+			GROUP BY article_id
+		) gc ON gc.article_id = a.id
+		LEFT JOIN (
+			SELECT article_id, COUNT(*) AS selected
+			FROM headlines
+			WHERE is_selected
+			GROUP BY article_id
+		) hc ON hc.article_id = a.id
+		LEFT JOIN (
+			SELECT article_id, MAX(version) AS version
+			FROM analysis_approvals
+			GROUP BY article_id
+		) ap ON ap.article_id = a.id
+		LEFT JOIN (
+			SELECT article_id, COUNT(*) AS total
+			FROM analysis_flags
+			WHERE cleared_at IS NULL
+			GROUP BY article_id
+		) lf ON lf.article_id = a.id
+		` + where + `
 		ORDER BY a.created_at DESC
-		LIMIT %s;
+		LIMIT ?;
 	`
 
-	limitParam := "?"
-	args := []any{limit}
-	if s.driver == "postgres" {
-		limitParam = "$1"
-	}
-
-	rows, err := s.database.QueryContext(ctx, fmt.Sprintf(query, limitParam), args...)
+	rows, err := s.store.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	items := make([]models.AnalysisListItem, 0, limit)
+	domains := make([]string, 0, limit)
 	for rows.Next() {
 		var (
 			id        int64
@@ -108,29 +203,72 @@ func (s *AdminService) ListAnalyses(ctx context.Context, limit int) ([]models.An
 			headline  string
 			sourceURL string
 			rawText   string
+			suggested string
+			domain    string
+			createdBy sql.NullInt64
+			assignee  sql.NullInt64
+			assigned  string
+			facts     int64
+			included  int64
+			gaps      int64
+			selected  int64
+			version   int64
+			approved  sql.NullInt64
+			flags     int64
 		)
 
-		if err := rows.Scan(&id, &category, &status, &createdAt, &headline, &sourceURL, &rawText); err != nil {
+		if err := rows.Scan(&id, &category, &status, &createdAt, &headline, &sourceURL, &rawText, &suggested, &domain, &createdBy, &assignee, &assigned, &facts, &included, &gaps, &selected, &version, &approved, &flags); err != nil {
 			return nil, err
 		}
+		if domain == "" {
+			domain = sourceDomain(sourceURL)
+		}
+		domains = append(domains, domain)
+		if !visibility.SourceText {
+			rawText = ""
+		}
 
-		items = append(items, models.AnalysisListItem{
-			ID:        id,
-			Title:     buildAnalysisTitle(id, headline, sourceURL, rawText),
-			Category:  category,
-			Status:    formatStatus(status),
-			CreatedAt: createdAt,
-		})
+		item := models.AnalysisListItem{
+			ID:                id,
+			Title:             buildAnalysisTitle(id, headline, sourceURL, rawText),
+			Category:          category,
+			SuggestedCategory: suggested,
+			Status:            formatStatus(status),
+			CreatedAt:         createdAt,
+			FactCount:         facts,
+			IncludedFacts:     included,
+			GapCount:          gaps,
+			HasHeadline:       selected > 0 || strings.TrimSpace(headline) != "",
+			ApprovalStatus:    approvalStatus(version, approved),
+			OpenFlags:         flags,
+		}
+		if createdBy.Valid {
+			item.CreatedBy = &createdBy.Int64
+		}
+		if assignee.Valid {
+			item.Assignee = &models.Assignee{UserID: assignee.Int64, Name: assigned}
+		}
+		items = append(items, item)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
+	ratings, err := lookupSourceRatings(ctx, s.store, domains)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range items {
+		items[idx].SourceRating = ratings[domains[idx]]
+	}
+
 	return items, nil
 }
 
-func (s *AdminService) GetAnalysisDetail(ctx context.Context, articleID int64) (models.AnalysisDetail, error) {
+// GetAnalysisDetail loads an analysis. Callers without source visibility get
+// no raw source text, including the title fallback derived from it.
+func (s *AdminService) GetAnalysisDetail(ctx context.Context, articleID int64, visibility models.Visibility) (models.AnalysisDetail, error) {
 	articleQuery := `
 		SELECT
 			a.id,
@@ -139,25 +277,56 @@ func (s *AdminService) GetAnalysisDetail(ctx context.Context, articleID int64) (
 			COALESCE(a.created_at, CURRENT_TIMESTAMP) AS created_at,
 			COALESCE(a.headline_selected, '') AS headline_selected,
 			COALESCE(a.source_url, '') AS source_url,
+			COALESCE(a.fetch_strategy, '') AS fetch_strategy,
 			COALESCE(a.raw_text, '') AS raw_text,
 			COALESCE(a.selected_format, 'timeline') AS selected_format,
 			COALESCE(a.article_text, '') AS article_text,
 			COALESCE(a.strapline_selected, '') AS strapline_selected,
 			COALESCE(a.slug, '') AS slug,
 			COALESCE(a.meta_description, '') AS meta_description,
-			COALESCE(a.excerpt, '') AS excerpt
+			COALESCE(a.excerpt, '') AS excerpt,
+			COALESCE(st.name, '') AS suggested_category,
+			COALESCE(a.category_suggestion_source, '') AS suggestion_source,
+			a.submitted_by,
+			COALESCE(u.display_name, u.email, '') AS submitter_name,
+			COALESCE(a.submission_channel, '') AS submission_channel,
+			COALESCE(a.client_ip, '') AS client_ip,
+			COALESCE(a.user_agent, '') AS user_agent,
+			COALESCE(a.submission_params, '') AS submission_params,
+			COALESCE(a.source_domain, '') AS source_domain,
+			a.fact_checked_at,
+			COALESCE(a.input_language, '') AS input_language,
+			COALESCE(l.name, a.input_language, '') AS input_language_name,
+			COALESCE(a.input_language_confidence, 0) AS input_language_confidence,
+			COALESCE(a.language_detection, '') AS language_detection,
+			COALESCE(a.output_language, '') AS output_language,
+			a.assigned_to,
+			COALESCE(au.display_name, au.email, '') AS assignee_name,
+			a.assigned_by,
+			a.assigned_at,
+			a.merged_into,
+			COALESCE(a.article_mode, '') AS article_mode,
+			a.article_sections,
+			a.grounding_checked_at,
+			a.entities_checked_at,
+			COALESCE(a.readability_metric, '') AS readability_metric,
+			COALESCE(a.readability_score, 0) AS readability_score,
+			a.readability_grade,
+			COALESCE(a.readability_level, '') AS readability_level,
+			COALESCE(a.failed_step, '') AS failed_step,
+			COALESCE(a.failure_error, '') AS failure_error,
+			a.failed_at,
+			a.version
 		FROM articles a
 		LEFT JOIN topics t ON t.id = a.topic_id
-		WHERE a.id = %s
+		LEFT JOIN topics st ON st.id = a.suggested_topic_id
+		LEFT JOIN users u ON u.id = a.submitted_by
+		LEFT JOIN users au ON au.id = a.assigned_to
+		LEFT JOIN languages l ON l.code = a.input_language
+		WHERE a.id = ?
 		LIMIT 1;
 	`
 
-	idParam := "?"
-	args := []any{articleID}
-	if s.driver == "postgres" {
-		idParam = "$1"
-	}
-
 	var (
 		id             int64
 		category       string
@@ -172,15 +341,41 @@ func (s *AdminService) GetAnalysisDetail(ctx context.Context, articleID int64) (
 		slug           string
 		metaDesc       string
 		excerpt        string
+		suggested      string
+		suggestedBy    string
+		submittedBy    sql.NullInt64
+		submission     models.Submission
+		params         string
+		domain         string
+		factCheckedAt  sql.NullTime
+		inputLanguage  models.LanguageDetection
+		outputLanguage string
+		assignedTo     sql.NullInt64
+		assigneeName   string
+		assignedBy     sql.NullInt64
+		assignedAt     sql.NullTime
+		mergedInto     sql.NullInt64
+		articleMode    string
+		sections       sql.NullString
+		groundedAt     sql.NullTime
+		entitiesAt     sql.NullTime
+		readability    models.Readability
+		grade          sql.NullFloat64
+		failedStep     string
+		failureError   string
+		failedAt       sql.NullTime
+		fetchStrategy  string
+		version        int64
 	)
 
-	if err := s.database.QueryRowContext(ctx, fmt.Sprintf(articleQuery, idParam), args...).Scan(
+	if err := s.store.QueryRowContext(ctx, articleQuery, articleID).Scan(
 		&id,
 		&category,
 		&status,
 		&createdAt,
 		&headline,
 		&sourceURL,
+		&fetchStrategy,
 		&rawText,
 		&selectedFormat,
 		&articleTxt,
@@ -188,11 +383,82 @@ func (s *AdminService) GetAnalysisDetail(ctx context.Context, articleID int64) (
 		&slug,
 		&metaDesc,
 		&excerpt,
+		&suggested,
+		&suggestedBy,
+		&submittedBy,
+		&submission.SubmitterName,
+		&submission.Channel,
+		&submission.ClientIP,
+		&submission.UserAgent,
+		&params,
+		&domain,
+		&factCheckedAt,
+		&inputLanguage.Code,
+		&inputLanguage.Name,
+		&inputLanguage.Confidence,
+		&inputLanguage.Method,
+		&outputLanguage,
+		&assignedTo,
+		&assigneeName,
+		&assignedBy,
+		&assignedAt,
+		&mergedInto,
+		&articleMode,
+		&sections,
+		&groundedAt,
+		&entitiesAt,
+		&readability.Metric,
+		&readability.Score,
+		&grade,
+		&readability.Level,
+		&failedStep,
+		&failureError,
+		&failedAt,
+		&version,
 	); err != nil {
 		return models.AnalysisDetail{}, err
 	}
+	if articleMode == "" {
+		articleMode = models.ArticleModeParagraph
+	}
+	if !visibility.SourceText {
+		rawText = ""
+	}
+	if submittedBy.Valid {
+		submission.SubmittedBy = &submittedBy.Int64
+	}
+	if params != "" {
+		if err := json.Unmarshal([]byte(params), &submission.Params); err != nil {
+			log.Printf("[analysis] article %d has unreadable submission params: %v", id, err)
+		}
+	}
+	var assignee *models.Assignee
+	if assignedTo.Valid {
+		assignee = &models.Assignee{UserID: assignedTo.Int64, Name: assigneeName}
+		if assignedBy.Valid {
+			assignee.AssignedBy = &assignedBy.Int64
+		}
+		if assignedAt.Valid {
+			assignee.AssignedAt = &assignedAt.Time
+		}
+	}
+	// Network details are for abuse investigation, not general editing.
+	if !visibility.Diagnostics {
+		submission.ClientIP = ""
+		submission.UserAgent = ""
+	}
+	var failure *models.AnalysisFailure
+	if failedStep != "" {
+		failure = &models.AnalysisFailure{Step: failedStep}
+		if visibility.Diagnostics {
+			failure.Error = failureError
+		}
+		if failedAt.Valid {
+			failure.FailedAt = &failedAt.Time
+		}
+	}
 
-	facts, err := s.listFactsByArticleID(ctx, articleID)
+	facts, err := s.listFactsByArticleID(ctx, articleID, false)
 	if err != nil {
 		return models.AnalysisDetail{}, err
 	}
@@ -228,156 +494,441 @@ func (s *AdminService) GetAnalysisDetail(ctx context.Context, articleID int64) (
 		straplineOptions = prependIfMissing(straplineOptions, selectedStrapline)
 	}
 
+	promptVersions, err := s.listPromptVersionsByArticleID(ctx, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+
+	sources, err := s.listSourcesByArticleID(ctx, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	if len(sources) > 0 {
+		if err := s.attachFactSources(ctx, articleID, facts); err != nil {
+			return models.AnalysisDetail{}, err
+		}
+	}
+
+	images, err := s.listImagesByArticleID(ctx, articleID, visibility)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+
+	snapshots, err := s.listSnapshotsByArticleID(ctx, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+
+	var entitiesCheckedAt *time.Time
+	if entitiesAt.Valid {
+		entitiesCheckedAt = &entitiesAt.Time
+	}
+	entities, err := listArticleEntities(ctx, s.store, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+
+	var checkedAt *time.Time
+	if factCheckedAt.Valid {
+		checkedAt = &factCheckedAt.Time
+		if err := s.attachFactChecks(ctx, articleID, facts); err != nil {
+			return models.AnalysisDetail{}, err
+		}
+	}
+
+	if domain == "" {
+		domain = sourceDomain(sourceURL)
+	}
+	domains := []string{domain}
+	for _, source := range sources {
+		domains = append(domains, sourceDomain(source.URL))
+	}
+	ratings, err := lookupSourceRatings(ctx, s.store, domains)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	for idx := range sources {
+		sources[idx].SourceRating = ratings[domains[idx+1]]
+	}
+
+	titleIssues := collectTitleIssues(titleKindHeadline, headlineOptions...)
+	titleIssues = append(titleIssues, collectTitleIssues(titleKindStrapline, straplineOptions...)...)
+
+	styleRules, err := loadStyleRules(ctx, s.store)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	styleIssues := collectStyleIssues(styleRules, styleFieldArticle, articleTxt)
+	styleIssues = append(styleIssues, collectStyleIssues(styleRules, titleKindHeadline, append(headlineOptions, selectedHeadline)...)...)
+	styleIssues = append(styleIssues, collectStyleIssues(styleRules, titleKindStrapline, append(straplineOptions, selectedStrapline)...)...)
+
+	// Analyses saved before readability was scored are measured on the fly.
+	if readability.Metric == "" {
+		readability = measureReadability(articleTxt, outputLanguage)
+	} else if grade.Valid {
+		readability.Grade = &grade.Float64
+	}
+
+	var groundingCheckedAt *time.Time
+	unsupported := make([]models.UnsupportedSentence, 0)
+	if groundedAt.Valid {
+		groundingCheckedAt = &groundedAt.Time
+		if unsupported, err = listUnsupportedSentences(ctx, s.store, articleID, articleTxt); err != nil {
+			return models.AnalysisDetail{}, err
+		}
+	}
+
+	timeline, err := listTimelineEvents(ctx, s.store, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	numericClaims, err := listNumericClaims(ctx, s.store, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	threadID, err := findThreadID(ctx, s.store, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	approvals, err := listApprovals(ctx, s.store, articleID, version)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	flags, err := listFlags(ctx, s.store, articleID)
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	correctionsFor, err := correctionsByArticle(ctx, s.store, []int64{articleID})
+	if err != nil {
+		return models.AnalysisDetail{}, err
+	}
+	corrections := correctionsFor[articleID]
+	if corrections == nil {
+		corrections = make([]models.AnalysisCorrection, 0)
+	}
+	approval := sql.NullInt64{}
+	for _, item := range approvals {
+		if !approval.Valid || item.Version > approval.Int64 {
+			approval = sql.NullInt64{Int64: item.Version, Valid: true}
+		}
+	}
+
+	var detectedLanguage *models.LanguageDetection
+	if inputLanguage.Code != "" {
+		detectedLanguage = &inputLanguage
+	}
+
+	var suggestion *models.CategorySuggestion
+	if suggested != "" {
+		suggestion = &models.CategorySuggestion{Category: suggested, Source: suggestedBy}
+	}
+
 	return models.AnalysisDetail{
-		ID:                id,
-		Title:             buildAnalysisTitle(id, headline, sourceURL, rawText),
-		Category:          category,
-		Status:            formatStatus(status),
-		SourceURL:         sourceURL,
-		RawText:           rawText,
-		SelectedFormat:    selectedFormat,
-		ArticleText:       articleTxt,
-		HeadlineSelected:  selectedHeadline,
-		StraplineSelected: selectedStrapline,
-		HeadlineOptions:   headlineOptions,
-		StraplineOptions:  straplineOptions,
-		Slug:              slug,
-		MetaDescription:   metaDesc,
-		Excerpt:           excerpt,
-		CreatedAt:         createdAt,
-		Facts:             facts,
-		Gaps:              gaps,
+		ID:                 id,
+		Version:            version,
+		Title:              buildAnalysisTitle(id, headline, sourceURL, rawText),
+		Category:           category,
+		Status:             formatStatus(status),
+		SourceURL:          sourceURL,
+		FetchStrategy:      fetchStrategy,
+		RawText:            rawText,
+		SelectedFormat:     selectedFormat,
+		ArticleText:        articleTxt,
+		ArticleMode:        articleMode,
+		ArticleSections:    decodeArticleSections(id, sections, articleTxt),
+		Readability:        readability,
+		Timeline:           timeline,
+		NumericClaims:      numericClaims,
+		HeadlineSelected:   selectedHeadline,
+		StraplineSelected:  selectedStrapline,
+		HeadlineOptions:    headlineOptions,
+		StraplineOptions:   straplineOptions,
+		Slug:               slug,
+		MetaDescription:    metaDesc,
+		Excerpt:            excerpt,
+		CategorySuggestion: suggestion,
+		Submission:         submission,
+		Assignee:           assignee,
+		MergedInto:         nullInt64Pointer(mergedInto),
+		Failure:            failure,
+		ThreadID:           threadID,
+		PromptVersions:     promptVersions,
+		Sources:            sources,
+		Images:             images,
+		Snapshots:          snapshots,
+		EntitiesCheckedAt:  entitiesCheckedAt,
+		Entities:           entities,
+		SourceRating:       ratings[domain],
+		FactCheckedAt:      checkedAt,
+		InputLanguage:      detectedLanguage,
+		OutputLanguage:     outputLanguage,
+		CreatedAt:          createdAt,
+		Facts:              facts,
+		FactTags:           FactTagsInUse(facts),
+		Gaps:               gaps,
+		TitleIssues:        titleIssues,
+		StyleIssues:        styleIssues,
+		GroundingCheckedAt: groundingCheckedAt,
+		Unsupported:        unsupported,
+		ApprovalStatus:     approvalStatus(version, approval),
+		Approvals:          approvals,
+		Flags:              flags,
+		Corrections:        corrections,
 	}, nil
 }
 
-func (s *AdminService) AddFact(ctx context.Context, articleID int64, text string) (int64, error) {
+// AssignAnalysis hands an analysis to an active user for review; a nil userID
+// clears the assignment.
+func (s *AdminService) AssignAnalysis(ctx context.Context, articleID int64, userID *int64, assignedBy *int64) error {
+	query := "UPDATE articles SET assigned_to = NULL, assigned_by = NULL, assigned_at = NULL WHERE id = ?"
+	args := []any{articleID}
+	if userID != nil {
+		var active bool
+		err := s.store.QueryRowContext(ctx, "SELECT COALESCE(is_active, true) FROM users WHERE id = ?", *userID).Scan(&active)
+		if errors.Is(err, sql.ErrNoRows) {
+			return invalidInput("userId is invalid")
+		}
+		if err != nil {
+			return err
+		}
+		if !active {
+			return invalidInput("userId is invalid: the user is deactivated")
+		}
+		query = "UPDATE articles SET assigned_to = ?, assigned_by = ?, assigned_at = CURRENT_TIMESTAMP WHERE id = ?"
+		args = []any{*userID, assignedBy, articleID}
+	}
+
+	result, err := s.store.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return err
+	}
+
+	if userID != nil {
+		notifyUsers(ctx, s.store, []*int64{userID}, assignedBy, notification{
+			kind:      models.NotificationAnalysisAssigned,
+			articleID: &articleID,
+			title:     "Analysis assigned to you",
+			body:      analysisLabel(ctx, s.store, articleID),
+		})
+	}
+	return nil
+}
+
+func (s *AdminService) AddFact(ctx context.Context, articleID int64, text string, addedBy *int64) (int64, error) {
 	cleanText := strings.TrimSpace(text)
 	if cleanText == "" {
-		return 0, errors.New("fact text is required")
+		return 0, invalidInput("fact text is required")
 	}
 
-	switch s.driver {
-	case "postgres":
-		var factID int64
-		query := `INSERT INTO facts (article_id, fact_text, is_confirmed, is_included, source) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-		if err := s.database.QueryRowContext(ctx, query, articleID, cleanText, false, true, "manual").Scan(&factID); err != nil {
-			return 0, err
+	// New facts go last, after any order an editor has set.
+	var position int
+	if err := s.store.QueryRowContext(ctx, "SELECT COALESCE(MAX(position), 0) FROM facts WHERE article_id = ?", articleID).Scan(&position); err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO facts (article_id, fact_text, is_confirmed, is_included, source, position) VALUES (?, ?, ?, ?, ?, ?)`
+	factID, err := s.store.Insert(ctx, query, articleID, cleanText, false, true, "manual", position+1)
+	if err != nil {
+		return 0, err
+	}
+	announceEdit(ctx, s.store, "facts", models.CollaborationEvent{Type: models.CollaborationFactAdded, AnalysisID: articleID, EntityID: factID, Version: 1, UserID: addedBy})
+	return factID, nil
+}
+
+// ReorderFacts sets the order of an analysis's facts. factIDs must list each
+// of its facts exactly once.
+func (s *AdminService) ReorderFacts(ctx context.Context, articleID int64, factIDs []int64, reorderedBy *int64) error {
+	if len(factIDs) == 0 {
+		return invalidInput("ids are required")
+	}
+
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		var exists int
+		if err := tx.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+			return err
 		}
-		return factID, nil
-	case "mysql":
-		query := `INSERT INTO facts (article_id, fact_text, is_confirmed, is_included, source) VALUES (?, ?, ?, ?, ?)`
-		result, err := s.database.ExecContext(ctx, query, articleID, cleanText, false, true, "manual")
+
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM facts WHERE article_id = ? AND deleted_at IS NULL", articleID)
 		if err != nil {
-			return 0, err
+			return err
 		}
-		factID, err := result.LastInsertId()
-		if err != nil {
-			return 0, err
+		current := make(map[int64]bool)
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			current[id] = false
 		}
-		return factID, nil
-	default:
-		return 0, errors.New("unsupported database driver")
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(factIDs) != len(current) {
+			return invalidInputf("ids must be the analysis's %d fact ids", len(current))
+		}
+		for _, id := range factIDs {
+			listed, ok := current[id]
+			if !ok {
+				return invalidInputf("fact %d is invalid for this analysis", id)
+			}
+			if listed {
+				return invalidInputf("ids must be unique; fact %d is repeated", id)
+			}
+			current[id] = true
+		}
+
+		for idx, id := range factIDs {
+			if _, err := tx.ExecContext(ctx, "UPDATE facts SET position = ? WHERE id = ?", idx+1, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	announceEdit(ctx, s.store, "articles", models.CollaborationEvent{Type: models.CollaborationFactsReordered, AnalysisID: articleID, UserID: reorderedBy})
+	return nil
 }
 
-func (s *AdminService) UpdateFact(ctx context.Context, factID int64, text *string, included *bool, confirmed *bool) error {
+// UpdateFact edits a fact still at version and returns its new version.
+func (s *AdminService) UpdateFact(ctx context.Context, factID int64, version int64, text *string, included *bool, confirmed *bool, editedBy *int64) (int64, error) {
 	setClauses := make([]string, 0, 3)
 	args := make([]any, 0, 4)
-	placeholderIndex := 1
 
 	if text != nil {
 		clean := strings.TrimSpace(*text)
-		setClauses = append(setClauses, fmt.Sprintf("fact_text = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "fact_text = ?")
 		args = append(args, clean)
-		placeholderIndex++
 	}
 	if included != nil {
-		setClauses = append(setClauses, fmt.Sprintf("is_included = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "is_included = ?")
 		args = append(args, *included)
-		placeholderIndex++
 	}
 	if confirmed != nil {
-		setClauses = append(setClauses, fmt.Sprintf("is_confirmed = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "is_confirmed = ?")
 		args = append(args, *confirmed)
-		placeholderIndex++
 	}
 
 	if len(setClauses) == 0 {
-		return errors.New("no fact fields provided")
+		return 0, invalidInput("no fact fields provided")
 	}
 
-	args = append(args, factID)
-	query := fmt.Sprintf(
-		"UPDATE facts SET %s WHERE id = %s",
-		strings.Join(setClauses, ", "),
-		s.bind(placeholderIndex),
-	)
+	return s.trackedUpdate(ctx, factEdits, factID, version, setClauses, args, editedBy)
+}
 
-	result, err := s.database.ExecContext(ctx, query, args...)
+// DeleteFact moves a fact to the trash, from which RestoreFact brings it back.
+func (s *AdminService) DeleteFact(ctx context.Context, factID int64, deletedBy *int64) error {
+	result, err := s.store.ExecContext(ctx, "UPDATE facts SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", factID)
 	if err != nil {
 		return err
 	}
 	if err := ensureRowsAffected(result); err != nil {
 		return err
 	}
+	announceEdit(ctx, s.store, "facts", models.CollaborationEvent{Type: models.CollaborationFactDeleted, EntityID: factID, UserID: deletedBy})
 	return nil
 }
 
-func (s *AdminService) DeleteFact(ctx context.Context, factID int64) error {
-	query := fmt.Sprintf("DELETE FROM facts WHERE id = %s", s.bind(1))
-	result, err := s.database.ExecContext(ctx, query, factID)
+// RestoreFact takes a fact out of the trash, back at its old position.
+func (s *AdminService) RestoreFact(ctx context.Context, factID int64, restoredBy *int64) (models.AnalysisFact, error) {
+	result, err := s.store.ExecContext(ctx, "UPDATE facts SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", factID)
 	if err != nil {
-		return err
+		return models.AnalysisFact{}, err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return models.AnalysisFact{}, err
+	}
+
+	var fact models.AnalysisFact
+	err = s.store.QueryRowContext(
+		ctx,
+		"SELECT id, COALESCE(fact_text, ''), COALESCE(is_included, false), COALESCE(is_confirmed, false), COALESCE(source, ''), version FROM facts WHERE id = ?",
+		factID,
+	).Scan(&fact.ID, &fact.Text, &fact.Included, &fact.Confirmed, &fact.Source, &fact.Version)
+	if err != nil {
+		return models.AnalysisFact{}, err
+	}
+	announceEdit(ctx, s.store, "facts", models.CollaborationEvent{Type: models.CollaborationFactRestored, EntityID: factID, Version: fact.Version, UserID: restoredBy})
+	return fact, nil
+}
+
+// ListFacts returns an analysis's facts in order, with the ones in the trash
+// when includeDeleted is set.
+func (s *AdminService) ListFacts(ctx context.Context, articleID int64, includeDeleted bool) ([]models.AnalysisFact, error) {
+	var exists int
+	if err := s.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return nil, err
 	}
-	return ensureRowsAffected(result)
+	return s.listFactsByArticleID(ctx, articleID, includeDeleted)
 }
 
-func (s *AdminService) UpdateGap(ctx context.Context, gapID int64, text *string, selected *bool, resolved *bool) error {
+func (s *AdminService) UpdateGap(ctx context.Context, gapID int64, version int64, text *string, selected *bool, resolved *bool, editedBy *int64) (int64, error) {
 	setClauses := make([]string, 0, 3)
 	args := make([]any, 0, 4)
-	placeholderIndex := 1
 
 	if text != nil {
 		clean := strings.TrimSpace(*text)
-		setClauses = append(setClauses, fmt.Sprintf("question = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "question = ?")
 		args = append(args, clean)
-		placeholderIndex++
 	}
 	if selected != nil {
-		setClauses = append(setClauses, fmt.Sprintf("is_selected = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "is_selected = ?")
 		args = append(args, *selected)
-		placeholderIndex++
 	}
 	if resolved != nil {
-		setClauses = append(setClauses, fmt.Sprintf("is_resolved = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "is_resolved = ?")
 		args = append(args, *resolved)
-		placeholderIndex++
 	}
 
 	if len(setClauses) == 0 {
-		return errors.New("no gap fields provided")
+		return 0, invalidInput("no gap fields provided")
 	}
 
-	args = append(args, gapID)
-	query := fmt.Sprintf(
-		"UPDATE gaps SET %s WHERE id = %s",
-		strings.Join(setClauses, ", "),
-		s.bind(placeholderIndex),
-	)
+	return s.trackedUpdate(ctx, gapEdits, gapID, version, setClauses, args, editedBy)
+}
 
-	result, err := s.database.ExecContext(ctx, query, args...)
-	if err != nil {
-		return err
-	}
-	if err := ensureRowsAffected(result); err != nil {
+// ReviewGapAnswer records an editor's verdict on a candidate answer.
+// Accepting one marks its gap resolved.
+func (s *AdminService) ReviewGapAnswer(ctx context.Context, gapID int64, answerID int64, status string, reviewedBy *int64) error {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status != models.GapAnswerAccepted && status != models.GapAnswerRejected {
+		return invalidInputf("status must be %s or %s", models.GapAnswerAccepted, models.GapAnswerRejected)
+	}
+
+	return s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		result, err := tx.ExecContext(
+			ctx,
+			"UPDATE gap_answers SET status = ?, reviewed_by = ?, reviewed_at = CURRENT_TIMESTAMP WHERE id = ? AND gap_id = ?",
+			status,
+			reviewedBy,
+			answerID,
+			gapID,
+		)
+		if err != nil {
+			return err
+		}
+		if err := ensureRowsAffected(result); err != nil {
+			return err
+		}
+		if status != models.GapAnswerAccepted {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE gaps SET is_resolved = ?, version = version + 1 WHERE id = ?", true, gapID)
 		return err
-	}
-	return nil
+	})
 }
 
 func (s *AdminService) UpdateAnalysis(
 	ctx context.Context,
 	articleID int64,
+	version int64,
 	status *string,
 	category *string,
 	selectedFormat *string,
@@ -387,20 +938,34 @@ func (s *AdminService) UpdateAnalysis(
 	slug *string,
 	metaDescription *string,
 	excerpt *string,
+	updatedBy *int64,
 ) error {
 	setClauses := make([]string, 0, 9)
 	args := make([]any, 0, 12)
-	placeholderIndex := 1
 	updated := false
+	completing := false
 
 	if status != nil {
 		normalizedStatus, err := normalizeAnalysisStatus(*status)
 		if err != nil {
 			return err
 		}
-		setClauses = append(setClauses, fmt.Sprintf("status = %s", s.bind(placeholderIndex)))
+		if normalizedStatus == "completed" {
+			var previous string
+			if err := s.store.QueryRowContext(ctx, "SELECT status FROM articles WHERE id = ?", articleID).Scan(&previous); err != nil {
+				return err
+			}
+			completing = previous != "completed"
+		}
+		setClauses = append(setClauses, "status = ?")
 		args = append(args, normalizedStatus)
-		placeholderIndex++
+		switch {
+		case completing:
+			setClauses = append(setClauses, "completed_at = CURRENT_TIMESTAMP", "completed_by = ?")
+			args = append(args, updatedBy)
+		case normalizedStatus != "completed":
+			setClauses = append(setClauses, "completed_at = NULL", "completed_by = NULL")
+		}
 		updated = true
 	}
 
@@ -409,80 +974,102 @@ func (s *AdminService) UpdateAnalysis(
 		if err != nil {
 			return err
 		}
-		setClauses = append(setClauses, fmt.Sprintf("topic_id = %s", s.bind(placeholderIndex)))
+		// The editor has decided, so any pending suggestion is settled.
+		setClauses = append(setClauses, "topic_id = ?", "suggested_topic_id = NULL", "category_suggestion_source = NULL")
 		args = append(args, topicID)
-		placeholderIndex++
 		updated = true
 	}
 
 	if selectedFormat != nil {
-		setClauses = append(setClauses, fmt.Sprintf("selected_format = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "selected_format = ?")
 		args = append(args, strings.TrimSpace(*selectedFormat))
-		placeholderIndex++
 		updated = true
 	}
 
 	if articleText != nil {
-		setClauses = append(setClauses, fmt.Sprintf("article_text = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "article_text = ?")
 		args = append(args, strings.TrimSpace(*articleText))
-		placeholderIndex++
 		updated = true
 	}
 
 	if headlineSelected != nil {
-		setClauses = append(setClauses, fmt.Sprintf("headline_selected = %s", s.bind(placeholderIndex)))
+		if err := validateManualTitle(titleKindHeadline, *headlineSelected); err != nil {
+			return err
+		}
+		setClauses = append(setClauses, "headline_selected = ?")
 		args = append(args, strings.TrimSpace(*headlineSelected))
-		placeholderIndex++
 		updated = true
 	}
 
 	if straplineSelected != nil {
-		setClauses = append(setClauses, fmt.Sprintf("strapline_selected = %s", s.bind(placeholderIndex)))
+		if err := validateManualTitle(titleKindStrapline, *straplineSelected); err != nil {
+			return err
+		}
+		setClauses = append(setClauses, "strapline_selected = ?")
 		args = append(args, strings.TrimSpace(*straplineSelected))
-		placeholderIndex++
 		updated = true
 	}
 
 	if slug != nil {
-		setClauses = append(setClauses, fmt.Sprintf("slug = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "slug = ?")
 		args = append(args, strings.TrimSpace(*slug))
-		placeholderIndex++
 		updated = true
 	}
 
 	if metaDescription != nil {
-		setClauses = append(setClauses, fmt.Sprintf("meta_description = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "meta_description = ?")
 		args = append(args, strings.TrimSpace(*metaDescription))
-		placeholderIndex++
 		updated = true
 	}
 
 	if excerpt != nil {
-		setClauses = append(setClauses, fmt.Sprintf("excerpt = %s", s.bind(placeholderIndex)))
+		setClauses = append(setClauses, "excerpt = ?")
 		args = append(args, strings.TrimSpace(*excerpt))
-		placeholderIndex++
 		updated = true
 	}
 
 	if !updated {
-		return errors.New("no analysis fields provided")
+		return invalidInput("no analysis fields provided")
 	}
 
-	setClauses = append(setClauses, "updated_at = CURRENT_TIMESTAMP")
+	if completing {
+		// What is published must be what was approved.
+		edited := category != nil || selectedFormat != nil || articleText != nil || headlineSelected != nil ||
+			straplineSelected != nil || slug != nil || metaDescription != nil || excerpt != nil
+		if edited && config.Current().RequireApproval {
+			return invalidInput("complete an analysis without changing anything else; edits need approving first")
+		}
+		if err := checkOpenFlags(ctx, s.store, articleID); err != nil {
+			return err
+		}
+		if err := checkCompletionApproval(ctx, s.store, articleID, updatedBy); err != nil {
+			return err
+		}
+		rules, err := loadStyleRules(ctx, s.store)
+		if err != nil {
+			return err
+		}
+		if err := checkCompletionStyle(ctx, s.store, rules, articleID, articleText, headlineSelected, straplineSelected); err != nil {
+			return err
+		}
+	}
 
-	args = append(args, articleID)
-	query := fmt.Sprintf(
-		"UPDATE articles SET %s WHERE id = %s",
-		strings.Join(setClauses, ", "),
-		s.bind(placeholderIndex),
-	)
+	setClauses = append(setClauses, "updated_at = CURRENT_TIMESTAMP")
 
-	result, err := s.database.ExecContext(ctx, query, args...)
+	version, err := updateVersioned(ctx, s.store, "articles", "analysis", articleID, version, setClauses, args)
 	if err != nil {
 		return err
 	}
-	if err := ensureRowsAffected(result); err != nil {
-		return err
+	if completing {
+		if err := carryApprovals(ctx, s.store, articleID, version-1, version); err != nil {
+			return err
+		}
+	}
+
+	if articleText != nil {
+		if err := rescoreReadability(ctx, s.store, articleID); err != nil {
+			return err
+		}
 	}
 
 	if headlineSelected != nil {
@@ -496,53 +1083,220 @@ func (s *AdminService) UpdateAnalysis(
 			return err
 		}
 	}
+	announceEdit(ctx, s.store, "articles", models.CollaborationEvent{Type: models.CollaborationAnalysisUpdated, AnalysisID: articleID, Version: version, UserID: updatedBy})
 
+	if completing {
+		s.notifyCompleted(ctx, articleID, updatedBy)
+		announce(pipelineEvent{
+			event:     config.EventAnalysisPublished,
+			articleID: articleID,
+			headline:  analysisLabel(ctx, s.store, articleID),
+		})
+	}
 	return nil
 }
 
-func (s *AdminService) syncHeadlineSelection(ctx context.Context, articleID int64, selected string) error {
-	resetQuery := fmt.Sprintf("UPDATE headlines SET is_selected = %s WHERE article_id = %s", s.bind(1), s.bind(2))
-	if _, err := s.database.ExecContext(ctx, resetQuery, false, articleID); err != nil {
-		return err
+const maxBulkAnalyses = 500
+
+// BulkUpdateAnalyses sets the status and/or category of many analyses in one
+// transaction; if any id is unknown nothing changes. It reports how many
+// analyses were updated.
+func (s *AdminService) BulkUpdateAnalyses(ctx context.Context, articleIDs []int64, status *string, category *string, updatedBy *int64) (int, error) {
+	ids := make([]int64, 0, len(articleIDs))
+	seen := make(map[int64]struct{}, len(articleIDs))
+	for _, id := range articleIDs {
+		if id <= 0 {
+			return 0, invalidInputf("invalid analysis id %d", id)
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
 	}
-
-	clean := strings.TrimSpace(selected)
-	if clean == "" {
-		return nil
+	if len(ids) == 0 {
+		return 0, invalidInput("ids are required")
 	}
-
-	updateQuery := fmt.Sprintf(
-		"UPDATE headlines SET is_selected = %s WHERE article_id = %s AND LOWER(TRIM(headline_text)) = LOWER(%s)",
-		s.bind(1),
-		s.bind(2),
-		s.bind(3),
-	)
-	result, err := s.database.ExecContext(ctx, updateQuery, true, articleID, clean)
-	if err != nil {
-		return err
+	if len(ids) > maxBulkAnalyses {
+		return 0, invalidInputf("ids must be at most %d analyses", maxBulkAnalyses)
+	}
+	if status == nil && category == nil {
+		return 0, invalidInput("status or category is required")
 	}
 
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return err
+	setClauses := make([]string, 0, 5)
+	args := make([]any, 0, len(ids)+2)
+	normalizedStatus := ""
+	if status != nil {
+		var err error
+		if normalizedStatus, err = normalizeAnalysisStatus(*status); err != nil {
+			return 0, err
+		}
+		setClauses = append(setClauses, "status = ?")
+		args = append(args, normalizedStatus)
+		if normalizedStatus != "completed" {
+			setClauses = append(setClauses, "completed_at = NULL", "completed_by = NULL")
+		}
 	}
-	if affected > 0 {
-		return nil
+	if category != nil && normalizedStatus == "completed" && config.Current().RequireApproval {
+		return 0, invalidInput("complete analyses without changing their category; edits need approving first")
 	}
+	if category != nil {
+		topicID, err := s.getOrCreateTopic(ctx, *category)
+		if err != nil {
+			return 0, err
+		}
+		setClauses = append(setClauses, "topic_id = ?", "suggested_topic_id = NULL", "category_suggestion_source = NULL")
+		args = append(args, topicID)
+	}
+	setClauses = append(setClauses, "updated_at = CURRENT_TIMESTAMP", "version = version + 1")
 
-	insertQuery := fmt.Sprintf(
-		"INSERT INTO headlines (article_id, headline_text, is_selected) VALUES (%s, %s, %s)",
-		s.bind(1),
-		s.bind(2),
-		s.bind(3),
-	)
-	_, err = s.database.ExecContext(ctx, insertQuery, articleID, clean, true)
+	idArgs := make([]any, len(ids))
+	for idx, id := range ids {
+		idArgs[idx] = id
+	}
+	inClause := "id IN (" + repository.Placeholders(len(ids)) + ")"
+
+	var rules []models.StyleRule
+	if normalizedStatus == "completed" {
+		var err error
+		if rules, err = loadStyleRules(ctx, s.store); err != nil {
+			return 0, err
+		}
+	}
+
+	completing := make([]int64, 0)
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT id, status, version FROM articles WHERE "+inClause, idArgs...)
+		if err != nil {
+			return err
+		}
+		previous := make(map[int64]string, len(ids))
+		versions := make(map[int64]int64, len(ids))
+		for rows.Next() {
+			var (
+				id      int64
+				state   string
+				version int64
+			)
+			if err := rows.Scan(&id, &state, &version); err != nil {
+				rows.Close()
+				return err
+			}
+			previous[id] = state
+			versions[id] = version
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			state, ok := previous[id]
+			if !ok {
+				return invalidInputf("invalid analysis id %d", id)
+			}
+			if normalizedStatus == "completed" && state != "completed" {
+				if err := checkOpenFlags(ctx, tx, id); err != nil {
+					return err
+				}
+				if err := checkCompletionApproval(ctx, tx, id, updatedBy); err != nil {
+					return err
+				}
+				if err := checkCompletionStyle(ctx, tx, rules, id, nil, nil, nil); err != nil {
+					return err
+				}
+				completing = append(completing, id)
+			}
+		}
+
+		query := "UPDATE articles SET " + strings.Join(setClauses, ", ") + " WHERE " + inClause
+		if _, err := tx.ExecContext(ctx, query, append(args, idArgs...)...); err != nil {
+			return err
+		}
+		if len(completing) == 0 {
+			return nil
+		}
+
+		completingArgs := []any{updatedBy}
+		for _, id := range completing {
+			if err := carryApprovals(ctx, tx, id, versions[id], versions[id]+1); err != nil {
+				return err
+			}
+			completingArgs = append(completingArgs, id)
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			"UPDATE articles SET completed_at = CURRENT_TIMESTAMP, completed_by = ? WHERE id IN ("+repository.Placeholders(len(completing))+")",
+			completingArgs...,
+		)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range completing {
+		s.notifyCompleted(ctx, id, updatedBy)
+		announce(pipelineEvent{
+			event:     config.EventAnalysisPublished,
+			articleID: id,
+			headline:  analysisLabel(ctx, s.store, id),
+		})
+	}
+	return len(ids), nil
+}
+
+// notifyCompleted tells the submitter and the assignee that an analysis is
+// done, unless they finished it themselves.
+func (s *AdminService) notifyCompleted(ctx context.Context, articleID int64, completedBy *int64) {
+	var submittedBy, assignedTo sql.NullInt64
+	err := s.store.QueryRowContext(ctx, "SELECT submitted_by, assigned_to FROM articles WHERE id = ?", articleID).Scan(&submittedBy, &assignedTo)
+	if err != nil {
+		log.Printf("[notifications] failed to load recipients for article %d: %v", articleID, err)
+		return
+	}
+
+	notifyUsers(ctx, s.store, []*int64{nullInt64Pointer(submittedBy), nullInt64Pointer(assignedTo)}, completedBy, notification{
+		kind:      models.NotificationAnalysisCompleted,
+		articleID: &articleID,
+		title:     "Analysis completed",
+		body:      analysisLabel(ctx, s.store, articleID),
+	})
+}
+
+func (s *AdminService) syncHeadlineSelection(ctx context.Context, articleID int64, selected string) error {
+	resetQuery := "UPDATE headlines SET is_selected = ? WHERE article_id = ?"
+	if _, err := s.store.ExecContext(ctx, resetQuery, false, articleID); err != nil {
+		return err
+	}
+
+	clean := strings.TrimSpace(selected)
+	if clean == "" {
+		return nil
+	}
+
+	updateQuery := "UPDATE headlines SET is_selected = ? WHERE article_id = ? AND LOWER(TRIM(headline_text)) = LOWER(?)"
+	result, err := s.store.ExecContext(ctx, updateQuery, true, articleID, clean)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	insertQuery := "INSERT INTO headlines (article_id, headline_text, is_selected) VALUES (?, ?, ?)"
+	_, err = s.store.ExecContext(ctx, insertQuery, articleID, clean, true)
 	return err
 }
 
 func (s *AdminService) syncStraplineSelection(ctx context.Context, articleID int64, selected string) error {
-	resetQuery := fmt.Sprintf("UPDATE straplines SET is_selected = %s WHERE article_id = %s", s.bind(1), s.bind(2))
-	if _, err := s.database.ExecContext(ctx, resetQuery, false, articleID); err != nil {
+	resetQuery := "UPDATE straplines SET is_selected = ? WHERE article_id = ?"
+	if _, err := s.store.ExecContext(ctx, resetQuery, false, articleID); err != nil {
 		return err
 	}
 
@@ -551,13 +1305,8 @@ func (s *AdminService) syncStraplineSelection(ctx context.Context, articleID int
 		return nil
 	}
 
-	updateQuery := fmt.Sprintf(
-		"UPDATE straplines SET is_selected = %s WHERE article_id = %s AND LOWER(TRIM(strapline_text)) = LOWER(%s)",
-		s.bind(1),
-		s.bind(2),
-		s.bind(3),
-	)
-	result, err := s.database.ExecContext(ctx, updateQuery, true, articleID, clean)
+	updateQuery := "UPDATE straplines SET is_selected = ? WHERE article_id = ? AND LOWER(TRIM(strapline_text)) = LOWER(?)"
+	result, err := s.store.ExecContext(ctx, updateQuery, true, articleID, clean)
 	if err != nil {
 		return err
 	}
@@ -570,45 +1319,43 @@ func (s *AdminService) syncStraplineSelection(ctx context.Context, articleID int
 		return nil
 	}
 
-	insertQuery := fmt.Sprintf(
-		"INSERT INTO straplines (article_id, strapline_text, is_selected) VALUES (%s, %s, %s)",
-		s.bind(1),
-		s.bind(2),
-		s.bind(3),
-	)
-	_, err = s.database.ExecContext(ctx, insertQuery, articleID, clean, true)
+	insertQuery := "INSERT INTO straplines (article_id, strapline_text, is_selected) VALUES (?, ?, ?)"
+	_, err = s.store.ExecContext(ctx, insertQuery, articleID, clean, true)
 	return err
 }
 
-func (s *AdminService) ListCategories(ctx context.Context) ([]string, error) {
-	rows, err := s.database.QueryContext(ctx, `SELECT name FROM topics ORDER BY name ASC`)
+// AcceptCategorySuggestion files the analysis under its suggested topic.
+func (s *AdminService) AcceptCategorySuggestion(ctx context.Context, articleID int64) error {
+	query := `
+		UPDATE articles
+		SET topic_id = suggested_topic_id, suggested_topic_id = NULL, category_suggestion_source = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = ? AND suggested_topic_id IS NOT NULL
+	`
+	result, err := s.store.ExecContext(ctx, query, articleID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-
-	categories := make([]string, 0)
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, err
-		}
-		cleanName := strings.TrimSpace(name)
-		if cleanName == "" {
-			continue
-		}
-		categories = append(categories, cleanName)
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	var exists int
+	if err := s.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return err
 	}
+	return invalidInput("no category suggestion to accept")
+}
 
-	return categories, nil
+func (s *AdminService) ListCategories(ctx context.Context) ([]string, error) {
+	return listTopicNames(ctx, s.store)
 }
 
 func (s *AdminService) GetSettings(ctx context.Context) (models.SettingsResponse, error) {
-	providers, err := s.listProvidersAndModels(ctx)
+	providers, err := listProvidersAndModels(ctx, s.store)
 	if err != nil {
 		return models.SettingsResponse{}, err
 	}
@@ -631,7 +1378,7 @@ func (s *AdminService) GetSettings(ctx context.Context) (models.SettingsResponse
 		updatedAt   time.Time
 	)
 
-	err = s.database.QueryRowContext(ctx, currentQuery).Scan(&providerKey, &modelKey, &updatedAt)
+	err = s.store.QueryRowContext(ctx, currentQuery).Scan(&providerKey, &modelKey, &updatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return models.SettingsResponse{
 			Providers: providers,
@@ -653,87 +1400,323 @@ func (s *AdminService) UpdateSettings(ctx context.Context, providerKey string, m
 	cleanProvider := strings.TrimSpace(providerKey)
 	cleanModel := strings.TrimSpace(modelKey)
 	if cleanProvider == "" || cleanModel == "" {
-		return errors.New("provider and model are required")
+		return invalidInput("provider and model are required")
 	}
 
-	var (
-		providerID int64
-		modelID    int64
+	selection, err := s.selectableModel(ctx, cleanProvider, cleanModel)
+	if err != nil {
+		return err
+	}
+
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"app_settings",
+		[]string{"id", "provider_id", "model_id"},
+		[]string{"id"},
+		[]string{"provider_id", "model_id"},
+		"updated_at = CURRENT_TIMESTAMP",
 	)
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.ExecContext(ctx, query, 1, selection.providerID, selection.modelID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type modelSelection struct {
+	providerID int64
+	modelID    int64
+	maxTokens  int
+}
 
+// selectableModel looks up an enabled model of a provider for the settings.
+func (s *AdminService) selectableModel(ctx context.Context, providerKey string, modelKey string) (modelSelection, error) {
 	matchQuery := `
-		SELECT p.id, m.id
+		SELECT p.id, m.id, COALESCE(m.is_enabled, true), COALESCE(m.max_tokens, 0)
 		FROM ai_providers p
 		JOIN ai_models m ON m.provider_id = p.id
-		WHERE p.provider_key = %s AND m.model_key = %s
+		WHERE p.provider_key = ? AND m.model_key = ?
 		LIMIT 1;
 	`
 
-	matchProviderBind := "?"
-	matchModelBind := "?"
-	if s.driver == "postgres" {
-		matchProviderBind = "$1"
-		matchModelBind = "$2"
+	var (
+		selection modelSelection
+		enabled   bool
+	)
+	err := s.store.QueryRowContext(ctx, matchQuery, providerKey, modelKey).Scan(&selection.providerID, &selection.modelID, &enabled, &selection.maxTokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		return modelSelection{}, invalidInput("invalid provider/model selection")
+	}
+	if err != nil {
+		return modelSelection{}, err
+	}
+	if !enabled {
+		return modelSelection{}, invalidInput("invalid provider/model selection: model is disabled")
 	}
+	return selection, nil
+}
 
-	if err := s.database.QueryRowContext(
-		ctx,
-		fmt.Sprintf(matchQuery, matchProviderBind, matchModelBind),
-		cleanProvider,
-		cleanModel,
-	).Scan(&providerID, &modelID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return errors.New("invalid provider/model selection")
+// listPromptVersionsByArticleID is empty for analyses created before prompt
+// versions were recorded.
+func (s *AdminService) listPromptVersionsByArticleID(ctx context.Context, articleID int64) (map[string]int, error) {
+	rows, err := s.store.QueryContext(ctx, "SELECT template_key, version FROM article_prompt_versions WHERE article_id = ?", articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]int)
+	for rows.Next() {
+		var (
+			key     string
+			version int
+		)
+		if err := rows.Scan(&key, &version); err != nil {
+			return nil, err
+		}
+		versions[key] = version
+	}
+	return versions, rows.Err()
+}
+
+// listSourcesByArticleID is empty unless the analysis was built from several
+// sources.
+func (s *AdminService) listSourcesByArticleID(ctx context.Context, articleID int64) ([]models.AnalysisSource, error) {
+	query := `
+		SELECT position, COALESCE(source_url, ''), COALESCE(fetch_strategy, ''), fact_count
+		FROM article_sources
+		WHERE article_id = ?
+		ORDER BY position ASC;
+	`
+
+	rows, err := s.store.QueryContext(ctx, query, articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make([]models.AnalysisSource, 0)
+	for rows.Next() {
+		var source models.AnalysisSource
+		if err := rows.Scan(&source.Position, &source.URL, &source.FetchStrategy, &source.FactCount); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
+
+// listImagesByArticleID leaves out the transcribed text for callers without
+// source visibility, as with the raw text.
+func (s *AdminService) listImagesByArticleID(ctx context.Context, articleID int64, visibility models.Visibility) ([]models.AnalysisImage, error) {
+	query := `
+		SELECT id, position, COALESCE(file_name, ''), mime_type, size_bytes, sha256, COALESCE(ocr_text, ''), COALESCE(created_at, CURRENT_TIMESTAMP)
+		FROM article_images
+		WHERE article_id = ?
+		ORDER BY position ASC;
+	`
+
+	rows, err := s.store.QueryContext(ctx, query, articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := make([]models.AnalysisImage, 0)
+	for rows.Next() {
+		var image models.AnalysisImage
+		if err := rows.Scan(&image.ID, &image.Position, &image.FileName, &image.MimeType, &image.SizeBytes, &image.SHA256, &image.OCRText, &image.CreatedAt); err != nil {
+			return nil, err
+		}
+		if !visibility.SourceText {
+			image.OCRText = ""
+		}
+		images = append(images, image)
+	}
+	return images, rows.Err()
+}
+
+// GetAnalysisImage returns an uploaded image and the path of its stored file.
+func (s *AdminService) GetAnalysisImage(ctx context.Context, articleID int64, imageID int64) (models.AnalysisImage, string, error) {
+	query := `
+		SELECT id, position, COALESCE(file_name, ''), mime_type, size_bytes, sha256, storage_key
+		FROM article_images
+		WHERE id = ? AND article_id = ?;
+	`
+
+	var (
+		image      models.AnalysisImage
+		storageKey string
+	)
+	err := s.store.QueryRowContext(ctx, query, imageID, articleID).Scan(
+		&image.ID, &image.Position, &image.FileName, &image.MimeType, &image.SizeBytes, &image.SHA256, &storageKey,
+	)
+	if err != nil {
+		return models.AnalysisImage{}, "", err
+	}
+	return image, ImagePath(storageKey), nil
+}
+
+func (s *AdminService) listSnapshotsByArticleID(ctx context.Context, articleID int64) ([]models.SourceSnapshot, error) {
+	query := `
+		SELECT id, position, source_url, fetched_url, fetch_strategy, COALESCE(content_type, ''), size_bytes, sha256, fetched_at
+		FROM source_snapshots
+		WHERE article_id = ?
+		ORDER BY position ASC;
+	`
+
+	rows, err := s.store.QueryContext(ctx, query, articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make([]models.SourceSnapshot, 0)
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
 		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// GetSourceSnapshot returns a source snapshot and the path of its stored file.
+func (s *AdminService) GetSourceSnapshot(ctx context.Context, articleID int64, snapshotID int64) (models.SourceSnapshot, string, error) {
+	query := `
+		SELECT id, position, source_url, fetched_url, fetch_strategy, COALESCE(content_type, ''), size_bytes, sha256, fetched_at, storage_key
+		FROM source_snapshots
+		WHERE id = ? AND article_id = ?;
+	`
+
+	var (
+		snapshot   models.SourceSnapshot
+		storageKey string
+	)
+	err := s.store.QueryRowContext(ctx, query, snapshotID, articleID).Scan(
+		&snapshot.ID, &snapshot.Position, &snapshot.SourceURL, &snapshot.FetchedURL, &snapshot.FetchStrategy,
+		&snapshot.ContentType, &snapshot.SizeBytes, &snapshot.SHA256, &snapshot.FetchedAt, &storageKey,
+	)
+	if err != nil {
+		return models.SourceSnapshot{}, "", err
+	}
+	return snapshot, SnapshotPath(storageKey), nil
+}
+
+func scanSnapshot(row rowScanner) (models.SourceSnapshot, error) {
+	var snapshot models.SourceSnapshot
+	err := row.Scan(
+		&snapshot.ID, &snapshot.Position, &snapshot.SourceURL, &snapshot.FetchedURL, &snapshot.FetchStrategy,
+		&snapshot.ContentType, &snapshot.SizeBytes, &snapshot.SHA256, &snapshot.FetchedAt,
+	)
+	return snapshot, err
+}
+
+// attachFactSources marks each extracted fact as corroborated or
+// single-source. Facts added by editors have no sources and are left unmarked.
+func (s *AdminService) attachFactSources(ctx context.Context, articleID int64, facts []models.AnalysisFact) error {
+	query := `
+		SELECT fs.fact_id, src.position
+		FROM fact_sources fs
+		JOIN article_sources src ON src.id = fs.article_source_id
+		WHERE src.article_id = ?
+		ORDER BY fs.fact_id ASC, src.position ASC;
+	`
+
+	rows, err := s.store.QueryContext(ctx, query, articleID)
+	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	switch s.driver {
-	case "postgres":
-		query := `
-			INSERT INTO app_settings (id, provider_id, model_id, updated_at)
-			VALUES (1, $1, $2, CURRENT_TIMESTAMP)
-			ON CONFLICT (id) DO UPDATE SET
-				provider_id = EXCLUDED.provider_id,
-				model_id = EXCLUDED.model_id,
-				updated_at = CURRENT_TIMESTAMP;
-		`
-		if _, err := s.database.ExecContext(ctx, query, providerID, modelID); err != nil {
+	positions := make(map[int64][]int)
+	for rows.Next() {
+		var (
+			factID   int64
+			position int
+		)
+		if err := rows.Scan(&factID, &position); err != nil {
 			return err
 		}
-	case "mysql":
-		query := `
-			INSERT INTO app_settings (id, provider_id, model_id, updated_at)
-			VALUES (1, ?, ?, CURRENT_TIMESTAMP)
-			ON DUPLICATE KEY UPDATE
-				provider_id = VALUES(provider_id),
-				model_id = VALUES(model_id),
-				updated_at = CURRENT_TIMESTAMP;
-		`
-		if _, err := s.database.ExecContext(ctx, query, providerID, modelID); err != nil {
+		positions[factID] = append(positions[factID], position)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for idx := range facts {
+		if sources, ok := positions[facts[idx].ID]; ok {
+			facts[idx].Sources = sources
+			facts[idx].Corroboration = corroborationStatus(len(sources))
+		}
+	}
+	return nil
+}
+
+func (s *AdminService) attachFactChecks(ctx context.Context, articleID int64, facts []models.AnalysisFact) error {
+	query := `
+		SELECT id, fact_id, COALESCE(claim_text, ''), COALESCE(claimant, ''), COALESCE(claim_date, ''),
+			COALESCE(publisher, ''), COALESCE(publisher_site, ''), review_url, COALESCE(review_title, ''),
+			COALESCE(rating, ''), COALESCE(language_code, ''), COALESCE(review_date, '')
+		FROM fact_check_matches
+		WHERE article_id = ?
+		ORDER BY fact_id ASC, id ASC;
+	`
+
+	rows, err := s.store.QueryContext(ctx, query, articleID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byFact := make(map[int64][]models.FactCheckMatch)
+	for rows.Next() {
+		var match models.FactCheckMatch
+		if err := rows.Scan(
+			&match.ID,
+			&match.FactID,
+			&match.ClaimText,
+			&match.Claimant,
+			&match.ClaimDate,
+			&match.Publisher,
+			&match.PublisherSite,
+			&match.URL,
+			&match.Title,
+			&match.Rating,
+			&match.Language,
+			&match.ReviewDate,
+		); err != nil {
 			return err
 		}
-	default:
-		return errors.New("unsupported database driver")
+		byFact[match.FactID] = append(byFact[match.FactID], match)
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
+	for idx := range facts {
+		facts[idx].FactChecks = byFact[facts[idx].ID]
+	}
 	return nil
 }
 
-func (s *AdminService) listFactsByArticleID(ctx context.Context, articleID int64) ([]models.AnalysisFact, error) {
+func (s *AdminService) listFactsByArticleID(ctx context.Context, articleID int64, includeDeleted bool) ([]models.AnalysisFact, error) {
+	filter := " AND deleted_at IS NULL"
+	if includeDeleted {
+		filter = ""
+	}
 	query := `
-		SELECT id, COALESCE(fact_text, ''), COALESCE(is_included, false), COALESCE(is_confirmed, false), COALESCE(source, '')
+		SELECT id, COALESCE(fact_text, ''), COALESCE(is_included, false), COALESCE(is_confirmed, false), COALESCE(source, ''), version, deleted_at
 		FROM facts
-		WHERE article_id = %s
-		ORDER BY id ASC;
+		WHERE article_id = ?` + filter + `
+		ORDER BY position ASC, id ASC;
 	`
 
-	idBind := "?"
-	if s.driver == "postgres" {
-		idBind = "$1"
-	}
-
-	rows, err := s.database.QueryContext(ctx, fmt.Sprintf(query, idBind), articleID)
+	rows, err := s.store.QueryContext(ctx, query, articleID)
 	if err != nil {
 		return nil, err
 	}
@@ -741,10 +1724,16 @@ func (s *AdminService) listFactsByArticleID(ctx context.Context, articleID int64
 
 	facts := make([]models.AnalysisFact, 0)
 	for rows.Next() {
-		var fact models.AnalysisFact
-		if err := rows.Scan(&fact.ID, &fact.Text, &fact.Included, &fact.Confirmed, &fact.Source); err != nil {
+		var (
+			fact      models.AnalysisFact
+			deletedAt sql.NullTime
+		)
+		if err := rows.Scan(&fact.ID, &fact.Text, &fact.Included, &fact.Confirmed, &fact.Source, &fact.Version, &deletedAt); err != nil {
 			return nil, err
 		}
+		if deletedAt.Valid {
+			fact.DeletedAt = &deletedAt.Time
+		}
 		facts = append(facts, fact)
 	}
 
@@ -752,23 +1741,26 @@ func (s *AdminService) listFactsByArticleID(ctx context.Context, articleID int64
 		return nil, err
 	}
 
+	tags, err := listFactTags(ctx, s.store, articleID)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range facts {
+		facts[idx].Tags = tags[facts[idx].ID]
+	}
+
 	return facts, nil
 }
 
 func (s *AdminService) listGapsByArticleID(ctx context.Context, articleID int64) ([]models.AnalysisGap, error) {
 	query := `
-		SELECT id, COALESCE(question, ''), COALESCE(is_selected, true), COALESCE(is_resolved, false)
+		SELECT id, COALESCE(question, ''), COALESCE(is_selected, true), COALESCE(is_resolved, false), COALESCE(origin, ''), version
 		FROM gaps
-		WHERE article_id = %s
+		WHERE article_id = ?
 		ORDER BY id ASC;
 	`
 
-	idBind := "?"
-	if s.driver == "postgres" {
-		idBind = "$1"
-	}
-
-	rows, err := s.database.QueryContext(ctx, fmt.Sprintf(query, idBind), articleID)
+	rows, err := s.store.QueryContext(ctx, query, articleID)
 	if err != nil {
 		return nil, err
 	}
@@ -777,7 +1769,7 @@ func (s *AdminService) listGapsByArticleID(ctx context.Context, articleID int64)
 	gaps := make([]models.AnalysisGap, 0)
 	for rows.Next() {
 		var gap models.AnalysisGap
-		if err := rows.Scan(&gap.ID, &gap.Text, &gap.Selected, &gap.Resolved); err != nil {
+		if err := rows.Scan(&gap.ID, &gap.Text, &gap.Selected, &gap.Resolved, &gap.Origin, &gap.Version); err != nil {
 			return nil, err
 		}
 		gaps = append(gaps, gap)
@@ -787,23 +1779,99 @@ func (s *AdminService) listGapsByArticleID(ctx context.Context, articleID int64)
 		return nil, err
 	}
 
+	leads, err := s.listGapLeadsByArticleID(ctx, articleID)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := s.listGapAnswersByArticleID(ctx, articleID)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range gaps {
+		gaps[idx].Leads = leads[gaps[idx].ID]
+		gaps[idx].Answers = answers[gaps[idx].ID]
+	}
+
 	return gaps, nil
 }
 
+func (s *AdminService) listGapLeadsByArticleID(ctx context.Context, articleID int64) (map[int64][]models.GapLead, error) {
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT id, gap_id, url, title, COALESCE(summary, ''), feed_url, published_at, score, created_at
+		FROM gap_leads
+		WHERE article_id = ?
+		ORDER BY gap_id ASC, score DESC, id ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leads := make(map[int64][]models.GapLead)
+	for rows.Next() {
+		var (
+			lead      models.GapLead
+			gapID     int64
+			published sql.NullTime
+		)
+		if err := rows.Scan(&lead.ID, &gapID, &lead.URL, &lead.Title, &lead.Summary, &lead.FeedURL, &published, &lead.Score, &lead.CreatedAt); err != nil {
+			return nil, err
+		}
+		if published.Valid {
+			lead.PublishedAt = &published.Time
+		}
+		leads[gapID] = append(leads[gapID], lead)
+	}
+	return leads, rows.Err()
+}
+
+func (s *AdminService) listGapAnswersByArticleID(ctx context.Context, articleID int64) (map[int64][]models.GapAnswer, error) {
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT id, gap_id, answer, confidence, sources, search_query, status, reviewed_at, created_at
+		FROM gap_answers
+		WHERE article_id = ?
+		ORDER BY gap_id ASC, id ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := make(map[int64][]models.GapAnswer)
+	for rows.Next() {
+		var (
+			answer     models.GapAnswer
+			gapID      int64
+			sources    string
+			reviewedAt sql.NullTime
+		)
+		if err := rows.Scan(&answer.ID, &gapID, &answer.Answer, &answer.Confidence, &sources, &answer.Query, &answer.Status, &reviewedAt, &answer.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(sources), &answer.Sources); err != nil {
+			return nil, fmt.Errorf("gap answer %d sources: %w", answer.ID, err)
+		}
+		if reviewedAt.Valid {
+			answer.ReviewedAt = &reviewedAt.Time
+		}
+		answers[gapID] = append(answers[gapID], answer)
+	}
+	return answers, rows.Err()
+}
+
 func (s *AdminService) listHeadlineOptionsByArticleID(ctx context.Context, articleID int64) ([]string, string, error) {
 	query := `
 		SELECT COALESCE(headline_text, ''), COALESCE(is_selected, false)
 		FROM headlines
-		WHERE article_id = %s
+		WHERE article_id = ?
 		ORDER BY id ASC;
 	`
 
-	idBind := "?"
-	if s.driver == "postgres" {
-		idBind = "$1"
-	}
-
-	rows, err := s.database.QueryContext(ctx, fmt.Sprintf(query, idBind), articleID)
+	rows, err := s.store.QueryContext(ctx, query, articleID)
 	if err != nil {
 		return nil, "", err
 	}
@@ -841,16 +1909,11 @@ func (s *AdminService) listStraplineOptionsByArticleID(ctx context.Context, arti
 	query := `
 		SELECT COALESCE(strapline_text, ''), COALESCE(is_selected, false)
 		FROM straplines
-		WHERE article_id = %s
+		WHERE article_id = ?
 		ORDER BY id ASC;
 	`
 
-	idBind := "?"
-	if s.driver == "postgres" {
-		idBind = "$1"
-	}
-
-	rows, err := s.database.QueryContext(ctx, fmt.Sprintf(query, idBind), articleID)
+	rows, err := s.store.QueryContext(ctx, query, articleID)
 	if err != nil {
 		return nil, "", err
 	}
@@ -884,96 +1947,16 @@ func (s *AdminService) listStraplineOptionsByArticleID(ctx context.Context, arti
 	return dedupeStrings(options), selected, nil
 }
 
-func (s *AdminService) listProvidersAndModels(ctx context.Context) ([]models.ProviderOption, error) {
-	query := `
-		SELECT
-			p.id,
-			p.provider_key,
-			p.display_name,
-			m.id,
-			m.model_key,
-			m.display_name,
-			COALESCE(m.is_default, false)
-		FROM ai_providers p
-		LEFT JOIN ai_models m ON m.provider_id = p.id
-		ORDER BY p.id ASC, m.id ASC;
-	`
-
-	rows, err := s.database.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	providers := make([]models.ProviderOption, 0)
-	indexByProviderID := make(map[int64]int)
-
-	for rows.Next() {
-		var (
-			providerID   int64
-			providerKey  string
-			providerName string
-			modelID      sql.NullInt64
-			modelKey     sql.NullString
-			modelName    sql.NullString
-			modelDefault sql.NullBool
-		)
-
-		if err := rows.Scan(
-			&providerID,
-			&providerKey,
-			&providerName,
-			&modelID,
-			&modelKey,
-			&modelName,
-			&modelDefault,
-		); err != nil {
-			return nil, err
-		}
-
-		providerIndex, exists := indexByProviderID[providerID]
-		if !exists {
-			providers = append(providers, models.ProviderOption{
-				ID:     providerID,
-				Key:    providerKey,
-				Name:   providerName,
-				Models: make([]models.ModelOption, 0),
-			})
-			providerIndex = len(providers) - 1
-			indexByProviderID[providerID] = providerIndex
-		}
-
-		if modelID.Valid {
-			providers[providerIndex].Models = append(providers[providerIndex].Models, models.ModelOption{
-				ID:        modelID.Int64,
-				Key:       modelKey.String,
-				Name:      modelName.String,
-				IsDefault: modelDefault.Valid && modelDefault.Bool,
-			})
-		}
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return providers, nil
-}
-
 func (s *AdminService) getOrCreateTopic(ctx context.Context, category string) (int64, error) {
 	cleanCategory := strings.TrimSpace(category)
 	if cleanCategory == "" {
-		return 0, errors.New("category cannot be empty")
+		return 0, invalidInput("category cannot be empty")
 	}
 
-	selectQuery := `SELECT id FROM topics WHERE LOWER(name) = LOWER(%s) LIMIT 1`
-	selectBind := "?"
-	if s.driver == "postgres" {
-		selectBind = "$1"
-	}
+	selectQuery := `SELECT id FROM topics WHERE LOWER(name) = LOWER(?) LIMIT 1`
 
 	var topicID int64
-	err := s.database.QueryRowContext(ctx, fmt.Sprintf(selectQuery, selectBind), cleanCategory).Scan(&topicID)
+	err := s.store.QueryRowContext(ctx, selectQuery, cleanCategory).Scan(&topicID)
 	if err == nil {
 		return topicID, nil
 	}
@@ -981,62 +1964,88 @@ func (s *AdminService) getOrCreateTopic(ctx context.Context, category string) (i
 		return 0, err
 	}
 
-	switch s.driver {
-	case "postgres":
-		insertQuery := `INSERT INTO topics (name) VALUES ($1) ON CONFLICT (name) DO NOTHING RETURNING id`
-		insertErr := s.database.QueryRowContext(ctx, insertQuery, cleanCategory).Scan(&topicID)
-		if insertErr == nil {
-			return topicID, nil
-		}
-		if !errors.Is(insertErr, sql.ErrNoRows) {
-			return 0, insertErr
-		}
+	insertQuery, err := repository.InsertIgnore(s.store.Driver(), "topics", []string{"name"}, []string{"name"})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.store.ExecContext(ctx, insertQuery, cleanCategory); err != nil {
+		return 0, err
+	}
+	if err := s.store.QueryRowContext(ctx, selectQuery, cleanCategory).Scan(&topicID); err != nil {
+		return 0, err
+	}
+	return topicID, nil
+}
 
-		if err := s.database.QueryRowContext(ctx, `SELECT id FROM topics WHERE LOWER(name) = LOWER($1) LIMIT 1`, cleanCategory).Scan(&topicID); err != nil {
-			return 0, err
-		}
-		return topicID, nil
-	case "mysql":
-		if _, err := s.database.ExecContext(ctx, `INSERT IGNORE INTO topics (name) VALUES (?)`, cleanCategory); err != nil {
-			return 0, err
-		}
-		if err := s.database.QueryRowContext(ctx, `SELECT id FROM topics WHERE LOWER(name) = LOWER(?) LIMIT 1`, cleanCategory).Scan(&topicID); err != nil {
-			return 0, err
-		}
-		return topicID, nil
-	default:
-		return 0, errors.New("unsupported database driver")
+type dashboardArticleCounts struct {
+	total        int64
+	pending      int64
+	completed    int64
+	unassigned   int64
+	assignedToMe int64
+
+	awaitingApproval int64
+	approved         int64
+	flagged          int64
+}
+
+// articleCounts reads the dashboard's article tallies in one pass. A nil
+// userID matches no assignee.
+func (s *AdminService) articleCounts(ctx context.Context, userID *int64) (dashboardArticleCounts, error) {
+	const (
+		approvedNow = "EXISTS (SELECT 1 FROM analysis_approvals ap WHERE ap.article_id = a.id AND ap.version = a.version)"
+		flaggedNow  = "EXISTS (SELECT 1 FROM analysis_flags f WHERE f.article_id = a.id AND f.cleared_at IS NULL)"
+	)
+	query := `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN a.status = 'pending' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN a.status = 'completed' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN a.assigned_to IS NULL AND a.status <> 'completed' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN a.assigned_to = ? AND a.status <> 'completed' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN a.status = 'pending' AND NOT ` + approvedNow + ` THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN a.status <> 'completed' AND ` + approvedNow + ` THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN a.status <> 'completed' AND ` + flaggedNow + ` THEN 1 ELSE 0 END), 0)
+		FROM articles a`
+
+	var counts dashboardArticleCounts
+	err := s.store.QueryRowContext(ctx, query, userID).Scan(
+		&counts.total,
+		&counts.pending,
+		&counts.completed,
+		&counts.unassigned,
+		&counts.assignedToMe,
+		&counts.awaitingApproval,
+		&counts.approved,
+		&counts.flagged,
+	)
+	if err != nil {
+		return dashboardArticleCounts{}, err
 	}
+	return counts, nil
 }
 
 func (s *AdminService) factUsage(ctx context.Context) (int64, int64, error) {
-	query := `SELECT COALESCE(SUM(CASE WHEN is_included THEN 1 ELSE 0 END), 0), COUNT(*) FROM facts`
+	query := `SELECT COALESCE(SUM(CASE WHEN is_included THEN 1 ELSE 0 END), 0), COUNT(*) FROM facts WHERE deleted_at IS NULL`
 
 	var (
 		included int64
 		total    int64
 	)
-	if err := s.database.QueryRowContext(ctx, query).Scan(&included, &total); err != nil {
+	if err := s.store.QueryRowContext(ctx, query).Scan(&included, &total); err != nil {
 		return 0, 0, err
 	}
 	return included, total, nil
 }
 
-func (s *AdminService) count(ctx context.Context, query string) (int64, error) {
+func (s *AdminService) count(ctx context.Context, query string, args ...any) (int64, error) {
 	var value int64
-	if err := s.database.QueryRowContext(ctx, query).Scan(&value); err != nil {
+	if err := s.store.QueryRowContext(ctx, query, args...).Scan(&value); err != nil {
 		return 0, err
 	}
 	return value, nil
 }
 
-func (s *AdminService) bind(index int) string {
-	if s.driver == "postgres" {
-		return fmt.Sprintf("$%d", index)
-	}
-	return "?"
-}
-
 func ensureRowsAffected(result sql.Result) error {
 	affected, err := result.RowsAffected()
 	if err != nil {
@@ -1076,8 +2085,22 @@ func normalizeAnalysisStatus(status string) (string, error) {
 	case "draft", "pending", "completed":
 		return clean, nil
 	default:
-		return "", errors.New("status must be draft, pending, or completed")
+		return "", invalidInput("status must be draft, pending, or completed")
+	}
+}
+
+// normalizeStatusFilter also accepts the statuses the pipeline, the retention
+// run and imports set, which editors can filter on but not set.
+func normalizeStatusFilter(status string) (string, error) {
+	clean := strings.ToLower(strings.TrimSpace(status))
+	switch clean {
+	case analysisStatusRunning, analysisStatusFailed, analysisStatusRetrying, analysisStatusArchived, analysisStatusImported:
+		return clean, nil
+	}
+	if _, err := normalizeAnalysisStatus(clean); err != nil {
+		return "", invalidInput("status must be draft, pending, completed, running, failed, retrying, archived, or imported")
 	}
+	return clean, nil
 }
 
 func formatStatus(status string) string {
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+const analysisChangedAt = "COALESCE(a.updated_at, a.created_at)"
+
+// AnalysisChanges lists analyses changed after cursor, oldest first, for
+// automation tools to poll. Without a cursor it starts with the latest limit
+// changes. Each analysis appears once, as it is now; titles never fall back to
+// the source text, since every role may poll.
+func (s *AdminService) AnalysisChanges(ctx context.Context, cursor string, limit int) (models.AnalysisChanges, error) {
+	limit = normalizeLimit(limit)
+	result := models.AnalysisChanges{Items: make([]models.AnalysisChange, 0), Cursor: strings.TrimSpace(cursor)}
+
+	query := `
+		SELECT a.id, COALESCE(a.headline_selected, ''), COALESCE(a.source_url, ''), COALESCE(a.status, 'draft'),
+			COALESCE(t.name, 'Uncategorized'), a.version, COALESCE(a.created_at, CURRENT_TIMESTAMP), ` + analysisChangedAt + `
+		FROM articles a
+		LEFT JOIN topics t ON t.id = a.topic_id`
+	args := make([]any, 0, 4)
+	if result.Cursor == "" {
+		query += " ORDER BY " + analysisChangedAt + " DESC, a.id DESC LIMIT ?"
+	} else {
+		changedAt, articleID, err := parseChangeCursor(result.Cursor)
+		if err != nil {
+			return result, err
+		}
+		query += `
+		WHERE ` + analysisChangedAt + ` > ? OR (` + analysisChangedAt + ` = ? AND a.id > ?)
+		ORDER BY ` + analysisChangedAt + ` ASC, a.id ASC LIMIT ?`
+		args = append(args, changedAt, changedAt, articleID)
+	}
+	args = append(args, limit+1)
+
+	rows, err := s.store.QueryContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	cfg := config.Current()
+	for rows.Next() {
+		var (
+			change    models.AnalysisChange
+			headline  string
+			sourceURL string
+		)
+		if err := rows.Scan(&change.AnalysisID, &headline, &sourceURL, &change.Status, &change.Category, &change.Version, &change.CreatedAt, &change.UpdatedAt); err != nil {
+			return result, err
+		}
+		change.ID = fmt.Sprintf("%d-%d", change.AnalysisID, change.UpdatedAt.UnixMilli())
+		change.Title = buildAnalysisTitle(change.AnalysisID, headline, sourceURL, "")
+		change.Status = strings.ToLower(change.Status)
+		change.URL = analysisLink(cfg, change.AnalysisID)
+		result.Items = append(result.Items, change)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	if len(result.Items) > limit {
+		result.HasMore = result.Cursor != ""
+		result.Items = result.Items[:limit]
+	}
+	if result.Cursor == "" {
+		slices.Reverse(result.Items)
+	}
+	if len(result.Items) > 0 {
+		last := result.Items[len(result.Items)-1]
+		result.Cursor = changeCursor(last.UpdatedAt, last.AnalysisID)
+	}
+	return result, nil
+}
+
+// A cursor is the change time and ID of the last analysis returned, opaque to
+// callers.
+func changeCursor(changedAt time.Time, articleID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(changedAt.Format(time.RFC3339Nano) + "|" + strconv.FormatInt(articleID, 10)))
+}
+
+func parseChangeCursor(cursor string) (time.Time, int64, error) {
+	invalid := invalidInput("cursor is invalid; pass back the cursor of an earlier response")
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	rawTime, rawID, ok := strings.Cut(string(decoded), "|")
+	if !ok {
+		return time.Time{}, 0, invalid
+	}
+	changedAt, err := time.Parse(time.RFC3339Nano, rawTime)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	articleID, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, invalid
+	}
+	return changedAt, articleID, nil
+}
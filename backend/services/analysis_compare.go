@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	"nanoheads/models"
+)
+
+// Article texts longer than this many sentences are compared up to it; the
+// diff is quadratic in the sentence count.
+const maxCompareSentences = 600
+
+type comparedFact struct {
+	text    string
+	tokens  map[string]struct{}
+	numbers map[string]struct{}
+}
+
+// CompareAnalyses diffs two analyses of the same story. Their included facts
+// are matched as the sources of a multi-source analysis are, one to one; of
+// the facts left over, pairs about the same thing whose figures disagree are
+// reported as contradictory.
+func (s *AdminService) CompareAnalyses(ctx context.Context, articleIDs []int64) (models.AnalysisComparison, error) {
+	if len(articleIDs) != 2 {
+		return models.AnalysisComparison{}, invalidInput("ids must be exactly two analysis ids")
+	}
+	if articleIDs[0] <= 0 || articleIDs[1] <= 0 {
+		return models.AnalysisComparison{}, invalidInput("ids must be positive analysis ids")
+	}
+	if articleIDs[0] == articleIDs[1] {
+		return models.AnalysisComparison{}, invalidInput("ids must be two different analyses")
+	}
+
+	var (
+		analyses [2]models.ComparedAnalysis
+		articles [2]string
+		facts    [2][]comparedFact
+	)
+	for idx, id := range articleIDs {
+		var err error
+		if analyses[idx], articles[idx], facts[idx], err = s.loadComparedAnalysis(ctx, id); err != nil {
+			return models.AnalysisComparison{}, err
+		}
+	}
+	if !strings.EqualFold(analyses[0].Language, analyses[1].Language) {
+		return models.AnalysisComparison{}, invalidInput("analyses must be in the same output language to compare")
+	}
+
+	comparison := compareFacts(facts[0], facts[1])
+	comparison.Left, comparison.Right = analyses[0], analyses[1]
+	comparison.Article = diffArticles(articles[0], articles[1])
+	return comparison, nil
+}
+
+func (s *AdminService) loadComparedAnalysis(ctx context.Context, articleID int64) (models.ComparedAnalysis, string, []comparedFact, error) {
+	analysis := models.ComparedAnalysis{ID: articleID}
+	var (
+		headline    string
+		articleText string
+	)
+	err := s.store.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(headline_selected, ''), COALESCE(status, 'draft'), COALESCE(output_language, ''),
+			COALESCE(source_url, ''), COALESCE(source_domain, ''), COALESCE(article_text, '')
+		FROM articles WHERE id = ?`,
+		articleID,
+	).Scan(&headline, &analysis.Status, &analysis.Language, &analysis.SourceURL, &analysis.SourceDomain, &articleText)
+	if err != nil {
+		return analysis, "", nil, err
+	}
+	analysis.Title = buildAnalysisTitle(articleID, headline, analysis.SourceURL, "")
+	analysis.Status = strings.ToLower(analysis.Status)
+	if analysis.Language == "" {
+		analysis.Language = englishLanguage.Name
+	}
+
+	texts, err := listTexts(ctx, s.store, `
+		SELECT fact_text FROM facts
+		WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true
+		ORDER BY position ASC, id ASC
+	`, articleID)
+	if err != nil {
+		return analysis, "", nil, err
+	}
+	facts := make([]comparedFact, len(texts))
+	for idx, text := range texts {
+		tokens, numbers := factTokens(text)
+		facts[idx] = comparedFact{text: text, tokens: tokens, numbers: numbers}
+	}
+	analysis.FactCount = len(facts)
+	return analysis, articleText, facts, nil
+}
+
+// compareFacts pairs the closest facts first, so a fact close to two others
+// goes with the better match.
+func compareFacts(left []comparedFact, right []comparedFact) models.AnalysisComparison {
+	comparison := models.AnalysisComparison{
+		SharedFacts:        make([]models.FactPair, 0),
+		ContradictoryFacts: make([]models.FactPair, 0),
+		LeftOnlyFacts:      make([]string, 0),
+		RightOnlyFacts:     make([]string, 0),
+	}
+
+	type candidate struct {
+		left, right int
+		score       float64
+	}
+	shared := make([]candidate, 0)
+	contradictory := make([]candidate, 0)
+	for i, a := range left {
+		for j, b := range right {
+			if score := factSimilarity(a.tokens, a.numbers, b.tokens, b.numbers); score >= factMatchThreshold {
+				shared = append(shared, candidate{i, j, score})
+				continue
+			}
+			if len(a.numbers) == 0 || len(b.numbers) == 0 {
+				continue
+			}
+			// The figures kept these apart; without them, do the words match?
+			if score := factSimilarity(wordTokens(a), nil, wordTokens(b), nil); score >= factMatchThreshold {
+				contradictory = append(contradictory, candidate{i, j, score})
+			}
+		}
+	}
+
+	leftUsed := make([]bool, len(left))
+	rightUsed := make([]bool, len(right))
+	pair := func(candidates []candidate) []candidate {
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+		picked := make([]candidate, 0)
+		for _, c := range candidates {
+			if leftUsed[c.left] || rightUsed[c.right] {
+				continue
+			}
+			leftUsed[c.left], rightUsed[c.right] = true, true
+			picked = append(picked, c)
+		}
+		// Pairs are listed in the left analysis's fact order.
+		sort.SliceStable(picked, func(i, j int) bool { return picked[i].left < picked[j].left })
+		return picked
+	}
+	for _, c := range pair(shared) {
+		comparison.SharedFacts = append(comparison.SharedFacts, models.FactPair{Left: left[c.left].text, Right: right[c.right].text, Similarity: roundScore(c.score)})
+	}
+	for _, c := range pair(contradictory) {
+		comparison.ContradictoryFacts = append(comparison.ContradictoryFacts, models.FactPair{Left: left[c.left].text, Right: right[c.right].text, Similarity: roundScore(c.score)})
+	}
+
+	for idx, fact := range left {
+		if !leftUsed[idx] {
+			comparison.LeftOnlyFacts = append(comparison.LeftOnlyFacts, fact.text)
+		}
+	}
+	for idx, fact := range right {
+		if !rightUsed[idx] {
+			comparison.RightOnlyFacts = append(comparison.RightOnlyFacts, fact.text)
+		}
+	}
+	return comparison
+}
+
+// wordTokens is a fact's tokens without its figures.
+func wordTokens(fact comparedFact) map[string]struct{} {
+	words := make(map[string]struct{}, len(fact.tokens))
+	for token := range fact.tokens {
+		if _, ok := fact.numbers[token]; !ok {
+			words[token] = struct{}{}
+		}
+	}
+	return words
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
+
+// diffArticles lines up the sentences the two texts share, by their longest
+// common subsequence. Runs that differ in between are set side by side as
+// changed rows, with whatever one run has left over removed or added.
+func diffArticles(left string, right string) []models.ArticleDiffLine {
+	a := splitSentences(left)
+	b := splitSentences(right)
+	if len(a) > maxCompareSentences {
+		a = a[:maxCompareSentences]
+	}
+	if len(b) > maxCompareSentences {
+		b = b[:maxCompareSentences]
+	}
+	keysA := make([]string, len(a))
+	for idx, sentence := range a {
+		keysA[idx] = sentenceKey(sentence)
+	}
+	keysB := make([]string, len(b))
+	for idx, sentence := range b {
+		keysB[idx] = sentenceKey(sentence)
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if keysA[i] == keysB[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]models.ArticleDiffLine, 0, max(len(a), len(b)))
+	var removed, added []string
+	flush := func() {
+		paired := min(len(removed), len(added))
+		for idx := 0; idx < paired; idx++ {
+			lines = append(lines, models.ArticleDiffLine{Op: models.ArticleDiffChanged, Left: removed[idx], Right: added[idx]})
+		}
+		for _, sentence := range removed[paired:] {
+			lines = append(lines, models.ArticleDiffLine{Op: models.ArticleDiffRemoved, Left: sentence})
+		}
+		for _, sentence := range added[paired:] {
+			lines = append(lines, models.ArticleDiffLine{Op: models.ArticleDiffAdded, Right: sentence})
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && keysA[i] == keysB[j]:
+			flush()
+			lines = append(lines, models.ArticleDiffLine{Op: models.ArticleDiffSame, Left: a[i], Right: b[j]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	flush()
+	return lines
+}
+
+// sentenceKey ignores case and spacing when sentences are compared.
+func sentenceKey(sentence string) string {
+	return strings.Join(strings.Fields(strings.ToLower(sentence)), " ")
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"slices"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	maxFlagReason    = 2000
+	maxFlagExcerpt   = 1000
+	maxClearanceNote = 2000
+)
+
+type sensitivityPayload struct {
+	ArticleID int64 `json:"articleId"`
+}
+
+// EnqueueSensitivityCheck queues the automated legal read of an analysis's
+// article.
+func EnqueueSensitivityCheck(ctx context.Context, jobs *JobService, articleID int64, createdBy *int64) (models.Job, error) {
+	var exists int
+	if err := jobs.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.Job{}, err
+	}
+	return jobs.Enqueue(ctx, models.JobTypeSensitivity, sensitivityPayload{ArticleID: articleID}, createdBy)
+}
+
+// CheckSensitivity has the model read an analysis's article for legal risk
+// and replaces the automated flags still open. A risk someone already cleared
+// is not raised again while its excerpt is unchanged; flags editors raised
+// are left alone.
+func (s *FactService) CheckSensitivity(ctx context.Context, articleID int64, report func(int, int)) (models.SensitivityResult, error) {
+	var articleText, language string
+	err := s.store.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(article_text, ''), COALESCE(output_language, '') FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&articleText, &language)
+	if err != nil {
+		return models.SensitivityResult{}, err
+	}
+	if language == "" {
+		language = englishLanguage.Name
+	}
+
+	facts, err := listTexts(ctx, s.store, "SELECT COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY position ASC, id ASC", articleID)
+	if err != nil {
+		return models.SensitivityResult{}, err
+	}
+
+	result := models.SensitivityResult{ArticleID: articleID}
+	report(0, 1)
+
+	flags := make([]models.AnalysisFlag, 0)
+	if strings.TrimSpace(articleText) != "" {
+		if err := s.applyRuntimeAISettings(ctx); err != nil {
+			return result, err
+		}
+		runID := newLLMRunID()
+		ctx = withLLMRunID(ctx, runID)
+		if activePrompts, err := loadPromptSet(ctx, s.store); err != nil {
+			log.Printf("[prompts] failed to load active prompts, using built-in defaults: %v", err)
+		} else {
+			ctx = withPromptSet(ctx, activePrompts)
+		}
+
+		flags, err = s.ai.CheckSensitivity(ctx, facts, articleText, language)
+		if err := s.llmCalls.AttachArticle(ctx, runID, articleID); err != nil {
+			log.Printf("[llm-calls] failed to link run %s to article %d: %v", runID, articleID, err)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM analysis_flags WHERE article_id = ? AND source = ? AND cleared_at IS NULL", articleID, models.FlagSourceAutomated); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT kind, COALESCE(excerpt, '') FROM analysis_flags WHERE article_id = ? AND source = ? AND cleared_at IS NOT NULL", articleID, models.FlagSourceAutomated)
+		if err != nil {
+			return err
+		}
+		cleared := make(map[string]bool)
+		for rows.Next() {
+			var kind, excerpt string
+			if err := rows.Scan(&kind, &excerpt); err != nil {
+				rows.Close()
+				return err
+			}
+			cleared[flagKey(kind, excerpt)] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, flag := range flags {
+			if flag.Excerpt != "" && cleared[flagKey(flag.Kind, flag.Excerpt)] {
+				result.Skipped++
+				continue
+			}
+			_, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO analysis_flags (article_id, kind, reason, excerpt, source) VALUES (?, ?, ?, ?, ?)",
+				articleID,
+				flag.Kind,
+				truncateRunes(flag.Reason, maxFlagReason),
+				nullString(truncateRunes(flag.Excerpt, maxFlagExcerpt)),
+				models.FlagSourceAutomated,
+			)
+			if err != nil {
+				return err
+			}
+			result.Flagged++
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	report(1, 1)
+	return result, nil
+}
+
+func flagKey(kind string, excerpt string) string {
+	return kind + "\x00" + strings.ToLower(strings.Join(strings.Fields(excerpt), " "))
+}
+
+// FlagAnalysis records an editor's legal or sensitivity concern about an
+// analysis.
+func (s *AdminService) FlagAnalysis(ctx context.Context, articleID int64, kind string, reason string, excerpt string, flaggedBy *int64) (models.AnalysisFlag, error) {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if !slices.Contains(models.FlagKinds, kind) {
+		return models.AnalysisFlag{}, invalidInputf("kind must be one of %s", strings.Join(models.FlagKinds, ", "))
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return models.AnalysisFlag{}, invalidInput("reason is required")
+	}
+	if len([]rune(reason)) > maxFlagReason {
+		return models.AnalysisFlag{}, invalidInputf("reason must be at most %d characters", maxFlagReason)
+	}
+	excerpt = strings.TrimSpace(excerpt)
+	if len([]rune(excerpt)) > maxFlagExcerpt {
+		return models.AnalysisFlag{}, invalidInputf("excerpt must be at most %d characters", maxFlagExcerpt)
+	}
+
+	var exists int
+	if err := s.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.AnalysisFlag{}, err
+	}
+
+	flagID, err := s.store.Insert(
+		ctx,
+		"INSERT INTO analysis_flags (article_id, kind, reason, excerpt, source, flagged_by) VALUES (?, ?, ?, ?, ?, ?)",
+		articleID,
+		kind,
+		reason,
+		nullString(excerpt),
+		models.FlagSourceEditor,
+		flaggedBy,
+	)
+	if err != nil {
+		return models.AnalysisFlag{}, err
+	}
+	return getFlag(ctx, s.store, flagID)
+}
+
+// ClearFlag closes a flag with clearedBy's note on why the analysis may go
+// out. Only a signed-in user can clear a flag, so the record names them.
+func (s *AdminService) ClearFlag(ctx context.Context, flagID int64, clearedBy *int64, note string) (models.AnalysisFlag, error) {
+	if clearedBy == nil {
+		return models.AnalysisFlag{}, invalidInput("clearing a flag needs a signed-in user")
+	}
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return models.AnalysisFlag{}, invalidInput("note is required to clear a flag")
+	}
+	if len([]rune(note)) > maxClearanceNote {
+		return models.AnalysisFlag{}, invalidInputf("note must be at most %d characters", maxClearanceNote)
+	}
+
+	result, err := s.store.ExecContext(
+		ctx,
+		"UPDATE analysis_flags SET cleared_by = ?, cleared_at = CURRENT_TIMESTAMP, clearance_note = ? WHERE id = ? AND cleared_at IS NULL",
+		*clearedBy,
+		note,
+		flagID,
+	)
+	if err != nil {
+		return models.AnalysisFlag{}, err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		flag, getErr := getFlag(ctx, s.store, flagID)
+		if getErr != nil {
+			return models.AnalysisFlag{}, getErr
+		}
+		if flag.ClearedAt != nil {
+			return models.AnalysisFlag{}, invalidInput("the flag is already cleared")
+		}
+		return models.AnalysisFlag{}, err
+	}
+	return getFlag(ctx, s.store, flagID)
+}
+
+const flagSelect = `
+	SELECT f.id, f.kind, f.reason, COALESCE(f.excerpt, ''), f.source, f.flagged_by, COALESCE(fu.display_name, fu.email, ''),
+		COALESCE(f.created_at, CURRENT_TIMESTAMP), f.cleared_by, COALESCE(cu.display_name, cu.email, ''), f.cleared_at, COALESCE(f.clearance_note, '')
+	FROM analysis_flags f
+	LEFT JOIN users fu ON fu.id = f.flagged_by
+	LEFT JOIN users cu ON cu.id = f.cleared_by`
+
+func getFlag(ctx context.Context, q repository.Querier, flagID int64) (models.AnalysisFlag, error) {
+	return scanFlag(q.QueryRowContext(ctx, flagSelect+" WHERE f.id = ?", flagID))
+}
+
+// listFlags lists an analysis's flags, open ones first, newest first within
+// each.
+func listFlags(ctx context.Context, q repository.Querier, articleID int64) ([]models.AnalysisFlag, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		flagSelect+" WHERE f.article_id = ? ORDER BY CASE WHEN f.cleared_at IS NULL THEN 0 ELSE 1 END, f.created_at DESC, f.id DESC",
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := make([]models.AnalysisFlag, 0)
+	for rows.Next() {
+		flag, err := scanFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+func scanFlag(row rowScanner) (models.AnalysisFlag, error) {
+	var (
+		flag      models.AnalysisFlag
+		flaggedBy sql.NullInt64
+		clearedBy sql.NullInt64
+		clearedAt sql.NullTime
+	)
+	err := row.Scan(
+		&flag.ID,
+		&flag.Kind,
+		&flag.Reason,
+		&flag.Excerpt,
+		&flag.Source,
+		&flaggedBy,
+		&flag.FlaggedByName,
+		&flag.CreatedAt,
+		&clearedBy,
+		&flag.ClearedByName,
+		&clearedAt,
+		&flag.ClearanceNote,
+	)
+	if err != nil {
+		return models.AnalysisFlag{}, err
+	}
+	flag.FlaggedBy = nullInt64Pointer(flaggedBy)
+	flag.ClearedBy = nullInt64Pointer(clearedBy)
+	if clearedAt.Valid {
+		clearedAt := clearedAt.Time
+		flag.ClearedAt = &clearedAt
+	}
+	return flag, nil
+}
+
+// checkOpenFlags refuses to complete an analysis while any flag on it is
+// still open.
+func checkOpenFlags(ctx context.Context, q repository.Querier, articleID int64) error {
+	var open int64
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM analysis_flags WHERE article_id = ? AND cleared_at IS NULL", articleID).Scan(&open); err != nil {
+		return err
+	}
+	switch {
+	case open == 1:
+		return invalidInputf("analysis %d has an open legal flag; it must be cleared before the analysis is completed", articleID)
+	case open > 1:
+		return invalidInputf("analysis %d has %d open legal flags; they must be cleared before the analysis is completed", articleID, open)
+	}
+	return nil
+}
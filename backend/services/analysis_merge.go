@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const maxMergeAnalyses = 10
+
+// mergeCandidate is an analysis about to be merged: its sources with the
+// facts each reported, its open questions and its timeline.
+type mergeCandidate struct {
+	category   string
+	language   string
+	mergedInto *int64
+	sources    []resolvedSource
+	gaps       []string
+	timeline   []models.TimelineEvent
+	tags       factTagSet
+}
+
+// MergeAnalyses combines analyses of the same story into a new one. Included
+// facts are matched across the analyses as they are across the sources of a
+// multi-source submission, open questions are unioned, and the article and
+// titles are generated again from the result. The originals are kept and
+// point to the merged analysis.
+func (s *FactService) MergeAnalyses(ctx context.Context, articleIDs []int64, articleMode string, lengths models.LengthTargets, submission *models.Submission) (response models.PhaseOneResponse, err error) {
+	if s.store.DB() == nil {
+		return models.PhaseOneResponse{}, errors.New("database is not initialized")
+	}
+
+	ids, err := mergeIDs(articleIDs)
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	if articleMode, err = NormalizeArticleMode(articleMode); err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	if err := ValidateLengthTargets(lengths); err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+
+	candidates := make([]mergeCandidate, 0, len(ids))
+	for _, id := range ids {
+		candidate, err := loadMergeCandidate(ctx, s.store, id)
+		if err != nil {
+			return models.PhaseOneResponse{}, err
+		}
+		if candidate.mergedInto != nil {
+			return models.PhaseOneResponse{}, invalidInputf("analysis %d is invalid to merge: it was merged into analysis %d", id, *candidate.mergedInto)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	languages, err := loadLanguages(ctx, s.store)
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	output := englishLanguage
+	if candidates[0].language != "" {
+		if output, err = pickLanguage(languages, candidates[0].language); err != nil {
+			return models.PhaseOneResponse{}, err
+		}
+	}
+	for _, candidate := range candidates[1:] {
+		language := candidate.language
+		if language == "" {
+			language = englishLanguage.Name
+		}
+		if !strings.EqualFold(language, output.Name) {
+			return models.PhaseOneResponse{}, invalidInput("analyses must be in the same output language to merge")
+		}
+	}
+
+	merged := &corroboration{tags: make(factTagSet)}
+	gaps := make([]string, 0)
+	seenGaps := make(map[string]struct{})
+	category := ""
+	timelines := make([][]models.TimelineEvent, 0, len(candidates))
+	for _, candidate := range candidates {
+		timelines = append(timelines, candidate.timeline)
+		merged.sources = append(merged.sources, candidate.sources...)
+		merged.tags.merge(candidate.tags)
+		for _, gap := range candidate.gaps {
+			key := strings.ToLower(strings.Join(strings.Fields(gap), " "))
+			if _, ok := seenGaps[key]; ok {
+				continue
+			}
+			seenGaps[key] = struct{}{}
+			gaps = append(gaps, gap)
+		}
+		if category == "" {
+			category = candidate.category
+		}
+	}
+	merged.facts = mergeSourceFacts(merged.sources)
+	merged.collapseDuplicateSources()
+	if len(merged.facts) == 0 {
+		return models.PhaseOneResponse{}, invalidInput("at least one included fact is required to merge analyses")
+	}
+	facts := merged.factTexts()
+	timeline := mergeTimelines(timelines...)
+
+	if err := s.applyRuntimeAISettings(ctx); err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+
+	runID := newLLMRunID()
+	ctx = withLLMRunID(ctx, runID)
+	ctx = withLengthTargets(ctx, lengths)
+
+	activePrompts, err := loadPromptSet(ctx, s.store)
+	if err != nil {
+		log.Printf("[prompts] failed to load active prompts, using built-in defaults: %v", err)
+	} else {
+		ctx = withPromptSet(ctx, activePrompts)
+	}
+
+	sourceURL := merged.sourceURL()
+	defer func() {
+		if err != nil && ctx.Err() == nil {
+			announcePhaseOneFailure(sourceURL, err)
+		}
+	}()
+
+	// Facts, questions and timelines are already in the output language;
+	// only the article is written in the generation language and translated.
+	generationLanguage := generationLanguageFor(output)
+	articleText, sections, err := s.writeArticle(ctx, articleMode, facts, merged.taggedFacts(), gaps, generationLanguage)
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	if output.Name != generationLanguage {
+		glossary, err := s.glossary.GetGlossary(ctx, output.Name)
+		if err != nil {
+			return models.PhaseOneResponse{}, err
+		}
+		if articleText, sections, err = s.translateArticle(ctx, articleText, sections, output.Name, glossary); err != nil {
+			return models.PhaseOneResponse{}, err
+		}
+	}
+
+	headlines, straplines := s.generateTitles(ctx, facts, gaps, articleText, output.Name)
+	articleText, sections, headlines, straplines = s.applyStyleGuide(ctx, articleText, sections, headlines, straplines)
+
+	articleID, err := s.savePhaseOne(ctx, 0, sourceURL, merged.rawText(), articleText, sections, category, nil, submission, facts, merged.tags.forFacts(facts), gaps, timeline, numericCheck{}, headlines, straplines, nil, merged, nil, languageDetection{}, output.Name, activePrompts.versions())
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+
+	args := []any{articleID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	query := "UPDATE articles SET merged_into = ?, updated_at = CURRENT_TIMESTAMP WHERE id IN (" + repository.Placeholders(len(ids)) + ")"
+	if _, err := s.store.ExecContext(ctx, query, args...); err != nil {
+		log.Printf("[merge] failed to link analyses %v to merged analysis %d: %v", ids, articleID, err)
+	}
+
+	s.afterSave(ctx, runID, articleID, submission)
+
+	headline := fmt.Sprintf("Analysis #%d", articleID)
+	if len(headlines) > 0 {
+		headline = headlines[0]
+	}
+	announce(pipelineEvent{
+		event:     config.EventAnalysisFinished,
+		articleID: articleID,
+		headline:  headline,
+		detail:    fmt.Sprintf("Merged from %d analyses: %d facts, %d open questions (%s)", len(ids), len(facts), len(gaps), output.Name),
+	})
+
+	return models.PhaseOneResponse{
+		ArticleID:     articleID,
+		Language:      output.Name,
+		Facts:         facts,
+		Gaps:          gaps,
+		Article:       articleText,
+		ArticleMode:   articleMode,
+		Sections:      sections,
+		Readability:   measureReadability(articleText, output.Name),
+		Timeline:      timeline,
+		Sources:       merged.summaries(),
+		Corroboration: merged.factCorroboration(facts),
+		MergedFrom:    ids,
+	}, nil
+}
+
+func mergeIDs(articleIDs []int64) ([]int64, error) {
+	ids := make([]int64, 0, len(articleIDs))
+	seen := make(map[int64]struct{}, len(articleIDs))
+	for _, id := range articleIDs {
+		if id <= 0 {
+			return nil, invalidInputf("invalid analysis id %d", id)
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 {
+		return nil, invalidInput("at least two analysis ids are required")
+	}
+	if len(ids) > maxMergeAnalyses {
+		return nil, invalidInputf("ids must be at most %d analyses", maxMergeAnalyses)
+	}
+	return ids, nil
+}
+
+// loadMergeCandidate reads an analysis with its included facts grouped by the
+// source that reported them. Facts of a single-source analysis, and facts not
+// linked to any source, belong to its first source.
+func loadMergeCandidate(ctx context.Context, store *repository.Store, articleID int64) (mergeCandidate, error) {
+	var candidate mergeCandidate
+
+	var (
+		sourceURL  string
+		rawText    string
+		mergedInto sql.NullInt64
+	)
+	err := store.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(t.name, ''), COALESCE(a.output_language, ''), COALESCE(a.source_url, ''), COALESCE(a.raw_text, ''), a.merged_into
+		FROM articles a
+		LEFT JOIN topics t ON t.id = a.topic_id
+		WHERE a.id = ?`,
+		articleID,
+	).Scan(&candidate.category, &candidate.language, &sourceURL, &rawText, &mergedInto)
+	if err != nil {
+		return mergeCandidate{}, err
+	}
+	candidate.mergedInto = nullInt64Pointer(mergedInto)
+
+	rows, err := store.QueryContext(ctx, "SELECT id, COALESCE(source_url, ''), COALESCE(raw_text, '') FROM article_sources WHERE article_id = ? ORDER BY position ASC", articleID)
+	if err != nil {
+		return mergeCandidate{}, err
+	}
+	sourceIndex := make(map[int64]int)
+	for rows.Next() {
+		var (
+			id     int64
+			source resolvedSource
+		)
+		if err := rows.Scan(&id, &source.url, &source.text); err != nil {
+			rows.Close()
+			return mergeCandidate{}, err
+		}
+		sourceIndex[id] = len(candidate.sources)
+		candidate.sources = append(candidate.sources, source)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return mergeCandidate{}, err
+	}
+	if len(candidate.sources) == 0 {
+		candidate.sources = []resolvedSource{{url: sourceURL, text: rawText}}
+	}
+
+	rows, err = store.QueryContext(ctx, `
+		SELECT f.id, COALESCE(f.fact_text, ''), fs.article_source_id
+		FROM facts f
+		LEFT JOIN fact_sources fs ON fs.fact_id = f.id
+		WHERE f.article_id = ? AND f.deleted_at IS NULL AND COALESCE(f.is_included, true) = true
+		ORDER BY f.position ASC, f.id ASC
+	`, articleID)
+	if err != nil {
+		return mergeCandidate{}, err
+	}
+	for rows.Next() {
+		var (
+			factID   int64
+			text     string
+			sourceID sql.NullInt64
+		)
+		if err := rows.Scan(&factID, &text, &sourceID); err != nil {
+			rows.Close()
+			return mergeCandidate{}, err
+		}
+		idx := 0
+		if linked, ok := sourceIndex[sourceID.Int64]; ok && sourceID.Valid {
+			idx = linked
+		}
+		candidate.sources[idx].facts = append(candidate.sources[idx].facts, text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return mergeCandidate{}, err
+	}
+
+	if candidate.timeline, err = listTimelineEvents(ctx, store, articleID); err != nil {
+		return mergeCandidate{}, err
+	}
+	if candidate.tags, err = loadFactTagSet(ctx, store, articleID); err != nil {
+		return mergeCandidate{}, err
+	}
+
+	rows, err = store.QueryContext(ctx, "SELECT COALESCE(question, '') FROM gaps WHERE article_id = ? AND COALESCE(is_resolved, false) = false ORDER BY id ASC", articleID)
+	if err != nil {
+		return mergeCandidate{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var question string
+		if err := rows.Scan(&question); err != nil {
+			return mergeCandidate{}, err
+		}
+		if question = strings.TrimSpace(question); question != "" {
+			candidate.gaps = append(candidate.gaps, question)
+		}
+	}
+	return candidate, rows.Err()
+}
+
+// collapseDuplicateSources folds sources with the same URL into the first of
+// them, so the same report submitted twice doesn't count as corroboration.
+func (c *corroboration) collapseDuplicateSources() {
+	first := make(map[string]int, len(c.sources))
+	remap := make([]int, len(c.sources))
+	kept := make([]resolvedSource, 0, len(c.sources))
+	for idx, source := range c.sources {
+		key := strings.ToLower(strings.TrimSpace(source.url))
+		if key != "" {
+			if target, ok := first[key]; ok {
+				remap[idx] = target
+				continue
+			}
+			first[key] = len(kept)
+		}
+		remap[idx] = len(kept)
+		kept = append(kept, source)
+	}
+	if len(kept) == len(c.sources) {
+		return
+	}
+
+	for idx := range c.facts {
+		sources := make([]int, 0, len(c.facts[idx].sources))
+		for _, source := range c.facts[idx].sources {
+			if target := remap[source]; !containsInt(sources, target) {
+				sources = append(sources, target)
+			}
+		}
+		c.facts[idx].sources = sources
+	}
+	c.sources = kept
+	sort.SliceStable(c.facts, func(i, j int) bool {
+		return len(c.facts[i].sources) > len(c.facts[j].sources)
+	})
+}
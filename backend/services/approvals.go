@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const maxApprovalNote = 1000
+
+// ApproveAnalysis records approverID's sign-off on the analysis as it is at
+// version, replacing any earlier one of theirs.
+func (s *AdminService) ApproveAnalysis(ctx context.Context, articleID int64, version int64, approverID *int64, note string) error {
+	if approverID == nil {
+		return invalidInput("approving needs a signed-in user")
+	}
+	if version < 1 {
+		return invalidInput("version must be a positive number")
+	}
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > maxApprovalNote {
+		return invalidInputf("note must be at most %d characters", maxApprovalNote)
+	}
+
+	var (
+		status  string
+		current int64
+	)
+	err := s.store.QueryRowContext(ctx, "SELECT status, version FROM articles WHERE id = ?", articleID).Scan(&status, &current)
+	if err != nil {
+		return err
+	}
+	// Approving a version the reviewer hasn't seen the latest of would
+	// approve edits they never read.
+	if version != current {
+		return &VersionConflictError{Kind: "analysis", ID: articleID, Current: current}
+	}
+	if status == "completed" {
+		return invalidInput("the analysis is already completed")
+	}
+
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"analysis_approvals",
+		[]string{"article_id", "approved_by", "version", "note"},
+		[]string{"article_id", "approved_by"},
+		[]string{"version", "note"},
+		"created_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.ExecContext(ctx, query, articleID, *approverID, version, nullString(note))
+	return err
+}
+
+// WithdrawApproval takes back userID's approval of an analysis.
+func (s *AdminService) WithdrawApproval(ctx context.Context, articleID int64, userID *int64) error {
+	if userID == nil {
+		return sql.ErrNoRows
+	}
+	result, err := s.store.ExecContext(ctx, "DELETE FROM analysis_approvals WHERE article_id = ? AND approved_by = ?", articleID, *userID)
+	if err != nil {
+		return err
+	}
+	return ensureRowsAffected(result)
+}
+
+// listApprovals lists an analysis's approvals, newest first, marking those
+// for version as current.
+func listApprovals(ctx context.Context, q repository.Querier, articleID int64, version int64) ([]models.AnalysisApproval, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT ap.id, ap.approved_by, COALESCE(u.display_name, u.email, ''), ap.version, COALESCE(ap.note, ''), COALESCE(ap.created_at, CURRENT_TIMESTAMP)
+		FROM analysis_approvals ap
+		JOIN users u ON u.id = ap.approved_by
+		WHERE ap.article_id = ?
+		ORDER BY ap.created_at DESC, ap.id DESC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvals := make([]models.AnalysisApproval, 0)
+	for rows.Next() {
+		var approval models.AnalysisApproval
+		if err := rows.Scan(&approval.ID, &approval.ApprovedBy, &approval.ApproverName, &approval.Version, &approval.Note, &approval.CreatedAt); err != nil {
+			return nil, err
+		}
+		approval.Current = approval.Version == version
+		approvals = append(approvals, approval)
+	}
+	return approvals, rows.Err()
+}
+
+// approvalStatus sums up approvals for an analysis at version, given the
+// newest version anyone approved.
+func approvalStatus(version int64, approved sql.NullInt64) string {
+	switch {
+	case !approved.Valid:
+		return models.ApprovalNone
+	case approved.Int64 == version:
+		return models.ApprovalApproved
+	default:
+		return models.ApprovalStale
+	}
+}
+
+// checkCompletionApproval refuses to complete an analysis that nobody but
+// the publisher has approved at its current version. Approvals only count
+// while their reviewer is active and may still approve.
+func checkCompletionApproval(ctx context.Context, q repository.Querier, articleID int64, publisher *int64) error {
+	if !config.Current().RequireApproval {
+		return nil
+	}
+
+	query := `
+		SELECT COUNT(*)
+		FROM analysis_approvals ap
+		JOIN articles a ON a.id = ap.article_id AND a.version = ap.version
+		JOIN users u ON u.id = ap.approved_by AND COALESCE(u.is_active, true) = true
+		JOIN role_permissions rp ON rp.role_id = u.role_id AND rp.permission = ?
+		WHERE ap.article_id = ?`
+	args := []any{models.PermissionApprove, articleID}
+	if publisher != nil {
+		query += " AND ap.approved_by <> ?"
+		args = append(args, *publisher)
+	}
+
+	var approvals int64
+	if err := q.QueryRowContext(ctx, query, args...).Scan(&approvals); err != nil {
+		return err
+	}
+	if approvals == 0 {
+		return invalidInputf("analysis %d must be approved by a second reviewer before it is completed", articleID)
+	}
+	return nil
+}
+
+// carryApprovals moves approvals of version on to the version completing
+// the analysis moved it to, since that change was only its status.
+func carryApprovals(ctx context.Context, q repository.Querier, articleID int64, from int64, to int64) error {
+	_, err := q.ExecContext(ctx, "UPDATE analysis_approvals SET version = ? WHERE article_id = ? AND version = ?", to, articleID, from)
+	return err
+}
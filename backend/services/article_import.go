@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	analysisStatusImported = "imported"
+
+	defaultImportLimit = 50
+	maxImportLimit     = 200
+	wordPressPageSize  = 100
+	maxWordPressBytes  = 8 << 20
+	// The result lists at most this many failed articles; the rest are only
+	// counted.
+	maxImportFailures = 20
+)
+
+// EnqueueImport validates an import and queues it. Importing runs the full
+// analysis for every article, so it is always done in the background.
+func EnqueueImport(ctx context.Context, jobs *JobService, request models.ArticleImport, createdBy *int64) (models.Job, error) {
+	clean, err := normalizeImport(request)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if clean.Language != "" {
+		language, err := resolveLanguage(ctx, jobs.store, clean.Language)
+		if err != nil {
+			return models.Job{}, err
+		}
+		clean.Language = language.Name
+	}
+	return jobs.Enqueue(ctx, models.JobTypeImport, clean, createdBy)
+}
+
+func normalizeImport(request models.ArticleImport) (models.ArticleImport, error) {
+	clean := models.ArticleImport{
+		Source:   request.Source,
+		URL:      strings.TrimSpace(request.URL),
+		Since:    strings.TrimSpace(request.Since),
+		Limit:    request.Limit,
+		Language: strings.TrimSpace(request.Language),
+		Category: strings.TrimSpace(request.Category),
+
+		Submission: request.Submission,
+	}
+
+	if clean.Source != models.ImportSourceWordPress && clean.Source != models.ImportSourceRSS {
+		return models.ArticleImport{}, invalidInput("import source must be wordpress or rss")
+	}
+	if clean.URL == "" {
+		return models.ArticleImport{}, invalidInput("url is required")
+	}
+	parsed, err := CheckSourceURL(clean.URL)
+	if err != nil {
+		return models.ArticleImport{}, err
+	}
+	parsed.Fragment = ""
+	if clean.Source == models.ImportSourceWordPress {
+		// The REST API is found from the site's address.
+		parsed.RawQuery = ""
+		parsed.Path = strings.TrimRight(parsed.Path, "/")
+	}
+	clean.URL = parsed.String()
+
+	if clean.Since != "" {
+		if _, err := time.Parse(time.DateOnly, clean.Since); err != nil {
+			return models.ArticleImport{}, invalidInput("since must be a date like 2006-01-02")
+		}
+	}
+	switch {
+	case clean.Limit == 0:
+		clean.Limit = defaultImportLimit
+	case clean.Limit < 0 || clean.Limit > maxImportLimit:
+		return models.ArticleImport{}, invalidInputf("limit must be between 1 and %d", maxImportLimit)
+	}
+	return clean, nil
+}
+
+type ImportService struct {
+	store *repository.Store
+	facts *FactService
+}
+
+func NewImportService(database *sql.DB) *ImportService {
+	return &ImportService{
+		store: repository.New(database),
+		facts: NewFactService(database),
+	}
+}
+
+// importedArticle is one article as the site lists it. text is empty, or too
+// short to analyse, when the listing only carries an excerpt.
+type importedArticle struct {
+	url       string
+	title     string
+	text      string
+	published *time.Time
+}
+
+// Run pulls the articles an import asks for and analyses each one in turn,
+// marking the analyses imported so they stay out of the review queue and the
+// draft retention run. Articles already analysed from the same URL are
+// skipped, so an import can be run again to pick up newer ones. One article
+// failing doesn't stop the rest.
+func (s *ImportService) Run(ctx context.Context, request models.ArticleImport, report func(int, int)) (models.ImportResult, error) {
+	result := models.ImportResult{Source: request.Source, Analyses: make([]int64, 0)}
+
+	var (
+		articles []importedArticle
+		err      error
+	)
+	client := newSourceFetchClient(sourceFetchTimeout)
+	if request.Source == models.ImportSourceWordPress {
+		articles, err = listWordPressPosts(ctx, client, request)
+	} else {
+		articles, err = listFeedArticles(ctx, client, request)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Found = len(articles)
+	report(0, len(articles))
+
+	for idx, article := range articles {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		articleID, skipped, err := s.importArticle(ctx, request, article)
+		switch {
+		case err != nil:
+			log.Printf("[import] failed to import %s: %v", article.url, err)
+			result.Failed++
+			if len(result.Failures) < maxImportFailures {
+				result.Failures = append(result.Failures, models.ImportFailure{URL: article.url, Error: err.Error()})
+			}
+		case skipped:
+			result.Skipped++
+		default:
+			result.Imported++
+			result.Analyses = append(result.Analyses, articleID)
+		}
+		report(idx+1, len(articles))
+	}
+	log.Printf("[import] %s: %d imported, %d skipped and %d failed from %s", request.Source, result.Imported, result.Skipped, result.Failed, request.URL)
+	return result, nil
+}
+
+func (s *ImportService) importArticle(ctx context.Context, request models.ArticleImport, article importedArticle) (int64, bool, error) {
+	var analysed int
+	if err := s.store.QueryRowContext(ctx, "SELECT COUNT(*) FROM articles WHERE source_url = ?", article.url).Scan(&analysed); err != nil {
+		return 0, false, err
+	}
+	if analysed > 0 {
+		return 0, true, nil
+	}
+
+	input := models.PhaseOneInput{
+		URL:      article.url,
+		Language: request.Language,
+		Category: request.Category,
+
+		Submission: importSubmission(request, article),
+	}
+	// An article the listing carries in full is analysed as listed, with its
+	// URL as the source; otherwise the page is fetched.
+	if !thinText(article.text, config.Current().FetchMinWords) {
+		input.Text = article.text
+		if article.title != "" && !strings.HasPrefix(article.text, article.title) {
+			input.Text = article.title + "\n\n" + article.text
+		}
+	}
+
+	response, err := s.facts.RunPhaseOne(ctx, input)
+	if err != nil {
+		return 0, false, err
+	}
+	if _, err := s.store.ExecContext(ctx, "UPDATE articles SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", analysisStatusImported, response.ArticleID); err != nil {
+		log.Printf("[import] failed to mark analysis %d imported: %v", response.ArticleID, err)
+	}
+	return response.ArticleID, false, nil
+}
+
+// importSubmission records the import as each analysis's submission, with
+// where the article was listed and when it was first published.
+func importSubmission(request models.ArticleImport, article importedArticle) *models.Submission {
+	submission := models.Submission{Channel: models.SubmissionChannelImport}
+	if request.Submission != nil {
+		submission = *request.Submission
+	}
+	params := make(map[string]any, len(submission.Params)+3)
+	for key, value := range submission.Params {
+		params[key] = value
+	}
+	params["importSource"] = request.Source
+	params["importUrl"] = request.URL
+	if article.published != nil {
+		params["publishedAt"] = article.published.UTC().Format(time.RFC3339)
+	}
+	submission.Params = params
+	return &submission
+}
+
+type wordPressPost struct {
+	Link    string `json:"link"`
+	DateGMT string `json:"date_gmt"`
+	Title   struct {
+		Rendered string `json:"rendered"`
+	} `json:"title"`
+	Content struct {
+		Rendered  string `json:"rendered"`
+		Protected bool   `json:"protected"`
+	} `json:"content"`
+}
+
+// listWordPressPosts pages through the site's REST API, newest posts first.
+// Password-protected posts are left out.
+func listWordPressPosts(ctx context.Context, client *http.Client, request models.ArticleImport) ([]importedArticle, error) {
+	cfg := config.Current()
+	perPage := min(request.Limit, wordPressPageSize)
+	articles := make([]importedArticle, 0, request.Limit)
+	for page := 1; len(articles) < request.Limit; page++ {
+		query := url.Values{}
+		query.Set("per_page", strconv.Itoa(perPage))
+		query.Set("page", strconv.Itoa(page))
+		query.Set("orderby", "date")
+		query.Set("order", "desc")
+		query.Set("_fields", "link,date_gmt,title,content")
+		if request.Since != "" {
+			query.Set("after", request.Since+"T00:00:00")
+		}
+		target, err := url.Parse(request.URL + "/wp-json/wp/v2/posts?" + query.Encode())
+		if err != nil {
+			return nil, err
+		}
+
+		posts, err := fetchWordPressPosts(ctx, client, cfg, target, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range posts {
+			if len(articles) == request.Limit {
+				break
+			}
+			if post.Content.Protected {
+				continue
+			}
+			link, err := CheckSourceURL(post.Link)
+			if err != nil {
+				continue
+			}
+			article := importedArticle{
+				url:   link.String(),
+				title: truncateRunes(sanitizeHTMLText(post.Title.Rendered), maxClipTitleRunes),
+				text:  sanitizeHTMLText(post.Content.Rendered),
+			}
+			if published, err := time.Parse("2006-01-02T15:04:05", post.DateGMT); err == nil {
+				article.published = &published
+			}
+			articles = append(articles, article)
+		}
+		if len(posts) < perPage {
+			break
+		}
+	}
+	return articles, nil
+}
+
+func fetchWordPressPosts(ctx context.Context, client *http.Client, cfg config.Config, target *url.URL, page int) ([]wordPressPost, error) {
+	if err := sourceFetches.admit(ctx, client, cfg, target); err != nil {
+		return nil, err
+	}
+	response, err := sourceFetches.get(ctx, client, cfg, target.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	// A page past the last one is refused rather than empty.
+	case response.StatusCode == http.StatusBadRequest && page > 1:
+		return nil, nil
+	case response.StatusCode == http.StatusNotFound:
+		return nil, invalidInputf("%s has no WordPress REST API", target.Host)
+	case response.StatusCode >= http.StatusBadRequest:
+		return nil, fmt.Errorf("WordPress posts returned status %d", response.StatusCode)
+	}
+
+	var posts []wordPressPost
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxWordPressBytes)).Decode(&posts); err != nil {
+		return nil, fmt.Errorf("decode WordPress posts: %w", err)
+	}
+	return posts, nil
+}
+
+// listFeedArticles reads an RSS or Atom feed. Items take their full content
+// when the feed carries it and their summary otherwise; dated items older
+// than Since are left out.
+func listFeedArticles(ctx context.Context, client *http.Client, request models.ArticleImport) ([]importedArticle, error) {
+	target, err := url.Parse(request.URL)
+	if err != nil {
+		return nil, err
+	}
+	page, err := sourceFetches.fetchPage(ctx, client, config.Current(), target)
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseFeed(page.body)
+	if err != nil {
+		return nil, err
+	}
+
+	var since time.Time
+	if request.Since != "" {
+		since, _ = time.Parse(time.DateOnly, request.Since)
+	}
+	articles := make([]importedArticle, 0, min(len(items), request.Limit))
+	for _, item := range items {
+		if len(articles) == request.Limit {
+			break
+		}
+		if item.published != nil && item.published.Before(since) {
+			continue
+		}
+		link, err := CheckSourceURL(item.url)
+		if err != nil {
+			continue
+		}
+		article := importedArticle{url: link.String(), title: item.title, text: item.content, published: item.published}
+		if article.text == "" {
+			article.text = item.summary
+		}
+		// parseFeed titles untitled items with their link.
+		if article.title == item.url {
+			article.title = ""
+		}
+		articles = append(articles, article)
+	}
+	return articles, nil
+}
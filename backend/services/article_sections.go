@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+var defaultSectionHeadings = map[string]string{
+	models.SectionBackground: "Background",
+	models.SectionUnverified: "What's unverified",
+	models.SectionNext:       "What's next",
+}
+
+// NormalizeArticleMode defaults an empty mode to a single paragraph.
+func NormalizeArticleMode(mode string) (string, error) {
+	switch clean := strings.ToLower(strings.TrimSpace(mode)); clean {
+	case "", models.ArticleModeParagraph:
+		return models.ArticleModeParagraph, nil
+	case models.ArticleModeLongForm, "longform":
+		return models.ArticleModeLongForm, nil
+	default:
+		return "", invalidInput("articleMode must be paragraph or long-form")
+	}
+}
+
+// normalizeArticleSections keeps the known sections with a body, in render
+// order, filling in missing subheadings. The lede never has one.
+func normalizeArticleSections(sections []models.ArticleSection) []models.ArticleSection {
+	byKey := make(map[string]models.ArticleSection, len(sections))
+	for _, section := range sections {
+		key := strings.ToLower(strings.TrimSpace(section.Key))
+		body := strings.TrimSpace(section.Body)
+		if body == "" {
+			continue
+		}
+		if _, seen := byKey[key]; seen {
+			continue
+		}
+		byKey[key] = models.ArticleSection{Key: key, Heading: strings.TrimSpace(section.Heading), Body: body}
+	}
+
+	out := make([]models.ArticleSection, 0, len(models.ArticleSectionKeys))
+	for _, key := range models.ArticleSectionKeys {
+		section, ok := byKey[key]
+		if !ok {
+			continue
+		}
+		if key == models.SectionLede {
+			section.Heading = ""
+		} else if section.Heading == "" {
+			section.Heading = defaultSectionHeadings[key]
+		}
+		out = append(out, section)
+	}
+	return out
+}
+
+// renderArticleSections is the plain-text article stored alongside the
+// sections: each subheading on its own line above its body.
+func renderArticleSections(sections []models.ArticleSection) string {
+	parts := make([]string, 0, len(sections)*2)
+	for _, section := range sections {
+		if section.Heading != "" {
+			parts = append(parts, section.Heading)
+		}
+		parts = append(parts, section.Body)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// writeArticle generates the article text in mode. Long-form articles also
+// return their sections; taggedFacts carry corroboration tags when the
+// analysis has several sources. An article that misses the run's length
+// target is written once more, and the second attempt is kept. So is one
+// using a banned word set to regenerate, unless the second attempt uses as
+// many; what is left is fixed or flagged like any style violation.
+func (s *FactService) writeArticle(ctx context.Context, mode string, facts []string, taggedFacts []string, gaps []string, language string) (string, []models.ArticleSection, error) {
+	text, sections, err := s.writeArticleToLength(ctx, mode, facts, taggedFacts, gaps, language)
+	if err != nil {
+		return "", nil, err
+	}
+	rules := s.regenerateRules(ctx)
+	terms := bannedTermsIn(rules, text)
+	if len(terms) == 0 {
+		return text, sections, nil
+	}
+
+	log.Printf("[style] article used %d banned terms, retrying", len(terms))
+	retried, retriedSections, err := s.writeArticleToLength(withBannedTermFeedback(ctx, terms), mode, facts, taggedFacts, gaps, language)
+	if err != nil {
+		log.Printf("[style] article retry failed, keeping the first attempt: %v", err)
+		return text, sections, nil
+	}
+	if len(bannedTermsIn(rules, retried)) >= len(terms) {
+		return text, sections, nil
+	}
+	return retried, retriedSections, nil
+}
+
+func (s *FactService) writeArticleToLength(ctx context.Context, mode string, facts []string, taggedFacts []string, gaps []string, language string) (string, []models.ArticleSection, error) {
+	text, sections, err := s.generateArticle(ctx, mode, facts, taggedFacts, gaps, language)
+	if err != nil {
+		return "", nil, err
+	}
+	problems := lengthProblems(text, lengthTargetFor(ctx, lengthOutputArticle))
+	if len(problems) == 0 {
+		return text, sections, nil
+	}
+
+	log.Printf("[length] article %s, retrying", strings.Join(problems, "; "))
+	feedback := fmt.Sprintf("Your previous article %s; rewrite it to fit.", strings.Join(problems, " and "))
+	retried, retriedSections, err := s.generateArticle(withLengthFeedback(ctx, feedback), mode, facts, taggedFacts, gaps, language)
+	if err != nil {
+		log.Printf("[length] article retry failed, keeping the first attempt: %v", err)
+		return text, sections, nil
+	}
+	return retried, retriedSections, nil
+}
+
+func (s *FactService) generateArticle(ctx context.Context, mode string, facts []string, taggedFacts []string, gaps []string, language string) (string, []models.ArticleSection, error) {
+	if mode == models.ArticleModeLongForm {
+		input := facts
+		if len(taggedFacts) > 0 {
+			input = taggedFacts
+		}
+		sections, err := s.ai.GenerateSectionedArticle(ctx, input, gaps, language)
+		if err != nil {
+			return "", nil, err
+		}
+		return renderArticleSections(sections), sections, nil
+	}
+
+	if len(taggedFacts) > 0 {
+		text, err := s.ai.GenerateCorroboratedArticle(ctx, taggedFacts, gaps, language)
+		return text, nil, err
+	}
+	text, err := s.ai.GenerateStructuredArticle(ctx, facts, gaps, language)
+	return text, nil, err
+}
+
+// translateArticle translates a generated article, section by section when it
+// has them.
+func (s *FactService) translateArticle(ctx context.Context, text string, sections []models.ArticleSection, language string, glossary models.Glossary) (string, []models.ArticleSection, error) {
+	if len(sections) == 0 {
+		translated, err := s.translateText(ctx, text, language, glossary)
+		return translated, nil, err
+	}
+	translated, err := s.translateSections(ctx, sections, language, glossary)
+	if err != nil {
+		return "", nil, err
+	}
+	return renderArticleSections(translated), translated, nil
+}
+
+// translateSections translates the subheadings as one list and each body on
+// its own, since bodies may run to several paragraphs.
+func (s *FactService) translateSections(ctx context.Context, sections []models.ArticleSection, language string, glossary models.Glossary) ([]models.ArticleSection, error) {
+	headings := make([]string, 0, len(sections))
+	for _, section := range sections {
+		if section.Heading != "" {
+			headings = append(headings, section.Heading)
+		}
+	}
+	translatedHeadings := headings
+	if len(headings) > 0 {
+		var err error
+		if translatedHeadings, err = s.translateList(ctx, headings, language, glossary); err != nil {
+			return nil, err
+		}
+		if len(translatedHeadings) != len(headings) {
+			return nil, errors.New("translation returned a different number of section headings")
+		}
+	}
+
+	out := make([]models.ArticleSection, len(sections))
+	next := 0
+	for idx, section := range sections {
+		out[idx].Key = section.Key
+		if section.Heading != "" {
+			out[idx].Heading = strings.TrimSpace(translatedHeadings[next])
+			next++
+		}
+		body, err := s.translateText(ctx, section.Body, language, glossary)
+		if err != nil {
+			return nil, err
+		}
+		out[idx].Body = strings.TrimSpace(body)
+	}
+	return out, nil
+}
+
+func storeArticleSections(ctx context.Context, tx *repository.Tx, articleID int64, sections []models.ArticleSection) error {
+	encoded, err := json.Marshal(sections)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE articles SET article_mode = ?, article_sections = ? WHERE id = ?",
+		models.ArticleModeLongForm,
+		string(encoded),
+		articleID,
+	)
+	return err
+}
+
+// decodeArticleSections returns the stored sections while they still render
+// to articleText. Once the text is edited by hand or re-translated, the plain
+// text is the article and the sections are left out.
+func decodeArticleSections(articleID int64, raw sql.NullString, articleText string) []models.ArticleSection {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var sections []models.ArticleSection
+	if err := json.Unmarshal([]byte(raw.String), &sections); err != nil {
+		log.Printf("[analysis] article %d has unreadable article sections: %v", articleID, err)
+		return nil
+	}
+	if renderArticleSections(sections) != strings.TrimSpace(articleText) {
+		return nil
+	}
+	return sections
+}
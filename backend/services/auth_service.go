@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrInvalidAPIKey   = errors.New("invalid api key")
+)
+
+const adminRoleKey = "admin"
+
+type AuthService struct {
+	store         *repository.Store
+	requireAuth   bool
+	adminAPIKey   string
+	sessionSecret []byte
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+}
+
+func NewAuthService(database *sql.DB) *AuthService {
+	cfg := config.Current()
+	return &AuthService{
+		store:         repository.New(database),
+		requireAuth:   cfg.AuthRequired,
+		adminAPIKey:   cfg.AdminAPIKey,
+		sessionSecret: []byte(cfg.SessionSecret),
+		accessTTL:     cfg.SessionAccessTTL.Duration,
+		refreshTTL:    cfg.SessionRefreshTTL.Duration,
+	}
+}
+
+// Resolve maps the presented API key, or a session's access token, to a
+// principal. Without a key the caller is treated as the local operator unless
+// AUTH_REQUIRED=true, which keeps single-user deployments working before any
+// accounts exist.
+func (s *AuthService) Resolve(ctx context.Context, apiKey string) (models.Principal, error) {
+	cleanKey := strings.TrimSpace(apiKey)
+	if cleanKey == "" {
+		if s.requireAuth {
+			return models.Principal{}, ErrUnauthenticated
+		}
+		return models.Principal{
+			Email:       "local",
+			DisplayName: "Local operator",
+			Role:        adminRoleKey,
+			Permissions: append([]string(nil), models.AllPermissions...),
+		}, nil
+	}
+
+	if s.adminAPIKey != "" && subtle.ConstantTimeCompare([]byte(cleanKey), []byte(s.adminAPIKey)) == 1 {
+		return models.Principal{
+			Email:         "bootstrap-admin",
+			DisplayName:   "Bootstrap admin",
+			Role:          adminRoleKey,
+			Permissions:   append([]string(nil), models.AllPermissions...),
+			Authenticated: true,
+		}, nil
+	}
+
+	if len(s.sessionSecret) > 0 && looksLikeAccessToken(cleanKey) {
+		return s.resolveAccessToken(ctx, cleanKey)
+	}
+	return s.resolveAPIKey(ctx, cleanKey)
+}
+
+func (s *AuthService) resolveAPIKey(ctx context.Context, apiKey string) (models.Principal, error) {
+	query := `
+		SELECT u.id, u.email, COALESCE(u.display_name, ''), r.id, r.role_key
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.api_key_hash = ? AND COALESCE(u.is_active, true) = true
+		LIMIT 1;
+	`
+
+	var (
+		principal models.Principal
+		roleID    int64
+	)
+	err := s.store.QueryRowContext(ctx, query, hashAPIKey(apiKey)).Scan(
+		&principal.UserID,
+		&principal.Email,
+		&principal.DisplayName,
+		&roleID,
+		&principal.Role,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Principal{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+
+	permissions, err := s.listRolePermissions(ctx, roleID)
+	if err != nil {
+		return models.Principal{}, err
+	}
+	principal.Permissions = permissions
+	principal.Authenticated = true
+
+	return principal, nil
+}
+
+func (s *AuthService) ListUsers(ctx context.Context) ([]models.User, error) {
+	query := `
+		SELECT u.id, u.email, COALESCE(u.display_name, ''), COALESCE(r.role_key, ''), COALESCE(u.is_active, true), COALESCE(u.created_at, CURRENT_TIMESTAMP)
+		FROM users u
+		LEFT JOIN roles r ON r.id = u.role_id
+		ORDER BY u.id ASC;
+	`
+
+	rows, err := s.store.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.DisplayName, &user.Role, &user.Active, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (s *AuthService) GetUser(ctx context.Context, userID int64) (models.User, error) {
+	query := `
+		SELECT u.id, u.email, COALESCE(u.display_name, ''), COALESCE(r.role_key, ''), COALESCE(u.is_active, true), COALESCE(u.created_at, CURRENT_TIMESTAMP)
+		FROM users u
+		LEFT JOIN roles r ON r.id = u.role_id
+		WHERE u.id = ?
+		LIMIT 1;
+	`
+
+	var user models.User
+	if err := s.store.QueryRowContext(ctx, query, userID).Scan(
+		&user.ID,
+		&user.Email,
+		&user.DisplayName,
+		&user.Role,
+		&user.Active,
+		&user.CreatedAt,
+	); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (s *AuthService) CreateUser(ctx context.Context, email string, displayName string, role string) (models.User, string, error) {
+	cleanEmail := strings.ToLower(strings.TrimSpace(email))
+	if cleanEmail == "" || !strings.Contains(cleanEmail, "@") {
+		return models.User{}, "", invalidInput("valid email is required")
+	}
+
+	roleID, err := s.roleIDByKey(ctx, role)
+	if err != nil {
+		return models.User{}, "", err
+	}
+
+	apiKey, err := generateAPIKey()
+	if err != nil {
+		return models.User{}, "", err
+	}
+
+	cleanName := strings.TrimSpace(displayName)
+	query := `INSERT INTO users (email, display_name, role_id, api_key_hash, is_active) VALUES (?, ?, ?, ?, ?)`
+	userID, err := s.store.Insert(ctx, query, cleanEmail, cleanName, roleID, hashAPIKey(apiKey), true)
+	if err != nil {
+		return models.User{}, "", err
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return models.User{}, "", err
+	}
+	return user, apiKey, nil
+}
+
+func (s *AuthService) UpdateUser(ctx context.Context, userID int64, displayName *string, role *string, active *bool) error {
+	setClauses := make([]string, 0, 4)
+	args := make([]any, 0, 4)
+
+	if displayName != nil {
+		setClauses = append(setClauses, "display_name = ?")
+		args = append(args, strings.TrimSpace(*displayName))
+	}
+	if role != nil {
+		roleID, err := s.roleIDByKey(ctx, *role)
+		if err != nil {
+			return err
+		}
+		setClauses = append(setClauses, "role_id = ?")
+		args = append(args, roleID)
+	}
+	if active != nil {
+		setClauses = append(setClauses, "is_active = ?")
+		args = append(args, *active)
+	}
+
+	if len(setClauses) == 0 {
+		return invalidInput("no user fields provided")
+	}
+
+	setClauses = append(setClauses, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, userID)
+	query := "UPDATE users SET " + strings.Join(setClauses, ", ") + " WHERE id = ?"
+
+	result, err := s.store.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return ensureRowsAffected(result)
+}
+
+func (s *AuthService) RotateAPIKey(ctx context.Context, userID int64) (string, error) {
+	apiKey, err := generateAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	query := "UPDATE users SET api_key_hash = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?"
+	result, err := s.store.ExecContext(ctx, query, hashAPIKey(apiKey), userID)
+	if err != nil {
+		return "", err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return "", err
+	}
+	// A new key is usually issued because the old one leaked, so the
+	// sessions signed in with it go too.
+	if err := s.revokeUserSessions(ctx, userID); err != nil {
+		return "", err
+	}
+	return apiKey, nil
+}
+
+func (s *AuthService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	query := `
+		SELECT r.id, r.role_key, r.display_name, rp.permission
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		ORDER BY r.id ASC, rp.permission ASC;
+	`
+
+	rows, err := s.store.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]models.Role, 0)
+	indexByRoleID := make(map[int64]int)
+	for rows.Next() {
+		var (
+			roleID     int64
+			roleKey    string
+			roleName   string
+			permission sql.NullString
+		)
+		if err := rows.Scan(&roleID, &roleKey, &roleName, &permission); err != nil {
+			return nil, err
+		}
+
+		roleIndex, exists := indexByRoleID[roleID]
+		if !exists {
+			roles = append(roles, models.Role{
+				ID:          roleID,
+				Key:         roleKey,
+				Name:        roleName,
+				Permissions: make([]string, 0),
+			})
+			roleIndex = len(roles) - 1
+			indexByRoleID[roleID] = roleIndex
+		}
+
+		if permission.Valid {
+			roles[roleIndex].Permissions = append(roles[roleIndex].Permissions, permission.String)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (s *AuthService) SetRolePermissions(ctx context.Context, roleKey string, permissions []string) error {
+	roleID, err := s.roleIDByKey(ctx, roleKey)
+	if err != nil {
+		return err
+	}
+
+	cleanPermissions := make([]string, 0, len(permissions))
+	for _, permission := range dedupeStrings(permissions) {
+		clean := strings.ToLower(permission)
+		if !isKnownPermission(clean) {
+			return invalidInputf("invalid permission %q", permission)
+		}
+		cleanPermissions = append(cleanPermissions, clean)
+	}
+
+	if strings.EqualFold(strings.TrimSpace(roleKey), adminRoleKey) && !containsString(cleanPermissions, models.PermissionManageUsers) {
+		return invalidInput("admin role must keep manage_users")
+	}
+
+	return s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		deleteQuery := "DELETE FROM role_permissions WHERE role_id = ?"
+		if _, err := tx.ExecContext(ctx, deleteQuery, roleID); err != nil {
+			return err
+		}
+
+		insertQuery := "INSERT INTO role_permissions (role_id, permission) VALUES (?, ?)"
+		for _, permission := range cleanPermissions {
+			if _, err := tx.ExecContext(ctx, insertQuery, roleID, permission); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *AuthService) listRolePermissions(ctx context.Context, roleID int64) ([]string, error) {
+	query := "SELECT permission FROM role_permissions WHERE role_id = ? ORDER BY permission ASC"
+	rows, err := s.store.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := make([]string, 0)
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (s *AuthService) roleIDByKey(ctx context.Context, roleKey string) (int64, error) {
+	cleanRole := strings.ToLower(strings.TrimSpace(roleKey))
+	if cleanRole == "" {
+		return 0, invalidInput("role is required")
+	}
+
+	var roleID int64
+	query := "SELECT id FROM roles WHERE role_key = ? LIMIT 1"
+	err := s.store.QueryRowContext(ctx, query, cleanRole).Scan(&roleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, invalidInputf("invalid role %q", roleKey)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return roleID, nil
+}
+
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return "nh_" + hex.EncodeToString(buf), nil
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(apiKey)))
+	return hex.EncodeToString(sum[:])
+}
+
+func isKnownPermission(permission string) bool {
+	return containsString(models.AllPermissions, permission)
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
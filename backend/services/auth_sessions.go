@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+)
+
+const (
+	accessTokenIssuer = "nanoheads"
+	maxDeviceName     = 100
+	maxUserAgent      = 500
+)
+
+// Session failures answer 401 like a bad API key; the messages tell a client
+// whether refreshing can help.
+var (
+	ErrAccessTokenExpired = WithCode(models.ErrorCodeUnauthenticated, errors.New("access token expired; refresh it"))
+	ErrInvalidAccessToken = WithCode(models.ErrorCodeUnauthenticated, errors.New("invalid access token"))
+	ErrSessionEnded       = WithCode(models.ErrorCodeUnauthenticated, errors.New("session has ended; sign in again"))
+)
+
+var accessTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type accessClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	SessionID int64  `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Login trades a user's API key for a session: a short-lived access token to
+// send as the bearer token, and a refresh token for the next one. The
+// bootstrap admin key has no user to hold a session and can't sign in.
+func (s *AuthService) Login(ctx context.Context, apiKey string, device string, userAgent string, clientIP string) (models.SessionTokens, error) {
+	if len(s.sessionSecret) == 0 {
+		return models.SessionTokens{}, notConfigured("sessions need SESSION_SECRET")
+	}
+	cleanKey := strings.TrimSpace(apiKey)
+	if cleanKey == "" {
+		return models.SessionTokens{}, invalidInput("apiKey is required")
+	}
+	if s.adminAPIKey != "" && subtle.ConstantTimeCompare([]byte(cleanKey), []byte(s.adminAPIKey)) == 1 {
+		return models.SessionTokens{}, invalidInput("the bootstrap admin key can't start a session; sign in with a user's API key")
+	}
+
+	principal, err := s.resolveAPIKey(ctx, cleanKey)
+	if errors.Is(err, ErrInvalidAPIKey) {
+		return models.SessionTokens{}, WithCode(models.ErrorCodeUnauthenticated, err)
+	}
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+
+	return s.startSession(ctx, principal.UserID, device, userAgent, clientIP)
+}
+
+func (s *AuthService) startSession(ctx context.Context, userID int64, device string, userAgent string, clientIP string) (models.SessionTokens, error) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	expiresAt := time.Now().UTC().Add(s.refreshTTL).Truncate(time.Second)
+	sessionID, err := s.store.Insert(
+		ctx,
+		"INSERT INTO auth_sessions (user_id, refresh_token_hash, device, user_agent, client_ip, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID,
+		hashAPIKey(refreshToken),
+		nullString(truncateRunes(strings.TrimSpace(device), maxDeviceName)),
+		nullString(truncateRunes(strings.TrimSpace(userAgent), maxUserAgent)),
+		nullString(clientIP),
+		expiresAt,
+	)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	return s.sessionTokens(ctx, userID, sessionID, refreshToken)
+}
+
+// Refresh swaps a refresh token for a new one and a fresh access token.
+// Each refresh token works once: one presented again after it was replaced
+// has been copied, so the session it belonged to is revoked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string, userAgent string, clientIP string) (models.SessionTokens, error) {
+	if len(s.sessionSecret) == 0 {
+		return models.SessionTokens{}, notConfigured("sessions need SESSION_SECRET")
+	}
+	if strings.TrimSpace(refreshToken) == "" {
+		return models.SessionTokens{}, invalidInput("refreshToken is required")
+	}
+	tokenHash := hashAPIKey(refreshToken)
+
+	var (
+		sessionID int64
+		userID    int64
+		expiresAt time.Time
+		revokedAt sql.NullTime
+		active    bool
+	)
+	err := s.store.QueryRowContext(
+		ctx,
+		`SELECT s.id, s.user_id, s.expires_at, s.revoked_at, COALESCE(u.is_active, true)
+		FROM auth_sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.refresh_token_hash = ?`,
+		tokenHash,
+	).Scan(&sessionID, &userID, &expiresAt, &revokedAt, &active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SessionTokens{}, s.refreshTokenReused(ctx, tokenHash)
+	}
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	if revokedAt.Valid || !active || !time.Now().Before(expiresAt) {
+		return models.SessionTokens{}, ErrSessionEnded
+	}
+
+	next, err := generateRefreshToken()
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	// Matching on the old hash makes the swap win or lose as a whole when the
+	// same token is refreshed twice at once.
+	result, err := s.store.ExecContext(
+		ctx,
+		`UPDATE auth_sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = ?, user_agent = ?, client_ip = ?, last_used_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL`,
+		hashAPIKey(next),
+		nullString(truncateRunes(strings.TrimSpace(userAgent), maxUserAgent)),
+		nullString(clientIP),
+		sessionID,
+		tokenHash,
+	)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SessionTokens{}, ErrSessionEnded
+		}
+		return models.SessionTokens{}, err
+	}
+	return s.sessionTokens(ctx, userID, sessionID, next)
+}
+
+// refreshTokenReused revokes the session whose last refresh token this was,
+// if any. Either way the caller gets ErrSessionEnded.
+func (s *AuthService) refreshTokenReused(ctx context.Context, tokenHash string) error {
+	var sessionID, userID int64
+	err := s.store.QueryRowContext(ctx, "SELECT id, user_id FROM auth_sessions WHERE previous_token_hash = ? AND revoked_at IS NULL", tokenHash).Scan(&sessionID, &userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionEnded
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("[auth] refresh token of session %d (user %d) was used twice; revoking the session", sessionID, userID)
+	if err := s.RevokeSession(ctx, userID, sessionID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return ErrSessionEnded
+}
+
+// Logout ends the session holding refreshToken. An unknown or already ended
+// session is not an error.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	if strings.TrimSpace(refreshToken) == "" {
+		return invalidInput("refreshToken is required")
+	}
+	_, err := s.store.ExecContext(
+		ctx,
+		"UPDATE auth_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE refresh_token_hash = ? AND revoked_at IS NULL",
+		hashAPIKey(refreshToken),
+	)
+	return err
+}
+
+// ListSessions lists a user's live sessions, newest use first, marking the
+// one currentSessionID names.
+func (s *AuthService) ListSessions(ctx context.Context, userID int64, currentSessionID int64) ([]models.AuthSession, error) {
+	rows, err := s.store.QueryContext(ctx, sessionSelect+" WHERE user_id = ? AND revoked_at IS NULL ORDER BY last_used_at DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]models.AuthSession, 0)
+	now := time.Now()
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		if !now.Before(session.ExpiresAt) {
+			continue
+		}
+		session.Current = session.ID == currentSessionID
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends one of userID's sessions. Its access token stops working
+// at once.
+func (s *AuthService) RevokeSession(ctx context.Context, userID int64, sessionID int64) error {
+	result, err := s.store.ExecContext(ctx, "UPDATE auth_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID)
+	if err != nil {
+		return err
+	}
+	return ensureRowsAffected(result)
+}
+
+// RevokeUserSessions signs a user out everywhere.
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID int64) error {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.revokeUserSessions(ctx, userID)
+}
+
+func (s *AuthService) revokeUserSessions(ctx context.Context, userID int64) error {
+	_, err := s.store.ExecContext(ctx, "UPDATE auth_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userID)
+	return err
+}
+
+// resolveAccessToken checks an access token's signature and expiry, then
+// that its session is still live and its user still active.
+func (s *AuthService) resolveAccessToken(ctx context.Context, token string) (models.Principal, error) {
+	claims, err := s.parseAccessToken(token, time.Now())
+	if err != nil {
+		return models.Principal{}, err
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || claims.SessionID <= 0 {
+		return models.Principal{}, ErrInvalidAccessToken
+	}
+
+	var (
+		principal models.Principal
+		roleID    int64
+		expiresAt time.Time
+	)
+	err = s.store.QueryRowContext(
+		ctx,
+		`SELECT u.id, u.email, COALESCE(u.display_name, ''), r.id, r.role_key, s.expires_at
+		FROM auth_sessions s
+		JOIN users u ON u.id = s.user_id
+		JOIN roles r ON r.id = u.role_id
+		WHERE s.id = ? AND s.user_id = ? AND s.revoked_at IS NULL AND COALESCE(u.is_active, true) = true`,
+		claims.SessionID,
+		userID,
+	).Scan(&principal.UserID, &principal.Email, &principal.DisplayName, &roleID, &principal.Role, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Principal{}, ErrSessionEnded
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+	if !time.Now().Before(expiresAt) {
+		return models.Principal{}, ErrSessionEnded
+	}
+
+	permissions, err := s.listRolePermissions(ctx, roleID)
+	if err != nil {
+		return models.Principal{}, err
+	}
+	principal.Permissions = permissions
+	principal.Authenticated = true
+	principal.SessionID = claims.SessionID
+	return principal, nil
+}
+
+func (s *AuthService) sessionTokens(ctx context.Context, userID int64, sessionID int64, refreshToken string) (models.SessionTokens, error) {
+	session, err := scanSession(s.store.QueryRowContext(ctx, sessionSelect+" WHERE id = ?", sessionID))
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	session.Current = true
+
+	now := time.Now()
+	accessToken, err := s.signAccessToken(accessClaims{
+		Issuer:    accessTokenIssuer,
+		Subject:   strconv.FormatInt(userID, 10),
+		SessionID: sessionID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.accessTTL).Unix(),
+	})
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	return models.SessionTokens{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(s.accessTTL / time.Second),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+		Session:          session,
+	}, nil
+}
+
+// signAccessToken makes an HS256 JWT of claims.
+func (s *AuthService) signAccessToken(claims accessClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := accessTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(s.accessTokenMAC(signed)), nil
+}
+
+// parseAccessToken only accepts tokens in the exact form signAccessToken
+// makes, so the header's algorithm is never taken from the token.
+func (s *AuthService) parseAccessToken(token string, now time.Time) (accessClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != accessTokenHeader {
+		return accessClaims{}, ErrInvalidAccessToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.accessTokenMAC(parts[0]+"."+parts[1])) {
+		return accessClaims{}, ErrInvalidAccessToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return accessClaims{}, ErrInvalidAccessToken
+	}
+	var claims accessClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Issuer != accessTokenIssuer {
+		return accessClaims{}, ErrInvalidAccessToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return accessClaims{}, ErrAccessTokenExpired
+	}
+	return claims, nil
+}
+
+func (s *AuthService) accessTokenMAC(signed string) []byte {
+	mac := hmac.New(sha256.New, s.sessionSecret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// looksLikeAccessToken tells a JWT from an API key, which never has dots.
+func looksLikeAccessToken(key string) bool {
+	return strings.Count(key, ".") == 2 && strings.HasPrefix(key, "eyJ")
+}
+
+const sessionSelect = `
+	SELECT id, user_id, COALESCE(device, ''), COALESCE(user_agent, ''), COALESCE(client_ip, ''), expires_at, revoked_at, last_used_at, COALESCE(created_at, CURRENT_TIMESTAMP)
+	FROM auth_sessions`
+
+func scanSession(row rowScanner) (models.AuthSession, error) {
+	var (
+		session   models.AuthSession
+		revokedAt sql.NullTime
+		lastUsed  sql.NullTime
+	)
+	if err := row.Scan(&session.ID, &session.UserID, &session.Device, &session.UserAgent, &session.ClientIP, &session.ExpiresAt, &revokedAt, &lastUsed, &session.CreatedAt); err != nil {
+		return models.AuthSession{}, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	if lastUsed.Valid {
+		session.LastUsedAt = &lastUsed.Time
+	}
+	return session, nil
+}
+
+func generateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate refresh token: %w", err)
+	}
+	return "nhr_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/repository"
+)
+
+const (
+	categorySourceLLM      = "llm"
+	categorySourceKeywords = "keywords"
+
+	// A topic needs this many keyword hits before the classifier suggests it.
+	minCategoryKeywordHits = 2
+)
+
+type categorySuggestion struct {
+	category string
+	source   string
+}
+
+// categoryKeywords covers the seeded topics. Topics added later are matched on
+// their own name only.
+var categoryKeywords = map[string][]string{
+	"finance": {
+		"bank", "banks", "budget", "economy", "economic", "gdp", "inflation", "interest rate", "investor",
+		"investors", "loan", "market", "markets", "rbi", "revenue", "rupee", "sensex", "nifty", "shares",
+		"stock", "stocks", "tax", "taxes", "profit", "earnings", "fiscal",
+	},
+	"politics": {
+		"assembly", "cabinet", "campaign", "chief minister", "congress", "constituency", "election",
+		"elections", "government", "lok sabha", "minister", "mla", "mp", "opposition", "parliament",
+		"party", "policy", "president", "prime minister", "rajya sabha", "vote", "voters", "bjp",
+	},
+	"technology": {
+		"ai", "app", "artificial intelligence", "chip", "cloud", "cyber", "data breach", "digital",
+		"internet", "iphone", "launch", "semiconductor", "smartphone", "software", "startup", "tech",
+		"technology", "5g", "google", "apple", "microsoft",
+	},
+	"science": {
+		"astronomer", "climate", "discovery", "isro", "nasa", "orbit", "research", "researchers",
+		"satellite", "scientist", "scientists", "species", "study", "vaccine", "space", "mission",
+	},
+	"sports": {
+		"bcci", "champion", "championship", "coach", "cricket", "cup", "football", "goal", "ipl",
+		"match", "medal", "olympic", "olympics", "player", "players", "score", "tournament", "wicket",
+		"team", "league",
+	},
+}
+
+var categoryWordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// suggestCategory proposes one of the existing topics for an analysis that was
+// submitted without one. It never fails the analysis; no suggestion is fine.
+func (s *FactService) suggestCategory(ctx context.Context, rawText string, facts []string) *categorySuggestion {
+	mode := config.Current().CategorySuggestions
+	if mode == "off" {
+		return nil
+	}
+
+	topics, err := listTopicNames(ctx, s.store)
+	if err != nil {
+		log.Printf("[category] failed to list topics: %v", err)
+		return nil
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+
+	if mode != categorySourceKeywords {
+		answer, err := s.ai.SuggestCategory(ctx, facts, topics)
+		if err == nil {
+			if topic := matchTopic(answer, topics); topic != "" {
+				return &categorySuggestion{category: topic, source: categorySourceLLM}
+			}
+			log.Printf("[category] model suggested unknown topic %q, using keywords", answer)
+		} else {
+			log.Printf("[category] suggestion failed, using keywords: %v", err)
+		}
+	}
+
+	if topic := keywordCategory(rawText+"\n"+strings.Join(facts, "\n"), topics); topic != "" {
+		return &categorySuggestion{category: topic, source: categorySourceKeywords}
+	}
+	return nil
+}
+
+func matchTopic(answer string, topics []string) string {
+	clean := strings.Trim(strings.TrimSpace(answer), `"'.`)
+	for _, topic := range topics {
+		if strings.EqualFold(topic, clean) {
+			return topic
+		}
+	}
+	return ""
+}
+
+// keywordCategory scores each topic by how often its keywords occur in text and
+// returns the best one, or "" when nothing scores well enough.
+func keywordCategory(text string, topics []string) string {
+	words := categoryWordPattern.FindAllString(strings.ToLower(text), -1)
+	if len(words) == 0 {
+		return ""
+	}
+	padded := " " + strings.Join(words, " ") + " "
+
+	best, bestHits := "", 0
+	for _, topic := range topics {
+		keywords := categoryKeywords[strings.ToLower(topic)]
+		if len(keywords) == 0 {
+			keywords = []string{strings.ToLower(topic)}
+		}
+
+		hits := 0
+		for _, keyword := range keywords {
+			hits += strings.Count(padded, " "+keyword+" ")
+		}
+		if hits > bestHits {
+			best, bestHits = topic, hits
+		}
+	}
+
+	if bestHits < minCategoryKeywordHits {
+		return ""
+	}
+	return best
+}
+
+func listTopicNames(ctx context.Context, store *repository.Store) ([]string, error) {
+	rows, err := store.QueryContext(ctx, `SELECT name FROM topics ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if clean := strings.TrimSpace(name); clean != "" {
+			names = append(names, clean)
+		}
+	}
+	return names, rows.Err()
+}
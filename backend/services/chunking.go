@@ -0,0 +1,162 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Chunks are never split below this, so a provider that rejects even short
+// requests fails instead of recursing forever.
+const minChunkTokens = 200
+
+// estimateTokens approximates what a BPE tokenizer makes of text: a short
+// word is one token and longer ones take one per four characters,
+// punctuation is a token of its own, and scripts written without spaces take
+// a token per character. It errs high, which is the safe side for a budget.
+func estimateTokens(text string) int {
+	tokens := 0
+	wordRunes := 0
+	flush := func() {
+		if wordRunes > 0 {
+			tokens += (wordRunes + 3) / 4
+			wordRunes = 0
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai):
+			flush()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			wordRunes++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// chunkText splits text into pieces of about maxTokens estimated tokens or
+// fewer. It breaks between paragraphs where it can, between sentences within
+// a paragraph too long for one piece, and between words only for a sentence
+// that is.
+func chunkText(text string, maxTokens int) []string {
+	maxTokens = max(maxTokens, minChunkTokens)
+	if estimateTokens(text) <= maxTokens {
+		if clean := strings.TrimSpace(text); clean != "" {
+			return []string{clean}
+		}
+		return nil
+	}
+
+	chunks := make([]string, 0)
+	var (
+		current       strings.Builder
+		currentTokens int
+	)
+	add := func(piece string, separator string) {
+		tokens := estimateTokens(piece)
+		if currentTokens > 0 && currentTokens+tokens > maxTokens {
+			chunks = append(chunks, strings.TrimSpace(current.String()))
+			current.Reset()
+			currentTokens = 0
+		}
+		if currentTokens > 0 {
+			current.WriteString(separator)
+		}
+		current.WriteString(piece)
+		currentTokens += tokens
+	}
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if estimateTokens(paragraph) <= maxTokens {
+			add(paragraph, "\n\n")
+			continue
+		}
+		sentences := splitSentences(paragraph)
+		if len(sentences) == 0 {
+			// A long run without closing punctuation reads as a subheading.
+			sentences = []string{paragraph}
+		}
+		for _, sentence := range sentences {
+			if estimateTokens(sentence) <= maxTokens {
+				add(sentence, " ")
+				continue
+			}
+			for _, words := range splitWords(sentence, maxTokens) {
+				add(words, " ")
+			}
+		}
+	}
+	if currentTokens > 0 {
+		chunks = append(chunks, strings.TrimSpace(current.String()))
+	}
+	return chunks
+}
+
+// splitWords cuts a run of text with no usable sentence breaks into pieces of
+// at most maxTokens estimated tokens. Text without spaces is cut between
+// characters.
+func splitWords(text string, maxTokens int) []string {
+	words, separator := strings.Fields(text), " "
+	if len(words) == 1 {
+		words, separator = strings.Split(text, ""), ""
+	}
+
+	pieces := make([]string, 0)
+	var current []string
+	currentTokens := 0
+	for _, word := range words {
+		tokens := estimateTokens(word)
+		if currentTokens > 0 && currentTokens+tokens > maxTokens {
+			pieces = append(pieces, strings.Join(current, separator))
+			current = nil
+			currentTokens = 0
+		}
+		current = append(current, word)
+		currentTokens += tokens
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, strings.Join(current, separator))
+	}
+	return pieces
+}
+
+// mergeChunkFacts folds the facts of consecutive chunks into one list in
+// story order, dropping a fact that restates one from an earlier chunk.
+// Facts within a chunk are already deduplicated by the model.
+func mergeChunkFacts(chunks [][]string) []string {
+	merged := make([]mergedFact, 0)
+	for chunkIdx, facts := range chunks {
+		for _, fact := range dedupeAndTrim(facts) {
+			tokens, numbers := factTokens(fact)
+			duplicate := false
+			for idx := range merged {
+				if merged[idx].sources[0] == chunkIdx {
+					continue
+				}
+				if factSimilarity(tokens, numbers, merged[idx].tokens, merged[idx].numbers) >= factMatchThreshold {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				merged = append(merged, mergedFact{text: fact, sources: []int{chunkIdx}, tokens: tokens, numbers: numbers})
+			}
+		}
+	}
+
+	facts := make([]string, len(merged))
+	for idx, fact := range merged {
+		facts[idx] = fact.text
+	}
+	return dedupeAndTrim(facts)
+}
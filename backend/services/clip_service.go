@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+const (
+	maxClipTitleRunes = 300
+	maxClipTextRunes  = 100000
+	// A chat message holding a link and fewer words than this besides is
+	// taken to be about the linked page.
+	messageLinkWords = 40
+)
+
+var messageURLPattern = regexp.MustCompile(`https?://\S+`)
+
+// EnqueueClip validates a clipped page and queues it for analysis. Sending the
+// same clip twice while the first is still pending returns the pending job.
+func EnqueueClip(ctx context.Context, jobs *JobService, clip models.Clip, createdBy *int64) (models.Job, error) {
+	clean, err := normalizeClip(clip)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if clean.Language != "" {
+		language, err := resolveLanguage(ctx, jobs.store, clean.Language)
+		if err != nil {
+			return models.Job{}, err
+		}
+		clean.Language = language.Name
+	}
+	// The clip waits in the jobs table, so it is masked before it is queued.
+	if config.Current().PIIRedaction == "mask" {
+		clean.Title, _ = maskPII(clean.Title)
+		clean.Text, _ = maskPII(clean.Text)
+	}
+	return jobs.Enqueue(ctx, models.JobTypeClip, clean, createdBy)
+}
+
+func normalizeClip(clip models.Clip) (models.Clip, error) {
+	clean := models.Clip{
+		URL:      strings.TrimSpace(clip.URL),
+		Title:    singleLine(strings.TrimSpace(clip.Title)),
+		Text:     strings.TrimSpace(clip.Text),
+		Language: strings.TrimSpace(clip.Language),
+		Category: strings.TrimSpace(clip.Category),
+
+		Submission: clip.Submission,
+	}
+
+	if clean.URL == "" && clean.Text == "" {
+		return models.Clip{}, invalidInput("url or selected text is required")
+	}
+	if clean.URL != "" {
+		parsed, err := CheckSourceURL(clean.URL)
+		if err != nil {
+			return models.Clip{}, err
+		}
+		parsed.Fragment = ""
+		clean.URL = parsed.String()
+	}
+	if len([]rune(clean.Text)) > maxClipTextRunes {
+		return models.Clip{}, invalidInputf("selected text must be at most %d characters", maxClipTextRunes)
+	}
+	clean.Title = truncateRunes(clean.Title, maxClipTitleRunes)
+
+	return clean, nil
+}
+
+// clipPhaseOneInput turns a clip into an analysis request. A selection is
+// analysed as-is with the page title as its first line; a bare URL is fetched.
+func clipPhaseOneInput(clip models.Clip) models.PhaseOneInput {
+	text := clip.Text
+	if text != "" && clip.Title != "" && !strings.HasPrefix(text, clip.Title) {
+		text = clip.Title + "\n\n" + text
+	}
+
+	return models.PhaseOneInput{
+		Text:     text,
+		URL:      clip.URL,
+		Language: clip.Language,
+		Category: clip.Category,
+
+		Submission: clip.Submission,
+	}
+}
+
+// messageInput splits a chat message into what to analyse: the linked page
+// when the message is little more than a link, and the message itself
+// otherwise.
+func messageInput(text string) (string, string) {
+	link := messageURLPattern.FindString(text)
+	if link == "" {
+		return text, ""
+	}
+	rest := strings.Replace(text, link, " ", 1)
+	if len(strings.Fields(rest)) >= messageLinkWords {
+		return text, ""
+	}
+	return "", strings.TrimRight(link, ".,;:!?)»”'\"")
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// A session that falls this far behind is dropped rather than slowing
+// everyone else in the room; its client reconnects and reloads.
+const collaborationBuffer = 32
+
+const maxPresenceTarget = 64
+
+// collaboration holds the sessions of this process. Editors connected to
+// different instances don't see each other.
+var collaboration = &collaborationHub{rooms: make(map[int64]map[*CollaborationSession]struct{})}
+
+type collaborationHub struct {
+	mu    sync.Mutex
+	rooms map[int64]map[*CollaborationSession]struct{}
+}
+
+// CollaborationSession is one connection watching an analysis.
+type CollaborationSession struct {
+	articleID int64
+	member    models.CollaborationMember
+	events    chan models.CollaborationEvent
+	closed    bool
+}
+
+type CollaborationService struct {
+	store *repository.Store
+}
+
+func NewCollaborationService(database *sql.DB) *CollaborationService {
+	return &CollaborationService{
+		store: repository.New(database),
+	}
+}
+
+// Join opens a session on an analysis for principal and tells the others in
+// the room. The caller must Leave it.
+func (s *CollaborationService) Join(ctx context.Context, articleID int64, principal models.Principal) (*CollaborationSession, error) {
+	var exists int
+	if err := s.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(principal.DisplayName)
+	if name == "" {
+		name = principal.Email
+	}
+	session := &CollaborationSession{
+		articleID: articleID,
+		member: models.CollaborationMember{
+			UserID: principal.UserID,
+			Name:   name,
+			State:  models.PresenceViewing,
+			Since:  time.Now(),
+		},
+		events: make(chan models.CollaborationEvent, collaborationBuffer),
+	}
+
+	collaboration.mu.Lock()
+	room := collaboration.rooms[articleID]
+	if room == nil {
+		room = make(map[*CollaborationSession]struct{})
+		collaboration.rooms[articleID] = room
+	}
+	room[session] = struct{}{}
+	collaboration.broadcastPresence(articleID)
+	collaboration.mu.Unlock()
+	return session, nil
+}
+
+// Events delivers the room's events. It is closed when the session leaves or
+// is dropped for falling behind.
+func (s *CollaborationSession) Events() <-chan models.CollaborationEvent {
+	return s.events
+}
+
+// SetState records whether the user is viewing or editing, and what.
+func (s *CollaborationSession) SetState(state string, target string) error {
+	state = strings.ToLower(strings.TrimSpace(state))
+	if state != models.PresenceViewing && state != models.PresenceEditing {
+		return invalidInput("state must be viewing or editing")
+	}
+	target = truncateRunes(strings.TrimSpace(target), maxPresenceTarget)
+	if state == models.PresenceViewing {
+		target = ""
+	}
+
+	collaboration.mu.Lock()
+	defer collaboration.mu.Unlock()
+	if s.closed || (s.member.State == state && s.member.Target == target) {
+		return nil
+	}
+	s.member.State = state
+	s.member.Target = target
+	s.member.Since = time.Now()
+	collaboration.broadcastPresence(s.articleID)
+	return nil
+}
+
+func (s *CollaborationSession) Leave() {
+	collaboration.mu.Lock()
+	defer collaboration.mu.Unlock()
+	if collaboration.remove(s) {
+		collaboration.broadcastPresence(s.articleID)
+	}
+}
+
+// remove takes a session out of its room; the caller holds mu.
+func (h *collaborationHub) remove(session *CollaborationSession) bool {
+	room := h.rooms[session.articleID]
+	if _, ok := room[session]; !ok {
+		return false
+	}
+	delete(room, session)
+	if len(room) == 0 {
+		delete(h.rooms, session.articleID)
+	}
+	session.closed = true
+	close(session.events)
+	return true
+}
+
+// broadcastPresence sends the room its member list, with a user's sessions
+// folded into one that is editing if any of them is. The caller holds mu.
+func (h *collaborationHub) broadcastPresence(articleID int64) {
+	members := make([]models.CollaborationMember, 0, len(h.rooms[articleID]))
+	byUser := make(map[int64]int)
+	for session := range h.rooms[articleID] {
+		member := session.member
+		idx, seen := byUser[member.UserID]
+		switch {
+		case !seen || member.UserID == 0:
+			byUser[member.UserID] = len(members)
+			members = append(members, member)
+		case member.State == models.PresenceEditing && members[idx].State != models.PresenceEditing:
+			members[idx] = member
+		case member.State == members[idx].State && member.Since.Before(members[idx].Since):
+			members[idx] = member
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Since.Before(members[j].Since) })
+
+	h.send(models.CollaborationEvent{
+		Type:       models.CollaborationPresence,
+		AnalysisID: articleID,
+		Members:    members,
+		At:         time.Now(),
+	})
+}
+
+// send delivers an event to a room; the caller holds mu.
+func (h *collaborationHub) send(event models.CollaborationEvent) {
+	dropped := make([]*CollaborationSession, 0)
+	for session := range h.rooms[event.AnalysisID] {
+		select {
+		case session.events <- event:
+		default:
+			dropped = append(dropped, session)
+		}
+	}
+	for _, session := range dropped {
+		log.Printf("[collaboration] dropping a slow session on analysis %d", event.AnalysisID)
+		h.remove(session)
+	}
+	if len(dropped) > 0 && len(h.rooms[event.AnalysisID]) > 0 {
+		h.broadcastPresence(event.AnalysisID)
+	}
+}
+
+func (h *collaborationHub) anyWatched() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.rooms) > 0
+}
+
+// announceEdit tells the room of an analysis about a change. A zero
+// articleID is looked up from table, which must have an article_id column;
+// nothing is read while nobody is connected.
+func announceEdit(ctx context.Context, q repository.Querier, table string, event models.CollaborationEvent) {
+	if !collaboration.anyWatched() {
+		return
+	}
+	if event.AnalysisID == 0 {
+		if err := q.QueryRowContext(ctx, "SELECT article_id FROM "+table+" WHERE id = ?", event.EntityID).Scan(&event.AnalysisID); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("[collaboration] failed to find the analysis of %s %d: %v", table, event.EntityID, err)
+			}
+			return
+		}
+	}
+	event.At = time.Now()
+
+	collaboration.mu.Lock()
+	defer collaboration.mu.Unlock()
+	collaboration.send(event)
+}
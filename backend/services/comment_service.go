@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const maxCommentRunes = 5000
+
+type CommentService struct {
+	store *repository.Store
+}
+
+func NewCommentService(database *sql.DB) *CommentService {
+	return &CommentService{
+		store: repository.New(database),
+	}
+}
+
+// List returns an analysis's threads, oldest first, with their replies.
+// status narrows the threads to "open" or "resolved"; empty returns both.
+func (s *CommentService) List(ctx context.Context, articleID int64, status string) ([]models.Comment, error) {
+	if err := s.ensureArticle(ctx, articleID); err != nil {
+		return nil, err
+	}
+
+	var resolvedFilter string
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "", "all":
+	case "open":
+		resolvedFilter = " AND root.resolved_at IS NULL"
+	case "resolved":
+		resolvedFilter = " AND root.resolved_at IS NOT NULL"
+	default:
+		return nil, invalidInput("status must be open, resolved, or all")
+	}
+
+	query := commentSelect + `
+		JOIN analysis_comments root ON root.id = COALESCE(c.parent_id, c.id)
+		WHERE c.article_id = ?` + resolvedFilter + `
+		ORDER BY c.created_at ASC, c.id ASC
+	`
+	rows, err := s.store.QueryContext(ctx, query, articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := make([]models.Comment, 0)
+	positions := make(map[int64]int)
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		if comment.ParentID == nil {
+			positions[comment.ID] = len(threads)
+			threads = append(threads, comment)
+			continue
+		}
+		if idx, ok := positions[*comment.ParentID]; ok {
+			threads[idx].Replies = append(threads[idx].Replies, comment)
+		}
+	}
+	return threads, rows.Err()
+}
+
+// Create posts a comment. Without parentID it starts a thread, optionally
+// anchored to one of the analysis's facts or gaps; a reply to a reply joins
+// the same thread.
+func (s *CommentService) Create(ctx context.Context, articleID int64, parentID *int64, factID *int64, gapID *int64, body string, authorID *int64) (models.Comment, error) {
+	cleanBody := strings.TrimSpace(body)
+	if cleanBody == "" {
+		return models.Comment{}, invalidInput("comment body is required")
+	}
+	if utf8.RuneCountInString(cleanBody) > maxCommentRunes {
+		return models.Comment{}, invalidInputf("comment body must be at most %d characters", maxCommentRunes)
+	}
+	if err := s.ensureArticle(ctx, articleID); err != nil {
+		return models.Comment{}, err
+	}
+
+	if parentID != nil {
+		if factID != nil || gapID != nil {
+			return models.Comment{}, invalidInput("factId and gapId are invalid on a reply; replies share their thread's anchor")
+		}
+		rootID, err := s.threadRoot(ctx, articleID, *parentID)
+		if err != nil {
+			return models.Comment{}, err
+		}
+		parentID = &rootID
+	}
+	if factID != nil && gapID != nil {
+		return models.Comment{}, invalidInput("a comment must be anchored to a fact or a gap, not both")
+	}
+	if factID != nil {
+		if err := s.ensureBelongs(ctx, "facts", "factId", articleID, *factID); err != nil {
+			return models.Comment{}, err
+		}
+	}
+	if gapID != nil {
+		if err := s.ensureBelongs(ctx, "gaps", "gapId", articleID, *gapID); err != nil {
+			return models.Comment{}, err
+		}
+	}
+
+	commentID, err := s.store.Insert(
+		ctx,
+		"INSERT INTO analysis_comments (article_id, parent_id, fact_id, gap_id, author_id, body) VALUES (?, ?, ?, ?, ?, ?)",
+		articleID,
+		parentID,
+		factID,
+		gapID,
+		authorID,
+		cleanBody,
+	)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	return s.get(ctx, commentID)
+}
+
+// SetResolved resolves or reopens the thread commentID belongs to and returns
+// the thread's first comment.
+func (s *CommentService) SetResolved(ctx context.Context, commentID int64, resolved bool, userID *int64) (models.Comment, error) {
+	var parentID sql.NullInt64
+	if err := s.store.QueryRowContext(ctx, "SELECT parent_id FROM analysis_comments WHERE id = ?", commentID).Scan(&parentID); err != nil {
+		return models.Comment{}, err
+	}
+	rootID := commentID
+	if parentID.Valid {
+		rootID = parentID.Int64
+	}
+
+	query := "UPDATE analysis_comments SET resolved_by = NULL, resolved_at = NULL WHERE id = ?"
+	args := []any{rootID}
+	if resolved {
+		query = "UPDATE analysis_comments SET resolved_by = ?, resolved_at = CURRENT_TIMESTAMP WHERE id = ?"
+		args = []any{userID, rootID}
+	}
+	result, err := s.store.ExecContext(ctx, query, args...)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return models.Comment{}, err
+	}
+	return s.get(ctx, rootID)
+}
+
+const commentSelect = `
+	SELECT c.id, c.article_id, c.parent_id, c.fact_id, c.gap_id, c.author_id,
+		COALESCE(u.display_name, u.email, '') AS author_name,
+		c.body, c.resolved_by, c.resolved_at, COALESCE(c.created_at, CURRENT_TIMESTAMP)
+	FROM analysis_comments c
+	LEFT JOIN users u ON u.id = c.author_id
+`
+
+func (s *CommentService) get(ctx context.Context, commentID int64) (models.Comment, error) {
+	return scanComment(s.store.QueryRowContext(ctx, commentSelect+" WHERE c.id = ?", commentID))
+}
+
+func (s *CommentService) ensureArticle(ctx context.Context, articleID int64) error {
+	var id int64
+	return s.store.QueryRowContext(ctx, "SELECT id FROM articles WHERE id = ?", articleID).Scan(&id)
+}
+
+// threadRoot is the top-level comment of the thread commentID is in.
+func (s *CommentService) threadRoot(ctx context.Context, articleID int64, commentID int64) (int64, error) {
+	var parentID sql.NullInt64
+	err := s.store.QueryRowContext(ctx, "SELECT parent_id FROM analysis_comments WHERE id = ? AND article_id = ?", commentID, articleID).Scan(&parentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, invalidInput("parentId is invalid for this analysis")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if parentID.Valid {
+		return parentID.Int64, nil
+	}
+	return commentID, nil
+}
+
+func (s *CommentService) ensureBelongs(ctx context.Context, table string, field string, articleID int64, id int64) error {
+	var found int64
+	err := s.store.QueryRowContext(ctx, "SELECT id FROM "+table+" WHERE id = ? AND article_id = ?", id, articleID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return invalidInputf("%s is invalid for this analysis", field)
+	}
+	return err
+}
+
+func scanComment(row rowScanner) (models.Comment, error) {
+	var (
+		comment    models.Comment
+		parentID   sql.NullInt64
+		factID     sql.NullInt64
+		gapID      sql.NullInt64
+		authorID   sql.NullInt64
+		resolvedBy sql.NullInt64
+		resolvedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&comment.ID,
+		&comment.ArticleID,
+		&parentID,
+		&factID,
+		&gapID,
+		&authorID,
+		&comment.AuthorName,
+		&comment.Body,
+		&resolvedBy,
+		&resolvedAt,
+		&comment.CreatedAt,
+	); err != nil {
+		return models.Comment{}, err
+	}
+	comment.ParentID = nullInt64Pointer(parentID)
+	comment.FactID = nullInt64Pointer(factID)
+	comment.GapID = nullInt64Pointer(gapID)
+	comment.AuthorID = nullInt64Pointer(authorID)
+	comment.ResolvedBy = nullInt64Pointer(resolvedBy)
+	if resolvedAt.Valid {
+		value := resolvedAt.Time
+		comment.ResolvedAt = &value
+		comment.Resolved = true
+	}
+	return comment, nil
+}
+
+func nullInt64Pointer(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int64
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	// Inputs with fewer words than this are too short to judge and pass.
+	minScreenedWords = 8
+	// A phrase list has to match this many different phrases before the input
+	// is turned away, so a news story quoting one spam line still passes.
+	screeningPhraseHits = 3
+)
+
+// Phrases that mark marketing spam and adult content. They are English only;
+// inputs in other languages are only checked for gibberish.
+var (
+	spamPhrases = []string{
+		"buy now", "click here", "order now", "limited time offer", "act now", "100% free",
+		"risk-free", "money back guarantee", "earn money fast", "work from home", "make money online",
+		"casino bonus", "free spins", "crypto giveaway", "double your bitcoin", "no credit check",
+		"free gift card", "subscribe now", "cheap viagra", "miracle cure", "lose weight fast", "dm for price",
+	}
+	adultPhrases = []string{
+		"porn", "xxx", "nsfw", "nude pics", "nudes", "camgirl", "webcam girls", "escort service",
+		"onlyfans", "hot singles", "hardcore sex", "milf", "sex chat", "adult videos",
+	}
+)
+
+// screeningRejection is a reason to turn an input away, with the error code
+// callers get for it.
+type screeningRejection struct {
+	code   string
+	reason string
+}
+
+// screenContent checks text for what is plainly not news: gibberish,
+// marketing spam or adult content. The checks are cheap heuristics run before
+// any model call, tuned to let through anything that might be a real story.
+func screenContent(text string) *screeningRejection {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) < minScreenedWords {
+		return nil
+	}
+	if reason := gibberishReason(text, words); reason != "" {
+		return &screeningRejection{code: models.ErrorCodeContentGibberish, reason: reason}
+	}
+
+	if hits := phraseHits(words, adultPhrases); len(hits) >= screeningPhraseHits {
+		return &screeningRejection{code: models.ErrorCodeContentAdult, reason: "it reads as adult content (" + strings.Join(hits, ", ") + ")"}
+	}
+	if hits := phraseHits(words, spamPhrases); len(hits) >= screeningPhraseHits {
+		return &screeningRejection{code: models.ErrorCodeContentSpam, reason: "it reads as advertising or spam (" + strings.Join(hits, ", ") + ")"}
+	}
+	links := 0
+	for _, word := range words {
+		if strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://") || strings.HasPrefix(word, "www.") {
+			links++
+		}
+	}
+	if links >= 5 && links*4 >= len(words) {
+		return &screeningRejection{code: models.ErrorCodeContentSpam, reason: fmt.Sprintf("it is mostly links (%d of %d words)", links, len(words))}
+	}
+	return nil
+}
+
+// gibberishReason explains why text looks like noise rather than language,
+// or returns "".
+func gibberishReason(text string, words []string) string {
+	letters, visible := 0, 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		visible++
+		if unicode.IsLetter(r) || unicode.IsMark(r) {
+			letters++
+		}
+	}
+	if visible == 0 || letters*2 < visible {
+		return "it is mostly numbers and symbols"
+	}
+
+	distinct := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		distinct[word] = struct{}{}
+	}
+	if len(words) >= 30 && len(distinct)*10 < len(words) {
+		return "it repeats the same few words"
+	}
+
+	// Vowels only tell for Latin script; other scripts write them as marks
+	// or not at all.
+	latin, noVowels := 0, 0
+	for _, word := range words {
+		letters := strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+		if len(letters) < 4 || !isLatinWord(letters) {
+			continue
+		}
+		latin++
+		if !strings.ContainsAny(letters, "aeiouy") || hasRepeatedRune(letters, 4) {
+			noVowels++
+		}
+	}
+	if latin >= minScreenedWords && noVowels*5 >= latin*2 {
+		return "most of its words are not words"
+	}
+	return ""
+}
+
+func isLatinWord(word string) bool {
+	for _, r := range word {
+		if !unicode.Is(unicode.Latin, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasRepeatedRune(word string, count int) bool {
+	run, previous := 0, rune(0)
+	for _, r := range word {
+		if r == previous {
+			run++
+		} else {
+			run, previous = 1, r
+		}
+		if run >= count {
+			return true
+		}
+	}
+	return false
+}
+
+// phraseHits lists the phrases found in words. A one-word phrase matches the
+// start of a word, so "nudes," and "pornstar" count.
+func phraseHits(words []string, phrases []string) []string {
+	joined := " " + strings.Join(words, " ")
+	hits := make([]string, 0)
+	for _, phrase := range phrases {
+		var found bool
+		if strings.Contains(phrase, " ") {
+			found = strings.Contains(joined, " "+phrase)
+		} else {
+			found = slices.ContainsFunc(words, func(word string) bool { return strings.HasPrefix(word, phrase) })
+		}
+		if found {
+			hits = append(hits, phrase)
+		}
+	}
+	return hits
+}
+
+// screenSources turns away the first source that fails screening, unless the
+// registry lets its domain skip screening. With several sources the error
+// names the source by number.
+func screenSources(ctx context.Context, store *repository.Store, sources []resolvedSource) error {
+	if !config.Current().ContentScreening {
+		return nil
+	}
+
+	exempt, err := screeningExemptDomains(ctx, store, sources)
+	if err != nil {
+		return err
+	}
+	for idx, source := range sources {
+		if domain := sourceDomain(source.url); domain != "" && exempt[domain] {
+			continue
+		}
+		rejection := screenContent(source.text)
+		if rejection == nil {
+			continue
+		}
+		err := fmt.Errorf("input was turned away by content screening: %s", rejection.reason)
+		if len(sources) > 1 {
+			err = fmt.Errorf("source %d: %w", idx+1, err)
+		}
+		origin := source.url
+		if origin == "" {
+			origin = "pasted text"
+		}
+		log.Printf("[screening] rejected %s: %v", origin, err)
+		return WithCode(rejection.code, err)
+	}
+	return nil
+}
+
+// screeningExemptDomains finds which of the sources' domains, or a parent of
+// them, are marked skipScreening in the registry.
+func screeningExemptDomains(ctx context.Context, store *repository.Store, sources []resolvedSource) (map[string]bool, error) {
+	exempt := make(map[string]bool)
+	candidates := make([]any, 0)
+	owners := make(map[string][]string)
+	for _, source := range sources {
+		domain := sourceDomain(source.url)
+		for _, candidate := range parentDomains(domain) {
+			if _, ok := owners[candidate]; !ok {
+				candidates = append(candidates, candidate)
+			}
+			owners[candidate] = append(owners[candidate], domain)
+		}
+	}
+	if len(candidates) == 0 {
+		return exempt, nil
+	}
+
+	query := "SELECT domain FROM sources WHERE skip_screening = ? AND domain IN (" + repository.Placeholders(len(candidates)) + ")"
+	rows, err := store.QueryContext(ctx, query, append([]any{true}, candidates...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, err
+		}
+		for _, owner := range owners[domain] {
+			exempt[owner] = true
+		}
+	}
+	return exempt, rows.Err()
+}
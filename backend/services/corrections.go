@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const maxCorrectionText = 4000
+
+// AddCorrection records a correction to a completed analysis. The analysis
+// counts as updated, so feeds and the sitemap pick the correction up.
+func (s *AdminService) AddCorrection(ctx context.Context, articleID int64, mistake string, correctedText string, createdBy *int64) (models.AnalysisCorrection, error) {
+	mistake = strings.TrimSpace(mistake)
+	correctedText = strings.TrimSpace(correctedText)
+	if mistake == "" {
+		return models.AnalysisCorrection{}, invalidInput("mistake is required")
+	}
+	if correctedText == "" {
+		return models.AnalysisCorrection{}, invalidInput("correctedText is required")
+	}
+	if len([]rune(mistake)) > maxCorrectionText || len([]rune(correctedText)) > maxCorrectionText {
+		return models.AnalysisCorrection{}, invalidInputf("mistake and correctedText must be at most %d characters", maxCorrectionText)
+	}
+
+	var status string
+	if err := s.store.QueryRowContext(ctx, "SELECT status FROM articles WHERE id = ?", articleID).Scan(&status); err != nil {
+		return models.AnalysisCorrection{}, err
+	}
+	if status != "completed" {
+		return models.AnalysisCorrection{}, invalidInput("only completed analyses take corrections; edit a draft instead")
+	}
+
+	var correctionID int64
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		var err error
+		correctionID, err = tx.Insert(
+			ctx,
+			"INSERT INTO analysis_corrections (article_id, mistake, corrected_text, created_by) VALUES (?, ?, ?, ?)",
+			articleID,
+			mistake,
+			correctedText,
+			createdBy,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE articles SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", articleID)
+		return err
+	})
+	if err != nil {
+		return models.AnalysisCorrection{}, err
+	}
+
+	_, correction, err := scanCorrection(s.store.QueryRowContext(ctx, correctionSelect+" WHERE c.id = ?", correctionID))
+	return correction, err
+}
+
+const correctionSelect = `
+	SELECT c.article_id, c.id, c.mistake, c.corrected_text, c.created_by, COALESCE(u.display_name, u.email, ''), COALESCE(c.created_at, CURRENT_TIMESTAMP)
+	FROM analysis_corrections c
+	LEFT JOIN users u ON u.id = c.created_by`
+
+// correctionsByArticle loads the corrections of each of articleIDs, oldest
+// first.
+func correctionsByArticle(ctx context.Context, q repository.Querier, articleIDs []int64) (map[int64][]models.AnalysisCorrection, error) {
+	byArticle := make(map[int64][]models.AnalysisCorrection)
+	if len(articleIDs) == 0 {
+		return byArticle, nil
+	}
+	args := make([]any, len(articleIDs))
+	for idx, id := range articleIDs {
+		args[idx] = id
+	}
+
+	rows, err := q.QueryContext(ctx, correctionSelect+" WHERE c.article_id IN ("+repository.Placeholders(len(args))+") ORDER BY c.created_at ASC, c.id ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		articleID, correction, err := scanCorrection(rows)
+		if err != nil {
+			return nil, err
+		}
+		byArticle[articleID] = append(byArticle[articleID], correction)
+	}
+	return byArticle, rows.Err()
+}
+
+func scanCorrection(row rowScanner) (int64, models.AnalysisCorrection, error) {
+	var (
+		articleID  int64
+		correction models.AnalysisCorrection
+		createdBy  sql.NullInt64
+	)
+	err := row.Scan(&articleID, &correction.ID, &correction.Mistake, &correction.CorrectedText, &createdBy, &correction.CreatedByName, &correction.CreatedAt)
+	if err != nil {
+		return 0, models.AnalysisCorrection{}, err
+	}
+	correction.CreatedBy = nullInt64Pointer(createdBy)
+	return articleID, correction, nil
+}
+
+// correctionsText is the plain-text corrections block printed under a
+// published article, or "" when it has none.
+func correctionsText(corrections []models.AnalysisCorrection) string {
+	if len(corrections) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	out.WriteString("Corrections")
+	for _, correction := range corrections {
+		out.WriteString("\n\n" + correctionLine(correction))
+	}
+	return out.String()
+}
+
+func correctionLine(correction models.AnalysisCorrection) string {
+	return correction.CreatedAt.UTC().Format("2 January 2006") + ": " + correction.Mistake + " Corrected: " + correction.CorrectedText
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// MaxCorroborationSources caps how many reports one analysis can merge; each
+// source costs a fetch and a fact extraction call.
+const MaxCorroborationSources = 6
+
+// Two facts from different sources are treated as the same claim when their
+// content words overlap at least this much (Dice coefficient).
+const factMatchThreshold = 0.5
+
+var factStopwords = map[string]struct{}{
+	"the": {}, "and": {}, "for": {}, "with": {}, "that": {}, "this": {}, "from": {}, "was": {},
+	"were": {}, "are": {}, "has": {}, "have": {}, "had": {}, "said": {}, "says": {}, "will": {},
+	"been": {}, "its": {}, "their": {}, "his": {}, "her": {}, "but": {}, "not": {}, "after": {},
+	"into": {}, "over": {}, "about": {}, "than": {}, "also": {}, "which": {}, "who": {}, "they": {},
+}
+
+type resolvedSource struct {
+	url           string
+	text          string
+	fetchStrategy string
+	page          *sourcePage // the fetched page, when the text came from the URL
+	facts         []string
+	extracted     bool
+}
+
+type mergedFact struct {
+	text    string
+	sources []int
+	tokens  map[string]struct{}
+	numbers map[string]struct{}
+}
+
+// corroboration holds the sources of a multi-source analysis and the facts
+// merged from them. Source indexes are zero-based here and one-based in
+// responses and storage.
+type corroboration struct {
+	sources []resolvedSource
+	facts   []mergedFact
+	tags    factTagSet
+}
+
+func (s *FactService) resolveSources(ctx context.Context, inputs []models.SourceInput, render bool) (*corroboration, error) {
+	if len(inputs) > MaxCorroborationSources {
+		return nil, invalidInputf("at most %d sources are allowed", MaxCorroborationSources)
+	}
+
+	seen := make(map[string]int, len(inputs))
+	result := &corroboration{sources: make([]resolvedSource, 0, len(inputs))}
+	for idx, input := range inputs {
+		key := strings.ToLower(strings.TrimSpace(input.URL)) + "\x00" + strings.TrimSpace(input.Text)
+		if previous, ok := seen[key]; ok {
+			return nil, invalidInputf("source %d must be different from source %d", idx+1, previous+1)
+		}
+		seen[key] = idx
+
+		source, err := s.resolveInput(ctx, models.PhaseOneInput{Text: input.Text, URL: input.URL, Render: render})
+		if err != nil {
+			return nil, fmt.Errorf("source %d: %w", idx+1, err)
+		}
+		result.sources = append(result.sources, source)
+	}
+	return result, nil
+}
+
+func (s *FactService) extractCorroboratedFacts(ctx context.Context, sources *corroboration, language string) error {
+	for idx := range sources.sources {
+		if sources.sources[idx].extracted {
+			continue
+		}
+		facts, tags, err := s.ai.ExtractFacts(ctx, compactLLMInput(sources.sources[idx].text), language)
+		if err != nil {
+			return fmt.Errorf("source %d: %w", idx+1, err)
+		}
+		if sources.tags == nil {
+			sources.tags = make(factTagSet)
+		}
+		sources.tags.merge(tags)
+		sources.sources[idx].facts = facts
+		sources.sources[idx].extracted = true
+	}
+
+	sources.facts = mergeSourceFacts(sources.sources)
+	if len(sources.facts) == 0 {
+		return emptyFacts("no facts were extracted from the sources")
+	}
+	return nil
+}
+
+// rawText is what gets stored as the analysis input: every source, labelled.
+func (c *corroboration) rawText() string {
+	parts := make([]string, 0, len(c.sources))
+	for idx, source := range c.sources {
+		label := fmt.Sprintf("Source %d", idx+1)
+		if source.url != "" {
+			label += " (" + source.url + ")"
+		}
+		parts = append(parts, label+":\n"+source.text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (c *corroboration) sourceURL() string {
+	for _, source := range c.sources {
+		if source.url != "" {
+			return source.url
+		}
+	}
+	return ""
+}
+
+// fetchStrategy belongs to the source sourceURL picks.
+func (c *corroboration) fetchStrategy() string {
+	for _, source := range c.sources {
+		if source.url != "" {
+			return source.fetchStrategy
+		}
+	}
+	return ""
+}
+
+func (c *corroboration) factTexts() []string {
+	texts := make([]string, len(c.facts))
+	for idx, fact := range c.facts {
+		texts[idx] = fact.text
+	}
+	return texts
+}
+
+func (c *corroboration) taggedFacts() []string {
+	tagged := make([]string, len(c.facts))
+	for idx, fact := range c.facts {
+		if len(fact.sources) >= 2 {
+			tagged[idx] = fmt.Sprintf("[corroborated by %d sources] %s", len(fact.sources), fact.text)
+			continue
+		}
+		tagged[idx] = "[single source] " + fact.text
+	}
+	return tagged
+}
+
+func (c *corroboration) summaries() []models.AnalysisSource {
+	summaries := make([]models.AnalysisSource, len(c.sources))
+	for idx, source := range c.sources {
+		summaries[idx] = models.AnalysisSource{Position: idx + 1, URL: source.url, FactCount: len(source.facts)}
+	}
+	return summaries
+}
+
+// factCorroboration describes each merged fact using texts, which is either
+// the merged facts themselves or their translation in the same order.
+func (c *corroboration) factCorroboration(texts []string) []models.FactCorroboration {
+	if len(texts) != len(c.facts) {
+		texts = c.factTexts()
+	}
+
+	out := make([]models.FactCorroboration, len(c.facts))
+	for idx, fact := range c.facts {
+		positions := make([]int, len(fact.sources))
+		for i, source := range fact.sources {
+			positions[i] = source + 1
+		}
+		out[idx] = models.FactCorroboration{
+			Text:    texts[idx],
+			Status:  corroborationStatus(len(positions)),
+			Sources: positions,
+		}
+	}
+	return out
+}
+
+func corroborationStatus(sourceCount int) string {
+	if sourceCount >= 2 {
+		return models.FactCorroborated
+	}
+	return models.FactSingleSource
+}
+
+// mergeSourceFacts folds each source's facts into a single list, matching a
+// fact to the closest one already seen from another source. Facts reported by
+// more sources come first; ties keep extraction order.
+func mergeSourceFacts(sources []resolvedSource) []mergedFact {
+	merged := make([]mergedFact, 0)
+	for sourceIdx, source := range sources {
+		for _, fact := range source.facts {
+			clean := strings.TrimSpace(fact)
+			if clean == "" {
+				continue
+			}
+			tokens, numbers := factTokens(clean)
+
+			best, bestScore := -1, 0.0
+			for idx := range merged {
+				if containsInt(merged[idx].sources, sourceIdx) {
+					continue
+				}
+				if score := factSimilarity(tokens, numbers, merged[idx].tokens, merged[idx].numbers); score > bestScore {
+					best, bestScore = idx, score
+				}
+			}
+
+			if best >= 0 && bestScore >= factMatchThreshold {
+				merged[best].sources = append(merged[best].sources, sourceIdx)
+				continue
+			}
+			merged = append(merged, mergedFact{text: clean, sources: []int{sourceIdx}, tokens: tokens, numbers: numbers})
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return len(merged[i].sources) > len(merged[j].sources)
+	})
+	return merged
+}
+
+// factSimilarity scores content-word overlap. Facts whose figures disagree
+// never match, so "420 crore" and "300 crore" stay separate claims.
+func factSimilarity(tokensA, numbersA, tokensB, numbersB map[string]struct{}) float64 {
+	if len(numbersA) > 0 && len(numbersB) > 0 && !isSubset(numbersA, numbersB) && !isSubset(numbersB, numbersA) {
+		return 0
+	}
+	if len(tokensA) == 0 || len(tokensB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for token := range tokensA {
+		if _, ok := tokensB[token]; ok {
+			shared++
+		}
+	}
+	if shared < 3 {
+		return 0
+	}
+	return 2 * float64(shared) / float64(len(tokensA)+len(tokensB))
+}
+
+func factTokens(text string) (map[string]struct{}, map[string]struct{}) {
+	tokens := make(map[string]struct{})
+	numbers := make(map[string]struct{})
+
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	for _, field := range fields {
+		if number, err := strconv.Atoi(field); err == nil {
+			numbers[strconv.Itoa(number)] = struct{}{}
+			tokens[field] = struct{}{}
+			continue
+		}
+		if len([]rune(field)) < 3 {
+			continue
+		}
+		if _, stop := factStopwords[field]; stop {
+			continue
+		}
+		if len([]rune(field)) > 4 {
+			field = strings.TrimSuffix(field, "s")
+		}
+		tokens[field] = struct{}{}
+	}
+	return tokens, numbers
+}
+
+func isSubset(small, large map[string]struct{}) bool {
+	for key := range small {
+		if _, ok := large[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func containsInt(values []int, target int) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+func insertCorroboration(ctx context.Context, tx *repository.Tx, articleID int64, sources *corroboration, factIDs []int64) error {
+	sourceIDs := make([]int64, len(sources.sources))
+	sourceQuery := `INSERT INTO article_sources (article_id, position, source_url, raw_text, fact_count, fetch_strategy) VALUES (?, ?, ?, ?, ?, ?)`
+	for idx, source := range sources.sources {
+		if err := registerSourceDomain(ctx, tx, sourceDomain(source.url)); err != nil {
+			return err
+		}
+		id, err := tx.Insert(ctx, sourceQuery, articleID, idx+1, nullString(source.url), source.text, len(source.facts), nullString(source.fetchStrategy))
+		if err != nil {
+			return err
+		}
+		sourceIDs[idx] = id
+	}
+
+	linkQuery := `INSERT INTO fact_sources (fact_id, article_source_id) VALUES (?, ?)`
+	for idx, fact := range sources.facts {
+		if idx >= len(factIDs) || factIDs[idx] <= 0 {
+			continue
+		}
+		for _, sourceIdx := range fact.sources {
+			if _, err := tx.ExecContext(ctx, linkQuery, factIDs[idx], sourceIDs[sourceIdx]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	defaultAnalyticsDays       = 30
+	defaultWeeklyAnalyticsDays = 12 * 7
+	maxAnalyticsDays           = 366
+)
+
+// analyticsBuckets maps dates to the buckets of a dashboard window. Dates are
+// compared by calendar day, as the database's DATE() reports them.
+type analyticsBuckets struct {
+	width int
+	start time.Time
+	count int
+}
+
+func normalizeAnalyticsPeriod(period models.AnalyticsPeriod) (models.AnalyticsPeriod, error) {
+	bucket := strings.ToLower(strings.TrimSpace(period.Bucket))
+	switch bucket {
+	case "":
+		bucket = "day"
+	case "day", "week":
+	default:
+		return models.AnalyticsPeriod{}, invalidInput("bucket must be day or week")
+	}
+
+	days := period.Days
+	if days == 0 {
+		days = defaultAnalyticsDays
+		if bucket == "week" {
+			days = defaultWeeklyAnalyticsDays
+		}
+	}
+	if days < 1 || days > maxAnalyticsDays {
+		return models.AnalyticsPeriod{}, invalidInput("days must be between 1 and 366")
+	}
+	return models.AnalyticsPeriod{Bucket: bucket, Days: days}, nil
+}
+
+// newAnalyticsBuckets covers the last days days up to today. Weekly buckets
+// start on Monday, so the first one may reach back before the window.
+func newAnalyticsBuckets(period models.AnalyticsPeriod, now time.Time) analyticsBuckets {
+	today := calendarDay(now)
+	start := today.AddDate(0, 0, -(period.Days - 1))
+	width := 1
+	if period.Bucket == "week" {
+		width = 7
+		start = startOfWeek(start)
+	}
+	days := int(today.Sub(start).Hours()/24) + 1
+	return analyticsBuckets{
+		width: width,
+		start: start,
+		count: (days + width - 1) / width,
+	}
+}
+
+func (b analyticsBuckets) index(value time.Time) int {
+	days := int(calendarDay(value).Sub(b.start).Hours() / 24)
+	if days < 0 {
+		return -1
+	}
+	idx := days / b.width
+	if idx >= b.count {
+		return -1
+	}
+	return idx
+}
+
+func (b analyticsBuckets) series(values []float64) []models.SeriesPoint {
+	points := make([]models.SeriesPoint, b.count)
+	for idx := range points {
+		points[idx] = models.SeriesPoint{
+			Date:  b.start.AddDate(0, 0, idx*b.width).Format(time.DateOnly),
+			Value: roundTo2(values[idx]),
+		}
+	}
+	return points
+}
+
+func (b analyticsBuckets) end() time.Time {
+	return b.start.AddDate(0, 0, b.count*b.width-1)
+}
+
+func calendarDay(value time.Time) time.Time {
+	year, month, day := value.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func startOfWeek(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func roundTo2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func ratio(total float64, count float64) float64 {
+	if count == 0 {
+		return 0
+	}
+	return total / count
+}
+
+func loadDashboardAnalytics(ctx context.Context, store *repository.Store, period models.AnalyticsPeriod, now time.Time) (models.DashboardAnalytics, error) {
+	buckets := newAnalyticsBuckets(period, now)
+	analytics := models.DashboardAnalytics{
+		Bucket:     period.Bucket,
+		From:       buckets.start.Format(time.DateOnly),
+		To:         buckets.end().Format(time.DateOnly),
+		Categories: make([]models.CategoryCount, 0),
+		Providers:  make([]models.ProviderUsage, 0),
+	}
+
+	analyses := make([]float64, buckets.count)
+	facts := make([]float64, buckets.count)
+	categories := make(map[string]int64)
+	var totalFacts float64
+
+	rows, err := store.QueryContext(ctx, `
+		SELECT DATE(a.created_at), COALESCE(t.name, 'Uncategorized'), COUNT(*), COALESCE(SUM(fc.fact_count), 0)
+		FROM articles a
+		LEFT JOIN topics t ON t.id = a.topic_id
+		LEFT JOIN (SELECT article_id, COUNT(*) AS fact_count FROM facts WHERE deleted_at IS NULL GROUP BY article_id) fc ON fc.article_id = a.id
+		WHERE a.created_at >= ?
+		GROUP BY DATE(a.created_at), t.name
+	`, buckets.start)
+	if err != nil {
+		return models.DashboardAnalytics{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			day       time.Time
+			category  string
+			count     int64
+			factCount int64
+		)
+		if err := rows.Scan(&day, &category, &count, &factCount); err != nil {
+			return models.DashboardAnalytics{}, err
+		}
+		idx := buckets.index(day)
+		if idx < 0 {
+			continue
+		}
+		analyses[idx] += float64(count)
+		facts[idx] += float64(factCount)
+		categories[category] += count
+		analytics.Totals.Analyses += count
+		totalFacts += float64(factCount)
+	}
+	if err := rows.Err(); err != nil {
+		return models.DashboardAnalytics{}, err
+	}
+
+	averageFacts := make([]float64, buckets.count)
+	for idx := range averageFacts {
+		averageFacts[idx] = ratio(facts[idx], analyses[idx])
+	}
+	analytics.Analyses = buckets.series(analyses)
+	analytics.AverageFacts = buckets.series(averageFacts)
+	analytics.Totals.AverageFacts = roundTo2(ratio(totalFacts, float64(analytics.Totals.Analyses)))
+
+	for category, count := range categories {
+		analytics.Categories = append(analytics.Categories, models.CategoryCount{Category: category, Count: count})
+	}
+	sort.Slice(analytics.Categories, func(i, j int) bool {
+		if analytics.Categories[i].Count != analytics.Categories[j].Count {
+			return analytics.Categories[i].Count > analytics.Categories[j].Count
+		}
+		return analytics.Categories[i].Category < analytics.Categories[j].Category
+	})
+
+	if err := loadCompletionSeries(ctx, store, buckets, &analytics); err != nil {
+		return models.DashboardAnalytics{}, err
+	}
+	if err := loadProviderUsage(ctx, store, buckets, &analytics); err != nil {
+		return models.DashboardAnalytics{}, err
+	}
+	return analytics, nil
+}
+
+// loadCompletionSeries buckets analyses by the day they were completed, which
+// may be well after they were created.
+func loadCompletionSeries(ctx context.Context, store *repository.Store, buckets analyticsBuckets, analytics *models.DashboardAnalytics) error {
+	rows, err := store.QueryContext(ctx, "SELECT COALESCE(created_at, completed_at), completed_at FROM articles WHERE completed_at >= ?", buckets.start)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	completed := make([]float64, buckets.count)
+	hours := make([]float64, buckets.count)
+	var totalHours float64
+	for rows.Next() {
+		var createdAt, completedAt time.Time
+		if err := rows.Scan(&createdAt, &completedAt); err != nil {
+			return err
+		}
+		idx := buckets.index(completedAt)
+		if idx < 0 {
+			continue
+		}
+		elapsed := math.Max(completedAt.Sub(createdAt).Hours(), 0)
+		completed[idx]++
+		hours[idx] += elapsed
+		totalHours += elapsed
+		analytics.Totals.Completed++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	averageHours := make([]float64, buckets.count)
+	for idx := range averageHours {
+		averageHours[idx] = ratio(hours[idx], completed[idx])
+	}
+	analytics.Completed = buckets.series(completed)
+	analytics.AverageHoursToComplete = buckets.series(averageHours)
+	analytics.Totals.AverageHoursToComplete = roundTo2(ratio(totalHours, float64(analytics.Totals.Completed)))
+	return nil
+}
+
+func loadProviderUsage(ctx context.Context, store *repository.Store, buckets analyticsBuckets, analytics *models.DashboardAnalytics) error {
+	rows, err := store.QueryContext(ctx, `
+		SELECT DATE(created_at), COALESCE(provider, ''), COUNT(*),
+			COALESCE(SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(latency_ms), 0)
+		FROM llm_calls
+		WHERE created_at >= ?
+		GROUP BY DATE(created_at), provider
+	`, buckets.start)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type usage struct {
+		calls     int64
+		errors    int64
+		latencyMS int64
+		series    []float64
+	}
+	providers := make(map[string]*usage)
+	for rows.Next() {
+		var (
+			day       time.Time
+			provider  string
+			calls     int64
+			failures  int64
+			latencyMS int64
+		)
+		if err := rows.Scan(&day, &provider, &calls, &failures, &latencyMS); err != nil {
+			return err
+		}
+		idx := buckets.index(day)
+		if idx < 0 {
+			continue
+		}
+		if provider == "" {
+			provider = "unknown"
+		}
+		entry, ok := providers[provider]
+		if !ok {
+			entry = &usage{series: make([]float64, buckets.count)}
+			providers[provider] = entry
+		}
+		entry.calls += calls
+		entry.errors += failures
+		entry.latencyMS += latencyMS
+		entry.series[idx] += float64(calls)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for provider, entry := range providers {
+		analytics.Providers = append(analytics.Providers, models.ProviderUsage{
+			Provider:     provider,
+			Calls:        entry.calls,
+			Errors:       entry.errors,
+			AvgLatencyMS: int64(ratio(float64(entry.latencyMS), float64(entry.calls))),
+			Series:       buckets.series(entry.series),
+		})
+	}
+	sort.Slice(analytics.Providers, func(i, j int) bool {
+		if analytics.Providers[i].Calls != analytics.Providers[j].Calls {
+			return analytics.Providers[i].Calls > analytics.Providers[j].Calls
+		}
+		return analytics.Providers[i].Provider < analytics.Providers[j].Provider
+	})
+	return nil
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// A digest lists at most this many analyses, newest first, and says how many
+// more there were.
+const maxDigestItems = 50
+
+//go:embed templates/digest.html
+var digestHTML string
+
+var digestTemplate = template.Must(template.New("digest").Parse(digestHTML))
+
+type DigestService struct {
+	store *repository.Store
+}
+
+func NewDigestService(database *sql.DB) *DigestService {
+	return &DigestService{store: repository.New(database)}
+}
+
+type digestItem struct {
+	ID        int64
+	Headline  string
+	Strapline string
+	Link      string
+}
+
+type digestEmail struct {
+	Title     string
+	Period    string
+	Frequency string
+	Items     []digestItem
+	More      int
+}
+
+// Get returns the caller's digest subscription; not being subscribed is not
+// an error.
+func (s *DigestService) Get(ctx context.Context, userID *int64) (models.DigestSubscription, error) {
+	if userID == nil {
+		return models.DigestSubscription{}, invalidInput("a signed-in user is required")
+	}
+
+	var (
+		subscription models.DigestSubscription
+		lastSentAt   sql.NullTime
+	)
+	err := s.store.QueryRowContext(ctx, "SELECT frequency, last_sent_at FROM digest_subscriptions WHERE user_id = ?", *userID).Scan(&subscription.Frequency, &lastSentAt)
+	if err == sql.ErrNoRows {
+		return models.DigestSubscription{}, nil
+	}
+	if err != nil {
+		return models.DigestSubscription{}, err
+	}
+	subscription.Subscribed = true
+	if lastSentAt.Valid {
+		subscription.LastSentAt = &lastSentAt.Time
+	}
+	return subscription, nil
+}
+
+// Subscribe starts or changes the caller's digest. Changing the frequency
+// keeps lastSentAt, so the next digest doesn't repeat what the last one had.
+func (s *DigestService) Subscribe(ctx context.Context, userID *int64, frequency string) (models.DigestSubscription, error) {
+	if userID == nil {
+		return models.DigestSubscription{}, invalidInput("a signed-in user is required")
+	}
+	frequency = strings.ToLower(strings.TrimSpace(frequency))
+	if frequency != models.DigestDaily && frequency != models.DigestWeekly {
+		return models.DigestSubscription{}, invalidInputf("frequency must be %q or %q", models.DigestDaily, models.DigestWeekly)
+	}
+	if !config.Current().DigestsEnabled() {
+		return models.DigestSubscription{}, notConfigured("digest emails need SMTP_HOST")
+	}
+
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"digest_subscriptions",
+		[]string{"user_id", "frequency"},
+		[]string{"user_id"},
+		[]string{"frequency"},
+		"updated_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		return models.DigestSubscription{}, err
+	}
+	if _, err := s.store.ExecContext(ctx, query, *userID, frequency); err != nil {
+		return models.DigestSubscription{}, err
+	}
+	return s.Get(ctx, userID)
+}
+
+func (s *DigestService) Unsubscribe(ctx context.Context, userID *int64) error {
+	if userID == nil {
+		return invalidInput("a signed-in user is required")
+	}
+	_, err := s.store.ExecContext(ctx, "DELETE FROM digest_subscriptions WHERE user_id = ?", *userID)
+	return err
+}
+
+type digestRecipient struct {
+	userID     int64
+	email      string
+	frequency  string
+	lastSentAt sql.NullTime
+}
+
+// Send emails every subscriber whose digest is due: daily ones once a day
+// after DIGEST_HOUR, weekly ones on Mondays. A digest covers what completed
+// since the last one, or over the past period for a first digest. Emails
+// that fail are tried again on the next run.
+func (s *DigestService) Send(ctx context.Context, report func(int, int)) (models.DigestRunResult, error) {
+	cfg := config.Current()
+	result := models.DigestRunResult{}
+	now := time.Now().UTC()
+
+	recipients, err := s.dueRecipients(ctx, cfg, now)
+	if err != nil {
+		return result, err
+	}
+	result.Due = len(recipients)
+	report(0, len(recipients))
+
+	for idx, recipient := range recipients {
+		since := now.Add(-digestPeriod(recipient.frequency))
+		if recipient.lastSentAt.Valid && recipient.lastSentAt.Time.After(since) {
+			since = recipient.lastSentAt.Time
+		}
+		items, total, err := s.completedSince(ctx, cfg, since, now)
+		if err != nil {
+			return result, err
+		}
+
+		if total == 0 {
+			result.Empty++
+		} else if err := sendDigest(cfg, recipient, since, items, total); err != nil {
+			log.Printf("[digests] email to user %d failed: %v", recipient.userID, err)
+			result.Failed++
+			report(idx+1, len(recipients))
+			continue
+		} else {
+			result.Sent++
+		}
+		if _, err := s.store.ExecContext(ctx, "UPDATE digest_subscriptions SET last_sent_at = ? WHERE user_id = ?", now, recipient.userID); err != nil {
+			return result, err
+		}
+		report(idx+1, len(recipients))
+	}
+	return result, nil
+}
+
+func (s *DigestService) dueRecipients(ctx context.Context, cfg config.Config, now time.Time) ([]digestRecipient, error) {
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT d.user_id, u.email, d.frequency, d.last_sent_at
+		FROM digest_subscriptions d
+		JOIN users u ON u.id = d.user_id
+		WHERE COALESCE(u.is_active, true) = ? AND COALESCE(u.email, '') <> ''
+		ORDER BY d.user_id`, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := make([]digestRecipient, 0)
+	for rows.Next() {
+		var recipient digestRecipient
+		if err := rows.Scan(&recipient.userID, &recipient.email, &recipient.frequency, &recipient.lastSentAt); err != nil {
+			return nil, err
+		}
+		slot := digestSlot(now, cfg.DigestHour, recipient.frequency)
+		if recipient.lastSentAt.Valid && !recipient.lastSentAt.Time.Before(slot) {
+			continue
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, rows.Err()
+}
+
+func (s *DigestService) completedSince(ctx context.Context, cfg config.Config, since time.Time, until time.Time) ([]digestItem, int, error) {
+	var total int
+	err := s.store.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM articles
+		WHERE status = 'completed' AND merged_into IS NULL
+			AND completed_at > ? AND completed_at <= ?`, since, until).Scan(&total)
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT id, COALESCE(headline_selected, ''), COALESCE(strapline_selected, ''), COALESCE(source_url, '')
+		FROM articles
+		WHERE status = 'completed' AND merged_into IS NULL
+			AND completed_at > ? AND completed_at <= ?
+		ORDER BY completed_at DESC, id DESC
+		LIMIT ?`, since, until, maxDigestItems)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := make([]digestItem, 0)
+	for rows.Next() {
+		var (
+			item      digestItem
+			headline  string
+			sourceURL string
+		)
+		if err := rows.Scan(&item.ID, &headline, &item.Strapline, &sourceURL); err != nil {
+			return nil, 0, err
+		}
+		item.Headline = buildAnalysisTitle(item.ID, headline, sourceURL, "")
+		item.Strapline = strings.TrimSpace(item.Strapline)
+		item.Link = analysisLink(cfg, item.ID)
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+func sendDigest(cfg config.Config, recipient digestRecipient, since time.Time, items []digestItem, total int) error {
+	noun := "analyses"
+	if total == 1 {
+		noun = "analysis"
+	}
+	email := digestEmail{
+		Title:     fmt.Sprintf("%s digest: %d completed %s", strings.ToUpper(recipient.frequency[:1])+recipient.frequency[1:], total, noun),
+		Period:    fmt.Sprintf("Completed since %s UTC", since.Format("Mon 2 Jan 15:04")),
+		Frequency: recipient.frequency,
+		Items:     items,
+		More:      total - len(items),
+	}
+
+	var html bytes.Buffer
+	if err := digestTemplate.Execute(&html, email); err != nil {
+		return err
+	}
+	return sendHTMLEmail(cfg, recipient.email, email.Title, digestText(email), html.String())
+}
+
+func digestText(email digestEmail) string {
+	var text strings.Builder
+	text.WriteString(email.Title + "\n" + email.Period + "\n")
+	for _, item := range email.Items {
+		text.WriteString("\n" + item.Headline + "\n")
+		if item.Strapline != "" {
+			text.WriteString(item.Strapline + "\n")
+		}
+		if item.Link != "" {
+			text.WriteString(item.Link + "\n")
+		} else {
+			text.WriteString(fmt.Sprintf("Analysis #%d\n", item.ID))
+		}
+	}
+	if email.More > 0 {
+		text.WriteString(fmt.Sprintf("\nand %d more\n", email.More))
+	}
+	return text.String()
+}
+
+func digestPeriod(frequency string) time.Duration {
+	if frequency == models.DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// digestSlot is the latest time at or before now a digest of frequency was
+// due: today at hour, or the latest Monday for weekly digests.
+func digestSlot(now time.Time, hour int, frequency string) time.Time {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if frequency == models.DigestWeekly {
+		slot = slot.AddDate(0, 0, -((int(slot.Weekday()) + 6) % 7))
+	}
+	return slot
+}
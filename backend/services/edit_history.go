@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// maxEditHistory is how many edits of one fact or gap can be undone.
+const maxEditHistory = 20
+
+var (
+	ErrNothingToUndo = errors.New("there is no edit to undo")
+	ErrNothingToRedo = errors.New("there is no undone edit to redo")
+)
+
+// editable describes a record whose editor changes are kept for undo: its
+// text column and the two flags an editor toggles, with their JSON names.
+// Rows not matching live can't be edited.
+type editable struct {
+	kind        string
+	table       string
+	live        string
+	event       string
+	textColumn  string
+	flagColumns [2]string
+	flagNames   [2]string
+}
+
+var (
+	factEdits = editable{
+		kind:        "fact",
+		table:       "facts",
+		live:        "deleted_at IS NULL",
+		event:       models.CollaborationFactUpdated,
+		textColumn:  "fact_text",
+		flagColumns: [2]string{"is_included", "is_confirmed"},
+		flagNames:   [2]string{"included", "confirmed"},
+	}
+	gapEdits = editable{
+		kind:        "gap",
+		table:       "gaps",
+		event:       models.CollaborationGapUpdated,
+		textColumn:  "question",
+		flagColumns: [2]string{"is_selected", "is_resolved"},
+		flagNames:   [2]string{"selected", "resolved"},
+	}
+)
+
+type editState struct {
+	text  string
+	flags [2]bool
+}
+
+type editRecord struct {
+	articleID int64
+	version   int64
+	state     editState
+}
+
+func (e editable) load(ctx context.Context, q repository.Querier, id int64) (editRecord, error) {
+	query := "SELECT article_id, version, COALESCE(" + e.textColumn + ", ''), COALESCE(" + e.flagColumns[0] + ", false), COALESCE(" + e.flagColumns[1] + ", false) FROM " + e.table + " WHERE id = ?"
+	if e.live != "" {
+		query += " AND " + e.live
+	}
+	var record editRecord
+	err := q.QueryRowContext(ctx, query, id).Scan(&record.articleID, &record.version, &record.state.text, &record.state.flags[0], &record.state.flags[1])
+	return record, err
+}
+
+func (e editable) encode(state editState) (string, error) {
+	encoded, err := json.Marshal(map[string]any{
+		"text":         state.text,
+		e.flagNames[0]: state.flags[0],
+		e.flagNames[1]: state.flags[1],
+	})
+	return string(encoded), err
+}
+
+func (e editable) decode(raw string) (editState, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return editState{}, err
+	}
+	var state editState
+	state.text, _ = fields["text"].(string)
+	for idx, name := range e.flagNames {
+		state.flags[idx], _ = fields[name].(bool)
+	}
+	return state, nil
+}
+
+// trackedUpdate applies an editor's change like updateVersioned and keeps it
+// for undo. A new edit drops whatever was undone before it.
+func (s *AdminService) trackedUpdate(ctx context.Context, e editable, id int64, version int64, setClauses []string, args []any, editedBy *int64) (int64, error) {
+	var next int64
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		before, err := e.load(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		next, err = updateVersioned(ctx, tx, e.table, e.kind, id, version, setClauses, args)
+		if err != nil {
+			return err
+		}
+		after, err := e.load(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if after.state == before.state {
+			return nil
+		}
+		return e.record(ctx, tx, id, before, after.state, editedBy)
+	})
+	if err != nil {
+		return 0, err
+	}
+	announceEdit(ctx, s.store, e.table, models.CollaborationEvent{Type: e.event, EntityID: id, Version: next, UserID: editedBy})
+	return next, nil
+}
+
+func (e editable) record(ctx context.Context, tx *repository.Tx, id int64, before editRecord, after editState, editedBy *int64) error {
+	previous, err := e.encode(before.state)
+	if err != nil {
+		return err
+	}
+	current, err := e.encode(after)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM edit_history WHERE entity_type = ? AND entity_id = ? AND undone_at IS NOT NULL", e.kind, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO edit_history (entity_type, entity_id, article_id, previous_state, new_state, edited_by) VALUES (?, ?, ?, ?, ?, ?)",
+		e.kind,
+		id,
+		before.articleID,
+		previous,
+		current,
+		editedBy,
+	); err != nil {
+		return err
+	}
+
+	var oldest int64
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT id FROM edit_history WHERE entity_type = ? AND entity_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?",
+		e.kind,
+		id,
+		maxEditHistory,
+	).Scan(&oldest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM edit_history WHERE entity_type = ? AND entity_id = ? AND id <= ?", e.kind, id, oldest)
+	return err
+}
+
+// revert undoes the latest edit still in force or, with redo, reapplies the
+// edit undone most recently. It refuses when the record was changed some
+// other way since, as putting it back would lose that change.
+func (s *AdminService) revert(ctx context.Context, e editable, id int64, redo bool, revertedBy *int64) (int64, error) {
+	query := "SELECT id, previous_state, new_state FROM edit_history WHERE entity_type = ? AND entity_id = ? AND undone_at IS NULL ORDER BY id DESC LIMIT 1"
+	mark := "UPDATE edit_history SET undone_at = CURRENT_TIMESTAMP WHERE id = ?"
+	nothing := ErrNothingToUndo
+	if redo {
+		query = "SELECT id, previous_state, new_state FROM edit_history WHERE entity_type = ? AND entity_id = ? AND undone_at IS NOT NULL ORDER BY id ASC LIMIT 1"
+		mark = "UPDATE edit_history SET undone_at = NULL WHERE id = ?"
+		nothing = ErrNothingToRedo
+	}
+
+	var next int64
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		current, err := e.load(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		var (
+			entryID  int64
+			previous string
+			edited   string
+		)
+		err = tx.QueryRowContext(ctx, query, e.kind, id).Scan(&entryID, &previous, &edited)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nothing
+		}
+		if err != nil {
+			return err
+		}
+
+		from, to := edited, previous
+		if redo {
+			from, to = previous, edited
+		}
+		expected, err := e.decode(from)
+		if err != nil {
+			return err
+		}
+		target, err := e.decode(to)
+		if err != nil {
+			return err
+		}
+		if current.state != expected {
+			return &VersionConflictError{Kind: e.kind, ID: id, Current: current.version}
+		}
+
+		next, err = updateVersioned(
+			ctx,
+			tx,
+			e.table,
+			e.kind,
+			id,
+			current.version,
+			[]string{e.textColumn + " = ?", e.flagColumns[0] + " = ?", e.flagColumns[1] + " = ?"},
+			[]any{target.text, target.flags[0], target.flags[1]},
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, mark, entryID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	announceEdit(ctx, s.store, e.table, models.CollaborationEvent{Type: e.event, EntityID: id, Version: next, UserID: revertedBy})
+	return next, nil
+}
+
+// UndoFactEdit reverts the last edit of a fact and returns its new version.
+func (s *AdminService) UndoFactEdit(ctx context.Context, factID int64, undoneBy *int64) (int64, error) {
+	return s.revert(ctx, factEdits, factID, false, undoneBy)
+}
+
+func (s *AdminService) RedoFactEdit(ctx context.Context, factID int64, redoneBy *int64) (int64, error) {
+	return s.revert(ctx, factEdits, factID, true, redoneBy)
+}
+
+func (s *AdminService) UndoGapEdit(ctx context.Context, gapID int64, undoneBy *int64) (int64, error) {
+	return s.revert(ctx, gapEdits, gapID, false, undoneBy)
+}
+
+func (s *AdminService) RedoGapEdit(ctx context.Context, gapID int64, redoneBy *int64) (int64, error) {
+	return s.revert(ctx, gapEdits, gapID, true, redoneBy)
+}
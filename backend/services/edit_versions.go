@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"nanoheads/repository"
+)
+
+// VersionConflictError means a record was edited since the version an update
+// was based on. Current is the version now stored.
+type VersionConflictError struct {
+	Kind    string
+	ID      int64
+	Current int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s %d was changed by someone else; reload it and reapply your edit", e.Kind, e.ID)
+}
+
+// updateVersioned applies setClauses to the row of table with id, provided it
+// is still at version, and returns the version it moved to.
+func updateVersioned(ctx context.Context, q repository.Querier, table string, kind string, id int64, version int64, setClauses []string, args []any) (int64, error) {
+	if version < 1 {
+		return 0, invalidInput("version must be a positive number")
+	}
+
+	setClauses = append(setClauses, "version = version + 1")
+	query := "UPDATE " + table + " SET " + strings.Join(setClauses, ", ") + " WHERE id = ? AND version = ?"
+	result, err := q.ExecContext(ctx, query, append(args, id, version)...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		return version + 1, nil
+	}
+
+	var current int64
+	if err := q.QueryRowContext(ctx, "SELECT version FROM "+table+" WHERE id = ?", id).Scan(&current); err != nil {
+		return 0, err
+	}
+	return 0, &VersionConflictError{Kind: kind, ID: id, Current: current}
+}
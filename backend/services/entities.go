@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	wikidataTimeout = 10 * time.Second
+	// Most-mentioned names looked up per analysis.
+	maxAnalysisEntities = 10
+	// Search results considered for each name.
+	wikidataCandidates = 5
+)
+
+// Lower-case words that can sit inside a name, as in "Bank of England".
+var entityConnectors = map[string]struct{}{
+	"of": {}, "de": {}, "del": {}, "della": {}, "da": {}, "do": {}, "dos": {}, "van": {}, "von": {}, "der": {}, "al": {}, "bin": {}, "la": {}, "le": {},
+}
+
+type entityPayload struct {
+	ArticleID int64 `json:"articleId"`
+}
+
+type EntityService struct {
+	store  *repository.Store
+	client *http.Client
+}
+
+func NewEntityService(database *sql.DB) *EntityService {
+	return &EntityService{
+		store:  repository.New(database),
+		client: &http.Client{Timeout: wikidataTimeout},
+	}
+}
+
+type entityMention struct {
+	name     string
+	mentions int
+}
+
+// wikidataEntity is a cached lookup; id is empty when nothing matched.
+type wikidataEntity struct {
+	id           string
+	label        string
+	description  string
+	wikipediaURL string
+}
+
+type wikidataSearchResponse struct {
+	Search []struct {
+		ID          string `json:"id"`
+		Label       string `json:"label"`
+		Description string `json:"description"`
+		Match       struct {
+			Text string `json:"text"`
+		} `json:"match"`
+	} `json:"search"`
+}
+
+type wikidataSitelinksResponse struct {
+	Entities map[string]struct {
+		Sitelinks map[string]struct {
+			URL string `json:"url"`
+		} `json:"sitelinks"`
+	} `json:"entities"`
+}
+
+// EnqueueEntityEnrichment queues Wikidata lookups of the names an analysis
+// mentions.
+func EnqueueEntityEnrichment(ctx context.Context, jobs *JobService, articleID int64, createdBy *int64) (models.Job, error) {
+	if !config.Current().EntityEnrichment {
+		return models.Job{}, notConfigured("ENTITY_ENRICHMENT must be on for entity lookups")
+	}
+
+	var exists int
+	if err := jobs.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.Job{}, err
+	}
+	return jobs.Enqueue(ctx, models.JobTypeEntities, entityPayload{ArticleID: articleID}, createdBy)
+}
+
+// Enrich picks out the names in an analysis's headline and included facts,
+// looks each up in Wikidata in the analysis's output language, and replaces
+// the analysis's entities. Lookups younger than EntityCacheTTL are reused,
+// including those that found nothing. A name whose lookup fails is left
+// out; the run only fails when every lookup does.
+func (s *EntityService) Enrich(ctx context.Context, articleID int64, report func(int, int)) (models.EntityResult, error) {
+	cfg := config.Current()
+	result := models.EntityResult{ArticleID: articleID}
+
+	var headline, outputLanguage string
+	err := s.store.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(headline_selected, ''), COALESCE(output_language, '') FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&headline, &outputLanguage)
+	if err != nil {
+		return result, err
+	}
+	facts, err := listTexts(ctx, s.store, "SELECT COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY position ASC, id ASC", articleID)
+	if err != nil {
+		return result, err
+	}
+
+	language := englishLanguage.Code
+	if outputLanguage != "" {
+		if resolved, err := resolveLanguage(ctx, s.store, outputLanguage); err == nil {
+			language = resolved.Code
+		}
+	}
+
+	mentions := entityMentions(append([]string{headline}, facts...))
+	if len(mentions) > maxAnalysisEntities {
+		mentions = mentions[:maxAnalysisEntities]
+	}
+	report(0, len(mentions))
+
+	entityIDs := make([]int64, 0, len(mentions))
+	kept := make([]entityMention, 0, len(mentions))
+	var lastErr error
+	for idx, mention := range mentions {
+		key := strings.ToLower(truncateRunes(mention.name, 255))
+		entityID, fresh, err := s.cachedEntity(ctx, key, language, cfg.EntityCacheTTL.Duration)
+		if err != nil {
+			return result, err
+		}
+		if fresh {
+			result.Cached++
+		} else {
+			found, err := s.lookup(ctx, cfg, mention.name, language)
+			if err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				log.Printf("[entities] article %d: lookup %q: %v", articleID, mention.name, err)
+				result.Failed++
+				lastErr = err
+				report(idx+1, len(mentions))
+				continue
+			}
+			if entityID, err = s.saveEntity(ctx, key, language, found); err != nil {
+				return result, err
+			}
+		}
+		entityIDs = append(entityIDs, entityID)
+		kept = append(kept, mention)
+		report(idx+1, len(mentions))
+	}
+	if len(mentions) > 0 && len(kept) == 0 {
+		return result, fmt.Errorf("every Wikidata lookup failed: %w", lastErr)
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM article_entities WHERE article_id = ?", articleID); err != nil {
+			return err
+		}
+		for idx, mention := range kept {
+			_, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO article_entities (article_id, entity_id, name, mentions, position) VALUES (?, ?, ?, ?, ?)",
+				articleID,
+				entityIDs[idx],
+				mention.name,
+				mention.mentions,
+				idx+1,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE articles SET entities_checked_at = CURRENT_TIMESTAMP WHERE id = ?", articleID)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Entities = len(kept)
+	err = s.store.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM article_entities ae JOIN entities e ON e.id = ae.entity_id WHERE ae.article_id = ? AND e.wikidata_id IS NOT NULL",
+		articleID,
+	).Scan(&result.Matched)
+	return result, err
+}
+
+// cachedEntity returns the cached lookup of key, and whether it is recent
+// enough to reuse.
+func (s *EntityService) cachedEntity(ctx context.Context, key string, language string, ttl time.Duration) (int64, bool, error) {
+	var (
+		id         int64
+		lookedUpAt time.Time
+	)
+	err := s.store.QueryRowContext(ctx, "SELECT id, looked_up_at FROM entities WHERE name_key = ? AND language = ?", key, language).Scan(&id, &lookedUpAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, ttl > 0 && time.Since(lookedUpAt) < ttl, nil
+}
+
+func (s *EntityService) saveEntity(ctx context.Context, key string, language string, found wikidataEntity) (int64, error) {
+	values := []any{
+		nullString(found.id),
+		nullString(found.label),
+		nullString(found.description),
+		nullString(found.wikipediaURL),
+		time.Now(),
+	}
+
+	var id int64
+	err := s.store.QueryRowContext(ctx, "SELECT id FROM entities WHERE name_key = ? AND language = ?", key, language).Scan(&id)
+	switch {
+	case err == nil:
+		_, err = s.store.ExecContext(
+			ctx,
+			"UPDATE entities SET wikidata_id = ?, label = ?, description = ?, wikipedia_url = ?, looked_up_at = ? WHERE id = ?",
+			append(values, id)...,
+		)
+		return id, err
+	case errors.Is(err, sql.ErrNoRows):
+		return s.store.Insert(
+			ctx,
+			"INSERT INTO entities (name_key, language, wikidata_id, label, description, wikipedia_url, looked_up_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			append([]any{key, language}, values...)...,
+		)
+	default:
+		return 0, err
+	}
+}
+
+// lookup searches Wikidata for name and takes the first item whose label or
+// alias is the name itself; disambiguation pages are skipped. The Wikipedia
+// link is in language when that Wikipedia has the article, else English.
+func (s *EntityService) lookup(ctx context.Context, cfg config.Config, name string, language string) (wikidataEntity, error) {
+	var search wikidataSearchResponse
+	err := s.wikidata(ctx, cfg, url.Values{
+		"action":   {"wbsearchentities"},
+		"search":   {name},
+		"language": {language},
+		"uselang":  {language},
+		"type":     {"item"},
+		"limit":    {fmt.Sprintf("%d", wikidataCandidates)},
+	}, &search)
+	if err != nil {
+		return wikidataEntity{}, err
+	}
+
+	var found wikidataEntity
+	for _, candidate := range search.Search {
+		if !strings.EqualFold(candidate.Match.Text, name) && !strings.EqualFold(candidate.Label, name) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(candidate.Description), "disambiguation page") {
+			continue
+		}
+		found = wikidataEntity{id: candidate.ID, label: candidate.Label, description: candidate.Description}
+		break
+	}
+	if found.id == "" {
+		return found, nil
+	}
+
+	var links wikidataSitelinksResponse
+	sites := slices.Compact([]string{language + "wiki", englishLanguage.Code + "wiki"})
+	err = s.wikidata(ctx, cfg, url.Values{
+		"action":     {"wbgetentities"},
+		"ids":        {found.id},
+		"props":      {"sitelinks/urls"},
+		"sitefilter": {strings.Join(sites, "|")},
+	}, &links)
+	if err != nil {
+		return found, err
+	}
+	for _, site := range sites {
+		if link := links.Entities[found.id].Sitelinks[site].URL; link != "" {
+			found.wikipediaURL = link
+			break
+		}
+	}
+	return found, nil
+}
+
+func (s *EntityService) wikidata(ctx context.Context, cfg config.Config, params url.Values, out any) error {
+	params.Set("format", "json")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.WikidataURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	// Wikimedia asks API clients to identify themselves.
+	request.Header.Set("User-Agent", cfg.FetchUserAgent)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("wikidata request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("wikidata returned status %d: %s", response.StatusCode, truncateRunes(string(body), 300))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("parse wikidata response: %w", err)
+	}
+	return nil
+}
+
+func listArticleEntities(ctx context.Context, store *repository.Store, articleID int64) ([]models.Entity, error) {
+	rows, err := store.QueryContext(
+		ctx,
+		`SELECT ae.name, ae.mentions, COALESCE(e.wikidata_id, ''), COALESCE(e.label, ''), COALESCE(e.description, ''), COALESCE(e.wikipedia_url, '')
+		FROM article_entities ae
+		JOIN entities e ON e.id = ae.entity_id
+		WHERE ae.article_id = ?
+		ORDER BY ae.position ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := make([]models.Entity, 0)
+	for rows.Next() {
+		var entity models.Entity
+		if err := rows.Scan(&entity.Name, &entity.Mentions, &entity.WikidataID, &entity.Label, &entity.Description, &entity.WikipediaURL); err != nil {
+			return nil, err
+		}
+		if entity.WikidataID != "" {
+			entity.WikidataURL = "https://www.wikidata.org/wiki/" + entity.WikidataID
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
+
+// entityMentions picks out runs of capitalised words, such as "Priya Sharma"
+// or "Bank of England", and short acronyms, most mentioned first. A single
+// capitalised word starting a sentence is too often an ordinary word to
+// count. A lone surname is counted toward the one longer name it ends.
+func entityMentions(lines []string) []entityMention {
+	counts := make(map[string]int)
+	order := make([]string, 0)
+	add := func(words []string) {
+		if len(words) > 1 && words[0] == "The" {
+			words = words[1:]
+		}
+		for len(words) > 0 {
+			if _, connector := entityConnectors[words[len(words)-1]]; !connector {
+				break
+			}
+			words = words[:len(words)-1]
+		}
+		if len(words) == 0 {
+			return
+		}
+		name := strings.Join(words, " ")
+		if _, ok := counts[name]; !ok {
+			order = append(order, name)
+		}
+		counts[name]++
+	}
+
+	for _, line := range lines {
+		var run []string
+		runAtStart := false
+		sentenceStart := true
+		for _, field := range strings.Fields(line) {
+			word := strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+			word = strings.TrimSuffix(strings.TrimSuffix(word, "'s"), "’s")
+			first, _ := utf8.DecodeRuneInString(word)
+
+			switch {
+			case word == "":
+			case unicode.IsUpper(first) && !isCalendarName(word) && (utf8.RuneCountInString(word) >= 3 || isAcronym(word)):
+				if len(run) == 0 {
+					runAtStart = sentenceStart
+				}
+				run = append(run, word)
+			case len(run) > 0 && isEntityConnector(word):
+				run = append(run, word)
+			default:
+				if len(run) > 1 || (len(run) == 1 && !runAtStart) {
+					add(run)
+				}
+				run = nil
+			}
+
+			last, _ := utf8.DecodeLastRuneInString(strings.TrimRight(field, `"'”’)`))
+			sentenceStart = isSentenceEnd(last)
+			// Punctuation after a word ends the name it belongs to.
+			if len(run) > 0 && (sentenceStart || strings.ContainsAny(field[len(field)-1:], ",;:)")) {
+				if len(run) > 1 || !runAtStart {
+					add(run)
+				}
+				run = nil
+			}
+		}
+		if len(run) > 1 || (len(run) == 1 && !runAtStart) {
+			add(run)
+		}
+	}
+
+	for _, name := range order {
+		if strings.Contains(name, " ") {
+			continue
+		}
+		var longer string
+		for _, other := range order {
+			if other != name && strings.HasSuffix(other, " "+name) {
+				if longer != "" {
+					longer = ""
+					break
+				}
+				longer = other
+			}
+		}
+		if longer != "" {
+			counts[longer] += counts[name]
+			delete(counts, name)
+		}
+	}
+
+	mentions := make([]entityMention, 0, len(counts))
+	for _, name := range order {
+		if count, ok := counts[name]; ok {
+			mentions = append(mentions, entityMention{name: name, mentions: count})
+		}
+	}
+	slices.SortStableFunc(mentions, func(a, b entityMention) int { return b.mentions - a.mentions })
+	return mentions
+}
+
+func isAcronym(word string) bool {
+	length := utf8.RuneCountInString(word)
+	return length >= 2 && length <= 6 && strings.IndexFunc(word, func(r rune) bool { return !unicode.IsUpper(r) }) < 0
+}
+
+func isEntityConnector(word string) bool {
+	_, ok := entityConnectors[word]
+	return ok
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"nanoheads/models"
+)
+
+// CodedError carries the API error code for a failure decided where it
+// happens, such as bad input or a URL that could not be fetched.
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// WithCode tags err with code; a nil err stays nil.
+func WithCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+func invalidInput(message string) error {
+	return WithCode(models.ErrorCodeInvalidRequest, errors.New(message))
+}
+
+func invalidInputf(format string, args ...any) error {
+	return WithCode(models.ErrorCodeInvalidRequest, fmt.Errorf(format, args...))
+}
+
+func notConfigured(message string) error {
+	return WithCode(models.ErrorCodeNotConfigured, errors.New(message))
+}
+
+func emptyFacts(message string) error {
+	return WithCode(models.ErrorCodeEmptyFacts, errors.New(message))
+}
+
+var errMissingAPIKey = notConfigured("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+
+// ErrorCode returns the API error code for err: the code it was tagged with,
+// or the one its type implies. Anything else is models.ErrorCodeInternal.
+func ErrorCode(err error) string {
+	var (
+		coded       *CodedError
+		unavailable *ProviderUnavailableError
+		conflict    *VersionConflictError
+		refused     *fetchRefusedError
+		apiErr      *apiRequestError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &coded):
+		return coded.Code
+	case errors.As(err, &unavailable):
+		return models.ErrorCodeProviderUnavailable
+	case errors.As(err, &conflict):
+		return models.ErrorCodeVersionConflict
+	case errors.Is(err, ErrNothingToUndo):
+		return models.ErrorCodeNothingToUndo
+	case errors.Is(err, ErrNothingToRedo):
+		return models.ErrorCodeNothingToRedo
+	case errors.Is(err, sql.ErrNoRows):
+		return models.ErrorCodeNotFound
+	case errors.As(err, &refused):
+		return models.ErrorCodeInvalidRequest
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+		return models.ErrorCodeProviderQuota
+	default:
+		return models.ErrorCodeInternal
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+)
+
+const (
+	errorReportTimeout = 10 * time.Second
+	// Reports beyond this many waiting to be sent are dropped, so an outage
+	// of the reporting service can't pile up memory.
+	errorReportBacklog = 100
+)
+
+// errorScope is what a request knows about itself, carried in its context so
+// errors reported deeper down are tagged with it.
+type errorScope struct {
+	requestID string
+	tags      map[string]string
+}
+
+type errorScopeKey struct{}
+
+// WithErrorScope tags the errors reported under ctx with requestID and tags.
+func WithErrorScope(ctx context.Context, requestID string, tags map[string]string) context.Context {
+	return context.WithValue(ctx, errorScopeKey{}, errorScope{requestID: requestID, tags: tags})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	scope, _ := ctx.Value(errorScopeKey{}).(errorScope)
+	return scope.requestID
+}
+
+type errorReporter struct {
+	endpoint  string
+	dsn       string
+	publicKey string
+	client    *http.Client
+	events    chan []byte
+}
+
+var (
+	errorReporterOnce sync.Once
+	sharedReporter    *errorReporter
+)
+
+// reporter returns the reporter for SENTRY_DSN, or nil with reporting off.
+func reporter() *errorReporter {
+	errorReporterOnce.Do(func() {
+		dsn := config.Current().SentryDSN
+		if dsn == "" {
+			return
+		}
+		parsed, err := url.Parse(dsn)
+		if err != nil || parsed.User == nil {
+			log.Printf("[errors] ignoring SENTRY_DSN: it must look like https://<key>@<host>/<project>")
+			return
+		}
+		prefix, project := "", strings.Trim(parsed.Path, "/")
+		if idx := strings.LastIndex(project, "/"); idx >= 0 {
+			prefix, project = "/"+project[:idx], project[idx+1:]
+		}
+		sharedReporter = &errorReporter{
+			endpoint:  fmt.Sprintf("%s://%s%s/api/%s/envelope/", parsed.Scheme, parsed.Host, prefix, project),
+			dsn:       dsn,
+			publicKey: parsed.User.Username(),
+			client:    &http.Client{Timeout: errorReportTimeout},
+			events:    make(chan []byte, errorReportBacklog),
+		}
+		go sharedReporter.send()
+	})
+	return sharedReporter
+}
+
+// ReportError sends err to the error reporting service, if one is set up,
+// with the request's scope from ctx and tags. It never blocks the caller.
+func ReportError(ctx context.Context, err error, tags map[string]string) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	report(ctx, "error", fmt.Sprintf("%T", unwrapAll(err)), err.Error(), "", tags)
+}
+
+// ReportPanic sends a recovered panic with the stack it was raised on.
+func ReportPanic(ctx context.Context, recovered any, stack []byte, tags map[string]string) {
+	report(ctx, "fatal", "panic", fmt.Sprint(recovered), string(stack), tags)
+}
+
+func report(ctx context.Context, level string, kind string, message string, stack string, tags map[string]string) {
+	r := reporter()
+	if r == nil {
+		return
+	}
+
+	scope, _ := ctx.Value(errorScopeKey{}).(errorScope)
+	merged := make(map[string]string, len(scope.tags)+len(tags)+1)
+	maps.Copy(merged, scope.tags)
+	maps.Copy(merged, tags)
+	if scope.requestID != "" {
+		merged["request_id"] = scope.requestID
+	}
+
+	eventID := newEventID()
+	event := map[string]any{
+		"event_id":  eventID,
+		"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+		"platform":  "go",
+		"level":     level,
+		"logger":    "nanoheads",
+		"exception": map[string]any{
+			"values": []map[string]any{{"type": kind, "value": redactSensitive(message)}},
+		},
+		"tags": merged,
+	}
+	if host, err := os.Hostname(); err == nil {
+		event["server_name"] = host
+	}
+	if environment := config.Current().SentryEnvironment; environment != "" {
+		event["environment"] = environment
+	}
+	if stack != "" {
+		event["extra"] = map[string]string{"stack": stack}
+	}
+
+	body, err := r.envelope(eventID, event)
+	if err != nil {
+		log.Printf("[errors] failed to encode report: %v", err)
+		return
+	}
+	select {
+	case r.events <- body:
+	default:
+		log.Printf("[errors] report backlog full, dropping %s", eventID)
+	}
+}
+
+// envelope wraps event in Sentry's envelope format: a header line, an item
+// header line and the event, each JSON.
+func (r *errorReporter) envelope(eventID string, event map[string]any) ([]byte, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	if err := encoder.Encode(map[string]string{"event_id": eventID, "dsn": r.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339)}); err != nil {
+		return nil, err
+	}
+	if err := encoder.Encode(map[string]string{"type": "event"}); err != nil {
+		return nil, err
+	}
+	if err := encoder.Encode(event); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func (r *errorReporter) send() {
+	for body := range r.events {
+		req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("[errors] failed to build report request: %v", err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/x-sentry-envelope")
+		req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=nanoheads/1.0, sentry_key="+r.publicKey)
+		resp, err := r.client.Do(req)
+		if err != nil {
+			log.Printf("[errors] failed to send report: %v", err)
+			continue
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("[errors] reporting service answered %d", resp.StatusCode)
+		}
+	}
+}
+
+func newEventID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// unwrapAll finds the innermost error, whose type says more than a wrapper's.
+func unwrapAll(err error) error {
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return err
+		}
+		err = inner
+	}
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// A bundle holds at most this many analyses; a range with more has to be
+// split so the file stays a reasonable download.
+const maxBundleAnalyses = 500
+
+//go:embed templates/bundle.html
+var bundleHTML string
+
+var bundleTemplate = template.Must(template.New("bundle").Parse(bundleHTML))
+
+type ExportService struct {
+	store *repository.Store
+}
+
+func NewExportService(database *sql.DB) *ExportService {
+	return &ExportService{store: repository.New(database)}
+}
+
+type bundleItem struct {
+	ID        int64
+	Headline  string
+	Strapline string
+	Category  string
+	Completed string
+	SourceURL string
+	Sections  []bundleSection
+
+	Corrections []bundleCorrection
+}
+
+type bundleCorrection struct {
+	Date          string
+	Mistake       string
+	CorrectedText string
+}
+
+type bundleSection struct {
+	Heading    string
+	Paragraphs []string
+}
+
+type bundleDocument struct {
+	Title  string
+	Period string
+	Items  []bundleItem
+}
+
+// Bundle renders the analyses completed between from and to, both dates
+// inclusive and in UTC, as one Markdown or HTML file. categories, when
+// given, limit it to those categories; "Uncategorized" matches analyses
+// without one.
+func (s *ExportService) Bundle(ctx context.Context, from string, to string, categories []string, format string) (models.ExportBundle, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" || format == "md" {
+		format = models.BundleFormatMarkdown
+	}
+	if format != models.BundleFormatMarkdown && format != models.BundleFormatHTML {
+		return models.ExportBundle{}, invalidInputf("format must be %q or %q", models.BundleFormatMarkdown, models.BundleFormatHTML)
+	}
+
+	start, err := time.Parse(time.DateOnly, strings.TrimSpace(from))
+	if err != nil {
+		return models.ExportBundle{}, invalidInput("from must be a date like 2006-01-02")
+	}
+	end := calendarDay(time.Now())
+	if strings.TrimSpace(to) != "" {
+		if end, err = time.Parse(time.DateOnly, strings.TrimSpace(to)); err != nil {
+			return models.ExportBundle{}, invalidInput("to must be a date like 2006-01-02")
+		}
+	}
+	if end.Before(start) {
+		return models.ExportBundle{}, invalidInput("to must not be before from")
+	}
+	if end.Sub(start) >= maxAnalyticsDays*24*time.Hour {
+		return models.ExportBundle{}, invalidInputf("a bundle can cover at most %d days", maxAnalyticsDays)
+	}
+
+	items, err := s.completedAnalyses(ctx, start, end.AddDate(0, 0, 1), categories)
+	if err != nil {
+		return models.ExportBundle{}, err
+	}
+	if len(items) == 0 {
+		return models.ExportBundle{}, invalidInput("no analyses were completed in that range")
+	}
+
+	document := bundleDocument{
+		Title:  "NanoHeads analyses",
+		Period: start.Format("2 January 2006"),
+		Items:  items,
+	}
+	if !end.Equal(start) {
+		document.Period += " to " + end.Format("2 January 2006")
+	}
+	name := fmt.Sprintf("analyses-%s-to-%s", start.Format(time.DateOnly), end.Format(time.DateOnly))
+
+	if format == models.BundleFormatHTML {
+		var body bytes.Buffer
+		if err := bundleTemplate.Execute(&body, document); err != nil {
+			return models.ExportBundle{}, err
+		}
+		return models.ExportBundle{Filename: name + ".html", ContentType: "text/html; charset=utf-8", Body: body.Bytes(), Analyses: len(items)}, nil
+	}
+	return models.ExportBundle{Filename: name + ".md", ContentType: "text/markdown; charset=utf-8", Body: []byte(bundleMarkdown(document)), Analyses: len(items)}, nil
+}
+
+func (s *ExportService) completedAnalyses(ctx context.Context, start time.Time, end time.Time, categories []string) ([]bundleItem, error) {
+	conditions := []string{
+		"a.status = 'completed'",
+		"a.merged_into IS NULL",
+		"a.completed_at >= ?",
+		"a.completed_at < ?",
+	}
+	args := []any{start, end}
+
+	names := make([]any, 0, len(categories))
+	uncategorized := false
+	for _, category := range categories {
+		category = strings.TrimSpace(category)
+		switch {
+		case category == "":
+		case strings.EqualFold(category, "Uncategorized"):
+			uncategorized = true
+		default:
+			names = append(names, strings.ToLower(category))
+		}
+	}
+	switch {
+	case len(names) > 0 && uncategorized:
+		conditions = append(conditions, "(a.topic_id IS NULL OR LOWER(t.name) IN ("+repository.Placeholders(len(names))+"))")
+		args = append(args, names...)
+	case len(names) > 0:
+		conditions = append(conditions, "LOWER(t.name) IN ("+repository.Placeholders(len(names))+")")
+		args = append(args, names...)
+	case uncategorized:
+		conditions = append(conditions, "a.topic_id IS NULL")
+	}
+
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT a.id, COALESCE(a.headline_selected, ''), COALESCE(a.strapline_selected, ''), COALESCE(t.name, 'Uncategorized'),
+			a.completed_at, COALESCE(a.source_url, ''), COALESCE(a.article_text, ''), a.article_sections
+		FROM articles a
+		LEFT JOIN topics t ON t.id = a.topic_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY a.completed_at, a.id
+		LIMIT ?`, append(args, maxBundleAnalyses+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]bundleItem, 0)
+	for rows.Next() {
+		var (
+			item        bundleItem
+			headline    string
+			completedAt time.Time
+			articleText string
+			sections    sql.NullString
+		)
+		if err := rows.Scan(&item.ID, &headline, &item.Strapline, &item.Category, &completedAt, &item.SourceURL, &articleText, &sections); err != nil {
+			return nil, err
+		}
+		item.Headline = buildAnalysisTitle(item.ID, headline, item.SourceURL, "")
+		if !strings.HasPrefix(item.SourceURL, "http://") && !strings.HasPrefix(item.SourceURL, "https://") {
+			item.SourceURL = ""
+		}
+		item.Strapline = strings.TrimSpace(item.Strapline)
+		item.Completed = completedAt.UTC().Format("2 January 2006")
+		item.Sections = bundleSections(item.ID, articleText, sections)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) > maxBundleAnalyses {
+		return nil, invalidInputf("more than %d analyses were completed in that range; export a shorter range", maxBundleAnalyses)
+	}
+
+	ids := make([]int64, len(items))
+	for idx, item := range items {
+		ids[idx] = item.ID
+	}
+	corrections, err := correctionsByArticle(ctx, s.store, ids)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range items {
+		for _, correction := range corrections[items[idx].ID] {
+			items[idx].Corrections = append(items[idx].Corrections, bundleCorrection{
+				Date:          correction.CreatedAt.UTC().Format("2 January 2006"),
+				Mistake:       strings.Join(strings.Fields(correction.Mistake), " "),
+				CorrectedText: strings.Join(strings.Fields(correction.CorrectedText), " "),
+			})
+		}
+	}
+	return items, nil
+}
+
+// bundleSections splits an article into its long-form sections, or one
+// untitled section for a paragraph article.
+func bundleSections(articleID int64, articleText string, raw sql.NullString) []bundleSection {
+	return articleBlocks(articleText, decodeArticleSections(articleID, raw, articleText))
+}
+
+// articleBlocks is an article as headed runs of paragraphs. The lede keeps
+// no heading, as it does in the editor.
+func articleBlocks(articleText string, sections []models.ArticleSection) []bundleSection {
+	if len(sections) == 0 {
+		if paragraphs := bundleParagraphs(articleText); len(paragraphs) > 0 {
+			return []bundleSection{{Paragraphs: paragraphs}}
+		}
+		return nil
+	}
+	out := make([]bundleSection, 0, len(sections))
+	for _, section := range sections {
+		heading := section.Heading
+		if section.Key == models.SectionLede {
+			heading = ""
+		}
+		out = append(out, bundleSection{Heading: heading, Paragraphs: bundleParagraphs(section.Body)})
+	}
+	return out
+}
+
+func bundleParagraphs(text string) []string {
+	paragraphs := make([]string, 0)
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, strings.Join(strings.Fields(paragraph), " "))
+		}
+	}
+	return paragraphs
+}
+
+func bundleMarkdown(document bundleDocument) string {
+	var out strings.Builder
+	out.WriteString("# " + document.Title + "\n\n")
+	out.WriteString(fmt.Sprintf("%s · %d %s\n", document.Period, len(document.Items), pluralAnalyses(len(document.Items))))
+	for _, item := range document.Items {
+		out.WriteString("\n---\n\n## " + markdownEscape(item.Headline) + "\n\n")
+		if item.Strapline != "" {
+			out.WriteString("_" + markdownEscape(item.Strapline) + "_\n\n")
+		}
+		meta := markdownEscape(item.Category) + " · " + item.Completed
+		if item.SourceURL != "" {
+			meta += " · [Source](<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(item.SourceURL) + ">)"
+		}
+		out.WriteString(meta + "\n")
+		for _, section := range item.Sections {
+			if section.Heading != "" {
+				out.WriteString("\n### " + markdownEscape(section.Heading) + "\n")
+			}
+			for _, paragraph := range section.Paragraphs {
+				out.WriteString("\n" + markdownEscape(paragraph) + "\n")
+			}
+		}
+		if len(item.Corrections) > 0 {
+			out.WriteString("\n### Corrections\n\n")
+			for _, correction := range item.Corrections {
+				out.WriteString("- **" + correction.Date + ":** " + markdownEscape(correction.Mistake) + " Corrected: " + markdownEscape(correction.CorrectedText) + "\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+func pluralAnalyses(count int) string {
+	if count == 1 {
+		return "analysis"
+	}
+	return "analyses"
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+// markdownEscape keeps generated text from being read as Markdown syntax,
+// such as a headline starting with "#" or a fact with asterisks.
+func markdownEscape(text string) string {
+	return markdownEscaper.Replace(text)
+}
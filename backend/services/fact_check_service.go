@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	factCheckPageSize   = 5
+	factCheckQueryRunes = 300
+)
+
+type FactCheckService struct {
+	store  *repository.Store
+	client *http.Client
+}
+
+type factCheckPayload struct {
+	ArticleID int64 `json:"articleId"`
+}
+
+type factCheckSearchResponse struct {
+	Claims []struct {
+		Text        string `json:"text"`
+		Claimant    string `json:"claimant"`
+		ClaimDate   string `json:"claimDate"`
+		ClaimReview []struct {
+			Publisher struct {
+				Name string `json:"name"`
+				Site string `json:"site"`
+			} `json:"publisher"`
+			URL           string `json:"url"`
+			Title         string `json:"title"`
+			ReviewDate    string `json:"reviewDate"`
+			TextualRating string `json:"textualRating"`
+			LanguageCode  string `json:"languageCode"`
+		} `json:"claimReview"`
+	} `json:"claims"`
+}
+
+func NewFactCheckService(database *sql.DB) *FactCheckService {
+	return &FactCheckService{
+		store:  repository.New(database),
+		client: &http.Client{Timeout: config.Current().FactCheckTimeout.Duration},
+	}
+}
+
+func factChecksEnabled() bool {
+	return config.Current().FactCheckAPIKey != ""
+}
+
+// EnqueueFactCheck queues a lookup of an analysis's facts against published
+// fact-checks.
+func EnqueueFactCheck(ctx context.Context, jobs *JobService, articleID int64, createdBy *int64) (models.Job, error) {
+	if !factChecksEnabled() {
+		return models.Job{}, notConfigured("FACT_CHECK_API_KEY is required for fact checks")
+	}
+
+	var exists int
+	if err := jobs.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.Job{}, err
+	}
+	return jobs.Enqueue(ctx, models.JobTypeFactCheck, factCheckPayload{ArticleID: articleID}, createdBy)
+}
+
+// CheckArticle searches for fact-checks of every fact in the analysis and
+// replaces the stored matches. A fact whose lookup fails keeps no matches; the
+// run only fails when every lookup does.
+func (s *FactCheckService) CheckArticle(ctx context.Context, articleID int64, report func(int, int)) (models.FactCheckResult, error) {
+	if !factChecksEnabled() {
+		return models.FactCheckResult{}, notConfigured("FACT_CHECK_API_KEY is required for fact checks")
+	}
+
+	var exists int
+	if err := s.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.FactCheckResult{}, err
+	}
+
+	rows, err := s.store.QueryContext(ctx, "SELECT id, COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL ORDER BY position ASC, id ASC", articleID)
+	if err != nil {
+		return models.FactCheckResult{}, err
+	}
+	type factRow struct {
+		id   int64
+		text string
+	}
+	facts := make([]factRow, 0)
+	for rows.Next() {
+		var fact factRow
+		if err := rows.Scan(&fact.id, &fact.text); err != nil {
+			rows.Close()
+			return models.FactCheckResult{}, err
+		}
+		if strings.TrimSpace(fact.text) != "" {
+			facts = append(facts, fact)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.FactCheckResult{}, err
+	}
+
+	result := models.FactCheckResult{ArticleID: articleID}
+	matches := make([]models.FactCheckMatch, 0)
+	var lastErr error
+	report(0, len(facts))
+	for idx, fact := range facts {
+		found, err := s.search(ctx, fact.text)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			log.Printf("[fact-check] article %d fact %d: %v", articleID, fact.id, err)
+			result.Failed++
+			lastErr = err
+			report(idx+1, len(facts))
+			continue
+		}
+
+		result.Checked++
+		if len(found) > 0 {
+			result.Matched++
+		}
+		for _, match := range found {
+			match.FactID = fact.id
+			matches = append(matches, match)
+		}
+		report(idx+1, len(facts))
+	}
+	if len(facts) > 0 && result.Checked == 0 {
+		return result, fmt.Errorf("every fact check lookup failed: %w", lastErr)
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM fact_check_matches WHERE article_id = ?", articleID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO fact_check_matches (
+				article_id, fact_id, claim_text, claimant, claim_date, publisher, publisher_site,
+				review_url, review_title, rating, language_code, review_date
+			) VALUES (` + repository.Placeholders(12) + `)`
+		for _, match := range matches {
+			if _, err := tx.ExecContext(
+				ctx,
+				query,
+				articleID,
+				match.FactID,
+				nullString(match.ClaimText),
+				nullString(match.Claimant),
+				nullString(match.ClaimDate),
+				nullString(match.Publisher),
+				nullString(match.PublisherSite),
+				match.URL,
+				nullString(match.Title),
+				nullString(match.Rating),
+				nullString(match.Language),
+				nullString(match.ReviewDate),
+			); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE articles SET fact_checked_at = CURRENT_TIMESTAMP WHERE id = ?", articleID)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Matches = len(matches)
+	return result, nil
+}
+
+func (s *FactCheckService) search(ctx context.Context, claim string) ([]models.FactCheckMatch, error) {
+	cfg := config.Current()
+
+	endpoint, err := url.Parse(cfg.FactCheckURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FACT_CHECK_URL: %w", err)
+	}
+	query := endpoint.Query()
+	query.Set("query", truncateRunes(claim, factCheckQueryRunes))
+	query.Set("pageSize", fmt.Sprintf("%d", factCheckPageSize))
+	query.Set("key", cfg.FactCheckAPIKey)
+	endpoint.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		// url.Error repeats the request URL, which carries the API key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("fact check request failed: %w", urlErr.Err)
+		}
+		return nil, fmt.Errorf("fact check request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("fact check api returned status %d: %s", response.StatusCode, redactSensitive(truncateRunes(string(body), 300)))
+	}
+
+	var out factCheckSearchResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("parse fact check response: %w", err)
+	}
+
+	matches := make([]models.FactCheckMatch, 0)
+	seen := make(map[string]struct{})
+	for _, claim := range out.Claims {
+		for _, review := range claim.ClaimReview {
+			reviewURL := strings.TrimSpace(review.URL)
+			if reviewURL == "" {
+				continue
+			}
+			if _, ok := seen[reviewURL]; ok {
+				continue
+			}
+			seen[reviewURL] = struct{}{}
+
+			matches = append(matches, models.FactCheckMatch{
+				ClaimText:     strings.TrimSpace(claim.Text),
+				Claimant:      strings.TrimSpace(claim.Claimant),
+				ClaimDate:     strings.TrimSpace(claim.ClaimDate),
+				Publisher:     strings.TrimSpace(review.Publisher.Name),
+				PublisherSite: strings.TrimSpace(review.Publisher.Site),
+				URL:           reviewURL,
+				Title:         strings.TrimSpace(review.Title),
+				Rating:        strings.TrimSpace(review.TextualRating),
+				Language:      strings.TrimSpace(review.LanguageCode),
+				ReviewDate:    strings.TrimSpace(review.ReviewDate),
+			})
+		}
+	}
+	return matches, nil
+}
@@ -3,342 +3,612 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
-	"io"
 	"log"
-	"net/http"
-	"net/url"
 	"regexp"
 	"strings"
-	"time"
 
-	"nanoheads/db"
+	"golang.org/x/sync/errgroup"
+
+	"nanoheads/config"
 	"nanoheads/models"
+	"nanoheads/repository"
 )
 
 type FactService struct {
-	database *sql.DB
+	store    *repository.Store
 	ai       *OpenAIService
+	llmCalls *LLMCallService
+	glossary *GlossaryService
+	jobs     *JobService
+}
+
+// phaseOneTranslation keeps the English originals of translated content so the
+// pairs can be stored and re-translated when the glossary changes.
+type phaseOneTranslation struct {
+	language        string
+	glossaryVersion int
+	facts           []string
+	gaps            []string
+	article         string
 }
 
 func NewFactService(database *sql.DB) *FactService {
+	llmCalls := NewLLMCallService(database)
+	ai := NewOpenAIService()
+	ai.SetRecorder(llmCalls)
+	ai.SetCache(NewLLMCacheService(database))
+
 	return &FactService{
-		database: database,
-		ai:       NewOpenAIService(),
+		store:    repository.New(database),
+		ai:       ai,
+		llmCalls: llmCalls,
+		glossary: NewGlossaryService(database),
+		jobs:     NewJobService(database),
 	}
 }
 
-func (s *FactService) RunPhaseOne(ctx context.Context, input models.PhaseOneInput) (models.PhaseOneResponse, error) {
-	if s.database == nil {
+func (s *FactService) RunPhaseOne(ctx context.Context, input models.PhaseOneInput) (response models.PhaseOneResponse, err error) {
+	if s.store.DB() == nil {
 		return models.PhaseOneResponse{}, errors.New("database is not initialized")
 	}
 
-	if err := s.applyRuntimeAISettings(ctx); err != nil {
-		return models.PhaseOneResponse{}, err
-	}
-
-	rawText, sourceURL, err := s.resolveInput(ctx, input)
+	articleMode, err := NormalizeArticleMode(input.ArticleMode)
 	if err != nil {
 		return models.PhaseOneResponse{}, err
 	}
-
-	outputLanguage := normalizeOutputLanguage(input.Language, rawText)
-	generationLanguage := stableGenerationLanguage(outputLanguage)
-	factsInput := compactLLMInput(rawText)
-
-	facts, err := s.ai.ExtractFacts(ctx, factsInput, generationLanguage)
-	if err != nil {
+	if err := ValidateLengthTargets(input.Lengths); err != nil {
 		return models.PhaseOneResponse{}, err
 	}
 
-	gaps, err := s.ai.GenerateGapQuestions(ctx, facts, generationLanguage)
-	if err != nil {
+	if err := s.applyRuntimeAISettings(ctx); err != nil {
 		return models.PhaseOneResponse{}, err
 	}
 
-	articleText, err := s.ai.GenerateStructuredArticle(ctx, facts, gaps, generationLanguage)
+	runID := newLLMRunID()
+	ctx = withLLMRunID(ctx, runID)
+	ctx = withLengthTargets(ctx, input.Lengths)
+
+	activePrompts, err := loadPromptSet(ctx, s.store)
 	if err != nil {
-		return models.PhaseOneResponse{}, err
+		log.Printf("[prompts] failed to load active prompts, using built-in defaults: %v", err)
+	} else {
+		ctx = withPromptSet(ctx, activePrompts)
 	}
 
-	if outputLanguage != generationLanguage {
-		facts, err = s.ai.TranslateList(ctx, facts, outputLanguage)
+	var (
+		rawText       string
+		sourceURL     string
+		fetchStrategy string
+		snapshots     []sourceSnapshot
+		multiple      *corroboration
+		images        []resolvedImage
+		screened      []resolvedSource
+	)
+	if len(input.Images) > 0 {
+		if len(input.Sources) > 1 {
+			return models.PhaseOneResponse{}, invalidInput("images cannot be combined with multiple sources")
+		}
+		images, err = s.readImages(ctx, input.Images)
 		if err != nil {
 			return models.PhaseOneResponse{}, err
 		}
-
-		gaps, err = s.ai.TranslateList(ctx, gaps, outputLanguage)
+		input.Text = imageInputText(images, input.Text)
+	}
+	if len(input.Sources) > 1 {
+		multiple, err = s.resolveSources(ctx, input.Sources, input.Render)
 		if err != nil {
 			return models.PhaseOneResponse{}, err
 		}
-
-		articleText, err = s.ai.TranslateText(ctx, articleText, outputLanguage)
+		maskSources(multiple.sources)
+		rawText, sourceURL, fetchStrategy = multiple.rawText(), multiple.sourceURL(), multiple.fetchStrategy()
+		snapshots = snapshotsOf(multiple.sources...)
+		screened = multiple.sources
+	} else {
+		source, err := s.resolveInput(ctx, input)
 		if err != nil {
 			return models.PhaseOneResponse{}, err
 		}
+		sources := []resolvedSource{source}
+		maskSources(sources)
+		source = sources[0]
+		rawText, sourceURL, fetchStrategy = source.text, source.url, source.fetchStrategy
+		snapshots = snapshotsOf(source)
+		screened = []resolvedSource{source}
+	}
+	// Text read from images has already been paid for, and reads oddly
+	// enough to trip the gibberish check.
+	if len(images) == 0 {
+		if err := screenSources(ctx, s.store, screened); err != nil {
+			return models.PhaseOneResponse{}, err
+		}
 	}
 
-	headlines, err := s.ai.GenerateHeadlineOptions(ctx, facts, articleText, outputLanguage)
+	languages, err := loadLanguages(ctx, s.store)
 	if err != nil {
-		log.Printf("[headlines] generation failed, using fallback: %v", err)
-		headlines = fallbackHeadlines(facts, articleText)
+		return models.PhaseOneResponse{}, err
 	}
-
-	straplines, err := s.ai.GenerateStraplineOptions(ctx, facts, gaps, articleText, outputLanguage)
+	detected := s.detectInputLanguage(ctx, languages, rawText)
+	output, err := pickOutputLanguage(languages, input.Language, detected)
 	if err != nil {
-		log.Printf("[straplines] generation failed, using fallback: %v", err)
-		straplines = fallbackStraplines(gaps, articleText)
+		return models.PhaseOneResponse{}, err
 	}
 
-	articleID, err := s.savePhaseOne(ctx, sourceURL, rawText, articleText, input.Category, facts, gaps, headlines, straplines)
-	if err != nil {
+	run := &phaseOneRun{
+		checkpoint: pipelineCheckpoint{
+			Category:       input.Category,
+			ArticleMode:    articleMode,
+			Lengths:        input.Lengths,
+			OutputLanguage: output.Name,
+		},
+		multiple:           multiple,
+		generationLanguage: generationLanguageFor(output),
+		factsInput:         compactLLMInput(rawText),
+		sourceURL:          sourceURL,
+		fetchStrategy:      fetchStrategy,
+		snapshots:          snapshots,
+		rawText:            rawText,
+		submission:         input.Submission,
+		detected:           detected,
+		images:             images,
+	}
+
+	// Input problems are the submitter's to fix; only failures once
+	// generation starts go to the newsroom channels, and only those leave a
+	// failed analysis behind to retry.
+	defer func() {
+		if err != nil && ctx.Err() == nil {
+			announcePhaseOneFailure(sourceURL, err)
+		}
+	}()
+	defer func() {
+		if err != nil {
+			err = s.recordFailure(ctx, runID, run, err)
+		}
+	}()
+
+	if err := s.generate(ctx, run); err != nil {
 		return models.PhaseOneResponse{}, err
 	}
+	return s.finish(ctx, runID, run, activePrompts.versions())
+}
 
-	return models.PhaseOneResponse{
-		ArticleID: articleID,
-		Language:  outputLanguage,
-		Facts:     facts,
-		Gaps:      gaps,
-		Article:   articleText,
-	}, nil
+// generateTitles falls back to titles derived from the facts and gaps when
+// generation fails; a missing headline is no reason to lose the analysis.
+// Headlines and straplines don't depend on each other and are generated at
+// the same time.
+func (s *FactService) generateTitles(ctx context.Context, facts []string, gaps []string, articleText string, language string) ([]string, []string) {
+	ctx = s.withHeadlineGuidance(ctx)
+	var headlines, straplines []string
+	var group errgroup.Group
+	group.SetLimit(config.Current().LLMParallelism)
+	group.Go(func() error {
+		headlines = s.generateTitleOptions(ctx, titleKindHeadline, func(ctx context.Context) ([]string, error) {
+			return s.ai.GenerateHeadlineOptions(ctx, facts, articleText, language)
+		}, func() []string {
+			return fallbackHeadlines(facts, articleText)
+		})
+		return nil
+	})
+	group.Go(func() error {
+		straplines = s.generateTitleOptions(ctx, titleKindStrapline, func(ctx context.Context) ([]string, error) {
+			return s.ai.GenerateStraplineOptions(ctx, facts, gaps, articleText, language)
+		}, func() []string {
+			return fallbackStraplines(gaps, articleText)
+		})
+		return nil
+	})
+	_ = group.Wait()
+	return headlines, straplines
 }
 
-func (s *FactService) resolveInput(ctx context.Context, input models.PhaseOneInput) (string, string, error) {
-	text := strings.TrimSpace(input.Text)
-	sourceURL := strings.TrimSpace(input.URL)
+// generateTitleOptions drops the options using a banned word set to
+// regenerate, asking once more when that leaves none. If the retry doesn't
+// help, the first options are kept and the style guide fixes or flags them.
+func (s *FactService) generateTitleOptions(ctx context.Context, kind string, generate func(context.Context) ([]string, error), fallback func() []string) []string {
+	options := s.titleOptionsToLength(ctx, kind, generate, fallback)
+	rules := s.regenerateRules(ctx)
+	if clean := withoutBannedTerms(rules, options); len(clean) > 0 || len(options) == 0 {
+		return clean
+	}
 
-	if text != "" {
-		return text, sourceURL, nil
+	terms := bannedTermsIn(rules, options...)
+	log.Printf("[style] every %s used banned terms, retrying", kind)
+	retried := s.titleOptionsToLength(withBannedTermFeedback(ctx, terms), kind, generate, fallback)
+	if clean := withoutBannedTerms(rules, retried); len(clean) > 0 {
+		return clean
 	}
-	if sourceURL == "" {
-		return "", "", errors.New("provide either text or url")
+	return options
+}
+
+func withoutBannedTerms(rules []models.StyleRule, options []string) []string {
+	clean := make([]string, 0, len(options))
+	for _, option := range options {
+		if len(bannedTermsIn(rules, option)) == 0 {
+			clean = append(clean, option)
+		}
 	}
+	return clean
+}
 
-	parsedURL, err := url.ParseRequestURI(sourceURL)
+// titleOptionsToLength keeps the options that meet the run's length target
+// for kind, asking once more when none do. If the retry doesn't help either,
+// every option is kept for the editor to trim.
+func (s *FactService) titleOptionsToLength(ctx context.Context, kind string, generate func(context.Context) ([]string, error), fallback func() []string) []string {
+	options, err := generate(ctx)
 	if err != nil {
-		return "", "", errors.New("url is invalid")
+		log.Printf("[%ss] generation failed, using fallback: %v", kind, err)
+		return normalizeGeneratedTitles(kind, fallback())
+	}
+	options = normalizeGeneratedTitles(kind, options)
+
+	target := lengthTargetFor(ctx, kind)
+	if target.IsZero() || len(options) == 0 {
+		return options
+	}
+	if within := withinLength(options, target); len(within) > 0 {
+		return within
 	}
 
-	fetchedText, err := s.fetchURLText(ctx, parsedURL.String())
+	feedback := fmt.Sprintf("Your previous %ss %s.", kind, strings.Join(lengthProblems(options[0], target), " and "))
+	retried, err := generate(withLengthFeedback(ctx, feedback))
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read url content: %w", err)
+		log.Printf("[length] %s retry failed, keeping the first options: %v", kind, err)
+		return options
+	}
+	retried = normalizeGeneratedTitles(kind, retried)
+	if within := withinLength(retried, target); len(within) > 0 {
+		return within
+	}
+	if len(retried) > 0 {
+		return retried
 	}
+	return options
+}
 
-	if strings.TrimSpace(fetchedText) == "" {
-		return "", "", errors.New("could not extract readable text from url")
+// afterSave links the run's LLM calls to the new analysis and queues its
+// grounding, sensitivity and fact checks and its entity lookups.
+func (s *FactService) afterSave(ctx context.Context, runID string, articleID int64, submission *models.Submission) {
+	if err := s.llmCalls.AttachArticle(ctx, runID, articleID); err != nil {
+		log.Printf("[llm-calls] failed to link run %s to article %d: %v", runID, articleID, err)
 	}
 
-	return fetchedText, parsedURL.String(), nil
+	var createdBy *int64
+	if submission != nil {
+		createdBy = submission.SubmittedBy
+	}
+	if _, err := EnqueueGroundingCheck(ctx, s.jobs, articleID, createdBy); err != nil {
+		log.Printf("[grounding] failed to queue article %d: %v", articleID, err)
+	}
+	if config.Current().SensitivityCheck {
+		if _, err := EnqueueSensitivityCheck(ctx, s.jobs, articleID, createdBy); err != nil {
+			log.Printf("[sensitivity] failed to queue article %d: %v", articleID, err)
+		}
+	}
+	if _, err := EnqueueFollowUpDetection(ctx, s.jobs, articleID, createdBy); err != nil {
+		log.Printf("[threads] failed to queue follow-up detection for article %d: %v", articleID, err)
+	}
+	if factChecksEnabled() {
+		if _, err := EnqueueFactCheck(ctx, s.jobs, articleID, createdBy); err != nil {
+			log.Printf("[fact-check] failed to queue article %d: %v", articleID, err)
+		}
+	}
+	if config.Current().EntityEnrichment {
+		if _, err := EnqueueEntityEnrichment(ctx, s.jobs, articleID, createdBy); err != nil {
+			log.Printf("[entities] failed to queue article %d: %v", articleID, err)
+		}
+	}
 }
 
-func (s *FactService) fetchURLText(ctx context.Context, sourceURL string) (string, error) {
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
-	if err != nil {
-		return "", err
+func (s *FactService) resolveInput(ctx context.Context, input models.PhaseOneInput) (resolvedSource, error) {
+	text := strings.TrimSpace(input.Text)
+	sourceURL := strings.TrimSpace(input.URL)
+
+	if sourceURL == "" {
+		if text == "" {
+			return resolvedSource{}, invalidInput("provide either text or url")
+		}
+		return resolvedSource{text: text}, nil
 	}
 
-	client := &http.Client{Timeout: 12 * time.Second}
-	response, err := client.Do(request)
+	parsedURL, err := CheckSourceURL(sourceURL)
 	if err != nil {
-		return "", err
+		return resolvedSource{}, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode >= http.StatusBadRequest {
-		return "", fmt.Errorf("url returned status %d", response.StatusCode)
+	if text != "" {
+		return resolvedSource{url: parsedURL.String(), text: text}, nil
 	}
 
-	body, err := io.ReadAll(io.LimitReader(response.Body, 2<<20))
+	fetchedText, page, err := sourceFetches.fetchText(ctx, parsedURL, input.Render)
 	if err != nil {
-		return "", err
+		err = fmt.Errorf("failed to read url content: %w", err)
+		// A refused or unrenderable URL is already marked as bad input.
+		if ErrorCode(err) == models.ErrorCodeInternal {
+			err = WithCode(models.ErrorCodeURLUnreachable, err)
+		}
+		return resolvedSource{}, err
+	}
+
+	if strings.TrimSpace(fetchedText) == "" {
+		return resolvedSource{}, WithCode(models.ErrorCodeURLNoText, errors.New("could not extract readable text from url"))
 	}
 
-	return sanitizeHTMLText(string(body)), nil
+	return resolvedSource{url: parsedURL.String(), text: fetchedText, fetchStrategy: page.strategy, page: page}, nil
 }
 
+// savePhaseOne stores a finished run as a new analysis, or into existingID,
+// the analysis a pipeline run saved its steps into.
 func (s *FactService) savePhaseOne(
 	ctx context.Context,
+	existingID int64,
 	sourceURL string,
 	rawText string,
 	articleText string,
+	sections []models.ArticleSection,
 	category string,
+	suggestion *categorySuggestion,
+	submission *models.Submission,
 	facts []string,
+	factTags [][]string,
 	gaps []string,
+	timeline []models.TimelineEvent,
+	numeric numericCheck,
 	headlines []string,
 	straplines []string,
+	translation *phaseOneTranslation,
+	multiple *corroboration,
+	images []resolvedImage,
+	detected languageDetection,
+	outputLanguage string,
+	promptVersions map[string]int,
 ) (int64, error) {
-	driver := db.Driver()
-	tx, err := s.database.BeginTx(ctx, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	committed := false
-	defer func() {
-		if !committed {
-			_ = tx.Rollback()
-		}
-	}()
-
-	topicID, err := resolveTopicID(ctx, tx, driver, category)
-	if err != nil {
-		return 0, err
-	}
-
 	headlines = dedupeAndTrim(headlines)
 	straplines = dedupeAndTrim(straplines)
 	selectedHeadline := firstListValue(headlines)
 	selectedStrapline := firstListValue(straplines)
 
-	articleID, err := insertArticle(
-		ctx,
-		tx,
-		driver,
-		sourceURL,
-		rawText,
-		articleText,
-		topicID,
-		selectedHeadline,
-		selectedStrapline,
-	)
-	if err != nil {
-		return 0, err
-	}
+	articleID := existingID
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		topicID, err := resolveTopicID(ctx, tx, category)
+		if err != nil {
+			return err
+		}
 
-	if err := insertFacts(ctx, tx, driver, articleID, facts); err != nil {
-		return 0, err
-	}
+		var suggestedTopicID *int64
+		suggestionSource := ""
+		if suggestion != nil {
+			suggestedTopicID, err = resolveTopicID(ctx, tx, suggestion.category)
+			if err != nil {
+				return err
+			}
+			suggestionSource = suggestion.source
+		}
 
-	if err := insertGaps(ctx, tx, driver, articleID, gaps); err != nil {
-		return 0, err
-	}
+		if existingID != 0 {
+			err = completeRunArticle(ctx, tx, existingID, articleText, topicID, suggestedTopicID, suggestionSource, selectedHeadline, selectedStrapline)
+		} else {
+			articleID, err = insertArticle(
+				ctx,
+				tx,
+				sourceURL,
+				"",
+				rawText,
+				articleText,
+				topicID,
+				suggestedTopicID,
+				suggestionSource,
+				submission,
+				selectedHeadline,
+				selectedStrapline,
+				detected,
+				outputLanguage,
+			)
+		}
+		if err != nil {
+			return err
+		}
 
-	if err := insertHeadlines(ctx, tx, driver, articleID, headlines, selectedHeadline); err != nil {
-		return 0, err
-	}
+		if len(sections) > 0 {
+			if err := storeArticleSections(ctx, tx, articleID, sections); err != nil {
+				return err
+			}
+		}
+		if _, err := storeReadability(ctx, tx, articleID, articleText, outputLanguage); err != nil {
+			return err
+		}
 
-	if err := insertStraplines(ctx, tx, driver, articleID, straplines, selectedStrapline); err != nil {
-		return 0, err
-	}
+		if existingID == 0 {
+			if err := insertArticleImages(ctx, tx, articleID, images); err != nil {
+				return err
+			}
+		}
 
-	if err := tx.Commit(); err != nil {
+		factIDs, err := insertFacts(ctx, tx, articleID, facts)
+		if err != nil {
+			return err
+		}
+		if err := insertFactTags(ctx, tx, factIDs, factTags); err != nil {
+			return err
+		}
+
+		gapIDs, err := insertGaps(ctx, tx, articleID, gaps)
+		if err != nil {
+			return err
+		}
+
+		if err := insertNumericGaps(ctx, tx, articleID, numeric.questions); err != nil {
+			return err
+		}
+
+		if err := insertTimelineEvents(ctx, tx, articleID, timeline); err != nil {
+			return err
+		}
+
+		if err := insertNumericClaims(ctx, tx, articleID, numeric.claims); err != nil {
+			return err
+		}
+
+		if multiple != nil {
+			if err := insertCorroboration(ctx, tx, articleID, multiple, factIDs); err != nil {
+				return err
+			}
+		}
+
+		if translation != nil {
+			records := make([]translationRecord, 0, len(factIDs)+len(gapIDs)+1)
+			records = append(records, pairTranslations(translationEntityFact, factIDs, translation.facts, facts)...)
+			records = append(records, pairTranslations(translationEntityGap, gapIDs, translation.gaps, gaps)...)
+			records = append(records, translationRecord{
+				entityType: translationEntityArticle,
+				entityID:   articleID,
+				source:     translation.article,
+				translated: articleText,
+			})
+			if err := insertTranslations(ctx, tx, articleID, translation.language, translation.glossaryVersion, records); err != nil {
+				return err
+			}
+		}
+
+		if err := insertHeadlines(ctx, tx, articleID, headlines, selectedHeadline); err != nil {
+			return err
+		}
+
+		if err := insertStraplines(ctx, tx, articleID, straplines, selectedStrapline); err != nil {
+			return err
+		}
+
+		return insertPromptVersions(ctx, tx, articleID, promptVersions)
+	})
+	if err != nil {
 		return 0, err
 	}
-	committed = true
 
 	return articleID, nil
 }
 
 func insertArticle(
 	ctx context.Context,
-	tx *sql.Tx,
-	driver string,
+	tx *repository.Tx,
 	sourceURL string,
+	fetchStrategy string,
 	rawText string,
 	articleText string,
 	topicID *int64,
+	suggestedTopicID *int64,
+	suggestionSource string,
+	submission *models.Submission,
 	headlineSelected string,
 	straplineSelected string,
+	detected languageDetection,
+	outputLanguage string,
 ) (int64, error) {
-	switch driver {
-	case "postgres":
-		var articleID int64
-		query := `INSERT INTO articles (source_url, raw_text, status, selected_format, article_text, topic_id, headline_selected, strapline_selected) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-		if err := tx.QueryRowContext(
-			ctx,
-			query,
-			sourceURL,
-			rawText,
-			"pending",
-			"timeline",
-			articleText,
-			topicID,
-			headlineSelected,
-			straplineSelected,
-		).Scan(&articleID); err != nil {
-			return 0, err
-		}
-		return articleID, nil
-	case "mysql":
-		query := `INSERT INTO articles (source_url, raw_text, status, selected_format, article_text, topic_id, headline_selected, strapline_selected) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-		result, err := tx.ExecContext(
-			ctx,
-			query,
-			sourceURL,
-			rawText,
-			"pending",
-			"timeline",
-			articleText,
-			topicID,
-			headlineSelected,
-			straplineSelected,
-		)
-		if err != nil {
-			return 0, err
-		}
-		articleID, err := result.LastInsertId()
+	if submission == nil {
+		submission = &models.Submission{}
+	}
+	var params sql.NullString
+	if len(submission.Params) > 0 {
+		encoded, err := json.Marshal(submission.Params)
 		if err != nil {
 			return 0, err
 		}
-		return articleID, nil
-	default:
-		return 0, errors.New("unsupported database driver")
+		params = sql.NullString{String: string(encoded), Valid: true}
 	}
-}
 
-func insertFacts(ctx context.Context, tx *sql.Tx, driver string, articleID int64, facts []string) error {
-	var query string
-	switch driver {
-	case "postgres":
-		query = `INSERT INTO facts (article_id, fact_text, is_confirmed, is_included, source) VALUES ($1, $2, $3, $4, $5)`
-	case "mysql":
-		query = `INSERT INTO facts (article_id, fact_text, is_confirmed, is_included, source) VALUES (?, ?, ?, ?, ?)`
-	default:
-		return errors.New("unsupported database driver")
+	domain := sourceDomain(sourceURL)
+	if err := registerSourceDomain(ctx, tx, domain); err != nil {
+		return 0, err
 	}
 
-	for _, fact := range facts {
+	query := `
+		INSERT INTO articles (
+			source_url, source_domain, raw_text, status, selected_format, article_text, topic_id, suggested_topic_id, category_suggestion_source,
+			submitted_by, submission_channel, client_ip, user_agent, submission_params, headline_selected, strapline_selected,
+			input_language, input_language_confidence, language_detection, output_language, fetch_strategy
+		) VALUES (` + repository.Placeholders(21) + `)`
+	return tx.Insert(
+		ctx,
+		query,
+		sourceURL,
+		nullString(domain),
+		rawText,
+		"pending",
+		"timeline",
+		articleText,
+		topicID,
+		suggestedTopicID,
+		nullString(suggestionSource),
+		submission.SubmittedBy,
+		nullString(submission.Channel),
+		nullString(submission.ClientIP),
+		nullString(submission.UserAgent),
+		params,
+		headlineSelected,
+		straplineSelected,
+		nullString(detected.language.Code),
+		detected.confidence,
+		nullString(detected.method),
+		nullString(outputLanguage),
+		nullString(fetchStrategy),
+	)
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// insertFacts returns the new ids aligned with facts; skipped blanks get 0.
+func insertFacts(ctx context.Context, tx *repository.Tx, articleID int64, facts []string) ([]int64, error) {
+	rows := make([][]any, 0, len(facts))
+	positions := make([]int, 0, len(facts))
+	for idx, fact := range facts {
 		cleanFact := strings.TrimSpace(fact)
 		if cleanFact == "" {
 			continue
 		}
-		if _, err := tx.ExecContext(ctx, query, articleID, cleanFact, false, true, "ai"); err != nil {
-			return err
-		}
+		rows = append(rows, []any{articleID, cleanFact, false, true, "ai", idx + 1})
+		positions = append(positions, idx)
 	}
-	return nil
-}
 
-func insertGaps(ctx context.Context, tx *sql.Tx, driver string, articleID int64, gaps []string) error {
-	var query string
-	switch driver {
-	case "postgres":
-		query = `INSERT INTO gaps (article_id, question, is_selected, is_resolved) VALUES ($1, $2, $3, $4)`
-	case "mysql":
-		query = `INSERT INTO gaps (article_id, question, is_selected, is_resolved) VALUES (?, ?, ?, ?)`
-	default:
-		return errors.New("unsupported database driver")
+	inserted, err := tx.InsertMany(ctx, "facts", []string{"article_id", "fact_text", "is_confirmed", "is_included", "source", "position"}, rows)
+	if err != nil {
+		return nil, err
 	}
+	return alignInsertedIDs(len(facts), positions, inserted), nil
+}
 
-	for _, gap := range gaps {
+func insertGaps(ctx context.Context, tx *repository.Tx, articleID int64, gaps []string) ([]int64, error) {
+	rows := make([][]any, 0, len(gaps))
+	positions := make([]int, 0, len(gaps))
+	for idx, gap := range gaps {
 		cleanGap := strings.TrimSpace(gap)
 		if cleanGap == "" {
 			continue
 		}
-		if _, err := tx.ExecContext(ctx, query, articleID, cleanGap, true, false); err != nil {
-			return err
-		}
+		rows = append(rows, []any{articleID, cleanGap, true, false})
+		positions = append(positions, idx)
 	}
-	return nil
+
+	inserted, err := tx.InsertMany(ctx, "gaps", []string{"article_id", "question", "is_selected", "is_resolved"}, rows)
+	if err != nil {
+		return nil, err
+	}
+	return alignInsertedIDs(len(gaps), positions, inserted), nil
+}
+
+func alignInsertedIDs(count int, positions []int, inserted []int64) []int64 {
+	ids := make([]int64, count)
+	for idx, position := range positions {
+		ids[position] = inserted[idx]
+	}
+	return ids
 }
 
 func insertHeadlines(
 	ctx context.Context,
-	tx *sql.Tx,
-	driver string,
+	tx *repository.Tx,
 	articleID int64,
 	headlines []string,
 	selected string,
@@ -347,15 +617,7 @@ func insertHeadlines(
 		return nil
 	}
 
-	var query string
-	switch driver {
-	case "postgres":
-		query = `INSERT INTO headlines (article_id, headline_text, is_selected) VALUES ($1, $2, $3)`
-	case "mysql":
-		query = `INSERT INTO headlines (article_id, headline_text, is_selected) VALUES (?, ?, ?)`
-	default:
-		return errors.New("unsupported database driver")
-	}
+	query := `INSERT INTO headlines (article_id, headline_text, is_selected) VALUES (?, ?, ?)`
 
 	selectedClean := strings.TrimSpace(selected)
 	for _, headline := range headlines {
@@ -374,8 +636,7 @@ func insertHeadlines(
 
 func insertStraplines(
 	ctx context.Context,
-	tx *sql.Tx,
-	driver string,
+	tx *repository.Tx,
 	articleID int64,
 	straplines []string,
 	selected string,
@@ -384,15 +645,7 @@ func insertStraplines(
 		return nil
 	}
 
-	var query string
-	switch driver {
-	case "postgres":
-		query = `INSERT INTO straplines (article_id, strapline_text, is_selected) VALUES ($1, $2, $3)`
-	case "mysql":
-		query = `INSERT INTO straplines (article_id, strapline_text, is_selected) VALUES (?, ?, ?)`
-	default:
-		return errors.New("unsupported database driver")
-	}
+	query := `INSERT INTO straplines (article_id, strapline_text, is_selected) VALUES (?, ?, ?)`
 
 	selectedClean := strings.TrimSpace(selected)
 	for _, strapline := range straplines {
@@ -409,53 +662,37 @@ func insertStraplines(
 	return nil
 }
 
-func resolveTopicID(ctx context.Context, tx *sql.Tx, driver string, category string) (*int64, error) {
+func insertPromptVersions(ctx context.Context, tx *repository.Tx, articleID int64, versions map[string]int) error {
+	query := `INSERT INTO article_prompt_versions (article_id, template_key, version) VALUES (?, ?, ?)`
+	for key, version := range versions {
+		if _, err := tx.ExecContext(ctx, query, articleID, key, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveTopicID(ctx context.Context, tx *repository.Tx, category string) (*int64, error) {
 	cleanCategory := strings.TrimSpace(category)
 	if cleanCategory == "" {
 		return nil, nil
 	}
 
-	switch driver {
-	case "postgres":
-		var topicID int64
-		selectQuery := `SELECT id FROM topics WHERE LOWER(name) = LOWER($1) LIMIT 1`
-		err := tx.QueryRowContext(ctx, selectQuery, cleanCategory).Scan(&topicID)
-		if err == nil {
-			return &topicID, nil
-		}
-		if !errors.Is(err, sql.ErrNoRows) {
-			return nil, err
-		}
-
-		insertQuery := `INSERT INTO topics (name) VALUES ($1) RETURNING id`
-		if err := tx.QueryRowContext(ctx, insertQuery, cleanCategory).Scan(&topicID); err != nil {
-			return nil, err
-		}
+	var topicID int64
+	selectQuery := `SELECT id FROM topics WHERE LOWER(name) = LOWER(?) LIMIT 1`
+	err := tx.QueryRowContext(ctx, selectQuery, cleanCategory).Scan(&topicID)
+	if err == nil {
 		return &topicID, nil
-	case "mysql":
-		var topicID int64
-		selectQuery := `SELECT id FROM topics WHERE LOWER(name) = LOWER(?) LIMIT 1`
-		err := tx.QueryRowContext(ctx, selectQuery, cleanCategory).Scan(&topicID)
-		if err == nil {
-			return &topicID, nil
-		}
-		if !errors.Is(err, sql.ErrNoRows) {
-			return nil, err
-		}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
 
-		insertQuery := `INSERT INTO topics (name) VALUES (?)`
-		result, err := tx.ExecContext(ctx, insertQuery, cleanCategory)
-		if err != nil {
-			return nil, err
-		}
-		topicID, err = result.LastInsertId()
-		if err != nil {
-			return nil, err
-		}
-		return &topicID, nil
-	default:
-		return nil, errors.New("unsupported database driver")
+	topicID, err = tx.Insert(ctx, `INSERT INTO topics (name) VALUES (?)`, cleanCategory)
+	if err != nil {
+		return nil, err
 	}
+	return &topicID, nil
 }
 
 func fallbackHeadlines(facts []string, articleText string) []string {
@@ -535,39 +772,6 @@ func sanitizeHTMLText(value string) string {
 	return strings.TrimSpace(whitespacePattern.ReplaceAllString(decoded, " "))
 }
 
-func normalizeOutputLanguage(requested string, inputText string) string {
-	clean := strings.ToLower(strings.TrimSpace(requested))
-
-	switch clean {
-	case "te", "telugu", "తెలుగు":
-		return "Telugu"
-	case "en", "english":
-		return "English"
-	}
-
-	if containsTeluguScript(inputText) {
-		return "Telugu"
-	}
-
-	return "English"
-}
-
-func containsTeluguScript(value string) bool {
-	for _, r := range value {
-		if r >= 0x0C00 && r <= 0x0C7F {
-			return true
-		}
-	}
-	return false
-}
-
-func stableGenerationLanguage(outputLanguage string) string {
-	if strings.EqualFold(strings.TrimSpace(outputLanguage), "Telugu") {
-		return "English"
-	}
-	return outputLanguage
-}
-
 func compactLLMInput(raw string) string {
 	clean := strings.TrimSpace(raw)
 	if clean == "" {
@@ -579,12 +783,23 @@ func compactLLMInput(raw string) string {
 }
 
 func safeRunesForExtraction(value string) int {
-	if containsTeluguScript(value) {
+	if containsIndicScript(value) {
 		return 3200
 	}
 	return 6200
 }
 
+// containsIndicScript reports text in a script that tokenizes to several
+// tokens per letter, which leaves less room for input.
+func containsIndicScript(value string) bool {
+	for _, r := range value {
+		if runeScript(r) != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func truncateRunes(value string, maxRunes int) string {
 	if maxRunes <= 0 {
 		return ""
@@ -599,28 +814,19 @@ func truncateRunes(value string, maxRunes int) string {
 }
 
 func (s *FactService) applyRuntimeAISettings(ctx context.Context) error {
-	query := `
-		SELECT p.provider_key, m.model_key
-		FROM app_settings s
-		JOIN ai_providers p ON p.id = s.provider_id
-		JOIN ai_models m ON m.id = s.model_id
-		WHERE s.id = 1
-		LIMIT 1;
-	`
-
-	var (
-		providerKey string
-		modelKey    string
-	)
-
-	err := s.database.QueryRowContext(ctx, query).Scan(&providerKey, &modelKey)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil
+	if err := loadProviderKeys(ctx, s.store); err != nil {
+		return err
 	}
+	active, ok, err := resolveActiveModel(ctx, s.store)
 	if err != nil {
 		return err
 	}
+	if !ok {
+		if stored := storedProviderKeys.get(s.ai.provider); stored != "" {
+			s.ai.apiKey = stored
+		}
+		return nil
+	}
 
-	s.ai.ApplySettings(providerKey, modelKey)
-	return nil
+	return s.ai.ApplySettings(active.provider, active.model, active.maxTokens)
 }
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	maxFactTags     = 6
+	maxFactTagRunes = 40
+)
+
+// factTagSet holds the tags the extraction prompt gave each fact, keyed by the
+// fact's text so they follow it through the merging of chunks and sources.
+type factTagSet map[string][]string
+
+func factTagKey(fact string) string {
+	return strings.ToLower(strings.TrimSpace(fact))
+}
+
+func (t factTagSet) add(fact string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	key := factTagKey(fact)
+	t[key] = cleanFactTags(append(t[key], tags...))
+}
+
+func (t factTagSet) merge(other factTagSet) {
+	for key, tags := range other {
+		t[key] = cleanFactTags(append(t[key], tags...))
+	}
+}
+
+// forFacts lines the tags up with facts, for storing next to them.
+func (t factTagSet) forFacts(facts []string) [][]string {
+	if len(t) == 0 {
+		return nil
+	}
+	tags := make([][]string, len(facts))
+	for idx, fact := range facts {
+		tags[idx] = t[factTagKey(fact)]
+	}
+	return tags
+}
+
+func normalizeFactTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.Trim(strings.TrimSpace(tag), "#[]"))), " ")
+}
+
+// cleanFactTags is the lenient form of normalizeFactTags for model output:
+// what a caller would be told off for is dropped instead.
+func cleanFactTags(tags []string) []string {
+	clean := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeFactTag(tag)
+		if tag == "" || len([]rune(tag)) > maxFactTagRunes || slices.Contains(clean, tag) {
+			continue
+		}
+		if clean = append(clean, tag); len(clean) == maxFactTags {
+			break
+		}
+	}
+	return clean
+}
+
+func normalizeFactTags(tags []string) ([]string, error) {
+	clean := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeFactTag(tag)
+		if tag == "" {
+			continue
+		}
+		if len([]rune(tag)) > maxFactTagRunes {
+			return nil, invalidInputf("tags must be at most %d characters", maxFactTagRunes)
+		}
+		if !slices.Contains(clean, tag) {
+			clean = append(clean, tag)
+		}
+	}
+	if len(clean) > maxFactTags {
+		return nil, invalidInputf("a fact can have at most %d tags", maxFactTags)
+	}
+	return clean, nil
+}
+
+// SetFactTags replaces the tags on a fact. Tags are lowercased; an empty
+// list clears them.
+func (s *AdminService) SetFactTags(ctx context.Context, factID int64, tags []string, setBy *int64) ([]string, error) {
+	clean, err := normalizeFactTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		articleID int64
+		version   int64
+	)
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if err := tx.QueryRowContext(ctx, "SELECT article_id, version FROM facts WHERE id = ? AND deleted_at IS NULL", factID).Scan(&articleID, &version); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM fact_tags WHERE fact_id = ?", factID); err != nil {
+			return err
+		}
+		return insertFactTags(ctx, tx, []int64{factID}, [][]string{clean})
+	})
+	if err != nil {
+		return nil, err
+	}
+	announceEdit(ctx, s.store, "facts", models.CollaborationEvent{Type: models.CollaborationFactUpdated, AnalysisID: articleID, EntityID: factID, Version: version, UserID: setBy})
+	return clean, nil
+}
+
+// insertFactTags stores tags[idx] on factIDs[idx]; facts that weren't
+// inserted have an id of 0 and are skipped.
+func insertFactTags(ctx context.Context, tx *repository.Tx, factIDs []int64, tags [][]string) error {
+	for idx, factID := range factIDs {
+		if factID == 0 || idx >= len(tags) {
+			continue
+		}
+		for _, tag := range tags[idx] {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO fact_tags (fact_id, tag) VALUES (?, ?)", factID, tag); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listFactTags reads the tags on an analysis's facts, by fact id.
+func listFactTags(ctx context.Context, q repository.Querier, articleID int64) (map[int64][]string, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT ft.fact_id, ft.tag FROM fact_tags ft
+		JOIN facts f ON f.id = ft.fact_id
+		WHERE f.article_id = ?
+		ORDER BY ft.fact_id ASC, ft.created_at ASC, ft.tag ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var (
+			factID int64
+			tag    string
+		)
+		if err := rows.Scan(&factID, &tag); err != nil {
+			return nil, err
+		}
+		tags[factID] = append(tags[factID], tag)
+	}
+	return tags, rows.Err()
+}
+
+// loadFactTagSet reads the tags on an analysis's facts by their text, for
+// facts about to be matched against others.
+func loadFactTagSet(ctx context.Context, q repository.Querier, articleID int64) (factTagSet, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT COALESCE(f.fact_text, ''), ft.tag FROM fact_tags ft
+		JOIN facts f ON f.id = ft.fact_id
+		WHERE f.article_id = ? AND f.deleted_at IS NULL
+		ORDER BY ft.fact_id ASC, ft.created_at ASC, ft.tag ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := make(factTagSet)
+	for rows.Next() {
+		var fact, tag string
+		if err := rows.Scan(&fact, &tag); err != nil {
+			return nil, err
+		}
+		set.add(fact, []string{tag})
+	}
+	return set, rows.Err()
+}
+
+// FactTagsInUse lists the tags on facts, sorted, for filtering by them.
+func FactTagsInUse(facts []models.AnalysisFact) []string {
+	tags := make([]string, 0)
+	for _, fact := range facts {
+		for _, tag := range fact.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// FilterFactsByTags keeps the facts with any of tags. No tags keeps them all.
+func FilterFactsByTags(facts []models.AnalysisFact, tags []string) []models.AnalysisFact {
+	wanted := cleanFactTags(tags)
+	if len(wanted) == 0 {
+		return facts
+	}
+	filtered := make([]models.AnalysisFact, 0, len(facts))
+	for _, fact := range facts {
+		for _, tag := range fact.Tags {
+			if slices.Contains(wanted, tag) {
+				filtered = append(filtered, fact)
+				break
+			}
+		}
+	}
+	return filtered
+}
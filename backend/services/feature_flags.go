@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// Overrides are re-read this often, so a toggle on one instance reaches the
+// others within that time.
+const featureFlagRefresh = 15 * time.Second
+
+type featureFlagDefinition struct {
+	key         string
+	description string
+	enabled     bool
+}
+
+// featureFlagDefinitions are the flags the code checks. Each defaults to how
+// things worked before the flag existed.
+var featureFlagDefinitions = []featureFlagDefinition{
+	{key: models.FlagWebSearch, description: "Web search for answers to open questions (POST /api/gaps/:id/research).", enabled: true},
+	{key: models.FlagTimeline, description: "Timeline extraction step of the analysis pipeline.", enabled: true},
+	{key: models.FlagNumericClaims, description: "Numeric claim consistency check step of the analysis pipeline.", enabled: true},
+	{key: models.FlagHeadlineGuidance, description: "Guidance learned from headline A/B test results in headline prompts.", enabled: true},
+}
+
+type featureFlagOverride struct {
+	enabled        bool
+	rolloutPercent int
+	updatedAt      time.Time
+}
+
+var featureFlagOverrides = &featureFlagCache{}
+
+type featureFlagCache struct {
+	mu       sync.Mutex
+	loadedAt time.Time
+	entries  map[string]featureFlagOverride
+}
+
+type FeatureFlagService struct {
+	store *repository.Store
+}
+
+func NewFeatureFlagService(database *sql.DB) *FeatureFlagService {
+	return &FeatureFlagService{
+		store: repository.New(database),
+	}
+}
+
+// Enabled reports whether key is on for subject. Partial rollouts put a
+// subject on the same side every time; an empty subject only gets flags that
+// are fully rolled out.
+func (s *FeatureFlagService) Enabled(ctx context.Context, key string, subject string) bool {
+	return featureEnabled(ctx, s.store, key, subject)
+}
+
+func featureEnabled(ctx context.Context, store *repository.Store, key string, subject string) bool {
+	definition, ok := findFeatureFlag(key)
+	if !ok {
+		log.Printf("[flags] unknown feature flag %s", key)
+		return false
+	}
+	flag := resolveFeatureFlag(definition, featureFlagOverrides.get(ctx, store))
+	if !flag.Enabled {
+		return false
+	}
+	if flag.RolloutPercent >= 100 {
+		return true
+	}
+	if subject == "" {
+		return false
+	}
+	return rolloutBucket(key, subject) < flag.RolloutPercent
+}
+
+// rolloutBucket places subject in 0-99, hashed with the flag so different
+// flags don't all reach the same subjects first.
+func rolloutBucket(key string, subject string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key + ":" + subject))
+	return int(hash.Sum32() % 100)
+}
+
+func findFeatureFlag(key string) (featureFlagDefinition, bool) {
+	for _, definition := range featureFlagDefinitions {
+		if definition.key == key {
+			return definition, true
+		}
+	}
+	return featureFlagDefinition{}, false
+}
+
+func resolveFeatureFlag(definition featureFlagDefinition, overrides map[string]featureFlagOverride) models.FeatureFlag {
+	flag := models.FeatureFlag{
+		Key:            definition.key,
+		Description:    definition.description,
+		Enabled:        definition.enabled,
+		RolloutPercent: 100,
+		Default:        definition.enabled,
+		Source:         models.FlagSourceDefault,
+	}
+	if enabled, ok := config.Current().FeatureFlags[definition.key]; ok {
+		flag.Enabled = enabled
+		flag.Default = enabled
+		flag.Source = models.FlagSourceEnvironment
+	}
+	if override, ok := overrides[definition.key]; ok {
+		flag.Enabled = override.enabled
+		flag.RolloutPercent = override.rolloutPercent
+		flag.Source = models.FlagSourceOverride
+		if !override.updatedAt.IsZero() {
+			updatedAt := override.updatedAt
+			flag.UpdatedAt = &updatedAt
+		}
+	}
+	return flag
+}
+
+// get returns the overrides, reloading them when they are stale. A failed
+// reload keeps the last ones it had.
+func (c *featureFlagCache) get(ctx context.Context, store *repository.Store) map[string]featureFlagOverride {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries != nil && time.Since(c.loadedAt) < featureFlagRefresh {
+		return c.entries
+	}
+
+	entries, err := loadFeatureFlagOverrides(ctx, store)
+	if err != nil {
+		log.Printf("[flags] failed to load feature flag overrides: %v", err)
+		if c.entries == nil {
+			return map[string]featureFlagOverride{}
+		}
+		return c.entries
+	}
+	c.entries = entries
+	c.loadedAt = time.Now()
+	return entries
+}
+
+func (c *featureFlagCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+func loadFeatureFlagOverrides(ctx context.Context, store *repository.Store) (map[string]featureFlagOverride, error) {
+	rows, err := store.QueryContext(ctx, "SELECT flag_key, enabled, rollout_percent, updated_at FROM feature_flags")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[string]featureFlagOverride)
+	for rows.Next() {
+		var (
+			key       string
+			override  featureFlagOverride
+			updatedAt sql.NullTime
+		)
+		if err := rows.Scan(&key, &override.enabled, &override.rolloutPercent, &updatedAt); err != nil {
+			return nil, err
+		}
+		override.updatedAt = updatedAt.Time
+		entries[key] = override
+	}
+	return entries, rows.Err()
+}
+
+func (s *FeatureFlagService) List(ctx context.Context) ([]models.FeatureFlag, error) {
+	overrides, err := loadFeatureFlagOverrides(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]models.FeatureFlag, 0, len(featureFlagDefinitions))
+	for _, definition := range featureFlagDefinitions {
+		flags = append(flags, resolveFeatureFlag(definition, overrides))
+	}
+	return flags, nil
+}
+
+// Set overrides a flag. A nil field keeps its current value.
+func (s *FeatureFlagService) Set(ctx context.Context, key string, enabled *bool, rolloutPercent *int, updatedBy *int64) (models.FeatureFlag, error) {
+	definition, ok := findFeatureFlag(key)
+	if !ok {
+		return models.FeatureFlag{}, sql.ErrNoRows
+	}
+	if enabled == nil && rolloutPercent == nil {
+		return models.FeatureFlag{}, invalidInput("enabled or rolloutPercent is required")
+	}
+	if rolloutPercent != nil && (*rolloutPercent < 0 || *rolloutPercent > 100) {
+		return models.FeatureFlag{}, invalidInputf("rolloutPercent must be between 0 and 100 (got %d)", *rolloutPercent)
+	}
+
+	overrides, err := loadFeatureFlagOverrides(ctx, s.store)
+	if err != nil {
+		return models.FeatureFlag{}, err
+	}
+	current := resolveFeatureFlag(definition, overrides)
+	if enabled != nil {
+		current.Enabled = *enabled
+	}
+	if rolloutPercent != nil {
+		current.RolloutPercent = *rolloutPercent
+	}
+
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"feature_flags",
+		[]string{"flag_key", "enabled", "rollout_percent", "updated_by"},
+		[]string{"flag_key"},
+		[]string{"enabled", "rollout_percent", "updated_by"},
+		"updated_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		return models.FeatureFlag{}, err
+	}
+	if _, err := s.store.ExecContext(ctx, query, key, current.Enabled, current.RolloutPercent, updatedBy); err != nil {
+		return models.FeatureFlag{}, err
+	}
+	featureFlagOverrides.invalidate()
+	log.Printf("[flags] %s set to enabled=%t rollout=%d%%", key, current.Enabled, current.RolloutPercent)
+
+	now := time.Now()
+	current.Source = models.FlagSourceOverride
+	current.UpdatedAt = &now
+	return current, nil
+}
+
+// Reset drops a flag's override, returning it to FEATURE_FLAGS or its default.
+func (s *FeatureFlagService) Reset(ctx context.Context, key string) (models.FeatureFlag, error) {
+	definition, ok := findFeatureFlag(key)
+	if !ok {
+		return models.FeatureFlag{}, sql.ErrNoRows
+	}
+	if _, err := s.store.ExecContext(ctx, "DELETE FROM feature_flags WHERE flag_key = ?", key); err != nil {
+		return models.FeatureFlag{}, err
+	}
+	featureFlagOverrides.invalidate()
+	return resolveFeatureFlag(definition, nil), nil
+}
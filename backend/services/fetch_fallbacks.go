@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"nanoheads/config"
+)
+
+// Fetch strategies, as recorded on analyses and their sources.
+const (
+	fetchDirect      = "direct"
+	fetchRendered    = "rendered"
+	fetchAMP         = "amp"
+	fetchGoogleCache = "google_cache"
+	fetchArchive     = "archive"
+)
+
+var (
+	linkTagPattern = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	ampRelPattern  = regexp.MustCompile(`(?i)\brel\s*=\s*["']?[^"'>]*\bamphtml\b`)
+	hrefPattern    = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+const archiveSnapshotAPI = "https://archive.org/wayback/available"
+
+// thinText reports whether text is too short to analyse on its own.
+func thinText(text string, minWords int) bool {
+	return len(strings.Fields(text)) < minWords
+}
+
+// fetchFallback fetches target another way. page is the best result so far,
+// nil if the direct fetch failed.
+func (f *sourceFetcher) fetchFallback(ctx context.Context, client *http.Client, cfg config.Config, strategy string, target *url.URL, page *sourcePage) (*sourcePage, error) {
+	var (
+		alternate string
+		err       error
+	)
+	switch strategy {
+	case fetchAMP:
+		if page == nil {
+			return nil, errors.New("page could not be read to find its AMP version")
+		}
+		alternate, err = ampURL(target, page.html)
+	case fetchGoogleCache:
+		alternate = "https://webcache.googleusercontent.com/search?q=" + url.QueryEscape("cache:"+target.String())
+	case fetchArchive:
+		alternate, err = f.archiveSnapshot(ctx, client, cfg, target)
+	default:
+		err = fmt.Errorf("unknown strategy %q", strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := CheckSourceURL(alternate)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := f.fetchPage(ctx, client, cfg, parsed)
+	if err != nil {
+		return nil, err
+	}
+	fallback.strategy = strategy
+	return fallback, nil
+}
+
+// ampURL reads the page's <link rel="amphtml"> and resolves it against
+// target.
+func ampURL(target *url.URL, page string) (string, error) {
+	for _, tag := range linkTagPattern.FindAllString(page, -1) {
+		if !ampRelPattern.MatchString(tag) {
+			continue
+		}
+		match := hrefPattern.FindStringSubmatch(tag)
+		if match == nil {
+			continue
+		}
+		href := strings.TrimSpace(strings.Join(match[1:], ""))
+		resolved, err := target.Parse(html.UnescapeString(href))
+		if err != nil {
+			return "", err
+		}
+		if resolved.String() == target.String() {
+			return "", errors.New("page is already the AMP version")
+		}
+		return resolved.String(), nil
+	}
+	return "", errors.New("page has no AMP version")
+}
+
+// archiveSnapshot asks the Wayback Machine for its closest snapshot of target
+// and returns the snapshot's raw capture URL, without the archive toolbar.
+func (f *sourceFetcher) archiveSnapshot(ctx context.Context, client *http.Client, cfg config.Config, target *url.URL) (string, error) {
+	lookup := archiveSnapshotAPI + "?url=" + url.QueryEscape(target.String())
+	if _, err := CheckSourceURL(lookup); err != nil {
+		return "", err
+	}
+	if err := f.wait(ctx, "archive.org", cfg.FetchDomainInterval.Duration); err != nil {
+		return "", err
+	}
+
+	response, err := f.get(ctx, client, cfg, lookup)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("snapshot lookup returned status %d", response.StatusCode)
+	}
+
+	var payload struct {
+		ArchivedSnapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				Status    string `json:"status"`
+				Timestamp string `json:"timestamp"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&payload); err != nil {
+		return "", fmt.Errorf("decode snapshot lookup: %w", err)
+	}
+
+	closest := payload.ArchivedSnapshots.Closest
+	if !closest.Available || closest.Timestamp == "" || (closest.Status != "" && closest.Status != "200") {
+		return "", errors.New("no archived snapshot")
+	}
+	return "https://web.archive.org/web/" + closest.Timestamp + "id_/" + target.String(), nil
+}
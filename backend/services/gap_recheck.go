@@ -0,0 +1,548 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/html/charset"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	// Open gaps looked at per run, most recent analyses first.
+	maxRecheckGaps = 200
+	// GapSearchURL is queried for at most this many of them.
+	maxGapSearches = 50
+	maxFeedItems   = 200
+	// Leads recorded for one gap in one run, best first.
+	maxLeadsPerGap = 3
+	// Share of a gap's terms an item must mention to be a lead.
+	gapLeadCoverage = 0.5
+	maxSearchTerms  = 6
+)
+
+// Words that frame a question rather than say what it is about.
+var gapQuestionWords = map[string]struct{}{
+	"what": {}, "when": {}, "where": {}, "why": {}, "how": {}, "whom": {}, "whose": {},
+	"does": {}, "did": {}, "can": {}, "could": {}, "would": {}, "should": {}, "there": {},
+	"any": {}, "much": {}, "many": {}, "yet": {}, "known": {}, "unclear": {}, "exactly": {},
+}
+
+type GapRecheckService struct {
+	store *repository.Store
+}
+
+func NewGapRecheckService(database *sql.DB) *GapRecheckService {
+	return &GapRecheckService{store: repository.New(database)}
+}
+
+type openGap struct {
+	id        int64
+	articleID int64
+	question  string
+	terms     map[string]struct{}
+	headline  string
+	sourceURL string
+	createdAt time.Time
+	story     storyProfile
+}
+
+type feedItem struct {
+	url       string
+	title     string
+	summary   string
+	content   string // the full text, when the feed carries it
+	feed      string
+	published *time.Time
+	profile   storyProfile
+}
+
+type gapLeadMatch struct {
+	gap   *openGap
+	item  feedItem
+	score float64
+}
+
+// Recheck looks for coverage published since each open gap's analysis that
+// mentions most of what the gap asks about, in the same story. Items from
+// GapFeeds are matched against every gap; GapSearchURL is queried per gap.
+// Each new lead is recorded once, and the analysis's submitter and assignee
+// are notified of it.
+func (s *GapRecheckService) Recheck(ctx context.Context, report func(int, int)) (models.GapRecheckResult, error) {
+	cfg := config.Current()
+	result := models.GapRecheckResult{}
+
+	gaps, err := s.loadOpenGaps(ctx, time.Now().Add(-time.Duration(cfg.GapRecheckDays)*24*time.Hour))
+	if err != nil {
+		return result, err
+	}
+	result.Gaps = len(gaps)
+	if len(gaps) == 0 {
+		report(0, 0)
+		return result, nil
+	}
+
+	searches := 0
+	if cfg.GapSearchURL != "" {
+		searches = min(len(gaps), maxGapSearches)
+	}
+	total := len(cfg.GapFeeds) + searches
+	done := 0
+	report(done, total)
+
+	client := newSourceFetchClient(sourceFetchTimeout)
+	shared := make([]feedItem, 0)
+	for _, feed := range cfg.GapFeeds {
+		items, err := s.readFeed(ctx, client, cfg, feed)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			log.Printf("[gap-recheck] feed %s: %v", previewForLog(feed), err)
+			result.FeedErrors++
+		}
+		result.Feeds++
+		shared = append(shared, items...)
+		done++
+		report(done, total)
+	}
+	result.Items = len(shared)
+
+	matches := make([]gapLeadMatch, 0)
+	for idx := range gaps {
+		gap := &gaps[idx]
+		items := shared
+		if idx < searches {
+			found, err := s.search(ctx, client, cfg, gap)
+			if err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				log.Printf("[gap-recheck] search for gap %d: %v", gap.id, err)
+				result.FeedErrors++
+			}
+			result.Searches++
+			result.Items += len(found)
+			items = append(slices.Clip(shared), found...)
+			done++
+			report(done, total)
+		}
+		matches = append(matches, bestLeads(gap, items)...)
+	}
+
+	recorded, err := s.recordLeads(ctx, matches)
+	if err != nil {
+		return result, err
+	}
+	for _, leads := range recorded {
+		result.Leads += len(leads)
+	}
+	result.Analyses = len(recorded)
+
+	for articleID, leads := range recorded {
+		s.notifyLeads(ctx, articleID, leads)
+	}
+	return result, nil
+}
+
+// loadOpenGaps reads the selected, unresolved gaps of analyses created since
+// since, with what their analyses' stories are about. Merged analyses are
+// left out; the analysis they were merged into carries their gaps.
+func (s *GapRecheckService) loadOpenGaps(ctx context.Context, since time.Time) ([]openGap, error) {
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT g.id, g.article_id, COALESCE(g.question, ''), COALESCE(a.headline_selected, ''), COALESCE(a.source_url, ''), a.created_at
+		FROM gaps g
+		JOIN articles a ON a.id = g.article_id
+		WHERE a.merged_into IS NULL AND a.created_at >= ?
+			AND COALESCE(g.is_selected, true) = true AND COALESCE(g.is_resolved, false) = false
+		ORDER BY a.created_at DESC, g.id ASC
+		LIMIT ?`,
+		since,
+		maxRecheckGaps,
+	)
+	if err != nil {
+		return nil, err
+	}
+	gaps := make([]openGap, 0)
+	for rows.Next() {
+		var gap openGap
+		if err := rows.Scan(&gap.id, &gap.articleID, &gap.question, &gap.headline, &gap.sourceURL, &gap.createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		gap.terms = gapTerms(gap.question)
+		if len(gap.terms) == 0 {
+			continue
+		}
+		gaps = append(gaps, gap)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(gaps) == 0 {
+		return gaps, nil
+	}
+
+	ids := make([]any, 0)
+	seen := make(map[int64]struct{})
+	for _, gap := range gaps {
+		if _, ok := seen[gap.articleID]; !ok {
+			seen[gap.articleID] = struct{}{}
+			ids = append(ids, gap.articleID)
+		}
+	}
+	facts, err := listFactsByArticle(ctx, s.store, ids)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range gaps {
+		gaps[idx].story = buildStoryProfile(gaps[idx].headline, facts[gaps[idx].articleID])
+	}
+	return gaps, nil
+}
+
+func (s *GapRecheckService) search(ctx context.Context, client *http.Client, cfg config.Config, gap *openGap) ([]feedItem, error) {
+	query := gapSearchQuery(gap.headline, gap.question)
+	if query == "" {
+		return nil, nil
+	}
+	return s.readFeed(ctx, client, cfg, strings.ReplaceAll(cfg.GapSearchURL, "{query}", url.QueryEscape(query)))
+}
+
+func (s *GapRecheckService) readFeed(ctx context.Context, client *http.Client, cfg config.Config, feed string) ([]feedItem, error) {
+	target, err := url.Parse(feed)
+	if err != nil {
+		return nil, err
+	}
+	page, err := sourceFetches.fetchPage(ctx, client, cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseFeed(page.body)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range items {
+		items[idx].feed = feed
+		items[idx].profile = buildStoryProfile(items[idx].title, []string{items[idx].summary})
+	}
+	return items, nil
+}
+
+// bestLeads matches items against gap and keeps the best few. An item must
+// be newer than the analysis when it carries a date, share a name with the
+// story (or, for stories without names, three content words), and mention
+// at least half the gap's terms.
+func bestLeads(gap *openGap, items []feedItem) []gapLeadMatch {
+	matches := make([]gapLeadMatch, 0)
+	seen := make(map[string]struct{})
+	for _, item := range items {
+		if item.url == gap.sourceURL {
+			continue
+		}
+		if _, ok := seen[item.url]; ok {
+			continue
+		}
+		if item.published != nil && item.published.Before(gap.createdAt) {
+			continue
+		}
+		if !sameStory(gap.story, item.profile) {
+			continue
+		}
+		mentioned := 0
+		for term := range gap.terms {
+			if _, ok := item.profile.tokens[term]; ok {
+				mentioned++
+			}
+		}
+		coverage := float64(mentioned) / float64(len(gap.terms))
+		if mentioned < min(2, len(gap.terms)) || coverage < gapLeadCoverage {
+			continue
+		}
+		seen[item.url] = struct{}{}
+		matches = append(matches, gapLeadMatch{gap: gap, item: item, score: roundTo2(coverage)})
+	}
+	slices.SortStableFunc(matches, func(a, b gapLeadMatch) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+	if len(matches) > maxLeadsPerGap {
+		matches = matches[:maxLeadsPerGap]
+	}
+	return matches
+}
+
+func sameStory(story storyProfile, item storyProfile) bool {
+	if len(story.names) > 0 {
+		for name := range story.names {
+			if _, ok := item.names[name]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	shared := 0
+	for token := range story.tokens {
+		if _, ok := item.tokens[token]; ok {
+			shared++
+		}
+	}
+	return shared >= 3
+}
+
+// recordLeads stores the matches not recorded before and returns the new
+// ones by analysis.
+func (s *GapRecheckService) recordLeads(ctx context.Context, matches []gapLeadMatch) (map[int64][]gapLeadMatch, error) {
+	recorded := make(map[int64][]gapLeadMatch)
+	for _, match := range matches {
+		sum := sha256.Sum256([]byte(match.item.url))
+		urlHash := hex.EncodeToString(sum[:])
+
+		var exists int
+		err := s.store.QueryRowContext(ctx, "SELECT 1 FROM gap_leads WHERE gap_id = ? AND url_hash = ?", match.gap.id, urlHash).Scan(&exists)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		_, err = s.store.ExecContext(
+			ctx,
+			`INSERT INTO gap_leads (gap_id, article_id, url, url_hash, title, summary, feed_url, published_at, score)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			match.gap.id,
+			match.gap.articleID,
+			match.item.url,
+			urlHash,
+			match.item.title,
+			nullString(truncateRunes(match.item.summary, 1000)),
+			match.item.feed,
+			match.item.published,
+			match.score,
+		)
+		if err != nil {
+			return nil, err
+		}
+		recorded[match.gap.articleID] = append(recorded[match.gap.articleID], match)
+	}
+	return recorded, nil
+}
+
+func (s *GapRecheckService) notifyLeads(ctx context.Context, articleID int64, leads []gapLeadMatch) {
+	var submittedBy, assignedTo sql.NullInt64
+	err := s.store.QueryRowContext(ctx, "SELECT submitted_by, assigned_to FROM articles WHERE id = ?", articleID).Scan(&submittedBy, &assignedTo)
+	if err != nil {
+		log.Printf("[notifications] failed to load recipients for article %d: %v", articleID, err)
+		return
+	}
+
+	lines := []string{analysisLabel(ctx, s.store, articleID)}
+	for idx, lead := range leads {
+		if idx == 3 {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(leads)-idx))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%q: %s (%s)", lead.gap.question, lead.item.title, lead.item.url))
+	}
+	title := "New coverage may answer an open question"
+	if len(leads) > 1 {
+		title = fmt.Sprintf("New coverage may answer %d open questions", len(leads))
+	}
+
+	notifyUsers(ctx, s.store, []*int64{nullInt64Pointer(submittedBy), nullInt64Pointer(assignedTo)}, nil, notification{
+		kind:      models.NotificationGapLeads,
+		articleID: &articleID,
+		title:     title,
+		body:      strings.Join(lines, "\n"),
+	})
+}
+
+// gapTerms are the content words of a gap's question, without the words
+// that only make it a question.
+func gapTerms(question string) map[string]struct{} {
+	tokens, _ := factTokens(question)
+	for word := range gapQuestionWords {
+		delete(tokens, word)
+	}
+	return tokens
+}
+
+// gapSearchQuery is a search for a gap: the names in its analysis's headline,
+// then the gap's own content words, as written.
+func gapSearchQuery(headline string, question string) string {
+	terms := make([]string, 0, maxSearchTerms)
+	add := func(word string) {
+		if len(terms) < maxSearchTerms && !slices.Contains(terms, word) {
+			terms = append(terms, word)
+		}
+	}
+	for _, name := range properNames(headline) {
+		add(name)
+	}
+	for _, field := range strings.Fields(question) {
+		word := strings.ToLower(strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }))
+		if len([]rune(word)) < 3 {
+			continue
+		}
+		if _, stop := factStopwords[word]; stop {
+			continue
+		}
+		if _, question := gapQuestionWords[word]; question {
+			continue
+		}
+		add(word)
+	}
+	return strings.Join(terms, " ")
+}
+
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type feedEntry struct {
+	Title       string     `xml:"title"`
+	Links       []feedLink `xml:"link"`
+	GUID        string     `xml:"guid"`
+	Description string     `xml:"description"`
+	Summary     string     `xml:"summary"`
+	Content     string     `xml:"content"`
+	Encoded     string     `xml:"encoded"`
+	PubDate     string     `xml:"pubDate"`
+	Date        string     `xml:"date"`
+	Published   string     `xml:"published"`
+	Updated     string     `xml:"updated"`
+}
+
+// feedDocument reads RSS 2.0 (channel items), RSS 1.0 (items at the root)
+// and Atom (entries).
+type feedDocument struct {
+	Channel struct {
+		Items []feedEntry `xml:"item"`
+	} `xml:"channel"`
+	Items   []feedEntry `xml:"item"`
+	Entries []feedEntry `xml:"entry"`
+}
+
+func parseFeed(body []byte) ([]feedItem, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var document feedDocument
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+
+	entries := append(append(document.Channel.Items, document.Items...), document.Entries...)
+	items := make([]feedItem, 0, min(len(entries), maxFeedItems))
+	for _, entry := range entries {
+		if len(items) == maxFeedItems {
+			break
+		}
+		link := entry.link()
+		if link == "" {
+			continue
+		}
+		summary := entry.Description
+		if summary == "" {
+			summary = entry.Summary
+		}
+		if summary == "" {
+			summary = entry.Content
+		}
+		content := entry.Encoded
+		if content == "" {
+			content = entry.Content
+		}
+		item := feedItem{
+			url:     link,
+			title:   truncateRunes(sanitizeHTMLText(entry.Title), 500),
+			summary: sanitizeHTMLText(summary),
+			content: sanitizeHTMLText(content),
+		}
+		for _, value := range []string{entry.PubDate, entry.Published, entry.Date, entry.Updated} {
+			if published, ok := parseFeedTime(value); ok {
+				item.published = &published
+				break
+			}
+		}
+		if item.title == "" {
+			item.title = link
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// link is the entry's web page: the RSS link, an Atom alternate link, or a
+// GUID that is a URL.
+func (e feedEntry) link() string {
+	candidates := make([]string, 0, len(e.Links)+1)
+	for _, link := range e.Links {
+		switch {
+		case link.Href == "":
+			candidates = append(candidates, link.Text)
+		case link.Rel == "" || link.Rel == "alternate":
+			candidates = append(candidates, link.Href)
+		}
+	}
+	candidates = append(candidates, e.GUID)
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if parsed, err := url.Parse(candidate); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" {
+			return candidate
+		}
+	}
+	return ""
+}
+
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func parseFeedTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range feedTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
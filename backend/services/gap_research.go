@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// How much of each fetched result page the model is shown.
+const researchSourceRunes = 1500
+
+type gapResearchPayload struct {
+	GapID int64 `json:"gapId"`
+}
+
+// gapAnswerDraft is one answer the model gave; sources are 1-based result
+// numbers.
+type gapAnswerDraft struct {
+	answer     string
+	confidence string
+	sources    []int
+}
+
+// EnqueueGapResearch queues a web search for answers to a gap.
+func EnqueueGapResearch(ctx context.Context, jobs *JobService, gapID int64, createdBy *int64) (models.Job, error) {
+	if !webSearchEnabled() {
+		return models.Job{}, notConfigured("SEARCH_PROVIDER is required for gap research")
+	}
+
+	var exists int
+	if err := jobs.store.QueryRowContext(ctx, "SELECT 1 FROM gaps WHERE id = ?", gapID).Scan(&exists); err != nil {
+		return models.Job{}, err
+	}
+	return jobs.Enqueue(ctx, models.JobTypeGapResearch, gapResearchPayload{GapID: gapID}, createdBy)
+}
+
+// ResearchGap searches the web for a gap's question, reads the top results
+// and asks the model what they say in answer. The answers replace the gap's
+// earlier answers still pending review; reviewed ones are kept. A result
+// whose page can't be fetched is judged on its search snippet.
+func (s *FactService) ResearchGap(ctx context.Context, gapID int64, createdBy *int64, report func(int, int)) (models.GapResearchResult, error) {
+	cfg := config.Current()
+	result := models.GapResearchResult{GapID: gapID}
+	if !webSearchEnabled() {
+		return result, notConfigured("SEARCH_PROVIDER is required for gap research")
+	}
+
+	var (
+		articleID int64
+		question  string
+		headline  string
+		language  string
+	)
+	err := s.store.QueryRowContext(
+		ctx,
+		`SELECT g.article_id, COALESCE(g.question, ''), COALESCE(a.headline_selected, ''), COALESCE(a.output_language, '')
+		FROM gaps g
+		JOIN articles a ON a.id = g.article_id
+		WHERE g.id = ?`,
+		gapID,
+	).Scan(&articleID, &question, &headline, &language)
+	if err != nil {
+		return result, err
+	}
+	if strings.TrimSpace(question) == "" {
+		return result, invalidInput("gap question is required for research")
+	}
+	if language == "" {
+		language = englishLanguage.Name
+	}
+	facts, err := listTexts(ctx, s.store, "SELECT COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY position ASC, id ASC", articleID)
+	if err != nil {
+		return result, err
+	}
+
+	result.Query = researchQuery(headline, question)
+	report(0, 1)
+	found, err := searchWeb(ctx, cfg, result.Query)
+	if err != nil {
+		return result, err
+	}
+	result.Results = len(found)
+	total := len(found) + 2
+	report(1, total)
+
+	client := newSourceFetchClient(sourceFetchTimeout)
+	sources := make([]string, len(found))
+	for idx, item := range found {
+		text := item.snippet
+		if target, err := CheckSourceURL(item.url); err == nil {
+			if page, err := sourceFetches.fetchPage(ctx, client, cfg, target); err == nil {
+				if pageText := page.text(); len(strings.Fields(pageText)) > len(strings.Fields(text)) {
+					text = pageText
+					result.Fetched++
+				}
+			} else if ctx.Err() != nil {
+				return result, ctx.Err()
+			} else {
+				log.Printf("[gap-research] gap %d: fetch %s: %v", gapID, previewForLog(item.url), err)
+			}
+		}
+		sources[idx] = fmt.Sprintf("%s (%s)\n%s", item.title, item.url, truncateRunes(text, researchSourceRunes))
+		report(idx+2, total)
+	}
+
+	drafts := make([]gapAnswerDraft, 0)
+	if len(sources) > 0 {
+		if err := s.applyRuntimeAISettings(ctx); err != nil {
+			return result, err
+		}
+		runID := newLLMRunID()
+		ctx = withLLMRunID(ctx, runID)
+		if activePrompts, err := loadPromptSet(ctx, s.store); err != nil {
+			log.Printf("[prompts] failed to load active prompts, using built-in defaults: %v", err)
+		} else {
+			ctx = withPromptSet(ctx, activePrompts)
+		}
+
+		drafts, err = s.ai.ResearchGap(ctx, question, facts, sources, language)
+		if err := s.llmCalls.AttachArticle(ctx, runID, articleID); err != nil {
+			log.Printf("[llm-calls] failed to link run %s to article %d: %v", runID, articleID, err)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM gap_answers WHERE gap_id = ? AND status = ?", gapID, models.GapAnswerPending); err != nil {
+			return err
+		}
+		for _, draft := range drafts {
+			cited := make([]models.GapAnswerSource, len(draft.sources))
+			for idx, source := range draft.sources {
+				cited[idx] = models.GapAnswerSource{Title: found[source-1].title, URL: found[source-1].url}
+			}
+			encoded, err := json.Marshal(cited)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(
+				ctx,
+				`INSERT INTO gap_answers (gap_id, article_id, answer, confidence, sources, search_query, status, created_by)
+				VALUES (`+repository.Placeholders(8)+`)`,
+				gapID,
+				articleID,
+				draft.answer,
+				draft.confidence,
+				string(encoded),
+				result.Query,
+				models.GapAnswerPending,
+				createdBy,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	report(total, total)
+	result.Answers = len(drafts)
+	return result, nil
+}
+
+// researchQuery is the gap's question, with the names from the analysis's
+// headline it doesn't already mention, so the search stays on the story.
+func researchQuery(headline string, question string) string {
+	query := strings.Join(strings.Fields(question), " ")
+	lowered := strings.ToLower(query)
+	added := make([]string, 0, 3)
+	for _, name := range properNames(headline) {
+		if len(added) == 3 {
+			break
+		}
+		if !strings.Contains(lowered, name) && !slices.Contains(added, name) {
+			added = append(added, name)
+		}
+	}
+	if len(added) == 0 {
+		return query
+	}
+	return query + " " + strings.Join(added, " ")
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+type GlossaryService struct {
+	store *repository.Store
+}
+
+func NewGlossaryService(database *sql.DB) *GlossaryService {
+	return &GlossaryService{
+		store: repository.New(database),
+	}
+}
+
+// GetGlossary returns the glossary for a target language. A language that has
+// never had a glossary comes back empty at version 0.
+func (s *GlossaryService) GetGlossary(ctx context.Context, language string) (models.Glossary, error) {
+	cleanLanguage, err := normalizeGlossaryLanguage(ctx, s.store, language)
+	if err != nil {
+		return models.Glossary{}, err
+	}
+
+	glossary := models.Glossary{
+		Language: cleanLanguage,
+		Terms:    make([]models.GlossaryTerm, 0),
+	}
+
+	var glossaryID int64
+	var updatedAt time.Time
+	query := "SELECT id, version, COALESCE(style_notes, ''), updated_at FROM glossaries WHERE language = ?"
+	err = s.store.QueryRowContext(ctx, query, cleanLanguage).Scan(&glossaryID, &glossary.Version, &glossary.StyleNotes, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return glossary, nil
+	}
+	if err != nil {
+		return models.Glossary{}, err
+	}
+	glossary.UpdatedAt = &updatedAt
+
+	termsQuery := `
+		SELECT id, source_term, target_term, COALESCE(notes, ''), updated_at
+		FROM glossary_terms
+		WHERE glossary_id = ?
+		ORDER BY source_term ASC
+	`
+	rows, err := s.store.QueryContext(ctx, termsQuery, glossaryID)
+	if err != nil {
+		return models.Glossary{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var term models.GlossaryTerm
+		if err := rows.Scan(&term.ID, &term.Source, &term.Target, &term.Notes, &term.UpdatedAt); err != nil {
+			return models.Glossary{}, err
+		}
+		glossary.Terms = append(glossary.Terms, term)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Glossary{}, err
+	}
+
+	stale, err := s.CountStaleTranslations(ctx, cleanLanguage, glossary.Version)
+	if err != nil {
+		return models.Glossary{}, err
+	}
+	glossary.StaleTranslations = stale
+
+	return glossary, nil
+}
+
+func (s *GlossaryService) CountStaleTranslations(ctx context.Context, language string, version int) (int64, error) {
+	var count int64
+	query := "SELECT COUNT(*) FROM translations WHERE target_language = ? AND glossary_version < ?"
+	if err := s.store.QueryRowContext(ctx, query, language, version).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *GlossaryService) AddTerm(ctx context.Context, language string, source string, target string, notes string) (models.Glossary, error) {
+	cleanSource := strings.TrimSpace(source)
+	cleanTarget := strings.TrimSpace(target)
+	if cleanSource == "" {
+		return models.Glossary{}, invalidInput("source term is required")
+	}
+	if cleanTarget == "" {
+		return models.Glossary{}, invalidInput("target term is required")
+	}
+
+	return s.change(ctx, language, func(tx *repository.Tx, glossaryID int64) error {
+		query := "INSERT INTO glossary_terms (glossary_id, source_term, target_term, notes) VALUES (?, ?, ?, ?)"
+		_, err := tx.ExecContext(ctx, query, glossaryID, cleanSource, cleanTarget, strings.TrimSpace(notes))
+		return err
+	})
+}
+
+func (s *GlossaryService) UpdateTerm(ctx context.Context, termID int64, source *string, target *string, notes *string) (models.Glossary, error) {
+	setClauses := make([]string, 0, 4)
+	args := make([]any, 0, 5)
+
+	if source != nil {
+		clean := strings.TrimSpace(*source)
+		if clean == "" {
+			return models.Glossary{}, invalidInput("source term is required")
+		}
+		setClauses = append(setClauses, "source_term = ?")
+		args = append(args, clean)
+	}
+	if target != nil {
+		clean := strings.TrimSpace(*target)
+		if clean == "" {
+			return models.Glossary{}, invalidInput("target term is required")
+		}
+		setClauses = append(setClauses, "target_term = ?")
+		args = append(args, clean)
+	}
+	if notes != nil {
+		setClauses = append(setClauses, "notes = ?")
+		args = append(args, strings.TrimSpace(*notes))
+	}
+	if len(setClauses) == 0 {
+		return models.Glossary{}, invalidInput("no glossary term fields provided")
+	}
+	setClauses = append(setClauses, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, termID)
+
+	language, err := s.termLanguage(ctx, termID)
+	if err != nil {
+		return models.Glossary{}, err
+	}
+
+	return s.change(ctx, language, func(tx *repository.Tx, glossaryID int64) error {
+		query := "UPDATE glossary_terms SET " + strings.Join(setClauses, ", ") + " WHERE id = ?"
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		return ensureRowsAffected(result)
+	})
+}
+
+func (s *GlossaryService) DeleteTerm(ctx context.Context, termID int64) (models.Glossary, error) {
+	language, err := s.termLanguage(ctx, termID)
+	if err != nil {
+		return models.Glossary{}, err
+	}
+
+	return s.change(ctx, language, func(tx *repository.Tx, glossaryID int64) error {
+		query := "DELETE FROM glossary_terms WHERE id = ?"
+		result, err := tx.ExecContext(ctx, query, termID)
+		if err != nil {
+			return err
+		}
+		return ensureRowsAffected(result)
+	})
+}
+
+func (s *GlossaryService) UpdateStyleNotes(ctx context.Context, language string, styleNotes string) (models.Glossary, error) {
+	return s.change(ctx, language, func(tx *repository.Tx, glossaryID int64) error {
+		query := "UPDATE glossaries SET style_notes = ? WHERE id = ?"
+		_, err := tx.ExecContext(ctx, query, strings.TrimSpace(styleNotes), glossaryID)
+		return err
+	})
+}
+
+// change applies a glossary edit and bumps the version in the same
+// transaction, so every stored translation made before the edit becomes stale.
+func (s *GlossaryService) change(ctx context.Context, language string, apply func(tx *repository.Tx, glossaryID int64) error) (models.Glossary, error) {
+	cleanLanguage, err := normalizeGlossaryLanguage(ctx, s.store, language)
+	if err != nil {
+		return models.Glossary{}, err
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		glossaryID, err := s.ensureGlossary(ctx, tx, cleanLanguage)
+		if err != nil {
+			return err
+		}
+
+		if err := apply(tx, glossaryID); err != nil {
+			return err
+		}
+
+		bumpQuery := "UPDATE glossaries SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?"
+		_, err = tx.ExecContext(ctx, bumpQuery, glossaryID)
+		return err
+	})
+	if err != nil {
+		return models.Glossary{}, err
+	}
+
+	return s.GetGlossary(ctx, cleanLanguage)
+}
+
+func (s *GlossaryService) ensureGlossary(ctx context.Context, tx *repository.Tx, language string) (int64, error) {
+	var glossaryID int64
+	query := "SELECT id FROM glossaries WHERE language = ?"
+	err := tx.QueryRowContext(ctx, query, language).Scan(&glossaryID)
+	if err == nil {
+		return glossaryID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	return tx.Insert(ctx, `INSERT INTO glossaries (language, version) VALUES (?, 0)`, language)
+}
+
+func (s *GlossaryService) termLanguage(ctx context.Context, termID int64) (string, error) {
+	var language string
+	query := `
+		SELECT g.language
+		FROM glossary_terms t
+		JOIN glossaries g ON g.id = t.glossary_id
+		WHERE t.id = ?
+	`
+	if err := s.store.QueryRowContext(ctx, query, termID).Scan(&language); err != nil {
+		return "", err
+	}
+	return language, nil
+}
+
+func normalizeGlossaryLanguage(ctx context.Context, store *repository.Store, language string) (string, error) {
+	if strings.TrimSpace(language) == "" {
+		return "", invalidInput("language is required")
+	}
+	resolved, err := resolveLanguage(ctx, store, language)
+	if err != nil {
+		return "", err
+	}
+	if resolved.Code == englishLanguage.Code {
+		return "", invalidInput("language must be a translation target such as Telugu")
+	}
+	return resolved.Name, nil
+}
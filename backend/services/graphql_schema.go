@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"nanoheads/graphql"
+	"nanoheads/models"
+)
+
+func nonNull(t graphql.Type) graphql.Type { return &graphql.NonNull{Of: t} }
+
+func listOf(t graphql.Type) graphql.Type {
+	return &graphql.NonNull{Of: &graphql.List{Of: &graphql.NonNull{Of: t}}}
+}
+
+// fromItem resolves a field of the listed analysis itself.
+func fromItem(read func(models.AnalysisListItem) any) graphql.ResolveFunc {
+	return func(_ context.Context, source any, _ map[string]any) (any, error) {
+		return read(source.(*analysisNode).item), nil
+	}
+}
+
+func (s *GraphQLService) fromContent(read func(analysisContent) any) graphql.ResolveFunc {
+	return func(ctx context.Context, source any, _ map[string]any) (any, error) {
+		content, err := s.content(ctx, source.(*analysisNode))
+		if err != nil {
+			return nil, err
+		}
+		return read(content), nil
+	}
+}
+
+func optionalString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func (s *GraphQLService) buildSchema() *graphql.Schema {
+	topic := &graphql.Object{
+		Name: "Topic",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: nonNull(graphql.ID)},
+			{Name: "name", Type: nonNull(graphql.String)},
+		},
+	}
+	assignee := &graphql.Object{
+		Name: "Assignee",
+		Fields: []*graphql.FieldDef{
+			{Name: "userId", Type: nonNull(graphql.ID)},
+			{Name: "name", Type: nonNull(graphql.String)},
+		},
+	}
+	sourceRating := &graphql.Object{
+		Name: "SourceRating",
+		Fields: []*graphql.FieldDef{
+			{Name: "domain", Type: nonNull(graphql.String)},
+			{Name: "name", Type: graphql.String},
+			{Name: "credibility", Type: nonNull(graphql.String)},
+			{Name: "flagged", Type: nonNull(graphql.Boolean)},
+		},
+	}
+	fact := &graphql.Object{
+		Name: "Fact",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: nonNull(graphql.ID)},
+			{Name: "text", Type: nonNull(graphql.String)},
+			{Name: "included", Type: nonNull(graphql.Boolean)},
+			{Name: "confirmed", Type: nonNull(graphql.Boolean)},
+			{Name: "source", Type: nonNull(graphql.String)},
+		},
+	}
+	gap := &graphql.Object{
+		Name:        "Gap",
+		Description: "An open question the sources leave unanswered.",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: nonNull(graphql.ID)},
+			{Name: "text", Type: nonNull(graphql.String)},
+			{Name: "selected", Type: nonNull(graphql.Boolean)},
+			{Name: "resolved", Type: nonNull(graphql.Boolean)},
+			{Name: "origin", Type: graphql.String, Resolve: func(_ context.Context, source any, _ map[string]any) (any, error) {
+				return optionalString(source.(models.AnalysisGap).Origin), nil
+			}},
+		},
+	}
+
+	analysis := &graphql.Object{
+		Name: "Analysis",
+		Fields: []*graphql.FieldDef{
+			{Name: "id", Type: nonNull(graphql.ID), Resolve: fromItem(func(item models.AnalysisListItem) any { return item.ID })},
+			{Name: "title", Type: nonNull(graphql.String), Resolve: fromItem(func(item models.AnalysisListItem) any { return item.Title })},
+			{Name: "status", Type: nonNull(graphql.String), Resolve: fromItem(func(item models.AnalysisListItem) any { return item.Status })},
+			{Name: "category", Type: nonNull(graphql.String), Resolve: fromItem(func(item models.AnalysisListItem) any { return item.Category })},
+			{Name: "suggestedCategory", Type: graphql.String, Resolve: fromItem(func(item models.AnalysisListItem) any { return optionalString(item.SuggestedCategory) })},
+			{Name: "createdAt", Type: nonNull(graphql.String), Resolve: fromItem(func(item models.AnalysisListItem) any { return item.CreatedAt })},
+			{Name: "createdBy", Type: graphql.ID, Resolve: fromItem(func(item models.AnalysisListItem) any { return item.CreatedBy })},
+			{Name: "assignee", Type: assignee, Resolve: fromItem(func(item models.AnalysisListItem) any { return item.Assignee })},
+			{Name: "sourceRating", Type: sourceRating, Resolve: fromItem(func(item models.AnalysisListItem) any { return item.SourceRating })},
+			{Name: "factCount", Type: nonNull(graphql.Int), Resolve: fromItem(func(item models.AnalysisListItem) any { return item.FactCount })},
+			{Name: "gapCount", Type: nonNull(graphql.Int), Resolve: fromItem(func(item models.AnalysisListItem) any { return item.GapCount })},
+			{Name: "hasHeadline", Type: nonNull(graphql.Boolean), Description: "Whether a headline has been selected.", Resolve: fromItem(func(item models.AnalysisListItem) any { return item.HasHeadline })},
+			{Name: "approvalStatus", Type: nonNull(graphql.String), Description: "approved, stale (edited since it was approved) or none.", Resolve: fromItem(func(item models.AnalysisListItem) any { return item.ApprovalStatus })},
+			{Name: "openFlags", Type: nonNull(graphql.Int), Description: "Legal flags not yet cleared.", Resolve: fromItem(func(item models.AnalysisListItem) any { return item.OpenFlags })},
+			{Name: "topic", Type: topic, Resolve: s.fromContent(func(content analysisContent) any { return content.topic })},
+			{Name: "headline", Type: graphql.String, Description: "The selected headline.", Resolve: s.fromContent(func(content analysisContent) any { return optionalString(content.headline) })},
+			{Name: "strapline", Type: graphql.String, Description: "The selected strapline.", Resolve: s.fromContent(func(content analysisContent) any { return optionalString(content.strapline) })},
+			{Name: "articleText", Type: nonNull(graphql.String), Resolve: s.fromContent(func(content analysisContent) any { return content.articleText })},
+			{Name: "sourceUrl", Type: graphql.String, Resolve: s.fromContent(func(content analysisContent) any { return optionalString(content.sourceURL) })},
+			{Name: "rawText", Type: graphql.String, Description: "Null without permission to view sources.", Resolve: s.fromContent(func(content analysisContent) any { return optionalString(content.rawText) })},
+			{Name: "outputLanguage", Type: nonNull(graphql.String), Resolve: s.fromContent(func(content analysisContent) any { return content.outputLanguage })},
+			{
+				Name: "facts",
+				Type: listOf(fact),
+				Args: []graphql.Argument{{Name: "included", Type: graphql.Boolean}},
+				Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+					facts, err := s.facts(ctx, source.(*analysisNode))
+					if err != nil {
+						return nil, err
+					}
+					included, ok := args["included"].(bool)
+					if !ok {
+						return facts, nil
+					}
+					filtered := make([]models.AnalysisFact, 0, len(facts))
+					for _, fact := range facts {
+						if fact.Included == included {
+							filtered = append(filtered, fact)
+						}
+					}
+					return filtered, nil
+				},
+			},
+			{
+				Name: "gaps",
+				Type: listOf(gap),
+				Args: []graphql.Argument{{Name: "resolved", Type: graphql.Boolean}},
+				Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+					gaps, err := s.gaps(ctx, source.(*analysisNode))
+					if err != nil {
+						return nil, err
+					}
+					resolved, ok := args["resolved"].(bool)
+					if !ok {
+						return gaps, nil
+					}
+					filtered := make([]models.AnalysisGap, 0, len(gaps))
+					for _, gap := range gaps {
+						if gap.Resolved == resolved {
+							filtered = append(filtered, gap)
+						}
+					}
+					return filtered, nil
+				},
+			},
+			{Name: "headlines", Type: listOf(graphql.String), Resolve: func(ctx context.Context, source any, _ map[string]any) (any, error) {
+				options, err := s.titles(ctx, source.(*analysisNode))
+				return options.headlines, err
+			}},
+			{Name: "straplines", Type: listOf(graphql.String), Resolve: func(ctx context.Context, source any, _ map[string]any) (any, error) {
+				options, err := s.titles(ctx, source.(*analysisNode))
+				return options.straplines, err
+			}},
+		},
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: []*graphql.FieldDef{
+			{
+				Name:        "analyses",
+				Description: "Analyses, newest first. assignedTo takes a user id, me or none; createdBy a user id or me.",
+				Type:        listOf(analysis),
+				Args: []graphql.Argument{
+					{Name: "limit", Type: graphql.Int, Default: 100},
+					{Name: "status", Type: graphql.String},
+					{Name: "category", Type: graphql.String},
+					{Name: "assignedTo", Type: graphql.String},
+					{Name: "createdBy", Type: graphql.String},
+					{Name: "days", Type: graphql.Int},
+				},
+				Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+					filter, err := analysisFilterArgs(ctx, args)
+					if err != nil {
+						return nil, err
+					}
+					limit, _ := args["limit"].(int)
+					items, err := s.admin.ListAnalyses(ctx, limit, filter, graphQLViewerFrom(ctx).Visibility)
+					if err != nil {
+						return nil, err
+					}
+					return newAnalysisNodes(items), nil
+				},
+			},
+			{
+				Name: "analysis",
+				Type: analysis,
+				Args: []graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}},
+				Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+					return s.analysis(ctx, args["id"])
+				},
+			},
+			{
+				Name: "topics",
+				Type: listOf(topic),
+				Resolve: func(ctx context.Context, _ any, _ map[string]any) (any, error) {
+					return s.listTopics(ctx)
+				},
+			},
+		},
+	}
+
+	return &graphql.Schema{Query: query}
+}
+
+// analysis loads one analysis. An analysis that doesn't exist is null
+// rather than an error, as a missing object usually is in GraphQL.
+func (s *GraphQLService) analysis(ctx context.Context, rawID any) (*analysisNode, error) {
+	id, err := parseGraphQLID(rawID)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := s.admin.GetAnalysisDetail(ctx, id, graphQLViewerFrom(ctx).Visibility)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	node := newAnalysisNodes([]models.AnalysisListItem{{
+		ID:           detail.ID,
+		Title:        detail.Title,
+		Category:     detail.Category,
+		Status:       detail.Status,
+		SourceRating: detail.SourceRating,
+		Assignee:     detail.Assignee,
+		CreatedBy:    detail.Submission.SubmittedBy,
+		CreatedAt:    detail.CreatedAt,
+	}})[0]
+	if detail.CategorySuggestion != nil {
+		node.item.SuggestedCategory = detail.CategorySuggestion.Category
+	}
+	node.item.FactCount = int64(len(detail.Facts))
+	for _, fact := range detail.Facts {
+		if fact.Included {
+			node.item.IncludedFacts++
+		}
+	}
+	node.item.GapCount = int64(len(detail.Gaps))
+	node.item.HasHeadline = strings.TrimSpace(detail.HeadlineSelected) != ""
+	node.item.ApprovalStatus = detail.ApprovalStatus
+	for _, flag := range detail.Flags {
+		if flag.ClearedAt == nil {
+			node.item.OpenFlags++
+		}
+	}
+	// The detail already has everything the nested fields need.
+	node.batch.facts = map[int64][]models.AnalysisFact{id: detail.Facts}
+	node.batch.gaps = map[int64][]models.AnalysisGap{id: detail.Gaps}
+	node.batch.titles = map[int64]titleOptions{id: {headlines: detail.HeadlineOptions, straplines: detail.StraplineOptions}}
+	return node, nil
+}
+
+func (s *GraphQLService) listTopics(ctx context.Context) ([]models.Topic, error) {
+	rows, err := s.store.QueryContext(ctx, "SELECT id, name FROM topics ORDER BY name ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := make([]models.Topic, 0)
+	for rows.Next() {
+		var topic models.Topic
+		if err := rows.Scan(&topic.ID, &topic.Name); err != nil {
+			return nil, err
+		}
+		if topic.Name = strings.TrimSpace(topic.Name); topic.Name != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, rows.Err()
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"nanoheads/graphql"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// GraphQLService answers read-only queries over analyses, so a dashboard can
+// fetch analyses with their facts, open questions and titles in one request.
+type GraphQLService struct {
+	store  *repository.Store
+	admin  *AdminService
+	schema *graphql.Schema
+}
+
+func NewGraphQLService(database *sql.DB) *GraphQLService {
+	s := &GraphQLService{
+		store: repository.New(database),
+		admin: NewAdminService(database),
+	}
+	s.schema = s.buildSchema()
+	return s
+}
+
+// GraphQLViewer is who a query runs as: "me" in filters is userID, and
+// visibility hides the same fields the REST endpoints do.
+type GraphQLViewer struct {
+	UserID     *int64
+	Visibility models.Visibility
+}
+
+type graphQLViewerKey struct{}
+
+func (s *GraphQLService) Execute(ctx context.Context, request graphql.Request, viewer GraphQLViewer) *graphql.Result {
+	if strings.TrimSpace(request.Query) == "" {
+		return &graphql.Result{Errors: []*graphql.Error{{Message: "query is required"}}}
+	}
+	return s.schema.Execute(context.WithValue(ctx, graphQLViewerKey{}, viewer), request)
+}
+
+// SDL is the schema in the GraphQL schema language.
+func (s *GraphQLService) SDL() string {
+	return s.schema.SDL()
+}
+
+func graphQLViewerFrom(ctx context.Context) GraphQLViewer {
+	viewer, _ := ctx.Value(graphQLViewerKey{}).(GraphQLViewer)
+	return viewer
+}
+
+// analysisNode is an analysis in a query result. Analyses listed together
+// share a batch, so a nested field is loaded for all of them with one query
+// the first time any of them asks for it.
+type analysisNode struct {
+	item  models.AnalysisListItem
+	batch *analysisBatch
+}
+
+type analysisBatch struct {
+	ids     []any
+	details map[int64]analysisContent
+	facts   map[int64][]models.AnalysisFact
+	gaps    map[int64][]models.AnalysisGap
+	titles  map[int64]titleOptions
+}
+
+// analysisContent holds the article columns the list doesn't return.
+type analysisContent struct {
+	articleText    string
+	sourceURL      string
+	rawText        string
+	outputLanguage string
+	headline       string
+	strapline      string
+	topic          *models.Topic
+}
+
+type titleOptions struct {
+	headlines  []string
+	straplines []string
+}
+
+func newAnalysisNodes(items []models.AnalysisListItem) []*analysisNode {
+	batch := &analysisBatch{ids: make([]any, 0, len(items))}
+	nodes := make([]*analysisNode, 0, len(items))
+	for _, item := range items {
+		batch.ids = append(batch.ids, item.ID)
+		nodes = append(nodes, &analysisNode{item: item, batch: batch})
+	}
+	return nodes
+}
+
+func (s *GraphQLService) content(ctx context.Context, node *analysisNode) (analysisContent, error) {
+	batch := node.batch
+	if batch.details == nil {
+		details, err := s.loadAnalysisContent(ctx, batch.ids, graphQLViewerFrom(ctx).Visibility)
+		if err != nil {
+			return analysisContent{}, err
+		}
+		batch.details = details
+	}
+	return batch.details[node.item.ID], nil
+}
+
+func (s *GraphQLService) loadAnalysisContent(ctx context.Context, ids []any, visibility models.Visibility) (map[int64]analysisContent, error) {
+	details := make(map[int64]analysisContent, len(ids))
+	if len(ids) == 0 {
+		return details, nil
+	}
+
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT
+			a.id,
+			COALESCE(a.article_text, ''),
+			COALESCE(a.source_url, ''),
+			COALESCE(a.raw_text, ''),
+			COALESCE(a.output_language, ''),
+			COALESCE(a.headline_selected, ''),
+			COALESCE(a.strapline_selected, ''),
+			a.topic_id,
+			COALESCE(t.name, '')
+		FROM articles a
+		LEFT JOIN topics t ON t.id = a.topic_id
+		WHERE a.id IN (`+repository.Placeholders(len(ids))+`)`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id        int64
+			content   analysisContent
+			topicID   sql.NullInt64
+			topicName string
+		)
+		if err := rows.Scan(&id, &content.articleText, &content.sourceURL, &content.rawText, &content.outputLanguage, &content.headline, &content.strapline, &topicID, &topicName); err != nil {
+			return nil, err
+		}
+		if !visibility.SourceText {
+			content.rawText = ""
+		}
+		if content.outputLanguage == "" {
+			content.outputLanguage = englishLanguage.Name
+		}
+		if topicID.Valid {
+			content.topic = &models.Topic{ID: topicID.Int64, Name: topicName}
+		}
+		details[id] = content
+	}
+	return details, rows.Err()
+}
+
+func (s *GraphQLService) facts(ctx context.Context, node *analysisNode) ([]models.AnalysisFact, error) {
+	batch := node.batch
+	if batch.facts == nil {
+		batch.facts = make(map[int64][]models.AnalysisFact, len(batch.ids))
+		rows, err := s.store.QueryContext(ctx, `
+			SELECT article_id, id, COALESCE(fact_text, ''), COALESCE(is_included, false), COALESCE(is_confirmed, false), COALESCE(source, '')
+			FROM facts
+			WHERE article_id IN (`+repository.Placeholders(len(batch.ids))+`) AND deleted_at IS NULL
+			ORDER BY article_id ASC, position ASC, id ASC`, batch.ids...)
+		if err != nil {
+			batch.facts = nil
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				articleID int64
+				fact      models.AnalysisFact
+			)
+			if err := rows.Scan(&articleID, &fact.ID, &fact.Text, &fact.Included, &fact.Confirmed, &fact.Source); err != nil {
+				batch.facts = nil
+				return nil, err
+			}
+			batch.facts[articleID] = append(batch.facts[articleID], fact)
+		}
+		if err := rows.Err(); err != nil {
+			batch.facts = nil
+			return nil, err
+		}
+	}
+	return nonNilSlice(batch.facts[node.item.ID]), nil
+}
+
+func (s *GraphQLService) gaps(ctx context.Context, node *analysisNode) ([]models.AnalysisGap, error) {
+	batch := node.batch
+	if batch.gaps == nil {
+		batch.gaps = make(map[int64][]models.AnalysisGap, len(batch.ids))
+		rows, err := s.store.QueryContext(ctx, `
+			SELECT article_id, id, COALESCE(question, ''), COALESCE(is_selected, true), COALESCE(is_resolved, false), COALESCE(origin, '')
+			FROM gaps
+			WHERE article_id IN (`+repository.Placeholders(len(batch.ids))+`)
+			ORDER BY article_id ASC, id ASC`, batch.ids...)
+		if err != nil {
+			batch.gaps = nil
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				articleID int64
+				gap       models.AnalysisGap
+			)
+			if err := rows.Scan(&articleID, &gap.ID, &gap.Text, &gap.Selected, &gap.Resolved, &gap.Origin); err != nil {
+				batch.gaps = nil
+				return nil, err
+			}
+			batch.gaps[articleID] = append(batch.gaps[articleID], gap)
+		}
+		if err := rows.Err(); err != nil {
+			batch.gaps = nil
+			return nil, err
+		}
+	}
+	return nonNilSlice(batch.gaps[node.item.ID]), nil
+}
+
+func (s *GraphQLService) titles(ctx context.Context, node *analysisNode) (titleOptions, error) {
+	batch := node.batch
+	if batch.titles == nil {
+		titles := make(map[int64]titleOptions, len(batch.ids))
+		for _, table := range []struct{ name, column string }{{"headlines", "headline_text"}, {"straplines", "strapline_text"}} {
+			rows, err := s.store.QueryContext(ctx, `
+				SELECT article_id, COALESCE(`+table.column+`, '')
+				FROM `+table.name+`
+				WHERE article_id IN (`+repository.Placeholders(len(batch.ids))+`)
+				ORDER BY article_id ASC, id ASC`, batch.ids...)
+			if err != nil {
+				return titleOptions{}, err
+			}
+			for rows.Next() {
+				var (
+					articleID int64
+					text      string
+				)
+				if err := rows.Scan(&articleID, &text); err != nil {
+					rows.Close()
+					return titleOptions{}, err
+				}
+				if text = strings.TrimSpace(text); text == "" {
+					continue
+				}
+				options := titles[articleID]
+				if table.name == "headlines" {
+					options.headlines = append(options.headlines, text)
+				} else {
+					options.straplines = append(options.straplines, text)
+				}
+				titles[articleID] = options
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return titleOptions{}, err
+			}
+		}
+		batch.titles = titles
+	}
+	options := batch.titles[node.item.ID]
+	return titleOptions{
+		headlines:  dedupeStrings(nonNilSlice(options.headlines)),
+		straplines: dedupeStrings(nonNilSlice(options.straplines)),
+	}, nil
+}
+
+func nonNilSlice[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+// analysisFilterArgs reads the filters of the analyses query the way the
+// REST list reads its query parameters.
+func analysisFilterArgs(ctx context.Context, args map[string]any) (models.AnalysisFilter, error) {
+	filter := models.AnalysisFilter{}
+	filter.Status, _ = args["status"].(string)
+	filter.Category, _ = args["category"].(string)
+	filter.Days, _ = args["days"].(int)
+
+	viewer := graphQLViewerFrom(ctx)
+	userFilter := func(key string) (*int64, error) {
+		value, _ := args[key].(string)
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, nil
+		}
+		if strings.EqualFold(value, "me") {
+			if viewer.UserID == nil {
+				return nil, invalidInput(key + ": me needs a signed-in user")
+			}
+			return viewer.UserID, nil
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, invalidInput("invalid " + key)
+		}
+		return &id, nil
+	}
+
+	assignedTo, _ := args["assignedTo"].(string)
+	if strings.EqualFold(strings.TrimSpace(assignedTo), "none") {
+		filter.Unassigned = true
+	} else {
+		id, err := userFilter("assignedTo")
+		if err != nil {
+			return models.AnalysisFilter{}, err
+		}
+		filter.AssignedTo = id
+	}
+	id, err := userFilter("createdBy")
+	if err != nil {
+		return models.AnalysisFilter{}, err
+	}
+	filter.CreatedBy = id
+	return filter, nil
+}
+
+func parseGraphQLID(value any) (int64, error) {
+	text, _ := value.(string)
+	id, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, invalidInput("invalid id")
+	}
+	return id, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const noIncludedFactsReason = "The analysis has no included facts to support this sentence."
+
+type groundingPayload struct {
+	ArticleID int64 `json:"articleId"`
+}
+
+// Abbreviations whose trailing full stop doesn't end a sentence.
+var sentenceAbbreviations = map[string]struct{}{
+	"mr": {}, "mrs": {}, "ms": {}, "dr": {}, "prof": {}, "st": {}, "gen": {}, "col": {}, "lt": {}, "sgt": {},
+	"gov": {}, "sen": {}, "rep": {}, "jr": {}, "sr": {}, "no": {}, "vs": {}, "etc": {}, "inc": {}, "ltd": {},
+	"co": {}, "corp": {}, "jan": {}, "feb": {}, "mar": {}, "apr": {}, "jun": {}, "jul": {}, "aug": {},
+	"sep": {}, "sept": {}, "oct": {}, "nov": {}, "dec": {},
+}
+
+// EnqueueGroundingCheck queues a check of an analysis's article against its
+// included facts.
+func EnqueueGroundingCheck(ctx context.Context, jobs *JobService, articleID int64, createdBy *int64) (models.Job, error) {
+	var exists int
+	if err := jobs.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.Job{}, err
+	}
+	return jobs.Enqueue(ctx, models.JobTypeGrounding, groundingPayload{ArticleID: articleID}, createdBy)
+}
+
+// CheckGrounding asks the model which sentences of an analysis's article the
+// included facts don't support, and replaces the stored flags. Without any
+// included facts, every sentence is flagged.
+func (s *FactService) CheckGrounding(ctx context.Context, articleID int64, report func(int, int)) (models.GroundingResult, error) {
+	var articleText, language string
+	err := s.store.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(article_text, ''), COALESCE(output_language, '') FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&articleText, &language)
+	if err != nil {
+		return models.GroundingResult{}, err
+	}
+	if language == "" {
+		language = englishLanguage.Name
+	}
+
+	facts, err := listTexts(ctx, s.store, "SELECT COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY position ASC, id ASC", articleID)
+	if err != nil {
+		return models.GroundingResult{}, err
+	}
+	gaps, err := listTexts(ctx, s.store, "SELECT COALESCE(question, '') FROM gaps WHERE article_id = ? ORDER BY id ASC", articleID)
+	if err != nil {
+		return models.GroundingResult{}, err
+	}
+
+	sentences := splitSentences(articleText)
+	result := models.GroundingResult{ArticleID: articleID, Sentences: len(sentences)}
+	report(0, 1)
+
+	flagged := make([]models.UnsupportedSentence, 0)
+	switch {
+	case len(sentences) == 0:
+	case len(facts) == 0:
+		for idx, sentence := range sentences {
+			flagged = append(flagged, models.UnsupportedSentence{Position: idx + 1, Sentence: sentence, Reason: noIncludedFactsReason})
+		}
+	default:
+		if err := s.applyRuntimeAISettings(ctx); err != nil {
+			return result, err
+		}
+		runID := newLLMRunID()
+		ctx = withLLMRunID(ctx, runID)
+		if activePrompts, err := loadPromptSet(ctx, s.store); err != nil {
+			log.Printf("[prompts] failed to load active prompts, using built-in defaults: %v", err)
+		} else {
+			ctx = withPromptSet(ctx, activePrompts)
+		}
+
+		flagged, err = s.ai.CheckGrounding(ctx, facts, gaps, sentences, language)
+		if err := s.llmCalls.AttachArticle(ctx, runID, articleID); err != nil {
+			log.Printf("[llm-calls] failed to link run %s to article %d: %v", runID, articleID, err)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM unsupported_sentences WHERE article_id = ?", articleID); err != nil {
+			return err
+		}
+		for _, sentence := range flagged {
+			_, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO unsupported_sentences (article_id, position, sentence, reason) VALUES (?, ?, ?, ?)",
+				articleID,
+				sentence.Position,
+				sentence.Sentence,
+				nullString(sentence.Reason),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE articles SET grounding_checked_at = CURRENT_TIMESTAMP WHERE id = ?", articleID)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	report(1, 1)
+	result.Unsupported = len(flagged)
+	return result, nil
+}
+
+// listUnsupportedSentences returns the flags from the last grounding check
+// that are still in articleText; sentences edited since then drop out.
+func listUnsupportedSentences(ctx context.Context, store *repository.Store, articleID int64, articleText string) ([]models.UnsupportedSentence, error) {
+	rows, err := store.QueryContext(ctx, "SELECT id, position, sentence, COALESCE(reason, '') FROM unsupported_sentences WHERE article_id = ? ORDER BY position ASC", articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	current := strings.Join(strings.Fields(articleText), " ")
+	sentences := make([]models.UnsupportedSentence, 0)
+	for rows.Next() {
+		var sentence models.UnsupportedSentence
+		if err := rows.Scan(&sentence.ID, &sentence.Position, &sentence.Sentence, &sentence.Reason); err != nil {
+			return nil, err
+		}
+		if strings.Contains(current, strings.Join(strings.Fields(sentence.Sentence), " ")) {
+			sentences = append(sentences, sentence)
+		}
+	}
+	return sentences, rows.Err()
+}
+
+func listTexts(ctx context.Context, store repository.Querier, query string, args ...any) ([]string, error) {
+	rows, err := store.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values, rows.Err()
+}
+
+// splitSentences breaks an article into sentences, one paragraph at a time.
+// A short line without closing punctuation is a section subheading and is
+// left out.
+func splitSentences(text string) []string {
+	sentences := make([]string, 0)
+	for _, paragraph := range strings.Split(text, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		last, _ := utf8.DecodeLastRuneInString(strings.TrimRight(paragraph, `"'”’)`))
+		if !isSentenceEnd(last) && len(strings.Fields(paragraph)) <= 8 {
+			continue
+		}
+
+		start := 0
+		runes := []rune(paragraph)
+		for idx, r := range runes {
+			if !isSentenceEnd(r) {
+				continue
+			}
+			end := idx + 1
+			for end < len(runes) && strings.ContainsRune(`"'”’)`, runes[end]) {
+				end++
+			}
+			if end < len(runes) && !unicode.IsSpace(runes[end]) {
+				continue
+			}
+			if r == '.' && isAbbreviation(runes[start:idx]) {
+				continue
+			}
+			if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+				sentences = append(sentences, sentence)
+			}
+			start = end
+		}
+		if rest := strings.TrimSpace(string(runes[start:])); rest != "" {
+			sentences = append(sentences, rest)
+		}
+	}
+	return sentences
+}
+
+func isSentenceEnd(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '।'
+}
+
+// isAbbreviation reports whether the word before a full stop is a known
+// abbreviation or an initial, as in "U.S." or "J. Smith".
+func isAbbreviation(before []rune) bool {
+	fields := strings.Fields(string(before))
+	if len(fields) == 0 {
+		return false
+	}
+	word := strings.TrimLeft(fields[len(fields)-1], `"'“‘(`)
+	if utf8.RuneCountInString(word) == 1 && unicode.IsLetter([]rune(word)[0]) {
+		return true
+	}
+	if strings.Contains(word, ".") {
+		return true
+	}
+	_, ok := sentenceAbbreviations[strings.ToLower(word)]
+	return ok
+}
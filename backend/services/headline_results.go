@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	maxHeadlineVariants  = 20
+	defaultHeadlineDays  = 90
+	headlineGuidanceDays = 90
+	// A group needs this many impressions before guidance mentions it, and
+	// has to beat the overall click-through rate by guidanceLift.
+	minGuidanceImpressions = 1000
+	guidanceLift           = 1.1
+)
+
+// Word counts up to these are short and medium headlines; longer ones are
+// long.
+const (
+	shortHeadlineWords  = 6
+	mediumHeadlineWords = 12
+)
+
+var headlineLengthGroups = map[string]string{
+	"short":  fmt.Sprintf("%d words or fewer", shortHeadlineWords),
+	"medium": fmt.Sprintf("%d–%d words", shortHeadlineWords+1, mediumHeadlineWords),
+	"long":   fmt.Sprintf("more than %d words", mediumHeadlineWords),
+}
+
+// RecordHeadlineResults stores the platform's latest click-through figures
+// for an analysis's headline test. Figures are cumulative, so a variant
+// reported again replaces what was recorded for it in the same test.
+func (s *StatsService) RecordHeadlineResults(ctx context.Context, articleID int64, testID string, platform string, variants []models.HeadlineVariant) ([]models.HeadlineResult, error) {
+	testID = strings.TrimSpace(testID)
+	platform = strings.TrimSpace(platform)
+	if utf8.RuneCountInString(testID) > 128 {
+		return nil, invalidInput("testId must be at most 128 characters")
+	}
+	if utf8.RuneCountInString(platform) > 64 {
+		return nil, invalidInput("platform must be at most 64 characters")
+	}
+	if len(variants) == 0 {
+		return nil, invalidInput("variants is required")
+	}
+	if len(variants) > maxHeadlineVariants {
+		return nil, invalidInputf("at most %d variants can be recorded at once", maxHeadlineVariants)
+	}
+	for idx, variant := range variants {
+		variant.Headline = strings.Join(strings.Fields(variant.Headline), " ")
+		switch {
+		case variant.Headline == "":
+			return nil, invalidInputf("variant %d: headline is required", idx+1)
+		case utf8.RuneCountInString(variant.Headline) > maxTitleChars:
+			return nil, invalidInputf("variant %d: headline must be at most %d characters", idx+1, maxTitleChars)
+		case variant.Impressions < 0 || variant.Clicks < 0:
+			return nil, invalidInputf("variant %d: impressions and clicks must be zero or positive", idx+1)
+		case variant.Clicks > variant.Impressions:
+			return nil, invalidInputf("variant %d: clicks must be at most impressions", idx+1)
+		}
+		variants[idx] = variant
+	}
+
+	var exists int
+	if err := s.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	generated, err := s.generatedHeadlines(ctx, articleID)
+	if err != nil {
+		return nil, err
+	}
+	var model string
+	err = s.store.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(model, '') FROM llm_calls WHERE article_id = ? AND step = ? AND status = ? ORDER BY id DESC LIMIT 1",
+		articleID,
+		"generate-headlines",
+		"ok",
+	).Scan(&model)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"headline_results",
+		[]string{"article_id", "test_key", "headline_hash", "headline_id", "headline_text", "platform", "style", "word_count", "model", "impressions", "clicks"},
+		[]string{"article_id", "test_key", "headline_hash"},
+		[]string{"headline_id", "headline_text", "platform", "style", "word_count", "model", "impressions", "clicks"},
+		"updated_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		for _, variant := range variants {
+			key := headlineKey(variant.Headline)
+			headlineID, ok := generated[key]
+			// Only a generated option is credited to the model; a headline
+			// an editor wrote or reworded is nobody's.
+			variantModel := ""
+			var id *int64
+			if ok {
+				id, variantModel = &headlineID, model
+			}
+			sum := sha256.Sum256([]byte(key))
+			_, err := tx.ExecContext(
+				ctx,
+				query,
+				articleID,
+				testID,
+				hex.EncodeToString(sum[:]),
+				id,
+				variant.Headline,
+				nullString(platform),
+				headlineStyle(variant.Headline),
+				len(strings.Fields(variant.Headline)),
+				nullString(variantModel),
+				variant.Impressions,
+				variant.Clicks,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.headlineResults(ctx, articleID, testID)
+}
+
+// generatedHeadlines maps the analysis's headline options, by headlineKey,
+// to their ids.
+func (s *StatsService) generatedHeadlines(ctx context.Context, articleID int64) (map[string]int64, error) {
+	rows, err := s.store.QueryContext(ctx, "SELECT id, COALESCE(headline_text, '') FROM headlines WHERE article_id = ?", articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	generated := make(map[string]int64)
+	for rows.Next() {
+		var (
+			id   int64
+			text string
+		)
+		if err := rows.Scan(&id, &text); err != nil {
+			return nil, err
+		}
+		generated[headlineKey(text)] = id
+	}
+	return generated, rows.Err()
+}
+
+func (s *StatsService) headlineResults(ctx context.Context, articleID int64, testID string) ([]models.HeadlineResult, error) {
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT id, article_id, headline_id, test_key, headline_text, COALESCE(platform, ''), style, word_count, COALESCE(model, ''),
+			impressions, clicks, COALESCE(updated_at, CURRENT_TIMESTAMP)
+		FROM headline_results
+		WHERE article_id = ? AND test_key = ?
+		ORDER BY id ASC`,
+		articleID,
+		testID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.HeadlineResult, 0)
+	for rows.Next() {
+		var (
+			result     models.HeadlineResult
+			headlineID sql.NullInt64
+		)
+		err := rows.Scan(
+			&result.ID,
+			&result.ArticleID,
+			&headlineID,
+			&result.TestID,
+			&result.Headline,
+			&result.Platform,
+			&result.Style,
+			&result.WordCount,
+			&result.Model,
+			&result.Impressions,
+			&result.Clicks,
+			&result.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result.HeadlineID = nullInt64Pointer(headlineID)
+		result.ClickThroughRate = clickThroughRate(result.Clicks, result.Impressions)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// HeadlineStats groups the headline results updated in the last days days,
+// ninety by default, by style, length and model.
+func (s *StatsService) HeadlineStats(ctx context.Context, days int) (models.HeadlineStats, error) {
+	if days == 0 {
+		days = defaultHeadlineDays
+	}
+	period, err := normalizeAnalyticsPeriod(models.AnalyticsPeriod{Bucket: "day", Days: days})
+	if err != nil {
+		return models.HeadlineStats{}, err
+	}
+	return headlineStats(ctx, s.store, period.Days)
+}
+
+func headlineStats(ctx context.Context, q repository.Querier, days int) (models.HeadlineStats, error) {
+	stats := models.HeadlineStats{Days: days}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+
+	lengthGroup := fmt.Sprintf(
+		"CASE WHEN word_count <= %d THEN 'short' WHEN word_count <= %d THEN 'medium' ELSE 'long' END",
+		shortHeadlineWords,
+		mediumHeadlineWords,
+	)
+	groupings := []struct {
+		expression string
+		target     *[]models.HeadlinePerformance
+	}{
+		{"style", &stats.ByStyle},
+		{lengthGroup, &stats.ByLength},
+		{"COALESCE(model, '')", &stats.ByModel},
+	}
+	for _, grouping := range groupings {
+		rows, err := q.QueryContext(
+			ctx,
+			"SELECT "+grouping.expression+", COUNT(*), COALESCE(SUM(impressions), 0), COALESCE(SUM(clicks), 0) FROM headline_results WHERE updated_at >= ? GROUP BY "+grouping.expression,
+			since,
+		)
+		if err != nil {
+			return stats, err
+		}
+		groups := make([]models.HeadlinePerformance, 0)
+		for rows.Next() {
+			var group models.HeadlinePerformance
+			if err := rows.Scan(&group.Group, &group.Variants, &group.Impressions, &group.Clicks); err != nil {
+				rows.Close()
+				return stats, err
+			}
+			group.ClickThroughRate = clickThroughRate(group.Clicks, group.Impressions)
+			groups = append(groups, group)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return stats, err
+		}
+		sort.SliceStable(groups, func(i, j int) bool {
+			if groups[i].ClickThroughRate != groups[j].ClickThroughRate {
+				return groups[i].ClickThroughRate > groups[j].ClickThroughRate
+			}
+			return groups[i].Impressions > groups[j].Impressions
+		})
+		*grouping.target = groups
+	}
+
+	for _, group := range stats.ByStyle {
+		stats.Variants += group.Variants
+		stats.Impressions += group.Impressions
+		stats.Clicks += group.Clicks
+	}
+	stats.ClickThroughRate = clickThroughRate(stats.Clicks, stats.Impressions)
+	stats.Guidance = headlineGuidance(stats)
+	return stats, nil
+}
+
+// headlineGuidance names the style and length that have done clearly better
+// than average, among those seen often enough to tell. Models aren't
+// mentioned; a prompt can't change which model reads it.
+func headlineGuidance(stats models.HeadlineStats) string {
+	if stats.Impressions == 0 {
+		return ""
+	}
+	findings := make([]string, 0, 2)
+	best := func(groups []models.HeadlinePerformance) (models.HeadlinePerformance, bool) {
+		qualified := 0
+		for _, group := range groups {
+			if group.Impressions >= minGuidanceImpressions {
+				qualified++
+			}
+		}
+		// groups is sorted best first, so the first qualified one leads.
+		for _, group := range groups {
+			if qualified < 2 || group.Impressions < minGuidanceImpressions {
+				continue
+			}
+			return group, group.ClickThroughRate >= stats.ClickThroughRate*guidanceLift
+		}
+		return models.HeadlinePerformance{}, false
+	}
+	if group, ok := best(stats.ByStyle); ok {
+		findings = append(findings, fmt.Sprintf("%s headlines drew %s of readers who saw them", headlineStyleLabel(group.Group), formatRate(group.ClickThroughRate)))
+	}
+	if group, ok := best(stats.ByLength); ok {
+		findings = append(findings, fmt.Sprintf("headlines of %s drew %s", headlineLengthGroups[group.Group], formatRate(group.ClickThroughRate)))
+	}
+	if len(findings) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(
+		"In this newsroom's headline tests over the last %d days, %s, against %s overall. Where the facts allow, lean towards what has worked; never trade accuracy for clicks.",
+		stats.Days,
+		strings.Join(findings, ", and "),
+		formatRate(stats.ClickThroughRate),
+	)
+}
+
+type headlineGuidanceKey struct{}
+
+// withHeadlineGuidance adds what past headline tests taught to the run's
+// headline prompts. Without enough results, or with the feature flag off,
+// the prompts are left as they are.
+func (s *FactService) withHeadlineGuidance(ctx context.Context) context.Context {
+	if !featureEnabled(ctx, s.store, models.FlagHeadlineGuidance, llmRunIDFromContext(ctx)) {
+		return ctx
+	}
+	stats, err := headlineStats(ctx, s.store, headlineGuidanceDays)
+	if err != nil {
+		log.Printf("[headlines] failed to load test results, generating without guidance: %v", err)
+		return ctx
+	}
+	if stats.Guidance == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, headlineGuidanceKey{}, stats.Guidance)
+}
+
+func headlineGuidanceConstraint(ctx context.Context) string {
+	guidance, _ := ctx.Value(headlineGuidanceKey{}).(string)
+	if guidance == "" {
+		return ""
+	}
+	return "\n\nLearned guidance: " + guidance
+}
+
+// headlineStyle sorts a headline by its most telling feature.
+func headlineStyle(headline string) string {
+	switch {
+	case strings.HasSuffix(headline, "?"):
+		return models.HeadlineStyleQuestion
+	case quoted(headline):
+		return models.HeadlineStyleQuote
+	case strings.IndexFunc(headline, unicode.IsDigit) >= 0:
+		return models.HeadlineStyleNumber
+	case strings.Contains(headline, ":"):
+		return models.HeadlineStyleLabel
+	default:
+		return models.HeadlineStyleStatement
+	}
+}
+
+// quoted reports whether headline has a quoted passage rather than an
+// apostrophe.
+func quoted(headline string) bool {
+	if strings.Count(headline, "\"") >= 2 || strings.Contains(headline, "“") {
+		return true
+	}
+	return strings.Contains(headline, " '") || strings.HasPrefix(headline, "'") || strings.Contains(headline, "‘")
+}
+
+func headlineStyleLabel(style string) string {
+	switch style {
+	case models.HeadlineStyleQuestion:
+		return "question"
+	case models.HeadlineStyleQuote:
+		return "quote-led"
+	case models.HeadlineStyleNumber:
+		return "number-led"
+	case models.HeadlineStyleLabel:
+		return "colon-split"
+	default:
+		return "plain statement"
+	}
+}
+
+func headlineKey(headline string) string {
+	return strings.ToLower(strings.Join(strings.Fields(headline), " "))
+}
+
+func clickThroughRate(clicks int64, impressions int64) float64 {
+	if impressions == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(impressions)
+}
+
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
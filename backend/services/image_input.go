@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/prompts"
+	"nanoheads/repository"
+)
+
+const defaultGroqVisionModel = "meta-llama/llama-4-scout-17b-16e-instruct"
+
+// MaxAnalysisImages caps the images in one analysis; each one is a vision
+// model call.
+const MaxAnalysisImages = 4
+
+// ImageExtensions maps the image types accepted for OCR to the extension used
+// when storing them.
+var ImageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+type visionCompletionRequest struct {
+	Model       string          `json:"model"`
+	Messages    []visionMessage `json:"messages"`
+	Temperature float64         `json:"temperature"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+}
+
+type visionMessage struct {
+	Role    string        `json:"role"`
+	Content []contentPart `json:"content"`
+}
+
+type contentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *imageURLPart `json:"image_url,omitempty"`
+}
+
+type imageURLPart struct {
+	URL string `json:"url"`
+}
+
+type resolvedImage struct {
+	id         int64
+	fileName   string
+	mimeType   string
+	data       []byte
+	sha256     string
+	storageKey string
+	text       string
+}
+
+// ExtractImageText transcribes the text in an image with the provider's
+// vision model. The recorded call keeps the prompt but not the image bytes.
+func (s *OpenAIService) ExtractImageText(ctx context.Context, mimeType string, data []byte) (string, error) {
+	const step = "ocr-image"
+	userPrompt := renderPrompt(ctx, prompts.KeyImageText, nil)
+	recordedPrompt := fmt.Sprintf("%s\n\n[image: %s, %d bytes]", userPrompt, mimeType, len(data))
+	target, err := s.routeCall(step)
+	if err != nil {
+		return "", err
+	}
+	model := target.visionModel()
+
+	started := time.Now()
+	content, err := target.sendImageCompletion(ctx, step, model, userPrompt, recordedPrompt, mimeType, data)
+	target.recordCall(ctx, step, model, "", recordedPrompt, false, content, err, time.Since(started))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stripCodeFence(content)), nil
+}
+
+func (s *OpenAIService) sendImageCompletion(
+	ctx context.Context,
+	step string,
+	model string,
+	userPrompt string,
+	recordedPrompt string,
+	mimeType string,
+	data []byte,
+) (string, error) {
+	if s.provider == mockProvider {
+		return mockCompletion(ctx, step, recordedPrompt)
+	}
+
+	requestBody := visionCompletionRequest{
+		Model: model,
+		Messages: []visionMessage{
+			{
+				Role: "user",
+				Content: []contentPart{
+					{Type: "text", Text: userPrompt},
+					{Type: "image_url", ImageURL: &imageURLPart{URL: "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)}},
+				},
+			},
+		},
+		MaxTokens: 1500,
+	}
+	if s.maxTokens > 0 && s.maxTokens < requestBody.MaxTokens {
+		requestBody.MaxTokens = s.maxTokens
+	}
+
+	log.Printf("[groq][%s] model=%s provider=%s image=%s bytes=%d", step, model, s.provider, mimeType, len(data))
+	return s.postCompletion(ctx, step, requestBody)
+}
+
+// visionModel is OCR_MODEL when set. Groq's text models can't read images,
+// so it falls back to Groq's vision model there and to the chat model for
+// OpenAI, whose default model accepts images.
+func (s *OpenAIService) visionModel() string {
+	if model := config.Current().OCRModel; model != "" && s.provider != mockProvider {
+		return model
+	}
+	if s.provider == "groq" {
+		return defaultGroqVisionModel
+	}
+	return s.model
+}
+
+func (s *FactService) readImages(ctx context.Context, inputs []models.ImageInput) ([]resolvedImage, error) {
+	if len(inputs) > MaxAnalysisImages {
+		return nil, invalidInputf("at most %d images are allowed", MaxAnalysisImages)
+	}
+
+	images := make([]resolvedImage, 0, len(inputs))
+	for idx, input := range inputs {
+		if _, ok := ImageExtensions[input.MimeType]; !ok {
+			return nil, invalidInputf("image %d: type %q is not supported", idx+1, input.MimeType)
+		}
+		if len(input.Data) == 0 {
+			return nil, invalidInputf("image %d is empty", idx+1)
+		}
+
+		text, err := s.ai.ExtractImageText(ctx, input.MimeType, input.Data)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", idx+1, err)
+		}
+		if text == "" {
+			return nil, invalidInputf("image %d: no readable text was found", idx+1)
+		}
+
+		sum := sha256.Sum256(input.Data)
+		images = append(images, resolvedImage{
+			fileName: cleanImageFileName(input.FileName),
+			mimeType: input.MimeType,
+			data:     input.Data,
+			sha256:   hex.EncodeToString(sum[:]),
+			text:     text,
+		})
+	}
+	return images, nil
+}
+
+// cleanImageFileName keeps only the base name a client sent; it is shown to
+// editors but never used as a path.
+func cleanImageFileName(name string) string {
+	clean := filepath.Base(strings.ReplaceAll(strings.TrimSpace(name), "\\", "/"))
+	if clean == "." || clean == ".." || clean == "/" {
+		return ""
+	}
+	return truncateRunes(clean, 255)
+}
+
+// imageInputText puts the transcribed images ahead of any text that came with
+// them.
+func imageInputText(images []resolvedImage, text string) string {
+	parts := make([]string, 0, len(images)+1)
+	for _, image := range images {
+		parts = append(parts, image.text)
+	}
+	if clean := strings.TrimSpace(text); clean != "" {
+		parts = append(parts, clean)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// storeImages writes each image under UPLOAD_DIR by content hash. The same
+// image submitted twice is stored once.
+func storeImages(images []resolvedImage) error {
+	root := config.Current().UploadDir
+	for idx := range images {
+		key := filepath.ToSlash(filepath.Join(images[idx].sha256[:2], images[idx].sha256+ImageExtensions[images[idx].mimeType]))
+		path := filepath.Join(root, filepath.FromSlash(key))
+
+		if err := writeStoredFile(path, images[idx].data); err != nil {
+			return fmt.Errorf("store image: %w", err)
+		}
+		images[idx].storageKey = key
+	}
+	return nil
+}
+
+// writeStoredFile writes a content-addressed file unless it is already
+// there, through a temporary file so readers never see a partial one.
+func writeStoredFile(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := temp.Write(data)
+	closeErr := temp.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return nil
+}
+
+// ImagePath is where a stored image lives on disk.
+func ImagePath(storageKey string) string {
+	return filepath.Join(config.Current().UploadDir, filepath.FromSlash(storageKey))
+}
+
+func imageSummaries(images []resolvedImage) []models.AnalysisImage {
+	summaries := make([]models.AnalysisImage, len(images))
+	for idx, image := range images {
+		summaries[idx] = models.AnalysisImage{
+			ID:        image.id,
+			Position:  idx + 1,
+			FileName:  image.fileName,
+			MimeType:  image.mimeType,
+			SizeBytes: int64(len(image.data)),
+			SHA256:    image.sha256,
+			OCRText:   image.text,
+		}
+	}
+	return summaries
+}
+
+func insertArticleImages(ctx context.Context, tx *repository.Tx, articleID int64, images []resolvedImage) error {
+	query := `
+		INSERT INTO article_images (article_id, position, file_name, mime_type, size_bytes, sha256, storage_key, ocr_text)
+		VALUES (` + repository.Placeholders(8) + `)`
+	for idx, image := range images {
+		id, err := tx.Insert(
+			ctx,
+			query,
+			articleID,
+			idx+1,
+			nullString(image.fileName),
+			image.mimeType,
+			len(image.data),
+			image.sha256,
+			image.storageKey,
+			image.text,
+		)
+		if err != nil {
+			return err
+		}
+		images[idx].id = id
+	}
+	return nil
+}
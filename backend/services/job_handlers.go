@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"nanoheads/models"
+)
+
+// RegisterJobHandlers wires the background job types into a worker.
+func RegisterJobHandlers(jobs *JobService, database *sql.DB) {
+	factService := NewFactService(database)
+	factChecks := NewFactCheckService(database)
+	threads := NewStoryThreadService(database)
+	gapRechecks := NewGapRecheckService(database)
+	entities := NewEntityService(database)
+	digests := NewDigestService(database)
+	retention := NewRetentionService(database)
+	imports := NewImportService(database)
+	telegram := NewTelegramService(database)
+
+	jobs.Register(models.JobTypeRetranslate, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload retranslatePayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid retranslate payload: %w", err)
+		}
+		return factService.RetranslateStale(ctx, payload.Language, report)
+	})
+
+	jobs.Register(models.JobTypeClip, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var clip models.Clip
+		if err := json.Unmarshal(job.Payload, &clip); err != nil {
+			return nil, fmt.Errorf("invalid clip payload: %w", err)
+		}
+		report(0, 1)
+		result, err := factService.RunPhaseOne(ctx, clipPhaseOneInput(clip))
+		if err != nil {
+			return nil, err
+		}
+		report(1, 1)
+		return result, nil
+	})
+
+	jobs.Register(models.JobTypeFactCheck, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload factCheckPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid fact-check payload: %w", err)
+		}
+		return factChecks.CheckArticle(ctx, payload.ArticleID, report)
+	})
+
+	jobs.Register(models.JobTypeGrounding, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload groundingPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid grounding payload: %w", err)
+		}
+		return factService.CheckGrounding(ctx, payload.ArticleID, report)
+	})
+
+	jobs.Register(models.JobTypeSensitivity, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload sensitivityPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid sensitivity payload: %w", err)
+		}
+		return factService.CheckSensitivity(ctx, payload.ArticleID, report)
+	})
+
+	jobs.Register(models.JobTypeFollowUp, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload followUpPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid follow-up payload: %w", err)
+		}
+		return threads.DetectFollowUp(ctx, payload.ArticleID, report)
+	})
+
+	jobs.Register(models.JobTypeGapResearch, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload gapResearchPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid gap research payload: %w", err)
+		}
+		return factService.ResearchGap(ctx, payload.GapID, job.CreatedBy, report)
+	})
+
+	jobs.Register(models.JobTypeGapRecheck, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		return gapRechecks.Recheck(ctx, report)
+	})
+
+	jobs.Register(models.JobTypeEntities, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload entityPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid entity payload: %w", err)
+		}
+		return entities.Enrich(ctx, payload.ArticleID, report)
+	})
+
+	jobs.Register(models.JobTypeDigest, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		return digests.Send(ctx, report)
+	})
+
+	jobs.Register(models.JobTypeRetention, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		return retention.Run(ctx, report)
+	})
+
+	jobs.Register(models.JobTypeImport, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var request models.ArticleImport
+		if err := json.Unmarshal(job.Payload, &request); err != nil {
+			return nil, fmt.Errorf("invalid import payload: %w", err)
+		}
+		return imports.Run(ctx, request, report)
+	})
+
+	jobs.Register(models.JobTypeTelegram, func(ctx context.Context, job models.Job, report func(int, int)) (any, error) {
+		var payload telegramPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid telegram payload: %w", err)
+		}
+		return telegram.Analyse(ctx, payload, report)
+	})
+}
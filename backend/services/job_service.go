@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// JobHandler runs one job. report may be called as work progresses; the value
+// returned is stored as the job result.
+type JobHandler func(ctx context.Context, job models.Job, report func(done int, total int)) (any, error)
+
+type JobService struct {
+	store    *repository.Store
+	handlers map[string]JobHandler
+}
+
+func NewJobService(database *sql.DB) *JobService {
+	return &JobService{
+		store:    repository.New(database),
+		handlers: make(map[string]JobHandler),
+	}
+}
+
+func (s *JobService) Register(jobType string, handler JobHandler) {
+	s.handlers[jobType] = handler
+}
+
+const (
+	// jobHeartbeatEvery is how often a running job shows its worker is alive.
+	jobHeartbeatEvery = 30 * time.Second
+	// A running job whose worker missed this many heartbeats is taken to be
+	// abandoned, by a worker that was killed or lost its node.
+	jobStaleAfter = 4 * jobHeartbeatEvery
+)
+
+// jobStale matches running jobs whose worker has stopped. Jobs claimed before
+// heartbeats existed fall back on when they started.
+const jobStale = "status = ? AND COALESCE(heartbeat_at, started_at) < ?"
+
+// Enqueue queues a job, returning the existing one instead when an identical
+// job is still queued or running. An identical job whose worker has stopped
+// is failed in favour of the new one rather than left to be claimed again.
+func (s *JobService) Enqueue(ctx context.Context, jobType string, payload any, createdBy *int64) (models.Job, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	staleBefore := time.Now().Add(-jobStaleAfter)
+	var existingID int64
+	findQuery := "SELECT id FROM jobs WHERE job_type = ? AND payload = ? AND (status = ? OR (status = ? AND NOT (" + jobStale + "))) ORDER BY id LIMIT 1"
+	err = s.store.QueryRowContext(ctx, findQuery, jobType, string(encoded), models.JobStatusQueued, models.JobStatusRunning, models.JobStatusRunning, staleBefore).Scan(&existingID)
+	if err == nil {
+		return s.Get(ctx, existingID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.Job{}, err
+	}
+
+	abandonQuery := "UPDATE jobs SET status = ?, error_message = ?, finished_at = CURRENT_TIMESTAMP WHERE job_type = ? AND payload = ? AND " + jobStale
+	if _, err := s.store.ExecContext(ctx, abandonQuery, models.JobStatusFailed, "its worker stopped; queued again", jobType, string(encoded), models.JobStatusRunning, staleBefore); err != nil {
+		return models.Job{}, err
+	}
+
+	query := `INSERT INTO jobs (job_type, status, payload, created_by) VALUES (?, ?, ?, ?)`
+	jobID, err := s.store.Insert(ctx, query, jobType, models.JobStatusQueued, string(encoded), createdBy)
+	if err != nil {
+		return models.Job{}, err
+	}
+	wakeWorkers(ctx, jobType, jobID)
+
+	return s.Get(ctx, jobID)
+}
+
+func (s *JobService) Get(ctx context.Context, jobID int64) (models.Job, error) {
+	query := `
+		SELECT id, job_type, status, COALESCE(payload, ''), COALESCE(result, ''), COALESCE(error_message, ''),
+			progress, total, created_by, created_at, started_at, finished_at
+		FROM jobs
+		WHERE id = ?
+	`
+
+	return scanJob(s.store.QueryRowContext(ctx, query, jobID))
+}
+
+func (s *JobService) List(ctx context.Context, jobType string, limit int) ([]models.Job, error) {
+	limit = normalizeLimit(limit)
+
+	where := ""
+	args := make([]any, 0, 2)
+	if clean := strings.TrimSpace(jobType); clean != "" {
+		where = "WHERE job_type = ?"
+		args = append(args, clean)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT id, job_type, status, COALESCE(payload, ''), COALESCE(result, ''), COALESCE(error_message, ''),
+			progress, total, created_by, created_at, started_at, finished_at
+		FROM jobs
+		%s
+		ORDER BY id DESC
+		LIMIT ?
+	`, where)
+
+	rows, err := s.store.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]models.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Run polls for queued jobs until ctx is cancelled. Only job types with a
+// registered handler are claimed, so several processes can share the table.
+// With Redis a worker is woken as soon as a job is queued, and polls the
+// table only every jobWakeupWait in case a wake-up was lost.
+func (s *JobService) Run(ctx context.Context, pollInterval time.Duration) {
+	if len(s.handlers) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	wakeups := make([]string, 0, len(s.handlers))
+	for jobType := range s.handlers {
+		wakeups = append(wakeups, jobWakeupKey(jobType))
+	}
+
+	for {
+		for {
+			job, ok, err := s.claimNext(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[jobs] claim failed: %v", err)
+				}
+				break
+			}
+			if !ok {
+				break
+			}
+			s.execute(ctx, job)
+		}
+
+		if redis := redisBackend(); redis != nil {
+			_, err := redis.blockingPop(ctx, jobWakeupWait, wakeups...)
+			if err == nil || errors.Is(err, errRedisNil) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[jobs] waiting on redis failed, polling instead: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+const (
+	jobWakeupWait = 30 * time.Second
+	// Wake-ups nobody takes are trimmed to this many per job type.
+	maxJobWakeups = 1000
+)
+
+func jobWakeupKey(jobType string) string {
+	return redisKeyPrefix + "jobs:" + jobType
+}
+
+// wakeWorkers tells a waiting worker that jobID was queued. The job is in
+// the table either way, so a failure only delays it to the next poll.
+func wakeWorkers(ctx context.Context, jobType string, jobID int64) {
+	redis := redisBackend()
+	if redis == nil {
+		return
+	}
+	key := jobWakeupKey(jobType)
+	_, err := redis.do(ctx, "LPUSH", key, strconv.FormatInt(jobID, 10))
+	if err == nil {
+		_, err = redis.do(ctx, "LTRIM", key, "0", strconv.Itoa(maxJobWakeups-1))
+	}
+	if err != nil {
+		log.Printf("[jobs] failed to wake workers for job %d: %v", jobID, err)
+	}
+}
+
+// scheduleCheckEvery bounds how long a scheduled job can run late.
+const scheduleCheckEvery = time.Minute
+
+// Schedule queues a jobType job every interval until ctx is cancelled. The
+// jobs table is the schedule, so processes sharing it share the schedule: an
+// interval is skipped when any of them queued a job of the type within it.
+// Only the process holding the schedule's lease checks, so two can't both
+// find the interval empty and queue a job each.
+func (s *JobService) Schedule(ctx context.Context, jobType string, payload any, interval time.Duration) {
+	check := min(interval, scheduleCheckEvery)
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	task := "schedule:" + jobType
+	defer releaseLease(context.WithoutCancel(ctx), s.store, task)
+
+	for {
+		// The lease outlives two missed checks before another process takes
+		// the schedule over.
+		held, err := acquireLease(ctx, s.store, task, 3*check)
+		if err == nil && held {
+			var recent int
+			err = s.store.QueryRowContext(ctx, "SELECT COUNT(*) FROM jobs WHERE job_type = ? AND created_at > ?", jobType, time.Now().Add(-interval)).Scan(&recent)
+			if err == nil && recent == 0 {
+				_, err = s.Enqueue(ctx, jobType, payload, nil)
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[jobs] scheduling %s failed: %v", jobType, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *JobService) claimNext(ctx context.Context) (models.Job, bool, error) {
+	types := make([]string, 0, len(s.handlers))
+	for jobType := range s.handlers {
+		types = append(types, jobType)
+	}
+
+	// Queued jobs go first, then ones whose worker died while running them.
+	now := time.Now()
+	claimable := []any{models.JobStatusQueued, models.JobStatusRunning, now.Add(-jobStaleAfter)}
+	args := append([]any(nil), claimable...)
+	for _, jobType := range types {
+		args = append(args, jobType)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT id, status FROM jobs WHERE (status = ? OR ("+jobStale+")) AND job_type IN (%s) ORDER BY CASE WHEN status = ? THEN 0 ELSE 1 END, id LIMIT 1",
+		repository.Placeholders(len(types)),
+	)
+	args = append(args, models.JobStatusQueued)
+
+	var (
+		jobID  int64
+		status string
+	)
+	if err := s.store.QueryRowContext(ctx, query, args...).Scan(&jobID, &status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Job{}, false, nil
+		}
+		return models.Job{}, false, err
+	}
+
+	claimQuery := "UPDATE jobs SET status = ?, started_at = CURRENT_TIMESTAMP, heartbeat_at = ? WHERE id = ? AND (status = ? OR (" + jobStale + "))"
+	result, err := s.store.ExecContext(ctx, claimQuery, append([]any{models.JobStatusRunning, now, jobID}, claimable...)...)
+	if err != nil {
+		return models.Job{}, false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		// Another worker claimed it first.
+		return models.Job{}, false, err
+	}
+	if status == models.JobStatusRunning {
+		log.Printf("[jobs] reclaimed job %d, whose worker stopped sending heartbeats", jobID)
+	}
+
+	job, err := s.Get(ctx, jobID)
+	if err != nil {
+		return models.Job{}, false, err
+	}
+	return job, true, nil
+}
+
+func (s *JobService) execute(ctx context.Context, job models.Job) {
+	handler := s.handlers[job.Type]
+
+	report := func(done int, total int) {
+		query := "UPDATE jobs SET progress = ?, total = ? WHERE id = ?"
+		if _, err := s.store.ExecContext(context.WithoutCancel(ctx), query, done, total, job.ID); err != nil {
+			log.Printf("[jobs] progress update for job %d failed: %v", job.ID, err)
+		}
+	}
+
+	stopHeartbeat := s.heartbeat(ctx, job.ID)
+	result, err := handler(ctx, job, report)
+	stopHeartbeat()
+
+	status := models.JobStatusCompleted
+	errorMessage := ""
+	if err != nil {
+		status = models.JobStatusFailed
+		errorMessage = err.Error()
+	}
+
+	encoded := ""
+	if result != nil {
+		if raw, marshalErr := json.Marshal(result); marshalErr == nil {
+			encoded = string(raw)
+		}
+	}
+
+	query := "UPDATE jobs SET status = ?, result = ?, error_message = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?"
+	if _, updateErr := s.store.ExecContext(context.WithoutCancel(ctx), query, status, encoded, errorMessage, job.ID); updateErr != nil {
+		log.Printf("[jobs] failed to record result for job %d: %v", job.ID, updateErr)
+	}
+	if err != nil {
+		log.Printf("[jobs] job %d (%s) failed: %v", job.ID, job.Type, err)
+		// A job cut short by shutdown is not a failure worth telling anyone about.
+		if job.CreatedBy != nil && ctx.Err() == nil {
+			notify(ctx, s.store, notification{
+				userID: *job.CreatedBy,
+				kind:   models.NotificationJobFailed,
+				jobID:  &job.ID,
+				title:  fmt.Sprintf("Your %s job failed", job.Type),
+				body:   fmt.Sprintf("Job #%d stopped with an error.", job.ID),
+			})
+		}
+	}
+}
+
+// heartbeat keeps a running job's heartbeat_at fresh until the returned
+// function is called.
+func (s *JobService) heartbeat(ctx context.Context, jobID int64) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(jobHeartbeatEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			query := "UPDATE jobs SET heartbeat_at = ? WHERE id = ? AND status = ?"
+			if _, err := s.store.ExecContext(context.WithoutCancel(ctx), query, time.Now(), jobID, models.JobStatusRunning); err != nil {
+				log.Printf("[jobs] heartbeat for job %d failed: %v", jobID, err)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (models.Job, error) {
+	var job models.Job
+	var payload, result string
+	var createdBy sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+
+	if err := row.Scan(
+		&job.ID,
+		&job.Type,
+		&job.Status,
+		&payload,
+		&result,
+		&job.Error,
+		&job.Progress,
+		&job.Total,
+		&createdBy,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+	); err != nil {
+		return models.Job{}, err
+	}
+
+	if payload != "" {
+		job.Payload = json.RawMessage(payload)
+	}
+	if result != "" {
+		job.Result = json.RawMessage(result)
+	}
+	if createdBy.Valid {
+		value := createdBy.Int64
+		job.CreatedBy = &value
+	}
+	if startedAt.Valid {
+		value := startedAt.Time
+		job.StartedAt = &value
+	}
+	if finishedAt.Valid {
+		value := finishedAt.Time
+		job.FinishedAt = &value
+	}
+
+	return job, nil
+}
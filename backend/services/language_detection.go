@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+const (
+	languageDetectedScript  = "script"
+	languageDetectedWords   = "words"
+	languageDetectedLLM     = "llm"
+	languageDetectedDefault = "default"
+
+	// Below this the model is asked to settle the language.
+	confidentLanguageDetection = 0.75
+
+	languageDetectionSampleRunes = 1200
+)
+
+type scriptRange struct {
+	script string
+	lo, hi rune
+}
+
+// Unicode blocks of the scripts languages can be detected by. Latin text is
+// told apart by marker words instead.
+var scriptRanges = []scriptRange{
+	{"devanagari", 0x0900, 0x097F},
+	{"bengali", 0x0980, 0x09FF},
+	{"gurmukhi", 0x0A00, 0x0A7F},
+	{"gujarati", 0x0A80, 0x0AFF},
+	{"odia", 0x0B00, 0x0B7F},
+	{"tamil", 0x0B80, 0x0BFF},
+	{"telugu", 0x0C00, 0x0C7F},
+	{"kannada", 0x0C80, 0x0CFF},
+	{"malayalam", 0x0D00, 0x0D7F},
+}
+
+// Common short words used to tell Latin-script languages apart. English is
+// the baseline another language has to beat.
+var latinMarkerWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "for", "on", "with", "was", "by", "it", "at", "from", "has", "have"},
+	"es": {"el", "la", "los", "las", "del", "que", "y", "por", "para", "con", "una", "es", "su", "al", "como", "más", "pero", "fue", "este", "esta"},
+}
+
+type languageDetection struct {
+	language   models.Language
+	confidence float64
+	method     string
+}
+
+func (d languageDetection) summary() *models.LanguageDetection {
+	return &models.LanguageDetection{
+		Code:       d.language.Code,
+		Name:       d.language.Name,
+		Confidence: math.Round(d.confidence*100) / 100,
+		Method:     d.method,
+	}
+}
+
+// detectInputLanguage runs the script and marker-word checks and, unless
+// LANGUAGE_DETECTION is "heuristic", asks the model when they are unsure. A
+// failed model call keeps the heuristic answer.
+func (s *FactService) detectInputLanguage(ctx context.Context, languages []models.Language, text string) languageDetection {
+	detection := detectLanguage(languages, text)
+	if detection.confidence >= confidentLanguageDetection || config.Current().LanguageDetection != languageDetectedLLM {
+		return detection
+	}
+
+	code, confidence, err := s.ai.DetectLanguage(ctx, truncateRunes(text, languageDetectionSampleRunes), languages)
+	if err != nil {
+		log.Printf("[language] detection call failed, keeping %s (%s): %v", detection.language.Name, detection.method, err)
+		return detection
+	}
+	language, ok := FindLanguage(languages, code)
+	if !ok {
+		log.Printf("[language] model answered unknown language %q, keeping %s (%s)", code, detection.language.Name, detection.method)
+		return detection
+	}
+	return languageDetection{language: language, confidence: confidence, method: languageDetectedLLM}
+}
+
+// detectLanguage picks a language by script when at least a fifth of the
+// letters are in one, since Indian-language reports often quote English.
+// Latin text is English unless another language's marker words clearly win.
+func detectLanguage(languages []models.Language, text string) languageDetection {
+	counts := make(map[string]int, len(scriptRanges))
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) {
+			continue
+		}
+		letters++
+		if script := runeScript(r); script != "" {
+			counts[script]++
+		}
+	}
+
+	best := ""
+	for _, block := range scriptRanges {
+		if counts[block.script] > counts[best] {
+			best = block.script
+		}
+	}
+	if best != "" && counts[best]*5 >= letters {
+		candidates := make([]models.Language, 0, 1)
+		for _, language := range languages {
+			if language.Script == best {
+				candidates = append(candidates, language)
+			}
+		}
+		if len(candidates) > 0 {
+			share := float64(counts[best]) / float64(letters)
+			confidence := 0.5 + share/2
+			// Scripts shared by several languages (Hindi and Marathi) need
+			// the model to choose.
+			if len(candidates) > 1 {
+				confidence = 0.5
+			}
+			return languageDetection{language: candidates[0], confidence: confidence, method: languageDetectedScript}
+		}
+	}
+
+	return detectLatinLanguage(languages, text)
+}
+
+func detectLatinLanguage(languages []models.Language, text string) languageDetection {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	score := func(code string) int {
+		markers := latinMarkerWords[code]
+		total := 0
+		for _, word := range words {
+			for _, marker := range markers {
+				if word == marker {
+					total++
+					break
+				}
+			}
+		}
+		return total
+	}
+
+	english, ok := FindLanguage(languages, englishLanguage.Code)
+	if !ok {
+		english = englishLanguage
+	}
+	baseline := score(englishLanguage.Code)
+
+	var (
+		best      models.Language
+		bestScore int
+	)
+	for _, language := range languages {
+		if language.Script != "latin" || language.Code == englishLanguage.Code {
+			continue
+		}
+		if value := score(language.Code); value > bestScore {
+			best, bestScore = language, value
+		}
+	}
+
+	switch {
+	case bestScore >= 3 && bestScore > baseline:
+		return languageDetection{language: best, confidence: float64(bestScore) / float64(bestScore+baseline), method: languageDetectedWords}
+	case baseline >= 3:
+		return languageDetection{language: english, confidence: float64(baseline) / float64(baseline+bestScore), method: languageDetectedWords}
+	default:
+		return languageDetection{language: english, confidence: 0.3, method: languageDetectedDefault}
+	}
+}
+
+func runeScript(r rune) string {
+	for _, block := range scriptRanges {
+		if r >= block.lo && r <= block.hi {
+			return block.script
+		}
+	}
+	return ""
+}
+
+// pickOutputLanguage is the requested language, or the input's own language
+// when none was asked for.
+func pickOutputLanguage(languages []models.Language, requested string, detected languageDetection) (models.Language, error) {
+	if strings.TrimSpace(requested) != "" {
+		return pickLanguage(languages, requested)
+	}
+	return detected.language, nil
+}
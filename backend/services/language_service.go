@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+type LanguageService struct {
+	store *repository.Store
+}
+
+var englishLanguage = models.Language{Code: "en", Name: "English", NativeName: "English", Script: "latin"}
+
+func NewLanguageService(database *sql.DB) *LanguageService {
+	return &LanguageService{
+		store: repository.New(database),
+	}
+}
+
+func (s *LanguageService) List(ctx context.Context) ([]models.Language, error) {
+	return loadLanguages(ctx, s.store)
+}
+
+// Resolve finds an enabled language by code, English name or native name.
+func (s *LanguageService) Resolve(ctx context.Context, value string) (models.Language, error) {
+	return resolveLanguage(ctx, s.store, value)
+}
+
+func loadLanguages(ctx context.Context, store *repository.Store) ([]models.Language, error) {
+	query := `
+		SELECT code, name, native_name, script, COALESCE(generate_in_english, true)
+		FROM languages
+		WHERE is_enabled = true
+		ORDER BY position ASC, name ASC;
+	`
+	rows, err := store.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	languages := make([]models.Language, 0)
+	for rows.Next() {
+		var language models.Language
+		if err := rows.Scan(&language.Code, &language.Name, &language.NativeName, &language.Script, &language.GenerateInEnglish); err != nil {
+			return nil, err
+		}
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, ok := FindLanguage(languages, englishLanguage.Code); !ok {
+		languages = append([]models.Language{englishLanguage}, languages...)
+	}
+	return languages, nil
+}
+
+func resolveLanguage(ctx context.Context, store *repository.Store, value string) (models.Language, error) {
+	languages, err := loadLanguages(ctx, store)
+	if err != nil {
+		return models.Language{}, err
+	}
+	return pickLanguage(languages, value)
+}
+
+func pickLanguage(languages []models.Language, value string) (models.Language, error) {
+	if language, ok := FindLanguage(languages, value); ok {
+		return language, nil
+	}
+	names := make([]string, len(languages))
+	for idx, language := range languages {
+		names[idx] = language.Name
+	}
+	return models.Language{}, invalidInputf("language %q is invalid; use one of %s", strings.TrimSpace(value), strings.Join(names, ", "))
+}
+
+func FindLanguage(languages []models.Language, value string) (models.Language, bool) {
+	clean := strings.ToLower(strings.TrimSpace(value))
+	if clean == "" {
+		return models.Language{}, false
+	}
+	for _, language := range languages {
+		if clean == language.Code || clean == strings.ToLower(language.Name) || clean == strings.ToLower(language.NativeName) {
+			return language, true
+		}
+	}
+	return models.Language{}, false
+}
+
+// generationLanguageFor is the language facts and the article are written in
+// before any translation.
+func generationLanguageFor(language models.Language) string {
+	if language.GenerateInEnglish {
+		return englishLanguage.Name
+	}
+	return language.Name
+}
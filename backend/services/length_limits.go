@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"nanoheads/models"
+)
+
+const lengthOutputArticle = "article"
+
+// Upper bounds on what a request may ask for; the title rules in config still
+// apply on top of requested title lengths.
+const (
+	maxArticleWords = 3000
+	maxArticleChars = 20000
+	maxTitleWords   = 50
+	maxTitleChars   = 300
+)
+
+type lengthTargetsKey struct{}
+
+// lengthRequest is the length targets of a run. feedback is set on a retry and
+// tells the model how the previous answer missed.
+type lengthRequest struct {
+	targets  models.LengthTargets
+	feedback string
+}
+
+// ValidateLengthTargets checks requested lengths before an analysis starts.
+func ValidateLengthTargets(targets models.LengthTargets) error {
+	checks := []struct {
+		name     string
+		value    models.LengthRange
+		maxWords int
+		maxChars int
+	}{
+		{lengthOutputArticle, targets.Article, maxArticleWords, maxArticleChars},
+		{titleKindHeadline, targets.Headline, maxTitleWords, maxTitleChars},
+		{titleKindStrapline, targets.Strapline, maxTitleWords, maxTitleChars},
+	}
+	for _, check := range checks {
+		r := check.value
+		if r.MinWords < 0 || r.MaxWords < 0 || r.MinChars < 0 || r.MaxChars < 0 {
+			return invalidInputf("%s lengths must be zero or positive", check.name)
+		}
+		if r.MaxWords > check.maxWords || r.MinWords > check.maxWords {
+			return invalidInputf("%s word limits must be at most %d", check.name, check.maxWords)
+		}
+		if r.MaxChars > check.maxChars || r.MinChars > check.maxChars {
+			return invalidInputf("%s character limits must be at most %d", check.name, check.maxChars)
+		}
+		if r.MaxWords > 0 && r.MinWords > r.MaxWords {
+			return invalidInputf("%s minWords must be at most maxWords", check.name)
+		}
+		if r.MaxChars > 0 && r.MinChars > r.MaxChars {
+			return invalidInputf("%s minChars must be at most maxChars", check.name)
+		}
+	}
+	return nil
+}
+
+func withLengthTargets(ctx context.Context, targets models.LengthTargets) context.Context {
+	if targets.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, lengthTargetsKey{}, lengthRequest{targets: targets})
+}
+
+// withLengthFeedback prepares the retry of an output that missed its limits.
+func withLengthFeedback(ctx context.Context, feedback string) context.Context {
+	request, ok := ctx.Value(lengthTargetsKey{}).(lengthRequest)
+	if !ok {
+		return ctx
+	}
+	request.feedback = feedback
+	return context.WithValue(ctx, lengthTargetsKey{}, request)
+}
+
+func lengthTargetFor(ctx context.Context, output string) models.LengthRange {
+	request, ok := ctx.Value(lengthTargetsKey{}).(lengthRequest)
+	if !ok {
+		return models.LengthRange{}
+	}
+	switch output {
+	case lengthOutputArticle:
+		return request.targets.Article
+	case titleKindHeadline:
+		return request.targets.Headline
+	case titleKindStrapline:
+		return request.targets.Strapline
+	}
+	return models.LengthRange{}
+}
+
+// lengthConstraint is appended to a generation prompt when the run has length
+// targets for output.
+func lengthConstraint(ctx context.Context, output string) string {
+	target := lengthTargetFor(ctx, output)
+	if target.IsZero() {
+		return ""
+	}
+
+	subject := "The article"
+	if output != lengthOutputArticle {
+		subject = "Each " + output
+	}
+	parts := make([]string, 0, 2)
+	if bound := describeBound(target.MinWords, target.MaxWords, "words"); bound != "" {
+		parts = append(parts, bound)
+	}
+	if bound := describeBound(target.MinChars, target.MaxChars, "characters"); bound != "" {
+		parts = append(parts, bound)
+	}
+	constraint := fmt.Sprintf("\n\nLength: %s must be %s.", subject, strings.Join(parts, " and "))
+	if request, _ := ctx.Value(lengthTargetsKey{}).(lengthRequest); request.feedback != "" {
+		constraint += " " + request.feedback
+	}
+	return constraint
+}
+
+func describeBound(minimum int, maximum int, unit string) string {
+	switch {
+	case minimum > 0 && maximum > 0:
+		return fmt.Sprintf("%d–%d %s", minimum, maximum, unit)
+	case minimum > 0:
+		return fmt.Sprintf("at least %d %s", minimum, unit)
+	case maximum > 0:
+		return fmt.Sprintf("at most %d %s", maximum, unit)
+	default:
+		return ""
+	}
+}
+
+func lengthProblems(text string, target models.LengthRange) []string {
+	clean := strings.TrimSpace(text)
+	words := len(strings.Fields(clean))
+	chars := utf8.RuneCountInString(clean)
+
+	problems := make([]string, 0, 2)
+	if (target.MinWords > 0 && words < target.MinWords) || (target.MaxWords > 0 && words > target.MaxWords) {
+		problems = append(problems, fmt.Sprintf("had %d words (wanted %s)", words, strings.TrimSpace(describeBound(target.MinWords, target.MaxWords, ""))))
+	}
+	if (target.MinChars > 0 && chars < target.MinChars) || (target.MaxChars > 0 && chars > target.MaxChars) {
+		problems = append(problems, fmt.Sprintf("had %d characters (wanted %s)", chars, strings.TrimSpace(describeBound(target.MinChars, target.MaxChars, ""))))
+	}
+	return problems
+}
+
+// withinLength keeps the options that meet target.
+func withinLength(values []string, target models.LengthRange) []string {
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		if len(lengthProblems(value, target)) == 0 {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// llmCacheCounts tallies cache lookups by step since the process started.
+var llmCacheCounts = &llmCacheCounters{since: time.Now(), steps: make(map[string]*llmCacheCount)}
+
+type llmCacheCounters struct {
+	mu    sync.Mutex
+	since time.Time
+	steps map[string]*llmCacheCount
+}
+
+type llmCacheCount struct {
+	hits   int64
+	misses int64
+}
+
+// LLMCacheService stores completions so that a request identical to an
+// earlier one, down to the model and request options, is answered without
+// calling the provider. Cached answers aren't logged as LLM calls. With
+// REDIS_URL set the entries live in Redis instead of llm_cache.
+type LLMCacheService struct {
+	store *repository.Store
+	redis *redisClient
+}
+
+func NewLLMCacheService(database *sql.DB) *LLMCacheService {
+	return &LLMCacheService{
+		store: repository.New(database),
+		redis: redisBackend(),
+	}
+}
+
+const llmCacheRedisPrefix = redisKeyPrefix + "llm-cache:"
+
+// Redis keys carry the step so one step's entries can be found and purged.
+func llmCacheRedisKey(step string, key string) string {
+	return llmCacheRedisPrefix + step + ":" + key
+}
+
+func (s *OpenAIService) SetCache(cache *LLMCacheService) {
+	s.cache = cache
+}
+
+// llmCacheKey hashes everything that shapes a completion. The effective
+// token cap is part of it, as a shorter cap can cut an answer short.
+func (s *OpenAIService) llmCacheKey(step string, systemPrompt string, userPrompt string, temperature float64, maxTokens int, useJSONFormat bool) string {
+	parts := []string{
+		s.provider,
+		s.model,
+		step,
+		strconv.FormatBool(useJSONFormat),
+		strconv.FormatFloat(temperature, 'f', -1, 64),
+		strconv.Itoa(maxTokens),
+		strconv.Itoa(s.maxTokens),
+		systemPrompt,
+		userPrompt,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *OpenAIService) cachingEnabled() bool {
+	return s.cache != nil && s.provider != mockProvider && config.Current().LLMCacheTTL.Duration > 0
+}
+
+func (s *OpenAIService) cachedCompletion(ctx context.Context, step string, key string) (string, bool) {
+	if !s.cachingEnabled() {
+		return "", false
+	}
+	content, ok, err := s.cache.lookup(ctx, step, key)
+	if err != nil {
+		log.Printf("[llm-cache][%s] lookup failed, calling the provider: %v", step, err)
+		return "", false
+	}
+	llmCacheCounts.count(step, ok)
+	if ok {
+		log.Printf("[llm-cache][%s] answered from cache model=%s provider=%s", step, s.model, s.provider)
+	}
+	return content, ok
+}
+
+// cacheCompletion stores a completion. It never fails the caller; a lost
+// entry only costs a repeat call.
+func (s *OpenAIService) cacheCompletion(ctx context.Context, step string, key string, content string) {
+	if !s.cachingEnabled() || strings.TrimSpace(content) == "" {
+		return
+	}
+	if err := s.cache.remember(ctx, key, s.provider, s.model, step, content, config.Current().LLMCacheTTL.Duration); err != nil {
+		log.Printf("[llm-cache][%s] failed to store completion: %v", step, err)
+	}
+}
+
+func (s *LLMCacheService) lookup(ctx context.Context, step string, key string) (string, bool, error) {
+	if s.redis != nil {
+		reply, err := s.redis.do(ctx, "GET", llmCacheRedisKey(step, key))
+		if errors.Is(err, errRedisNil) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		response, _ := reply.(string)
+		return response, true, nil
+	}
+
+	var (
+		id       int64
+		response string
+	)
+	err := s.store.QueryRowContext(ctx, "SELECT id, response FROM llm_cache WHERE cache_key = ? AND expires_at > ?", key, time.Now()).Scan(&id, &response)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if _, err := s.store.ExecContext(ctx, "UPDATE llm_cache SET hit_count = hit_count + 1, last_used_at = CURRENT_TIMESTAMP WHERE id = ?", id); err != nil {
+		log.Printf("[llm-cache] failed to count hit: %v", err)
+	}
+	return response, true, nil
+}
+
+func (s *LLMCacheService) remember(ctx context.Context, key string, provider string, model string, step string, response string, ttl time.Duration) error {
+	if s.redis != nil {
+		_, err := s.redis.do(ctx, "SET", llmCacheRedisKey(step, key), response, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+		return err
+	}
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"llm_cache",
+		[]string{"cache_key", "provider", "model", "step", "response", "expires_at"},
+		[]string{"cache_key"},
+		[]string{"response", "expires_at"},
+		"hit_count = 0",
+		"created_at = CURRENT_TIMESTAMP",
+		"last_used_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.ExecContext(ctx, query, key, provider, truncateRunes(model, 255), truncateRunes(step, 64), response, time.Now().Add(ttl))
+	return err
+}
+
+// Stats reports the stored entries by step, with this process's hit rate.
+func (s *LLMCacheService) Stats(ctx context.Context) (models.LLMCacheStats, error) {
+	ttl := config.Current().LLMCacheTTL.Duration
+	stats := models.LLMCacheStats{
+		Enabled: ttl > 0,
+		Backend: "database",
+		TTL:     ttl.String(),
+		Steps:   make([]models.LLMCacheStep, 0),
+	}
+	steps := make(map[string]*models.LLMCacheStep)
+	if s.redis != nil {
+		stats.Backend = "redis"
+		keys, err := s.redis.scan(ctx, llmCacheRedisPrefix+"*")
+		if err != nil {
+			return models.LLMCacheStats{}, err
+		}
+		for _, key := range keys {
+			name, _, _ := strings.Cut(strings.TrimPrefix(key, llmCacheRedisPrefix), ":")
+			step, ok := steps[name]
+			if !ok {
+				step = &models.LLMCacheStep{Step: name}
+				steps[name] = step
+			}
+			step.Entries++
+			stats.Entries++
+		}
+		return s.withLookupCounts(stats, steps), nil
+	}
+
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT step, COUNT(*), COALESCE(SUM(hit_count), 0), COALESCE(SUM(CASE WHEN expires_at <= ? THEN 1 ELSE 0 END), 0)
+		FROM llm_cache
+		GROUP BY step`,
+		time.Now(),
+	)
+	if err != nil {
+		return models.LLMCacheStats{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			step    models.LLMCacheStep
+			expired int64
+		)
+		if err := rows.Scan(&step.Step, &step.Entries, &step.StoredHits, &expired); err != nil {
+			return models.LLMCacheStats{}, err
+		}
+		steps[step.Step] = &step
+		stats.Entries += step.Entries
+		stats.StoredHits += step.StoredHits
+		stats.Expired += expired
+	}
+	if err := rows.Err(); err != nil {
+		return models.LLMCacheStats{}, err
+	}
+	return s.withLookupCounts(stats, steps), nil
+}
+
+func (s *LLMCacheService) withLookupCounts(stats models.LLMCacheStats, steps map[string]*models.LLMCacheStep) models.LLMCacheStats {
+	llmCacheCounts.mu.Lock()
+	stats.Since = llmCacheCounts.since
+	for name, count := range llmCacheCounts.steps {
+		step, ok := steps[name]
+		if !ok {
+			step = &models.LLMCacheStep{Step: name}
+			steps[name] = step
+		}
+		step.Hits = count.hits
+		step.Misses = count.misses
+		stats.Hits += count.hits
+		stats.Misses += count.misses
+	}
+	llmCacheCounts.mu.Unlock()
+
+	for _, step := range steps {
+		step.HitRate = roundTo2(ratio(float64(step.Hits), float64(step.Hits+step.Misses)))
+		stats.Steps = append(stats.Steps, *step)
+	}
+	sort.Slice(stats.Steps, func(i, j int) bool { return stats.Steps[i].Step < stats.Steps[j].Step })
+	stats.HitRate = roundTo2(ratio(float64(stats.Hits), float64(stats.Hits+stats.Misses)))
+	return stats
+}
+
+// Purge deletes cached completions: those of step when it is set, and only
+// the expired ones when expiredOnly is. Redis drops expired entries itself.
+func (s *LLMCacheService) Purge(ctx context.Context, step string, expiredOnly bool) (models.LLMCachePurge, error) {
+	if s.redis != nil {
+		if expiredOnly {
+			return models.LLMCachePurge{}, nil
+		}
+		pattern := llmCacheRedisPrefix + "*"
+		if step = strings.TrimSpace(step); step != "" {
+			pattern = llmCacheRedisKey(step, "*")
+		}
+		keys, err := s.redis.scan(ctx, pattern)
+		if err != nil {
+			return models.LLMCachePurge{}, err
+		}
+		var purge models.LLMCachePurge
+		for start := 0; start < len(keys); start += 500 {
+			batch := keys[start:min(start+500, len(keys))]
+			deleted, err := s.redis.do(ctx, append([]string{"DEL"}, batch...)...)
+			if err != nil {
+				return purge, err
+			}
+			count, _ := deleted.(int64)
+			purge.Deleted += count
+		}
+		return purge, nil
+	}
+
+	conditions := make([]string, 0, 2)
+	args := make([]any, 0, 2)
+	if step = strings.TrimSpace(step); step != "" {
+		conditions = append(conditions, "step = ?")
+		args = append(args, step)
+	}
+	if expiredOnly {
+		conditions = append(conditions, "expires_at <= ?")
+		args = append(args, time.Now())
+	}
+
+	query := "DELETE FROM llm_cache"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	result, err := s.store.ExecContext(ctx, query, args...)
+	if err != nil {
+		return models.LLMCachePurge{}, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return models.LLMCachePurge{}, err
+	}
+	return models.LLMCachePurge{Deleted: deleted}, nil
+}
+
+func (c *llmCacheCounters) count(step string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count, ok := c.steps[step]
+	if !ok {
+		count = &llmCacheCount{}
+		c.steps[step] = count
+	}
+	if hit {
+		count.hits++
+	} else {
+		count.misses++
+	}
+}
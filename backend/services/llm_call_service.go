@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+type llmCallRecorder interface {
+	Record(ctx context.Context, call models.LLMCall) error
+}
+
+type llmRunIDKey struct{}
+
+type LLMCallService struct {
+	store *repository.Store
+}
+
+func NewLLMCallService(database *sql.DB) *LLMCallService {
+	return &LLMCallService{
+		store: repository.New(database),
+	}
+}
+
+func (s *LLMCallService) Record(ctx context.Context, call models.LLMCall) error {
+	query := `INSERT INTO llm_calls (run_id, step, provider, model, json_mode, system_prompt, user_prompt, response_text, status, error_class, error_message, http_status, latency_ms) VALUES (` + repository.Placeholders(13) + `)`
+	_, err := s.store.ExecContext(
+		ctx,
+		query,
+		call.RunID,
+		call.Step,
+		call.Provider,
+		call.Model,
+		call.JSONMode,
+		call.SystemPrompt,
+		call.UserPrompt,
+		call.Response,
+		call.Status,
+		call.ErrorClass,
+		call.ErrorMessage,
+		call.HTTPStatus,
+		call.LatencyMS,
+	)
+	return err
+}
+
+func (s *LLMCallService) AttachArticle(ctx context.Context, runID string, articleID int64) error {
+	if strings.TrimSpace(runID) == "" || articleID <= 0 {
+		return nil
+	}
+
+	query := "UPDATE llm_calls SET article_id = ? WHERE run_id = ?"
+	_, err := s.store.ExecContext(ctx, query, articleID, runID)
+	return err
+}
+
+func (s *LLMCallService) Search(ctx context.Context, filter models.LLMCallFilter) (models.LLMCallPage, error) {
+	page := filter.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := normalizeLimit(filter.PageSize)
+	if filter.PageSize <= 0 {
+		pageSize = 25
+	}
+
+	conditions := make([]string, 0, 7)
+	args := make([]any, 0, 9)
+
+	addCondition := func(clause string, value any) {
+		conditions = append(conditions, clause)
+		args = append(args, value)
+	}
+
+	if filter.ArticleID > 0 {
+		addCondition("article_id = ?", filter.ArticleID)
+	}
+	if step := strings.TrimSpace(filter.Step); step != "" {
+		addCondition("step = ?", step)
+	}
+	if model := strings.TrimSpace(filter.Model); model != "" {
+		addCondition("model = ?", model)
+	}
+	if provider := strings.TrimSpace(filter.Provider); provider != "" {
+		addCondition("provider = ?", strings.ToLower(provider))
+	}
+	if status := strings.TrimSpace(filter.Status); status != "" {
+		addCondition("status = ?", strings.ToLower(status))
+	}
+	if errorClass := strings.TrimSpace(filter.ErrorClass); errorClass != "" {
+		addCondition("error_class = ?", strings.ToLower(errorClass))
+	}
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		conditions = append(conditions, "(LOWER(COALESCE(user_prompt, '')) LIKE ? OR LOWER(COALESCE(response_text, '')) LIKE ? OR LOWER(COALESCE(error_message, '')) LIKE ?)")
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM llm_calls %s", where)
+	if err := s.store.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return models.LLMCallPage{}, err
+	}
+
+	listQuery := fmt.Sprintf(`
+		SELECT
+			id,
+			COALESCE(run_id, ''),
+			article_id,
+			COALESCE(step, ''),
+			COALESCE(provider, ''),
+			COALESCE(model, ''),
+			COALESCE(status, ''),
+			COALESCE(error_class, ''),
+			COALESCE(error_message, ''),
+			COALESCE(http_status, 0),
+			COALESCE(latency_ms, 0),
+			COALESCE(user_prompt, ''),
+			COALESCE(response_text, ''),
+			COALESCE(created_at, CURRENT_TIMESTAMP)
+		FROM llm_calls
+		%s
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?;
+	`, where)
+	listArgs := append(append([]any(nil), args...), pageSize, (page-1)*pageSize)
+
+	rows, err := s.store.QueryContext(ctx, listQuery, listArgs...)
+	if err != nil {
+		return models.LLMCallPage{}, err
+	}
+	defer rows.Close()
+
+	items := make([]models.LLMCallSummary, 0, pageSize)
+	for rows.Next() {
+		var (
+			item      models.LLMCallSummary
+			articleID sql.NullInt64
+			prompt    string
+			response  string
+		)
+		if err := rows.Scan(
+			&item.ID,
+			&item.RunID,
+			&articleID,
+			&item.Step,
+			&item.Provider,
+			&item.Model,
+			&item.Status,
+			&item.ErrorClass,
+			&item.ErrorMessage,
+			&item.HTTPStatus,
+			&item.LatencyMS,
+			&prompt,
+			&response,
+			&item.CreatedAt,
+		); err != nil {
+			return models.LLMCallPage{}, err
+		}
+		if articleID.Valid {
+			item.ArticleID = &articleID.Int64
+		}
+		item.ErrorMessage = redactSensitive(item.ErrorMessage)
+		item.PromptPreview = truncate(singleLine(redactSensitive(prompt)), 240)
+		item.ResponsePreview = truncate(singleLine(redactSensitive(response)), 240)
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.LLMCallPage{}, err
+	}
+
+	return models.LLMCallPage{
+		Items:    items,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}, nil
+}
+
+func (s *LLMCallService) Get(ctx context.Context, callID int64) (models.LLMCall, error) {
+	query := `
+		SELECT
+			id,
+			COALESCE(run_id, ''),
+			article_id,
+			COALESCE(step, ''),
+			COALESCE(provider, ''),
+			COALESCE(model, ''),
+			COALESCE(json_mode, false),
+			COALESCE(system_prompt, ''),
+			COALESCE(user_prompt, ''),
+			COALESCE(response_text, ''),
+			COALESCE(status, ''),
+			COALESCE(error_class, ''),
+			COALESCE(error_message, ''),
+			COALESCE(http_status, 0),
+			COALESCE(latency_ms, 0),
+			COALESCE(created_at, CURRENT_TIMESTAMP)
+		FROM llm_calls
+		WHERE id = ?
+		LIMIT 1;
+	`
+
+	var (
+		call      models.LLMCall
+		articleID sql.NullInt64
+	)
+	if err := s.store.QueryRowContext(ctx, query, callID).Scan(
+		&call.ID,
+		&call.RunID,
+		&articleID,
+		&call.Step,
+		&call.Provider,
+		&call.Model,
+		&call.JSONMode,
+		&call.SystemPrompt,
+		&call.UserPrompt,
+		&call.Response,
+		&call.Status,
+		&call.ErrorClass,
+		&call.ErrorMessage,
+		&call.HTTPStatus,
+		&call.LatencyMS,
+		&call.CreatedAt,
+	); err != nil {
+		return models.LLMCall{}, err
+	}
+	if articleID.Valid {
+		call.ArticleID = &articleID.Int64
+	}
+
+	call.SystemPrompt = redactSensitive(call.SystemPrompt)
+	call.UserPrompt = redactSensitive(call.UserPrompt)
+	call.Response = redactSensitive(call.Response)
+	call.ErrorMessage = redactSensitive(call.ErrorMessage)
+
+	return call, nil
+}
+
+func withLLMRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, llmRunIDKey{}, runID)
+}
+
+func llmRunIDFromContext(ctx context.Context) string {
+	value, _ := ctx.Value(llmRunIDKey{}).(string)
+	return value
+}
+
+func newLLMRunID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("run-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+func classifyLLMError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var apiErr *apiRequestError
+	if errors.As(err, &apiErr) {
+		switch {
+		case isRequestTooLargeMessage(apiErr.Message) && apiErr.StatusCode != 429:
+			return "request_too_large"
+		case apiErr.StatusCode == 429:
+			return "rate_limited"
+		case apiErr.StatusCode == 401 || apiErr.StatusCode == 403:
+			return "auth"
+		case apiErr.StatusCode >= 500:
+			return "provider_unavailable"
+		default:
+			return "bad_request"
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+
+	lower := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(lower, "timeout"):
+		return "timeout"
+	case strings.Contains(lower, "call groq"):
+		return "network"
+	case strings.Contains(lower, "parse"):
+		return "invalid_response"
+	case strings.Contains(lower, "no choices"):
+		return "empty_response"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	secretTokenPattern = regexp.MustCompile(`(?i)\b(sk-[a-z0-9_\-]{8,}|gsk_[a-z0-9]{8,}|nh_[a-f0-9]{16,})`)
+	bearerPattern      = regexp.MustCompile(`(?i)bearer\s+[a-z0-9._\-]{8,}`)
+	emailPattern       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern       = regexp.MustCompile(`(?:\+\d{1,3}[\s\-]?)?\b(?:\d{10,12}|\d{3}-\d{3}-\d{4}|\d{5}\s\d{5})\b`)
+)
+
+func redactSensitive(value string) string {
+	if value == "" {
+		return value
+	}
+	redacted := secretTokenPattern.ReplaceAllString(value, "[REDACTED_KEY]")
+	redacted = bearerPattern.ReplaceAllString(redacted, "Bearer [REDACTED]")
+	redacted = emailPattern.ReplaceAllString(redacted, "[REDACTED_EMAIL]")
+	redacted = phonePattern.ReplaceAllString(redacted, "[REDACTED_PHONE]")
+	return redacted
+}
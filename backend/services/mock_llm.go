@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+)
+
+const mockProvider = "mock"
+
+var (
+	mockLineNumberPattern = regexp.MustCompile(`^\d+\.\s*`)
+	mockSentencePattern   = regexp.MustCompile(`[^.!?\n]+[.!?]`)
+)
+
+func (s *OpenAIService) useMock() {
+	s.provider = mockProvider
+	s.model = mockProvider
+	s.apiKey = mockProvider
+	s.baseURL = ""
+}
+
+// mockCompletion stands in for the provider when LLM_MOCK is set. Answers
+// are shaped like real ones, so parsing, validation and storage all run.
+func mockCompletion(ctx context.Context, step string, userPrompt string) (string, error) {
+	if latency := config.Current().MockLLMLatency.Duration; latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	switch step {
+	case "extract-facts":
+		return mockJSON(map[string]any{"facts": mockFacts(userPrompt)})
+	case "extract-timeline":
+		return mockJSON(map[string]any{"events": []map[string]string{
+			{"date": "2024-03-03", "dateText": "3 March", "event": "Officials published the report.", "sourceSentence": ""},
+		}})
+	case "extract-numeric-claims":
+		return mockJSON(map[string]any{"claims": []map[string]any{
+			{"kind": "count", "value": 12, "unit": "people", "subject": "people injured", "group": "casualties", "role": "total", "text": ""},
+			{"kind": "count", "value": 8, "unit": "people", "subject": "people treated in hospital", "group": "casualties", "role": "part", "text": ""},
+			{"kind": "count", "value": 4, "unit": "people", "subject": "people treated at the scene", "group": "casualties", "role": "part", "text": ""},
+		}})
+	case "generate-gaps":
+		return mockJSON(map[string]any{"gaps": []string{
+			"Who independently confirmed these figures?",
+			"When is the next official update expected?",
+		}})
+	case "generate-article", "generate-corroborated-article", "simplify-article":
+		return mockJSON(map[string]any{"article": "This is a mock article assembled from the extracted facts for load testing."})
+	case "generate-sectioned-article":
+		return mockJSON(map[string]any{"sections": []map[string]string{
+			{"key": "lede", "body": "This is a mock lede assembled from the extracted facts for load testing."},
+			{"key": "background", "heading": "Background", "body": "Mock background drawn from the supporting facts."},
+			{"key": "unverified", "heading": "What's unverified", "body": "Mock summary of the claims still awaiting confirmation."},
+			{"key": "next", "heading": "What's next", "body": "Mock outline of the expected next developments."},
+		}})
+	case "generate-headlines":
+		return mockJSON(map[string]any{"headlines": []string{"Officials confirm key figures in new report", "New report sets out the main findings"}})
+	case "generate-straplines":
+		return mockJSON(map[string]any{"straplines": []string{"Key questions remain over verification and next steps"}})
+	case "check-grounding":
+		return mockJSON(map[string]any{"unsupported": []any{}})
+	case "check-sensitivity":
+		return mockJSON(map[string]any{"flags": []any{}})
+	case "research-gap":
+		return mockJSON(map[string]any{"answers": []map[string]any{
+			{"answer": "Officials expect to publish an update within the week.", "sources": []int{1}, "confidence": "low"},
+		}})
+	case "suggest-category":
+		return mockJSON(map[string]any{"category": "Other"})
+	case "detect-language":
+		// An unlisted code makes the caller keep its own detection.
+		return mockJSON(map[string]any{"language": "und", "confidence": 0})
+	case "ocr-image":
+		return "The district administration confirmed that 120 families were moved to relief camps on Tuesday. Officials said drinking water and medical teams have been sent to the affected villages.", nil
+	case "translate-list":
+		return strings.Join(mockSection(userPrompt, "Input lines:", true), "\n"), nil
+	case "translate-article":
+		return strings.Join(mockSection(userPrompt, "Text:", false), " "), nil
+	case stepSettingsTest:
+		return "OK", nil
+	default:
+		return "{}", nil
+	}
+}
+
+func mockFacts(userPrompt string) []string {
+	if idx := strings.LastIndex(userPrompt, "\n\nImportant:"); idx >= 0 {
+		userPrompt = userPrompt[:idx]
+	}
+	input := strings.Join(mockSection(userPrompt, "Input:", false), " ")
+	facts := make([]string, 0, 5)
+	for _, sentence := range mockSentencePattern.FindAllString(input, -1) {
+		clean := strings.TrimSpace(sentence)
+		if len(strings.Fields(clean)) < 5 {
+			continue
+		}
+		facts = append(facts, clean)
+		if len(facts) == 5 {
+			break
+		}
+	}
+	if len(facts) == 0 {
+		facts = append(facts, "The submitted text describes a single reported event.")
+	}
+	return facts
+}
+
+// mockSection echoes the lines after marker, which is what a translation
+// prompt asks to have translated.
+func mockSection(userPrompt string, marker string, numbered bool) []string {
+	idx := strings.LastIndex(userPrompt, marker)
+	if idx < 0 {
+		return []string{userPrompt}
+	}
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(userPrompt[idx+len(marker):], "\n") {
+		clean := strings.TrimSpace(line)
+		if numbered {
+			clean = mockLineNumberPattern.ReplaceAllString(clean, "")
+		}
+		if clean != "" {
+			lines = append(lines, clean)
+		}
+	}
+	return lines
+}
+
+func mockJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("encode mock response: %w", err)
+	}
+	return string(encoded), nil
+}
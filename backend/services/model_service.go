@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const modelSelectQuery = `
+	SELECT
+		m.id,
+		p.provider_key,
+		m.model_key,
+		m.display_name,
+		COALESCE(m.is_default, false),
+		COALESCE(m.is_enabled, true),
+		m.max_tokens,
+		m.input_price_per_million,
+		m.output_price_per_million
+	FROM ai_models m
+	JOIN ai_providers p ON p.id = m.provider_id
+`
+
+type ModelService struct {
+	store *repository.Store
+}
+
+// activeModel is the provider and model a run should use, as checked against
+// ai_models. A zero maxTokens means the model has no configured limit.
+type activeModel struct {
+	provider  string
+	model     string
+	maxTokens int
+}
+
+func NewModelService(database *sql.DB) *ModelService {
+	return &ModelService{
+		store: repository.New(database),
+	}
+}
+
+func (s *ModelService) ListProviders(ctx context.Context) ([]models.ProviderOption, error) {
+	return listProvidersAndModels(ctx, s.store)
+}
+
+func (s *ModelService) GetModel(ctx context.Context, modelID int64) (models.ModelOption, error) {
+	return scanModel(s.store.QueryRowContext(ctx, modelSelectQuery+" WHERE m.id = ?", modelID))
+}
+
+func (s *ModelService) AddModel(
+	ctx context.Context,
+	providerKey string,
+	modelKey string,
+	name string,
+	maxTokens *int,
+	inputPrice *float64,
+	outputPrice *float64,
+) (models.ModelOption, error) {
+	cleanKey := strings.TrimSpace(modelKey)
+	if cleanKey == "" {
+		return models.ModelOption{}, invalidInput("model key is required")
+	}
+	cleanName := strings.TrimSpace(name)
+	if cleanName == "" {
+		cleanName = cleanKey
+	}
+	if err := validateModelLimits(maxTokens, inputPrice, outputPrice); err != nil {
+		return models.ModelOption{}, err
+	}
+
+	var providerID int64
+	providerQuery := "SELECT id FROM ai_providers WHERE provider_key = ?"
+	if err := s.store.QueryRowContext(ctx, providerQuery, strings.ToLower(strings.TrimSpace(providerKey))).Scan(&providerID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ModelOption{}, invalidInput("invalid provider")
+		}
+		return models.ModelOption{}, err
+	}
+
+	var existing int64
+	err := s.store.QueryRowContext(ctx, "SELECT id FROM ai_models WHERE model_key = ?", cleanKey).Scan(&existing)
+	if err == nil {
+		return models.ModelOption{}, invalidInput("model key must be unique")
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.ModelOption{}, err
+	}
+
+	query := `
+		INSERT INTO ai_models (provider_id, model_key, display_name, is_default, is_enabled, max_tokens, input_price_per_million, output_price_per_million)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	modelID, err := s.store.Insert(ctx, query, providerID, cleanKey, cleanName, false, true, tokenLimitValue(maxTokens), inputPrice, outputPrice)
+	if err != nil {
+		return models.ModelOption{}, err
+	}
+
+	return s.GetModel(ctx, modelID)
+}
+
+// UpdateModel edits a model's name, availability, token limit or pricing. A
+// max token value of 0 removes the limit.
+func (s *ModelService) UpdateModel(
+	ctx context.Context,
+	modelID int64,
+	name *string,
+	enabled *bool,
+	maxTokens *int,
+	inputPrice *float64,
+	outputPrice *float64,
+) (models.ModelOption, error) {
+	if err := validateModelLimits(maxTokens, inputPrice, outputPrice); err != nil {
+		return models.ModelOption{}, err
+	}
+
+	setClauses := make([]string, 0, 7)
+	args := make([]any, 0, 7)
+
+	if name != nil {
+		clean := strings.TrimSpace(*name)
+		if clean == "" {
+			return models.ModelOption{}, invalidInput("model name is required")
+		}
+		setClauses = append(setClauses, "display_name = ?")
+		args = append(args, clean)
+	}
+	if enabled != nil {
+		if !*enabled {
+			active, err := s.isActiveModel(ctx, modelID)
+			if err != nil {
+				return models.ModelOption{}, err
+			}
+			if active {
+				return models.ModelOption{}, invalidInput("the active model must be switched in settings before it is disabled")
+			}
+			// A disabled model cannot stay its provider's default.
+			setClauses = append(setClauses, "is_default = ?")
+			args = append(args, false)
+		}
+		setClauses = append(setClauses, "is_enabled = ?")
+		args = append(args, *enabled)
+	}
+	if maxTokens != nil {
+		setClauses = append(setClauses, "max_tokens = ?")
+		args = append(args, tokenLimitValue(maxTokens))
+	}
+	if inputPrice != nil {
+		setClauses = append(setClauses, "input_price_per_million = ?")
+		args = append(args, *inputPrice)
+	}
+	if outputPrice != nil {
+		setClauses = append(setClauses, "output_price_per_million = ?")
+		args = append(args, *outputPrice)
+	}
+	if len(setClauses) == 0 {
+		return models.ModelOption{}, invalidInput("no model fields provided")
+	}
+	setClauses = append(setClauses, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, modelID)
+
+	query := "UPDATE ai_models SET " + strings.Join(setClauses, ", ") + " WHERE id = ?"
+	result, err := s.store.ExecContext(ctx, query, args...)
+	if err != nil {
+		return models.ModelOption{}, err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return models.ModelOption{}, err
+	}
+
+	return s.GetModel(ctx, modelID)
+}
+
+// SetDefault makes a model its provider's default, clearing the flag on the
+// provider's other models.
+func (s *ModelService) SetDefault(ctx context.Context, modelID int64) (models.ModelOption, error) {
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		var (
+			providerID int64
+			enabled    bool
+		)
+		query := "SELECT provider_id, COALESCE(is_enabled, true) FROM ai_models WHERE id = ?"
+		if err := tx.QueryRowContext(ctx, query, modelID).Scan(&providerID, &enabled); err != nil {
+			return err
+		}
+		if !enabled {
+			return invalidInput("model must be enabled before it can be the default")
+		}
+
+		update := `
+			UPDATE ai_models
+			SET is_default = (id = ?), updated_at = CURRENT_TIMESTAMP
+			WHERE provider_id = ? AND (COALESCE(is_default, false) OR id = ?)
+		`
+		_, err := tx.ExecContext(ctx, update, modelID, providerID, modelID)
+		return err
+	})
+	if err != nil {
+		return models.ModelOption{}, err
+	}
+
+	return s.GetModel(ctx, modelID)
+}
+
+func (s *ModelService) isActiveModel(ctx context.Context, modelID int64) (bool, error) {
+	var count int
+	if err := s.store.QueryRowContext(ctx, "SELECT COUNT(*) FROM app_settings WHERE model_id = ?", modelID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// resolveActiveModel reads the model chosen in settings and checks it against
+// ai_models. When that model has been disabled the provider's default is used
+// instead, then any other enabled model of the provider.
+func resolveActiveModel(ctx context.Context, store *repository.Store) (activeModel, bool, error) {
+	query := `
+		SELECT p.id, p.provider_key, m.model_key, COALESCE(m.is_enabled, true), COALESCE(m.max_tokens, 0)
+		FROM app_settings s
+		JOIN ai_providers p ON p.id = s.provider_id
+		JOIN ai_models m ON m.id = s.model_id
+		WHERE s.id = 1
+		LIMIT 1;
+	`
+
+	var (
+		providerID int64
+		active     activeModel
+		enabled    bool
+	)
+	err := store.QueryRowContext(ctx, query).Scan(&providerID, &active.provider, &active.model, &enabled, &active.maxTokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		return activeModel{}, false, nil
+	}
+	if err != nil {
+		return activeModel{}, false, err
+	}
+	if enabled {
+		return active, true, nil
+	}
+
+	fallbackQuery := `
+		SELECT model_key, COALESCE(max_tokens, 0)
+		FROM ai_models
+		WHERE provider_id = ? AND COALESCE(is_enabled, true)
+		ORDER BY COALESCE(is_default, false) DESC, id ASC
+		LIMIT 1
+	`
+	selected := active.model
+	err = store.QueryRowContext(ctx, fallbackQuery, providerID).Scan(&active.model, &active.maxTokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		return activeModel{}, false, WithCode(models.ErrorCodeNotConfigured, fmt.Errorf("no enabled model is configured for provider %s", active.provider))
+	}
+	if err != nil {
+		return activeModel{}, false, err
+	}
+
+	log.Printf("[models] selected model %s is disabled, using %s", selected, active.model)
+	return active, true, nil
+}
+
+func listProvidersAndModels(ctx context.Context, store *repository.Store) ([]models.ProviderOption, error) {
+	rows, err := store.QueryContext(ctx, `SELECT id, provider_key, display_name FROM ai_providers ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	providers := make([]models.ProviderOption, 0)
+	indexByKey := make(map[string]int)
+	for rows.Next() {
+		provider := models.ProviderOption{Models: make([]models.ModelOption, 0)}
+		if err := rows.Scan(&provider.ID, &provider.Key, &provider.Name); err != nil {
+			return nil, err
+		}
+		indexByKey[provider.Key] = len(providers)
+		providers = append(providers, provider)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	modelRows, err := store.QueryContext(ctx, modelSelectQuery+" ORDER BY m.id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer modelRows.Close()
+
+	for modelRows.Next() {
+		model, err := scanModel(modelRows)
+		if err != nil {
+			return nil, err
+		}
+		if idx, ok := indexByKey[model.Provider]; ok {
+			providers[idx].Models = append(providers[idx].Models, model)
+		}
+	}
+
+	return providers, modelRows.Err()
+}
+
+func scanModel(row rowScanner) (models.ModelOption, error) {
+	var (
+		model       models.ModelOption
+		maxTokens   sql.NullInt64
+		inputPrice  sql.NullFloat64
+		outputPrice sql.NullFloat64
+	)
+	if err := row.Scan(
+		&model.ID,
+		&model.Provider,
+		&model.Key,
+		&model.Name,
+		&model.IsDefault,
+		&model.Enabled,
+		&maxTokens,
+		&inputPrice,
+		&outputPrice,
+	); err != nil {
+		return models.ModelOption{}, err
+	}
+
+	if maxTokens.Valid && maxTokens.Int64 > 0 {
+		value := int(maxTokens.Int64)
+		model.MaxTokens = &value
+	}
+	if inputPrice.Valid {
+		model.InputPricePerMillion = &inputPrice.Float64
+	}
+	if outputPrice.Valid {
+		model.OutputPricePerMillion = &outputPrice.Float64
+	}
+	return model, nil
+}
+
+func validateModelLimits(maxTokens *int, inputPrice *float64, outputPrice *float64) error {
+	if maxTokens != nil && *maxTokens < 0 {
+		return invalidInput("max tokens must be 0 (no limit) or positive")
+	}
+	if (inputPrice != nil && *inputPrice < 0) || (outputPrice != nil && *outputPrice < 0) {
+		return invalidInput("prices must be zero or positive")
+	}
+	return nil
+}
+
+func tokenLimitValue(maxTokens *int) sql.NullInt64 {
+	if maxTokens == nil || *maxTokens <= 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*maxTokens), Valid: true}
+}
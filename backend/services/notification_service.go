@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	defaultNotificationLimit = 50
+	smtpTimeout              = 30 * time.Second
+)
+
+type NotificationService struct {
+	store *repository.Store
+}
+
+type notification struct {
+	userID    int64
+	kind      string
+	articleID *int64
+	jobID     *int64
+	title     string
+	body      string
+}
+
+func NewNotificationService(database *sql.DB) *NotificationService {
+	return &NotificationService{
+		store: repository.New(database),
+	}
+}
+
+// List returns a user's newest notifications and how many are unread.
+func (s *NotificationService) List(ctx context.Context, userID *int64, unreadOnly bool, limit int) (models.NotificationList, error) {
+	list := models.NotificationList{Items: make([]models.Notification, 0)}
+	if userID == nil {
+		return list, nil
+	}
+	if limit <= 0 || limit > 200 {
+		limit = defaultNotificationLimit
+	}
+
+	query := `
+		SELECT id, kind, article_id, job_id, title, COALESCE(body, ''), read_at, COALESCE(created_at, CURRENT_TIMESTAMP)
+		FROM notifications
+		WHERE user_id = ?`
+	if unreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+
+	rows, err := s.store.QueryContext(ctx, query, *userID, limit)
+	if err != nil {
+		return models.NotificationList{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			item      models.Notification
+			articleID sql.NullInt64
+			jobID     sql.NullInt64
+			readAt    sql.NullTime
+		)
+		if err := rows.Scan(&item.ID, &item.Kind, &articleID, &jobID, &item.Title, &item.Body, &readAt, &item.CreatedAt); err != nil {
+			return models.NotificationList{}, err
+		}
+		item.ArticleID = nullInt64Pointer(articleID)
+		item.JobID = nullInt64Pointer(jobID)
+		if readAt.Valid {
+			item.ReadAt = &readAt.Time
+			item.Read = true
+		}
+		list.Items = append(list.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return models.NotificationList{}, err
+	}
+
+	err = s.store.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", *userID).Scan(&list.Unread)
+	return list, err
+}
+
+// MarkRead marks one of the user's notifications read. Marking it again is
+// not an error.
+func (s *NotificationService) MarkRead(ctx context.Context, userID *int64, notificationID int64) error {
+	if userID == nil {
+		return sql.ErrNoRows
+	}
+
+	var readAt sql.NullTime
+	err := s.store.QueryRowContext(ctx, "SELECT read_at FROM notifications WHERE id = ? AND user_id = ?", notificationID, *userID).Scan(&readAt)
+	if err != nil || readAt.Valid {
+		return err
+	}
+	_, err = s.store.ExecContext(ctx, "UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE id = ?", notificationID)
+	return err
+}
+
+// MarkAllRead marks every unread notification of the user read and reports
+// how many there were.
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID *int64) (int64, error) {
+	if userID == nil {
+		return 0, nil
+	}
+	result, err := s.store.ExecContext(ctx, "UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = ? AND read_at IS NULL", *userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// notify stores a notification and, when SMTP is configured, emails a copy in
+// the background. Failures are logged; the event that caused the
+// notification has already happened and must not be undone by them.
+func notify(ctx context.Context, store *repository.Store, n notification) {
+	id, err := store.Insert(
+		ctx,
+		"INSERT INTO notifications (user_id, kind, article_id, job_id, title, body) VALUES (?, ?, ?, ?, ?, ?)",
+		n.userID,
+		n.kind,
+		n.articleID,
+		n.jobID,
+		truncateRunes(n.title, 255),
+		nullString(n.body),
+	)
+	if err != nil {
+		log.Printf("[notifications] failed to notify user %d (%s): %v", n.userID, n.kind, err)
+		return
+	}
+
+	if config.Current().SMTPHost == "" {
+		return
+	}
+	go emailNotification(store, id, n)
+}
+
+// notifyUsers sends the same notification to each distinct user in userIDs,
+// skipping nil entries and the user who caused the event.
+func notifyUsers(ctx context.Context, store *repository.Store, userIDs []*int64, actor *int64, n notification) {
+	seen := make(map[int64]struct{}, len(userIDs))
+	for _, userID := range userIDs {
+		if userID == nil || (actor != nil && *userID == *actor) {
+			continue
+		}
+		if _, ok := seen[*userID]; ok {
+			continue
+		}
+		seen[*userID] = struct{}{}
+		n.userID = *userID
+		notify(ctx, store, n)
+	}
+}
+
+func emailNotification(store *repository.Store, notificationID int64, n notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), smtpTimeout)
+	defer cancel()
+
+	var (
+		email  string
+		active bool
+	)
+	err := store.QueryRowContext(ctx, "SELECT email, COALESCE(is_active, true) FROM users WHERE id = ?", n.userID).Scan(&email, &active)
+	if err != nil {
+		log.Printf("[notifications] no email address for user %d: %v", n.userID, err)
+		return
+	}
+	if !active || strings.TrimSpace(email) == "" {
+		return
+	}
+
+	cfg := config.Current()
+	body := n.body
+	if n.articleID != nil {
+		reference := analysisLink(cfg, *n.articleID)
+		if reference == "" {
+			reference = fmt.Sprintf("Analysis #%d", *n.articleID)
+		}
+		body = strings.TrimSpace(body + "\n\n" + reference)
+	}
+	if err := sendEmail(cfg, email, n.title, body); err != nil {
+		log.Printf("[notifications] email to user %d failed: %v", n.userID, err)
+		return
+	}
+	if _, err := store.ExecContext(ctx, "UPDATE notifications SET emailed_at = CURRENT_TIMESTAMP WHERE id = ?", notificationID); err != nil {
+		log.Printf("[notifications] failed to record email for notification %d: %v", notificationID, err)
+	}
+}
+
+// sendEmail delivers a plain-text message.
+func sendEmail(cfg config.Config, to string, subject string, body string) error {
+	return deliverEmail(cfg, to, emailMessage(cfg.SMTPFrom, to, subject, body))
+}
+
+// sendHTMLEmail delivers html with text as the alternative for mail clients
+// that don't show HTML.
+func sendHTMLEmail(cfg config.Config, to string, subject string, text string, html string) error {
+	return deliverEmail(cfg, to, htmlEmailMessage(cfg.SMTPFrom, to, subject, text, html))
+}
+
+// deliverEmail sends a built message, upgrading to TLS when the server
+// offers STARTTLS. Unlike smtp.SendMail it gives up after smtpTimeout.
+func deliverEmail(cfg config.Config, to string, message []byte) error {
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	conn, err := net.DialTimeout("tcp", addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.SMTPHost}); err != nil {
+			return err
+		}
+	}
+	if cfg.SMTPUsername != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(cfg.SMTPFrom); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, writeErr := writer.Write(message)
+	if err := errors.Join(writeErr, writer.Close()); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func emailMessage(from string, to string, subject string, body string) []byte {
+	var message strings.Builder
+	writeEmailHeaders(&message, from, to, subject)
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(crlf(body))
+	message.WriteString("\r\n")
+	return []byte(message.String())
+}
+
+func htmlEmailMessage(from string, to string, subject string, text string, html string) []byte {
+	boundary := "nanoheads-" + rand.Text()
+
+	var message strings.Builder
+	writeEmailHeaders(&message, from, to, subject)
+	message.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+	for _, part := range []struct{ contentType, body string }{{"text/plain", text}, {"text/html", html}} {
+		message.WriteString("--" + boundary + "\r\n")
+		message.WriteString("Content-Type: " + part.contentType + "; charset=utf-8\r\n")
+		message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+		message.WriteString(crlf(part.body))
+		message.WriteString("\r\n")
+	}
+	message.WriteString("--" + boundary + "--\r\n")
+	return []byte(message.String())
+}
+
+func writeEmailHeaders(message *strings.Builder, from string, to string, subject string) {
+	// Titles come from headlines; a stray line break must not start a header.
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	message.WriteString("From: " + from + "\r\n")
+	message.WriteString("To: " + to + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+}
+
+func crlf(body string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// analysisLabel names an analysis in a notification. It never uses the raw
+// source text, which not every recipient may see.
+func analysisLabel(ctx context.Context, store *repository.Store, articleID int64) string {
+	var headline, sourceURL string
+	err := store.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(headline_selected, ''), COALESCE(source_url, '') FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&headline, &sourceURL)
+	if err != nil {
+		return fmt.Sprintf("Analysis #%d", articleID)
+	}
+	return buildAnalysisTitle(articleID, headline, sourceURL, "")
+}
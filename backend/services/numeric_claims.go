@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// numericCheck is the numeric claims found in a source and the open
+// questions raised by the ones that don't add up.
+type numericCheck struct {
+	claims    []models.NumericClaim
+	questions []string
+}
+
+// checkNumericClaims extracts the figures in the source and checks them
+// against each other. Like the timeline it is an extra: a failed extraction
+// is logged and leaves the check empty.
+func (s *FactService) checkNumericClaims(ctx context.Context, text string, language string) numericCheck {
+	claims, err := s.ai.ExtractNumericClaims(ctx, text, language)
+	if err != nil {
+		log.Printf("[numeric-claims] extraction failed: %v", err)
+		return numericCheck{}
+	}
+	return numericCheck{claims: claims, questions: numericInconsistencies(claims)}
+}
+
+// translateNumericCheck puts a check into language. The questions are built
+// in English; subjects and groups are in the language they were extracted in.
+func (s *FactService) translateNumericCheck(ctx context.Context, check numericCheck, extractedIn string, language string) (numericCheck, error) {
+	translateQuestions := len(check.questions) > 0 && !strings.EqualFold(language, englishLanguage.Name)
+	translateClaims := len(check.claims) > 0 && !strings.EqualFold(language, extractedIn)
+	if !translateQuestions && !translateClaims {
+		return check, nil
+	}
+
+	glossary, err := s.glossary.GetGlossary(ctx, language)
+	if err != nil {
+		return numericCheck{}, err
+	}
+
+	if translateQuestions {
+		translated, err := s.translateList(ctx, check.questions, language, glossary)
+		if err != nil {
+			return numericCheck{}, err
+		}
+		check.questions = translated
+	}
+	if translateClaims {
+		labels := make([]string, 0, len(check.claims)*2)
+		for _, claim := range check.claims {
+			labels = append(labels, claim.Subject, claim.Group)
+		}
+		translated, err := s.translateList(ctx, dedupeAndTrim(labels), language, glossary)
+		if err != nil {
+			return numericCheck{}, err
+		}
+		lookup := make(map[string]string, len(translated))
+		for idx, label := range dedupeAndTrim(labels) {
+			if idx < len(translated) && strings.TrimSpace(translated[idx]) != "" {
+				lookup[label] = translated[idx]
+			}
+		}
+		claims := make([]models.NumericClaim, len(check.claims))
+		for idx, claim := range check.claims {
+			if value, ok := lookup[claim.Subject]; ok {
+				claim.Subject = value
+			}
+			if value, ok := lookup[claim.Group]; ok {
+				claim.Group = value
+			}
+			claims[idx] = claim
+		}
+		check.claims = claims
+	}
+	return check, nil
+}
+
+// numericInconsistencies returns a question for each problem found: the
+// parts of a breakdown adding up to more than its total, shares of the same
+// whole adding up to more than 100%, and the same thing given different
+// figures. Parts falling short of a total aren't flagged, since a text often
+// lists only some of them.
+func numericInconsistencies(claims []models.NumericClaim) []string {
+	questions := make([]string, 0)
+
+	type breakdown struct {
+		label  string
+		unit   string
+		kind   string
+		totals []float64
+		parts  []float64
+	}
+	groups := make([]*breakdown, 0)
+	groupIndex := make(map[string]*breakdown)
+	for _, claim := range claims {
+		if claim.Group == "" || claim.Kind == models.NumericDate {
+			continue
+		}
+		key := numericKey(claim.Group, claim.Unit)
+		group, ok := groupIndex[key]
+		if !ok {
+			group = &breakdown{label: claim.Group, unit: claim.Unit, kind: claim.Kind}
+			groupIndex[key] = group
+			groups = append(groups, group)
+		}
+		if claim.Role == models.NumericRoleTotal {
+			group.totals = append(group.totals, claim.Value)
+		} else {
+			group.parts = append(group.parts, claim.Value)
+		}
+	}
+	for _, group := range groups {
+		if len(group.parts) < 2 {
+			continue
+		}
+		sum := 0.0
+		for _, part := range group.parts {
+			sum += part
+		}
+		switch {
+		case len(group.totals) > 0:
+			total := group.totals[0]
+			if sum > total+numericTolerance(group.kind, total, len(group.parts)) {
+				questions = append(questions, fmt.Sprintf(
+					"The figures given for %s add up to %s, more than the stated total of %s. Which figures are correct?",
+					group.label, formatFigure(sum, group.unit), formatFigure(total, group.unit),
+				))
+			}
+		case group.kind == models.NumericPercentage:
+			if sum > 100+numericTolerance(group.kind, 100, len(group.parts)) {
+				questions = append(questions, fmt.Sprintf(
+					"The shares given for %s add up to %s, more than 100%%. Which figures are correct?",
+					group.label, formatFigure(sum, group.unit),
+				))
+			}
+		}
+	}
+
+	type subjectFigures struct {
+		label   string
+		figures []models.NumericClaim
+	}
+	subjects := make([]*subjectFigures, 0)
+	subjectIndex := make(map[string]*subjectFigures)
+	for _, claim := range claims {
+		key := claim.Kind + "|" + numericKey(claim.Subject, claim.Unit)
+		subject, ok := subjectIndex[key]
+		if !ok {
+			subject = &subjectFigures{label: claim.Subject}
+			subjectIndex[key] = subject
+			subjects = append(subjects, subject)
+		}
+		known := false
+		for _, figure := range subject.figures {
+			if sameFigure(figure, claim) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			subject.figures = append(subject.figures, claim)
+		}
+	}
+	for _, subject := range subjects {
+		if len(subject.figures) < 2 {
+			continue
+		}
+		values := make([]string, len(subject.figures))
+		for idx, figure := range subject.figures {
+			if figure.Kind == models.NumericDate {
+				values[idx] = figure.Date
+			} else {
+				values[idx] = formatFigure(figure.Value, figure.Unit)
+			}
+		}
+		questions = append(questions, fmt.Sprintf(
+			"The source gives different figures for %s: %s. Which is correct?",
+			subject.label, strings.Join(values, " and "),
+		))
+	}
+
+	return dedupeStrings(questions)
+}
+
+func numericKey(label string, unit string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " ")) + "|" + strings.ToLower(strings.TrimSpace(unit))
+}
+
+// numericTolerance allows for each figure having been rounded: half a point
+// per percentage, 1% of the reference per amount. Counts must add up exactly.
+func numericTolerance(kind string, reference float64, parts int) float64 {
+	switch kind {
+	case models.NumericPercentage:
+		return 0.5 * float64(parts)
+	case models.NumericAmount:
+		return math.Abs(reference) * 0.01 * float64(parts)
+	}
+	return 1e-9
+}
+
+// sameFigure reports whether two claims agree. A less precise date agrees
+// with a more precise one inside it, so "2024" and "2024-03-01" don't clash.
+func sameFigure(a models.NumericClaim, b models.NumericClaim) bool {
+	if a.Kind == models.NumericDate {
+		return strings.HasPrefix(a.Date, b.Date) || strings.HasPrefix(b.Date, a.Date)
+	}
+	return math.Abs(a.Value-b.Value) <= numericTolerance(a.Kind, math.Max(math.Abs(a.Value), math.Abs(b.Value)), 1)
+}
+
+func formatFigure(value float64, unit string) string {
+	figure := strconv.FormatFloat(roundTo2(value), 'f', -1, 64)
+	switch unit {
+	case "":
+		return figure
+	case "%":
+		return figure + "%"
+	}
+	return figure + " " + unit
+}
+
+func insertNumericClaims(ctx context.Context, tx *repository.Tx, articleID int64, claims []models.NumericClaim) error {
+	for idx, claim := range claims {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO numeric_claims (article_id, position, kind, value, unit, claim_date, subject, group_label, role, claim_text) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			articleID,
+			idx+1,
+			claim.Kind,
+			claim.Value,
+			nullString(claim.Unit),
+			nullString(claim.Date),
+			claim.Subject,
+			nullString(claim.Group),
+			nullString(claim.Role),
+			nullString(claim.Text),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertNumericGaps stores the check's questions as open questions marked as
+// coming from the numeric check.
+func insertNumericGaps(ctx context.Context, tx *repository.Tx, articleID int64, questions []string) error {
+	for _, question := range questions {
+		if question = strings.TrimSpace(question); question == "" {
+			continue
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO gaps (article_id, question, is_selected, is_resolved, origin) VALUES (?, ?, ?, ?, ?)",
+			articleID,
+			question,
+			true,
+			false,
+			models.GapOriginNumericCheck,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listNumericClaims(ctx context.Context, store *repository.Store, articleID int64) ([]models.NumericClaim, error) {
+	rows, err := store.QueryContext(
+		ctx,
+		`SELECT id, position, kind, COALESCE(value, 0), COALESCE(unit, ''), COALESCE(claim_date, ''), subject, COALESCE(group_label, ''), COALESCE(role, ''), COALESCE(claim_text, '')
+		FROM numeric_claims
+		WHERE article_id = ?
+		ORDER BY position ASC, id ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := make([]models.NumericClaim, 0)
+	for rows.Next() {
+		var claim models.NumericClaim
+		if err := rows.Scan(&claim.ID, &claim.Position, &claim.Kind, &claim.Value, &claim.Unit, &claim.Date, &claim.Subject, &claim.Group, &claim.Role, &claim.Text); err != nil {
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+	return claims, rows.Err()
+}
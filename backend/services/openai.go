@@ -8,12 +8,20 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"nanoheads/config"
+	"nanoheads/models"
 	"nanoheads/prompts"
 )
 
@@ -29,7 +37,10 @@ type OpenAIService struct {
 	model      string
 	baseURL    string
 	provider   string
+	maxTokens  int
 	httpClient *http.Client
+	recorder   llmCallRecorder
+	cache      *LLMCacheService
 }
 
 type chatCompletionRequest struct {
@@ -56,7 +67,36 @@ type chatCompletionResponse struct {
 }
 
 type factsOutput struct {
-	Facts []string `json:"facts"`
+	Facts []extractedFact `json:"facts"`
+}
+
+// extractedFact is a fact as the facts prompt returns it: an object with its
+// tags, or just the text, as prompts saved before tagging ask for.
+type extractedFact struct {
+	Text string      `json:"text"`
+	Tags factTagList `json:"tags"`
+}
+
+// factTagList also takes tags given as one comma-separated string.
+type factTagList []string
+
+func (l *factTagList) UnmarshalJSON(data []byte) error {
+	var joined string
+	if err := json.Unmarshal(data, &joined); err == nil {
+		*l = strings.Split(joined, ",")
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+func (f *extractedFact) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*f = extractedFact{Text: text}
+		return nil
+	}
+	type object extractedFact
+	return json.Unmarshal(data, (*object)(f))
 }
 
 type gapsOutput struct {
@@ -67,6 +107,55 @@ type articleOutput struct {
 	Article string `json:"article"`
 }
 
+type sectionedArticleOutput struct {
+	Sections []models.ArticleSection `json:"sections"`
+}
+
+type groundingOutput struct {
+	Unsupported []struct {
+		Sentence int    `json:"sentence"`
+		Reason   string `json:"reason"`
+	} `json:"unsupported"`
+}
+
+type sensitivityOutput struct {
+	Flags []struct {
+		Kind    string `json:"kind"`
+		Excerpt string `json:"excerpt"`
+		Reason  string `json:"reason"`
+	} `json:"flags"`
+}
+
+type gapResearchOutput struct {
+	Answers []struct {
+		Answer     string `json:"answer"`
+		Sources    []int  `json:"sources"`
+		Confidence string `json:"confidence"`
+	} `json:"answers"`
+}
+
+type timelineOutput struct {
+	Events []struct {
+		Date           string `json:"date"`
+		DateText       string `json:"dateText"`
+		Event          string `json:"event"`
+		SourceSentence string `json:"sourceSentence"`
+	} `json:"events"`
+}
+
+type numericClaimsOutput struct {
+	Claims []struct {
+		Kind    string  `json:"kind"`
+		Value   float64 `json:"value"`
+		Unit    string  `json:"unit"`
+		Date    string  `json:"date"`
+		Subject string  `json:"subject"`
+		Group   string  `json:"group"`
+		Role    string  `json:"role"`
+		Text    string  `json:"text"`
+	} `json:"claims"`
+}
+
 type headlinesOutput struct {
 	Headlines []string `json:"headlines"`
 }
@@ -75,6 +164,15 @@ type straplinesOutput struct {
 	Straplines []string `json:"straplines"`
 }
 
+type categoryOutput struct {
+	Category string `json:"category"`
+}
+
+type languageOutput struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
 type apiRequestError struct {
 	StatusCode int
 	Message    string
@@ -84,6 +182,18 @@ func (e *apiRequestError) Error() string {
 	return fmt.Sprintf("groq request failed (%d): %s", e.StatusCode, e.Message)
 }
 
+// IsUpstreamError reports whether err is a model provider's error answer or
+// a failed outbound connection. A provider's "invalid API key" is our fault,
+// not the caller's, and neither should reach API callers verbatim.
+func IsUpstreamError(err error) bool {
+	var (
+		apiErr *apiRequestError
+		urlErr *url.Error
+		netErr *net.OpError
+	)
+	return errors.As(err, &apiErr) || errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
 func NewOpenAIService() *OpenAIService {
 	model := firstNonEmptyEnv("GROQ_MODEL", "OPENAI_MODEL")
 	if model == "" {
@@ -97,7 +207,7 @@ func NewOpenAIService() *OpenAIService {
 
 	apiKey := firstNonEmptyEnv("GROQ_API_KEY", "OPENAI_API_KEY")
 
-	return &OpenAIService{
+	service := &OpenAIService{
 		apiKey:   apiKey,
 		model:    model,
 		baseURL:  strings.TrimRight(baseURL, "/"),
@@ -106,118 +216,267 @@ func NewOpenAIService() *OpenAIService {
 			Timeout: 60 * time.Second,
 		},
 	}
+	if config.Current().MockLLM {
+		service.useMock()
+	}
+	return service
 }
 
-func (s *OpenAIService) ApplySettings(provider string, model string) {
+// ApplySettings switches to a provider and model that have already been
+// checked against ai_models. maxTokens caps every completion when positive.
+func (s *OpenAIService) ApplySettings(provider string, model string, maxTokens int) error {
 	cleanProvider := strings.ToLower(strings.TrimSpace(provider))
 	cleanModel := strings.TrimSpace(model)
 
+	switch cleanProvider {
+	case "openai", "groq":
+	default:
+		return fmt.Errorf("unsupported AI provider %q", provider)
+	}
+	if config.Current().MockLLM {
+		s.useMock()
+		return nil
+	}
+
 	if cleanModel != "" {
 		s.model = cleanModel
 	}
+	s.maxTokens = maxTokens
 
-	switch cleanProvider {
-	case "openai":
-		apiKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
-		baseURL := strings.TrimSpace(os.Getenv("OPENAI_BASE_URL"))
-		if baseURL == "" {
-			baseURL = defaultOpenAIURL
-		}
-		if s.model == "" {
+	s.apiKey, s.baseURL = providerCredentials(cleanProvider)
+	s.provider = cleanProvider
+	if s.model == "" {
+		s.model = defaultGroqModel
+		if cleanProvider == "openai" {
 			s.model = defaultOpenAIModel
 		}
-		s.apiKey = apiKey
-		s.baseURL = strings.TrimRight(baseURL, "/")
-		s.provider = "openai"
-	default:
-		apiKey := firstNonEmptyEnv("GROQ_API_KEY", "OPENAI_API_KEY")
-		baseURL := firstNonEmptyEnv("GROQ_BASE_URL", "OPENAI_BASE_URL")
-		if baseURL == "" {
-			baseURL = defaultGroqBaseURL
-		}
-		if s.model == "" {
-			s.model = defaultGroqModel
+	}
+	return nil
+}
+
+// providerCredentials returns the API key and base URL of provider, "openai"
+// or "groq". A key saved in settings wins over the environment; groq falls
+// back on the OPENAI_ variables.
+func providerCredentials(provider string) (string, string) {
+	apiKey := providerEnvKey(provider)
+	baseURL := firstNonEmptyEnv("GROQ_BASE_URL", "OPENAI_BASE_URL")
+	if provider == "openai" {
+		baseURL = firstNonEmptyEnv("OPENAI_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = defaultGroqBaseURL
+		if provider == "openai" {
+			baseURL = defaultOpenAIURL
 		}
-		s.apiKey = apiKey
-		s.baseURL = strings.TrimRight(baseURL, "/")
-		s.provider = "groq"
 	}
+	if stored := storedProviderKeys.get(provider); stored != "" {
+		apiKey = stored
+	}
+	return apiKey, strings.TrimRight(baseURL, "/")
 }
 
-func (s *OpenAIService) ExtractFacts(ctx context.Context, text string, language string) ([]string, error) {
+// ExtractFacts lists the facts in text, with the tags the prompt gave them.
+// Text over LLMChunkTokens is split into chunks that are extracted one at a
+// time and merged, so a long piece keeps the facts of its later parts.
+func (s *OpenAIService) ExtractFacts(ctx context.Context, text string, language string) ([]string, factTagSet, error) {
 	clean := strings.TrimSpace(text)
 	if clean == "" {
-		return nil, errors.New("input text is empty")
+		return nil, nil, invalidInput("input text is empty")
 	}
 	if s.apiKey == "" {
-		return nil, errors.New("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+		return nil, nil, errMissingAPIKey
+	}
+
+	chunks := chunkText(clean, config.Current().LLMChunkTokens)
+	if len(chunks) > 1 {
+		log.Printf("[groq][extract-facts] splitting about %d tokens of input into %d chunks", estimateTokens(clean), len(chunks))
+	}
+	perChunk := make([][]string, 0, len(chunks))
+	tags := make(factTagSet)
+	for _, chunk := range chunks {
+		facts, err := s.extractChunkFacts(ctx, chunk, language, tags)
+		if err != nil {
+			return nil, nil, err
+		}
+		perChunk = append(perChunk, facts)
 	}
 
+	facts := mergeChunkFacts(perChunk)
+	if len(facts) == 0 {
+		return nil, nil, emptyFacts("groq returned empty facts")
+	}
+	return facts, tags, nil
+}
+
+// extractChunkFacts extracts one chunk. A chunk the provider still finds too
+// large, for instance under a tokens-per-minute limit, is split in two and
+// each half extracted instead.
+func (s *OpenAIService) extractChunkFacts(ctx context.Context, chunk string, language string, tags factTagSet) ([]string, error) {
 	systemPrompt := fmt.Sprintf(
 		"You are a strict fact extraction engine. Return only facts explicitly present in the input. No hallucination. Output language must be %s.",
 		language,
 	)
-	currentInput := clean
-	var lastErr error
-
-	for attempt := 1; attempt <= 4; attempt++ {
-		userPrompt := prompts.BuildFactsPrompt(currentInput) + languageConstraint(language)
-		rawJSON, err := s.callJSONCompletion(ctx, "extract-facts", systemPrompt, userPrompt, 0.1, 700)
-		if err == nil {
-			var out factsOutput
-			if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
-				return nil, fmt.Errorf("parse facts response: %w", err)
-			}
+	userPrompt := renderPrompt(ctx, prompts.KeyFacts, map[string]string{"text": chunk}) + languageConstraint(language)
+	rawJSON, err := s.callJSONCompletion(ctx, "extract-facts", systemPrompt, userPrompt, 0.1, 1000)
+	if err != nil {
+		tokens := estimateTokens(chunk)
+		if !isRequestTooLargeError(err) || tokens <= minChunkTokens {
+			return nil, err
+		}
+		halves := chunkText(chunk, tokens/2+1)
+		if len(halves) < 2 {
+			return nil, err
+		}
 
-			facts := out.Facts
-			if len(facts) == 0 {
-				facts = parseFirstStringArrayField(rawJSON, "facts")
+		log.Printf("[groq][extract-facts] request too large, retrying as %d smaller chunks (tokens_before=%d)", len(halves), tokens)
+		perHalf := make([][]string, 0, len(halves))
+		for _, half := range halves {
+			facts, err := s.extractChunkFacts(ctx, half, language, tags)
+			if err != nil {
+				return nil, err
 			}
+			perHalf = append(perHalf, facts)
+		}
+		return mergeChunkFacts(perHalf), nil
+	}
 
-			deduped := dedupeAndTrim(facts)
-			if len(deduped) == 0 {
-				return nil, errors.New("groq returned empty facts")
-			}
+	var out factsOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return nil, fmt.Errorf("parse facts response: %w", err)
+	}
+	facts := make([]string, 0, len(out.Facts))
+	for _, fact := range out.Facts {
+		facts = append(facts, fact.Text)
+		tags.add(fact.Text, fact.Tags)
+	}
+	if len(facts) == 0 {
+		facts = parseFirstStringArrayField(rawJSON, "facts")
+	}
+	return dedupeAndTrim(facts), nil
+}
 
-			return deduped, nil
-		}
+// ExtractTimeline lists the dated events in text. Events are written in
+// language; dates and source sentences are left as the text gives them.
+func (s *OpenAIService) ExtractTimeline(ctx context.Context, text string, language string) ([]models.TimelineEvent, error) {
+	if s.apiKey == "" {
+		return nil, errMissingAPIKey
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, invalidInput("text is required to extract a timeline")
+	}
 
-		if !isRequestTooLargeError(err) {
-			return nil, err
-		}
+	systemPrompt := fmt.Sprintf(
+		"You extract dated events from news text. Use only events the text reports. Write events in %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeyTimeline, map[string]string{"text": text})
 
-		lastErr = err
-		shorterInput := shrinkPromptInput(currentInput)
-		if shorterInput == currentInput {
-			return nil, err
+	rawJSON, err := s.callJSONCompletion(ctx, "extract-timeline", systemPrompt, userPrompt, 0.1, 1200)
+	if err != nil {
+		return nil, err
+	}
+
+	var out timelineOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return nil, fmt.Errorf("parse timeline response: %w", err)
+	}
+
+	events := make([]models.TimelineEvent, 0, len(out.Events))
+	seen := make(map[string]struct{}, len(out.Events))
+	for _, item := range out.Events {
+		event := models.TimelineEvent{
+			Date:           normalizeTimelineDate(item.Date),
+			DateText:       strings.TrimSpace(item.DateText),
+			Event:          strings.TrimSpace(item.Event),
+			SourceSentence: strings.Join(strings.Fields(item.SourceSentence), " "),
+		}
+		if event.Event == "" {
+			continue
 		}
+		key := event.Date + "|" + strings.ToLower(event.Event)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		event.Position = len(events) + 1
+		events = append(events, event)
+	}
+	return events, nil
+}
 
-		log.Printf(
-			"[groq][extract-facts] request too large, retrying with shorter input (attempt=%d runes_before=%d runes_after=%d)",
-			attempt,
-			len([]rune(currentInput)),
-			len([]rune(shorterInput)),
-		)
-		currentInput = shorterInput
+// ExtractNumericClaims lists the amounts, percentages, counts and dates
+// stated in text, grouped into breakdowns where the text gives them.
+func (s *OpenAIService) ExtractNumericClaims(ctx context.Context, text string, language string) ([]models.NumericClaim, error) {
+	if s.apiKey == "" {
+		return nil, errMissingAPIKey
 	}
+	if strings.TrimSpace(text) == "" {
+		return nil, invalidInput("text is required to extract numeric claims")
+	}
+
+	systemPrompt := fmt.Sprintf(
+		"You extract the figures stated in news text exactly as reported. Write subjects and groups in %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeyNumericClaims, map[string]string{"text": text})
 
-	if lastErr != nil {
-		return nil, lastErr
+	rawJSON, err := s.callJSONCompletion(ctx, "extract-numeric-claims", systemPrompt, userPrompt, 0, 1600)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("extract facts failed after retries")
+	var out numericClaimsOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return nil, fmt.Errorf("parse numeric claims response: %w", err)
+	}
+
+	claims := make([]models.NumericClaim, 0, len(out.Claims))
+	for _, item := range out.Claims {
+		claim := models.NumericClaim{
+			Kind:    strings.ToLower(strings.TrimSpace(item.Kind)),
+			Value:   item.Value,
+			Unit:    strings.TrimSpace(item.Unit),
+			Subject: strings.TrimSpace(item.Subject),
+			Group:   strings.TrimSpace(item.Group),
+			Role:    strings.ToLower(strings.TrimSpace(item.Role)),
+			Text:    strings.Join(strings.Fields(item.Text), " "),
+		}
+		if !containsString(models.NumericClaimKinds, claim.Kind) || claim.Subject == "" {
+			continue
+		}
+		if claim.Kind == models.NumericDate {
+			if claim.Date = normalizeTimelineDate(item.Date); claim.Date == "" {
+				continue
+			}
+			year, _ := strconv.Atoi(claim.Date[:4])
+			claim.Value = float64(year)
+			claim.Unit = ""
+		}
+		if claim.Kind == models.NumericPercentage {
+			claim.Unit = "%"
+		}
+		if claim.Role != models.NumericRoleTotal && claim.Role != models.NumericRolePart {
+			claim.Role = ""
+		}
+		if claim.Group == "" {
+			claim.Role = ""
+		}
+		claim.Position = len(claims) + 1
+		claims = append(claims, claim)
+	}
+	return claims, nil
 }
 
 func (s *OpenAIService) GenerateGapQuestions(ctx context.Context, facts []string, language string) ([]string, error) {
 	if s.apiKey == "" {
-		return nil, errors.New("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+		return nil, errMissingAPIKey
 	}
 	if len(facts) == 0 {
-		return nil, errors.New("facts are required to generate gaps")
+		return nil, emptyFacts("facts are required to generate gaps")
 	}
 
 	joinedFacts := strings.Join(facts, "\n- ")
-	userPrompt := prompts.BuildGapsPrompt(fmt.Sprintf("Facts:\n- %s", joinedFacts)) + languageConstraint(language)
+	userPrompt := renderPrompt(ctx, prompts.KeyGaps, map[string]string{"facts": fmt.Sprintf("Facts:\n- %s", joinedFacts)}) + languageConstraint(language)
 	systemPrompt := fmt.Sprintf(
 		"You identify missing verification context. Return practical unanswered questions only. Output language must be %s.",
 		language,
@@ -248,10 +507,10 @@ func (s *OpenAIService) GenerateGapQuestions(ctx context.Context, facts []string
 
 func (s *OpenAIService) GenerateStructuredArticle(ctx context.Context, facts []string, gaps []string, language string) (string, error) {
 	if s.apiKey == "" {
-		return "", errors.New("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+		return "", errMissingAPIKey
 	}
 	if len(facts) == 0 {
-		return "", errors.New("facts are required to generate article")
+		return "", emptyFacts("facts are required to generate article")
 	}
 
 	factsBlock := "- " + strings.Join(facts, "\n- ")
@@ -264,9 +523,99 @@ func (s *OpenAIService) GenerateStructuredArticle(ctx context.Context, facts []s
 		"You write a concise structured article paragraph using only provided facts. Keep uncertain points as open context. Output language must be %s.",
 		language,
 	)
-	userPrompt := prompts.BuildArticlePrompt(factsBlock, gapsBlock) + languageConstraint(language)
+	userPrompt := renderPrompt(ctx, prompts.KeyArticle, map[string]string{"facts": factsBlock, "gaps": gapsBlock}) + languageConstraint(language) + lengthConstraint(ctx, lengthOutputArticle) + bannedTermConstraint(ctx)
+
+	return s.completeArticle(ctx, "generate-article", systemPrompt, userPrompt)
+}
+
+// GenerateCorroboratedArticle writes the article from facts merged across
+// several sources. Each fact carries a tag saying how many sources reported it.
+func (s *OpenAIService) GenerateCorroboratedArticle(ctx context.Context, taggedFacts []string, gaps []string, language string) (string, error) {
+	if s.apiKey == "" {
+		return "", errMissingAPIKey
+	}
+	if len(taggedFacts) == 0 {
+		return "", emptyFacts("facts are required to generate article")
+	}
 
-	rawJSON, err := s.callJSONCompletion(ctx, "generate-article", systemPrompt, userPrompt, 0.3, 1200)
+	factsBlock := "- " + strings.Join(taggedFacts, "\n- ")
+	gapsBlock := ""
+	if len(gaps) > 0 {
+		gapsBlock = "- " + strings.Join(gaps, "\n- ")
+	}
+
+	systemPrompt := fmt.Sprintf(
+		"You write a concise structured article paragraph from facts reported across several sources. State corroborated facts plainly and attribute single-source facts. Output language must be %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeyCorroboratedArticle, map[string]string{"facts": factsBlock, "gaps": gapsBlock}) + languageConstraint(language) + lengthConstraint(ctx, lengthOutputArticle) + bannedTermConstraint(ctx)
+
+	return s.completeArticle(ctx, "generate-corroborated-article", systemPrompt, userPrompt)
+}
+
+// GenerateSectionedArticle writes a long-form article split into the sections
+// of models.ArticleSectionKeys. facts may carry corroboration tags.
+func (s *OpenAIService) GenerateSectionedArticle(ctx context.Context, facts []string, gaps []string, language string) ([]models.ArticleSection, error) {
+	if s.apiKey == "" {
+		return nil, errMissingAPIKey
+	}
+	if len(facts) == 0 {
+		return nil, emptyFacts("facts are required to generate article")
+	}
+
+	factsBlock := "- " + strings.Join(facts, "\n- ")
+	gapsBlock := ""
+	if len(gaps) > 0 {
+		gapsBlock = "- " + strings.Join(gaps, "\n- ")
+	}
+
+	systemPrompt := fmt.Sprintf(
+		"You write a long-form news article in labelled sections using only provided facts. Keep uncertain points in the unverified section. Output language must be %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeySectionedArticle, map[string]string{"facts": factsBlock, "gaps": gapsBlock}) + languageConstraint(language) + lengthConstraint(ctx, lengthOutputArticle) + bannedTermConstraint(ctx)
+
+	rawJSON, err := s.callJSONCompletion(ctx, "generate-sectioned-article", systemPrompt, userPrompt, 0.3, 2400)
+	if err != nil {
+		return nil, err
+	}
+
+	var out sectionedArticleOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return nil, fmt.Errorf("parse sectioned article response: %w", err)
+	}
+
+	sections := normalizeArticleSections(out.Sections)
+	if len(sections) == 0 || sections[0].Key != models.SectionLede {
+		return nil, errors.New("groq returned no article lede")
+	}
+	return sections, nil
+}
+
+// SimplifyArticle rewrites article for the reader described by level,
+// checking details against facts.
+func (s *OpenAIService) SimplifyArticle(ctx context.Context, article string, facts []string, level string, language string) (string, error) {
+	if s.apiKey == "" {
+		return "", errMissingAPIKey
+	}
+	if strings.TrimSpace(article) == "" {
+		return "", invalidInput("article text is required to simplify")
+	}
+
+	factsBlock := ""
+	if len(facts) > 0 {
+		factsBlock = "- " + strings.Join(facts, "\n- ")
+	}
+	systemPrompt := fmt.Sprintf(
+		"You simplify news articles without changing what they report. Output language must be %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeySimplify, map[string]string{"level": level, "facts": factsBlock, "article": article}) + languageConstraint(language)
+	return s.completeArticle(ctx, "simplify-article", systemPrompt, userPrompt)
+}
+
+func (s *OpenAIService) completeArticle(ctx context.Context, step string, systemPrompt string, userPrompt string) (string, error) {
+	rawJSON, err := s.callJSONCompletion(ctx, step, systemPrompt, userPrompt, 0.3, 1200)
 	if err != nil {
 		return "", err
 	}
@@ -294,20 +643,20 @@ func (s *OpenAIService) GenerateHeadlineOptions(
 	language string,
 ) ([]string, error) {
 	if s.apiKey == "" {
-		return nil, errors.New("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+		return nil, errMissingAPIKey
 	}
 
 	factsBlock := "- " + strings.Join(dedupeAndTrim(facts), "\n- ")
 	articleBlock := truncateForPrompt(article, 900)
 	if strings.TrimSpace(factsBlock) == "-" {
-		return nil, errors.New("facts are required to generate headlines")
+		return nil, emptyFacts("facts are required to generate headlines")
 	}
 
 	systemPrompt := fmt.Sprintf(
 		"You generate editorial headlines from verified facts only. Output language must be %s.",
 		language,
 	)
-	userPrompt := prompts.BuildHeadlinesPrompt(factsBlock, articleBlock) + languageConstraint(language)
+	userPrompt := renderPrompt(ctx, prompts.KeyHeadlines, map[string]string{"facts": factsBlock, "article": articleBlock}) + languageConstraint(language) + lengthConstraint(ctx, titleKindHeadline) + headlineGuidanceConstraint(ctx) + bannedTermConstraint(ctx)
 
 	rawJSON, err := s.callJSONCompletion(ctx, "generate-headlines", systemPrompt, userPrompt, 0.35, 700)
 	if err != nil {
@@ -340,7 +689,7 @@ func (s *OpenAIService) GenerateStraplineOptions(
 	language string,
 ) ([]string, error) {
 	if s.apiKey == "" {
-		return nil, errors.New("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+		return nil, errMissingAPIKey
 	}
 
 	factsBlock := "- " + strings.Join(dedupeAndTrim(facts), "\n- ")
@@ -348,7 +697,7 @@ func (s *OpenAIService) GenerateStraplineOptions(
 	articleBlock := truncateForPrompt(article, 900)
 
 	if strings.TrimSpace(factsBlock) == "-" {
-		return nil, errors.New("facts are required to generate straplines")
+		return nil, emptyFacts("facts are required to generate straplines")
 	}
 	if strings.TrimSpace(gapsBlock) == "-" {
 		gapsBlock = "- None"
@@ -358,7 +707,7 @@ func (s *OpenAIService) GenerateStraplineOptions(
 		"You generate concise editorial straplines from verified facts. Output language must be %s.",
 		language,
 	)
-	userPrompt := prompts.BuildStraplinesPrompt(factsBlock, gapsBlock, articleBlock) + languageConstraint(language)
+	userPrompt := renderPrompt(ctx, prompts.KeyStraplines, map[string]string{"facts": factsBlock, "gaps": gapsBlock, "article": articleBlock}) + languageConstraint(language) + lengthConstraint(ctx, titleKindStrapline) + bannedTermConstraint(ctx)
 
 	rawJSON, err := s.callJSONCompletion(ctx, "generate-straplines", systemPrompt, userPrompt, 0.35, 700)
 	if err != nil {
@@ -383,9 +732,258 @@ func (s *OpenAIService) GenerateStraplineOptions(
 	return limitListItems(deduped, 4), nil
 }
 
-func (s *OpenAIService) TranslateList(ctx context.Context, items []string, language string) ([]string, error) {
+// CheckGrounding asks the model which of sentences aren't supported by facts
+// and gaps. Reasons are written in language.
+func (s *OpenAIService) CheckGrounding(ctx context.Context, facts []string, gaps []string, sentences []string, language string) ([]models.UnsupportedSentence, error) {
+	if s.apiKey == "" {
+		return nil, errMissingAPIKey
+	}
+	if len(facts) == 0 {
+		return nil, emptyFacts("facts are required to check grounding")
+	}
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+
+	numbered := make([]string, len(sentences))
+	for idx, sentence := range sentences {
+		numbered[idx] = fmt.Sprintf("%d. %s", idx+1, sentence)
+	}
+	gapsBlock := ""
+	if len(gaps) > 0 {
+		gapsBlock = "- " + strings.Join(gaps, "\n- ")
+	}
+
+	systemPrompt := fmt.Sprintf(
+		"You verify that a news article is grounded in its facts. You judge sentences and never rewrite them. Write reasons in %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeyGrounding, map[string]string{
+		"facts":     "- " + strings.Join(facts, "\n- "),
+		"gaps":      gapsBlock,
+		"sentences": strings.Join(numbered, "\n"),
+	})
+
+	rawJSON, err := s.callJSONCompletion(ctx, "check-grounding", systemPrompt, userPrompt, 0, 1200)
+	if err != nil {
+		return nil, err
+	}
+
+	var out groundingOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return nil, fmt.Errorf("parse grounding response: %w", err)
+	}
+
+	flagged := make([]models.UnsupportedSentence, 0, len(out.Unsupported))
+	seen := make(map[int]struct{}, len(out.Unsupported))
+	for _, item := range out.Unsupported {
+		if item.Sentence < 1 || item.Sentence > len(sentences) {
+			continue
+		}
+		if _, ok := seen[item.Sentence]; ok {
+			continue
+		}
+		seen[item.Sentence] = struct{}{}
+		flagged = append(flagged, models.UnsupportedSentence{
+			Position: item.Sentence,
+			Sentence: sentences[item.Sentence-1],
+			Reason:   strings.TrimSpace(item.Reason),
+		})
+	}
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].Position < flagged[j].Position })
+	return flagged, nil
+}
+
+// CheckSensitivity asks the model which parts of article carry legal risk.
+// Flags of a kind the model made up are filed as other; reasons are written
+// in language.
+func (s *OpenAIService) CheckSensitivity(ctx context.Context, facts []string, article string, language string) ([]models.AnalysisFlag, error) {
+	if s.apiKey == "" {
+		return nil, errMissingAPIKey
+	}
+	if strings.TrimSpace(article) == "" {
+		return nil, nil
+	}
+
+	factsBlock := ""
+	if len(facts) > 0 {
+		factsBlock = truncateForPrompt("- "+strings.Join(facts, "\n- "), 4000)
+	}
+	systemPrompt := fmt.Sprintf(
+		"You are a newsroom's pre-publication legal reader. You point out legal risks and never rewrite the article. Write reasons in %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeySensitivity, map[string]string{
+		"facts":   factsBlock,
+		"article": article,
+	})
+
+	rawJSON, err := s.callJSONCompletion(ctx, "check-sensitivity", systemPrompt, userPrompt, 0, 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	var out sensitivityOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return nil, fmt.Errorf("parse sensitivity response: %w", err)
+	}
+
+	flags := make([]models.AnalysisFlag, 0, len(out.Flags))
+	for _, item := range out.Flags {
+		reason := strings.Join(strings.Fields(item.Reason), " ")
+		if reason == "" {
+			continue
+		}
+		kind := strings.ToLower(strings.TrimSpace(item.Kind))
+		if !slices.Contains(models.FlagKinds, kind) {
+			kind = models.FlagKindOther
+		}
+		flags = append(flags, models.AnalysisFlag{
+			Kind:    kind,
+			Reason:  reason,
+			Excerpt: strings.TrimSpace(item.Excerpt),
+			Source:  models.FlagSourceAutomated,
+		})
+	}
+	return flags, nil
+}
+
+// ResearchGap asks the model what the numbered search results say in answer
+// to question. Answers citing no valid result are dropped; cited result
+// numbers are returned 1-based, as the prompt gives them.
+func (s *OpenAIService) ResearchGap(ctx context.Context, question string, facts []string, results []string, language string) ([]gapAnswerDraft, error) {
+	if s.apiKey == "" {
+		return nil, errMissingAPIKey
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	numbered := make([]string, len(results))
+	for idx, result := range results {
+		numbered[idx] = fmt.Sprintf("%d. %s", idx+1, result)
+	}
+	factsBlock := ""
+	if len(facts) > 0 {
+		factsBlock = truncateForPrompt("- "+strings.Join(limitListItems(facts, 12), "\n- "), 2400)
+	}
+
+	systemPrompt := fmt.Sprintf(
+		"You research open questions in news stories and only report what the sources say. Write answers in %s.",
+		language,
+	)
+	userPrompt := renderPrompt(ctx, prompts.KeyGapResearch, map[string]string{
+		"question": question,
+		"facts":    factsBlock,
+		"results":  strings.Join(numbered, "\n\n"),
+	})
+
+	rawJSON, err := s.callJSONCompletion(ctx, "research-gap", systemPrompt, userPrompt, 0.1, 1200)
+	if err != nil {
+		return nil, err
+	}
+
+	var out gapResearchOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return nil, fmt.Errorf("parse gap research response: %w", err)
+	}
+
+	drafts := make([]gapAnswerDraft, 0, len(out.Answers))
+	for _, item := range out.Answers {
+		draft := gapAnswerDraft{
+			answer:     strings.Join(strings.Fields(item.Answer), " "),
+			confidence: strings.ToLower(strings.TrimSpace(item.Confidence)),
+		}
+		for _, source := range item.Sources {
+			if source >= 1 && source <= len(results) && !containsInt(draft.sources, source) {
+				draft.sources = append(draft.sources, source)
+			}
+		}
+		if draft.answer == "" || len(draft.sources) == 0 {
+			continue
+		}
+		if draft.confidence != "high" && draft.confidence != "medium" {
+			draft.confidence = "low"
+		}
+		drafts = append(drafts, draft)
+	}
+	return drafts, nil
+}
+
+// SuggestCategory asks the model to place the facts under one of categories.
+// The answer is returned as given; callers match it against their own list.
+func (s *OpenAIService) SuggestCategory(ctx context.Context, facts []string, categories []string) (string, error) {
+	if s.apiKey == "" {
+		return "", errMissingAPIKey
+	}
+	if len(facts) == 0 {
+		return "", emptyFacts("facts are required to suggest a category")
+	}
+	if len(categories) == 0 {
+		return "", invalidInput("categories are required to suggest a category")
+	}
+
+	factsBlock := truncateForPrompt("- "+strings.Join(limitListItems(facts, 12), "\n- "), 2400)
+	categoriesBlock := "- " + strings.Join(categories, "\n- ")
+	systemPrompt := "You file news analyses under the newsroom's existing topics. Answer with one topic from the list."
+	userPrompt := renderPrompt(ctx, prompts.KeyCategory, map[string]string{"categories": categoriesBlock, "facts": factsBlock})
+
+	rawJSON, err := s.callJSONCompletion(ctx, "suggest-category", systemPrompt, userPrompt, 0, 60)
+	if err != nil {
+		return "", err
+	}
+
+	var out categoryOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return "", fmt.Errorf("parse category response: %w", err)
+	}
+
+	category := strings.TrimSpace(out.Category)
+	if category == "" {
+		category = strings.TrimSpace(parseFirstStringField(rawJSON, "category"))
+	}
+	if category == "" {
+		return "", errors.New("groq returned empty category")
+	}
+	return category, nil
+}
+
+// DetectLanguage asks the model which of languages text is written in and
+// returns the code it answered with.
+func (s *OpenAIService) DetectLanguage(ctx context.Context, text string, languages []models.Language) (string, float64, error) {
 	if s.apiKey == "" {
-		return nil, errors.New("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+		return "", 0, errMissingAPIKey
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", 0, invalidInput("text is required to detect a language")
+	}
+
+	options := make([]string, len(languages))
+	for idx, language := range languages {
+		options[idx] = fmt.Sprintf("- %s: %s", language.Code, language.Name)
+	}
+	systemPrompt := "You identify the language of news text. Answer with one language code from the list."
+	userPrompt := renderPrompt(ctx, prompts.KeyLanguage, map[string]string{"languages": strings.Join(options, "\n"), "text": text})
+
+	rawJSON, err := s.callJSONCompletion(ctx, "detect-language", systemPrompt, userPrompt, 0, 40)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var out languageOutput
+	if err := json.Unmarshal([]byte(rawJSON), &out); err != nil {
+		return "", 0, fmt.Errorf("parse language response: %w", err)
+	}
+	code := strings.ToLower(strings.TrimSpace(out.Language))
+	if code == "" {
+		return "", 0, errors.New("groq returned empty language")
+	}
+	return code, math.Max(0, math.Min(1, out.Confidence)), nil
+}
+
+func (s *OpenAIService) TranslateList(ctx context.Context, items []string, language string, glossary models.Glossary) ([]string, error) {
+	if s.apiKey == "" {
+		return nil, errMissingAPIKey
 	}
 
 	cleanLanguage := strings.TrimSpace(language)
@@ -408,8 +1006,9 @@ func (s *OpenAIService) TranslateList(ctx context.Context, items []string, langu
 
 	systemPrompt := "You are a precise translator. Preserve factual meaning, tone, and specificity."
 	userPrompt := fmt.Sprintf(
-		"Translate each line from English into %s.\n\nRules:\n- Keep exactly the same number of lines and same order.\n- Return only plain text with one translated line per output line.\n- Do not return JSON.\n- Do not add bullets or numbering.\n\nInput lines:\n%s",
+		"Translate each line from English into %s.\n\nRules:\n- Keep exactly the same number of lines and same order.\n- Return only plain text with one translated line per output line.\n- Do not return JSON.\n- Do not add bullets or numbering.%s\n\nInput lines:\n%s",
 		cleanLanguage,
+		glossaryGuidance(glossary),
 		strings.Join(lines, "\n"),
 	)
 
@@ -425,7 +1024,7 @@ func (s *OpenAIService) TranslateList(ctx context.Context, items []string, langu
 	if len(translated) != len(lines) {
 		translated = make([]string, 0, len(lines))
 		for _, item := range items {
-			single, err := s.TranslateText(ctx, item, cleanLanguage)
+			single, err := s.TranslateText(ctx, item, cleanLanguage, glossary)
 			if err != nil {
 				return nil, err
 			}
@@ -436,15 +1035,15 @@ func (s *OpenAIService) TranslateList(ctx context.Context, items []string, langu
 	return translated, nil
 }
 
-func (s *OpenAIService) TranslateText(ctx context.Context, text string, language string) (string, error) {
+func (s *OpenAIService) TranslateText(ctx context.Context, text string, language string, glossary models.Glossary) (string, error) {
 	if s.apiKey == "" {
-		return "", errors.New("GROQ_API_KEY (or OPENAI_API_KEY) is missing")
+		return "", errMissingAPIKey
 	}
 
 	cleanText := strings.TrimSpace(text)
 	cleanLanguage := strings.TrimSpace(language)
 	if cleanText == "" {
-		return "", errors.New("text is empty")
+		return "", invalidInput("text is empty")
 	}
 	if strings.EqualFold(cleanLanguage, "English") {
 		return cleanText, nil
@@ -452,8 +1051,9 @@ func (s *OpenAIService) TranslateText(ctx context.Context, text string, language
 
 	systemPrompt := "You are a precise translator for news writing."
 	userPrompt := fmt.Sprintf(
-		"Translate the following text from English to %s.\n\nRules:\n- Preserve all factual details.\n- Keep the output as a single paragraph.\n- Return only the translated text, no JSON and no explanation.\n\nText:\n%s",
+		"Translate the following text from English to %s.\n\nRules:\n- Preserve all factual details.\n- Keep the output as a single paragraph.\n- Return only the translated text, no JSON and no explanation.%s\n\nText:\n%s",
 		cleanLanguage,
+		glossaryGuidance(glossary),
 		cleanText,
 	)
 
@@ -470,6 +1070,23 @@ func (s *OpenAIService) TranslateText(ctx context.Context, text string, language
 	return translated, nil
 }
 
+func glossaryGuidance(glossary models.Glossary) string {
+	var builder strings.Builder
+	if len(glossary.Terms) > 0 {
+		builder.WriteString("\n\nGlossary (always use these translations):")
+		for _, term := range glossary.Terms {
+			builder.WriteString(fmt.Sprintf("\n- %s => %s", term.Source, term.Target))
+			if notes := strings.TrimSpace(term.Notes); notes != "" {
+				builder.WriteString(" (" + singleLine(notes) + ")")
+			}
+		}
+	}
+	if notes := strings.TrimSpace(glossary.StyleNotes); notes != "" {
+		builder.WriteString("\n\nStyle guide:\n" + notes)
+	}
+	return builder.String()
+}
+
 func (s *OpenAIService) callJSONCompletion(
 	ctx context.Context,
 	step string,
@@ -516,6 +1133,46 @@ func (s *OpenAIService) callCompletion(
 	maxTokens int,
 	useJSONFormat bool,
 ) (string, error) {
+	target, err := s.routeCall(step)
+	if err != nil {
+		return "", err
+	}
+
+	cacheKey := target.llmCacheKey(step, systemPrompt, userPrompt, temperature, maxTokens, useJSONFormat)
+	if content, ok := target.cachedCompletion(ctx, step, cacheKey); ok {
+		return content, nil
+	}
+
+	started := time.Now()
+	content, err := target.sendCompletion(ctx, step, systemPrompt, userPrompt, temperature, maxTokens, useJSONFormat)
+	target.recordCall(ctx, step, target.model, systemPrompt, userPrompt, useJSONFormat, content, err, time.Since(started))
+	if err == nil {
+		target.cacheCompletion(ctx, step, cacheKey, content)
+	} else {
+		ReportError(ctx, err, map[string]string{
+			"provider":    target.provider,
+			"model":       target.model,
+			"step":        step,
+			"error_class": classifyLLMError(err),
+			"llm_run_id":  llmRunIDFromContext(ctx),
+		})
+	}
+	return content, err
+}
+
+func (s *OpenAIService) sendCompletion(
+	ctx context.Context,
+	step string,
+	systemPrompt string,
+	userPrompt string,
+	temperature float64,
+	maxTokens int,
+	useJSONFormat bool,
+) (string, error) {
+	if s.provider == mockProvider {
+		return mockCompletion(ctx, step, userPrompt)
+	}
+
 	requestBody := chatCompletionRequest{
 		Model: s.model,
 		Messages: []chatMessage{
@@ -531,20 +1188,29 @@ func (s *OpenAIService) callCompletion(
 		Temperature: temperature,
 		MaxTokens:   maxTokens,
 	}
+	if s.maxTokens > 0 && (maxTokens <= 0 || maxTokens > s.maxTokens) {
+		requestBody.MaxTokens = s.maxTokens
+	}
 
 	if useJSONFormat {
 		requestBody.ResponseFormat = &responseFormat{Type: "json_object"}
 	}
 
+	log.Printf("[groq][%s] model=%s provider=%s json_mode=%t", step, s.model, s.provider, useJSONFormat)
+	if config.Current().DebugEnabled() {
+		log.Printf("[groq][%s] system prompt:\n%s", step, previewForLog(systemPrompt))
+		log.Printf("[groq][%s] user prompt:\n%s", step, previewForLog(userPrompt))
+	}
+
+	return s.postCompletion(ctx, step, requestBody)
+}
+
+func (s *OpenAIService) postCompletion(ctx context.Context, step string, requestBody any) (string, error) {
 	payload, err := json.Marshal(requestBody)
 	if err != nil {
 		return "", fmt.Errorf("marshal groq request: %w", err)
 	}
 
-	log.Printf("[groq][%s] model=%s provider=%s json_mode=%t", step, s.model, s.provider, useJSONFormat)
-	log.Printf("[groq][%s] system prompt:\n%s", step, previewForLog(systemPrompt))
-	log.Printf("[groq][%s] user prompt:\n%s", step, previewForLog(userPrompt))
-
 	endpoint := s.baseURL + "/chat/completions"
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
 	if err != nil {
@@ -554,6 +1220,12 @@ func (s *OpenAIService) callCompletion(
 	req.Header.Set("Authorization", "Bearer "+s.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	content, err := s.doCompletion(req, step)
+	providerBreakers.record(s.provider, err, time.Now())
+	return content, err
+}
+
+func (s *OpenAIService) doCompletion(req *http.Request, step string) (string, error) {
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("call groq: %w", err)
@@ -583,11 +1255,59 @@ func (s *OpenAIService) callCompletion(
 	}
 
 	content := strings.TrimSpace(out.Choices[0].Message.Content)
-	log.Printf("[groq][%s] raw response:\n%s", step, previewForLog(content))
+	if config.Current().DebugEnabled() {
+		log.Printf("[groq][%s] raw response:\n%s", step, previewForLog(content))
+	}
 
 	return content, nil
 }
 
+func (s *OpenAIService) SetRecorder(recorder llmCallRecorder) {
+	s.recorder = recorder
+}
+
+func (s *OpenAIService) recordCall(
+	ctx context.Context,
+	step string,
+	model string,
+	systemPrompt string,
+	userPrompt string,
+	useJSONFormat bool,
+	content string,
+	callErr error,
+	elapsed time.Duration,
+) {
+	if s.recorder == nil {
+		return
+	}
+
+	call := models.LLMCall{
+		RunID:        llmRunIDFromContext(ctx),
+		Step:         step,
+		Provider:     s.provider,
+		Model:        model,
+		JSONMode:     useJSONFormat,
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		Response:     content,
+		Status:       "ok",
+		LatencyMS:    elapsed.Milliseconds(),
+	}
+	if callErr != nil {
+		call.Status = "error"
+		call.ErrorClass = classifyLLMError(callErr)
+		call.ErrorMessage = callErr.Error()
+		var apiErr *apiRequestError
+		if errors.As(callErr, &apiErr) {
+			call.HTTPStatus = apiErr.StatusCode
+		}
+	}
+
+	if err := s.recorder.Record(context.WithoutCancel(ctx), call); err != nil {
+		log.Printf("[groq][%s] failed to record llm call: %v", step, err)
+	}
+}
+
 func (s *OpenAIService) shouldUseResponseFormatJSONMode() bool {
 	if strings.EqualFold(s.provider, "groq") {
 		return false
@@ -869,28 +1589,6 @@ func isRequestTooLargeMessage(message string) bool {
 		strings.Contains(lower, "context length")
 }
 
-func shrinkPromptInput(text string) string {
-	const minRunes = 900
-
-	runes := []rune(strings.TrimSpace(text))
-	if len(runes) <= minRunes {
-		return strings.TrimSpace(text)
-	}
-
-	nextLen := int(float64(len(runes)) * 0.72)
-	if nextLen < minRunes {
-		nextLen = minRunes
-	}
-	if nextLen >= len(runes) {
-		nextLen = len(runes) - 1
-	}
-	if nextLen <= 0 {
-		return strings.TrimSpace(text)
-	}
-
-	return strings.TrimSpace(string(runes[:nextLen]))
-}
-
 func shouldRetryWithoutJSONMode(message string) bool {
 	lower := strings.ToLower(message)
 	return strings.Contains(lower, "response_format") ||
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// Kinds of personal data the PII scan finds, named as flag reasons read, and
+// the text masking puts in their place.
+const (
+	piiEmail    = "email address"
+	piiPhone    = "phone number"
+	piiIDNumber = "ID or account number"
+)
+
+var piiMasks = map[string]string{
+	piiEmail:    "[email redacted]",
+	piiPhone:    "[phone redacted]",
+	piiIDNumber: "[ID number redacted]",
+}
+
+var piiPlurals = map[string]string{
+	piiEmail:    "email addresses",
+	piiPhone:    "phone numbers",
+	piiIDNumber: "ID or account numbers",
+}
+
+type piiPattern struct {
+	kind    string
+	pattern *regexp.Regexp
+	// valid weeds out matches that only look the part, such as digit runs
+	// failing a checksum.
+	valid func(string) bool
+}
+
+// piiPatterns run in order, and a later match overlapping an earlier one is
+// dropped, so the specific ID formats go before the looser phone numbers.
+// They lean towards missing something over masking ordinary figures: phone
+// numbers need an international prefix, a trunk 0 or the North American
+// grouping, and card and bank numbers must pass their checksums.
+var piiPatterns = []piiPattern{
+	// US social security numbers.
+	{kind: piiIDNumber, pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), valid: func(match string) bool {
+		return !strings.HasPrefix(match, "000") && !strings.HasPrefix(match, "666") && match[0] != '9'
+	}},
+	// UK National Insurance numbers.
+	{kind: piiIDNumber, pattern: regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)},
+	// IBANs.
+	{kind: piiIDNumber, pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`), valid: validIBAN},
+	// Payment card numbers.
+	{kind: piiIDNumber, pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: validLuhn},
+	{kind: piiEmail, pattern: regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}\b`)},
+	{kind: piiPhone, pattern: regexp.MustCompile(`(?:\+|\b00)\d{1,3}[ .-]?(?:\(\d{1,4}\)[ .-]?)?\d{1,4}(?:[ .-]?\d{2,4}){1,4}\b`), valid: phoneDigits},
+	{kind: piiPhone, pattern: regexp.MustCompile(`(?:\(\d{3}\) ?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`)},
+	{kind: piiPhone, pattern: regexp.MustCompile(`\b0\d{2,4}[ -]?\d{3,4}[ -]?\d{3,4}\b`), valid: phoneDigits},
+}
+
+type piiMatch struct {
+	kind       string
+	start, end int
+}
+
+// piiScan is what a scan found, by kind.
+type piiScan map[string]int
+
+func (p piiScan) total() int {
+	total := 0
+	for _, count := range p {
+		total += count
+	}
+	return total
+}
+
+// summary reads like "2 email addresses and 1 phone number".
+func (p piiScan) summary() string {
+	parts := make([]string, 0, len(p))
+	for _, kind := range []string{piiEmail, piiPhone, piiIDNumber} {
+		count := p[kind]
+		switch {
+		case count == 1:
+			parts = append(parts, "1 "+kind)
+		case count > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", count, piiPlurals[kind]))
+		}
+	}
+	if len(parts) > 1 {
+		return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+	return strings.Join(parts, "")
+}
+
+func findPII(text string) []piiMatch {
+	matches := make([]piiMatch, 0)
+	overlaps := func(start, end int) bool {
+		return slices.ContainsFunc(matches, func(match piiMatch) bool { return start < match.end && match.start < end })
+	}
+	for _, pattern := range piiPatterns {
+		for _, span := range pattern.pattern.FindAllStringIndex(text, -1) {
+			if overlaps(span[0], span[1]) {
+				continue
+			}
+			if pattern.valid != nil && !pattern.valid(text[span[0]:span[1]]) {
+				continue
+			}
+			matches = append(matches, piiMatch{kind: pattern.kind, start: span[0], end: span[1]})
+		}
+	}
+	slices.SortFunc(matches, func(a, b piiMatch) int { return a.start - b.start })
+	return matches
+}
+
+func scanPII(text string) piiScan {
+	found := make(piiScan)
+	for _, match := range findPII(text) {
+		found[match.kind]++
+	}
+	return found
+}
+
+// maskPII replaces the personal data in text and says what it replaced.
+func maskPII(text string) (string, piiScan) {
+	matches := findPII(text)
+	found := make(piiScan)
+	if len(matches) == 0 {
+		return text, found
+	}
+	var masked strings.Builder
+	last := 0
+	for _, match := range matches {
+		masked.WriteString(text[last:match.start])
+		masked.WriteString(piiMasks[match.kind])
+		last = match.end
+		found[match.kind]++
+	}
+	masked.WriteString(text[last:])
+	return masked.String(), found
+}
+
+// maskSources masks the sources' text, and their fetched pages so stored
+// snapshots don't keep what the text lost, when PII_REDACTION is "mask".
+// Uploaded images are kept as they are.
+func maskSources(sources []resolvedSource) {
+	if config.Current().PIIRedaction != "mask" {
+		return
+	}
+	found := make(piiScan)
+	for idx := range sources {
+		source := &sources[idx]
+		masked, inText := maskPII(source.text)
+		source.text = masked
+		for kind, count := range inText {
+			found[kind] += count
+		}
+		if source.page != nil {
+			body, _ := maskPII(string(source.page.body))
+			source.page.body = []byte(body)
+			source.page.html, _ = maskPII(source.page.html)
+		}
+	}
+	if found.total() > 0 {
+		log.Printf("[pii] masked %s in submitted text", found.summary())
+	}
+}
+
+// flagPII raises a privacy flag on an analysis whose source text holds
+// personal data, when PII_REDACTION is "flag". The flag names what was found
+// but not where, so it doesn't copy the data itself.
+func flagPII(ctx context.Context, store *repository.Store, articleID int64, rawText string) {
+	if config.Current().PIIRedaction != "flag" {
+		return
+	}
+	found := scanPII(rawText)
+	if found.total() == 0 {
+		return
+	}
+	var open int
+	err := store.QueryRowContext(ctx, "SELECT COUNT(*) FROM analysis_flags WHERE article_id = ? AND source = ? AND cleared_at IS NULL", articleID, models.FlagSourcePII).Scan(&open)
+	if err != nil {
+		log.Printf("[pii] failed to check flags of article %d: %v", articleID, err)
+		return
+	}
+	// A rerun doesn't raise the same flag twice.
+	if open > 0 {
+		return
+	}
+	_, err = store.ExecContext(
+		ctx,
+		"INSERT INTO analysis_flags (article_id, kind, reason, source) VALUES (?, ?, ?, ?)",
+		articleID,
+		models.FlagKindPrivacy,
+		"The source text contains "+found.summary()+". Check that publishing them is justified, or remove them, before the analysis goes out.",
+		models.FlagSourcePII,
+	)
+	if err != nil {
+		log.Printf("[pii] failed to flag article %d: %v", articleID, err)
+	}
+}
+
+func phoneDigits(match string) bool {
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 9 && digits <= 15
+}
+
+func validLuhn(match string) bool {
+	sum, digits := 0, 0
+	for idx := len(match) - 1; idx >= 0; idx-- {
+		c := match[idx]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if digits%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+func validIBAN(match string) bool {
+	compact := strings.ReplaceAll(match, " ", "")
+	if len(compact) < 15 || len(compact) > 34 {
+		return false
+	}
+	var numeric strings.Builder
+	for _, r := range compact[4:] + compact[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&numeric, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+	value, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(value, big.NewInt(97)).Int64() == 1
+}
@@ -0,0 +1,763 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	analysisStatusRunning  = "running"
+	analysisStatusFailed   = "failed"
+	analysisStatusRetrying = "retrying"
+)
+
+// A run left running or retrying this long was cut off, for example by a
+// restart, and can be retried from its last saved step.
+const staleRunAfter = 15 * time.Minute
+
+const maxFailureErrorRunes = 2000
+
+// Generation steps in the order they run; a checkpoint lists the ones done.
+const (
+	stepFacts       = "facts"
+	stepGaps        = "gaps"
+	stepArticle     = "article"
+	stepTimeline    = "timeline"
+	stepNumeric     = "numeric-claims"
+	stepCategory    = "category"
+	stepTranslation = "translation"
+	stepTitles      = "titles"
+	stepSave        = "save"
+)
+
+// pipelineCheckpoint is what a run has finished. Facts, questions and the
+// article are in the generation language; Output holds them in the output
+// language once the translation step is done.
+type pipelineCheckpoint struct {
+	Category       string               `json:"category,omitempty"`
+	ArticleMode    string               `json:"articleMode"`
+	Lengths        models.LengthTargets `json:"lengths"`
+	OutputLanguage string               `json:"outputLanguage"`
+	Completed      []string             `json:"completed"`
+
+	Sources    []checkpointSource      `json:"sources,omitempty"`
+	Facts      []string                `json:"facts,omitempty"`
+	FactTags   [][]string              `json:"factTags,omitempty"` // by fact, in the order of Facts
+	Gaps       []string                `json:"gaps,omitempty"`
+	Article    string                  `json:"article,omitempty"`
+	Sections   []models.ArticleSection `json:"sections,omitempty"`
+	Timeline   []models.TimelineEvent  `json:"timeline,omitempty"`
+	Claims     []models.NumericClaim   `json:"numericClaims,omitempty"`
+	Questions  []string                `json:"numericQuestions,omitempty"`
+	Suggestion *checkpointSuggestion   `json:"suggestion,omitempty"`
+	Output     *checkpointOutput       `json:"output,omitempty"`
+	Headlines  []string                `json:"headlines,omitempty"`
+	Straplines []string                `json:"straplines,omitempty"`
+}
+
+// checkpointSource is a source of a multi-source run. Extracted is set once
+// its facts are in, so a retry only extracts the sources that failed.
+type checkpointSource struct {
+	URL           string   `json:"url,omitempty"`
+	Text          string   `json:"text"`
+	FetchStrategy string   `json:"fetchStrategy,omitempty"`
+	Facts         []string `json:"facts,omitempty"`
+	Extracted     bool     `json:"extracted,omitempty"`
+}
+
+type checkpointSuggestion struct {
+	Category string `json:"category"`
+	Source   string `json:"source"`
+}
+
+type checkpointOutput struct {
+	Translated      bool                    `json:"translated,omitempty"`
+	GlossaryVersion int                     `json:"glossaryVersion,omitempty"`
+	Facts           []string                `json:"facts"`
+	Gaps            []string                `json:"gaps"`
+	Article         string                  `json:"article"`
+	Sections        []models.ArticleSection `json:"sections,omitempty"`
+	Timeline        []models.TimelineEvent  `json:"timeline,omitempty"`
+	Claims          []models.NumericClaim   `json:"numericClaims,omitempty"`
+	Questions       []string                `json:"numericQuestions,omitempty"`
+}
+
+func (c *pipelineCheckpoint) done(step string) bool {
+	return slices.Contains(c.Completed, step)
+}
+
+func (c *pipelineCheckpoint) complete(step string) {
+	if !c.done(step) {
+		c.Completed = append(c.Completed, step)
+	}
+}
+
+// stepDependents are the steps to run again when an editor changes the
+// output of a step. Facts, questions and the article are never regenerated
+// over an edit.
+var stepDependents = map[string][]string{
+	stepFacts:   {stepCategory, stepTranslation, stepTitles},
+	stepGaps:    {stepTranslation, stepTitles},
+	stepArticle: {stepTranslation, stepTitles},
+}
+
+func (c *pipelineCheckpoint) rerunAfter(step string) {
+	c.Completed = slices.DeleteFunc(c.Completed, func(done string) bool {
+		return slices.Contains(stepDependents[step], done)
+	})
+}
+
+// phaseOneRun is a pass through generation, for a new analysis or a retry of
+// a failed one. step is the step running, recorded if it fails; articleID is
+// set once the facts step has created the analysis.
+type phaseOneRun struct {
+	articleID          int64
+	checkpoint         pipelineCheckpoint
+	multiple           *corroboration
+	generationLanguage string
+	factsInput         string
+	step               string
+
+	sourceURL     string
+	fetchStrategy string
+	snapshots     []sourceSnapshot
+	rawText       string
+	submission    *models.Submission
+	detected      languageDetection
+	images        []resolvedImage
+}
+
+// generate runs the steps the checkpoint doesn't list as done, saving each
+// as it finishes. Steps whose failure the pipeline tolerates, such as the
+// timeline, count as done even when they came back empty.
+func (s *FactService) generate(ctx context.Context, run *phaseOneRun) error {
+	cp := &run.checkpoint
+	language := run.generationLanguage
+
+	if !cp.done(stepFacts) {
+		run.step = stepFacts
+		if run.multiple != nil {
+			if err := s.extractCorroboratedFacts(ctx, run.multiple, language); err != nil {
+				return err
+			}
+			cp.Facts = run.multiple.factTexts()
+			cp.FactTags = run.multiple.tags.forFacts(cp.Facts)
+		} else {
+			facts, tags, err := s.ai.ExtractFacts(ctx, run.factsInput, language)
+			if err != nil {
+				return err
+			}
+			cp.Facts, cp.FactTags = facts, tags.forFacts(facts)
+		}
+		if err := s.persistStep(ctx, run, stepFacts); err != nil {
+			return err
+		}
+	}
+
+	if !cp.done(stepGaps) {
+		run.step = stepGaps
+		gaps, err := s.ai.GenerateGapQuestions(ctx, cp.Facts, language)
+		if err != nil {
+			return err
+		}
+		cp.Gaps = gaps
+		if err := s.persistStep(ctx, run, stepGaps); err != nil {
+			return err
+		}
+	}
+
+	if !cp.done(stepArticle) {
+		run.step = stepArticle
+		var taggedFacts []string
+		if run.multiple != nil {
+			taggedFacts = run.multiple.taggedFacts()
+		}
+		articleText, sections, err := s.writeArticle(ctx, cp.ArticleMode, cp.Facts, taggedFacts, cp.Gaps, language)
+		if err != nil {
+			return err
+		}
+		cp.Article, cp.Sections = articleText, sections
+		if err := s.persistStep(ctx, run, stepArticle); err != nil {
+			return err
+		}
+	}
+
+	if !cp.done(stepTimeline) {
+		run.step = stepTimeline
+		if featureEnabled(ctx, s.store, models.FlagTimeline, llmRunIDFromContext(ctx)) {
+			cp.Timeline = s.extractTimeline(ctx, run.factsInput, language)
+		}
+		if err := s.persistStep(ctx, run, stepTimeline); err != nil {
+			return err
+		}
+	}
+
+	if !cp.done(stepNumeric) {
+		run.step = stepNumeric
+		if featureEnabled(ctx, s.store, models.FlagNumericClaims, llmRunIDFromContext(ctx)) {
+			numeric := s.checkNumericClaims(ctx, run.factsInput, language)
+			cp.Claims, cp.Questions = numeric.claims, numeric.questions
+		}
+		if err := s.persistStep(ctx, run, stepNumeric); err != nil {
+			return err
+		}
+	}
+
+	if !cp.done(stepCategory) {
+		run.step = stepCategory
+		if strings.TrimSpace(cp.Category) == "" {
+			if suggestion := s.suggestCategory(ctx, run.factsInput, cp.Facts); suggestion != nil {
+				cp.Suggestion = &checkpointSuggestion{Category: suggestion.category, Source: suggestion.source}
+			}
+		}
+		if err := s.persistStep(ctx, run, stepCategory); err != nil {
+			return err
+		}
+	}
+
+	if !cp.done(stepTranslation) {
+		run.step = stepTranslation
+		output := &checkpointOutput{
+			Facts:    cp.Facts,
+			Gaps:     cp.Gaps,
+			Article:  cp.Article,
+			Sections: cp.Sections,
+			Timeline: cp.Timeline,
+		}
+		// Each part is translated on its own, so they go out together.
+		group, groupCtx := errgroup.WithContext(ctx)
+		group.SetLimit(config.Current().LLMParallelism)
+		if cp.OutputLanguage != language {
+			glossary, err := s.glossary.GetGlossary(ctx, cp.OutputLanguage)
+			if err != nil {
+				return err
+			}
+			output.Translated = true
+			output.GlossaryVersion = glossary.Version
+			group.Go(func() (err error) {
+				output.Facts, err = s.translateList(groupCtx, cp.Facts, cp.OutputLanguage, glossary)
+				return err
+			})
+			group.Go(func() (err error) {
+				output.Gaps, err = s.translateList(groupCtx, cp.Gaps, cp.OutputLanguage, glossary)
+				return err
+			})
+			group.Go(func() (err error) {
+				output.Article, output.Sections, err = s.translateArticle(groupCtx, cp.Article, cp.Sections, cp.OutputLanguage, glossary)
+				return err
+			})
+			group.Go(func() (err error) {
+				output.Timeline, err = s.translateTimeline(groupCtx, cp.Timeline, cp.OutputLanguage, glossary)
+				return err
+			})
+		}
+		group.Go(func() error {
+			numeric, err := s.translateNumericCheck(groupCtx, numericCheck{claims: cp.Claims, questions: cp.Questions}, language, cp.OutputLanguage)
+			output.Claims, output.Questions = numeric.claims, numeric.questions
+			return err
+		})
+		if err := group.Wait(); err != nil {
+			return err
+		}
+		cp.Output = output
+		if err := s.persistStep(ctx, run, stepTranslation); err != nil {
+			return err
+		}
+	}
+
+	if !cp.done(stepTitles) {
+		run.step = stepTitles
+		cp.Headlines, cp.Straplines = s.generateTitles(ctx, cp.Output.Facts, cp.Output.Gaps, cp.Output.Article, cp.OutputLanguage)
+		if err := s.persistStep(ctx, run, stepTitles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish completes the analysis of a run that got through generation,
+// replacing the output saved step by step with the final, translated one.
+func (s *FactService) finish(ctx context.Context, runID string, run *phaseOneRun, promptVersions map[string]int) (models.PhaseOneResponse, error) {
+	cp := &run.checkpoint
+	output := cp.Output
+	run.step = stepSave
+
+	articleText, sections, headlines, straplines := s.applyStyleGuide(ctx, output.Article, output.Sections, cp.Headlines, cp.Straplines)
+
+	var suggestion *categorySuggestion
+	if cp.Suggestion != nil {
+		suggestion = &categorySuggestion{category: cp.Suggestion.Category, source: cp.Suggestion.Source}
+	}
+	var translation *phaseOneTranslation
+	if output.Translated {
+		translation = &phaseOneTranslation{
+			language:        cp.OutputLanguage,
+			glossaryVersion: output.GlossaryVersion,
+			facts:           cp.Facts,
+			gaps:            cp.Gaps,
+			article:         cp.Article,
+		}
+	}
+	numeric := numericCheck{claims: output.Claims, questions: output.Questions}
+
+	articleID, err := s.savePhaseOne(ctx, run.articleID, run.sourceURL, run.rawText, articleText, sections, cp.Category, suggestion, run.submission, output.Facts, cp.FactTags, output.Gaps, output.Timeline, numeric, headlines, straplines, translation, run.multiple, run.images, run.detected, cp.OutputLanguage, promptVersions)
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+
+	flagPII(ctx, s.store, articleID, run.rawText)
+	s.afterSave(ctx, runID, articleID, run.submission)
+
+	headline := fmt.Sprintf("Analysis #%d", articleID)
+	if len(headlines) > 0 {
+		headline = headlines[0]
+	}
+	announce(pipelineEvent{
+		event:     config.EventAnalysisFinished,
+		articleID: articleID,
+		headline:  headline,
+		detail:    fmt.Sprintf("%d facts, %d open questions (%s)", len(output.Facts), len(output.Gaps)+len(numeric.questions), cp.OutputLanguage),
+	})
+
+	response := models.PhaseOneResponse{
+		ArticleID:     articleID,
+		Language:      cp.OutputLanguage,
+		Facts:         output.Facts,
+		Gaps:          append(output.Gaps, numeric.questions...),
+		Article:       articleText,
+		ArticleMode:   cp.ArticleMode,
+		Sections:      sections,
+		Readability:   measureReadability(articleText, cp.OutputLanguage),
+		Timeline:      output.Timeline,
+		NumericClaims: numeric.claims,
+		InputLanguage: run.detected.summary(),
+	}
+	if suggestion != nil {
+		response.SuggestedCategory = suggestion.category
+	}
+	if run.multiple != nil {
+		response.Sources = run.multiple.summaries()
+		response.Corroboration = run.multiple.factCorroboration(output.Facts)
+	}
+	if len(run.images) > 0 {
+		response.Images = imageSummaries(run.images)
+	}
+	return response, nil
+}
+
+// persistStep saves the output of step with the checkpoint that lists it as
+// done, in a transaction of its own, so a later failure doesn't lose it. The
+// facts step creates the analysis. Facts, questions and the article go where
+// editors can see and edit them; the other steps only go in the checkpoint.
+func (s *FactService) persistStep(ctx context.Context, run *phaseOneRun, step string) error {
+	checkpoint := run.checkpoint
+	checkpoint.Completed = append(slices.Clone(checkpoint.Completed), step)
+	encoded, err := run.encodeCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+	if run.articleID == 0 {
+		if err := storeImages(run.images); err != nil {
+			return err
+		}
+		if err := storeSnapshots(run.snapshots); err != nil {
+			return err
+		}
+	}
+
+	articleID := run.articleID
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if articleID == 0 {
+			if articleID, err = createRunArticle(ctx, tx, run); err != nil {
+				return err
+			}
+		}
+		switch step {
+		case stepFacts:
+			if _, err := insertFacts(ctx, tx, articleID, checkpoint.Facts); err != nil {
+				return err
+			}
+		case stepGaps:
+			if _, err := insertGaps(ctx, tx, articleID, checkpoint.Gaps); err != nil {
+				return err
+			}
+		case stepArticle:
+			if _, err := tx.ExecContext(ctx, "UPDATE articles SET article_text = ? WHERE id = ?", checkpoint.Article, articleID); err != nil {
+				return err
+			}
+			if len(checkpoint.Sections) > 0 {
+				if err := storeArticleSections(ctx, tx, articleID, checkpoint.Sections); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			"UPDATE articles SET status = ?, pipeline_checkpoint = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			analysisStatusRunning,
+			encoded,
+			articleID,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("save %s: %w", step, err)
+	}
+
+	run.articleID = articleID
+	run.checkpoint.complete(step)
+	return nil
+}
+
+// createRunArticle inserts the analysis a run saves its steps into, with the
+// input and images but no output yet.
+func createRunArticle(ctx context.Context, tx *repository.Tx, run *phaseOneRun) (int64, error) {
+	topicID, err := resolveTopicID(ctx, tx, run.checkpoint.Category)
+	if err != nil {
+		return 0, err
+	}
+	articleID, err := insertArticle(ctx, tx, run.sourceURL, run.fetchStrategy, run.rawText, "", topicID, nil, "", run.submission, "", "", run.detected, run.checkpoint.OutputLanguage)
+	if err != nil {
+		return 0, err
+	}
+	if err := insertArticleImages(ctx, tx, articleID, run.images); err != nil {
+		return 0, err
+	}
+	return articleID, insertSourceSnapshots(ctx, tx, articleID, run.snapshots)
+}
+
+func (run *phaseOneRun) encodeCheckpoint(checkpoint pipelineCheckpoint) (string, error) {
+	if run.multiple != nil {
+		checkpoint.Sources = checkpointSources(run.multiple)
+	}
+	encoded, err := json.Marshal(checkpoint)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// recordFailure marks the analysis of a run that failed as failed, creating
+// it if the run failed before its first step was saved, so it can be retried.
+// It returns the error to report, naming the analysis when it could be saved.
+func (s *FactService) recordFailure(ctx context.Context, runID string, run *phaseOneRun, cause error) error {
+	// The run may have failed because the request was cancelled; the record
+	// is still worth keeping.
+	ctx = context.WithoutCancel(ctx)
+
+	encoded, err := run.encodeCheckpoint(run.checkpoint)
+	if err != nil {
+		log.Printf("[pipeline] failed to encode the checkpoint of a failed run: %v", err)
+		return cause
+	}
+
+	if run.articleID == 0 && len(run.images) > 0 {
+		if err := storeImages(run.images); err != nil {
+			log.Printf("[pipeline] failed to store the images of a failed run: %v", err)
+			run.images = nil
+		}
+	}
+	if run.articleID == 0 && len(run.snapshots) > 0 {
+		if err := storeSnapshots(run.snapshots); err != nil {
+			log.Printf("[pipeline] failed to store the source snapshots of a failed run: %v", err)
+			run.snapshots = nil
+		}
+	}
+
+	articleID := run.articleID
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if articleID == 0 {
+			if articleID, err = createRunArticle(ctx, tx, run); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE articles
+			SET status = ?, failed_step = ?, failure_error = ?, failed_at = CURRENT_TIMESTAMP, pipeline_checkpoint = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`,
+			analysisStatusFailed,
+			run.step,
+			truncateRunes(cause.Error(), maxFailureErrorRunes),
+			encoded,
+			articleID,
+		)
+		return err
+	})
+	if err != nil {
+		log.Printf("[pipeline] failed to record the run that failed at %s: %v", run.step, err)
+		return cause
+	}
+
+	if err := s.llmCalls.AttachArticle(ctx, runID, articleID); err != nil {
+		log.Printf("[llm-calls] failed to link run %s to article %d: %v", runID, articleID, err)
+	}
+	log.Printf("[pipeline] analysis %d failed at %s: %v", articleID, run.step, cause)
+	return fmt.Errorf("%s failed, saved as analysis %d to retry: %w", run.step, articleID, cause)
+}
+
+func checkpointSources(multiple *corroboration) []checkpointSource {
+	sources := make([]checkpointSource, len(multiple.sources))
+	for idx, source := range multiple.sources {
+		sources[idx] = checkpointSource{URL: source.url, Text: source.text, FetchStrategy: source.fetchStrategy, Facts: source.facts, Extracted: source.extracted}
+	}
+	return sources
+}
+
+func (c *pipelineCheckpoint) corroboration() *corroboration {
+	if len(c.Sources) == 0 {
+		return nil
+	}
+	multiple := &corroboration{sources: make([]resolvedSource, len(c.Sources))}
+	for idx, source := range c.Sources {
+		multiple.sources[idx] = resolvedSource{url: source.URL, text: source.Text, fetchStrategy: source.FetchStrategy, facts: source.Facts, extracted: source.Extracted}
+	}
+	if c.done(stepFacts) {
+		multiple.facts = mergeSourceFacts(multiple.sources)
+	}
+	return multiple
+}
+
+// RetryAnalysis runs the steps a failed analysis didn't finish, reusing the
+// input and the saved output of the steps it did, as editors left it, and
+// completes the analysis in place. A run cut off without being marked failed
+// can be retried once it has gone stale.
+func (s *FactService) RetryAnalysis(ctx context.Context, articleID int64) (response models.PhaseOneResponse, err error) {
+	if s.store.DB() == nil {
+		return models.PhaseOneResponse{}, errors.New("database is not initialized")
+	}
+
+	claimed, err := s.store.ExecContext(
+		ctx,
+		`UPDATE articles SET status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND (LOWER(status) = ? OR (LOWER(status) IN (?, ?) AND updated_at < ?))`,
+		analysisStatusRetrying,
+		articleID,
+		analysisStatusFailed,
+		analysisStatusRunning,
+		analysisStatusRetrying,
+		time.Now().Add(-staleRunAfter),
+	)
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	if err := ensureRowsAffected(claimed); err != nil {
+		var status string
+		if err := s.store.QueryRowContext(ctx, "SELECT status FROM articles WHERE id = ?", articleID).Scan(&status); err != nil {
+			return models.PhaseOneResponse{}, err
+		}
+		if status == analysisStatusRunning || status == analysisStatusRetrying {
+			return models.PhaseOneResponse{}, invalidInputf("invalid retry: analysis %d is still running", articleID)
+		}
+		return models.PhaseOneResponse{}, invalidInputf("invalid retry: analysis %d is %s and only failed analyses can be retried", articleID, status)
+	}
+
+	var run *phaseOneRun
+	runID := newLLMRunID()
+	defer func() {
+		if err == nil {
+			return
+		}
+		if run != nil && run.step != "" {
+			err = s.recordFailure(ctx, runID, run, err)
+			if ctx.Err() == nil {
+				announcePhaseOneFailure(run.sourceURL, err)
+			}
+			return
+		}
+		// Nothing ran, so the analysis goes back as it was.
+		if _, releaseErr := s.store.ExecContext(context.WithoutCancel(ctx), "UPDATE articles SET status = ? WHERE id = ? AND status = ?", analysisStatusFailed, articleID, analysisStatusRetrying); releaseErr != nil {
+			log.Printf("[pipeline] failed to release the retry of analysis %d: %v", articleID, releaseErr)
+		}
+	}()
+
+	run, err = s.loadFailedRun(ctx, articleID)
+	if err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	step := run.step
+	run.step = ""
+	if step == "" {
+		step = "its last saved step"
+	}
+
+	if err := s.applyRuntimeAISettings(ctx); err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	ctx = withLLMRunID(ctx, runID)
+	ctx = withLengthTargets(ctx, run.checkpoint.Lengths)
+
+	activePrompts, err := loadPromptSet(ctx, s.store)
+	if err != nil {
+		log.Printf("[prompts] failed to load active prompts, using built-in defaults: %v", err)
+	} else {
+		ctx = withPromptSet(ctx, activePrompts)
+	}
+
+	log.Printf("[pipeline] retrying analysis %d from %s (%d steps done)", articleID, step, len(run.checkpoint.Completed))
+	if err := s.generate(ctx, run); err != nil {
+		return models.PhaseOneResponse{}, err
+	}
+	return s.finish(ctx, runID, run, activePrompts.versions())
+}
+
+func (s *FactService) loadFailedRun(ctx context.Context, articleID int64) (*phaseOneRun, error) {
+	var (
+		run            = phaseOneRun{articleID: articleID}
+		checkpoint     sql.NullString
+		submittedBy    sql.NullInt64
+		channel        string
+		languageCode   string
+		confidence     float64
+		method         string
+		outputLanguage string
+	)
+	err := s.store.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(source_url, ''), COALESCE(raw_text, ''), pipeline_checkpoint, COALESCE(failed_step, ''), submitted_by, COALESCE(submission_channel, ''),
+			COALESCE(input_language, ''), COALESCE(input_language_confidence, 0), COALESCE(language_detection, ''), COALESCE(output_language, '')
+		FROM articles WHERE id = ?`,
+		articleID,
+	).Scan(&run.sourceURL, &run.rawText, &checkpoint, &run.step, &submittedBy, &channel, &languageCode, &confidence, &method, &outputLanguage)
+	if err != nil {
+		return nil, err
+	}
+	if !checkpoint.Valid || strings.TrimSpace(checkpoint.String) == "" {
+		return nil, invalidInputf("invalid retry: analysis %d has no saved progress", articleID)
+	}
+	if err := json.Unmarshal([]byte(checkpoint.String), &run.checkpoint); err != nil {
+		return nil, fmt.Errorf("analysis %d has an unreadable checkpoint: %w", articleID, err)
+	}
+
+	languages, err := loadLanguages(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+	if run.checkpoint.OutputLanguage == "" {
+		run.checkpoint.OutputLanguage = outputLanguage
+	}
+	output := englishLanguage
+	if run.checkpoint.OutputLanguage != "" {
+		if output, err = pickLanguage(languages, run.checkpoint.OutputLanguage); err != nil {
+			return nil, err
+		}
+	}
+	run.checkpoint.OutputLanguage = output.Name
+	run.generationLanguage = generationLanguageFor(output)
+
+	run.detected = languageDetection{confidence: confidence, method: method}
+	for _, language := range languages {
+		if strings.EqualFold(language.Code, languageCode) {
+			run.detected.language = language
+		}
+	}
+	run.submission = &models.Submission{SubmittedBy: nullInt64Pointer(submittedBy), Channel: channel}
+	run.multiple = run.checkpoint.corroboration()
+	run.factsInput = compactLLMInput(run.rawText)
+	if err := s.loadEditedOutput(ctx, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// loadEditedOutput takes the facts, questions and article of a run as they
+// are stored, since editors may have worked on them since the run failed, and
+// sets the steps that used them to run again where they changed.
+func (s *FactService) loadEditedOutput(ctx context.Context, run *phaseOneRun) error {
+	cp := &run.checkpoint
+	if cp.done(stepFacts) {
+		facts, err := listTexts(ctx, s.store, "SELECT COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY position ASC, id ASC", run.articleID)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(facts, listTextsOf(cp.Facts)) {
+			cp.Facts = facts
+			cp.FactTags = nil
+			cp.rerunAfter(stepFacts)
+			// Source tags belong to the facts as extracted.
+			if run.multiple != nil {
+				run.multiple.facts = nil
+			}
+		}
+	}
+	if cp.done(stepGaps) {
+		gaps, err := listTexts(ctx, s.store, "SELECT COALESCE(question, '') FROM gaps WHERE article_id = ? ORDER BY id ASC", run.articleID)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(gaps, listTextsOf(cp.Gaps)) {
+			cp.Gaps = gaps
+			cp.rerunAfter(stepGaps)
+		}
+	}
+	if cp.done(stepArticle) {
+		var articleText string
+		if err := s.store.QueryRowContext(ctx, "SELECT COALESCE(article_text, '') FROM articles WHERE id = ?", run.articleID).Scan(&articleText); err != nil {
+			return err
+		}
+		if strings.TrimSpace(articleText) != strings.TrimSpace(cp.Article) {
+			cp.Article, cp.Sections = articleText, nil
+			cp.rerunAfter(stepArticle)
+		}
+	}
+	return nil
+}
+
+// listTextsOf trims values and drops blanks, as listTexts does for stored
+// rows, so the two can be compared.
+func listTextsOf(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+// completeRunArticle fills in the analysis of a finished run. The facts and
+// questions saved as the run went are replaced by the final ones, and what was
+// kept for a retry is cleared.
+func completeRunArticle(ctx context.Context, tx *repository.Tx, articleID int64, articleText string, topicID *int64, suggestedTopicID *int64, suggestionSource string, headlineSelected string, straplineSelected string) error {
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE articles
+		SET status = ?, article_text = ?, topic_id = ?, suggested_topic_id = ?, category_suggestion_source = ?, headline_selected = ?, strapline_selected = ?,
+			article_sections = NULL,
+			failed_step = NULL, failure_error = NULL, failed_at = NULL, pipeline_checkpoint = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		"pending",
+		articleText,
+		topicID,
+		suggestedTopicID,
+		nullString(suggestionSource),
+		headlineSelected,
+		straplineSelected,
+		articleID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return err
+	}
+	for _, query := range []string{"DELETE FROM facts WHERE article_id = ?", "DELETE FROM gaps WHERE article_id = ?", "DELETE FROM edit_history WHERE article_id = ?"} {
+		if _, err := tx.ExecContext(ctx, query, articleID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	maxPurgeReason   = 2000
+	maxPurgeAnalyses = 500
+	privacyPurgeList = 100
+)
+
+// privacyTables are the tables holding rows of an analysis by article_id.
+// Share links are left out of the export: their rows only carry a token hash
+// and view counts.
+var privacyTables = []string{
+	"article_sources",
+	"article_images",
+	"source_snapshots",
+	"facts",
+	"gaps",
+	"gap_leads",
+	"gap_answers",
+	"headlines",
+	"straplines",
+	"headline_results",
+	"timeline_events",
+	"numeric_claims",
+	"unsupported_sentences",
+	"fact_check_matches",
+	"article_entities",
+	"translations",
+	"analysis_comments",
+	"analysis_flags",
+	"analysis_corrections",
+	"analysis_approvals",
+	"edit_history",
+	"article_prompt_versions",
+	"story_thread_articles",
+	"llm_calls",
+}
+
+type PrivacyService struct {
+	store    *repository.Store
+	llmCache *LLMCacheService
+}
+
+func NewPrivacyService(database *sql.DB) *PrivacyService {
+	return &PrivacyService{
+		store:    repository.New(database),
+		llmCache: NewLLMCacheService(database),
+	}
+}
+
+// Export gathers every stored row of the analyses that used sourceURL, as
+// their source or as a corroborating one, or that mention entity, matched by
+// name, Wikidata label or Wikidata id. Exactly one of the two is given.
+func (s *PrivacyService) Export(ctx context.Context, sourceURL string, entity string) (models.PrivacyExport, error) {
+	sourceURL = strings.TrimSpace(sourceURL)
+	entity = strings.TrimSpace(entity)
+
+	var (
+		query string
+		args  []any
+	)
+	switch {
+	case sourceURL != "" && entity != "":
+		return models.PrivacyExport{}, invalidInput("give either sourceUrl or entity, not both")
+	case sourceURL != "":
+		query = `
+			SELECT a.id FROM articles a WHERE a.source_url = ?
+			UNION
+			SELECT s.article_id FROM article_sources s WHERE s.source_url = ?`
+		args = []any{sourceURL, sourceURL}
+	case entity != "":
+		key := strings.ToLower(entity)
+		query = `
+			SELECT DISTINCT ae.article_id
+			FROM article_entities ae
+			JOIN entities e ON e.id = ae.entity_id
+			WHERE LOWER(ae.name) = ? OR e.name_key = ? OR LOWER(e.label) = ? OR e.wikidata_id = ?`
+		args = []any{key, key, key, entity}
+	default:
+		return models.PrivacyExport{}, invalidInput("sourceUrl or entity is required")
+	}
+
+	ids, err := listIDs(ctx, s.store, query, args...)
+	if err != nil {
+		return models.PrivacyExport{}, err
+	}
+	slices.Sort(ids)
+
+	export := models.PrivacyExport{
+		SourceURL:   sourceURL,
+		Entity:      entity,
+		GeneratedAt: time.Now().UTC(),
+		Analyses:    make([]models.PrivacyExportAnalysis, 0, len(ids)),
+	}
+	for _, id := range ids {
+		analysis, err := s.exportAnalysis(ctx, id)
+		if err != nil {
+			return models.PrivacyExport{}, err
+		}
+		export.Analyses = append(export.Analyses, analysis)
+	}
+	return export, nil
+}
+
+func (s *PrivacyService) exportAnalysis(ctx context.Context, articleID int64) (models.PrivacyExportAnalysis, error) {
+	analysis := models.PrivacyExportAnalysis{ID: articleID, Records: make(map[string][]map[string]any, len(privacyTables))}
+
+	articles, err := queryRecords(ctx, s.store, "SELECT * FROM articles WHERE id = ?", articleID)
+	if err != nil {
+		return analysis, err
+	}
+	if len(articles) == 0 {
+		return analysis, sql.ErrNoRows
+	}
+	analysis.Article = articles[0]
+
+	for _, table := range privacyTables {
+		records, err := queryRecords(ctx, s.store, "SELECT * FROM "+table+" WHERE article_id = ? ORDER BY 1", articleID)
+		if err != nil {
+			return analysis, fmt.Errorf("export %s: %w", table, err)
+		}
+		analysis.Records[table] = records
+	}
+
+	entities, err := queryRecords(ctx, s.store, "SELECT e.* FROM entities e JOIN article_entities ae ON ae.entity_id = e.id WHERE ae.article_id = ? ORDER BY e.id", articleID)
+	if err != nil {
+		return analysis, err
+	}
+	analysis.Records["entities"] = entities
+	return analysis, nil
+}
+
+// queryRecords returns rows as maps of column name to value, with text
+// columns as strings rather than the bytes some drivers hand back.
+func queryRecords(ctx context.Context, q repository.Querier, query string, args ...any) ([]map[string]any, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]map[string]any, 0)
+	for rows.Next() {
+		values := make([]any, len(columns))
+		targets := make([]any, len(columns))
+		for idx := range values {
+			targets[idx] = &values[idx]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+		record := make(map[string]any, len(columns))
+		for idx, column := range columns {
+			if raw, ok := values[idx].([]byte); ok {
+				record[column] = string(raw)
+				continue
+			}
+			record[column] = values[idx]
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func listIDs(ctx context.Context, q repository.Querier, query string, args ...any) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Purge irreversibly deletes analyses with everything derived from them:
+// facts, sources, snapshots, images, comments and history go with the
+// article, and the LLM calls, jobs, translation memory entries and entity
+// lookups that only they used are deleted too. story_threads they were in
+// are retitled from the analyses left, as a thread's title is copied from
+// its earliest one, and deleted once empty. Stored files no other
+// analysis shares are removed from disk. The LLM cache is keyed by prompt
+// hash alone, so it is emptied whole. The purge is recorded with purgedBy
+// and reason but none of the purged content.
+func (s *PrivacyService) Purge(ctx context.Context, analysisIDs []int64, reason string, purgedBy *int64) (models.PrivacyPurge, error) {
+	if purgedBy == nil {
+		return models.PrivacyPurge{}, invalidInput("purging needs a signed-in user")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return models.PrivacyPurge{}, invalidInput("reason is required")
+	}
+	if len([]rune(reason)) > maxPurgeReason {
+		return models.PrivacyPurge{}, invalidInputf("reason must be at most %d characters", maxPurgeReason)
+	}
+	ids := slices.Clone(analysisIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) == 0 {
+		return models.PrivacyPurge{}, invalidInput("analysisIds is required")
+	}
+	if len(ids) > maxPurgeAnalyses {
+		return models.PrivacyPurge{}, invalidInputf("at most %d analyses can be purged at once", maxPurgeAnalyses)
+	}
+
+	args := make([]any, len(ids))
+	for idx, id := range ids {
+		args[idx] = id
+	}
+	in := "(" + repository.Placeholders(len(ids)) + ")"
+
+	purge := models.PrivacyPurge{PurgedBy: purgedBy, Reason: reason, AnalysisIDs: ids}
+	keys := make([]string, 0)
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		found, err := listIDs(ctx, tx, "SELECT id FROM articles WHERE id IN "+in, args...)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if !slices.Contains(found, id) {
+				return invalidInputf("analysis %d does not exist", id)
+			}
+		}
+
+		keysFound, err := listTexts(
+			ctx,
+			tx,
+			"SELECT storage_key FROM article_images WHERE article_id IN "+in+" UNION SELECT storage_key FROM source_snapshots WHERE article_id IN "+in,
+			append(append([]any{}, args...), args...)...,
+		)
+		if err != nil {
+			return err
+		}
+		keys = keysFound
+
+		hashes, err := s.translationHashes(ctx, tx, in, args)
+		if err != nil {
+			return err
+		}
+		entityIDs, err := listIDs(ctx, tx, "SELECT DISTINCT entity_id FROM article_entities WHERE article_id IN "+in, args...)
+		if err != nil {
+			return err
+		}
+		gapIDs, err := listIDs(ctx, tx, "SELECT id FROM gaps WHERE article_id IN "+in, args...)
+		if err != nil {
+			return err
+		}
+		threadIDs, err := listIDs(ctx, tx, "SELECT DISTINCT thread_id FROM story_thread_articles WHERE article_id IN "+in, args...)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM llm_calls WHERE article_id IN "+in, args...)
+		if err != nil {
+			return err
+		}
+		calls, _ := result.RowsAffected()
+		purge.LLMCalls = int(calls)
+
+		jobs, err := deleteJobsMentioning(ctx, tx, "articleId", ids)
+		if err != nil {
+			return err
+		}
+		gapJobs, err := deleteJobsMentioning(ctx, tx, "gapId", gapIDs)
+		if err != nil {
+			return err
+		}
+		purge.Jobs = jobs + gapJobs
+
+		if len(hashes) > 0 {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM translation_memory WHERE source_hash IN ("+repository.Placeholders(len(hashes))+")", hashes...); err != nil {
+				return err
+			}
+		}
+
+		result, err = tx.ExecContext(ctx, "DELETE FROM articles WHERE id IN "+in, args...)
+		if err != nil {
+			return err
+		}
+		deleted, _ := result.RowsAffected()
+		purge.Analyses = int(deleted)
+
+		if err := retitleThreads(ctx, tx, threadIDs); err != nil {
+			return err
+		}
+
+		if len(entityIDs) > 0 {
+			entityArgs := make([]any, len(entityIDs))
+			for idx, id := range entityIDs {
+				entityArgs[idx] = id
+			}
+			_, err := tx.ExecContext(
+				ctx,
+				"DELETE FROM entities WHERE id IN ("+repository.Placeholders(len(entityIDs))+") AND NOT EXISTS (SELECT 1 FROM article_entities ae WHERE ae.entity_id = entities.id)",
+				entityArgs...,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		purge.ID, err = tx.Insert(
+			ctx,
+			"INSERT INTO privacy_purges (purged_by, reason, analysis_ids, analyses, llm_calls, jobs) VALUES (?, ?, ?, ?, ?, ?)",
+			*purgedBy,
+			reason,
+			joinIDs(ids),
+			purge.Analyses,
+			purge.LLMCalls,
+			purge.Jobs,
+		)
+		return err
+	})
+	if err != nil {
+		return models.PrivacyPurge{}, err
+	}
+
+	purge.FilesRemoved = removeUnusedFiles(ctx, s.store, keys)
+	if _, err := s.store.ExecContext(ctx, "UPDATE privacy_purges SET files_removed = ? WHERE id = ?", purge.FilesRemoved, purge.ID); err != nil {
+		log.Printf("[privacy] failed to record files removed by purge %d: %v", purge.ID, err)
+	}
+	if _, err := s.llmCache.Purge(ctx, "", false); err != nil {
+		log.Printf("[privacy] failed to empty the LLM cache after purge %d: %v", purge.ID, err)
+	}
+	log.Printf("[privacy] purge %d removed %d analyses", purge.ID, purge.Analyses)
+
+	return s.getPurge(ctx, purge.ID)
+}
+
+// translationHashes returns the translation memory hashes of the texts the
+// analyses could have had translated.
+func (s *PrivacyService) translationHashes(ctx context.Context, tx *repository.Tx, in string, args []any) ([]any, error) {
+	queries := []string{
+		"SELECT COALESCE(fact_text, '') FROM facts WHERE article_id IN " + in,
+		"SELECT COALESCE(question, '') FROM gaps WHERE article_id IN " + in,
+		"SELECT COALESCE(headline_text, '') FROM headlines WHERE article_id IN " + in,
+		"SELECT COALESCE(strapline_text, '') FROM straplines WHERE article_id IN " + in,
+		"SELECT COALESCE(article_text, '') FROM articles WHERE id IN " + in,
+	}
+	seen := make(map[string]struct{})
+	hashes := make([]any, 0)
+	for _, query := range queries {
+		texts, err := listTexts(ctx, tx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, text := range texts {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			hash := translationHash(text)
+			if _, ok := seen[hash]; ok {
+				continue
+			}
+			seen[hash] = struct{}{}
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// deleteJobsMentioning deletes jobs whose payload or result names one of ids
+// under field, as job payloads are stored as JSON text.
+func deleteJobsMentioning(ctx context.Context, tx *repository.Tx, field string, ids []int64) (int, error) {
+	deleted := 0
+	for _, id := range ids {
+		mention := `"` + field + `":` + strconv.FormatInt(id, 10)
+		result, err := tx.ExecContext(
+			ctx,
+			"DELETE FROM jobs WHERE payload LIKE ? OR payload LIKE ? OR result LIKE ? OR result LIKE ?",
+			"%"+mention+",%",
+			"%"+mention+"}%",
+			"%"+mention+",%",
+			"%"+mention+"}%",
+		)
+		if err != nil {
+			return deleted, err
+		}
+		count, _ := result.RowsAffected()
+		deleted += int(count)
+	}
+	return deleted, nil
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for idx, id := range ids {
+		parts[idx] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+const purgeSelect = `
+	SELECT p.id, p.purged_by, COALESCE(u.display_name, u.email, ''), p.reason, p.analysis_ids,
+		p.analyses, p.llm_calls, p.jobs, p.files_removed, COALESCE(p.created_at, CURRENT_TIMESTAMP)
+	FROM privacy_purges p
+	LEFT JOIN users u ON u.id = p.purged_by`
+
+// ListPurges returns the most recent purges, newest first.
+func (s *PrivacyService) ListPurges(ctx context.Context) ([]models.PrivacyPurge, error) {
+	rows, err := s.store.QueryContext(ctx, purgeSelect+" ORDER BY p.created_at DESC, p.id DESC LIMIT ?", privacyPurgeList)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purges := make([]models.PrivacyPurge, 0)
+	for rows.Next() {
+		purge, err := scanPurge(rows)
+		if err != nil {
+			return nil, err
+		}
+		purges = append(purges, purge)
+	}
+	return purges, rows.Err()
+}
+
+func (s *PrivacyService) getPurge(ctx context.Context, purgeID int64) (models.PrivacyPurge, error) {
+	return scanPurge(s.store.QueryRowContext(ctx, purgeSelect+" WHERE p.id = ?", purgeID))
+}
+
+func scanPurge(row rowScanner) (models.PrivacyPurge, error) {
+	var (
+		purge    models.PrivacyPurge
+		purgedBy sql.NullInt64
+		ids      string
+	)
+	err := row.Scan(
+		&purge.ID,
+		&purgedBy,
+		&purge.PurgedByName,
+		&purge.Reason,
+		&ids,
+		&purge.Analyses,
+		&purge.LLMCalls,
+		&purge.Jobs,
+		&purge.FilesRemoved,
+		&purge.CreatedAt,
+	)
+	if err != nil {
+		return models.PrivacyPurge{}, err
+	}
+	purge.PurgedBy = nullInt64Pointer(purgedBy)
+	purge.AnalysisIDs = make([]int64, 0)
+	for _, part := range strings.Split(ids, ",") {
+		if id, err := strconv.ParseInt(part, 10, 64); err == nil {
+			purge.AnalysisIDs = append(purge.AnalysisIDs, id)
+		}
+	}
+	return purge, nil
+}
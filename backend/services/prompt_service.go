@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/prompts"
+	"nanoheads/repository"
+)
+
+type PromptService struct {
+	store *repository.Store
+}
+
+type promptSetKey struct{}
+
+type activePrompt struct {
+	version int
+	body    string
+}
+
+// promptSet is the set of active prompt bodies for one pipeline run. It
+// remembers which versions were rendered so they can be stored with the
+// analysis.
+type promptSet struct {
+	mu     sync.Mutex
+	active map[string]activePrompt
+	used   map[string]int
+}
+
+// Stand-in values for previews that don't supply their own.
+var promptSampleVariables = map[string]string{
+	"text":       "The city council approved a budget of 420 crore rupees for road repairs on Monday. Work on the first 40 kilometres begins in March.",
+	"facts":      "- The city council approved a 420 crore rupee road repair budget on Monday.\n- Work on the first 40 kilometres begins in March.",
+	"gaps":       "- Who won the repair tender?\n- When is the work expected to finish?",
+	"article":    "The city council approved a 420 crore rupee budget for road repairs on Monday, with work on the first 40 kilometres set to begin in March.",
+	"categories": "- Politics\n- Business\n- Other",
+	"languages":  "- en: English\n- te: Telugu\n- hi: Hindi",
+}
+
+func NewPromptService(database *sql.DB) *PromptService {
+	return &PromptService{
+		store: repository.New(database),
+	}
+}
+
+func (s *PromptService) List(ctx context.Context) ([]models.PromptTemplate, error) {
+	items := make([]models.PromptTemplate, 0, len(prompts.Templates()))
+	for _, template := range prompts.Templates() {
+		item, err := s.summary(ctx, template)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *PromptService) Get(ctx context.Context, key string) (models.PromptTemplateDetail, error) {
+	template, err := lookupPromptTemplate(key)
+	if err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+
+	summary, err := s.summary(ctx, template)
+	if err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+
+	query := `
+		SELECT version, body, COALESCE(notes, ''), is_active, created_by, COALESCE(created_at, CURRENT_TIMESTAMP)
+		FROM prompt_templates
+		WHERE template_key = ?
+		ORDER BY version DESC;
+	`
+	rows, err := s.store.QueryContext(ctx, query, template.Key)
+	if err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+	defer rows.Close()
+
+	versions := make([]models.PromptVersion, 0)
+	for rows.Next() {
+		var (
+			version   models.PromptVersion
+			createdBy sql.NullInt64
+		)
+		if err := rows.Scan(&version.Version, &version.Body, &version.Notes, &version.Active, &createdBy, &version.CreatedAt); err != nil {
+			return models.PromptTemplateDetail{}, err
+		}
+		if createdBy.Valid {
+			version.CreatedBy = &createdBy.Int64
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+
+	return models.PromptTemplateDetail{
+		PromptTemplate: summary,
+		Default:        template.Default,
+		Versions:       versions,
+	}, nil
+}
+
+// Save stores body as the next version of key. When activate is false the
+// version is kept as a draft and the current one stays live.
+func (s *PromptService) Save(ctx context.Context, key string, body string, notes string, activate bool, createdBy *int64) (models.PromptTemplateDetail, error) {
+	template, err := lookupPromptTemplate(key)
+	if err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+	if err := prompts.Validate(template.Key, body); err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		var latest int
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM prompt_templates WHERE template_key = ?", template.Key).Scan(&latest); err != nil {
+			return err
+		}
+
+		if activate {
+			if _, err := tx.ExecContext(ctx, "UPDATE prompt_templates SET is_active = false WHERE template_key = ?", template.Key); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO prompt_templates (template_key, version, body, notes, is_active, created_by) VALUES (?, ?, ?, ?, ?, ?)",
+			template.Key,
+			latest+1,
+			body,
+			nullString(strings.TrimSpace(notes)),
+			activate,
+			createdBy,
+		)
+		return err
+	})
+	if err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+
+	return s.Get(ctx, template.Key)
+}
+
+// Activate makes version the live body for key. Version 0 goes back to the
+// built-in default.
+func (s *PromptService) Activate(ctx context.Context, key string, version int) (models.PromptTemplateDetail, error) {
+	template, err := lookupPromptTemplate(key)
+	if err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+	if version < 0 {
+		return models.PromptTemplateDetail{}, invalidInput("version must be zero or positive")
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if version > 0 {
+			var exists int
+			if err := tx.QueryRowContext(ctx, "SELECT 1 FROM prompt_templates WHERE template_key = ? AND version = ?", template.Key, version).Scan(&exists); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE prompt_templates SET is_active = false WHERE template_key = ?", template.Key); err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE prompt_templates SET is_active = true WHERE template_key = ? AND version = ?", template.Key, version)
+		return err
+	})
+	if err != nil {
+		return models.PromptTemplateDetail{}, err
+	}
+
+	return s.Get(ctx, template.Key)
+}
+
+// Preview renders a prompt without calling the model. body wins over version;
+// with neither, the active body is used. Missing variables get sample values.
+func (s *PromptService) Preview(ctx context.Context, key string, body string, version *int, vars map[string]string) (models.PromptPreview, error) {
+	template, err := lookupPromptTemplate(key)
+	if err != nil {
+		return models.PromptPreview{}, err
+	}
+
+	switch {
+	case strings.TrimSpace(body) != "":
+		if err := prompts.Validate(template.Key, body); err != nil {
+			return models.PromptPreview{}, err
+		}
+	case version != nil && *version > 0:
+		if err := s.store.QueryRowContext(ctx, "SELECT body FROM prompt_templates WHERE template_key = ? AND version = ?", template.Key, *version).Scan(&body); err != nil {
+			return models.PromptPreview{}, err
+		}
+	case version != nil:
+		body = template.Default
+	default:
+		summary, err := s.summary(ctx, template)
+		if err != nil {
+			return models.PromptPreview{}, err
+		}
+		body = summary.Body
+	}
+
+	filled := make(map[string]string, len(template.Variables))
+	for _, name := range template.Variables {
+		if value, ok := vars[name]; ok {
+			filled[name] = value
+			continue
+		}
+		filled[name] = promptSampleVariables[name]
+	}
+
+	return models.PromptPreview{
+		Key:       template.Key,
+		Variables: filled,
+		Prompt:    prompts.Render(body, filled),
+	}, nil
+}
+
+func (s *PromptService) summary(ctx context.Context, template prompts.Template) (models.PromptTemplate, error) {
+	item := models.PromptTemplate{
+		Key:         template.Key,
+		Description: template.Description,
+		Variables:   template.Variables,
+		Body:        template.Default,
+	}
+
+	if err := s.store.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM prompt_templates WHERE template_key = ?", template.Key).Scan(&item.LatestVersion); err != nil {
+		return models.PromptTemplate{}, err
+	}
+
+	var createdAt time.Time
+	err := s.store.QueryRowContext(
+		ctx,
+		"SELECT version, body, COALESCE(created_at, CURRENT_TIMESTAMP) FROM prompt_templates WHERE template_key = ? AND is_active = true",
+		template.Key,
+	).Scan(&item.ActiveVersion, &item.Body, &createdAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.PromptTemplate{}, err
+	}
+	if err == nil {
+		item.UpdatedAt = &createdAt
+	}
+	return item, nil
+}
+
+func lookupPromptTemplate(key string) (prompts.Template, error) {
+	template, ok := prompts.Lookup(strings.ToLower(strings.TrimSpace(key)))
+	if !ok {
+		return prompts.Template{}, invalidInputf("invalid prompt template %q", key)
+	}
+	return template, nil
+}
+
+func loadPromptSet(ctx context.Context, store *repository.Store) (*promptSet, error) {
+	rows, err := store.QueryContext(ctx, "SELECT template_key, version, body FROM prompt_templates WHERE is_active = true")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := &promptSet{
+		active: make(map[string]activePrompt),
+		used:   make(map[string]int),
+	}
+	for rows.Next() {
+		var (
+			key    string
+			prompt activePrompt
+		)
+		if err := rows.Scan(&key, &prompt.version, &prompt.body); err != nil {
+			return nil, err
+		}
+		set.active[key] = prompt
+	}
+	return set, rows.Err()
+}
+
+func (p *promptSet) render(key string, vars map[string]string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if prompt, ok := p.active[key]; ok {
+		p.used[key] = prompt.version
+		return prompts.Render(prompt.body, vars)
+	}
+
+	p.used[key] = 0
+	template, _ := prompts.Lookup(key)
+	return prompts.Render(template.Default, vars)
+}
+
+func (p *promptSet) versions() map[string]int {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make(map[string]int, len(p.used))
+	for key, version := range p.used {
+		out[key] = version
+	}
+	return out
+}
+
+func withPromptSet(ctx context.Context, set *promptSet) context.Context {
+	return context.WithValue(ctx, promptSetKey{}, set)
+}
+
+// renderPrompt uses the run's prompt set when there is one, otherwise the
+// built-in default.
+func renderPrompt(ctx context.Context, key string, vars map[string]string) string {
+	if set, ok := ctx.Value(promptSetKey{}).(*promptSet); ok && set != nil {
+		return set.render(key, vars)
+	}
+
+	template, _ := prompts.Lookup(key)
+	return prompts.Render(template.Default, vars)
+}
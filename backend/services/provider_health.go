@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+var llmProviders = []string{"groq", "openai"}
+
+var providerModelEnv = map[string]string{
+	"groq":   "GROQ_MODEL",
+	"openai": "OPENAI_MODEL",
+}
+
+// Errors worth resting a provider over; a bad request or an unparseable
+// answer still means the provider is up.
+var providerFailureClasses = map[string]struct{}{
+	"rate_limited":         {},
+	"auth":                 {},
+	"provider_unavailable": {},
+	"timeout":              {},
+	"network":              {},
+}
+
+// providerBreakers counts failures per provider for the whole process; each
+// replica keeps its own.
+var providerBreakers = &providerBreakerSet{states: make(map[string]*providerState)}
+
+type providerBreakerSet struct {
+	mu     sync.Mutex
+	states map[string]*providerState
+}
+
+type providerState struct {
+	failures    int
+	openUntil   time.Time
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+}
+
+// ProviderUnavailableError is returned instead of calling a provider whose
+// circuit breaker is open. Providers has the health of each provider tried.
+type ProviderUnavailableError struct {
+	Providers []models.ProviderHealth
+}
+
+func (e *ProviderUnavailableError) Error() string {
+	health := e.Providers[0]
+	message := fmt.Sprintf("AI provider %s is unavailable after %d consecutive failures", health.Provider, health.ConsecutiveFailures)
+	if health.RetryAt != nil {
+		message += "; retry after " + health.RetryAt.Format(time.RFC3339)
+	}
+	return message
+}
+
+// ProviderHealth reports the circuit breaker of every supported provider.
+func ProviderHealth() []models.ProviderHealth {
+	now := time.Now()
+	health := make([]models.ProviderHealth, 0, len(llmProviders))
+	for _, provider := range llmProviders {
+		health = append(health, providerBreakers.health(provider, now))
+	}
+	return health
+}
+
+// allow reports whether provider may be called now.
+func (b *providerBreakerSet) allow(provider string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.states[provider]
+	return !ok || !now.Before(state.openUntil)
+}
+
+// record counts the outcome of a call. A provider that fails
+// ProviderFailures times in a row is opened for ProviderCooldown, and a
+// failure while half-open opens it again straight away.
+func (b *providerBreakerSet) record(provider string, err error, now time.Time) {
+	cfg := config.Current()
+	class := classifyLLMError(err)
+	if class == "canceled" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.states[provider]
+	if !ok {
+		state = &providerState{}
+		b.states[provider] = state
+	}
+
+	if _, failed := providerFailureClasses[class]; !failed {
+		state.failures = 0
+		state.openUntil = time.Time{}
+		state.lastSuccess = now
+		return
+	}
+	state.failures++
+	state.lastFailure = now
+	state.lastError = truncateRunes(redactSensitive(err.Error()), 300)
+	if cfg.ProviderFailures > 0 && state.failures >= cfg.ProviderFailures {
+		if now.After(state.openUntil) {
+			log.Printf("[providers] %s failed %d times in a row, resting it for %s: %s", provider, state.failures, cfg.ProviderCooldown.Duration, state.lastError)
+		}
+		state.openUntil = now.Add(cfg.ProviderCooldown.Duration)
+	}
+}
+
+func (b *providerBreakerSet) health(provider string, now time.Time) models.ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	health := models.ProviderHealth{Provider: provider, State: models.ProviderClosed, Healthy: true}
+	state, ok := b.states[provider]
+	if !ok {
+		return health
+	}
+
+	health.ConsecutiveFailures = state.failures
+	health.LastError = state.lastError
+	if !state.lastFailure.IsZero() {
+		lastFailure := state.lastFailure
+		health.LastFailureAt = &lastFailure
+	}
+	if !state.lastSuccess.IsZero() {
+		lastSuccess := state.lastSuccess
+		health.LastSuccessAt = &lastSuccess
+	}
+	if !state.openUntil.IsZero() {
+		retryAt := state.openUntil
+		health.RetryAt = &retryAt
+		health.State = models.ProviderHalfOpen
+		if now.Before(state.openUntil) {
+			health.State = models.ProviderOpen
+			health.Healthy = false
+		}
+	}
+	return health
+}
+
+// routeCall picks the service a call goes through: s itself, or, while its
+// provider is resting, a copy set up for ProviderFailover with that
+// provider's default model.
+func (s *OpenAIService) routeCall(step string) (*OpenAIService, error) {
+	if s.provider == mockProvider {
+		return s, nil
+	}
+	now := time.Now()
+	if providerBreakers.allow(s.provider, now) {
+		return s, nil
+	}
+
+	unavailable := &ProviderUnavailableError{Providers: []models.ProviderHealth{providerBreakers.health(s.provider, now)}}
+	failover := config.Current().ProviderFailover
+	if failover == "" || failover == s.provider {
+		return nil, unavailable
+	}
+	if !providerBreakers.allow(failover, now) {
+		unavailable.Providers = append(unavailable.Providers, providerBreakers.health(failover, now))
+		return nil, unavailable
+	}
+
+	fallback := *s
+	fallback.model = os.Getenv(providerModelEnv[failover])
+	if err := fallback.ApplySettings(failover, fallback.model, s.maxTokens); err != nil {
+		return nil, err
+	}
+	if fallback.apiKey == "" {
+		log.Printf("[providers][%s] %s is resting and %s has no API key to fail over with", step, s.provider, failover)
+		return nil, unavailable
+	}
+	log.Printf("[providers][%s] %s is resting, failing over to %s", step, s.provider, failover)
+	return &fallback, nil
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	providerKeyTestTimeout = 15 * time.Second
+	maxProviderKeyLength   = 512
+)
+
+// storedProviderKeys holds the decrypted keys from provider_api_keys. It is
+// reloaded before each pipeline run, so keys saved on another instance are
+// picked up by the next analysis here.
+var storedProviderKeys = &providerKeyCache{keys: make(map[string]string)}
+
+type providerKeyCache struct {
+	mu   sync.RWMutex
+	keys map[string]string
+}
+
+func (c *providerKeyCache) get(provider string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.keys[provider]
+}
+
+func (c *providerKeyCache) set(provider string, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key == "" {
+		delete(c.keys, provider)
+		return
+	}
+	c.keys[provider] = key
+}
+
+func (c *providerKeyCache) replace(keys map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = keys
+}
+
+type ProviderKeyService struct {
+	store  *repository.Store
+	client *http.Client
+}
+
+func NewProviderKeyService(database *sql.DB) *ProviderKeyService {
+	return &ProviderKeyService{
+		store:  repository.New(database),
+		client: &http.Client{Timeout: providerKeyTestTimeout},
+	}
+}
+
+// loadProviderKeys refreshes storedProviderKeys from the database. A key that
+// can't be opened, say after PROVIDER_KEY_SECRET changed, is skipped so the
+// provider falls back to its environment key.
+func loadProviderKeys(ctx context.Context, store *repository.Store) error {
+	secret, err := config.Current().ProviderKeyCipherKey()
+	if err != nil {
+		return nil
+	}
+
+	rows, err := store.QueryContext(ctx, `
+		SELECT p.provider_key, k.encrypted_key
+		FROM provider_api_keys k
+		JOIN ai_providers p ON p.id = k.provider_id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]string)
+	for rows.Next() {
+		var provider, sealed string
+		if err := rows.Scan(&provider, &sealed); err != nil {
+			return err
+		}
+		key, err := openProviderKey(secret, provider, sealed)
+		if err != nil {
+			log.Printf("[providers] ignoring the stored %s API key: %v", provider, err)
+			continue
+		}
+		keys[provider] = key
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	storedProviderKeys.replace(keys)
+	return nil
+}
+
+// sealProviderKey encrypts key with AES-GCM, binding it to provider so a
+// sealed key can't be moved to another provider's row. The nonce is kept in
+// front of the ciphertext.
+func sealProviderKey(secret []byte, provider string, key string) (string, error) {
+	aead, err := providerKeyAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(key), []byte(provider))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openProviderKey(secret []byte, provider string, encoded string) (string, error) {
+	aead, err := providerKeyAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("sealed key is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, ciphertext, []byte(provider))
+	if err != nil {
+		return "", errors.New("sealed key does not open with PROVIDER_KEY_SECRET")
+	}
+	return string(key), nil
+}
+
+func providerKeyAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// maskProviderKey keeps a key's prefix and last four characters, enough to
+// tell keys apart without revealing them.
+func maskProviderKey(key string) string {
+	if len(key) < 12 {
+		return strings.Repeat("•", 4)
+	}
+	prefix := key[:3]
+	if idx := strings.IndexAny(key, "-_"); idx > 0 && idx < 8 {
+		prefix = key[:idx+1]
+	}
+	return prefix + "…" + key[len(key)-4:]
+}
+
+// ListKeys reports where each provider's API key comes from, masked.
+func (s *ProviderKeyService) ListKeys(ctx context.Context) ([]models.ProviderKeyStatus, error) {
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT p.provider_key, p.display_name, k.key_hint, k.updated_at
+		FROM ai_providers p
+		LEFT JOIN provider_api_keys k ON k.provider_id = p.id
+		ORDER BY p.id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]models.ProviderKeyStatus, 0)
+	for rows.Next() {
+		var (
+			status    models.ProviderKeyStatus
+			hint      sql.NullString
+			updatedAt sql.NullTime
+		)
+		if err := rows.Scan(&status.Provider, &status.Name, &hint, &updatedAt); err != nil {
+			return nil, err
+		}
+		switch {
+		case hint.Valid:
+			status.Source = models.ProviderKeyStored
+			status.MaskedKey = hint.String
+			if updatedAt.Valid {
+				status.UpdatedAt = &updatedAt.Time
+			}
+		case providerEnvKey(status.Provider) != "":
+			status.Source = models.ProviderKeyEnvironment
+			status.MaskedKey = maskProviderKey(providerEnvKey(status.Provider))
+		default:
+			status.Source = models.ProviderKeyMissing
+		}
+		keys = append(keys, status)
+	}
+	return keys, rows.Err()
+}
+
+// SetKey seals and stores key for provider, replacing any stored before.
+func (s *ProviderKeyService) SetKey(ctx context.Context, provider string, key string, updatedBy *int64) (models.ProviderKeyStatus, error) {
+	if config.Current().ProviderKeySecret == "" {
+		return models.ProviderKeyStatus{}, notConfigured("PROVIDER_KEY_SECRET must be set to store provider API keys")
+	}
+	secret, err := config.Current().ProviderKeyCipherKey()
+	if err != nil {
+		return models.ProviderKeyStatus{}, err
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return models.ProviderKeyStatus{}, invalidInput("apiKey is required")
+	}
+	if len(key) > maxProviderKeyLength || strings.ContainsAny(key, " \t\r\n") {
+		return models.ProviderKeyStatus{}, invalidInput("apiKey is invalid")
+	}
+
+	providerID, name, err := s.provider(ctx, provider)
+	if err != nil {
+		return models.ProviderKeyStatus{}, err
+	}
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	sealed, err := sealProviderKey(secret, provider, key)
+	if err != nil {
+		return models.ProviderKeyStatus{}, err
+	}
+
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"provider_api_keys",
+		[]string{"provider_id", "encrypted_key", "key_hint", "updated_by"},
+		[]string{"provider_id"},
+		[]string{"encrypted_key", "key_hint", "updated_by"},
+		"updated_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		return models.ProviderKeyStatus{}, err
+	}
+	hint := maskProviderKey(key)
+	if _, err := s.store.ExecContext(ctx, query, providerID, sealed, hint, updatedBy); err != nil {
+		return models.ProviderKeyStatus{}, err
+	}
+	storedProviderKeys.set(provider, key)
+
+	now := time.Now()
+	return models.ProviderKeyStatus{Provider: provider, Name: name, Source: models.ProviderKeyStored, MaskedKey: hint, UpdatedAt: &now}, nil
+}
+
+// DeleteKey forgets the stored key, leaving provider on its environment key.
+func (s *ProviderKeyService) DeleteKey(ctx context.Context, provider string) error {
+	providerID, _, err := s.provider(ctx, provider)
+	if err != nil {
+		return err
+	}
+	result, err := s.store.ExecContext(ctx, "DELETE FROM provider_api_keys WHERE provider_id = ?", providerID)
+	if err != nil {
+		return err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return err
+	}
+	storedProviderKeys.set(strings.ToLower(strings.TrimSpace(provider)), "")
+	return nil
+}
+
+// TestKey checks key, or the key provider uses now when key is empty, by
+// listing the provider's models with it.
+func (s *ProviderKeyService) TestKey(ctx context.Context, provider string, key string) (models.ProviderKeyTest, error) {
+	if _, _, err := s.provider(ctx, provider); err != nil {
+		return models.ProviderKeyTest{}, err
+	}
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if err := loadProviderKeys(ctx, s.store); err != nil {
+		return models.ProviderKeyTest{}, err
+	}
+	current, baseURL := providerCredentials(provider)
+	key = strings.TrimSpace(key)
+	if key == "" {
+		key = current
+	}
+	result := models.ProviderKeyTest{Provider: provider}
+	if key == "" {
+		result.Error = "no API key is configured"
+		return result, nil
+	}
+	result.MaskedKey = maskProviderKey(key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/models", nil)
+	if err != nil {
+		return models.ProviderKeyTest{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	started := time.Now()
+	resp, err := s.client.Do(req)
+	result.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		result.Error = fmt.Sprintf("could not reach %s: %v", provider, err)
+		return result, nil
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		result.Valid = true
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		result.Error = "the provider rejected the key"
+	default:
+		result.Error = fmt.Sprintf("the provider answered %d", resp.StatusCode)
+	}
+	return result, nil
+}
+
+func (s *ProviderKeyService) provider(ctx context.Context, provider string) (int64, string, error) {
+	var (
+		id   int64
+		name string
+	)
+	err := s.store.QueryRowContext(ctx, "SELECT id, display_name FROM ai_providers WHERE provider_key = ?", strings.ToLower(strings.TrimSpace(provider))).Scan(&id, &name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", invalidInputf("invalid provider %q", provider)
+	}
+	return id, name, err
+}
+
+// providerEnvKey is the key provider would use without a stored one.
+func providerEnvKey(provider string) string {
+	if provider == "openai" {
+		return firstNonEmptyEnv("OPENAI_API_KEY")
+	}
+	return firstNonEmptyEnv("GROQ_API_KEY", "OPENAI_API_KEY")
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	providerProbeTimeout = 5 * time.Second
+	// Probes arrive every few seconds from each kubelet; the provider is
+	// asked at most this often and the answer reused in between.
+	providerProbeInterval = 30 * time.Second
+)
+
+// ProviderProbe checks that the selected AI provider answers, for readiness.
+type ProviderProbe struct {
+	store  *repository.Store
+	client *http.Client
+
+	mu        sync.Mutex
+	last      models.DependencyCheck
+	checkedAt time.Time
+}
+
+func NewProviderProbe(database *sql.DB) *ProviderProbe {
+	return &ProviderProbe{
+		store:  repository.New(database),
+		client: &http.Client{Timeout: providerProbeTimeout},
+	}
+}
+
+func (p *ProviderProbe) Check(ctx context.Context) models.DependencyCheck {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.checkedAt.IsZero() && time.Since(p.checkedAt) < providerProbeInterval {
+		return p.last
+	}
+	// A probe that gives up early shouldn't leave a failure cached.
+	p.last = p.probe(context.WithoutCancel(ctx))
+	p.checkedAt = time.Now()
+	return p.last
+}
+
+func (p *ProviderProbe) probe(ctx context.Context) models.DependencyCheck {
+	if config.Current().MockLLM {
+		return models.DependencyCheck{Status: models.DependencyUp, Detail: map[string]string{"provider": mockProvider}}
+	}
+
+	provider := "groq"
+	active, ok, err := resolveActiveModel(ctx, p.store)
+	if err != nil {
+		return models.DependencyCheck{Status: models.DependencyDown, Error: fmt.Sprintf("could not read the selected model: %v", err)}
+	}
+	if ok {
+		provider = active.provider
+	}
+	check := models.DependencyCheck{Status: models.DependencyDown, Detail: map[string]string{"provider": provider}}
+
+	if health := providerBreakers.health(provider, time.Now()); health.State == models.ProviderOpen {
+		check.Error = "circuit breaker is open: " + health.LastError
+		return check
+	}
+	if err := loadProviderKeys(ctx, p.store); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	apiKey, baseURL := providerCredentials(provider)
+	if apiKey == "" {
+		check.Error = "no API key is configured"
+		return check
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/models", nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	started := time.Now()
+	resp, err := p.client.Do(req)
+	check.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		check.Error = fmt.Sprintf("the provider answered %d", resp.StatusCode)
+		return check
+	}
+	check.Status = models.DependencyUp
+	return check
+}
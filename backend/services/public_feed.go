@@ -0,0 +1,260 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	// The feeds carry the newest analyses; the sitemap lists every one, up to
+	// the 50,000 URLs a sitemap may hold.
+	publicFeedItems   = 50
+	maxSitemapEntries = 50000
+	jsonFeedVersion   = "https://jsonfeed.org/version/1.1"
+	sitemapNamespace  = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+type PublicFeedService struct {
+	store *repository.Store
+}
+
+func NewPublicFeedService(database *sql.DB) *PublicFeedService {
+	return &PublicFeedService{store: repository.New(database)}
+}
+
+// publishedAnalysis is a completed analysis with a slug, which is what the
+// static front-end can serve.
+type publishedAnalysis struct {
+	id          int64
+	url         string
+	headline    string
+	summary     string
+	articleText string
+	category    string
+	language    string
+	completedAt time.Time
+	updatedAt   time.Time
+}
+
+// published loads the newest published analyses, at most limit, skipping
+// all but the newest of any that share a slug.
+func (s *PublicFeedService) published(ctx context.Context, cfg config.Config, limit int) ([]publishedAnalysis, error) {
+	if cfg.PublicSiteURL == "" {
+		return nil, notConfigured("public feeds need PUBLIC_SITE_URL")
+	}
+
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT a.id, a.slug, COALESCE(a.headline_selected, ''), COALESCE(a.meta_description, ''), COALESCE(a.excerpt, ''),
+			COALESCE(a.article_text, ''), COALESCE(t.name, ''), COALESCE(a.output_language, ''), a.completed_at,
+			COALESCE(a.updated_at, a.completed_at)
+		FROM articles a
+		LEFT JOIN topics t ON t.id = a.topic_id
+		WHERE a.status = 'completed' AND a.merged_into IS NULL
+			AND a.completed_at IS NOT NULL AND COALESCE(a.slug, '') <> ''
+		ORDER BY a.completed_at DESC, a.id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]publishedAnalysis, 0)
+	seen := make(map[string]struct{})
+	for rows.Next() {
+		var (
+			item     publishedAnalysis
+			slug     string
+			headline string
+			excerpt  string
+		)
+		if err := rows.Scan(&item.id, &slug, &headline, &item.summary, &excerpt, &item.articleText, &item.category, &item.language, &item.completedAt, &item.updatedAt); err != nil {
+			return nil, err
+		}
+		slug = strings.Trim(strings.TrimSpace(slug), "/")
+		if _, ok := seen[slug]; ok || slug == "" {
+			continue
+		}
+		seen[slug] = struct{}{}
+
+		item.url = cfg.PublicSiteURL + "/" + url.PathEscape(slug)
+		item.headline = buildAnalysisTitle(item.id, headline, "", "")
+		if strings.TrimSpace(item.summary) == "" {
+			item.summary = excerpt
+		}
+		item.summary = strings.TrimSpace(item.summary)
+		item.completedAt = item.completedAt.UTC()
+		item.updatedAt = item.updatedAt.UTC()
+		if item.updatedAt.Before(item.completedAt) {
+			item.updatedAt = item.completedAt
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// JSONFeed lists the newest published analyses as a JSON Feed. feedURL is
+// where the feed itself is served. A corrected analysis carries its
+// corrections under the article text.
+func (s *PublicFeedService) JSONFeed(ctx context.Context, feedURL string) (models.JSONFeed, error) {
+	cfg := config.Current()
+	items, err := s.published(ctx, cfg, publicFeedItems)
+	if err != nil {
+		return models.JSONFeed{}, err
+	}
+	ids := make([]int64, len(items))
+	for idx, item := range items {
+		ids[idx] = item.id
+	}
+	corrections, err := correctionsByArticle(ctx, s.store, ids)
+	if err != nil {
+		return models.JSONFeed{}, err
+	}
+
+	feed := models.JSONFeed{
+		Version:     jsonFeedVersion,
+		Title:       cfg.FeedTitle,
+		HomePageURL: cfg.PublicSiteURL + "/",
+		FeedURL:     feedURL,
+		Items:       make([]models.JSONFeedItem, 0, len(items)),
+	}
+	for _, item := range items {
+		entry := models.JSONFeedItem{
+			ID:            item.url,
+			URL:           item.url,
+			Title:         item.headline,
+			Summary:       item.summary,
+			ContentText:   item.articleText,
+			DatePublished: item.completedAt,
+			DateModified:  item.updatedAt,
+			Language:      item.language,
+		}
+		if block := correctionsText(corrections[item.id]); block != "" {
+			entry.ContentText = strings.TrimRight(entry.ContentText, "\n") + "\n\n" + block
+		}
+		if item.category != "" {
+			entry.Tags = []string{item.category}
+		}
+		feed.Items = append(feed.Items, entry)
+	}
+	return feed, nil
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          *rssSelf  `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Description string   `xml:"description,omitempty"`
+	Category    []string `xml:"category,omitempty"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	Value     string `xml:",chardata"`
+	Permalink bool   `xml:"isPermaLink,attr"`
+}
+
+// RSS lists the newest published analyses as an RSS 2.0 feed served at
+// feedURL.
+func (s *PublicFeedService) RSS(ctx context.Context, feedURL string) ([]byte, error) {
+	cfg := config.Current()
+	items, err := s.published(ctx, cfg, publicFeedItems)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := rssChannel{
+		Title:       cfg.FeedTitle,
+		Link:        cfg.PublicSiteURL + "/",
+		Description: "Latest analyses from " + cfg.FeedTitle,
+		Items:       make([]rssItem, 0, len(items)),
+	}
+	if feedURL != "" {
+		channel.Self = &rssSelf{Href: feedURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	for idx, item := range items {
+		if idx == 0 {
+			channel.LastBuildDate = item.completedAt.Format(time.RFC1123Z)
+		}
+		entry := rssItem{
+			Title:       item.headline,
+			Link:        item.url,
+			GUID:        rssGUID{Value: item.url, Permalink: true},
+			Description: item.summary,
+			PubDate:     item.completedAt.Format(time.RFC1123Z),
+		}
+		if item.category != "" {
+			entry.Category = []string{item.category}
+		}
+		channel.Items = append(channel.Items, entry)
+	}
+	return marshalXML(rssDocument{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel})
+}
+
+type sitemapDocument struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Sitemap lists every published analysis with when it last changed.
+func (s *PublicFeedService) Sitemap(ctx context.Context) ([]byte, error) {
+	cfg := config.Current()
+	items, err := s.published(ctx, cfg, maxSitemapEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	document := sitemapDocument{XMLNS: sitemapNamespace, URLs: make([]sitemapURL, 0, len(items))}
+	for _, item := range items {
+		document.URLs = append(document.URLs, sitemapURL{Loc: item.url, LastMod: item.updatedAt.Format(time.RFC3339)})
+	}
+	return marshalXML(document)
+}
+
+func marshalXML(document any) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const maxSimplifyAttempts = 2
+
+// readingLevelReaders describes the reader each simplify level writes for.
+var readingLevelReaders = map[string]string{
+	models.ReadingLevelVeryEasy: "a reader of about ten (US grade 5): sentences under 12 words and everyday words",
+	models.ReadingLevelEasy:     "a reader of about twelve (US grade 7): sentences under 15 words and plain words",
+	models.ReadingLevelStandard: "a general adult reader (US grade 9): sentences under 20 words",
+}
+
+// measureReadability scores text written in language, given by name.
+func measureReadability(text string, language string) models.Readability {
+	sentences := splitSentences(text)
+	words := make([]string, 0)
+	for _, sentence := range sentences {
+		words = append(words, readabilityWords(sentence)...)
+	}
+
+	metric := models.ReadabilityLIX
+	if language == "" || strings.EqualFold(language, englishLanguage.Name) {
+		metric = models.ReadabilityFlesch
+	}
+	result := models.Readability{Metric: metric}
+	if len(words) == 0 || len(sentences) == 0 {
+		result.Level = models.ReadingLevelStandard
+		return result
+	}
+
+	wordsPerSentence := float64(len(words)) / float64(len(sentences))
+	if metric == models.ReadabilityFlesch {
+		syllables := 0
+		for _, word := range words {
+			syllables += countSyllables(word)
+		}
+		syllablesPerWord := float64(syllables) / float64(len(words))
+		grade := roundTo2(0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59)
+		result.Score = roundTo2(206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord)
+		result.Grade = &grade
+		result.Level = fleschLevel(result.Score)
+		return result
+	}
+
+	long := 0
+	for _, word := range words {
+		if utf8.RuneCountInString(word) > 6 {
+			long++
+		}
+	}
+	result.Score = roundTo2(wordsPerSentence + 100*float64(long)/float64(len(words)))
+	result.Level = lixLevel(result.Score)
+	return result
+}
+
+func fleschLevel(score float64) string {
+	switch {
+	case score >= 80:
+		return models.ReadingLevelVeryEasy
+	case score >= 70:
+		return models.ReadingLevelEasy
+	case score >= 60:
+		return models.ReadingLevelStandard
+	case score >= 30:
+		return models.ReadingLevelDifficult
+	default:
+		return models.ReadingLevelVeryDifficult
+	}
+}
+
+func lixLevel(score float64) string {
+	switch {
+	case score < 30:
+		return models.ReadingLevelVeryEasy
+	case score < 40:
+		return models.ReadingLevelEasy
+	case score < 50:
+		return models.ReadingLevelStandard
+	case score < 60:
+		return models.ReadingLevelDifficult
+	default:
+		return models.ReadingLevelVeryDifficult
+	}
+}
+
+// readingLevelRank orders levels from easiest; unknown levels rank last.
+func readingLevelRank(level string) int {
+	for idx, candidate := range models.ReadingLevels {
+		if candidate == level {
+			return idx
+		}
+	}
+	return len(models.ReadingLevels)
+}
+
+// readabilityWords keeps the tokens with a letter in them, so figures and
+// stray punctuation don't count as words. Combining marks belong to the
+// word, as in Devanagari.
+func readabilityWords(sentence string) []string {
+	tokens := strings.FieldsFunc(sentence, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && r != '\'' && r != '’' && r != '-'
+	})
+	words := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if strings.ContainsFunc(token, unicode.IsLetter) {
+			words = append(words, token)
+		}
+	}
+	return words
+}
+
+// countSyllables estimates an English word's syllables from its vowel groups,
+// discounting a silent final e.
+func countSyllables(word string) int {
+	word = strings.ToLower(strings.TrimRight(word, "'’"))
+	count := 0
+	inVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !inVowel {
+			count++
+		}
+		inVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+// storeReadability scores an article's text and saves the result with it.
+func storeReadability(ctx context.Context, q repository.Querier, articleID int64, text string, language string) (models.Readability, error) {
+	readability := measureReadability(text, language)
+	var grade sql.NullFloat64
+	if readability.Grade != nil {
+		grade = sql.NullFloat64{Float64: *readability.Grade, Valid: true}
+	}
+	_, err := q.ExecContext(
+		ctx,
+		"UPDATE articles SET readability_metric = ?, readability_score = ?, readability_grade = ?, readability_level = ? WHERE id = ?",
+		readability.Metric,
+		readability.Score,
+		grade,
+		readability.Level,
+		articleID,
+	)
+	return readability, err
+}
+
+// rescoreReadability scores an article again after its text changes.
+func rescoreReadability(ctx context.Context, q repository.Querier, articleID int64) error {
+	var text, language string
+	err := q.QueryRowContext(ctx, "SELECT COALESCE(article_text, ''), COALESCE(output_language, '') FROM articles WHERE id = ?", articleID).Scan(&text, &language)
+	if err != nil {
+		return err
+	}
+	_, err = storeReadability(ctx, q, articleID, text, language)
+	return err
+}
+
+// SimplifyArticle rewrites an analysis's article for a reading level, trying
+// once more when the first rewrite still reads harder than the target. A
+// long-form article is rewritten section by section. An article already at
+// the target is left alone.
+func (s *FactService) SimplifyArticle(ctx context.Context, articleID int64, level string, requestedBy *int64) (models.SimplifyResult, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == "" {
+		level = models.ReadingLevelEasy
+	}
+	if !containsString(models.SimplifyLevels, level) {
+		return models.SimplifyResult{}, invalidInput("level must be very-easy, easy or standard")
+	}
+
+	var (
+		text        string
+		language    string
+		rawSections sql.NullString
+	)
+	err := s.store.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(article_text, ''), COALESCE(output_language, ''), article_sections FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&text, &language, &rawSections)
+	if err != nil {
+		return models.SimplifyResult{}, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return models.SimplifyResult{}, invalidInput("article text is required to simplify")
+	}
+	if language == "" {
+		language = englishLanguage.Name
+	}
+
+	result := models.SimplifyResult{ArticleID: articleID, Target: level, Article: text}
+	result.Before = measureReadability(text, language)
+	result.After = result.Before
+	if readingLevelRank(result.Before.Level) <= readingLevelRank(level) {
+		result.Reached = true
+		return result, nil
+	}
+
+	facts, err := listTexts(ctx, s.store, "SELECT COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY position ASC, id ASC", articleID)
+	if err != nil {
+		return models.SimplifyResult{}, err
+	}
+	sections := decodeArticleSections(articleID, rawSections, text)
+
+	if err := s.applyRuntimeAISettings(ctx); err != nil {
+		return models.SimplifyResult{}, err
+	}
+	runID := newLLMRunID()
+	ctx = withLLMRunID(ctx, runID)
+	if activePrompts, err := loadPromptSet(ctx, s.store); err != nil {
+		log.Printf("[prompts] failed to load active prompts, using built-in defaults: %v", err)
+	} else {
+		ctx = withPromptSet(ctx, activePrompts)
+	}
+	defer func() {
+		if err := s.llmCalls.AttachArticle(ctx, runID, articleID); err != nil {
+			log.Printf("[llm-calls] failed to link run %s to article %d: %v", runID, articleID, err)
+		}
+	}()
+
+	reader := readingLevelReaders[level]
+	for attempt := 0; attempt < maxSimplifyAttempts; attempt++ {
+		if len(sections) > 0 {
+			for idx := range sections {
+				body, err := s.ai.SimplifyArticle(ctx, sections[idx].Body, facts, reader, language)
+				if err != nil {
+					return models.SimplifyResult{}, err
+				}
+				sections[idx].Body = strings.TrimSpace(body)
+			}
+			text = renderArticleSections(sections)
+		} else {
+			if text, err = s.ai.SimplifyArticle(ctx, text, facts, reader, language); err != nil {
+				return models.SimplifyResult{}, err
+			}
+		}
+		if readingLevelRank(measureReadability(text, language).Level) <= readingLevelRank(level) {
+			break
+		}
+	}
+	text, sections, _, _ = s.applyStyleGuide(ctx, text, sections, nil, nil)
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE articles SET article_text = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ?", text, articleID); err != nil {
+			return err
+		}
+		if len(sections) > 0 {
+			if err := storeArticleSections(ctx, tx, articleID, sections); err != nil {
+				return err
+			}
+		}
+		result.After, err = storeReadability(ctx, tx, articleID, text, language)
+		return err
+	})
+	if err != nil {
+		return models.SimplifyResult{}, err
+	}
+
+	if _, err := EnqueueGroundingCheck(ctx, s.jobs, articleID, requestedBy); err != nil {
+		log.Printf("[grounding] failed to queue article %d: %v", articleID, err)
+	}
+	if config.Current().SensitivityCheck {
+		if _, err := EnqueueSensitivityCheck(ctx, s.jobs, articleID, requestedBy); err != nil {
+			log.Printf("[sensitivity] failed to queue article %d: %v", articleID, err)
+		}
+	}
+
+	result.Article = text
+	result.Reached = readingLevelRank(result.After.Level) <= readingLevelRank(level)
+	return result, nil
+}
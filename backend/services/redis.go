@@ -0,0 +1,328 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+)
+
+const (
+	redisKeyPrefix   = "nanoheads:"
+	redisDialTimeout = 3 * time.Second
+	// A command gives up after this long, so a slow Redis costs a fallback
+	// rather than stalling the request.
+	redisCommandTimeout = 3 * time.Second
+	maxIdleRedisConns   = 8
+)
+
+// errRedisNil is a nil reply: a missing key, or a blocking pop that timed out.
+var errRedisNil = errors.New("redis: nil")
+
+// redisError is an error reply. The connection is still usable after one.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisClient speaks as much RESP as the cache, the fetch limiter and the
+// job queue need, over a small pool of connections.
+type redisClient struct {
+	addr     string
+	tls      *tls.Config
+	username string
+	password string
+	database int
+	idle     chan *redisConn
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+var (
+	sharedRedisOnce sync.Once
+	sharedRedis     *redisClient
+)
+
+// redisBackend returns the client for REDIS_URL, or nil when it is unset and
+// callers should use their database or in-memory implementation.
+func redisBackend() *redisClient {
+	sharedRedisOnce.Do(func() {
+		raw := config.Current().RedisURL
+		if raw == "" {
+			return
+		}
+		client, err := newRedisClient(raw)
+		if err != nil {
+			log.Printf("[redis] ignoring REDIS_URL: %v", err)
+			return
+		}
+		sharedRedis = client
+	})
+	return sharedRedis
+}
+
+func newRedisClient(raw string) (*redisClient, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	client := &redisClient{idle: make(chan *redisConn, maxIdleRedisConns)}
+	switch parsed.Scheme {
+	case "redis":
+	case "rediss":
+		client.tls = &tls.Config{ServerName: parsed.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+	}
+	client.addr = parsed.Host
+	if parsed.Port() == "" {
+		client.addr = net.JoinHostPort(parsed.Hostname(), "6379")
+	}
+	if parsed.User != nil {
+		if password, ok := parsed.User.Password(); ok {
+			client.username, client.password = parsed.User.Username(), password
+		} else {
+			client.password = parsed.User.Username()
+		}
+	}
+	if path := strings.Trim(parsed.Path, "/"); path != "" {
+		client.database, err = strconv.Atoi(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database %q", path)
+		}
+	}
+	return client, nil
+}
+
+// do runs one command and returns its reply: a string, an int64, a []any or
+// nil. A nil reply comes back as errRedisNil.
+func (c *redisClient) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.roundTrip(ctx, time.Now().Add(redisCommandTimeout), args)
+	c.put(conn, err)
+	return reply, err
+}
+
+// blockingPop waits up to timeout for an element of one of keys, returning
+// the key it came from, or errRedisNil when none arrived. Cancelling ctx
+// abandons the wait.
+func (c *redisClient) blockingPop(ctx context.Context, timeout time.Duration, keys ...string) (string, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"BRPOP"}, keys...)
+	args = append(args, strconv.FormatFloat(timeout.Seconds(), 'f', 3, 64))
+	reply, err := conn.roundTrip(ctx, time.Now().Add(timeout+redisCommandTimeout), args)
+	c.put(conn, err)
+	if err != nil {
+		return "", err
+	}
+	popped, ok := reply.([]any)
+	if !ok || len(popped) != 2 {
+		return "", fmt.Errorf("redis: unexpected BRPOP reply %T", reply)
+	}
+	key, _ := popped[0].(string)
+	return key, nil
+}
+
+func (c *redisClient) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	raw, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.tls != nil {
+		raw = tls.Client(raw, c.tls)
+	}
+	conn := &redisConn{conn: raw, reader: bufio.NewReader(raw)}
+
+	deadline := time.Now().Add(redisCommandTimeout)
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := conn.roundTrip(ctx, deadline, auth); err != nil {
+			raw.Close()
+			return nil, err
+		}
+	}
+	if c.database != 0 {
+		if _, err := conn.roundTrip(ctx, deadline, []string{"SELECT", strconv.Itoa(c.database)}); err != nil {
+			raw.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// put returns conn to the pool unless err left it in an unknown state.
+func (c *redisClient) put(conn *redisConn, err error) {
+	var replyErr redisError
+	if err != nil && !errors.Is(err, errRedisNil) && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		return
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (c *redisConn) roundTrip(ctx context.Context, deadline time.Time, args []string) (any, error) {
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var command strings.Builder
+	command.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		command.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if _, err := io.WriteString(c.conn, command.String()); err != nil {
+		return nil, err
+	}
+	reply, err := c.readReply()
+	if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+		return nil, ctxErr
+	}
+	return reply, err
+}
+
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]any, count)
+		for idx := range items {
+			item, err := c.readReply()
+			var replyErr redisError
+			switch {
+			case errors.As(err, &replyErr):
+				item = replyErr
+			case err != nil && !errors.Is(err, errRedisNil):
+				return nil, err
+			}
+			items[idx] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", previewForLog(line))
+	}
+}
+
+// scan lists the keys matching pattern. It walks the whole keyspace, so it
+// is for admin reports, not request paths.
+func (c *redisClient) scan(ctx context.Context, pattern string) ([]string, error) {
+	keys := make([]string, 0)
+	cursor := "0"
+	for {
+		reply, err := c.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", "500")
+		if err != nil {
+			return nil, err
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("redis: unexpected SCAN reply %T", reply)
+		}
+		cursor, _ = page[0].(string)
+		found, _ := page[1].([]any)
+		for _, key := range found {
+			if name, ok := key.(string); ok {
+				keys = append(keys, name)
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// reserveSlotScript books the next free turn on a key, spacing ms after the
+// one before, and answers how many ms away it is. Redis's clock is used so
+// instances with skewed clocks still agree.
+const reserveSlotScript = `
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+local slot = tonumber(redis.call('GET', KEYS[1]) or '0')
+if slot < now then slot = now end
+local spacing = tonumber(ARGV[1])
+redis.call('SET', KEYS[1], slot + spacing, 'PX', slot + spacing - now + 1000)
+return slot - now
+`
+
+// reserveSlot is a rate limit shared by every instance: callers with the
+// same key go at most once per spacing, each waiting the returned delay.
+func (c *redisClient) reserveSlot(ctx context.Context, key string, spacing time.Duration) (time.Duration, error) {
+	reply, err := c.do(ctx, "EVAL", reserveSlotScript, "1", redisKeyPrefix+key, strconv.FormatInt(spacing.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	delay, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected reply %T", reply)
+	}
+	return time.Duration(delay) * time.Millisecond, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"nanoheads/config"
+)
+
+// How long a rendered page gets after load for scripts to fill it in.
+const renderSettle = 1500 * time.Millisecond
+
+// renderSlots caps concurrent browser renders; each one holds a tab and,
+// without RenderBrowserURL, a Chrome process.
+var renderSlots = make(chan struct{}, 2)
+
+var errRenderingOff = invalidInput("render must be false: rendering is not enabled on this server")
+
+// renderPage loads target in headless Chrome and returns the document as
+// scripts left it. Every request the page makes is checked the way direct
+// fetches are: documents against CheckSourceURL, everything against the
+// blocked networks. Images, media and fonts are not loaded.
+func renderPage(ctx context.Context, cfg config.Config, target *url.URL) (string, error) {
+	select {
+	case renderSlots <- struct{}{}:
+		defer func() { <-renderSlots }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.RenderTimeout.Duration)
+	defer cancel()
+
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if cfg.RenderBrowserURL != "" {
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(ctx, cfg.RenderBrowserURL)
+	} else {
+		options := chromedp.DefaultExecAllocatorOptions[:]
+		if cfg.ChromePath != "" {
+			options = append(options, chromedp.ExecPath(cfg.ChromePath))
+		}
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(ctx, options...)
+	}
+	defer cancelAlloc()
+
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+
+	chromedp.ListenTarget(browserCtx, func(event any) {
+		paused, ok := event.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		go func() {
+			executor := cdp.WithExecutor(browserCtx, chromedp.FromContext(browserCtx).Target)
+			if err := renderRequestAllowed(browserCtx, cfg, paused); err != nil {
+				log.Printf("[render] blocked %s for %s: %v", previewForLog(paused.Request.URL), target.Redacted(), err)
+				_ = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).Do(executor)
+				return
+			}
+			_ = fetch.ContinueRequest(paused.RequestID).Do(executor)
+		}()
+	})
+
+	var document string
+	err := chromedp.Run(browserCtx,
+		fetch.Enable(),
+		emulation.SetUserAgentOverride(cfg.FetchUserAgent),
+		chromedp.Navigate(target.String()),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.Sleep(renderSettle),
+		chromedp.OuterHTML("html", &document, chromedp.ByQuery),
+	)
+	if err != nil {
+		return "", fmt.Errorf("render page: %w", err)
+	}
+	return document, nil
+}
+
+func renderRequestAllowed(ctx context.Context, cfg config.Config, paused *fetch.EventRequestPaused) error {
+	switch paused.ResourceType {
+	case network.ResourceTypeImage, network.ResourceTypeMedia, network.ResourceTypeFont:
+		return errors.New("resource type is not loaded")
+	}
+
+	target, err := url.Parse(paused.Request.URL)
+	if err != nil {
+		return err
+	}
+	switch target.Scheme {
+	case "data", "blob":
+		return nil
+	case "http", "https":
+	default:
+		return fmt.Errorf("scheme %s is not loaded", target.Scheme)
+	}
+	if paused.ResourceType == network.ResourceTypeDocument {
+		if _, err := CheckSourceURL(target.String()); err != nil {
+			return err
+		}
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", strings.Trim(target.Hostname(), "[]"))
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !cfg.FetchAddressAllowed(addr) {
+			return fmt.Errorf("it resolves to blocked address %s", addr.Unmap())
+		}
+	}
+	return nil
+}
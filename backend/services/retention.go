@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	analysisStatusArchived = "archived"
+
+	// Drafts are archived or deleted this many to a transaction.
+	retentionBatch = 100
+	// The preview lists at most this many of the drafts due.
+	retentionPreviewItems = 200
+)
+
+type RetentionService struct {
+	store *repository.Store
+}
+
+func NewRetentionService(database *sql.DB) *RetentionService {
+	return &RetentionService{store: repository.New(database)}
+}
+
+// retentionCutoff is when a draft must have last changed before to be due,
+// or false when retention is off.
+func retentionCutoff(cfg config.Config, now time.Time) (time.Time, bool) {
+	if !cfg.RetentionEnabled() {
+		return time.Time{}, false
+	}
+	return now.UTC().AddDate(0, 0, -cfg.RetentionDays), true
+}
+
+const retentionDue = "a.status = 'draft' AND COALESCE(a.updated_at, a.created_at) < ?"
+
+// Preview reports which drafts the next run would archive or delete, oldest
+// first.
+func (s *RetentionService) Preview(ctx context.Context) (models.RetentionPreview, error) {
+	cfg := config.Current()
+	preview := models.RetentionPreview{
+		Enabled:  cfg.RetentionEnabled(),
+		Action:   cfg.RetentionAction,
+		Days:     cfg.RetentionDays,
+		Analyses: make([]models.RetentionCandidate, 0),
+	}
+	cutoff, ok := retentionCutoff(cfg, time.Now())
+	if !ok {
+		return preview, nil
+	}
+	preview.Cutoff = &cutoff
+
+	if err := s.store.QueryRowContext(ctx, "SELECT COUNT(*) FROM articles a WHERE "+retentionDue, cutoff).Scan(&preview.Total); err != nil {
+		return preview, err
+	}
+	candidates, err := s.due(ctx, cutoff, retentionPreviewItems)
+	if err != nil {
+		return preview, err
+	}
+	preview.Analyses = candidates
+	return preview, nil
+}
+
+func (s *RetentionService) due(ctx context.Context, cutoff time.Time, limit int) ([]models.RetentionCandidate, error) {
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT a.id, COALESCE(a.headline_selected, ''), COALESCE(a.source_url, ''), COALESCE(t.name, ''),
+			a.created_at, COALESCE(a.updated_at, a.created_at)
+		FROM articles a
+		LEFT JOIN topics t ON t.id = a.topic_id
+		WHERE `+retentionDue+`
+		ORDER BY COALESCE(a.updated_at, a.created_at) ASC, a.id ASC
+		LIMIT ?`, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := make([]models.RetentionCandidate, 0)
+	for rows.Next() {
+		var (
+			candidate models.RetentionCandidate
+			headline  string
+			sourceURL string
+		)
+		if err := rows.Scan(&candidate.ID, &headline, &sourceURL, &candidate.Category, &candidate.CreatedAt, &candidate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		// Titles never fall back to the source text here, which is what
+		// the run is about to remove.
+		candidate.Title = buildAnalysisTitle(candidate.ID, headline, sourceURL, "")
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// Run archives or deletes every draft last changed more than RetentionDays
+// ago. Either way the source text, fetched pages and uploaded images go, and
+// so do the prompts that quoted them; stored files no other analysis uses
+// are removed from disk.
+func (s *RetentionService) Run(ctx context.Context, report func(int, int)) (models.RetentionResult, error) {
+	cfg := config.Current()
+	result := models.RetentionResult{Action: cfg.RetentionAction}
+	cutoff, ok := retentionCutoff(cfg, time.Now())
+	if !ok {
+		return result, notConfigured("RETENTION_DAYS is required for draft retention")
+	}
+
+	var total int64
+	if err := s.store.QueryRowContext(ctx, "SELECT COUNT(*) FROM articles a WHERE "+retentionDue, cutoff).Scan(&total); err != nil {
+		return result, err
+	}
+	report(0, int(total))
+
+	done := 0
+	for {
+		candidates, err := s.due(ctx, cutoff, retentionBatch)
+		if err != nil {
+			return result, err
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		ids := make([]any, len(candidates))
+		for idx, candidate := range candidates {
+			ids[idx] = candidate.ID
+		}
+
+		removed, err := s.retire(ctx, cfg.RetentionAction, ids)
+		if err != nil {
+			return result, err
+		}
+		if cfg.RetentionAction == models.RetentionDelete {
+			result.Deleted += len(ids)
+		} else {
+			result.Archived += len(ids)
+		}
+		result.FilesRemoved += removeUnusedFiles(ctx, s.store, removed)
+
+		done += len(ids)
+		report(done, max(done, int(total)))
+		if len(candidates) < retentionBatch {
+			break
+		}
+	}
+	log.Printf("[retention] %s: %d drafts last changed before %s", cfg.RetentionAction, done, cutoff.Format(time.DateOnly))
+	return result, nil
+}
+
+// retire archives or deletes one batch of drafts and returns the storage
+// keys of the files they referenced.
+func (s *RetentionService) retire(ctx context.Context, action string, ids []any) ([]string, error) {
+	in := "(" + repository.Placeholders(len(ids)) + ")"
+	keys := make([]string, 0)
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		rows, err := tx.QueryContext(
+			ctx,
+			"SELECT storage_key FROM article_images WHERE article_id IN "+in+" UNION SELECT storage_key FROM source_snapshots WHERE article_id IN "+in,
+			append(append([]any{}, ids...), ids...)...,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return err
+			}
+			keys = append(keys, key)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// llm_calls outlive a deleted analysis, so their prompts are
+		// scrubbed either way.
+		statements := []string{"UPDATE llm_calls SET user_prompt = NULL WHERE article_id IN " + in}
+		if action == models.RetentionDelete {
+			statements = append(statements, "DELETE FROM articles WHERE id IN "+in)
+		} else {
+			statements = append(statements,
+				"DELETE FROM article_images WHERE article_id IN "+in,
+				"DELETE FROM source_snapshots WHERE article_id IN "+in,
+				"UPDATE article_sources SET raw_text = NULL WHERE article_id IN "+in,
+				"UPDATE articles SET status = '"+analysisStatusArchived+"', archived_at = CURRENT_TIMESTAMP, raw_text = NULL WHERE id IN "+in,
+			)
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement, ids...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// removeUnusedFiles deletes the stored files of keys that no image or
+// snapshot row points at any more. Files are content-addressed, so another
+// analysis may still share one. Failures are logged and skipped; they leave
+// a stray file, not a broken analysis.
+func removeUnusedFiles(ctx context.Context, store *repository.Store, keys []string) int {
+	removed := 0
+	for _, key := range keys {
+		if key == "" || strings.Contains(key, "..") {
+			continue
+		}
+		var used int
+		err := store.QueryRowContext(
+			ctx,
+			"SELECT (SELECT COUNT(*) FROM article_images WHERE storage_key = ?) + (SELECT COUNT(*) FROM source_snapshots WHERE storage_key = ?)",
+			key,
+			key,
+		).Scan(&used)
+		if err != nil {
+			log.Printf("[retention] failed to check whether %s is still used: %v", key, err)
+			continue
+		}
+		if used > 0 {
+			continue
+		}
+		path := filepath.Join(config.Current().UploadDir, filepath.FromSlash(key))
+		if err := os.Remove(path); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("[retention] failed to remove %s: %v", path, err)
+			}
+			continue
+		}
+		removed++
+	}
+	return removed
+}
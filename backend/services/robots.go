@@ -0,0 +1,127 @@
+package services
+
+import (
+	"bufio"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsRules are the robots.txt rules that apply to one agent.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	disallowed bool // robots.txt could not be read, so nothing may be fetched
+}
+
+// parseRobots reads the groups of a robots.txt that name agent, falling back
+// to the "*" group, as RFC 9309 describes. Groups naming the same agent are
+// merged.
+func parseRobots(body string, agent string) robotsRules {
+	var named, wildcard robotsRules
+	var foundNamed bool
+
+	var groupAgents []string
+	inRules := false
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+			continue
+		}
+		if len(groupAgents) == 0 {
+			continue
+		}
+		inRules = true
+
+		targets := make([]*robotsRules, 0, 2)
+		if slices.Contains(groupAgents, agent) {
+			targets = append(targets, &named)
+			foundNamed = true
+		}
+		if slices.Contains(groupAgents, "*") {
+			targets = append(targets, &wildcard)
+		}
+		for _, target := range targets {
+			switch key {
+			case "allow", "disallow":
+				if value != "" {
+					target.rules = append(target.rules, robotsRule{pattern: value, allow: key == "allow"})
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					target.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if foundNamed {
+		return named
+	}
+	return wildcard
+}
+
+// allows applies the longest matching rule to path; on a tie Allow wins.
+// With no match the path may be fetched.
+func (r robotsRules) allows(path string) bool {
+	if r.disallowed {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if length := len(rule.pattern); length > longest || (length == longest && rule.allow) {
+			allowed, longest = rule.allow, length
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a rule against a path: a prefix match where "*"
+// stands for any run of characters and a trailing "$" anchors the end.
+func robotsMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for idx, part := range parts[1:] {
+		if anchored && idx == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		at := strings.Index(rest, part)
+		if at < 0 {
+			return false
+		}
+		rest = rest[at+len(part):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const maxViewNameLength = 100
+
+// SavedViewService stores named analysis list filters. Views are private to
+// the user who saved them; other users' views read as not found.
+type SavedViewService struct {
+	store *repository.Store
+}
+
+func NewSavedViewService(database *sql.DB) *SavedViewService {
+	return &SavedViewService{
+		store: repository.New(database),
+	}
+}
+
+func (s *SavedViewService) List(ctx context.Context, userID *int64) ([]models.SavedView, error) {
+	views := make([]models.SavedView, 0)
+	if userID == nil {
+		return views, nil
+	}
+
+	rows, err := s.store.QueryContext(ctx, savedViewSelect+" WHERE user_id = ? ORDER BY name ASC", *userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		view, err := scanSavedView(rows)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+func (s *SavedViewService) Get(ctx context.Context, viewID int64, userID *int64) (models.SavedView, error) {
+	if userID == nil {
+		return models.SavedView{}, sql.ErrNoRows
+	}
+	return scanSavedView(s.store.QueryRowContext(ctx, savedViewSelect+" WHERE id = ? AND user_id = ?", viewID, *userID))
+}
+
+func (s *SavedViewService) Create(ctx context.Context, name string, filters models.ViewFilters, userID *int64) (models.SavedView, error) {
+	if userID == nil {
+		return models.SavedView{}, invalidInput("a signed-in user is required to save views")
+	}
+	name, encoded, err := s.prepare(ctx, 0, name, filters, *userID)
+	if err != nil {
+		return models.SavedView{}, err
+	}
+
+	id, err := s.store.Insert(ctx, "INSERT INTO saved_views (user_id, name, filters) VALUES (?, ?, ?)", *userID, name, encoded)
+	if err != nil {
+		return models.SavedView{}, err
+	}
+	return s.Get(ctx, id, userID)
+}
+
+// Update replaces a view's name and filters.
+func (s *SavedViewService) Update(ctx context.Context, viewID int64, name string, filters models.ViewFilters, userID *int64) (models.SavedView, error) {
+	if _, err := s.Get(ctx, viewID, userID); err != nil {
+		return models.SavedView{}, err
+	}
+	name, encoded, err := s.prepare(ctx, viewID, name, filters, *userID)
+	if err != nil {
+		return models.SavedView{}, err
+	}
+
+	_, err = s.store.ExecContext(
+		ctx,
+		"UPDATE saved_views SET name = ?, filters = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		name,
+		encoded,
+		viewID,
+	)
+	if err != nil {
+		return models.SavedView{}, err
+	}
+	return s.Get(ctx, viewID, userID)
+}
+
+func (s *SavedViewService) Delete(ctx context.Context, viewID int64, userID *int64) error {
+	if userID == nil {
+		return sql.ErrNoRows
+	}
+	result, err := s.store.ExecContext(ctx, "DELETE FROM saved_views WHERE id = ? AND user_id = ?", viewID, *userID)
+	if err != nil {
+		return err
+	}
+	return ensureRowsAffected(result)
+}
+
+// prepare validates a view about to be saved and encodes its filters. viewID
+// is the view being updated, or 0 for a new one.
+func (s *SavedViewService) prepare(ctx context.Context, viewID int64, name string, filters models.ViewFilters, userID int64) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", invalidInput("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxViewNameLength {
+		return "", "", invalidInput("name must be at most 100 characters")
+	}
+
+	filters, err := normalizeViewFilters(filters)
+	if err != nil {
+		return "", "", err
+	}
+	encoded, err := json.Marshal(filters)
+	if err != nil {
+		return "", "", err
+	}
+
+	var existing int64
+	err = s.store.QueryRowContext(ctx, "SELECT id FROM saved_views WHERE user_id = ? AND LOWER(name) = LOWER(?)", userID, name).Scan(&existing)
+	switch {
+	case err == nil && existing != viewID:
+		return "", "", invalidInput("view name must be unique")
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return "", "", err
+	}
+	return name, string(encoded), nil
+}
+
+func normalizeViewFilters(filters models.ViewFilters) (models.ViewFilters, error) {
+	filters.AssignedTo = strings.ToLower(strings.TrimSpace(filters.AssignedTo))
+	if filters.AssignedTo != "none" && !validUserFilter(filters.AssignedTo) {
+		return models.ViewFilters{}, invalidInput("assignedTo must be me, none, or a user id")
+	}
+	filters.CreatedBy = strings.ToLower(strings.TrimSpace(filters.CreatedBy))
+	if !validUserFilter(filters.CreatedBy) {
+		return models.ViewFilters{}, invalidInput("createdBy must be me or a user id")
+	}
+
+	filters.Status = strings.TrimSpace(filters.Status)
+	if filters.Status != "" {
+		status, err := normalizeStatusFilter(filters.Status)
+		if err != nil {
+			return models.ViewFilters{}, err
+		}
+		filters.Status = status
+	}
+	filters.Category = truncateRunes(strings.TrimSpace(filters.Category), 255)
+
+	if filters.Days < 0 || filters.Days > maxAnalyticsDays {
+		return models.ViewFilters{}, invalidInput("days must be between 1 and 366")
+	}
+	return filters, nil
+}
+
+func validUserFilter(value string) bool {
+	if value == "" || value == "me" {
+		return true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && id > 0
+}
+
+const savedViewSelect = "SELECT id, name, filters, COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM saved_views"
+
+func scanSavedView(row rowScanner) (models.SavedView, error) {
+	var (
+		view    models.SavedView
+		filters string
+	)
+	if err := row.Scan(&view.ID, &view.Name, &filters, &view.CreatedAt, &view.UpdatedAt); err != nil {
+		return models.SavedView{}, err
+	}
+	if err := json.Unmarshal([]byte(filters), &view.Filters); err != nil {
+		return models.SavedView{}, err
+	}
+	return view, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"nanoheads/repository"
+)
+
+// leaseHolder names this process in scheduler_leases.
+var leaseHolder = newLeaseHolder()
+
+func newLeaseHolder() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// acquireLease takes or renews the lease on task until ttl from now. It
+// fails, without error, while another process holds an unexpired lease.
+func acquireLease(ctx context.Context, store *repository.Store, task string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := store.ExecContext(
+		ctx,
+		// acquired_at is set first: MySQL evaluates later assignments with the
+		// new holder already in place.
+		"UPDATE scheduler_leases SET acquired_at = CASE WHEN holder = ? THEN acquired_at ELSE CURRENT_TIMESTAMP END, holder = ?, expires_at = ? WHERE task_name = ? AND (holder = ? OR expires_at < ?)",
+		leaseHolder,
+		leaseHolder,
+		now.Add(ttl),
+		task,
+		leaseHolder,
+		now,
+	)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err == nil, err
+	}
+
+	_, err = store.ExecContext(ctx, "INSERT INTO scheduler_leases (task_name, holder, expires_at) VALUES (?, ?, ?)", task, leaseHolder, now.Add(ttl))
+	if err == nil {
+		return true, nil
+	}
+	// Losing the race to insert the row shows up as a key violation; the
+	// row being there now tells it apart from a real failure.
+	var holder string
+	if lookupErr := store.QueryRowContext(ctx, "SELECT holder FROM scheduler_leases WHERE task_name = ?", task).Scan(&holder); lookupErr == nil {
+		return holder == leaseHolder, nil
+	} else if !errors.Is(lookupErr, sql.ErrNoRows) {
+		return false, lookupErr
+	}
+	return false, err
+}
+
+// releaseLease gives up task so another process can take it at once rather
+// than after the lease expires.
+func releaseLease(ctx context.Context, store *repository.Store, task string) error {
+	_, err := store.ExecContext(ctx, "DELETE FROM scheduler_leases WHERE task_name = ? AND holder = ?", task, leaseHolder)
+	return err
+}
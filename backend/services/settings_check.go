@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+)
+
+const (
+	stepSettingsTest    = "settings-test"
+	settingsTestTimeout = 30 * time.Second
+	maxSettingsReply    = 200
+)
+
+// TestSettings sends a tiny completion to providerKey and modelKey, or to the
+// selected model when both are empty, and reports how it went. It goes
+// straight to the provider: no cache, and no failover that would hide a
+// failing model.
+func (s *AdminService) TestSettings(ctx context.Context, providerKey string, modelKey string) (models.SettingsTest, error) {
+	cleanProvider := strings.ToLower(strings.TrimSpace(providerKey))
+	cleanModel := strings.TrimSpace(modelKey)
+	if (cleanProvider == "") != (cleanModel == "") {
+		return models.SettingsTest{}, invalidInput("provider and model are required together")
+	}
+
+	var maxTokens int
+	if cleanProvider == "" {
+		active, ok, err := resolveActiveModel(ctx, s.store)
+		if err != nil {
+			return models.SettingsTest{}, err
+		}
+		if !ok {
+			return models.SettingsTest{}, invalidInput("no model is selected yet; provider and model are required")
+		}
+		cleanProvider, cleanModel, maxTokens = active.provider, active.model, active.maxTokens
+	} else {
+		selection, err := s.selectableModel(ctx, cleanProvider, cleanModel)
+		if err != nil {
+			return models.SettingsTest{}, err
+		}
+		maxTokens = selection.maxTokens
+	}
+
+	if err := loadProviderKeys(ctx, s.store); err != nil {
+		return models.SettingsTest{}, err
+	}
+	ai := NewOpenAIService()
+	if err := ai.ApplySettings(cleanProvider, cleanModel, maxTokens); err != nil {
+		return models.SettingsTest{}, err
+	}
+
+	result := models.SettingsTest{Provider: cleanProvider, Model: cleanModel}
+	if ai.apiKey == "" {
+		result.ErrorClass = "auth"
+		result.Error = fmt.Sprintf("no API key is configured for %s", cleanProvider)
+		return result, nil
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, settingsTestTimeout)
+	defer cancel()
+	started := time.Now()
+	reply, err := ai.sendCompletion(callCtx, stepSettingsTest, "You are a connectivity check.", "Reply with the single word OK.", 0, 8, false)
+	result.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		result.ErrorClass = classifyLLMError(err)
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Success = true
+	result.Reply = truncateRunes(reply, maxSettingsReply)
+	return result, nil
+}
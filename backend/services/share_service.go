@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	defaultShareHours = 72
+	maxShareHours     = 30 * 24
+)
+
+// ErrShareUnavailable is returned for share links that are unknown, expired
+// or revoked; they are told apart only in the logs.
+var ErrShareUnavailable = WithCode(models.ErrorCodeNotFound, errors.New("this review link has expired or was withdrawn"))
+
+//go:embed templates/review.html
+var reviewHTML string
+
+var reviewTemplate = template.Must(template.New("review").Parse(reviewHTML))
+
+type ShareService struct {
+	store *repository.Store
+	admin *AdminService
+}
+
+func NewShareService(database *sql.DB) *ShareService {
+	return &ShareService{
+		store: repository.New(database),
+		admin: NewAdminService(database),
+	}
+}
+
+// Create makes a share link for an analysis that lasts hours, 72 when 0.
+// It returns the share and the token the link is made from, which is not
+// stored and can't be shown again.
+func (s *ShareService) Create(ctx context.Context, articleID int64, hours int, note string, createdBy *int64) (models.AnalysisShare, string, error) {
+	if hours == 0 {
+		hours = defaultShareHours
+	}
+	if hours < 1 || hours > maxShareHours {
+		return models.AnalysisShare{}, "", invalidInputf("expiresInHours must be between 1 and %d", maxShareHours)
+	}
+	var exists int
+	if err := s.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.AnalysisShare{}, "", err
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return models.AnalysisShare{}, "", fmt.Errorf("generate share token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	expiresAt := time.Now().UTC().Add(time.Duration(hours) * time.Hour).Truncate(time.Second)
+
+	id, err := s.store.Insert(
+		ctx,
+		"INSERT INTO analysis_shares (article_id, token_hash, note, created_by, expires_at) VALUES (?, ?, ?, ?, ?)",
+		articleID,
+		hashAPIKey(token),
+		nullString(truncateRunes(strings.TrimSpace(note), 500)),
+		createdBy,
+		expiresAt,
+	)
+	if err != nil {
+		return models.AnalysisShare{}, "", err
+	}
+	share, err := s.get(ctx, id)
+	return share, token, err
+}
+
+// List returns an analysis's share links, newest first, including those
+// that have expired or were revoked.
+func (s *ShareService) List(ctx context.Context, articleID int64) ([]models.AnalysisShare, error) {
+	rows, err := s.store.QueryContext(ctx, shareSelect+" WHERE article_id = ? ORDER BY created_at DESC, id DESC", articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]models.AnalysisShare, 0)
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// Revoke stops a share link working. Revoking it again is not an error.
+func (s *ShareService) Revoke(ctx context.Context, shareID int64) (models.AnalysisShare, error) {
+	if _, err := s.get(ctx, shareID); err != nil {
+		return models.AnalysisShare{}, err
+	}
+	if _, err := s.store.ExecContext(ctx, "UPDATE analysis_shares SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL", shareID); err != nil {
+		return models.AnalysisShare{}, err
+	}
+	return s.get(ctx, shareID)
+}
+
+// Open returns what a share link shows, counting the view.
+func (s *ShareService) Open(ctx context.Context, token string) (models.SharedAnalysis, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return models.SharedAnalysis{}, ErrShareUnavailable
+	}
+
+	var (
+		shareID   int64
+		articleID int64
+		note      string
+		expiresAt time.Time
+		revokedAt sql.NullTime
+	)
+	err := s.store.QueryRowContext(
+		ctx,
+		"SELECT id, article_id, COALESCE(note, ''), expires_at, revoked_at FROM analysis_shares WHERE token_hash = ?",
+		hashAPIKey(token),
+	).Scan(&shareID, &articleID, &note, &expiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SharedAnalysis{}, ErrShareUnavailable
+	}
+	if err != nil {
+		return models.SharedAnalysis{}, err
+	}
+	switch {
+	case revokedAt.Valid:
+		log.Printf("[shares] share %d of analysis %d was opened after it was revoked", shareID, articleID)
+		return models.SharedAnalysis{}, ErrShareUnavailable
+	case !time.Now().Before(expiresAt):
+		log.Printf("[shares] share %d of analysis %d was opened after it expired", shareID, articleID)
+		return models.SharedAnalysis{}, ErrShareUnavailable
+	}
+
+	detail, err := s.admin.GetAnalysisDetail(ctx, articleID, models.Visibility{})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SharedAnalysis{}, ErrShareUnavailable
+	}
+	if err != nil {
+		return models.SharedAnalysis{}, err
+	}
+	if _, err := s.store.ExecContext(ctx, "UPDATE analysis_shares SET view_count = view_count + 1, last_viewed_at = CURRENT_TIMESTAMP WHERE id = ?", shareID); err != nil {
+		log.Printf("[shares] failed to count a view of share %d: %v", shareID, err)
+	}
+
+	shared := models.SharedAnalysis{
+		Headline:        buildAnalysisTitle(articleID, detail.HeadlineSelected, "", ""),
+		Strapline:       detail.StraplineSelected,
+		Category:        detail.Category,
+		ArticleText:     detail.ArticleText,
+		ArticleSections: detail.ArticleSections,
+		Facts:           make([]models.SharedFact, 0, len(detail.Facts)),
+		Gaps:            make([]models.SharedGap, 0, len(detail.Gaps)),
+		Note:            note,
+		ExpiresAt:       expiresAt.UTC(),
+	}
+	for _, fact := range detail.Facts {
+		if fact.Included {
+			shared.Facts = append(shared.Facts, models.SharedFact{Text: fact.Text, Confirmed: fact.Confirmed})
+		}
+	}
+	for _, gap := range detail.Gaps {
+		shared.Gaps = append(shared.Gaps, models.SharedGap{Text: gap.Text, Resolved: gap.Resolved})
+	}
+	return shared, nil
+}
+
+// RenderReview renders the page a share link opens in a browser. A nil
+// analysis renders the page for a link that no longer works.
+func RenderReview(shared *models.SharedAnalysis) ([]byte, error) {
+	page := struct {
+		Analysis *models.SharedAnalysis
+		Sections []bundleSection
+	}{Analysis: shared}
+	if shared != nil {
+		page.Sections = articleBlocks(shared.ArticleText, shared.ArticleSections)
+	}
+
+	var out bytes.Buffer
+	if err := reviewTemplate.Execute(&out, page); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+const shareSelect = `
+	SELECT id, article_id, COALESCE(note, ''), created_by, expires_at, revoked_at, view_count, last_viewed_at, COALESCE(created_at, CURRENT_TIMESTAMP)
+	FROM analysis_shares`
+
+func (s *ShareService) get(ctx context.Context, shareID int64) (models.AnalysisShare, error) {
+	return scanShare(s.store.QueryRowContext(ctx, shareSelect+" WHERE id = ?", shareID))
+}
+
+func scanShare(row rowScanner) (models.AnalysisShare, error) {
+	var (
+		share      models.AnalysisShare
+		createdBy  sql.NullInt64
+		revokedAt  sql.NullTime
+		lastViewed sql.NullTime
+	)
+	if err := row.Scan(&share.ID, &share.ArticleID, &share.Note, &createdBy, &share.ExpiresAt, &revokedAt, &share.Views, &lastViewed, &share.CreatedAt); err != nil {
+		return models.AnalysisShare{}, err
+	}
+	share.CreatedBy = nullInt64Pointer(createdBy)
+	if revokedAt.Valid {
+		share.RevokedAt = &revokedAt.Time
+	}
+	if lastViewed.Valid {
+		share.LastViewedAt = &lastViewed.Time
+	}
+	share.Active = share.RevokedAt == nil && time.Now().Before(share.ExpiresAt)
+	return share, nil
+}
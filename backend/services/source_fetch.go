@@ -0,0 +1,341 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"nanoheads/config"
+)
+
+const (
+	sourceFetchTimeout = 12 * time.Second
+	maxSourcePageBytes = 2 << 20
+	maxRobotsBytes     = 500 << 10
+	robotsCacheFor     = time.Hour
+	// A robots.txt that errors is retried sooner than a readable one.
+	robotsRetryAfter = 5 * time.Minute
+	maxCrawlDelay    = 30 * time.Second
+)
+
+// sourceFetches is shared by every FactService, so host spacing and cached
+// robots.txt rules hold across requests and background jobs.
+var sourceFetches = &sourceFetcher{
+	nextFetch: map[string]time.Time{},
+	robots:    map[string]cachedRobots{},
+}
+
+type sourceFetcher struct {
+	mu        sync.Mutex
+	nextFetch map[string]time.Time
+	robots    map[string]cachedRobots
+}
+
+type cachedRobots struct {
+	rules   robotsRules
+	expires time.Time
+}
+
+// sourcePage is a fetched page as it was received, kept so the analysis can
+// store a snapshot of what its source said.
+type sourcePage struct {
+	url         string // where the content came from, after redirects
+	strategy    string
+	contentType string
+	body        []byte
+	html        string // body decoded to UTF-8
+	fetchedAt   time.Time
+}
+
+func (p *sourcePage) text() string {
+	if p == nil {
+		return ""
+	}
+	return sanitizeHTMLText(p.html)
+}
+
+// fetchText fetches a submitted URL and returns its readable text, decoded
+// from the charset the page declares, and the page it came from. render
+// loads the page in the browser instead; without it, a page too thin for
+// RenderMinWords is rendered when rendering is on. A page still thin or
+// failing is tried through the configured fallbacks, and if none does
+// better, the first result stands.
+func (f *sourceFetcher) fetchText(ctx context.Context, target *url.URL, render bool) (string, *sourcePage, error) {
+	cfg := config.Current()
+	client := newSourceFetchClient(sourceFetchTimeout)
+
+	if render {
+		if !cfg.RenderEnabled {
+			return "", nil, errRenderingOff
+		}
+		page, err := f.fetchRendered(ctx, client, cfg, target)
+		if err != nil {
+			return "", nil, err
+		}
+		return page.text(), page, nil
+	}
+
+	page, err := f.fetchPage(ctx, client, cfg, target)
+	if asFetchRefused(err) != nil {
+		return "", nil, err
+	}
+	best, bestText := page, page.text()
+	if err == nil && cfg.RenderEnabled && thinText(bestText, cfg.RenderMinWords) {
+		if rendered, renderErr := f.fetchRendered(ctx, client, cfg, target); renderErr != nil {
+			log.Printf("[fetch] rendering %s failed: %v", target.Redacted(), renderErr)
+		} else if text := rendered.text(); len(strings.Fields(text)) > len(strings.Fields(bestText)) {
+			best, bestText = rendered, text
+		}
+	}
+	if err == nil && !thinText(bestText, cfg.FetchMinWords) {
+		return bestText, best, nil
+	}
+
+	for _, strategy := range cfg.FetchFallbacks {
+		candidate, fallbackErr := f.fetchFallback(ctx, client, cfg, strategy, target, best)
+		if fallbackErr != nil {
+			log.Printf("[fetch] %s fallback for %s failed: %v", strategy, target.Redacted(), fallbackErr)
+			continue
+		}
+		text := candidate.text()
+		if !thinText(text, cfg.FetchMinWords) {
+			return text, candidate, nil
+		}
+		if len(text) > len(bestText) {
+			best, bestText = candidate, text
+		}
+	}
+
+	if best == nil {
+		return "", nil, err
+	}
+	return bestText, best, nil
+}
+
+// admit applies robots.txt to target and waits for its host's turn.
+func (f *sourceFetcher) admit(ctx context.Context, client *http.Client, cfg config.Config, target *url.URL) error {
+	spacing := cfg.FetchDomainInterval.Duration
+	if cfg.FetchRespectRobots {
+		rules, err := f.robotsFor(ctx, client, cfg, target)
+		if err != nil {
+			return err
+		}
+		if rules.disallowed {
+			return &fetchRefusedError{reason: fmt.Sprintf("robots.txt on %s cannot be read, so its pages may not be fetched", target.Host)}
+		}
+		if !rules.allows(robotsPath(target)) {
+			return &fetchRefusedError{reason: fmt.Sprintf("robots.txt on %s does not allow fetching it", target.Host)}
+		}
+		spacing = max(spacing, min(rules.crawlDelay, maxCrawlDelay))
+	}
+	return f.wait(ctx, strings.ToLower(target.Hostname()), spacing)
+}
+
+func (f *sourceFetcher) fetchRendered(ctx context.Context, client *http.Client, cfg config.Config, target *url.URL) (*sourcePage, error) {
+	if err := f.admit(ctx, client, cfg, target); err != nil {
+		return nil, err
+	}
+	document, err := renderPage(ctx, cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	return &sourcePage{
+		url:         target.String(),
+		strategy:    fetchRendered,
+		contentType: "text/html; charset=utf-8",
+		body:        []byte(document),
+		html:        document,
+		fetchedAt:   time.Now(),
+	}, nil
+}
+
+func (f *sourceFetcher) fetchPage(ctx context.Context, client *http.Client, cfg config.Config, target *url.URL) (*sourcePage, error) {
+	if err := f.admit(ctx, client, cfg, target); err != nil {
+		return nil, err
+	}
+
+	response, err := f.get(ctx, client, cfg, target.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("url returned status %d", response.StatusCode)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	if !readableContentType(contentType) {
+		return nil, &fetchRefusedError{reason: fmt.Sprintf("it serves %s, not a web page", contentType)}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxSourcePageBytes))
+	if err != nil {
+		return nil, err
+	}
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sourcePage{
+		url:         response.Request.URL.String(),
+		strategy:    fetchDirect,
+		contentType: contentType,
+		body:        body,
+		html:        string(decoded),
+		fetchedAt:   time.Now(),
+	}, nil
+}
+
+func (f *sourceFetcher) get(ctx context.Context, client *http.Client, cfg config.Config, target string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", cfg.FetchUserAgent)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+
+	response, err := client.Do(request)
+	if err != nil {
+		if refused := asFetchRefused(err); refused != nil {
+			return nil, refused
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// robotsFor returns the robots.txt rules for target's origin, from cache when
+// fresh. A missing robots.txt (any 4xx) allows everything; a server error
+// disallows everything for a while.
+func (f *sourceFetcher) robotsFor(ctx context.Context, client *http.Client, cfg config.Config, target *url.URL) (robotsRules, error) {
+	origin := target.Scheme + "://" + strings.ToLower(target.Host)
+
+	f.mu.Lock()
+	cached, ok := f.robots[origin]
+	f.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.rules, nil
+	}
+
+	response, err := f.get(ctx, client, cfg, origin+"/robots.txt")
+	if err != nil {
+		if refused := asFetchRefused(err); refused != nil {
+			return robotsRules{}, refused
+		}
+		return robotsRules{}, fmt.Errorf("read robots.txt on %s: %w", target.Host, err)
+	}
+	defer response.Body.Close()
+
+	var rules robotsRules
+	cacheFor := robotsCacheFor
+	switch {
+	case response.StatusCode >= http.StatusInternalServerError:
+		rules.disallowed = true
+		cacheFor = robotsRetryAfter
+	case response.StatusCode >= http.StatusBadRequest:
+	default:
+		body, err := io.ReadAll(io.LimitReader(response.Body, maxRobotsBytes))
+		if err != nil {
+			return robotsRules{}, fmt.Errorf("read robots.txt on %s: %w", target.Host, err)
+		}
+		rules = parseRobots(string(body), cfg.RobotsAgent())
+	}
+
+	f.mu.Lock()
+	f.robots[origin] = cachedRobots{rules: rules, expires: time.Now().Add(cacheFor)}
+	f.mu.Unlock()
+	return rules, nil
+}
+
+// wait holds a fetch to host until spacing has passed since the one before
+// it. Each caller reserves its slot up front, so concurrent fetches queue.
+// With Redis the slots are shared by every instance; if Redis can't be
+// reached, this process reserves them locally as it would without it.
+func (f *sourceFetcher) wait(ctx context.Context, host string, spacing time.Duration) error {
+	if redis := redisBackend(); redis != nil {
+		delay, err := redis.reserveSlot(ctx, "fetch-slot:"+host, spacing)
+		if err == nil {
+			return sleepContext(ctx, delay)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("[fetch] redis spacing for %s failed, spacing locally: %v", host, err)
+	}
+
+	now := time.Now()
+
+	f.mu.Lock()
+	if len(f.nextFetch) > 1000 {
+		for key, at := range f.nextFetch {
+			if at.Before(now) {
+				delete(f.nextFetch, key)
+			}
+		}
+	}
+	slot := f.nextFetch[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	f.nextFetch[host] = slot.Add(spacing)
+	f.mu.Unlock()
+
+	return sleepContext(ctx, slot.Sub(now))
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func robotsPath(target *url.URL) string {
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	return path
+}
+
+// readableContentType accepts HTML, XML and plain text, and responses that
+// don't say what they are.
+func readableContentType(contentType string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == "application/xml" ||
+		mediaType == "application/xhtml+xml"
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+type SourceService struct {
+	store *repository.Store
+}
+
+var sourceDomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+func NewSourceService(database *sql.DB) *SourceService {
+	return &SourceService{
+		store: repository.New(database),
+	}
+}
+
+// List returns registry entries, optionally only those with one credibility
+// rating. Each entry counts the analyses whose domain it covers exactly.
+func (s *SourceService) List(ctx context.Context, credibility string) ([]models.Source, error) {
+	query := `
+		SELECT s.id, s.domain, COALESCE(s.name, ''), s.credibility, COALESCE(s.notes, ''), s.skip_screening, s.updated_by, s.updated_at,
+			(SELECT COUNT(*) FROM articles a WHERE a.source_domain = s.domain) AS analysis_count
+		FROM sources s
+	`
+	args := make([]any, 0, 1)
+	if clean := strings.ToLower(strings.TrimSpace(credibility)); clean != "" {
+		if !containsString(models.CredibilityRatings, clean) {
+			return nil, invalidInput("credibility is invalid")
+		}
+		query += " WHERE s.credibility = ?"
+		args = append(args, clean)
+	}
+	query += " ORDER BY s.domain ASC"
+
+	rows, err := s.store.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make([]models.Source, 0)
+	for rows.Next() {
+		source, err := scanSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
+
+func (s *SourceService) Get(ctx context.Context, sourceID int64) (models.Source, error) {
+	query := `
+		SELECT s.id, s.domain, COALESCE(s.name, ''), s.credibility, COALESCE(s.notes, ''), s.skip_screening, s.updated_by, s.updated_at,
+			(SELECT COUNT(*) FROM articles a WHERE a.source_domain = s.domain) AS analysis_count
+		FROM sources s
+		WHERE s.id = ?
+	`
+	return scanSource(s.store.QueryRowContext(ctx, query, sourceID))
+}
+
+// Create adds a domain to the registry. A domain that was registered
+// automatically when an analysis arrived is rated in place instead.
+func (s *SourceService) Create(ctx context.Context, domain string, name string, credibility string, notes string, skipScreening bool, updatedBy *int64) (models.Source, error) {
+	cleanDomain, err := normalizeSourceDomain(domain)
+	if err != nil {
+		return models.Source{}, err
+	}
+	cleanCredibility, err := normalizeCredibility(credibility)
+	if err != nil {
+		return models.Source{}, err
+	}
+
+	var existingID int64
+	err = s.store.QueryRowContext(ctx, "SELECT id FROM sources WHERE domain = ?", cleanDomain).Scan(&existingID)
+	if err == nil {
+		cleanName, cleanNotes := strings.TrimSpace(name), strings.TrimSpace(notes)
+		return s.Update(ctx, existingID, &cleanName, &cleanCredibility, &cleanNotes, &skipScreening, updatedBy)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.Source{}, err
+	}
+
+	sourceID, err := s.store.Insert(
+		ctx,
+		"INSERT INTO sources (domain, name, credibility, notes, skip_screening, updated_by) VALUES (?, ?, ?, ?, ?, ?)",
+		cleanDomain,
+		nullString(strings.TrimSpace(name)),
+		cleanCredibility,
+		nullString(strings.TrimSpace(notes)),
+		skipScreening,
+		updatedBy,
+	)
+	if err != nil {
+		return models.Source{}, err
+	}
+	return s.Get(ctx, sourceID)
+}
+
+func (s *SourceService) Update(ctx context.Context, sourceID int64, name *string, credibility *string, notes *string, skipScreening *bool, updatedBy *int64) (models.Source, error) {
+	setClauses := make([]string, 0, 6)
+	args := make([]any, 0, 6)
+
+	if name != nil {
+		setClauses = append(setClauses, "name = ?")
+		args = append(args, nullString(strings.TrimSpace(*name)))
+	}
+	if credibility != nil {
+		clean, err := normalizeCredibility(*credibility)
+		if err != nil {
+			return models.Source{}, err
+		}
+		setClauses = append(setClauses, "credibility = ?")
+		args = append(args, clean)
+	}
+	if notes != nil {
+		setClauses = append(setClauses, "notes = ?")
+		args = append(args, nullString(strings.TrimSpace(*notes)))
+	}
+	if skipScreening != nil {
+		setClauses = append(setClauses, "skip_screening = ?")
+		args = append(args, *skipScreening)
+	}
+	if len(setClauses) == 0 {
+		return models.Source{}, invalidInput("no source fields provided")
+	}
+	setClauses = append(setClauses, "updated_by = ?", "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, updatedBy, sourceID)
+
+	query := "UPDATE sources SET " + strings.Join(setClauses, ", ") + " WHERE id = ?"
+	result, err := s.store.ExecContext(ctx, query, args...)
+	if err != nil {
+		return models.Source{}, err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return models.Source{}, err
+	}
+
+	return s.Get(ctx, sourceID)
+}
+
+func (s *SourceService) Delete(ctx context.Context, sourceID int64) error {
+	result, err := s.store.ExecContext(ctx, "DELETE FROM sources WHERE id = ?", sourceID)
+	if err != nil {
+		return err
+	}
+	return ensureRowsAffected(result)
+}
+
+type sourceScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSource(row sourceScanner) (models.Source, error) {
+	var (
+		source    models.Source
+		updatedBy sql.NullInt64
+		updatedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&source.ID,
+		&source.Domain,
+		&source.Name,
+		&source.Credibility,
+		&source.Notes,
+		&source.SkipScreening,
+		&updatedBy,
+		&updatedAt,
+		&source.AnalysisCount,
+	); err != nil {
+		return models.Source{}, err
+	}
+	if updatedBy.Valid {
+		source.UpdatedBy = &updatedBy.Int64
+	}
+	if updatedAt.Valid {
+		value := updatedAt.Time
+		source.UpdatedAt = &value
+	}
+	source.Flagged = source.Credibility == models.CredibilityLow
+	return source, nil
+}
+
+func normalizeCredibility(value string) (string, error) {
+	clean := strings.ToLower(strings.TrimSpace(value))
+	if clean == "" {
+		return models.CredibilityUnrated, nil
+	}
+	if !containsString(models.CredibilityRatings, clean) {
+		return "", invalidInputf("credibility is invalid; use one of %s", strings.Join(models.CredibilityRatings, ", "))
+	}
+	return clean, nil
+}
+
+// normalizeSourceDomain accepts a bare domain or a full URL and returns the
+// lowercase host without port or "www.".
+func normalizeSourceDomain(value string) (string, error) {
+	clean := strings.ToLower(strings.TrimSpace(value))
+	if clean == "" {
+		return "", invalidInput("domain is required")
+	}
+
+	host := clean
+	if strings.Contains(clean, "://") {
+		parsed, err := url.Parse(clean)
+		if err != nil {
+			return "", invalidInput("domain is invalid")
+		}
+		host = parsed.Host
+	} else if idx := strings.IndexAny(host, "/?#"); idx >= 0 {
+		host = host[:idx]
+	}
+	if withoutPort, _, err := net.SplitHostPort(host); err == nil {
+		host = withoutPort
+	}
+	host = strings.TrimPrefix(strings.TrimSuffix(host, "."), "www.")
+
+	if !sourceDomainPattern.MatchString(host) {
+		return "", invalidInput("domain is invalid")
+	}
+	return host, nil
+}
+
+// sourceDomain is the registry domain for an analysis URL, or "" when the URL
+// has no usable host.
+func sourceDomain(rawURL string) string {
+	if strings.TrimSpace(rawURL) == "" {
+		return ""
+	}
+	domain, err := normalizeSourceDomain(rawURL)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+// registerSourceDomain adds an unrated registry entry for a domain seen for the
+// first time, so editors have it in the list to rate.
+func registerSourceDomain(ctx context.Context, tx *repository.Tx, domain string) error {
+	if domain == "" {
+		return nil
+	}
+	query, err := repository.InsertIgnore(tx.Driver(), "sources", []string{"domain", "credibility"}, []string{"domain"})
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, query, domain, models.CredibilityUnrated)
+	return err
+}
+
+// lookupSourceRatings finds the registry entry for each domain, preferring an
+// exact match and then the closest parent domain, so news.example.com falls
+// back to example.com.
+func lookupSourceRatings(ctx context.Context, store *repository.Store, domains []string) (map[string]*models.SourceRating, error) {
+	candidates := make([]any, 0, len(domains)*2)
+	seen := make(map[string]struct{})
+	for _, domain := range domains {
+		for _, candidate := range parentDomains(domain) {
+			if _, ok := seen[candidate]; ok {
+				continue
+			}
+			seen[candidate] = struct{}{}
+			candidates = append(candidates, candidate)
+		}
+	}
+	ratings := make(map[string]*models.SourceRating, len(domains))
+	if len(candidates) == 0 {
+		return ratings, nil
+	}
+
+	query := "SELECT domain, COALESCE(name, ''), credibility, COALESCE(notes, '') FROM sources WHERE domain IN (" + repository.Placeholders(len(candidates)) + ")"
+	rows, err := store.QueryContext(ctx, query, candidates...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	registry := make(map[string]models.SourceRating, len(candidates))
+	for rows.Next() {
+		var entry models.SourceRating
+		if err := rows.Scan(&entry.RegistryDomain, &entry.Name, &entry.Credibility, &entry.Notes); err != nil {
+			return nil, err
+		}
+		registry[entry.RegistryDomain] = entry
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, domain := range domains {
+		if domain == "" {
+			continue
+		}
+		rating := &models.SourceRating{Domain: domain, Credibility: models.CredibilityUnrated}
+		for _, candidate := range parentDomains(domain) {
+			entry, ok := registry[candidate]
+			if !ok {
+				continue
+			}
+			entry.Domain = domain
+			if rating.RegistryDomain == "" {
+				rating = &entry
+			}
+			// An unrated subdomain entry shouldn't hide a rated parent.
+			if entry.Credibility != models.CredibilityUnrated {
+				rating = &entry
+				break
+			}
+		}
+		rating.Flagged = rating.Credibility == models.CredibilityLow
+		ratings[domain] = rating
+	}
+	return ratings, nil
+}
+
+// parentDomains lists domain and its parents down to two labels, most
+// specific first.
+func parentDomains(domain string) []string {
+	if domain == "" {
+		return nil
+	}
+	labels := strings.Split(domain, ".")
+	out := make([]string, 0, len(labels))
+	for idx := 0; idx+2 <= len(labels); idx++ {
+		out = append(out, strings.Join(labels[idx:], "."))
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// ErrSnapshotChanged means a stored snapshot no longer hashes to what was
+// recorded when its page was fetched.
+var ErrSnapshotChanged = errors.New("snapshot no longer matches its recorded sha256")
+
+type sourceSnapshot struct {
+	position   int
+	sourceURL  string
+	page       *sourcePage
+	sha256     string
+	storageKey string
+}
+
+// snapshotsOf lists the fetched pages of sources, positioned as the sources
+// are stored. Sources given as text have none.
+func snapshotsOf(sources ...resolvedSource) []sourceSnapshot {
+	snapshots := make([]sourceSnapshot, 0, len(sources))
+	for idx, source := range sources {
+		if source.page == nil {
+			continue
+		}
+		sum := sha256.Sum256(source.page.body)
+		snapshots = append(snapshots, sourceSnapshot{
+			position:  idx + 1,
+			sourceURL: source.url,
+			page:      source.page,
+			sha256:    hex.EncodeToString(sum[:]),
+		})
+	}
+	return snapshots
+}
+
+// storeSnapshots writes each page gzipped under UPLOAD_DIR/snapshots by
+// content hash, so a page fetched twice unchanged is stored once.
+func storeSnapshots(snapshots []sourceSnapshot) error {
+	root := config.Current().UploadDir
+	for idx := range snapshots {
+		key := filepath.ToSlash(filepath.Join("snapshots", snapshots[idx].sha256[:2], snapshots[idx].sha256+".html.gz"))
+
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		_, writeErr := writer.Write(snapshots[idx].page.body)
+		if err := errors.Join(writeErr, writer.Close()); err != nil {
+			return fmt.Errorf("store snapshot: %w", err)
+		}
+		if err := writeStoredFile(filepath.Join(root, filepath.FromSlash(key)), compressed.Bytes()); err != nil {
+			return fmt.Errorf("store snapshot: %w", err)
+		}
+		snapshots[idx].storageKey = key
+	}
+	return nil
+}
+
+func insertSourceSnapshots(ctx context.Context, tx *repository.Tx, articleID int64, snapshots []sourceSnapshot) error {
+	query := `
+		INSERT INTO source_snapshots (article_id, position, source_url, fetched_url, fetch_strategy, content_type, size_bytes, sha256, storage_key, fetched_at)
+		VALUES (` + repository.Placeholders(10) + `)`
+	for _, snapshot := range snapshots {
+		if snapshot.storageKey == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			query,
+			articleID,
+			snapshot.position,
+			snapshot.sourceURL,
+			snapshot.page.url,
+			snapshot.page.strategy,
+			nullString(snapshot.page.contentType),
+			len(snapshot.page.body),
+			snapshot.sha256,
+			snapshot.storageKey,
+			snapshot.page.fetchedAt.UTC(),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSnapshot decompresses a stored snapshot and checks it still matches
+// the hash recorded when it was fetched.
+func ReadSnapshot(snapshot models.SourceSnapshot, path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	defer reader.Close()
+	body, err := io.ReadAll(io.LimitReader(reader, maxSourcePageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != snapshot.SHA256 {
+		return nil, ErrSnapshotChanged
+	}
+	return body, nil
+}
+
+// SnapshotPath is where a stored snapshot lives on disk.
+func SnapshotPath(storageKey string) string {
+	return filepath.Join(config.Current().UploadDir, filepath.FromSlash(storageKey))
+}
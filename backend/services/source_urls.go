@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"nanoheads/config"
+)
+
+// CheckSourceURL parses a URL submitted for analysis and applies the URL
+// allow and deny lists from config.
+func CheckSourceURL(raw string) (*url.URL, error) {
+	parsed, err := url.ParseRequestURI(strings.TrimSpace(raw))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, invalidInput("url is invalid")
+	}
+	if !config.Current().HostAllowed(parsed.Hostname()) {
+		return nil, invalidInputf("url host %s is not allowed", parsed.Hostname())
+	}
+	return parsed, nil
+}
+
+// fetchRefusedError is returned when fetching a submitted URL would reach
+// somewhere it should not.
+type fetchRefusedError struct {
+	reason string
+}
+
+func (e *fetchRefusedError) Error() string {
+	return "invalid url: " + e.reason
+}
+
+func asFetchRefused(err error) *fetchRefusedError {
+	var refused *fetchRefusedError
+	if errors.As(err, &refused) {
+		return refused
+	}
+	return nil
+}
+
+// newSourceFetchClient returns the client for fetching submitted URLs.
+//
+// Addresses are checked as the connection is made, after DNS resolution, so
+// a host cannot pass the check and then resolve somewhere internal. Each
+// redirect goes through CheckSourceURL again. Environment proxies are
+// ignored: through a proxy the dialled address would be the proxy's.
+func newSourceFetchClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return &fetchRefusedError{reason: fmt.Sprintf("cannot check address %s", address)}
+			}
+			if !config.Current().FetchAddressAllowed(addrPort.Addr()) {
+				return &fetchRefusedError{reason: fmt.Sprintf("it resolves to blocked address %s", addrPort.Addr().Unmap())}
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if limit := config.Current().FetchMaxRedirects; len(via) > limit {
+				return &fetchRefusedError{reason: fmt.Sprintf("it redirects more than %d times", limit)}
+			}
+			if _, err := CheckSourceURL(request.URL.String()); err != nil {
+				return &fetchRefusedError{reason: fmt.Sprintf("it redirects to %s: %s", request.URL.Redacted(), strings.TrimPrefix(err.Error(), "url "))}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+const (
+	ssoTimeout = 10 * time.Second
+	// A sign-in has this long to come back from the provider.
+	ssoStateTTL = 10 * time.Minute
+)
+
+var errSSOStateInvalid = invalidInput("this sign-in has expired or was started in another browser; try again")
+
+// oidcProvider is the part of a provider's discovery document used here.
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// ssoState travels to the provider and back as the state parameter, signed
+// so it can't be forged. Nonce is also kept in a cookie, tying the sign-in
+// to the browser that started it.
+type ssoState struct {
+	Nonce     string `json:"n"`
+	Next      string `json:"r,omitempty"`
+	ExpiresAt int64  `json:"e"`
+}
+
+type idTokenClaims struct {
+	Issuer        string       `json:"iss"`
+	Audience      oidcAudience `json:"aud"`
+	AuthorizedBy  string       `json:"azp"`
+	ExpiresAt     int64        `json:"exp"`
+	Nonce         string       `json:"nonce"`
+	Email         string       `json:"email"`
+	EmailVerified any          `json:"email_verified"`
+	Name          string       `json:"name"`
+	HD            string       `json:"hd"`
+}
+
+const googleIssuer = "https://accounts.google.com"
+
+// oidcAudience is aud, which may be one client ID or a list of them.
+type oidcAudience []string
+
+func (a *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = oidcAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// SSOService signs people in through an OpenID Connect provider and starts
+// a session for them, creating their account the first time if their email
+// domain is allowed.
+type SSOService struct {
+	auth   *AuthService
+	client *http.Client
+
+	mu             sync.Mutex
+	provider       oidcProvider
+	providerIssuer string
+}
+
+func NewSSOService(auth *AuthService) *SSOService {
+	return &SSOService{
+		auth:   auth,
+		client: &http.Client{Timeout: ssoTimeout},
+	}
+}
+
+// Start returns the provider URL to send the browser to, and the nonce the
+// caller must keep in a cookie for Callback. next is a path in the admin app
+// to land on afterwards.
+func (s *SSOService) Start(ctx context.Context, next string) (string, string, error) {
+	cfg := config.Current()
+	if !cfg.SSOEnabled() {
+		return "", "", notConfigured("single sign-on needs OIDC_CLIENT_ID")
+	}
+	provider, err := s.discover(ctx, cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generate sso nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(buf)
+	state, err := s.signState(ssoState{Nonce: nonce, Next: cleanNextPath(next), ExpiresAt: time.Now().Add(ssoStateTTL).Unix()})
+	if err != nil {
+		return "", "", err
+	}
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {cfg.OIDCClientID},
+		"redirect_uri":  {cfg.OIDCRedirectURL},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	// Google shows only accounts of the hosted domain; other providers
+	// ignore it.
+	if len(cfg.OIDCAllowedDomains) == 1 {
+		params.Set("hd", cfg.OIDCAllowedDomains[0])
+	}
+	separator := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return provider.AuthorizationEndpoint + separator + params.Encode(), nonce, nil
+}
+
+// Callback finishes a sign-in the provider sent back with code, checking
+// state against the nonce from the browser's cookie. It returns the new
+// session and the admin path to land on.
+func (s *SSOService) Callback(ctx context.Context, code string, state string, cookieNonce string, userAgent string, clientIP string) (models.SessionTokens, string, error) {
+	cfg := config.Current()
+	if !cfg.SSOEnabled() {
+		return models.SessionTokens{}, "", notConfigured("single sign-on needs OIDC_CLIENT_ID")
+	}
+	signed, err := s.parseState(state, time.Now())
+	if err != nil {
+		return models.SessionTokens{}, "", err
+	}
+	if cookieNonce == "" || subtle.ConstantTimeCompare([]byte(cookieNonce), []byte(signed.Nonce)) != 1 {
+		return models.SessionTokens{}, "", errSSOStateInvalid
+	}
+	if strings.TrimSpace(code) == "" {
+		return models.SessionTokens{}, "", invalidInput("the sign-in provider sent no authorization code")
+	}
+
+	provider, err := s.discover(ctx, cfg)
+	if err != nil {
+		return models.SessionTokens{}, "", err
+	}
+	idToken, err := s.exchangeCode(ctx, cfg, provider, code)
+	if err != nil {
+		return models.SessionTokens{}, "", err
+	}
+	claims, err := parseIDToken(idToken, provider.Issuer, cfg.OIDCClientID, signed.Nonce, time.Now())
+	if err != nil {
+		return models.SessionTokens{}, "", err
+	}
+
+	userID, err := s.userFor(ctx, cfg, claims)
+	if err != nil {
+		return models.SessionTokens{}, "", err
+	}
+	tokens, err := s.auth.startSession(ctx, userID, "Single sign-on", userAgent, clientIP)
+	if err != nil {
+		return models.SessionTokens{}, "", err
+	}
+	return tokens, signed.Next, nil
+}
+
+// userFor finds the account for a verified email, creating it when the
+// email's domain is allowed. On Google the domain is the account's hosted
+// domain (hd): a consumer account can carry a verified address at any
+// domain, so it may neither be created nor take over an account at an
+// allowed one.
+func (s *SSOService) userFor(ctx context.Context, cfg config.Config, claims idTokenClaims) (int64, error) {
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" {
+		return 0, WithCode(models.ErrorCodeForbidden, errors.New("the sign-in provider didn't share an email address"))
+	}
+	if !emailVerified(claims.EmailVerified) {
+		return 0, WithCode(models.ErrorCodeForbidden, fmt.Errorf("%s is not verified with the sign-in provider", email))
+	}
+
+	_, domain, _ := strings.Cut(email, "@")
+	allowed := slices.Contains(cfg.OIDCAllowedDomains, domain)
+	if cfg.OIDCIssuer == googleIssuer {
+		hosted := slices.Contains(cfg.OIDCAllowedDomains, strings.ToLower(strings.TrimSpace(claims.HD)))
+		if allowed && !hosted {
+			return 0, WithCode(models.ErrorCodeForbidden, fmt.Errorf("%s must sign in with its %s Google Workspace account", email, domain))
+		}
+		allowed = hosted
+	}
+
+	var (
+		userID int64
+		active bool
+	)
+	err := s.auth.store.QueryRowContext(ctx, "SELECT id, COALESCE(is_active, true) FROM users WHERE email = ?", email).Scan(&userID, &active)
+	switch {
+	case err == nil && !active:
+		return 0, WithCode(models.ErrorCodeForbidden, fmt.Errorf("the account for %s is disabled", email))
+	case err == nil:
+		return userID, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return 0, err
+	}
+
+	if !allowed {
+		return 0, WithCode(models.ErrorCodeForbidden, fmt.Errorf("there is no account for %s; ask an admin to add you", email))
+	}
+	user, _, err := s.auth.CreateUser(ctx, email, claims.Name, cfg.OIDCDefaultRole)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("[auth] created user %d (%s) with role %s on first single sign-on", user.ID, email, user.Role)
+	return user.ID, nil
+}
+
+// discover reads the provider's discovery document once per issuer.
+func (s *SSOService) discover(ctx context.Context, cfg config.Config) (oidcProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.providerIssuer == cfg.OIDCIssuer {
+		return s.provider, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.OIDCIssuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return oidcProvider{}, err
+	}
+	request.Header.Set("Accept", "application/json")
+	var provider oidcProvider
+	if err := s.doJSON(request, &provider); err != nil {
+		return oidcProvider{}, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" {
+		return oidcProvider{}, fmt.Errorf("oidc discovery: %s lists no authorization or token endpoint", cfg.OIDCIssuer)
+	}
+	if provider.Issuer == "" {
+		provider.Issuer = cfg.OIDCIssuer
+	}
+	s.provider, s.providerIssuer = provider, cfg.OIDCIssuer
+	return provider, nil
+}
+
+func (s *SSOService) exchangeCode(ctx context.Context, cfg config.Config, provider oidcProvider, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.OIDCRedirectURL},
+		"client_id":     {cfg.OIDCClientID},
+		"client_secret": {cfg.OIDCClientSecret},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	var payload struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := s.doJSON(request, &payload); err != nil {
+		return "", fmt.Errorf("oidc token exchange: %w", err)
+	}
+	if payload.IDToken == "" {
+		return "", errors.New("oidc token exchange: no id_token in the response")
+	}
+	return payload.IDToken, nil
+}
+
+func (s *SSOService) doJSON(request *http.Request, out any) error {
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s returned status %d: %s", request.URL.Host, response.StatusCode, truncateRunes(string(body), 300))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// parseIDToken reads and checks the claims of an ID token. Its signature
+// isn't checked: it came straight from the token endpoint over TLS, which
+// OpenID Connect accepts in place of one (Core 1.0, 3.1.3.7).
+func parseIDToken(token string, issuer string, clientID string, nonce string, now time.Time) (idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return idTokenClaims{}, errors.New("oidc: malformed id_token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("oidc: decode id_token: %w", err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return idTokenClaims{}, fmt.Errorf("oidc: decode id_token: %w", err)
+	}
+
+	// Google has issued tokens naming itself without the scheme.
+	if claims.Issuer != issuer && "https://"+claims.Issuer != issuer {
+		return idTokenClaims{}, fmt.Errorf("oidc: id_token issued by %q, not %q", claims.Issuer, issuer)
+	}
+	if !slices.Contains(claims.Audience, clientID) || (len(claims.Audience) > 1 && claims.AuthorizedBy != clientID) {
+		return idTokenClaims{}, errors.New("oidc: id_token is for another client")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return idTokenClaims{}, errSSOStateInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return idTokenClaims{}, errSSOStateInvalid
+	}
+	return claims, nil
+}
+
+// emailVerified reads email_verified, which some providers send as a string.
+func emailVerified(value any) bool {
+	switch verified := value.(type) {
+	case bool:
+		return verified
+	case string:
+		return strings.EqualFold(verified, "true")
+	default:
+		return false
+	}
+}
+
+func (s *SSOService) signState(state ssoState) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.stateMAC(encoded)), nil
+}
+
+func (s *SSOService) parseState(state string, now time.Time) (ssoState, error) {
+	encoded, signature, ok := strings.Cut(state, ".")
+	if !ok {
+		return ssoState{}, errSSOStateInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.stateMAC(encoded)) {
+		return ssoState{}, errSSOStateInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ssoState{}, errSSOStateInvalid
+	}
+	var parsed ssoState
+	if err := json.Unmarshal(payload, &parsed); err != nil || now.Unix() >= parsed.ExpiresAt {
+		return ssoState{}, errSSOStateInvalid
+	}
+	return parsed, nil
+}
+
+// stateMAC signs with the session secret under its own label, so a state
+// can never pass for an access token.
+func (s *SSOService) stateMAC(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.auth.sessionSecret)
+	mac.Write([]byte("sso-state:" + encoded))
+	return mac.Sum(nil)
+}
+
+// cleanNextPath keeps next only if it is a path on the admin app, so the
+// sign-in can't be used to send someone elsewhere.
+func cleanNextPath(next string) string {
+	next = strings.TrimSpace(next)
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.ContainsAny(next, "\\#\r\n") || len(next) > 500 {
+		return ""
+	}
+	return next
+}
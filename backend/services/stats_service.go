@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	defaultTrendingItems = 10
+	maxTrendingItems     = 50
+)
+
+type StatsService struct {
+	store *repository.Store
+}
+
+func NewStatsService(database *sql.DB) *StatsService {
+	return &StatsService{
+		store: repository.New(database),
+	}
+}
+
+// EditorStats reports every active user, and any inactive one with activity
+// in the period. Open assignments are counted as of now, whatever the period.
+func (s *StatsService) EditorStats(ctx context.Context, days int) (models.EditorStats, error) {
+	period, err := normalizeAnalyticsPeriod(models.AnalyticsPeriod{Bucket: "day", Days: days})
+	if err != nil {
+		return models.EditorStats{}, err
+	}
+	window := newAnalyticsBuckets(period, time.Now())
+
+	editors, order, err := s.loadEditors(ctx)
+	if err != nil {
+		return models.EditorStats{}, err
+	}
+
+	counts := []struct {
+		query string
+		field func(*models.EditorStat) *int64
+	}{
+		{
+			"SELECT submitted_by, COUNT(*) FROM articles WHERE submitted_by IS NOT NULL AND created_at >= ? GROUP BY submitted_by",
+			func(stat *models.EditorStat) *int64 { return &stat.Created },
+		},
+		{
+			"SELECT completed_by, COUNT(*) FROM articles WHERE completed_by IS NOT NULL AND completed_at >= ? GROUP BY completed_by",
+			func(stat *models.EditorStat) *int64 { return &stat.Published },
+		},
+		{
+			"SELECT author_id, COUNT(*) FROM analysis_comments WHERE author_id IS NOT NULL AND created_at >= ? GROUP BY author_id",
+			func(stat *models.EditorStat) *int64 { return &stat.Comments },
+		},
+	}
+	for _, count := range counts {
+		if err := s.countByUser(ctx, editors, count.field, count.query, window.start); err != nil {
+			return models.EditorStats{}, err
+		}
+	}
+	err = s.countByUser(
+		ctx,
+		editors,
+		func(stat *models.EditorStat) *int64 { return &stat.OpenAssignments },
+		"SELECT assigned_to, COUNT(*) FROM articles WHERE assigned_to IS NOT NULL AND completed_at IS NULL GROUP BY assigned_to",
+	)
+	if err != nil {
+		return models.EditorStats{}, err
+	}
+	if err := s.loadReviews(ctx, editors, window.start); err != nil {
+		return models.EditorStats{}, err
+	}
+
+	stats := models.EditorStats{
+		From:    window.start.Format(time.DateOnly),
+		To:      window.end().Format(time.DateOnly),
+		Days:    period.Days,
+		Editors: make([]models.EditorStat, 0, len(order)),
+	}
+	for _, userID := range order {
+		stat := editors[userID]
+		if !stat.Active && stat.Created+stat.Reviewed+stat.Published+stat.Comments+stat.OpenAssignments == 0 {
+			continue
+		}
+		stats.Editors = append(stats.Editors, *stat)
+	}
+	sort.SliceStable(stats.Editors, func(i, j int) bool {
+		left, right := stats.Editors[i], stats.Editors[j]
+		if left.Published != right.Published {
+			return left.Published > right.Published
+		}
+		if left.Reviewed != right.Reviewed {
+			return left.Reviewed > right.Reviewed
+		}
+		return left.Created > right.Created
+	})
+	return stats, nil
+}
+
+func (s *StatsService) loadEditors(ctx context.Context) (map[int64]*models.EditorStat, []int64, error) {
+	rows, err := s.store.QueryContext(ctx, `
+		SELECT u.id, u.email, COALESCE(u.display_name, ''), COALESCE(r.role_key, ''), COALESCE(u.is_active, true)
+		FROM users u
+		LEFT JOIN roles r ON r.id = u.role_id
+		ORDER BY u.id ASC
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	editors := make(map[int64]*models.EditorStat)
+	order := make([]int64, 0)
+	for rows.Next() {
+		var stat models.EditorStat
+		if err := rows.Scan(&stat.UserID, &stat.Email, &stat.Name, &stat.Role, &stat.Active); err != nil {
+			return nil, nil, err
+		}
+		if stat.Name == "" {
+			stat.Name = stat.Email
+		}
+		editors[stat.UserID] = &stat
+		order = append(order, stat.UserID)
+	}
+	return editors, order, rows.Err()
+}
+
+// countByUser adds the (user id, count) rows of query to the field picked by
+// field. Rows for users that no longer exist are ignored.
+func (s *StatsService) countByUser(ctx context.Context, editors map[int64]*models.EditorStat, field func(*models.EditorStat) *int64, query string, args ...any) error {
+	rows, err := s.store.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, count int64
+		if err := rows.Scan(&userID, &count); err != nil {
+			return err
+		}
+		if stat, ok := editors[userID]; ok {
+			*field(stat) += count
+		}
+	}
+	return rows.Err()
+}
+
+// loadReviews counts analyses completed in the period while assigned to an
+// editor. Analyses assigned before this was tracked fall back to their
+// creation time.
+func (s *StatsService) loadReviews(ctx context.Context, editors map[int64]*models.EditorStat, since time.Time) error {
+	rows, err := s.store.QueryContext(
+		ctx,
+		"SELECT assigned_to, COALESCE(assigned_at, created_at, completed_at), completed_at FROM articles WHERE assigned_to IS NOT NULL AND completed_at >= ?",
+		since,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	hours := make(map[int64]float64)
+	for rows.Next() {
+		var (
+			userID      int64
+			assignedAt  time.Time
+			completedAt time.Time
+		)
+		if err := rows.Scan(&userID, &assignedAt, &completedAt); err != nil {
+			return err
+		}
+		stat, ok := editors[userID]
+		if !ok {
+			continue
+		}
+		stat.Reviewed++
+		hours[userID] += math.Max(completedAt.Sub(assignedAt).Hours(), 0)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for userID, total := range hours {
+		stat := editors[userID]
+		stat.AvgReviewHours = roundTo2(ratio(total, float64(stat.Reviewed)))
+	}
+	return nil
+}
+
+// TrendingStats ranks what the analyses of the last days days covered: their
+// topics, and the entities found in them. Each analysis counts for less the
+// older it is, halving every quarter of the window, and merged duplicates
+// aren't counted at all. Entities matched to the same Wikidata item in
+// different languages count as one.
+func (s *StatsService) TrendingStats(ctx context.Context, days int, limit int) (models.TrendingStats, error) {
+	period, err := normalizeAnalyticsPeriod(models.AnalyticsPeriod{Bucket: "day", Days: days})
+	if err != nil {
+		return models.TrendingStats{}, err
+	}
+	if limit == 0 {
+		limit = defaultTrendingItems
+	}
+	if limit < 1 || limit > maxTrendingItems {
+		return models.TrendingStats{}, invalidInput("limit must be between 1 and 50")
+	}
+	now := time.Now()
+	window := newAnalyticsBuckets(period, now)
+	halfLife := math.Max(float64(period.Days)/4, 1)
+
+	stats := models.TrendingStats{
+		From: window.start.Format(time.DateOnly),
+		To:   window.end().Format(time.DateOnly),
+		Days: period.Days,
+	}
+	stats.Topics, err = s.rankTrending(ctx, now, halfLife, limit, `
+		SELECT t.id, t.name, '', a.created_at
+		FROM articles a
+		JOIN topics t ON t.id = a.topic_id
+		WHERE a.created_at >= ? AND a.merged_into IS NULL
+	`, window.start)
+	if err != nil {
+		return models.TrendingStats{}, err
+	}
+	stats.Entities, err = s.rankTrending(ctx, now, halfLife, limit, `
+		SELECT e.id, COALESCE(e.label, ae.name), COALESCE(e.wikidata_id, ''), a.created_at
+		FROM article_entities ae
+		JOIN entities e ON e.id = ae.entity_id
+		JOIN articles a ON a.id = ae.article_id
+		WHERE a.created_at >= ? AND a.merged_into IS NULL
+	`, window.start)
+	if err != nil {
+		return models.TrendingStats{}, err
+	}
+	return stats, nil
+}
+
+// rankTrending scores the (id, name, wikidata id, created at) rows of query,
+// one per analysis a subject appears in, and returns the limit best.
+func (s *StatsService) rankTrending(ctx context.Context, now time.Time, halfLife float64, limit int, query string, args ...any) ([]models.TrendingItem, error) {
+	rows, err := s.store.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[string]*models.TrendingItem)
+	for rows.Next() {
+		var (
+			id        int64
+			item      models.TrendingItem
+			createdAt time.Time
+		)
+		if err := rows.Scan(&id, &item.Name, &item.WikidataID, &createdAt); err != nil {
+			return nil, err
+		}
+		key := item.WikidataID
+		if key == "" {
+			key = strconv.FormatInt(id, 10)
+		}
+		existing, ok := items[key]
+		if !ok {
+			existing = &item
+			items[key] = existing
+		}
+		existing.Analyses++
+		if createdAt.After(existing.LastSeen) {
+			existing.LastSeen = createdAt
+		}
+		age := math.Max(now.Sub(createdAt).Hours()/24, 0)
+		existing.Score += math.Pow(0.5, age/halfLife)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ranked := make([]models.TrendingItem, 0, len(items))
+	for _, item := range items {
+		item.Score = roundTo2(item.Score)
+		ranked = append(ranked, *item)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		left, right := ranked[i], ranked[j]
+		if left.Score != right.Score {
+			return left.Score > right.Score
+		}
+		if left.Analyses != right.Analyses {
+			return left.Analyses > right.Analyses
+		}
+		return left.Name < right.Name
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
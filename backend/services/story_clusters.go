@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"nanoheads/models"
+)
+
+const (
+	maxClusterDays = 60
+	// Most recent analyses clustered at once.
+	maxClusterAnalyses = 300
+)
+
+type clusterAnalysis struct {
+	member   models.ClusterMember
+	language string
+	profile  storyProfile
+}
+
+// Clusters groups the analyses of the last days into stories. Two analyses
+// in the same language belong together when they are as similar as a
+// follow-up would need to be, or when they are already in one thread; a
+// cluster is everything joined that way. Only clusters of at least minSize
+// analyses are returned, largest first.
+//
+// Similarity is lexical, the shared names and words of storySimilarity, not
+// embeddings: Groq, the default provider, has no embeddings API, and scoring
+// the same way as follow-ups keeps clusters and threads in agreement without
+// a model call per analysis.
+func (s *StoryThreadService) Clusters(ctx context.Context, days int, minSize int) ([]models.StoryCluster, error) {
+	if days < 1 || days > maxClusterDays {
+		return nil, invalidInputf("days must be between 1 and %d", maxClusterDays)
+	}
+	if minSize < 2 {
+		return nil, invalidInput("minSize must be at least 2")
+	}
+
+	analyses, err := s.loadClusterAnalyses(ctx, time.Now().Add(-time.Duration(days)*24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	parent := make([]int, len(analyses))
+	for idx := range parent {
+		parent[idx] = idx
+	}
+	var find func(int) int
+	find = func(idx int) int {
+		if parent[idx] != idx {
+			parent[idx] = find(parent[idx])
+		}
+		return parent[idx]
+	}
+	union := func(a, b int) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	scores := make([][]float64, len(analyses))
+	for idx := range scores {
+		scores[idx] = make([]float64, len(analyses))
+	}
+	threads := make(map[int64]int)
+	for i := range analyses {
+		if thread := analyses[i].member.ThreadID; thread != nil {
+			if first, ok := threads[*thread]; ok {
+				union(first, i)
+			} else {
+				threads[*thread] = i
+			}
+		}
+		for j := i + 1; j < len(analyses); j++ {
+			if analyses[i].language != analyses[j].language {
+				continue
+			}
+			score := storySimilarity(analyses[i].profile, analyses[j].profile)
+			scores[i][j], scores[j][i] = score, score
+			if score >= followUpThreshold {
+				union(i, j)
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	roots := make([]int, 0)
+	for idx := range analyses {
+		root := find(idx)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], idx)
+	}
+
+	clusters := make([]models.StoryCluster, 0)
+	for _, root := range roots {
+		members := groups[root]
+		if len(members) < minSize {
+			continue
+		}
+
+		// Analyses are loaded newest first, so ties go to the latest.
+		representative, bestTotal := members[0], -1.0
+		for _, i := range members {
+			total := 0.0
+			for _, j := range members {
+				total += scores[i][j]
+			}
+			if total > bestTotal {
+				representative, bestTotal = i, total
+			}
+		}
+
+		cluster := models.StoryCluster{
+			Headline:         analyses[representative].member.Title,
+			RepresentativeID: analyses[representative].member.ArticleID,
+			Language:         analyses[representative].language,
+			Size:             len(members),
+			LatestAt:         analyses[members[0]].member.CreatedAt,
+			Analyses:         make([]models.ClusterMember, 0, len(members)),
+		}
+		for _, idx := range members {
+			cluster.Analyses = append(cluster.Analyses, analyses[idx].member)
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].Size != clusters[j].Size {
+			return clusters[i].Size > clusters[j].Size
+		}
+		return clusters[i].LatestAt.After(clusters[j].LatestAt)
+	})
+	return clusters, nil
+}
+
+func (s *StoryThreadService) loadClusterAnalyses(ctx context.Context, since time.Time) ([]clusterAnalysis, error) {
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT a.id, COALESCE(a.headline_selected, ''), COALESCE(a.source_url, ''), COALESCE(a.raw_text, ''), COALESCE(a.status, 'draft'),
+			COALESCE(a.output_language, ''), sta.thread_id, a.created_at
+		FROM articles a
+		LEFT JOIN story_thread_articles sta ON sta.article_id = a.id
+		WHERE a.merged_into IS NULL AND a.created_at >= ?
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT ?`,
+		since,
+		maxClusterAnalyses,
+	)
+	if err != nil {
+		return nil, err
+	}
+	analyses := make([]clusterAnalysis, 0)
+	headlines := make([]string, 0)
+	for rows.Next() {
+		var (
+			analysis  clusterAnalysis
+			headline  string
+			sourceURL string
+			rawText   string
+			status    string
+			threadID  sql.NullInt64
+		)
+		if err := rows.Scan(&analysis.member.ArticleID, &headline, &sourceURL, &rawText, &status, &analysis.language, &threadID, &analysis.member.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		analysis.member.Title = buildAnalysisTitle(analysis.member.ArticleID, headline, sourceURL, rawText)
+		analysis.member.Status = formatStatus(status)
+		analysis.member.ThreadID = nullInt64Pointer(threadID)
+		analyses = append(analyses, analysis)
+		headlines = append(headlines, headline)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(analyses) == 0 {
+		return analyses, nil
+	}
+
+	ids := make([]any, len(analyses))
+	for idx, analysis := range analyses {
+		ids[idx] = analysis.member.ArticleID
+	}
+	facts, err := listFactsByArticle(ctx, s.store, ids)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range analyses {
+		analyses[idx].profile = buildStoryProfile(headlines[idx], facts[analyses[idx].member.ArticleID])
+	}
+	return analyses, nil
+}
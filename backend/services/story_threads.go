@@ -0,0 +1,462 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	// How far back to look for the story a new analysis follows up.
+	followUpWindow = 30 * 24 * time.Hour
+	// Most recent analyses compared against a new one.
+	followUpCandidates = 200
+	followUpThreshold  = 0.3
+	// Names two analyses must share, when both have any, to be one story.
+	followUpSharedNames = 2
+)
+
+type followUpPayload struct {
+	ArticleID int64 `json:"articleId"`
+}
+
+type StoryThreadService struct {
+	store *repository.Store
+}
+
+func NewStoryThreadService(database *sql.DB) *StoryThreadService {
+	return &StoryThreadService{store: repository.New(database)}
+}
+
+// storyProfile is what an analysis is compared on: the content words of its
+// facts and headline, and the names in them.
+type storyProfile struct {
+	tokens map[string]struct{}
+	names  map[string]struct{}
+}
+
+// EnqueueFollowUpDetection queues a search for the earlier story an analysis
+// follows up.
+func EnqueueFollowUpDetection(ctx context.Context, jobs *JobService, articleID int64, createdBy *int64) (models.Job, error) {
+	var exists int
+	if err := jobs.store.QueryRowContext(ctx, "SELECT 1 FROM articles WHERE id = ?", articleID).Scan(&exists); err != nil {
+		return models.Job{}, err
+	}
+	return jobs.Enqueue(ctx, models.JobTypeFollowUp, followUpPayload{ArticleID: articleID}, createdBy)
+}
+
+// DetectFollowUp compares an analysis with the analyses in the same language
+// from the month before it, and links it to the closest one as a follow-up.
+// The earlier analysis's thread is joined, or started when it has none.
+func (s *StoryThreadService) DetectFollowUp(ctx context.Context, articleID int64, report func(int, int)) (models.FollowUpResult, error) {
+	result := models.FollowUpResult{ArticleID: articleID}
+
+	threadID, err := findThreadID(ctx, s.store, articleID)
+	if err != nil {
+		return result, err
+	}
+	if threadID != nil {
+		result.ThreadID = threadID
+		return result, nil
+	}
+
+	var (
+		language  string
+		headline  string
+		createdAt time.Time
+	)
+	err = s.store.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(output_language, ''), COALESCE(headline_selected, ''), created_at FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&language, &headline, &createdAt)
+	if err != nil {
+		return result, err
+	}
+
+	facts, err := listTexts(ctx, s.store, "SELECT COALESCE(fact_text, '') FROM facts WHERE article_id = ? AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY position ASC, id ASC", articleID)
+	if err != nil {
+		return result, err
+	}
+	report(0, 1)
+	if len(facts) == 0 {
+		report(1, 1)
+		return result, nil
+	}
+	profile := buildStoryProfile(headline, facts)
+
+	candidates, err := s.loadFollowUpCandidates(ctx, articleID, language, createdAt)
+	if err != nil {
+		return result, err
+	}
+
+	var (
+		best      int64
+		bestScore float64
+		bestTitle string
+	)
+	for _, candidate := range candidates {
+		if score := storySimilarity(profile, candidate.profile); score > bestScore {
+			best, bestScore, bestTitle = candidate.id, score, candidate.title
+		}
+	}
+	if best == 0 || bestScore < followUpThreshold {
+		report(1, 1)
+		return result, nil
+	}
+
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		id, err := findThreadID(ctx, tx, best)
+		if err != nil {
+			return err
+		}
+		if id == nil {
+			created, err := tx.Insert(ctx, "INSERT INTO story_threads (title) VALUES (?)", truncateRunes(bestTitle, 255))
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO story_thread_articles (thread_id, article_id) VALUES (?, ?)", created, best); err != nil {
+				return err
+			}
+			id = &created
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO story_thread_articles (thread_id, article_id, follows_article_id, similarity) VALUES (?, ?, ?, ?)",
+			*id,
+			articleID,
+			best,
+			roundTo2(bestScore),
+		)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE story_threads SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", *id); err != nil {
+			return err
+		}
+		result.ThreadID = id
+		return nil
+	})
+	if err != nil {
+		return models.FollowUpResult{ArticleID: articleID}, err
+	}
+
+	report(1, 1)
+	result.FollowsArticleID = &best
+	result.Similarity = roundTo2(bestScore)
+	return result, nil
+}
+
+type followUpCandidate struct {
+	id      int64
+	title   string
+	profile storyProfile
+}
+
+// loadFollowUpCandidates reads the recent analyses a new one may follow up,
+// with their included facts. Analyses merged into another are left out; the
+// merged analysis stands for them.
+func (s *StoryThreadService) loadFollowUpCandidates(ctx context.Context, articleID int64, language string, createdAt time.Time) ([]followUpCandidate, error) {
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT a.id, COALESCE(a.headline_selected, ''), COALESCE(a.source_url, ''), COALESCE(a.raw_text, '')
+		FROM articles a
+		WHERE a.id <> ? AND a.merged_into IS NULL AND COALESCE(a.output_language, '') = ?
+			AND a.created_at >= ? AND a.created_at <= ?
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT ?`,
+		articleID,
+		language,
+		createdAt.Add(-followUpWindow),
+		createdAt,
+		followUpCandidates,
+	)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]followUpCandidate, 0)
+	headlines := make(map[int64]string)
+	for rows.Next() {
+		var (
+			candidate followUpCandidate
+			headline  string
+			sourceURL string
+			rawText   string
+		)
+		if err := rows.Scan(&candidate.id, &headline, &sourceURL, &rawText); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidate.title = buildAnalysisTitle(candidate.id, headline, sourceURL, rawText)
+		headlines[candidate.id] = headline
+		candidates = append(candidates, candidate)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	ids := make([]any, len(candidates))
+	for idx, candidate := range candidates {
+		ids[idx] = candidate.id
+	}
+	facts, err := listFactsByArticle(ctx, s.store, ids)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range candidates {
+		candidates[idx].profile = buildStoryProfile(headlines[candidates[idx].id], facts[candidates[idx].id])
+	}
+	return candidates, nil
+}
+
+// Get returns a thread with its analyses oldest first, each with the facts
+// it added to the story.
+func (s *StoryThreadService) Get(ctx context.Context, threadID int64) (models.StoryThread, error) {
+	var thread models.StoryThread
+	var title sql.NullString
+	err := s.store.QueryRowContext(ctx, "SELECT id, title, created_at, updated_at FROM story_threads WHERE id = ?", threadID).
+		Scan(&thread.ID, &title, &thread.CreatedAt, &thread.UpdatedAt)
+	if err != nil {
+		return models.StoryThread{}, err
+	}
+	thread.Title = title.String
+
+	rows, err := s.store.QueryContext(
+		ctx,
+		`SELECT a.id, COALESCE(a.headline_selected, ''), COALESCE(a.source_url, ''), COALESCE(a.raw_text, ''), COALESCE(a.status, 'draft'), sta.follows_article_id, sta.similarity, a.created_at
+		FROM story_thread_articles sta
+		JOIN articles a ON a.id = sta.article_id
+		WHERE sta.thread_id = ?
+		ORDER BY a.created_at ASC, a.id ASC`,
+		threadID,
+	)
+	if err != nil {
+		return models.StoryThread{}, err
+	}
+	thread.Analyses = make([]models.StoryThreadEntry, 0)
+	for rows.Next() {
+		var (
+			entry      models.StoryThreadEntry
+			headline   string
+			sourceURL  string
+			rawText    string
+			status     string
+			follows    sql.NullInt64
+			similarity sql.NullFloat64
+		)
+		if err := rows.Scan(&entry.ArticleID, &headline, &sourceURL, &rawText, &status, &follows, &similarity, &entry.CreatedAt); err != nil {
+			rows.Close()
+			return models.StoryThread{}, err
+		}
+		entry.Title = buildAnalysisTitle(entry.ArticleID, headline, sourceURL, rawText)
+		entry.Status = formatStatus(status)
+		entry.FollowsArticleID = nullInt64Pointer(follows)
+		if similarity.Valid {
+			entry.Similarity = &similarity.Float64
+		}
+		thread.Analyses = append(thread.Analyses, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.StoryThread{}, err
+	}
+	if len(thread.Analyses) == 0 {
+		return thread, nil
+	}
+
+	ids := make([]any, len(thread.Analyses))
+	for idx, entry := range thread.Analyses {
+		ids[idx] = entry.ArticleID
+	}
+	facts, err := listFactsByArticle(ctx, s.store, ids)
+	if err != nil {
+		return models.StoryThread{}, err
+	}
+
+	// A fact is new when it doesn't match one reported earlier in the
+	// thread, the way facts are matched across the sources of an analysis.
+	type seenFact struct{ tokens, numbers map[string]struct{} }
+	seen := make([]seenFact, 0)
+	for idx := range thread.Analyses {
+		newFacts := make([]string, 0)
+		for _, fact := range facts[thread.Analyses[idx].ArticleID] {
+			tokens, numbers := factTokens(fact)
+			known := false
+			for _, earlier := range seen {
+				if factSimilarity(tokens, numbers, earlier.tokens, earlier.numbers) >= factMatchThreshold {
+					known = true
+					break
+				}
+			}
+			if !known {
+				newFacts = append(newFacts, fact)
+			}
+			seen = append(seen, seenFact{tokens: tokens, numbers: numbers})
+		}
+		thread.Analyses[idx].NewFacts = newFacts
+	}
+	return thread, nil
+}
+
+func findThreadID(ctx context.Context, q repository.Querier, articleID int64) (*int64, error) {
+	var threadID int64
+	err := q.QueryRowContext(ctx, "SELECT thread_id FROM story_thread_articles WHERE article_id = ?", articleID).Scan(&threadID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &threadID, nil
+}
+
+// retitleThreads names each of threadIDs after its earliest analysis again,
+// as it was named when the thread began, once analyses have left it. Threads
+// left with none are deleted.
+func retitleThreads(ctx context.Context, q repository.Querier, threadIDs []int64) error {
+	for _, threadID := range threadIDs {
+		var (
+			articleID int64
+			headline  string
+			sourceURL string
+			rawText   string
+		)
+		err := q.QueryRowContext(
+			ctx,
+			`SELECT a.id, COALESCE(a.headline_selected, ''), COALESCE(a.source_url, ''), COALESCE(a.raw_text, '')
+			FROM story_thread_articles sta
+			JOIN articles a ON a.id = sta.article_id
+			WHERE sta.thread_id = ?
+			ORDER BY a.created_at ASC, a.id ASC
+			LIMIT 1`,
+			threadID,
+		).Scan(&articleID, &headline, &sourceURL, &rawText)
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := q.ExecContext(ctx, "DELETE FROM story_threads WHERE id = ?", threadID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		title := truncateRunes(buildAnalysisTitle(articleID, headline, sourceURL, rawText), 255)
+		if _, err := q.ExecContext(ctx, "UPDATE story_threads SET title = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", title, threadID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listFactsByArticle(ctx context.Context, store *repository.Store, articleIDs []any) (map[int64][]string, error) {
+	rows, err := store.QueryContext(
+		ctx,
+		"SELECT article_id, COALESCE(fact_text, '') FROM facts WHERE article_id IN ("+repository.Placeholders(len(articleIDs))+") AND deleted_at IS NULL AND COALESCE(is_included, true) = true ORDER BY article_id ASC, position ASC, id ASC",
+		articleIDs...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facts := make(map[int64][]string)
+	for rows.Next() {
+		var (
+			articleID int64
+			text      string
+		)
+		if err := rows.Scan(&articleID, &text); err != nil {
+			return nil, err
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			facts[articleID] = append(facts[articleID], text)
+		}
+	}
+	return facts, rows.Err()
+}
+
+func buildStoryProfile(headline string, facts []string) storyProfile {
+	text := strings.Join(append([]string{headline}, facts...), "\n")
+	tokens, _ := factTokens(text)
+	names := make(map[string]struct{})
+	for _, line := range append([]string{headline}, facts...) {
+		for _, name := range properNames(line) {
+			names[name] = struct{}{}
+		}
+	}
+	return storyProfile{tokens: tokens, names: names}
+}
+
+// properNames picks out capitalised words that don't start a sentence, as a
+// stand-in for the people, places and organisations a story is about. Day
+// and month names are skipped. Scripts without capitals yield none.
+func properNames(text string) []string {
+	names := make([]string, 0)
+	sentenceStart := true
+	for _, field := range strings.Fields(text) {
+		word := strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		first, _ := utf8.DecodeRuneInString(word)
+		if !sentenceStart && unicode.IsUpper(first) && utf8.RuneCountInString(word) >= 3 && !isAllCaps(word) && !isCalendarName(word) {
+			names = append(names, strings.ToLower(word))
+		}
+		last, _ := utf8.DecodeLastRuneInString(strings.TrimRight(field, `"'”’)`))
+		sentenceStart = isSentenceEnd(last)
+	}
+	return names
+}
+
+func isCalendarName(word string) bool {
+	if monthNumber(word) != 0 {
+		return true
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(word, day.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// storySimilarity weighs shared names and shared content words equally. When
+// both analyses name people or places, too few in common rules out a match,
+// so two stories about different fires aren't threaded together.
+func storySimilarity(a storyProfile, b storyProfile) float64 {
+	words := dice(a.tokens, b.tokens)
+	if len(a.names) == 0 || len(b.names) == 0 {
+		return words
+	}
+	shared := 0
+	for name := range a.names {
+		if _, ok := b.names[name]; ok {
+			shared++
+		}
+	}
+	if shared < followUpSharedNames {
+		return 0
+	}
+	return (dice(a.names, b.names) + words) / 2
+}
+
+func dice(a map[string]struct{}, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for key := range a {
+		if _, ok := b[key]; ok {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const maxStyleRules = 1000
+
+// StyleGuideService manages the newsroom's house style rules. Generated copy
+// is corrected by the rules set to fix, and an analysis can't be completed
+// while its article or selected titles break any rule.
+type StyleGuideService struct {
+	store *repository.Store
+}
+
+func NewStyleGuideService(database *sql.DB) *StyleGuideService {
+	return &StyleGuideService{
+		store: repository.New(database),
+	}
+}
+
+func (s *StyleGuideService) List(ctx context.Context) ([]models.StyleRule, error) {
+	return loadStyleRules(ctx, s.store)
+}
+
+func (s *StyleGuideService) Get(ctx context.Context, ruleID int64) (models.StyleRule, error) {
+	return scanStyleRule(s.store.QueryRowContext(ctx, styleRuleSelect+" WHERE id = ?", ruleID))
+}
+
+func (s *StyleGuideService) Create(ctx context.Context, rule models.StyleRule, updatedBy *int64) (models.StyleRule, error) {
+	rule, err := normalizeStyleRule(rule)
+	if err != nil {
+		return models.StyleRule{}, err
+	}
+	if err := s.ensureSingleSetting(ctx, rule.Kind, 0); err != nil {
+		return models.StyleRule{}, err
+	}
+
+	id, err := s.store.Insert(
+		ctx,
+		"INSERT INTO style_rules (kind, value, replacement, action, notes, updated_by) VALUES (?, ?, ?, ?, ?, ?)",
+		rule.Kind,
+		rule.Value,
+		nullString(rule.Replacement),
+		rule.Action,
+		nullString(rule.Notes),
+		updatedBy,
+	)
+	if err != nil {
+		return models.StyleRule{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *StyleGuideService) Update(ctx context.Context, ruleID int64, rule models.StyleRule, updatedBy *int64) (models.StyleRule, error) {
+	rule, err := normalizeStyleRule(rule)
+	if err != nil {
+		return models.StyleRule{}, err
+	}
+	if err := s.ensureSingleSetting(ctx, rule.Kind, ruleID); err != nil {
+		return models.StyleRule{}, err
+	}
+
+	result, err := s.store.ExecContext(
+		ctx,
+		"UPDATE style_rules SET kind = ?, value = ?, replacement = ?, action = ?, notes = ?, updated_by = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		rule.Kind,
+		rule.Value,
+		nullString(rule.Replacement),
+		rule.Action,
+		nullString(rule.Notes),
+		updatedBy,
+		ruleID,
+	)
+	if err != nil {
+		return models.StyleRule{}, err
+	}
+	if err := ensureRowsAffected(result); err != nil {
+		return models.StyleRule{}, err
+	}
+	return s.Get(ctx, ruleID)
+}
+
+func (s *StyleGuideService) Delete(ctx context.Context, ruleID int64) error {
+	result, err := s.store.ExecContext(ctx, "DELETE FROM style_rules WHERE id = ?", ruleID)
+	if err != nil {
+		return err
+	}
+	return ensureRowsAffected(result)
+}
+
+// Replace swaps the whole style guide for an uploaded set of rules.
+func (s *StyleGuideService) Replace(ctx context.Context, rules []models.StyleRule, updatedBy *int64) ([]models.StyleRule, error) {
+	if len(rules) > maxStyleRules {
+		return nil, invalidInputf("rules must be at most %d", maxStyleRules)
+	}
+
+	clean := make([]models.StyleRule, 0, len(rules))
+	settings := make(map[string]struct{})
+	for idx, rule := range rules {
+		rule, err := normalizeStyleRule(rule)
+		if err != nil {
+			return nil, invalidInputf("rule %d is invalid: %w", idx+1, err)
+		}
+		if rule.Kind != models.StyleRuleBannedWord {
+			if _, ok := settings[rule.Kind]; ok {
+				return nil, invalidInputf("%s rules must be unique", rule.Kind)
+			}
+			settings[rule.Kind] = struct{}{}
+		}
+		clean = append(clean, rule)
+	}
+
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM style_rules"); err != nil {
+			return err
+		}
+		for _, rule := range clean {
+			_, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO style_rules (kind, value, replacement, action, notes, updated_by) VALUES (?, ?, ?, ?, ?, ?)",
+				rule.Kind,
+				rule.Value,
+				nullString(rule.Replacement),
+				rule.Action,
+				nullString(rule.Notes),
+				updatedBy,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ctx)
+}
+
+// Check runs the style guide over a piece of copy, applying the fixes a
+// generated article or title would get.
+func (s *StyleGuideService) Check(ctx context.Context, field string, text string) (models.StyleCheckResult, error) {
+	field = strings.ToLower(strings.TrimSpace(field))
+	if field == "" {
+		field = styleFieldArticle
+	}
+	if field != styleFieldArticle && field != titleKindHeadline && field != titleKindStrapline {
+		return models.StyleCheckResult{}, invalidInput("field must be article, headline or strapline")
+	}
+	if strings.TrimSpace(text) == "" {
+		return models.StyleCheckResult{}, invalidInput("text is required")
+	}
+
+	rules, err := loadStyleRules(ctx, s.store)
+	if err != nil {
+		return models.StyleCheckResult{}, err
+	}
+	fixed, issues := applyStyleRules(rules, field, strings.TrimSpace(text), true)
+	return models.StyleCheckResult{Text: fixed, Issues: issues}, nil
+}
+
+// ensureSingleSetting keeps the style guide to one date format and one title
+// case. ruleID is the rule being updated, or 0 for a new one.
+func (s *StyleGuideService) ensureSingleSetting(ctx context.Context, kind string, ruleID int64) error {
+	if kind == models.StyleRuleBannedWord {
+		return nil
+	}
+	var existing int64
+	err := s.store.QueryRowContext(ctx, "SELECT id FROM style_rules WHERE kind = ? AND id <> ?", kind, ruleID).Scan(&existing)
+	switch {
+	case err == nil:
+		return invalidInputf("%s rules must be unique; update rule %d instead", kind, existing)
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	default:
+		return err
+	}
+}
+
+func normalizeStyleRule(rule models.StyleRule) (models.StyleRule, error) {
+	rule.Kind = strings.ToLower(strings.TrimSpace(rule.Kind))
+	if !containsString(models.StyleRuleKinds, rule.Kind) {
+		return models.StyleRule{}, invalidInput("kind must be banned-word, date-format or title-case")
+	}
+	rule.Action = strings.ToLower(strings.TrimSpace(rule.Action))
+	if rule.Action == "" {
+		rule.Action = models.StyleActionFlag
+	}
+	if rule.Action != models.StyleActionFlag && rule.Action != models.StyleActionFix && rule.Action != models.StyleActionRegenerate {
+		return models.StyleRule{}, invalidInput("action must be flag, fix or regenerate")
+	}
+	if rule.Action == models.StyleActionRegenerate && rule.Kind != models.StyleRuleBannedWord {
+		return models.StyleRule{}, invalidInput("only banned words can be regenerated")
+	}
+	rule.Value = strings.Join(strings.Fields(rule.Value), " ")
+	rule.Replacement = strings.TrimSpace(rule.Replacement)
+	rule.Notes = strings.TrimSpace(rule.Notes)
+
+	switch rule.Kind {
+	case models.StyleRuleBannedWord:
+		if rule.Value == "" {
+			return models.StyleRule{}, invalidInput("banned word is required")
+		}
+		if utf8.RuneCountInString(rule.Value) > 255 || utf8.RuneCountInString(rule.Replacement) > 255 {
+			return models.StyleRule{}, invalidInput("banned words and replacements must be at most 255 characters")
+		}
+		if rule.Action == models.StyleActionFix && rule.Replacement == "" {
+			return models.StyleRule{}, invalidInput("replacement is required to fix a banned word")
+		}
+	case models.StyleRuleDateFormat:
+		rule.Value = strings.ToLower(rule.Value)
+		if !containsString(models.DateFormats, rule.Value) {
+			return models.StyleRule{}, invalidInput("date format must be day-month-year, month-day-year or iso")
+		}
+		rule.Replacement = ""
+	case models.StyleRuleTitleCase:
+		rule.Value = strings.ToLower(rule.Value)
+		if !containsString(models.TitleCaseStyles, rule.Value) {
+			return models.StyleRule{}, invalidInput("title case must be sentence or title")
+		}
+		rule.Replacement = ""
+	}
+	return rule, nil
+}
+
+func loadStyleRules(ctx context.Context, q repository.Querier) ([]models.StyleRule, error) {
+	rows, err := q.QueryContext(ctx, styleRuleSelect+" ORDER BY kind ASC, value ASC, id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]models.StyleRule, 0)
+	for rows.Next() {
+		rule, err := scanStyleRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// checkCompletionStyle returns an error when the copy an analysis would be
+// completed with breaks the style guide. Non-nil overrides are values saved
+// in the same update.
+func checkCompletionStyle(ctx context.Context, q repository.Querier, rules []models.StyleRule, articleID int64, articleText *string, headline *string, strapline *string) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var current [3]string
+	err := q.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(article_text, ''), COALESCE(headline_selected, ''), COALESCE(strapline_selected, '') FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&current[0], &current[1], &current[2])
+	if err != nil {
+		return err
+	}
+	for idx, override := range []*string{articleText, headline, strapline} {
+		if override != nil {
+			current[idx] = strings.TrimSpace(*override)
+		}
+	}
+
+	issues := checkStyle(rules, styleFieldArticle, current[0])
+	issues = append(issues, checkStyle(rules, titleKindHeadline, current[1])...)
+	issues = append(issues, checkStyle(rules, titleKindStrapline, current[2])...)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	problems := make([]string, 0, 3)
+	for _, issue := range issues {
+		if len(problems) == cap(problems) {
+			break
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s", issue.Field, issue.Message))
+	}
+	summary := strings.Join(problems, "; ")
+	if extra := len(issues) - len(problems); extra > 0 {
+		summary += fmt.Sprintf("; and %d more", extra)
+	}
+	return invalidInputf("analysis %d must be clear of style guide violations before it is completed (%s)", articleID, summary)
+}
+
+const styleRuleSelect = "SELECT id, kind, value, COALESCE(replacement, ''), action, COALESCE(notes, ''), updated_by, updated_at FROM style_rules"
+
+func scanStyleRule(row rowScanner) (models.StyleRule, error) {
+	var (
+		rule      models.StyleRule
+		updatedBy sql.NullInt64
+		updatedAt sql.NullTime
+	)
+	if err := row.Scan(&rule.ID, &rule.Kind, &rule.Value, &rule.Replacement, &rule.Action, &rule.Notes, &updatedBy, &updatedAt); err != nil {
+		return models.StyleRule{}, err
+	}
+	rule.UpdatedBy = nullInt64Pointer(updatedBy)
+	if updatedAt.Valid {
+		rule.UpdatedAt = &updatedAt.Time
+	}
+	return rule, nil
+}
+
+// applyStyleGuide corrects freshly generated copy with the rules set to fix.
+// A style guide that can't be loaded leaves the copy as generated; the
+// completion check still applies.
+func (s *FactService) applyStyleGuide(ctx context.Context, articleText string, sections []models.ArticleSection, headlines []string, straplines []string) (string, []models.ArticleSection, []string, []string) {
+	rules, err := loadStyleRules(ctx, s.store)
+	if err != nil {
+		log.Printf("[style] failed to load style rules, skipping fixes: %v", err)
+		return articleText, sections, headlines, straplines
+	}
+	if len(rules) == 0 {
+		return articleText, sections, headlines, straplines
+	}
+
+	if len(sections) > 0 {
+		for idx := range sections {
+			sections[idx].Heading = fixStyle(rules, styleFieldArticle, sections[idx].Heading)
+			sections[idx].Body = fixStyle(rules, styleFieldArticle, sections[idx].Body)
+		}
+		articleText = renderArticleSections(sections)
+	} else {
+		articleText = fixStyle(rules, styleFieldArticle, articleText)
+	}
+
+	for idx, headline := range headlines {
+		headlines[idx] = fixStyle(rules, titleKindHeadline, headline)
+	}
+	for idx, strapline := range straplines {
+		straplines[idx] = fixStyle(rules, titleKindStrapline, strapline)
+	}
+	return articleText, sections, dedupeAndTrim(headlines), dedupeAndTrim(straplines)
+}
+
+type bannedTermsKey struct{}
+
+// withBannedTermFeedback prepares the retry of generated copy that used
+// terms a regenerate rule bans.
+func withBannedTermFeedback(ctx context.Context, terms []string) context.Context {
+	return context.WithValue(ctx, bannedTermsKey{}, terms)
+}
+
+// bannedTermConstraint is appended to a generation prompt on such a retry.
+func bannedTermConstraint(ctx context.Context) string {
+	terms, _ := ctx.Value(bannedTermsKey{}).([]string)
+	if len(terms) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(terms))
+	for idx, term := range terms {
+		quoted[idx] = fmt.Sprintf("%q", term)
+	}
+	return "\n\nWording: Your previous answer used " + strings.Join(quoted, ", ") + ", which the newsroom does not allow. Do not use them, or any variant of them."
+}
+
+// regenerateRules loads the banned words whose hits in generated copy are
+// written again. A style guide that can't be loaded skips the retry; the
+// completion check still applies.
+func (s *FactService) regenerateRules(ctx context.Context) []models.StyleRule {
+	rules, err := loadStyleRules(ctx, s.store)
+	if err != nil {
+		log.Printf("[style] failed to load style rules, skipping regeneration: %v", err)
+		return nil
+	}
+	regenerate := make([]models.StyleRule, 0)
+	for _, rule := range rules {
+		if rule.Action == models.StyleActionRegenerate {
+			regenerate = append(regenerate, rule)
+		}
+	}
+	return regenerate
+}
+
+// bannedTermsIn lists the banned words of rules that any of texts uses.
+func bannedTermsIn(rules []models.StyleRule, texts ...string) []string {
+	terms := make([]string, 0)
+	for _, rule := range rules {
+		if slices.ContainsFunc(texts, func(text string) bool { return len(findBannedWord(rule, text)) > 0 }) {
+			terms = append(terms, rule.Value)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"nanoheads/models"
+)
+
+const styleFieldArticle = "article"
+
+const monthNamePattern = `(january|february|march|april|may|june|july|august|september|october|november|december|jan|feb|mar|apr|jun|jul|aug|sept|sep|oct|nov|dec)\.?`
+
+var (
+	dayMonthYearPattern = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+` + monthNamePattern + `,?\s+(\d{4})\b`)
+	monthDayYearPattern = regexp.MustCompile(`(?i)\b` + monthNamePattern + `\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`)
+	isoDatePattern      = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+)
+
+var dateLayouts = map[string]string{
+	models.DateFormatDayMonthYear: "2 January 2006",
+	models.DateFormatMonthDayYear: "January 2, 2006",
+	models.DateFormatISO:          "2006-01-02",
+}
+
+// Words kept lower case inside a title-cased headline.
+var titleCaseMinorWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "as": {}, "at": {}, "but": {}, "by": {}, "for": {}, "from": {},
+	"in": {}, "nor": {}, "of": {}, "on": {}, "or": {}, "the": {}, "to": {}, "up": {}, "via": {}, "with": {},
+}
+
+// styleSpan is a violation found in a piece of text, with the text it should
+// be replaced by; fix is empty when the rule cannot correct it.
+type styleSpan struct {
+	start, end int
+	fix        string
+	message    string
+}
+
+// applyStyleRules checks text against the rules that apply to field. With fix
+// set, rules whose action is fix correct what they can, and only the
+// violations left are returned.
+func applyStyleRules(rules []models.StyleRule, field string, text string, fix bool) (string, []models.StyleIssue) {
+	issues := make([]models.StyleIssue, 0)
+	for _, rule := range rules {
+		fixing := fix && (rule.Action == models.StyleActionFix || rule.Action == models.StyleActionRegenerate)
+
+		if rule.Kind == models.StyleRuleTitleCase {
+			if field == styleFieldArticle {
+				continue
+			}
+			expected, message := checkTitleCase(rule.Value, text)
+			if message == "" {
+				continue
+			}
+			if fixing && expected != "" {
+				text = expected
+				if expected, message = checkTitleCase(rule.Value, text); message == "" {
+					continue
+				}
+			}
+			issues = append(issues, models.StyleIssue{
+				RuleID:     rule.ID,
+				Kind:       rule.Kind,
+				Field:      field,
+				Text:       text,
+				Message:    message,
+				Suggestion: expected,
+			})
+			continue
+		}
+
+		var spans []styleSpan
+		switch rule.Kind {
+		case models.StyleRuleBannedWord:
+			spans = findBannedWord(rule, text)
+		case models.StyleRuleDateFormat:
+			spans = findMisformattedDates(rule.Value, text)
+		}
+		if len(spans) == 0 {
+			continue
+		}
+
+		for _, span := range spans {
+			if fixing && span.fix != "" {
+				continue
+			}
+			issues = append(issues, models.StyleIssue{
+				RuleID:     rule.ID,
+				Kind:       rule.Kind,
+				Field:      field,
+				Text:       text[span.start:span.end],
+				Message:    span.message,
+				Suggestion: span.fix,
+			})
+		}
+		if fixing {
+			// Replace from the end so earlier offsets stay valid.
+			for idx := len(spans) - 1; idx >= 0; idx-- {
+				if span := spans[idx]; span.fix != "" {
+					text = text[:span.start] + span.fix + text[span.end:]
+				}
+			}
+		}
+	}
+	return text, issues
+}
+
+func checkStyle(rules []models.StyleRule, field string, text string) []models.StyleIssue {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	_, issues := applyStyleRules(rules, field, text, false)
+	return issues
+}
+
+func fixStyle(rules []models.StyleRule, field string, text string) string {
+	fixed, _ := applyStyleRules(rules, field, text, true)
+	return fixed
+}
+
+// collectStyleIssues checks each distinct value of a field.
+func collectStyleIssues(rules []models.StyleRule, field string, values ...string) []models.StyleIssue {
+	issues := make([]models.StyleIssue, 0)
+	for _, value := range dedupeStrings(values) {
+		issues = append(issues, checkStyle(rules, field, value)...)
+	}
+	return issues
+}
+
+func bannedWordPattern(phrase string) *regexp.Regexp {
+	words := strings.Fields(phrase)
+	for idx, word := range words {
+		words[idx] = regexp.QuoteMeta(word)
+	}
+	// RE2's \b only knows ASCII, so word boundaries are spelled out to work
+	// in every script.
+	return regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}])(` + strings.Join(words, `\s+`) + `)([^\p{L}\p{N}]|$)`)
+}
+
+func findBannedWord(rule models.StyleRule, text string) []styleSpan {
+	pattern := bannedWordPattern(rule.Value)
+	spans := make([]styleSpan, 0)
+	for offset := 0; offset < len(text); {
+		loc := pattern.FindStringSubmatchIndex(text[offset:])
+		if loc == nil {
+			break
+		}
+		start, end := offset+loc[4], offset+loc[5]
+		span := styleSpan{start: start, end: end, message: fmt.Sprintf("%q is banned by the style guide", rule.Value)}
+		if rule.Replacement != "" {
+			span.fix = matchLeadingCase(text[start:end], rule.Replacement)
+			span.message += fmt.Sprintf("; use %q", rule.Replacement)
+		}
+		spans = append(spans, span)
+		offset = end
+	}
+	return spans
+}
+
+// matchLeadingCase capitalises replacement when the text it replaces starts
+// with a capital, as at the start of a sentence.
+func matchLeadingCase(original string, replacement string) string {
+	first, _ := utf8.DecodeRuneInString(original)
+	if !unicode.IsUpper(first) {
+		return replacement
+	}
+	return capitalizeFirst(replacement)
+}
+
+func findMisformattedDates(format string, text string) []styleSpan {
+	layout, ok := dateLayouts[format]
+	if !ok {
+		return nil
+	}
+
+	type match struct {
+		start, end int
+		date       time.Time
+	}
+	matches := make([]match, 0)
+	collect := func(pattern *regexp.Regexp, parse func(groups []string) (time.Time, bool)) {
+		for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			groups := make([]string, 0, len(loc)/2)
+			for idx := 0; idx < len(loc); idx += 2 {
+				groups = append(groups, text[loc[idx]:loc[idx+1]])
+			}
+			if date, ok := parse(groups); ok {
+				matches = append(matches, match{start: loc[0], end: loc[1], date: date})
+			}
+		}
+	}
+	collect(dayMonthYearPattern, func(groups []string) (time.Time, bool) {
+		return buildDate(groups[3], monthNumber(groups[2]), groups[1])
+	})
+	collect(monthDayYearPattern, func(groups []string) (time.Time, bool) {
+		return buildDate(groups[3], monthNumber(groups[1]), groups[2])
+	})
+	collect(isoDatePattern, func(groups []string) (time.Time, bool) {
+		month, err := strconv.Atoi(groups[2])
+		if err != nil {
+			return time.Time{}, false
+		}
+		return buildDate(groups[1], month, groups[3])
+	})
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	spans := make([]styleSpan, 0)
+	lastEnd := -1
+	for _, m := range matches {
+		if m.start < lastEnd {
+			continue
+		}
+		lastEnd = m.end
+		expected := m.date.Format(layout)
+		if text[m.start:m.end] == expected {
+			continue
+		}
+		spans = append(spans, styleSpan{
+			start:   m.start,
+			end:     m.end,
+			fix:     expected,
+			message: fmt.Sprintf("dates must be written as %q", expected),
+		})
+	}
+	return spans
+}
+
+func monthNumber(name string) int {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for month := time.January; month <= time.December; month++ {
+		full := strings.ToLower(month.String())
+		if name == full || (len(name) >= 3 && strings.HasPrefix(full, name)) {
+			return int(month)
+		}
+	}
+	return 0
+}
+
+// buildDate rejects days that don't exist in the month, such as 31 June.
+func buildDate(year string, month int, day string) (time.Time, bool) {
+	y, err := strconv.Atoi(year)
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+	d, err := strconv.Atoi(day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	date := time.Date(y, time.Month(month), d, 0, 0, 0, 0, time.UTC)
+	if date.Day() != d || int(date.Month()) != month {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// checkTitleCase returns the title in the expected case and a message when
+// the title breaks the rule. Sentence case is only corrected at the start:
+// lowering the other words could break proper nouns, so an expected value is
+// not returned for them.
+func checkTitleCase(style string, title string) (string, string) {
+	clean := strings.TrimSpace(title)
+	words := strings.Fields(clean)
+	if len(words) == 0 {
+		return "", ""
+	}
+
+	switch style {
+	case models.TitleCaseTitle:
+		expected := make([]string, len(words))
+		for idx, word := range words {
+			expected[idx] = titleCaseWord(word, idx == 0 || idx == len(words)-1)
+		}
+		if joined := strings.Join(expected, " "); joined != strings.Join(words, " ") {
+			return joined, "must be in title case"
+		}
+	case models.TitleCaseSentence:
+		first, _ := utf8.DecodeRuneInString(clean)
+		if unicode.IsLower(first) {
+			return capitalizeFirst(clean), "must be in sentence case"
+		}
+		if looksTitleCased(words[1:]) {
+			return "", "must be in sentence case"
+		}
+	}
+	return "", ""
+}
+
+func titleCaseWord(word string, edge bool) string {
+	if strings.ContainsFunc(word, unicode.IsDigit) || isAllCaps(word) {
+		return word
+	}
+	bare := strings.ToLower(strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }))
+	if _, minor := titleCaseMinorWords[bare]; minor && !edge {
+		return strings.ToLower(word)
+	}
+	return capitalizeFirst(word)
+}
+
+// looksTitleCased reports whether every longer word after the first is
+// capitalised, which in a sentence-case headline is unlikely to be only
+// proper nouns.
+func looksTitleCased(words []string) bool {
+	counted := 0
+	for _, word := range words {
+		if utf8.RuneCountInString(word) < 4 || strings.ContainsFunc(word, unicode.IsDigit) {
+			continue
+		}
+		first, _ := utf8.DecodeRuneInString(strings.TrimLeftFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }))
+		if !unicode.IsUpper(first) {
+			return false
+		}
+		counted++
+	}
+	return counted >= 3
+}
+
+// capitalizeFirst upper-cases the first letter, skipping leading quotes and
+// other punctuation.
+func capitalizeFirst(value string) string {
+	for idx, r := range value {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		return value[:idx] + string(unicode.ToUpper(r)) + value[idx+utf8.RuneLen(r):]
+	}
+	return value
+}
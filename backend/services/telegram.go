@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	telegramAPI = "https://api.telegram.org/bot"
+
+	telegramCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	telegramCodeLength   = 8
+	telegramCodeLifetime = 15 * time.Minute
+	// Telegram refuses messages over 4096 characters; replies are split
+	// into parts shorter than that.
+	maxTelegramMessage = 3500
+)
+
+const telegramHelp = "Send me a link, or paste or forward an article's text, and I'll reply with its facts, open questions and headline options.\n\n" +
+	"/link <code> links your NanoHeads account, with a code from the admin app.\n" +
+	"/lang <language> sets the language of your analyses; /lang default goes back to the usual one.\n" +
+	"/unlink disconnects this Telegram account."
+
+type TelegramService struct {
+	store *repository.Store
+	jobs  *JobService
+	facts *FactService
+}
+
+func NewTelegramService(database *sql.DB) *TelegramService {
+	return &TelegramService{
+		store: repository.New(database),
+		jobs:  NewJobService(database),
+		facts: NewFactService(database),
+	}
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	From      *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"`
+	} `json:"chat"`
+	Text    string `json:"text"`
+	Caption string `json:"caption"`
+}
+
+// telegramPayload is a message queued for analysis, with where to reply.
+type telegramPayload struct {
+	ChatID    int64  `json:"chatId"`
+	MessageID int64  `json:"messageId"`
+	UserID    int64  `json:"userId"`
+	Text      string `json:"text,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Language  string `json:"language,omitempty"`
+}
+
+// CreateLinkCode starts linking a Telegram account to userID. A new code
+// replaces any the user hasn't used yet.
+func (s *TelegramService) CreateLinkCode(ctx context.Context, userID *int64) (models.TelegramLinkCode, error) {
+	if config.Current().TelegramBotToken == "" {
+		return models.TelegramLinkCode{}, notConfigured("TELEGRAM_BOT_TOKEN is required for the Telegram bot")
+	}
+	if userID == nil {
+		return models.TelegramLinkCode{}, invalidInput("linking Telegram needs a signed-in user")
+	}
+
+	buf := make([]byte, telegramCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return models.TelegramLinkCode{}, fmt.Errorf("generate link code: %w", err)
+	}
+	code := make([]byte, telegramCodeLength)
+	for idx, b := range buf {
+		code[idx] = telegramCodeAlphabet[int(b)%len(telegramCodeAlphabet)]
+	}
+	link := models.TelegramLinkCode{
+		Code:      string(code),
+		ExpiresAt: time.Now().UTC().Add(telegramCodeLifetime).Truncate(time.Second),
+	}
+
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM telegram_links WHERE user_id = ? AND telegram_user_id IS NULL", *userID); err != nil {
+			return err
+		}
+		_, err := tx.Insert(ctx, "INSERT INTO telegram_links (user_id, code_hash, code_expires_at) VALUES (?, ?, ?)", *userID, hashAPIKey(link.Code), link.ExpiresAt)
+		return err
+	})
+	return link, err
+}
+
+func (s *TelegramService) ListLinks(ctx context.Context, userID *int64) ([]models.TelegramLink, error) {
+	links := make([]models.TelegramLink, 0)
+	if userID == nil {
+		return links, nil
+	}
+	rows, err := s.store.QueryContext(
+		ctx,
+		"SELECT id, telegram_user_id, COALESCE(telegram_username, ''), COALESCE(language, ''), linked_at FROM telegram_links WHERE user_id = ? AND telegram_user_id IS NOT NULL ORDER BY linked_at DESC, id DESC",
+		*userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			link     models.TelegramLink
+			linkedAt sql.NullTime
+		)
+		if err := rows.Scan(&link.ID, &link.TelegramUserID, &link.Username, &link.Language, &linkedAt); err != nil {
+			return nil, err
+		}
+		if linkedAt.Valid {
+			link.LinkedAt = &linkedAt.Time
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// Unlink disconnects one of the user's Telegram accounts.
+func (s *TelegramService) Unlink(ctx context.Context, userID *int64, linkID int64) error {
+	if userID == nil {
+		return sql.ErrNoRows
+	}
+	result, err := s.store.ExecContext(ctx, "DELETE FROM telegram_links WHERE id = ? AND user_id = ? AND telegram_user_id IS NOT NULL", linkID, *userID)
+	if err != nil {
+		return err
+	}
+	return ensureRowsAffected(result)
+}
+
+// HandleUpdate answers a message sent to the bot. Only private chats are
+// answered, since a link stands for one person. Messages that aren't
+// commands are queued for analysis on behalf of the linked user, who gets
+// the result as a reply once it is done.
+func (s *TelegramService) HandleUpdate(ctx context.Context, body []byte) error {
+	var update telegramUpdate
+	if err := json.Unmarshal(body, &update); err != nil {
+		return invalidInput("invalid Telegram update")
+	}
+	message := update.Message
+	if message == nil || message.From == nil || message.Chat.Type != "private" {
+		return nil
+	}
+	reply := func(text string) {
+		s.send(ctx, message.Chat.ID, message.MessageID, text)
+	}
+
+	text := strings.TrimSpace(message.Text)
+	if text == "" {
+		text = strings.TrimSpace(message.Caption)
+	}
+	if text == "" {
+		reply("Send me a link or an article's text.")
+		return nil
+	}
+	command, argument := telegramCommand(text)
+	switch command {
+	case "start", "help":
+		reply(html.EscapeString(telegramHelp))
+		return nil
+	case "link":
+		reply(s.link(ctx, message.From.ID, message.From.Username, argument))
+		return nil
+	}
+
+	var (
+		linkID   int64
+		userID   int64
+		language string
+	)
+	err := s.store.QueryRowContext(ctx, "SELECT id, user_id, COALESCE(language, '') FROM telegram_links WHERE telegram_user_id = ?", message.From.ID).Scan(&linkID, &userID, &language)
+	if errors.Is(err, sql.ErrNoRows) {
+		reply("This Telegram account isn't linked yet. Get a code from the NanoHeads admin app and send it here as /link &lt;code&gt;.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "":
+	case "lang":
+		reply(s.setLanguage(ctx, linkID, language, argument))
+		return nil
+	case "unlink":
+		if _, err := s.store.ExecContext(ctx, "DELETE FROM telegram_links WHERE id = ?", linkID); err != nil {
+			return err
+		}
+		reply("This Telegram account is no longer linked.")
+		return nil
+	default:
+		reply("I don't know that command. Send /help to see what I can do.")
+		return nil
+	}
+
+	var allowed int
+	err = s.store.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM users u JOIN role_permissions rp ON rp.role_id = u.role_id
+		WHERE u.id = ? AND COALESCE(u.is_active, true) = true AND rp.permission = ?`,
+		userID,
+		models.PermissionEditAnalyses,
+	).Scan(&allowed)
+	if err != nil {
+		return err
+	}
+	if allowed == 0 {
+		reply("Your NanoHeads account can't create analyses.")
+		return nil
+	}
+
+	payload := telegramPayload{ChatID: message.Chat.ID, MessageID: message.MessageID, UserID: userID, Language: language}
+	payload.Text, payload.URL = messageInput(text)
+	// The message waits in the jobs table, so it is masked before it is
+	// queued, as clips are.
+	if config.Current().PIIRedaction == "mask" {
+		payload.Text, _ = maskPII(payload.Text)
+	}
+	if _, err := s.jobs.Enqueue(ctx, models.JobTypeTelegram, payload, &userID); err != nil {
+		reply("I couldn't start the analysis. Please try again in a moment.")
+		return err
+	}
+	reply("Analysing… I'll reply here when it's done.")
+	return nil
+}
+
+func (s *TelegramService) link(ctx context.Context, telegramUserID int64, username string, code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return "Send the code from the NanoHeads admin app as /link &lt;code&gt;."
+	}
+	var linkID int64
+	err := s.store.QueryRowContext(
+		ctx,
+		"SELECT id FROM telegram_links WHERE code_hash = ? AND code_expires_at > ? AND telegram_user_id IS NULL",
+		hashAPIKey(code),
+		time.Now().UTC(),
+	).Scan(&linkID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "That code is unknown or has expired. Get a new one from the NanoHeads admin app."
+	}
+	if err != nil {
+		log.Printf("[telegram] failed to look up a link code: %v", err)
+		return "Linking failed. Please try again in a moment."
+	}
+
+	// An account linked before moves to the user who sent the new code.
+	err = s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM telegram_links WHERE telegram_user_id = ?", telegramUserID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			"UPDATE telegram_links SET telegram_user_id = ?, telegram_username = ?, code_hash = NULL, code_expires_at = NULL, linked_at = CURRENT_TIMESTAMP WHERE id = ?",
+			telegramUserID,
+			nullString(username),
+			linkID,
+		)
+		return err
+	})
+	if err != nil {
+		log.Printf("[telegram] failed to link account %d: %v", telegramUserID, err)
+		return "Linking failed. Please try again in a moment."
+	}
+	return "Linked. Send me a link or an article's text to analyse it."
+}
+
+func (s *TelegramService) setLanguage(ctx context.Context, linkID int64, current string, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if current == "" {
+			return "Your analyses are in the default language. Send /lang &lt;language&gt; to change it."
+		}
+		return "Your analyses are in " + html.EscapeString(current) + "."
+	}
+
+	var language sql.NullString
+	if !strings.EqualFold(value, "default") {
+		resolved, err := resolveLanguage(ctx, s.store, value)
+		if err != nil {
+			return html.EscapeString(err.Error())
+		}
+		language = sql.NullString{String: resolved.Name, Valid: true}
+	}
+	if _, err := s.store.ExecContext(ctx, "UPDATE telegram_links SET language = ? WHERE id = ?", language, linkID); err != nil {
+		log.Printf("[telegram] failed to set the language of link %d: %v", linkID, err)
+		return "Changing the language failed. Please try again in a moment."
+	}
+	if !language.Valid {
+		return "Your analyses are back to the default language."
+	}
+	return "Your analyses will be in " + html.EscapeString(language.String) + "."
+}
+
+// Analyse runs a queued message through the pipeline and replies with the
+// facts, open questions and headline options.
+func (s *TelegramService) Analyse(ctx context.Context, payload telegramPayload, report func(int, int)) (models.PhaseOneResponse, error) {
+	report(0, 1)
+	userID := payload.UserID
+	response, err := s.facts.RunPhaseOne(ctx, models.PhaseOneInput{
+		Text:     payload.Text,
+		URL:      payload.URL,
+		Language: payload.Language,
+
+		Submission: &models.Submission{
+			SubmittedBy: &userID,
+			Channel:     models.SubmissionChannelTelegram,
+			Params:      map[string]any{"url": payload.URL, "language": payload.Language},
+		},
+	})
+	if err != nil {
+		s.send(ctx, payload.ChatID, payload.MessageID, "The analysis failed: "+html.EscapeString(truncateRunes(redactSensitive(err.Error()), 300)))
+		return models.PhaseOneResponse{}, err
+	}
+
+	headlines, err := listTexts(ctx, s.store, "SELECT headline_text FROM headlines WHERE article_id = ? ORDER BY id", response.ArticleID)
+	if err != nil {
+		log.Printf("[telegram] failed to load the headlines of article %d: %v", response.ArticleID, err)
+	}
+	for _, part := range telegramResult(response, headlines, analysisLink(config.Current(), response.ArticleID)) {
+		s.send(ctx, payload.ChatID, payload.MessageID, part)
+	}
+	report(1, 1)
+	return response, nil
+}
+
+// send replies in a chat. Failures are logged; the bot has no one else to
+// tell.
+func (s *TelegramService) send(ctx context.Context, chatID int64, replyTo int64, text string) {
+	cfg := config.Current()
+	if cfg.TelegramBotToken == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.WebhookTimeout.Duration)
+	defer cancel()
+
+	message := map[string]any{
+		"chat_id":              chatID,
+		"text":                 text,
+		"parse_mode":           "HTML",
+		"link_preview_options": map[string]any{"is_disabled": true},
+	}
+	if replyTo > 0 {
+		message["reply_parameters"] = map[string]any{"message_id": replyTo, "allow_sending_without_reply": true}
+	}
+	// postWebhook keeps the URL, and so the bot token, out of its errors.
+	if err := postWebhook(ctx, telegramAPI+cfg.TelegramBotToken+"/sendMessage", message); err != nil {
+		log.Printf("[telegram] failed to reply in chat %d: %v", chatID, err)
+	}
+}
+
+// telegramCommand splits "/cmd@bot argument" into "cmd" and the argument;
+// text that isn't a command gives an empty command.
+func telegramCommand(text string) (string, string) {
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	command, argument, _ := strings.Cut(text[1:], " ")
+	command, _, _ = strings.Cut(command, "@")
+	return strings.ToLower(command), strings.TrimSpace(argument)
+}
+
+// telegramResult formats an analysis as one or more messages.
+func telegramResult(response models.PhaseOneResponse, headlines []string, link string) []string {
+	lines := []string{"<b>Facts</b>"}
+	for _, fact := range response.Facts {
+		lines = append(lines, "• "+html.EscapeString(fact))
+	}
+	if len(response.Gaps) > 0 {
+		lines = append(lines, "", "<b>Open questions</b>")
+		for _, gap := range response.Gaps {
+			lines = append(lines, "• "+html.EscapeString(gap))
+		}
+	}
+	if len(headlines) > 0 {
+		lines = append(lines, "", "<b>Headline options</b>")
+		for idx, headline := range headlines {
+			lines = append(lines, fmt.Sprintf("%d. %s", idx+1, html.EscapeString(headline)))
+		}
+	}
+	footer := fmt.Sprintf("Analysis #%d", response.ArticleID)
+	if link != "" {
+		footer = `<a href="` + html.EscapeString(link) + `">` + footer + "</a>"
+	}
+	lines = append(lines, "", footer)
+
+	parts := make([]string, 0, 1)
+	var part strings.Builder
+	for _, line := range lines {
+		line = truncateRunes(line, maxTelegramMessage)
+		if part.Len() > 0 && len([]rune(part.String()))+len([]rune(line))+1 > maxTelegramMessage {
+			parts = append(parts, strings.TrimSpace(part.String()))
+			part.Reset()
+		}
+		part.WriteString(line)
+		part.WriteString("\n")
+	}
+	if part.Len() > 0 {
+		parts = append(parts, strings.TrimSpace(part.String()))
+	}
+	return parts
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+var timelineDatePattern = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?$`)
+
+var timelineDateLayouts = map[int]string{4: "2006", 7: "2006-01", 10: "2006-01-02"}
+
+// normalizeTimelineDate keeps a date only in one of the forms timeline
+// events use, and only when it exists in the calendar.
+func normalizeTimelineDate(value string) string {
+	value = strings.TrimSpace(value)
+	if !timelineDatePattern.MatchString(value) {
+		return ""
+	}
+	if _, err := time.Parse(timelineDateLayouts[len(value)], value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// extractTimeline lists the dated events in the source. A timeline is an
+// extra, so failures are logged and the analysis goes on without one.
+func (s *FactService) extractTimeline(ctx context.Context, text string, language string) []models.TimelineEvent {
+	events, err := s.ai.ExtractTimeline(ctx, text, language)
+	if err != nil {
+		log.Printf("[timeline] extraction failed: %v", err)
+		return nil
+	}
+	return events
+}
+
+// translateTimeline translates the event descriptions. Dates and source
+// sentences are quotes from the source and stay as they are.
+func (s *FactService) translateTimeline(ctx context.Context, events []models.TimelineEvent, language string, glossary models.Glossary) ([]models.TimelineEvent, error) {
+	if len(events) == 0 {
+		return events, nil
+	}
+	texts := make([]string, len(events))
+	for idx, event := range events {
+		texts[idx] = event.Event
+	}
+	translated, err := s.translateList(ctx, texts, language, glossary)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.TimelineEvent, len(events))
+	for idx, event := range events {
+		if idx < len(translated) && strings.TrimSpace(translated[idx]) != "" {
+			event.Event = translated[idx]
+		}
+		out[idx] = event
+	}
+	return out, nil
+}
+
+func insertTimelineEvents(ctx context.Context, tx *repository.Tx, articleID int64, events []models.TimelineEvent) error {
+	for idx, event := range events {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO timeline_events (article_id, position, event_date, date_text, event_text, source_sentence) VALUES (?, ?, ?, ?, ?, ?)",
+			articleID,
+			idx+1,
+			nullString(event.Date),
+			nullString(event.DateText),
+			event.Event,
+			nullString(event.SourceSentence),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listTimelineEvents(ctx context.Context, store *repository.Store, articleID int64) ([]models.TimelineEvent, error) {
+	rows, err := store.QueryContext(
+		ctx,
+		"SELECT id, position, COALESCE(event_date, ''), COALESCE(date_text, ''), event_text, COALESCE(source_sentence, '') FROM timeline_events WHERE article_id = ? ORDER BY position ASC, id ASC",
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.TimelineEvent, 0)
+	for rows.Next() {
+		var event models.TimelineEvent
+		if err := rows.Scan(&event.ID, &event.Position, &event.Date, &event.DateText, &event.Event, &event.SourceSentence); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// mergeTimelines unions the timelines of merged analyses. Events reported by
+// more than one of them are kept once, and dated events are put in order
+// with undated ones after them.
+func mergeTimelines(timelines ...[]models.TimelineEvent) []models.TimelineEvent {
+	merged := make([]models.TimelineEvent, 0)
+	seen := make(map[string]struct{})
+	for _, timeline := range timelines {
+		for _, event := range timeline {
+			key := event.Date + "|" + strings.ToLower(strings.Join(strings.Fields(event.Event), " "))
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			event.ID = 0
+			merged = append(merged, event)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Date == "" || merged[j].Date == "" {
+			return merged[i].Date != "" && merged[j].Date == ""
+		}
+		return merged[i].Date < merged[j].Date
+	})
+	for idx := range merged {
+		merged[idx].Position = idx + 1
+	}
+	return merged
+}
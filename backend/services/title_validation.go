@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+const (
+	titleKindHeadline  = "headline"
+	titleKindStrapline = "strapline"
+)
+
+const titleEndingPunctuation = ".,;:!?।"
+
+func titleLimits(kind string) (int, int) {
+	rules := config.Current().Titles
+	if kind == titleKindStrapline {
+		return rules.StraplineMaxWords, rules.StraplineMaxChars
+	}
+	return rules.HeadlineMaxWords, rules.HeadlineMaxChars
+}
+
+func validateTitle(kind string, text string) []string {
+	clean := strings.TrimSpace(text)
+	if clean == "" {
+		return nil
+	}
+
+	maxWords, maxChars := titleLimits(kind)
+	problems := make([]string, 0, 4)
+
+	if words := len(strings.Fields(clean)); maxWords > 0 && words > maxWords {
+		problems = append(problems, fmt.Sprintf("has %d words (max %d)", words, maxWords))
+	}
+	if chars := len([]rune(clean)); maxChars > 0 && chars > maxChars {
+		problems = append(problems, fmt.Sprintf("has %d characters (max %d)", chars, maxChars))
+	}
+
+	runes := []rune(clean)
+	if strings.ContainsRune(titleEndingPunctuation, runes[len(runes)-1]) {
+		problems = append(problems, "must not end with punctuation")
+	}
+	if isAllCaps(clean) {
+		problems = append(problems, "must not be ALL CAPS")
+	}
+
+	return problems
+}
+
+func validateManualTitle(kind string, text string) error {
+	problems := validateTitle(kind, text)
+	if len(problems) == 0 {
+		return nil
+	}
+	return invalidInputf("%s is invalid: %s", kind, strings.Join(problems, "; "))
+}
+
+// normalizeGeneratedTitles strips trailing punctuation the model tends to add
+// and drops options that still break the layout rules, keeping the originals
+// only when nothing valid is left so the editor still has something to fix.
+func normalizeGeneratedTitles(kind string, values []string) []string {
+	cleaned := make([]string, 0, len(values))
+	valid := make([]string, 0, len(values))
+	for _, value := range values {
+		clean := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(value), titleEndingPunctuation))
+		if clean == "" {
+			continue
+		}
+		cleaned = append(cleaned, clean)
+		if len(validateTitle(kind, clean)) == 0 {
+			valid = append(valid, clean)
+		}
+	}
+
+	if len(valid) > 0 {
+		return dedupeAndTrim(valid)
+	}
+	return dedupeAndTrim(cleaned)
+}
+
+func collectTitleIssues(kind string, values ...string) []models.TitleIssue {
+	issues := make([]models.TitleIssue, 0)
+	for _, value := range dedupeStrings(values) {
+		problems := validateTitle(kind, value)
+		if len(problems) == 0 {
+			continue
+		}
+		issues = append(issues, models.TitleIssue{
+			Kind:     kind,
+			Text:     value,
+			Problems: problems,
+		})
+	}
+	return issues
+}
+
+func isAllCaps(value string) bool {
+	cased := 0
+	for _, r := range value {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsUpper(r) {
+			cased++
+		}
+	}
+	return cased >= 4
+}
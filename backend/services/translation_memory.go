@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"nanoheads/config"
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+// translateList translates items through the translation memory: strings
+// already translated into language under the same glossary version are
+// reused and only the rest go to the model. Memory entries are keyed by
+// glossary version, so a glossary edit never serves translations made with the
+// old terms.
+func (s *FactService) translateList(ctx context.Context, items []string, language string, glossary models.Glossary) ([]string, error) {
+	if !config.Current().TranslationMemory || strings.EqualFold(strings.TrimSpace(language), englishLanguage.Name) {
+		return s.ai.TranslateList(ctx, items, language, glossary)
+	}
+
+	clean := make([]string, 0, len(items))
+	for _, item := range items {
+		if text := strings.TrimSpace(item); text != "" {
+			clean = append(clean, text)
+		}
+	}
+	if len(clean) == 0 {
+		return s.ai.TranslateList(ctx, items, language, glossary)
+	}
+
+	remembered, err := lookupTranslationMemory(ctx, s.store, language, glossary.Version, clean)
+	if err != nil {
+		log.Printf("[translation-memory] lookup failed, translating everything: %v", err)
+		return s.ai.TranslateList(ctx, items, language, glossary)
+	}
+
+	missing := make([]string, 0, len(clean))
+	queued := make(map[string]struct{}, len(clean))
+	for _, text := range clean {
+		if _, ok := remembered[text]; ok {
+			continue
+		}
+		if _, ok := queued[text]; ok {
+			continue
+		}
+		queued[text] = struct{}{}
+		missing = append(missing, text)
+	}
+
+	if len(missing) > 0 {
+		translated, err := s.ai.TranslateList(ctx, missing, language, glossary)
+		if err != nil {
+			return nil, err
+		}
+		if len(translated) != len(missing) {
+			return nil, fmt.Errorf("translation returned %d lines for %d items", len(translated), len(missing))
+		}
+		fresh := make(map[string]string, len(missing))
+		for idx, text := range missing {
+			fresh[text] = translated[idx]
+			remembered[text] = translated[idx]
+		}
+		s.rememberTranslations(ctx, language, glossary.Version, fresh)
+	}
+
+	out := make([]string, len(clean))
+	for idx, text := range clean {
+		out[idx] = remembered[text]
+	}
+	if reused := len(clean) - len(missing); reused > 0 {
+		log.Printf("[translation-memory] reused %d of %d %s lines", reused, len(clean), language)
+	}
+	return out, nil
+}
+
+// translateText is translateList for a single paragraph.
+func (s *FactService) translateText(ctx context.Context, text string, language string, glossary models.Glossary) (string, error) {
+	clean := strings.TrimSpace(text)
+	if !config.Current().TranslationMemory || clean == "" || strings.EqualFold(strings.TrimSpace(language), englishLanguage.Name) {
+		return s.ai.TranslateText(ctx, text, language, glossary)
+	}
+
+	remembered, err := lookupTranslationMemory(ctx, s.store, language, glossary.Version, []string{clean})
+	if err != nil {
+		log.Printf("[translation-memory] lookup failed, translating: %v", err)
+	}
+	if translated, ok := remembered[clean]; ok {
+		log.Printf("[translation-memory] reused %s paragraph", language)
+		return translated, nil
+	}
+
+	translated, err := s.ai.TranslateText(ctx, clean, language, glossary)
+	if err != nil {
+		return "", err
+	}
+	s.rememberTranslations(ctx, language, glossary.Version, map[string]string{clean: translated})
+	return translated, nil
+}
+
+func translationHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupTranslationMemory returns stored translations by source text and
+// counts the hits. Entries whose source text differs from the input despite a
+// matching hash are ignored.
+func lookupTranslationMemory(ctx context.Context, store *repository.Store, language string, glossaryVersion int, texts []string) (map[string]string, error) {
+	hashes := make([]any, 0, len(texts))
+	seen := make(map[string]struct{}, len(texts))
+	for _, text := range texts {
+		hash := translationHash(text)
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+		seen[hash] = struct{}{}
+		hashes = append(hashes, hash)
+	}
+
+	query := `
+		SELECT id, source_text, translated_text
+		FROM translation_memory
+		WHERE target_language = ? AND glossary_version = ? AND source_hash IN (` + repository.Placeholders(len(hashes)) + `)`
+	args := append([]any{language, glossaryVersion}, hashes...)
+	rows, err := store.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]string, len(texts))
+	ids := make([]any, 0, len(texts))
+	for rows.Next() {
+		var (
+			id         int64
+			source     string
+			translated string
+		)
+		if err := rows.Scan(&id, &source, &translated); err != nil {
+			return nil, err
+		}
+		source = strings.TrimSpace(source)
+		if _, ok := seen[translationHash(source)]; !ok || strings.TrimSpace(translated) == "" {
+			continue
+		}
+		found[source] = translated
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 {
+		query := "UPDATE translation_memory SET hit_count = hit_count + 1, last_used_at = CURRENT_TIMESTAMP WHERE id IN (" + repository.Placeholders(len(ids)) + ")"
+		if _, err := store.ExecContext(ctx, query, ids...); err != nil {
+			log.Printf("[translation-memory] failed to count hits: %v", err)
+		}
+	}
+	return found, nil
+}
+
+// rememberTranslations stores fresh translations. It never fails the caller;
+// a lost entry only costs a repeat translation.
+func (s *FactService) rememberTranslations(ctx context.Context, language string, glossaryVersion int, translations map[string]string) {
+	query, err := repository.Upsert(
+		s.store.Driver(),
+		"translation_memory",
+		[]string{"source_hash", "target_language", "glossary_version", "source_text", "translated_text"},
+		[]string{"source_hash", "target_language", "glossary_version"},
+		[]string{"translated_text"},
+		"last_used_at = CURRENT_TIMESTAMP",
+	)
+	if err != nil {
+		log.Printf("[translation-memory] %v", err)
+		return
+	}
+
+	for source, translated := range translations {
+		clean := strings.TrimSpace(translated)
+		if clean == "" {
+			continue
+		}
+		if _, err := s.store.ExecContext(ctx, query, translationHash(source), language, glossaryVersion, source, clean); err != nil {
+			log.Printf("[translation-memory] failed to store %s translation: %v", language, err)
+			return
+		}
+	}
+}
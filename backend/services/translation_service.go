@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"nanoheads/models"
+	"nanoheads/repository"
+)
+
+const (
+	translationEntityFact    = "fact"
+	translationEntityGap     = "gap"
+	translationEntityArticle = "article"
+)
+
+type translationRecord struct {
+	id         int64
+	articleID  int64
+	entityType string
+	entityID   int64
+	source     string
+	translated string
+}
+
+type retranslatePayload struct {
+	Language string `json:"language"`
+}
+
+// EnqueueRetranslation queues a re-translation of every stored translation for
+// language that predates the current glossary version.
+func EnqueueRetranslation(ctx context.Context, jobs *JobService, language string, createdBy *int64) (models.Job, error) {
+	cleanLanguage, err := normalizeGlossaryLanguage(ctx, jobs.store, language)
+	if err != nil {
+		return models.Job{}, err
+	}
+	return jobs.Enqueue(ctx, models.JobTypeRetranslate, retranslatePayload{Language: cleanLanguage}, createdBy)
+}
+
+// RetranslateStale re-translates stored translations made with an older
+// glossary. Text an editor has changed since it was translated is left alone;
+// only the stored translation is refreshed.
+func (s *FactService) RetranslateStale(ctx context.Context, language string, report func(done int, total int)) (models.RetranslationResult, error) {
+	if err := s.applyRuntimeAISettings(ctx); err != nil {
+		return models.RetranslationResult{}, err
+	}
+
+	glossary, err := s.glossary.GetGlossary(ctx, language)
+	if err != nil {
+		return models.RetranslationResult{}, err
+	}
+
+	records, err := listStaleTranslations(ctx, s.store, glossary.Language, glossary.Version)
+	if err != nil {
+		return models.RetranslationResult{}, err
+	}
+
+	result := models.RetranslationResult{
+		Language:        glossary.Language,
+		GlossaryVersion: glossary.Version,
+		Total:           len(records),
+	}
+	report(0, result.Total)
+
+	done := 0
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].articleID == records[start].articleID {
+			end++
+		}
+		group := records[start:end]
+
+		updated, skipped, err := s.retranslateGroup(ctx, glossary, group)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			log.Printf("[retranslate] article %d failed: %v", group[0].articleID, err)
+			result.Failed += len(group)
+		}
+		result.Updated += updated
+		result.SkippedEdited += skipped
+
+		done += len(group)
+		report(done, result.Total)
+		start = end
+	}
+
+	return result, nil
+}
+
+func (s *FactService) retranslateGroup(ctx context.Context, glossary models.Glossary, group []translationRecord) (int, int, error) {
+	listRecords := make([]translationRecord, 0, len(group))
+	listSources := make([]string, 0, len(group))
+	translated := make(map[int64]string, len(group))
+
+	for _, record := range group {
+		if record.entityType == translationEntityArticle {
+			text, err := s.translateText(ctx, record.source, glossary.Language, glossary)
+			if err != nil {
+				return 0, 0, err
+			}
+			translated[record.id] = text
+			continue
+		}
+		listRecords = append(listRecords, record)
+		listSources = append(listSources, record.source)
+	}
+
+	if len(listRecords) > 0 {
+		texts, err := s.translateList(ctx, listSources, glossary.Language, glossary)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(texts) != len(listRecords) {
+			return 0, 0, fmt.Errorf("translation returned %d lines for %d items", len(texts), len(listRecords))
+		}
+		for idx, record := range listRecords {
+			translated[record.id] = texts[idx]
+		}
+	}
+
+	updated, skipped := 0, 0
+	err := s.store.WithTx(ctx, func(tx *repository.Tx) error {
+		for _, record := range group {
+			text := strings.TrimSpace(translated[record.id])
+			if text == "" {
+				continue
+			}
+
+			applied, err := applyRetranslation(ctx, tx, record, text)
+			if err != nil {
+				return err
+			}
+
+			storedText := record.translated
+			if applied {
+				storedText = text
+				updated++
+				if record.entityType == translationEntityArticle {
+					if err := rescoreReadability(ctx, tx, record.entityID); err != nil {
+						return err
+					}
+				}
+			} else {
+				skipped++
+			}
+
+			query := "UPDATE translations SET translated_text = ?, glossary_version = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?"
+			if _, err := tx.ExecContext(ctx, query, storedText, glossary.Version, record.id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return updated, skipped, nil
+}
+
+// applyRetranslation swaps in the new text only while the entity still holds
+// the previous machine translation.
+func applyRetranslation(ctx context.Context, tx *repository.Tx, record translationRecord, text string) (bool, error) {
+	var table, column string
+	switch record.entityType {
+	case translationEntityFact:
+		table, column = "facts", "fact_text"
+	case translationEntityGap:
+		table, column = "gaps", "question"
+	case translationEntityArticle:
+		table, column = "articles", "article_text"
+	default:
+		return false, fmt.Errorf("unknown translation entity %q", record.entityType)
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ? AND %s = ?", table, column, column)
+	result, err := tx.ExecContext(ctx, query, text, record.entityID, record.translated)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func listStaleTranslations(ctx context.Context, store *repository.Store, language string, version int) ([]translationRecord, error) {
+	query := `
+		SELECT id, COALESCE(article_id, 0), entity_type, entity_id, source_text, translated_text
+		FROM translations
+		WHERE target_language = ? AND glossary_version < ?
+		ORDER BY article_id ASC, id ASC
+	`
+
+	rows, err := store.QueryContext(ctx, query, language, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]translationRecord, 0)
+	for rows.Next() {
+		var record translationRecord
+		if err := rows.Scan(&record.id, &record.articleID, &record.entityType, &record.entityID, &record.source, &record.translated); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func insertTranslations(
+	ctx context.Context,
+	tx *repository.Tx,
+	articleID int64,
+	language string,
+	glossaryVersion int,
+	records []translationRecord,
+) error {
+	query := `INSERT INTO translations (article_id, entity_type, entity_id, target_language, source_text, translated_text, glossary_version) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	for _, record := range records {
+		if record.entityID <= 0 || strings.TrimSpace(record.source) == "" || strings.TrimSpace(record.translated) == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			query,
+			articleID,
+			record.entityType,
+			record.entityID,
+			language,
+			strings.TrimSpace(record.source),
+			strings.TrimSpace(record.translated),
+			glossaryVersion,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pairTranslations lines up originals with their translations. Lists whose
+// lengths diverged cannot be paired reliably and are not tracked.
+func pairTranslations(entityType string, ids []int64, sources []string, translated []string) []translationRecord {
+	if len(ids) != len(sources) || len(sources) != len(translated) {
+		return nil
+	}
+
+	records := make([]translationRecord, 0, len(ids))
+	for idx, id := range ids {
+		records = append(records, translationRecord{
+			entityType: entityType,
+			entityID:   id,
+			source:     sources[idx],
+			translated: strings.TrimSpace(translated[idx]),
+		})
+	}
+	return records
+}
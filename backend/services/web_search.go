@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"nanoheads/config"
+)
+
+const searchQueryRunes = 300
+
+var searchEndpoints = map[string]string{
+	"bing":    "https://api.bing.microsoft.com/v7.0/search",
+	"brave":   "https://api.search.brave.com/res/v1/web/search",
+	"serpapi": "https://serpapi.com/search.json",
+}
+
+type webResult struct {
+	title   string
+	url     string
+	snippet string
+}
+
+type bingSearchResponse struct {
+	WebPages struct {
+		Value []struct {
+			Name    string `json:"name"`
+			URL     string `json:"url"`
+			Snippet string `json:"snippet"`
+		} `json:"value"`
+	} `json:"webPages"`
+}
+
+type braveSearchResponse struct {
+	Web struct {
+		Results []struct {
+			Title       string `json:"title"`
+			URL         string `json:"url"`
+			Description string `json:"description"`
+		} `json:"results"`
+	} `json:"web"`
+}
+
+type serpAPISearchResponse struct {
+	OrganicResults []struct {
+		Title   string `json:"title"`
+		Link    string `json:"link"`
+		Snippet string `json:"snippet"`
+	} `json:"organic_results"`
+}
+
+func webSearchEnabled() bool {
+	return config.Current().SearchProvider != ""
+}
+
+// searchWeb runs query against the configured search provider and returns
+// up to SearchResults web results, in the provider's order.
+func searchWeb(ctx context.Context, cfg config.Config, query string) ([]webResult, error) {
+	endpointURL := cfg.SearchURL
+	if endpointURL == "" {
+		endpointURL = searchEndpoints[cfg.SearchProvider]
+	}
+	endpoint, err := url.Parse(endpointURL)
+	if err != nil || endpointURL == "" {
+		return nil, fmt.Errorf("invalid search endpoint for provider %q", cfg.SearchProvider)
+	}
+
+	count := strconv.Itoa(cfg.SearchResults)
+	params := endpoint.Query()
+	switch cfg.SearchProvider {
+	case "bing":
+		params.Set("q", truncateRunes(query, searchQueryRunes))
+		params.Set("count", count)
+		params.Set("responseFilter", "Webpages")
+	case "brave":
+		params.Set("q", truncateRunes(query, searchQueryRunes))
+		params.Set("count", count)
+	case "serpapi":
+		params.Set("engine", "google")
+		params.Set("q", truncateRunes(query, searchQueryRunes))
+		params.Set("num", count)
+		params.Set("api_key", cfg.SearchAPIKey)
+	}
+	endpoint.RawQuery = params.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	switch cfg.SearchProvider {
+	case "bing":
+		request.Header.Set("Ocp-Apim-Subscription-Key", cfg.SearchAPIKey)
+	case "brave":
+		request.Header.Set("X-Subscription-Token", cfg.SearchAPIKey)
+	}
+
+	client := &http.Client{Timeout: cfg.SearchTimeout.Duration}
+	response, err := client.Do(request)
+	if err != nil {
+		// url.Error repeats the request URL, which can carry the API key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("search request failed: %w", urlErr.Err)
+		}
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 2<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%s search returned status %d: %s", cfg.SearchProvider, response.StatusCode, redactSensitive(truncateRunes(string(body), 300)))
+	}
+
+	results, err := parseSearchResults(cfg.SearchProvider, body)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s search response: %w", cfg.SearchProvider, err)
+	}
+	if len(results) > cfg.SearchResults {
+		results = results[:cfg.SearchResults]
+	}
+	return results, nil
+}
+
+func parseSearchResults(provider string, body []byte) ([]webResult, error) {
+	results := make([]webResult, 0)
+	add := func(title string, link string, snippet string) {
+		link = strings.TrimSpace(link)
+		if parsed, err := url.Parse(link); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return
+		}
+		results = append(results, webResult{
+			title:   sanitizeHTMLText(title),
+			url:     link,
+			snippet: sanitizeHTMLText(snippet),
+		})
+	}
+
+	switch provider {
+	case "bing":
+		var out bingSearchResponse
+		if err := json.Unmarshal(body, &out); err != nil {
+			return nil, err
+		}
+		for _, page := range out.WebPages.Value {
+			add(page.Name, page.URL, page.Snippet)
+		}
+	case "brave":
+		var out braveSearchResponse
+		if err := json.Unmarshal(body, &out); err != nil {
+			return nil, err
+		}
+		for _, page := range out.Web.Results {
+			add(page.Title, page.URL, page.Description)
+		}
+	case "serpapi":
+		var out serpAPISearchResponse
+		if err := json.Unmarshal(body, &out); err != nil {
+			return nil, err
+		}
+		for _, page := range out.OrganicResults {
+			add(page.Title, page.Link, page.Snippet)
+		}
+	default:
+		return nil, fmt.Errorf("unknown search provider %q", provider)
+	}
+	return results, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+// webhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+// body under WEBHOOK_SECRET.
+const webhookSignatureHeader = "X-Nanoheads-Signature"
+
+type pipelineEvent struct {
+	event     string
+	articleID int64
+	headline  string
+	detail    string
+}
+
+var pipelineEventTitles = map[string]string{
+	config.EventAnalysisFinished:  "New draft ready",
+	config.EventAnalysisFailed:    "Analysis failed",
+	config.EventAnalysisPublished: "Analysis published",
+}
+
+// announce posts event to the configured webhooks in the background.
+func announce(event pipelineEvent) {
+	cfg := config.Current()
+	if !cfg.WebhookEnabled(event.event) {
+		return
+	}
+	go deliverPipelineEvent(cfg, event)
+}
+
+func announcePhaseOneFailure(sourceURL string, err error) {
+	headline := "Submitted text"
+	if sourceURL != "" {
+		headline = sourceURL
+	}
+	announce(pipelineEvent{
+		event:    config.EventAnalysisFailed,
+		headline: headline,
+		detail:   truncateRunes(redactSensitive(err.Error()), 300),
+	})
+}
+
+func deliverPipelineEvent(cfg config.Config, event pipelineEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.WebhookTimeout.Duration)
+	defer cancel()
+
+	link := analysisLink(cfg, event.articleID)
+	if cfg.SlackWebhookURL != "" {
+		if err := postWebhook(ctx, cfg.SlackWebhookURL, slackMessage(event, link)); err != nil {
+			log.Printf("[webhooks] slack %s failed: %v", event.event, err)
+		}
+	}
+	if cfg.TeamsWebhookURL != "" {
+		if err := postWebhook(ctx, cfg.TeamsWebhookURL, teamsMessage(event, link)); err != nil {
+			log.Printf("[webhooks] teams %s failed: %v", event.event, err)
+		}
+	}
+	if cfg.WebhookURL != "" {
+		if err := postSignedWebhook(ctx, cfg.WebhookURL, cfg.WebhookSecret, jsonEvent(event, link, time.Now())); err != nil {
+			log.Printf("[webhooks] json %s failed: %v", event.event, err)
+		}
+	}
+}
+
+// analysisLink opens the analysis in the admin app, or is empty when
+// ADMIN_URL isn't set.
+func analysisLink(cfg config.Config, articleID int64) string {
+	if cfg.AdminURL == "" || articleID <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/new-analysis/%d", cfg.AdminURL, articleID)
+}
+
+func slackMessage(event pipelineEvent, link string) map[string]any {
+	headline := slackEscape(event.headline)
+	if link != "" {
+		headline = "<" + link + "|" + headline + ">"
+	}
+	lines := []string{"*" + pipelineEventTitles[event.event] + "*", headline}
+	if event.detail != "" {
+		lines = append(lines, slackEscape(event.detail))
+	}
+	return map[string]any{"text": strings.Join(lines, "\n")}
+}
+
+// slackEscape escapes the characters Slack treats as markup in message text.
+func slackEscape(value string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(value)
+}
+
+// teamsMessage is an Adaptive Card, which both Workflows webhooks and the
+// older Office 365 connectors accept.
+func teamsMessage(event pipelineEvent, link string) map[string]any {
+	body := []map[string]any{
+		{"type": "TextBlock", "text": pipelineEventTitles[event.event], "weight": "Bolder", "size": "Medium"},
+		{"type": "TextBlock", "text": event.headline, "wrap": true},
+	}
+	if event.detail != "" {
+		body = append(body, map[string]any{"type": "TextBlock", "text": event.detail, "wrap": true, "isSubtle": true})
+	}
+
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if link != "" {
+		card["actions"] = []map[string]any{{"type": "Action.OpenUrl", "title": "Open analysis", "url": link}}
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	}
+}
+
+// jsonEvent is the event in the schema documented for automation tools,
+// models.WebhookEvent.
+func jsonEvent(event pipelineEvent, link string, now time.Time) models.WebhookEvent {
+	payload := models.WebhookEvent{
+		ID:         "evt_" + newLLMRunID(),
+		Event:      event.event,
+		Version:    models.WebhookEventVersion,
+		OccurredAt: now.UTC(),
+		Data: models.WebhookEventData{
+			Headline: event.headline,
+			Detail:   event.detail,
+			URL:      link,
+		},
+	}
+	if event.articleID > 0 {
+		articleID := event.articleID
+		payload.Data.AnalysisID = &articleID
+	}
+	return payload
+}
+
+func postWebhook(ctx context.Context, webhookURL string, payload any) error {
+	return postSignedWebhook(ctx, webhookURL, "", payload)
+}
+
+// postSignedWebhook posts payload, signing the body when secret is set so
+// the receiver can check it came from here.
+func postSignedWebhook(ctx context.Context, webhookURL string, secret string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		request.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		// The webhook URL is a credential; keep it out of the logs.
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("status %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
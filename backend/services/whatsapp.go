@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanoheads/config"
+	"nanoheads/models"
+)
+
+type WhatsAppService struct {
+	jobs *JobService
+}
+
+func NewWhatsAppService(database *sql.DB) *WhatsAppService {
+	return &WhatsAppService{
+		jobs: NewJobService(database),
+	}
+}
+
+// whatsAppDelivery is the part of a WhatsApp Business webhook delivery the
+// intake reads. Deliveries also carry status updates for sent messages,
+// which are ignored.
+type whatsAppDelivery struct {
+	Entry []struct {
+		Changes []struct {
+			Field string `json:"field"`
+			Value struct {
+				Metadata struct {
+					DisplayPhoneNumber string `json:"display_phone_number"`
+				} `json:"metadata"`
+				Contacts []struct {
+					WaID    string `json:"wa_id"`
+					Profile struct {
+						Name string `json:"name"`
+					} `json:"profile"`
+				} `json:"contacts"`
+				Messages []whatsAppMessage `json:"messages"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type whatsAppMessage struct {
+	ID        string `json:"id"`
+	From      string `json:"from"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Image    *whatsAppMedia `json:"image"`
+	Video    *whatsAppMedia `json:"video"`
+	Document *whatsAppMedia `json:"document"`
+	Context  struct {
+		Forwarded           bool `json:"forwarded"`
+		FrequentlyForwarded bool `json:"frequently_forwarded"`
+	} `json:"context"`
+}
+
+type whatsAppMedia struct {
+	Caption string `json:"caption"`
+}
+
+// VerifySubscription answers Meta's check of the webhook URL, which echoes
+// the challenge back when the verify token matches.
+func (s *WhatsAppService) VerifySubscription(mode string, token string, challenge string) (string, bool) {
+	expected := config.Current().WhatsAppVerifyToken
+	if mode != "subscribe" || expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return "", false
+	}
+	return challenge, true
+}
+
+// VerifySignature checks X-Hub-Signature-256, the HMAC-SHA256 of the body
+// keyed with the app secret.
+func (s *WhatsAppService) VerifySignature(body []byte, header string) bool {
+	secret := config.Current().WhatsAppAppSecret
+	signature, ok := strings.CutPrefix(strings.TrimSpace(header), "sha256=")
+	if secret == "" || !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// HandleDelivery queues an analysis for every message in a delivery, tagged
+// with the number and profile name of whoever sent it. Messages with no
+// text, such as stickers or a photo without a caption, are skipped, as is
+// any message that can't be queued, so one bad tip doesn't hold up the rest.
+func (s *WhatsAppService) HandleDelivery(ctx context.Context, body []byte) (int, error) {
+	var delivery whatsAppDelivery
+	if err := json.Unmarshal(body, &delivery); err != nil {
+		return 0, invalidInput("invalid WhatsApp delivery")
+	}
+
+	language := config.Current().WhatsAppLanguage
+	queued := 0
+	for _, entry := range delivery.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			names := make(map[string]string, len(change.Value.Contacts))
+			for _, contact := range change.Value.Contacts {
+				names[contact.WaID] = contact.Profile.Name
+			}
+
+			for _, message := range change.Value.Messages {
+				text := strings.TrimSpace(whatsAppText(message))
+				if text == "" {
+					continue
+				}
+				clip := models.Clip{Language: language}
+				clip.Text, clip.URL = messageInput(text)
+				clip.Submission = whatsAppSubmission(message, names[message.From], change.Value.Metadata.DisplayPhoneNumber)
+
+				if _, err := EnqueueClip(ctx, s.jobs, clip, nil); err != nil {
+					log.Printf("[whatsapp] failed to queue message %s: %v", message.ID, err)
+					continue
+				}
+				queued++
+			}
+		}
+	}
+	return queued, nil
+}
+
+func whatsAppText(message whatsAppMessage) string {
+	switch message.Type {
+	case "text":
+		return message.Text.Body
+	case "image", "video", "document":
+		for _, media := range []*whatsAppMedia{message.Image, message.Video, message.Document} {
+			if media != nil {
+				return media.Caption
+			}
+		}
+	}
+	return ""
+}
+
+func whatsAppSubmission(message whatsAppMessage, name string, businessNumber string) *models.Submission {
+	params := map[string]any{
+		"sender":    message.From,
+		"messageId": message.ID,
+	}
+	if name != "" {
+		params["senderName"] = name
+	}
+	if businessNumber != "" {
+		params["businessNumber"] = businessNumber
+	}
+	if message.Context.Forwarded || message.Context.FrequentlyForwarded {
+		params["forwarded"] = true
+	}
+	if seconds, err := strconv.ParseInt(message.Timestamp, 10, 64); err == nil {
+		params["sentAt"] = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+	}
+	return &models.Submission{Channel: models.SubmissionChannelWhatsApp, Params: params}
+}